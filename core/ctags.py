@@ -0,0 +1,376 @@
+"""Ctags调用模块
+
+该模块封装了对Universal Ctags的调用，用于从C/C++源文件中提取函数的起止行：
+- 可执行文件查找与版本检查，只允许白名单中的程序名
+- 支持JSON输出时使用--output-format=json，否则解析制表符分隔的传统格式
+- 每次调用的超时控制
+- 参数校验，文件路径不会被解释为选项或经过shell
+- 系统中没有可用的ctags时，使用纯Python实现的函数提取作为后备方案
+
+作者: Re-Centris团队
+版本: 1.0.0
+许可证: MIT
+"""
+
+import os
+import re
+import json
+import shutil
+import logging
+import subprocess
+import threading
+from typing import Dict, List, Optional, Tuple
+
+# 获取模块日志记录器
+logger = logging.getLogger("re-centris.ctags")
+
+# 允许执行的ctags程序名
+ALLOWED_NAMES = {"ctags", "universal-ctags", "ctags-universal", "uctags"}
+
+# 默认的单次调用超时时间(秒)
+DEFAULT_TIMEOUT = 60
+
+# 版本检查的超时时间(秒)
+VERSION_TIMEOUT = 5
+
+# 函数提取使用的ctags参数；--options=NONE 避免读取用户或当前目录下的选项文件
+CTAGS_ARGS = ["--options=NONE", "-f", "-", "--kinds-C=*", "--fields=neKSt"]
+
+# 使用JSON输出时的ctags参数，额外输出作用域
+CTAGS_JSON_ARGS = ["--options=NONE", "-f", "-", "--output-format=json", "--kinds-C=*", "--fields=neKSst"]
+
+# 查找结果缓存，键为配置的路径
+_found: Dict[Optional[str], Optional[str]] = {}
+# JSON输出支持的检查结果缓存，键为ctags路径
+_json: Dict[str, bool] = {}
+_found_lock = threading.Lock()
+
+
+class CtagsError(Exception):
+    """ctags调用失败"""
+
+
+def find_ctags(configured: Optional[str] = None) -> Optional[str]:
+    """查找可用的Universal Ctags
+
+    Args:
+        configured: 配置的ctags路径或程序名，为空时从PATH中查找
+
+    Returns:
+        ctags的绝对路径，没有可用的ctags时返回None
+    """
+    with _found_lock:
+        if configured not in _found:
+            _found[configured] = _find(configured)
+        return _found[configured]
+
+
+def _find(configured: Optional[str]) -> Optional[str]:
+    """查找并检查ctags，不使用缓存"""
+    candidates = [configured] if configured else sorted(ALLOWED_NAMES)
+    for candidate in candidates:
+        path = shutil.which(candidate)
+        if not path:
+            if configured:
+                logger.warning(f"未找到配置的ctags: {configured}")
+            continue
+
+        path = os.path.abspath(path)
+        names = {os.path.basename(path), os.path.basename(os.path.realpath(path))}
+        if not names & ALLOWED_NAMES:
+            logger.warning(f"ctags程序名不在白名单中，已忽略: {path}")
+            continue
+
+        version = _version(path)
+        if version is None:
+            continue
+        if "Universal Ctags" not in version:
+            logger.warning(f"需要Universal Ctags，已忽略: {path} ({version})")
+            continue
+
+        logger.info(f"使用ctags: {path} ({version})")
+        return path
+
+    logger.warning("没有可用的Universal Ctags，使用内置的函数提取")
+    return None
+
+
+def _version(path: str) -> Optional[str]:
+    """返回ctags版本信息的第一行，无法执行时返回None"""
+    try:
+        result = subprocess.run(
+            [path, "--version"],
+            stdin=subprocess.DEVNULL,
+            stdout=subprocess.PIPE,
+            stderr=subprocess.DEVNULL,
+            timeout=VERSION_TIMEOUT,
+            check=True
+        )
+    except (OSError, subprocess.SubprocessError) as e:
+        logger.warning(f"无法执行ctags {path}: {str(e)}")
+        return None
+    lines = result.stdout.decode("utf-8", errors="replace").splitlines()
+    return lines[0].strip() if lines else ""
+
+
+def supports_json(ctags: str) -> bool:
+    """检查ctags是否支持--output-format=json
+
+    Universal Ctags只有在编译时链接了libjansson才支持JSON输出，
+    此时--list-features中包含json
+    """
+    with _found_lock:
+        if ctags not in _json:
+            _json[ctags] = _has_json_feature(ctags)
+        return _json[ctags]
+
+
+def _has_json_feature(ctags: str) -> bool:
+    """执行ctags --list-features并检查json特性，不使用缓存"""
+    try:
+        result = subprocess.run(
+            [ctags, "--list-features"],
+            stdin=subprocess.DEVNULL,
+            stdout=subprocess.PIPE,
+            stderr=subprocess.DEVNULL,
+            timeout=VERSION_TIMEOUT,
+            check=True
+        )
+    except (OSError, subprocess.SubprocessError) as e:
+        logger.warning(f"无法获取ctags特性列表 {ctags}: {str(e)}")
+        return False
+    for line in result.stdout.decode("utf-8", errors="replace").splitlines():
+        fields = line.split()
+        if fields and fields[0] == "json":
+            return True
+    return False
+
+
+def _check_path(file_path: str) -> str:
+    """校验待解析的文件路径并返回其绝对路径
+
+    绝对路径以"/"开头，不会被ctags解释为选项
+    """
+    if not isinstance(file_path, str) or not file_path:
+        raise CtagsError(f"无效的文件路径: {file_path!r}")
+    if any(c in file_path for c in "\0\n\r"):
+        raise CtagsError(f"文件路径包含非法字符: {file_path!r}")
+
+    path = os.path.abspath(file_path)
+    if not os.path.isfile(path):
+        raise CtagsError(f"不是普通文件: {file_path}")
+    return path
+
+
+def run_ctags(ctags: str, file_path: str, timeout: float = DEFAULT_TIMEOUT,
+              json_output: bool = False) -> str:
+    """对单个文件运行ctags并返回其输出
+
+    Args:
+        ctags: find_ctags返回的ctags路径
+        file_path: 待解析的文件路径
+        timeout: 超时时间(秒)
+        json_output: 是否使用JSON输出格式
+
+    Raises:
+        CtagsError: 路径无效、ctags失败或超时
+    """
+    path = _check_path(file_path)
+    try:
+        result = subprocess.run(
+            [ctags] + (CTAGS_JSON_ARGS if json_output else CTAGS_ARGS) + [path],
+            stdin=subprocess.DEVNULL,
+            stdout=subprocess.PIPE,
+            stderr=subprocess.PIPE,
+            timeout=timeout
+        )
+    except subprocess.TimeoutExpired:
+        raise CtagsError(f"ctags处理超时({timeout}秒): {file_path}")
+    except OSError as e:
+        raise CtagsError(f"无法执行ctags: {str(e)}")
+
+    if result.returncode != 0:
+        stderr = result.stderr.decode("utf-8", errors="replace").strip()
+        raise CtagsError(f"ctags退出码{result.returncode}: {stderr}")
+    return result.stdout.decode("utf-8", errors="replace")
+
+
+def parse_ctags_output(output: str) -> List[Tuple[int, int]]:
+    """从ctags输出中解析函数的起止行
+
+    Returns:
+        [(起始行, 结束行), ...]
+    """
+    functions = []
+    for line in output.splitlines():
+        fields = line.split("\t")
+        if len(fields) < 4:
+            continue
+
+        extensions = {}
+        for field in fields[3:]:
+            key, sep, value = field.partition(":")
+            if sep:
+                extensions[key] = value
+            elif field:
+                extensions["kind"] = field
+
+        if extensions.get("kind") != "function":
+            continue
+        try:
+            functions.append((int(extensions["line"]), int(extensions["end"])))
+        except (KeyError, ValueError):
+            continue
+    return functions
+
+
+def parse_ctags_json(output: str) -> List[Dict[str, object]]:
+    """解析ctags的JSON输出
+
+    每行是一个JSON对象，只保留_type为tag的条目；无法解析的行会被跳过。
+
+    Returns:
+        [{"name", "kind", "line", "end", "scope", "scope_kind"}, ...]，
+        缺少的字段为None
+    """
+    tags = []
+    for line in output.splitlines():
+        line = line.strip()
+        if not line:
+            continue
+        try:
+            entry = json.loads(line)
+        except ValueError:
+            logger.debug(f"无法解析的ctags输出: {line}")
+            continue
+        if not isinstance(entry, dict) or entry.get("_type", "tag") != "tag":
+            continue
+
+        tags.append({
+            "name": entry.get("name"),
+            "kind": entry.get("kind"),
+            "line": _int_field(entry.get("line")),
+            "end": _int_field(entry.get("end")),
+            "scope": entry.get("scope"),
+            "scope_kind": entry.get("scopeKind"),
+        })
+    return tags
+
+
+def _int_field(value) -> Optional[int]:
+    """将行号字段转换为整数，无效时返回None"""
+    if isinstance(value, bool):
+        return None
+    if isinstance(value, int):
+        return value
+    if isinstance(value, str) and value.isdigit():
+        return int(value)
+    return None
+
+
+def functions_from_tags(tags: List[Dict[str, object]]) -> List[Tuple[int, int]]:
+    """从parse_ctags_json的结果中取出函数的起止行"""
+    return [
+        (tag["line"], tag["end"])
+        for tag in tags
+        if tag["kind"] == "function" and tag["line"] is not None and tag["end"] is not None
+    ]
+
+
+# 不是函数定义的控制语句关键字
+_CONTROL_KEYWORDS = {"if", "for", "while", "switch", "catch", "return", "sizeof", "do", "else"}
+
+# 函数头: 名称(参数)后可跟限定符、初始化列表或尾置返回类型
+_FUNCTION_PATTERN = re.compile(r'([A-Za-z_~][\w:~<>]*|operator\s*[^\s(]+)\s*\([^;{}]*\)[^;{}()=]*(:[^;{}]*)?\s*$')
+
+
+def _strip_comments_and_strings(content: str) -> str:
+    """将注释和字符串字面量替换为空格，保留换行以维持行号"""
+    def blank(match):
+        return re.sub(r'[^\n]', ' ', match.group(0))
+
+    pattern = re.compile(
+        r'//[^\n]*|/\*.*?\*/|"(?:\\.|[^"\\\n])*"|\'(?:\\.|[^\'\\\n])*\'|^[ \t]*#[^\n]*',
+        re.DOTALL | re.MULTILINE
+    )
+    return pattern.sub(blank, content)
+
+
+def find_functions(content: str) -> List[Tuple[int, int]]:
+    """不依赖ctags，从C/C++源码中提取函数定义的起止行
+
+    识别形如"名称(参数) {"的块，跳过控制语句，并进入namespace、class等
+    可以包含函数定义的块。精度低于ctags，仅作为后备方案。
+
+    Returns:
+        [(起始行, 结束行), ...]
+    """
+    code = _strip_comments_and_strings(content)
+    functions = []
+    header_start = 0
+    i = 0
+    while i < len(code):
+        c = code[i]
+        if c in ";}":
+            header_start = i + 1
+        elif c == "{":
+            header = code[header_start:i]
+            match = _FUNCTION_PATTERN.search(header)
+            name = match.group(1).split("::")[-1] if match else ""
+            if match and name not in _CONTROL_KEYWORDS:
+                end = _matching_brace(code, i)
+                start_line = code.count("\n", 0, header_start + match.start(1)) + 1
+                end_line = code.count("\n", 0, end) + 1
+                functions.append((start_line, end_line))
+                i = end
+                header_start = end + 1
+            else:
+                # namespace、class等块，继续在块内查找
+                header_start = i + 1
+        i += 1
+    return functions
+
+
+def _matching_brace(code: str, start: int) -> int:
+    """返回与start处的"{"匹配的"}"的位置，未闭合时返回最后一个字符的位置"""
+    depth = 0
+    for i in range(start, len(code)):
+        if code[i] == "{":
+            depth += 1
+        elif code[i] == "}":
+            depth -= 1
+            if depth == 0:
+                return i
+    return len(code) - 1
+
+
+def extract_functions(file_path: str, content: Optional[str] = None,
+                      ctags: Optional[str] = None,
+                      timeout: float = DEFAULT_TIMEOUT) -> List[Tuple[int, int]]:
+    """提取文件中函数定义的起止行
+
+    有可用的ctags时使用ctags，支持时使用JSON输出；否则或ctags失败时
+    使用find_functions
+
+    Args:
+        file_path: 源文件路径
+        content: 文件内容，为空时从file_path读取
+        ctags: find_ctags返回的ctags路径
+        timeout: ctags超时时间(秒)
+
+    Returns:
+        [(起始行, 结束行), ...]
+    """
+    if ctags:
+        try:
+            if supports_json(ctags):
+                output = run_ctags(ctags, file_path, timeout, json_output=True)
+                return functions_from_tags(parse_ctags_json(output))
+            return parse_ctags_output(run_ctags(ctags, file_path, timeout))
+        except CtagsError as e:
+            logger.warning(f"{str(e)}，使用内置的函数提取")
+
+    if content is None:
+        with open(file_path, "r", encoding="utf-8", errors="replace") as f:
+            content = f.read()
+    return find_functions(content)
@@ -7,7 +7,6 @@
 # 导入必要的库
 import os
 import sys
-import subprocess
 import re
 import shutil
 import json
@@ -17,6 +16,13 @@ import multiprocessing
 import concurrent.futures
 import logging
 import datetime
+
+# 添加项目根目录到Python路径
+project_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+if project_root not in sys.path:
+    sys.path.insert(0, project_root)
+from core.ctags import find_ctags, extract_functions
+
 """全局变量定义"""
 # 获取当前文件所在的目录
 current_file_path = os.path.abspath(__file__)
@@ -33,7 +39,7 @@ finalDBPath = analyse_file_dir + "/preprocessor/componentDB/"  # 最终组件数
 metaPath = analyse_file_dir + "/preprocessor/metaInfos/"  # 元信息路径
 aveFuncPath = metaPath + "aveFuncs"  # 平均函数数量文件路径
 weightPath = metaPath + "weights/"  # 权重文件路径
-ctagsPath = "/usr/local/bin/ctags"  # ctags工具路径
+ctagsPath = os.environ.get("CTAGS_PATH")  # ctags工具路径，为空时从PATH中查找
 log_path = analyse_file_dir + "/logs/detector"                           # 创建日志目录
 
 # 生成目录
@@ -141,55 +147,45 @@ def process_single_file(file_info):
     line_count = 0
     
     try:
-        # 使用ctags提取函数信息
-        functionList = subprocess.check_output(ctagsPath + ' -f - --kinds-C=* --fields=neKSt "' + filePath + '"',
-                                            stderr=subprocess.STDOUT, shell=True).decode()
-
         # 打开并读取源文件内容
         with open(filePath, 'r', encoding="UTF-8") as f:
             lines = f.readlines()
 
+        # 提取函数起止行，没有可用的ctags时使用内置的函数提取
+        functionList = extract_functions(filePath, "".join(lines), ctags=find_ctags(ctagsPath))
+
         # 初始化函数解析变量
-        allFuncs = str(functionList).split('\n')
-        func = re.compile(r'(function)')
-        number = re.compile(r'(\d+)')
         funcSearch = re.compile(r'{([\S\s]*)}')
 
         file_count = 1
 
         # 处理文件中的每个函数
-        for i in allFuncs:
-            elemList = re.sub(r'[\t\s ]{2,}', '', i)
-            elemList = elemList.split('\t')
+        for funcStartLine, funcEndLine in functionList:
             funcBody = ""
 
-            if i != '' and len(elemList) >= 8 and func.fullmatch(elemList[3]):
-                funcStartLine = int(number.search(elemList[4]).group(0))
-                funcEndLine = int(number.search(elemList[7]).group(0))
-
-                tmpString = "".join(lines[funcStartLine - 1 : funcEndLine])
+            tmpString = "".join(lines[funcStartLine - 1 : funcEndLine])
 
-                if funcSearch.search(tmpString):
-                    funcBody = funcBody + funcSearch.search(tmpString).group(1)
-                else:
-                    funcBody = " "
+            if funcSearch.search(tmpString):
+                funcBody = funcBody + funcSearch.search(tmpString).group(1)
+            else:
+                funcBody = " "
 
-                funcBody = removeComment(funcBody)
-                funcBody = normalize(funcBody)
-                funcHash = computeTlsh(funcBody)
+            funcBody = removeComment(funcBody)
+            funcBody = normalize(funcBody)
+            funcHash = computeTlsh(funcBody)
 
-                if len(funcHash) == 72 and funcHash.startswith("T1"):
-                    funcHash = funcHash[2:]
-                elif funcHash == "TNULL" or funcHash == "" or funcHash == "NULL":
-                    continue
+            if len(funcHash) == 72 and funcHash.startswith("T1"):
+                funcHash = funcHash[2:]
+            elif funcHash == "TNULL" or funcHash == "" or funcHash == "NULL":
+                continue
 
-                storedPath = filePath.replace(repoPath, "")
-                if funcHash not in file_result:
-                    file_result[funcHash] = []
-                file_result[funcHash].append(storedPath)
+            storedPath = filePath.replace(repoPath, "")
+            if funcHash not in file_result:
+                file_result[funcHash] = []
+            file_result[funcHash].append(storedPath)
 
-                line_count += len(lines)
-                func_count += 1
+            line_count += len(lines)
+            func_count += 1
 
     except Exception as e:
         logging.error(f"处理文件 {filePath} 时出错: {e}")
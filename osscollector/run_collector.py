@@ -22,6 +22,12 @@ import signal
 import time
 import concurrent.futures.process
 
+# 导入核心模块
+project_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+if project_root not in sys.path:
+    sys.path.insert(0, project_root)
+from core.ctags import find_ctags, extract_functions
+
 """全局变量"""
 # 获取当前文件所在的目录
 current_file_path = os.path.abspath(__file__)
@@ -37,7 +43,7 @@ result_path	= oss_collector_path + "/repo_functions"	# 存储结果的路径
 log_path = analyse_file_dir +  "/logs/oss_collector"  # 日志存储目录
 status_path = oss_collector_path + "/status.json" # 状态文件路径
 temp_path = oss_collector_path + "/temp"  # 临时文件目录
-ctags_path	= "/usr/local/bin/ctags" 			# Ctags工具的路径,用于解析C/C++代码
+ctags_path	= os.environ.get("CTAGS_PATH")	# Ctags工具的路径,用于解析C/C++代码,为空时从PATH中查找
 
 
 # 创建必要的目录
@@ -278,36 +284,11 @@ def process_single_file(file_path: str, base_path: str) -> Tuple[List[Tuple[str,
         lines = content.splitlines()
         line_count = len(lines)
 
-        # 使用转换后的文件进行ctags处理
-        try:
-            functionList = subprocess.check_output(
-                f'{ctags_path} -f - --kinds-C=* --fields=neKSt "{temp_file}"',
-                stderr=subprocess.STDOUT,
-                shell=True,
-                timeout=300
-            ).decode('utf-8')
-        except subprocess.TimeoutExpired:
-            logging.warning(f"处理文件超时: {file_path}")
-            return [], 0, 0, 0
-
-        # 预编译正则表达式
-        func_pattern = re.compile(r'(function)')
-        number_pattern = re.compile(r'(\d+)')
+        # 使用转换后的文件提取函数，没有可用的ctags时使用内置的函数提取
+        functions = extract_functions(temp_file, content,
+                                      ctags=find_ctags(ctags_path), timeout=300)
         func_search_pattern = re.compile(r'{([\S\s]*)}')
 
-        # 批量处理函数
-        functions = []
-        for i in str(functionList).split('\n'):
-            if not i:
-                continue
-                
-            elemList = re.sub(r'[\t\s ]{2,}', '', i).split('\t')
-            if len(elemList) >= 8 and func_pattern.fullmatch(elemList[3]):
-                functions.append((
-                    int(number_pattern.search(elemList[4]).group(0)),
-                    int(number_pattern.search(elemList[7]).group(0))
-                ))
-
         # 批量处理函数体
         for start_line, end_line in functions:
             try:
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"os"
 
 	"github.com/re-centris/re-centris-go/internal/cmd"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
+		var exitErr *cmd.ExitError
+		if errors.As(err, &exitErr) {
+			log.Print(err)
+			os.Exit(exitErr.Code)
+		}
 		log.Fatal(err)
 	}
-} 
\ No newline at end of file
+}
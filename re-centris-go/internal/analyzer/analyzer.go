@@ -1,69 +1,213 @@
 package analyzer
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser/cpp"
 	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/bufpool"
+	"github.com/re-centris/re-centris-go/internal/common/cache"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/intern"
 	"github.com/re-centris/re-centris-go/internal/common/logger"
-	"golang.org/x/sync/errgroup"
+	"github.com/re-centris/re-centris-go/internal/common/progress"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"go.uber.org/zap"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 // FileInfo represents information about an analyzed file
 type FileInfo struct {
-	Path     string
-	Language string
-	Hash     *tlsh.TLSH
-	Size     int64
+	Path      string
+	Language  string
+	Hash      *tlsh.TLSH
+	Size      int64
+	Functions []parser.Function
+	// Declarations are the functions only declared, collected with
+	// AnalyzerOptions.PairDeclarations
+	Declarations []parser.Declaration
+	// Digest is the SHA-256 of the raw content and NormalizedDigest the
+	// SHA-256 of its token stream, see normalize.TokenStream. They tell
+	// exact and renamed copies apart from near-misses.
+	Digest           string
+	NormalizedDigest string
+	// Component is the component of a known file if it was recorded with
+	// its signatures; otherwise it is derived from Path
+	Component string
+}
+
+// Signature modes
+const (
+	// SignatureBytes hashes the (optionally normalized) bytes of files
+	SignatureBytes = "bytes"
+	// SignatureTokens hashes token streams with identifiers and literals
+	// abstracted, see normalize.TokenStream
+	SignatureTokens = "tokens"
+)
+
+// ParseSignatureMode validates a signature mode; empty means SignatureBytes
+func ParseSignatureMode(mode string) (string, error) {
+	switch mode {
+	case "", SignatureBytes:
+		return SignatureBytes, nil
+	case SignatureTokens:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported signature mode: %s", mode)
+	}
 }
 
 // AnalyzerOptions contains options for the analyzer
 type AnalyzerOptions struct {
 	MaxWorkers int
-	Languages  map[string][]string // map of language to file extensions
+	// Pool, if set, is shared with other stages and limits the files
+	// analyzed at once across them
+	Pool      *workpool.Pool
+	Languages map[string][]string    // map of language to file extensions
+	Skip      func(path string) bool // optional, skips matching files in AnalyzeDirectory
+	// Symlinks is SymlinkFollow (default) or SymlinkSkip
+	Symlinks string
+	// Include, if set, restricts the walked files to those matching a
+	// pattern; Exclude skips matching files and directories. Patterns use
+	// .gitignore syntax.
+	Include []string
+	Exclude []string
+	// GitIgnore also skips the paths ignored by .gitignore files
+	GitIgnore bool
+	// NoSniff disables skipping binary, minified and generated files
+	NoSniff bool
+	// SniffLanguages detects the language of files without a supported
+	// extension from their content, see DetectLanguage
+	SniffLanguages bool
+	// Normalize selects the normalization applied before hashing by language
+	Normalize map[string]normalize.Options
+	// SignatureMode is SignatureBytes (default) or SignatureTokens
+	SignatureMode string
+	// MaxFileSize, if positive, skips larger files as KindOversize
+	// without reading them
+	MaxFileSize int64
+	// ChunkedSize, if positive, hashes files of at least this size in
+	// chunks instead of reading them into memory, see analyzeChunked
+	ChunkedSize int64
+	// FileTimeout, if positive, stops the analysis of a file taking
+	// longer, see withDeadline
+	FileTimeout time.Duration
+	// SlowFiles, if set, collects the files slow to analyze
+	SlowFiles *SlowFileReport
+	// Encoding is EncodingRaw (default), EncodingAuto or the charset that
+	// files which are not UTF-8 are transcoded from, see ParseEncoding
+	Encoding string
+	// Conditionals selects the handling of conditional compilation by the
+	// C/C++ parser, see cpp.ParseConditionals
+	Conditionals string
+	// PairDeclarations links C/C++ definitions to their prototypes in the
+	// headers next to them, see PairDeclarations
+	PairDeclarations bool
+	// MinFunctionLines, MinFunctionTokens and MinFunctionComplexity, if
+	// positive, drop the functions below them, such as getters, see
+	// filterFunctions
+	MinFunctionLines      int
+	MinFunctionTokens     int
+	MinFunctionComplexity int
+	// Cache, if set, persists the analysis of file contents across runs
+	Cache    *cache.DiskCache
+	Progress *progress.Reporter // optional, reports the progress of AnalyzeDirectory
+	// ErrorPolicy decides whether AnalyzeDirectory aborts on a failed file
+	ErrorPolicy ErrorPolicy
+	// Errors, if set, collects the files skipped under ErrorPolicy
+	Errors *ErrorReport
 }
 
 // Analyzer handles code analysis
 type Analyzer struct {
-	opts AnalyzerOptions
+	opts    AnalyzerOptions
+	parsers *parser.Registry
+	names   *intern.Pool // function names repeat across files and versions
+	skipped skipStats
+	// normalizers holds the normalizers of the languages in opts.Normalize
+	normalizers map[string]*normalize.Normalizer
+	// encoding is the charset named by opts.Encoding, nil for auto and raw
+	encoding encoding.Encoding
 }
 
 // New creates a new Analyzer
 func New(opts AnalyzerOptions) *Analyzer {
-	return &Analyzer{opts: opts}
+	parsers := parser.NewRegistry()
+	parsers.Register(cpp.New(cpp.Options{Conditionals: opts.Conditionals}))
+
+	normalizers := make(map[string]*normalize.Normalizer)
+	for language, o := range opts.Normalize {
+		if o.Enabled() {
+			normalizers[language] = normalize.New(language, o)
+		}
+	}
+
+	a := &Analyzer{
+		opts:        opts,
+		parsers:     parsers,
+		names:       intern.New(),
+		normalizers: normalizers,
+	}
+	if name, err := ParseEncoding(opts.Encoding); err == nil && name != EncodingRaw && name != EncodingAuto {
+		a.encoding, _ = htmlindex.Get(name)
+	}
+	return a
 }
 
-// AnalyzeFile analyzes a single file and returns its FileInfo
-func (a *Analyzer) AnalyzeFile(ctx context.Context, path string) (*FileInfo, error) {
-	// Get file extension
+// DefaultLanguages returns the file extensions of the supported languages
+func DefaultLanguages() map[string][]string {
+	return map[string][]string{
+		"cpp":    {".c", ".cc", ".cpp", ".cxx", ".h", ".hpp"},
+		"java":   {".java"},
+		"python": {".py"},
+	}
+}
+
+// Language returns the language of a file based on its extension,
+// or an empty string if the extension is not supported
+func (a *Analyzer) Language(path string) string {
 	ext := strings.ToLower(filepath.Ext(path))
-	
-	// Find language for this extension
-	var language string
 	for lang, exts := range a.opts.Languages {
 		for _, e := range exts {
 			if e == ext {
-				language = lang
-				break
+				return lang
 			}
 		}
-		if language != "" {
-			break
-		}
 	}
+	return ""
+}
 
-	if language == "" {
-		return nil, fmt.Errorf("unsupported file extension: %s", ext)
+// AnalyzeFile analyzes a single file and returns its FileInfo
+func (a *Analyzer) AnalyzeFile(ctx context.Context, path string) (*FileInfo, error) {
+	return a.withDeadline(ctx, path, func(ctx context.Context) (*FileInfo, error) {
+		return a.analyzeFile(ctx, path)
+	})
+}
+
+// analyzeFile reads and analyzes a file
+func (a *Analyzer) analyzeFile(ctx context.Context, path string) (*FileInfo, error) {
+	// Find language for this file
+	language := a.Language(path)
+	if language == "" && !(a.opts.SniffLanguages && sniffable(path)) {
+		return nil, fmt.Errorf("unsupported file extension: %s", filepath.Ext(path))
 	}
 
-	// Open and read file
-	file, err := os.Open(path)
+	// Open and read file within the open file budget
+	file, err := fsutil.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %v", err)
 	}
@@ -74,68 +218,200 @@ func (a *Analyzer) AnalyzeFile(ctx context.Context, path string) (*FileInfo, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file stats: %v", err)
 	}
+	if a.opts.MaxFileSize > 0 && stat.Size() > a.opts.MaxFileSize {
+		return nil, a.skipOversize(path, stat.Size())
+	}
+	if a.opts.ChunkedSize > 0 && stat.Size() >= a.opts.ChunkedSize {
+		return a.analyzeChunked(ctx, path, language, file, stat.Size())
+	}
 
-	// Read file content
-	content, err := io.ReadAll(file)
+	// Read file content into a pooled buffer; analyzeContent keeps no
+	// references to it
+	content, err := bufpool.ReadAll(file, stat.Size())
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %v", err)
 	}
+	defer bufpool.Put(content)
+
+	if language == "" {
+		if language = a.sniffLanguage(content.Bytes()); language == "" {
+			return nil, fmt.Errorf("unrecognized language: %s", path)
+		}
+	}
+
+	return a.analyzeContent(ctx, path, language, content.Bytes(), stat.Size())
+}
 
-	// Calculate TLSH hash
-	hash, err := tlsh.New(content)
+// analyzeContent hashes the content of a file and extracts its functions.
+// Skipped content returns an error satisfying IsSkipped, and parsing stops
+// with the error of ctx once it is done.
+func (a *Analyzer) analyzeContent(ctx context.Context, path, language string, content []byte, size int64) (*FileInfo, error) {
+	// The digest identifies the file as read, the analysis covers its text
+	contentDigest := digest(content)
+	content = a.toUTF8(content)
+
+	if !a.opts.NoSniff {
+		if kind := Classify(content); kind != "" {
+			err := &SkippedError{Kind: kind}
+			a.skipped.add(err)
+			return nil, err
+		}
+	}
+
+	// Content analyzed before, by this or an earlier run, is not recomputed
+	if file, ok := a.cached(path, language, contentDigest, size); ok {
+		a.filterFunctions(file)
+		return file, nil
+	}
+
+	// The token stream is hashed in token mode and digested in either
+	tokens := bufpool.Get()
+	defer bufpool.Put(tokens)
+	normalize.WriteTokenStream(tokens, language, content)
+
+	// Calculate TLSH hash of the normalized content
+	input := tokens.Bytes()
+	if a.opts.SignatureMode != SignatureTokens {
+		input = a.signatureInput(language, content)
+	}
+	hash, err := tlsh.New(input)
+	if err == tlsh.ErrDataTooSmall {
+		a.skipped.add(err)
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate TLSH hash: %v", err)
 	}
 
-	return &FileInfo{
-		Path:     path,
-		Language: language,
-		Hash:     hash,
-		Size:     stat.Size(),
-	}, nil
+	// Extract functions if a parser is available for this language
+	var (
+		functions    []parser.Function
+		declarations []parser.Declaration
+	)
+	if p, ok := a.parsers.Get(language); ok {
+		if cp, ok := p.(parser.ContextParser); ok {
+			functions, declarations, err = cp.ParseContext(ctx, bytes.NewReader(content))
+			if !a.opts.PairDeclarations {
+				declarations = nil
+			}
+		} else if dp, ok := p.(parser.DeclarationParser); ok && a.opts.PairDeclarations {
+			functions, declarations, err = dp.ParseWithDeclarations(bytes.NewReader(content))
+		} else {
+			functions, err = p.Parse(bytes.NewReader(content))
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			logger.Warn("Failed to parse functions",
+				zap.String("path", path),
+				zap.Error(err))
+		}
+		for i := range functions {
+			functions[i].Name = a.names.Intern(functions[i].Name)
+			if a.transforms(language) {
+				functions[i].Hash = ""
+				if h, err := tlsh.New(a.signatureInput(language, []byte(functions[i].Content))); err == nil {
+					functions[i].Hash = h.String()
+				}
+			}
+		}
+	}
+
+	file := &FileInfo{
+		Path:             path,
+		Language:         language,
+		Hash:             hash,
+		Size:             size,
+		Functions:        functions,
+		Declarations:     declarations,
+		Digest:           contentDigest,
+		NormalizedDigest: digest(tokens.Bytes()),
+	}
+	a.store(file)
+	a.filterFunctions(file)
+	return file, nil
+}
+
+// filterFunctions drops the functions of a file with fewer lines or
+// tokens or a lower complexity than the thresholds. It runs after caching,
+// so cached analyses serve any thresholds; functions the parser does not
+// measure are only filtered by lines.
+func (a *Analyzer) filterFunctions(file *FileInfo) {
+	if a.opts.MinFunctionLines <= 0 && a.opts.MinFunctionTokens <= 0 && a.opts.MinFunctionComplexity <= 0 {
+		return
+	}
+	kept := make([]parser.Function, 0, len(file.Functions))
+	for _, fn := range file.Functions {
+		if fn.EndLine-fn.StartLine+1 < a.opts.MinFunctionLines {
+			continue
+		}
+		if fn.Tokens > 0 && (fn.Tokens < a.opts.MinFunctionTokens || fn.Complexity < a.opts.MinFunctionComplexity) {
+			continue
+		}
+		kept = append(kept, fn)
+	}
+	file.Functions = kept
+}
+
+// digest returns the hex-encoded SHA-256 of data
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signatureInput returns the bytes hashed for content of a language: its
+// token stream in token mode, otherwise the normalized content
+func (a *Analyzer) signatureInput(language string, content []byte) []byte {
+	if a.opts.SignatureMode == SignatureTokens {
+		return normalize.TokenStream(language, content)
+	}
+	if n := a.normalizers[language]; n != nil {
+		return n.Normalize(content)
+	}
+	return content
+}
+
+// transforms reports whether content of a language is transformed before
+// hashing, so function hashes computed by the parser must be replaced
+func (a *Analyzer) transforms(language string) bool {
+	return a.opts.SignatureMode == SignatureTokens || a.normalizers[language] != nil
 }
 
-// AnalyzeDirectory analyzes all files in a directory and its subdirectories
+// AnalyzeDirectory analyzes all files in a directory and its subdirectories.
+// A zip or tar archive is analyzed like a directory, see AnalyzeArchive.
 func (a *Analyzer) AnalyzeDirectory(ctx context.Context, dir string) ([]*FileInfo, error) {
+	if IsArchive(dir) {
+		return a.AnalyzeArchive(ctx, dir)
+	}
+
 	var (
 		files    []*FileInfo
 		filesMux sync.Mutex
+		failed   atomic.Int64
 	)
 
-	// Create error group with context and worker limit
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(a.opts.MaxWorkers)
-
-	// Walk through directory
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	// Create worker group with context and worker limit
+	g, ctx := workpool.WithContext(ctx, a.opts.Pool, a.opts.MaxWorkers)
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+	// The total grows while the directory is walked
+	stage := a.opts.Progress.Stage("analyze", 0)
 
-		// Check if context is cancelled
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+	// Walk through directory
+	err := a.walkDirectory(ctx, dir, &failed, func(path string, info os.FileInfo) error {
+		stage.AddTotal(1)
 
 		// Process file in goroutine
 		g.Go(func() error {
+			defer stage.Add(1)
+
 			fileInfo, err := a.AnalyzeFile(ctx, path)
 			if err != nil {
-				if err == tlsh.ErrDataTooSmall {
-					// Skip files that are too small
+				if IsSkipped(err) {
+					// Skip files that are too small or not worth hashing
 					return nil
 				}
-				logger.Error("Failed to analyze file",
-					zap.String("path", path),
-					zap.Error(err))
-				return err
+				return a.fileError(path, err, &failed)
 			}
 
 			// Add file info to results
@@ -150,21 +426,53 @@ func (a *Analyzer) AnalyzeDirectory(ctx context.Context, dir string) ([]*FileInf
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk directory: %v", err)
+		return nil, err
 	}
 
 	// Wait for all goroutines to complete
 	if err := g.Wait(); err != nil {
 		return nil, fmt.Errorf("error while analyzing files: %v", err)
 	}
+	stage.Done()
+
+	// Files finish in any order, sort them for reproducible output
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	if a.opts.PairDeclarations {
+		PairDeclarations(files)
+	}
 
 	return files, nil
 }
 
+// fileError handles a file that failed to analyze according to the error
+// policy and returns the error to abort the run with, if any. failed counts
+// the failed files of the run.
+func (a *Analyzer) fileError(path string, err error, failed *atomic.Int64) error {
+	policy := a.opts.ErrorPolicy
+	if !policy.Skip {
+		logger.Error("Failed to analyze file",
+			zap.String("path", path),
+			zap.Error(err))
+		return err
+	}
+
+	logger.Warn("Skipping file that failed to analyze",
+		zap.String("path", path),
+		zap.Error(err))
+	a.opts.Errors.Add(path, err)
+
+	if n := failed.Add(1); policy.MaxErrors > 0 && n > int64(policy.MaxErrors) {
+		return fmt.Errorf("too many errors: %d files failed to analyze", n)
+	}
+	return nil
+}
+
 // FindSimilarFiles finds files similar to the target file
 func (a *Analyzer) FindSimilarFiles(target *FileInfo, candidates []*FileInfo, threshold int) []*FileInfo {
 	var similar []*FileInfo
-	
+
 	for _, candidate := range candidates {
 		// Skip same file
 		if target.Path == candidate.Path {
@@ -184,4 +492,4 @@ func (a *Analyzer) FindSimilarFiles(target *FileInfo, candidates []*FileInfo, th
 	}
 
 	return similar
-} 
\ No newline at end of file
+}
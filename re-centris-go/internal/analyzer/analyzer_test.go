@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/common/cache"
+)
+
+// benchmarkSource returns a C source file of about size bytes
+func benchmarkSource(size int) []byte {
+	var b strings.Builder
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "int function_%d(int a, int b) {\n\tint sum = a + b * %d;\n\treturn sum - %d;\n}\n\n", i, i, i*7)
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkAnalyzeFile(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "bench.c")
+	if err := os.WriteFile(path, benchmarkSource(64<<10), 0644); err != nil {
+		b.Fatal(err)
+	}
+	a := New(AnalyzerOptions{Languages: map[string][]string{"c": {".c"}}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.AnalyzeFile(context.Background(), path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAnalyzeDirectory(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 50; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.c", i)), benchmarkSource(16<<10+i*512), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	a := New(AnalyzerOptions{MaxWorkers: 4, Languages: map[string][]string{"c": {".c"}}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.AnalyzeDirectory(context.Background(), dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFilterFunctions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "counter.cpp")
+	src := `int Counter::get() const { return count_; }
+
+int Counter::add(const int *values, int n)
+{
+	for (int i = 0; i < n; i++) {
+		if (values[i] > 0 && values[i] < limit_)
+			count_ += values[i];
+	}
+	return count_;
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diskCache, err := cache.NewDisk(cache.DiskOptions{Dir: filepath.Join(dir, "cache")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(opts AnalyzerOptions) string {
+		opts.Languages = map[string][]string{"cpp": {".cpp"}}
+		opts.Cache = diskCache
+		file, err := New(opts).AnalyzeFile(context.Background(), path)
+		if err != nil {
+			t.Fatalf("AnalyzeFile() failed: %v", err)
+		}
+		var got []string
+		for _, fn := range file.Functions {
+			got = append(got, fn.Name)
+		}
+		return strings.Join(got, " ")
+	}
+
+	// Thresholds apply to fresh and cached analyses alike
+	for _, c := range []struct {
+		opts AnalyzerOptions
+		want string
+	}{
+		{AnalyzerOptions{MinFunctionTokens: 10}, "Counter::add"},
+		{AnalyzerOptions{MinFunctionLines: 3}, "Counter::add"},
+		{AnalyzerOptions{MinFunctionLines: 9}, ""},
+		{AnalyzerOptions{}, "Counter::get Counter::add"},
+		{AnalyzerOptions{MinFunctionComplexity: 2}, "Counter::add"},
+		{AnalyzerOptions{MinFunctionComplexity: 5}, ""},
+	} {
+		if got := names(c.opts); got != c.want {
+			t.Errorf("lines %d, tokens %d, complexity %d: functions %q, want %q", c.opts.MinFunctionLines, c.opts.MinFunctionTokens, c.opts.MinFunctionComplexity, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/re-centris/re-centris-go/internal/common/bufpool"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"go.uber.org/zap"
+)
+
+// maxArchiveEntrySize is the size of the largest archive entry read into
+// memory; larger entries are skipped as KindOversize
+const maxArchiveEntrySize = 64 << 20
+
+// archiveFormats are the supported archive extensions
+var archiveFormats = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// ArchiveFormat returns the lower-case archive extension of a path, or an
+// empty string if it is not a supported archive
+func ArchiveFormat(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range archiveFormats {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// IsArchive reports whether a path is a supported archive
+func IsArchive(path string) bool {
+	return ArchiveFormat(path) != ""
+}
+
+// AnalyzeArchive analyzes the supported files of a zip or tar archive
+// without extracting it. Entries are read into memory one by one and named
+// as if the archive was a directory, so "src.tar.gz" yields files like
+// "src.tar.gz/lib/a.c". The Include, Exclude and ErrorPolicy options apply
+// to the entries.
+func (a *Analyzer) AnalyzeArchive(ctx context.Context, archive string) ([]*FileInfo, error) {
+	include, err := parseIgnoreRules(a.opts.Include, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %v", err)
+	}
+	exclude, err := parseIgnoreRules(a.opts.Exclude, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %v", err)
+	}
+
+	var (
+		files    []*FileInfo
+		filesMux sync.Mutex
+		failed   atomic.Int64
+	)
+
+	g, ctx := workpool.WithContext(ctx, a.opts.Pool, a.opts.MaxWorkers)
+
+	stage := a.opts.Progress.Stage("analyze", 0)
+
+	err = readArchive(archive, func(name string, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entryPath := filepath.Join(archive, filepath.FromSlash(name))
+		language := a.Language(name)
+		sniff := language == "" && a.opts.SniffLanguages && sniffable(name)
+		if (language == "" && !sniff) || excludedEntry(exclude, name) || !included(include, name) {
+			return nil
+		}
+		if a.opts.Skip != nil && a.opts.Skip(entryPath) {
+			return nil
+		}
+
+		// Sizes in headers may lie, so limit what is read
+		limit := int64(maxArchiveEntrySize)
+		if a.opts.MaxFileSize > 0 && a.opts.MaxFileSize < limit {
+			limit = a.opts.MaxFileSize
+		}
+		buf, err := bufpool.ReadAll(io.LimitReader(r, limit+1), 0)
+		if err != nil {
+			return a.fileError(entryPath, fmt.Errorf("failed to read archive entry: %v", err), &failed)
+		}
+		if int64(buf.Len()) > limit {
+			bufpool.Put(buf)
+			a.skipped.add(&SkippedError{Kind: KindOversize})
+			logger.Warn("Skipping large archive entry",
+				zap.String("path", entryPath),
+				zap.Int64("max_file_size", limit))
+			return nil
+		}
+		if sniff {
+			if language = a.sniffLanguage(buf.Bytes()); language == "" {
+				bufpool.Put(buf)
+				return nil
+			}
+		}
+
+		stage.AddTotal(1)
+		g.Go(func() error {
+			defer stage.Add(1)
+
+			fileInfo, err := a.withDeadline(ctx, entryPath, func(ctx context.Context) (*FileInfo, error) {
+				defer bufpool.Put(buf)
+				content := buf.Bytes()
+				return a.analyzeContent(ctx, entryPath, language, content, int64(len(content)))
+			})
+			if err != nil {
+				if IsSkipped(err) {
+					return nil
+				}
+				return a.fileError(entryPath, err, &failed)
+			}
+
+			filesMux.Lock()
+			files = append(files, fileInfo)
+			filesMux.Unlock()
+			return nil
+		})
+		return nil
+	})
+	if waitErr := g.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("error while analyzing files: %v", waitErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stage.Done()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	if a.opts.PairDeclarations {
+		PairDeclarations(files)
+	}
+
+	return files, nil
+}
+
+// excludedEntry reports whether an archive entry or one of its parent
+// directories matches the exclude rules
+func excludedEntry(rules []ignoreRule, name string) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if ignored(rules, dir, true) {
+			return true
+		}
+	}
+	return ignored(rules, name, false)
+}
+
+// readArchive calls fn for every regular file of an archive with its
+// cleaned, slash-separated name
+func readArchive(archive string, fn func(name string, r io.Reader) error) error {
+	if ArchiveFormat(archive) == ".zip" {
+		return readZip(archive, fn)
+	}
+	return readTar(archive, fn)
+}
+
+// readZip calls fn for every regular file of a zip archive
+func readZip(archive string, fn func(name string, r io.Reader) error) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from archive: %v", f.Name, err)
+		}
+		err = fn(entryName(f.Name), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readTar calls fn for every regular file of a plain or gzip-compressed
+// tar archive
+func readTar(archive string, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if format := ArchiveFormat(archive); format == ".tar.gz" || format == ".tgz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(entryName(header.Name), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// entryName cleans an archive entry name so it stays below the archive
+func entryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// archiveSource returns C source long enough to be hashed
+func archiveSource(name string) []byte {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "int %s_%d(int x) { return x * %d + %d; }\n", name, i, i, i*7)
+	}
+	return []byte(b.String())
+}
+
+func TestAnalyzeArchive(t *testing.T) {
+	dir := t.TempDir()
+	entries := map[string][]byte{
+		"pkg/src/a.c":            archiveSource("a"),
+		"pkg/node_modules/dep.c": archiveSource("dep"),
+		"../../escape/b.c":       archiveSource("b"),
+		"pkg/README":             []byte("not source"),
+		"pkg/src/tiny.c":         []byte("int x;"),
+	}
+
+	tgzPath := filepath.Join(dir, "drop.tar.gz")
+	file, err := os.Create(tgzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write(content)
+	}
+	tw.Close()
+	gz.Close()
+	file.Close()
+
+	zipPath := filepath.Join(dir, "drop.zip")
+	file, err = os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	for name, content := range entries {
+		w, _ := zw.Create(name)
+		w.Write(content)
+	}
+	zw.Close()
+	file.Close()
+
+	a := New(AnalyzerOptions{
+		MaxWorkers: 2,
+		Languages:  map[string][]string{"cpp": {".c"}},
+		Exclude:    DefaultExcludes,
+	})
+	for _, archive := range []string{tgzPath, zipPath} {
+		files, err := a.AnalyzeDirectory(context.Background(), archive)
+		if err != nil {
+			t.Fatalf("AnalyzeDirectory(%s) error = %v", archive, err)
+		}
+
+		var got []string
+		for _, f := range files {
+			got = append(got, f.Path)
+		}
+		want := []string{filepath.Join(archive, "escape", "b.c"), filepath.Join(archive, "pkg", "src", "a.c")}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("AnalyzeDirectory(%s) = %v, want %v", archive, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser/cpp"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// cacheVersion is bumped whenever analysis results change for the same
+// content and options, invalidating cached entries
+const cacheVersion = 6
+
+// cachedAnalysis is the analysis of a file's content stored in the disk cache
+type cachedAnalysis struct {
+	Hash             string            `json:"hash"`
+	NormalizedDigest string            `json:"normalized_digest"`
+	Functions        []parser.Function `json:"functions,omitempty"`
+	// Declarations are only collected with PairDeclarations
+	Declarations []parser.Declaration `json:"declarations,omitempty"`
+}
+
+// cacheKey returns the cache key of content with the given digest. It
+// covers the options changing the analysis of the content.
+func (a *Analyzer) cacheKey(language, digest string) string {
+	data, _ := json.Marshal(struct {
+		Version       int
+		Language      string
+		Digest        string
+		SignatureMode string
+		Normalize     interface{}
+		Encoding      string `json:",omitempty"`
+		Conditionals  string `json:",omitempty"`
+		Declarations  bool   `json:",omitempty"`
+	}{cacheVersion, language, digest, a.opts.SignatureMode, a.opts.Normalize[language], EncodingKey(a.opts.Encoding), cpp.ConditionalsKey(a.opts.Conditionals), a.opts.PairDeclarations})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cached returns the cached analysis of content, if any
+func (a *Analyzer) cached(path, language, digest string, size int64) (*FileInfo, bool) {
+	if a.opts.Cache == nil {
+		return nil, false
+	}
+
+	data, ok := a.opts.Cache.Get(a.cacheKey(language, digest))
+	if !ok {
+		return nil, false
+	}
+	var entry cachedAnalysis
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	hash, err := tlsh.Parse(entry.Hash)
+	if err != nil {
+		return nil, false
+	}
+
+	for i := range entry.Functions {
+		entry.Functions[i].Name = a.names.Intern(entry.Functions[i].Name)
+	}
+	return &FileInfo{
+		Path:             path,
+		Language:         language,
+		Hash:             hash,
+		Size:             size,
+		Functions:        entry.Functions,
+		Declarations:     entry.Declarations,
+		Digest:           digest,
+		NormalizedDigest: entry.NormalizedDigest,
+	}, true
+}
+
+// store stores the analysis of a file in the disk cache, if any. Failures
+// only cost a recomputation and are logged.
+func (a *Analyzer) store(file *FileInfo) {
+	if a.opts.Cache == nil {
+		return
+	}
+
+	data, err := json.Marshal(cachedAnalysis{
+		Hash:             file.Hash.String(),
+		NormalizedDigest: file.NormalizedDigest,
+		Functions:        file.Functions,
+		Declarations:     file.Declarations,
+	})
+	if err == nil {
+		err = a.opts.Cache.Put(a.cacheKey(file.Language, file.Digest), data)
+	}
+	if err != nil {
+		logger.Warn("Failed to cache analysis",
+			zap.String("path", file.Path),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// DefaultChunkedSize is the default ChunkedSize, the size of the largest
+// archive entry read into memory
+const DefaultChunkedSize = maxArchiveEntrySize
+
+// chunkSize is the size of the chunks large files are read and hashed in
+const chunkSize = 1 << 20
+
+// skipOversize counts and reports a file larger than MaxFileSize
+func (a *Analyzer) skipOversize(path string, size int64) error {
+	logger.Warn("Skipping oversize file",
+		zap.String("path", path),
+		zap.Int64("size", size),
+		zap.Int64("max_file_size", a.opts.MaxFileSize))
+	err := &SkippedError{Kind: KindOversize}
+	a.skipped.add(err)
+	return err
+}
+
+// analyzeChunked hashes a file of at least ChunkedSize bytes chunk by
+// chunk, so a huge generated file neither exhausts memory nor holds a
+// worker longer than reading it takes. Normalization, transcoding, token
+// streams and function extraction need the whole content, so the file is
+// hashed as read and has no functions; its first chunk is classified and,
+// without a supported extension, sniffed for its language.
+func (a *Analyzer) analyzeChunked(ctx context.Context, path, language string, r io.Reader, size int64) (*FileInfo, error) {
+	logger.Info("Hashing large file in chunks",
+		zap.String("path", path),
+		zap.Int64("size", size))
+
+	chunk := make([]byte, chunkSize)
+	hasher := tlsh.NewHasher()
+	for first := true; ; first = false {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := io.ReadFull(r, chunk)
+		if first && n > 0 {
+			if !a.opts.NoSniff {
+				if kind := Classify(chunk[:n]); kind != "" {
+					err := &SkippedError{Kind: kind}
+					a.skipped.add(err)
+					return nil, err
+				}
+			}
+			if language == "" {
+				if language = a.sniffLanguage(chunk[:n]); language == "" {
+					return nil, fmt.Errorf("unrecognized language: %s", path)
+				}
+			}
+		}
+		hasher.Write(chunk[:n])
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %v", err)
+		}
+	}
+
+	hash, err := hasher.Hash()
+	if err == tlsh.ErrDataTooSmall {
+		a.skipped.add(err)
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate TLSH hash: %v", err)
+	}
+	sum := hasher.Sum256()
+	return &FileInfo{
+		Path:     path,
+		Language: language,
+		Hash:     hash,
+		Size:     size,
+		Digest:   hex.EncodeToString(sum[:]),
+	}, nil
+}
@@ -0,0 +1,43 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLargeFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.c")
+	content := bytes.Repeat([]byte("static const int table_entry = 42; /* entry */\n"), 50000)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	languages := map[string][]string{"cpp": {".c"}}
+
+	whole, err := New(AnalyzerOptions{Languages: languages}).AnalyzeFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("AnalyzeFile failed: %v", err)
+	}
+	chunked, err := New(AnalyzerOptions{Languages: languages, ChunkedSize: chunkSize}).AnalyzeFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("chunked AnalyzeFile failed: %v", err)
+	}
+	if chunked.Hash.String() != whole.Hash.String() || chunked.Digest != whole.Digest {
+		t.Errorf("chunked hash %s (digest %s), want %s (digest %s)", chunked.Hash, chunked.Digest, whole.Hash, whole.Digest)
+	}
+	if len(chunked.Functions) != 0 || chunked.NormalizedDigest != "" {
+		t.Errorf("chunked file has %d functions and normalized digest %q", len(chunked.Functions), chunked.NormalizedDigest)
+	}
+
+	a := New(AnalyzerOptions{Languages: languages, MaxFileSize: int64(len(content)) - 1, MaxWorkers: 2})
+	files, err := a.AnalyzeDirectory(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("AnalyzeDirectory failed: %v", err)
+	}
+	if len(files) != 0 || a.SkipStats()[KindOversize] != 1 {
+		t.Errorf("got %d files and skip stats %v, want the file skipped as oversize", len(files), a.SkipStats())
+	}
+}
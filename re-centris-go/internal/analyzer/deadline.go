@@ -0,0 +1,102 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// maxSlowFiles is the number of slowest files a SlowFileReport keeps
+const maxSlowFiles = 100
+
+// SlowFile is a file whose analysis took long or timed out
+type SlowFile struct {
+	Path     string
+	Duration time.Duration
+	TimedOut bool
+}
+
+// SlowFileReport collects the files whose analysis took at least its
+// threshold or exceeded FileTimeout, keeping the slowest. It is safe for
+// concurrent use; a nil report records nothing.
+type SlowFileReport struct {
+	threshold time.Duration
+	files     []SlowFile
+	mutex     sync.Mutex
+}
+
+// NewSlowFileReport creates a report of the files taking at least
+// threshold; with a non-positive threshold only timeouts are recorded
+func NewSlowFileReport(threshold time.Duration) *SlowFileReport {
+	return &SlowFileReport{threshold: threshold}
+}
+
+// add records a file if it is slow
+func (r *SlowFileReport) add(file SlowFile) {
+	if r == nil || (!file.TimedOut && (r.threshold <= 0 || file.Duration < r.threshold)) {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.files = append(r.files, file)
+	if len(r.files) > 2*maxSlowFiles {
+		r.sort()
+		r.files = r.files[:maxSlowFiles]
+	}
+}
+
+// Files returns the slowest recorded files, slowest first
+func (r *SlowFileReport) Files() []SlowFile {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sort()
+	return append([]SlowFile(nil), r.files[:min(len(r.files), maxSlowFiles)]...)
+}
+
+func (r *SlowFileReport) sort() {
+	sort.SliceStable(r.files, func(i, j int) bool {
+		if r.files[i].Duration != r.files[j].Duration {
+			return r.files[i].Duration > r.files[j].Duration
+		}
+		return r.files[i].Path < r.files[j].Path
+	})
+}
+
+// withDeadline runs the analysis of a file, records it in SlowFiles if it
+// is slow and stops it after FileTimeout, by cancelling the context it
+// passes to the analysis. A file whose analysis timed out is skipped as
+// KindTimeout.
+func (a *Analyzer) withDeadline(ctx context.Context, path string, analyze func(ctx context.Context) (*FileInfo, error)) (*FileInfo, error) {
+	start := time.Now()
+	if a.opts.FileTimeout <= 0 {
+		file, err := analyze(ctx)
+		a.opts.SlowFiles.add(SlowFile{Path: path, Duration: time.Since(start)})
+		return file, err
+	}
+
+	fileCtx, cancel := context.WithTimeout(ctx, a.opts.FileTimeout)
+	defer cancel()
+	file, err := analyze(fileCtx)
+	if err == nil || fileCtx.Err() == nil {
+		a.opts.SlowFiles.add(SlowFile{Path: path, Duration: time.Since(start)})
+		return file, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	logger.Warn("Stopped analyzing file exceeding the file timeout",
+		zap.String("path", path),
+		zap.Duration("timeout", a.opts.FileTimeout))
+	a.opts.SlowFiles.add(SlowFile{Path: path, Duration: time.Since(start), TimedOut: true})
+	skipped := &SkippedError{Kind: KindTimeout}
+	a.skipped.add(skipped)
+	return nil, skipped
+}
@@ -0,0 +1,44 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithDeadline(t *testing.T) {
+	report := NewSlowFileReport(20 * time.Millisecond)
+	a := New(AnalyzerOptions{FileTimeout: 100 * time.Millisecond, SlowFiles: report})
+
+	_, err := a.withDeadline(context.Background(), "stuck.c", func(ctx context.Context) (*FileInfo, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	var skipped *SkippedError
+	if !errors.As(err, &skipped) || skipped.Kind != KindTimeout {
+		t.Fatalf("stuck file error = %v, want a timeout skip", err)
+	}
+
+	for _, file := range []struct {
+		path  string
+		sleep time.Duration
+	}{{"slow.c", 40 * time.Millisecond}, {"fast.c", 0}} {
+		sleep := file.sleep
+		info, err := a.withDeadline(context.Background(), file.path, func(context.Context) (*FileInfo, error) {
+			time.Sleep(sleep)
+			return &FileInfo{Path: file.path}, nil
+		})
+		if err != nil || info.Path != file.path {
+			t.Fatalf("%s: got %v, %v", file.path, info, err)
+		}
+	}
+
+	files := report.Files()
+	if len(files) != 2 || files[0].Path != "stuck.c" || !files[0].TimedOut || files[1].Path != "slow.c" || files[1].TimedOut {
+		t.Errorf("slow files = %+v, want stuck.c timed out then slow.c", files)
+	}
+	if a.SkipStats()[KindTimeout] != 1 {
+		t.Errorf("skip stats = %v, want one timeout", a.SkipStats())
+	}
+}
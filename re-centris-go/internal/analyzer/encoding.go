@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encodings of source files
+const (
+	// EncodingAuto detects the charset of files that are not UTF-8 and
+	// transcodes them to UTF-8 before hashing, see DetectEncoding
+	EncodingAuto = "auto"
+	// EncodingRaw hashes the bytes of files as they are
+	EncodingRaw = "raw"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseEncoding validates an encoding: EncodingAuto, EncodingRaw or the name
+// of a charset, such as gbk or latin1, that files which are not UTF-8 are
+// assumed to be in. Empty means EncodingRaw.
+func ParseEncoding(name string) (string, error) {
+	switch name = strings.ToLower(name); name {
+	case "", EncodingRaw:
+		return EncodingRaw, nil
+	case EncodingAuto:
+		return name, nil
+	}
+	if _, err := htmlindex.Get(name); err != nil {
+		return "", fmt.Errorf("unsupported encoding: %s", name)
+	}
+	return name, nil
+}
+
+// EncodingKey returns the encoding recorded in cache keys, index
+// fingerprints and corpus manifests. It is empty for EncodingRaw, which
+// matches the keys written before encodings were supported; the default
+// EncodingAuto and charsets have keys of their own.
+func EncodingKey(name string) string {
+	if name, _ = ParseEncoding(name); name == EncodingRaw {
+		return ""
+	}
+	return name
+}
+
+// DetectEncoding returns the charset of content that is not valid UTF-8:
+// UTF-16 if it starts with a byte order mark, GB18030 (a superset of GBK)
+// if its non-ASCII bytes pair up into mostly common Chinese characters,
+// and Windows-1252 (a superset of Latin-1) otherwise
+func DetectEncoding(content []byte) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	}
+
+	pairs, common := 0, 0
+	for i := 0; i < len(content); i++ {
+		lead := content[i]
+		if lead < 0x80 {
+			continue
+		}
+		if i+1 == len(content) || lead == 0x80 || lead == 0xFF {
+			return charmap.Windows1252
+		}
+		trail := content[i+1]
+		if trail < 0x40 || trail == 0x7F || trail == 0xFF {
+			return charmap.Windows1252
+		}
+		pairs++
+		// GB2312 punctuation and hanzi, the bulk of Chinese text
+		if lead >= 0xA1 && lead <= 0xF7 && trail >= 0xA1 {
+			common++
+		}
+		i++
+	}
+	if pairs > 0 && common*5 >= pairs*4 {
+		return simplifiedchinese.GB18030
+	}
+	return charmap.Windows1252
+}
+
+// toUTF8 returns content transcoded to UTF-8 according to the encoding
+// option, or content itself if it is kept as is. Valid UTF-8 is only
+// stripped of a byte order mark.
+func (a *Analyzer) toUTF8(content []byte) []byte {
+	if a.encoding == nil && a.opts.Encoding != EncodingAuto {
+		return content
+	}
+	if utf8.Valid(content) {
+		return bytes.TrimPrefix(content, utf8BOM)
+	}
+
+	enc := a.encoding
+	if enc == nil {
+		enc = DetectEncoding(content)
+	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return content
+	}
+	return decoded
+}
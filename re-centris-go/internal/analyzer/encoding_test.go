@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestEncoding(t *testing.T) {
+	var source strings.Builder
+	for i := 0; i < 20; i++ {
+		source.WriteString("/* Prüfsumme berechnen, 计算校验和 */\n")
+		source.WriteString("int checksum_" + strings.Repeat("x", i) + "(const char *buf, int len)\n{\n    int sum = 0;\n    while (len-- > 0)\n        sum += *buf++;\n    return sum;\n}\n\n")
+	}
+	utf8Source := source.String()
+	latin1, _ := charmap.ISO8859_1.NewEncoder().String(strings.ReplaceAll(utf8Source, "计算校验和", "somme"))
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(strings.ReplaceAll(utf8Source, "Prüfsumme", "checksum"))
+
+	if enc := DetectEncoding([]byte(latin1)); enc != charmap.Windows1252 {
+		t.Errorf("DetectEncoding(latin1) = %v, want Windows-1252", enc)
+	}
+	if enc := DetectEncoding([]byte(gbk)); enc != simplifiedchinese.GB18030 {
+		t.Errorf("DetectEncoding(gbk) = %v, want GB18030", enc)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"latin1.c":      latin1,
+		"latin1_utf8.c": strings.ReplaceAll(utf8Source, "计算校验和", "somme"),
+		"gbk.c":         gbk,
+		"gbk_utf8.c":    "\xEF\xBB\xBF" + strings.ReplaceAll(utf8Source, "Prüfsumme", "checksum"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashes := func(encoding string) map[string]string {
+		a := New(AnalyzerOptions{Languages: map[string][]string{"cpp": {".c"}}, Encoding: encoding})
+		h := make(map[string]string)
+		for name := range files {
+			file, err := a.AnalyzeFile(context.Background(), filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("AnalyzeFile(%s) failed: %v", name, err)
+			}
+			h[name] = file.Hash.String()
+		}
+		return h
+	}
+
+	auto := hashes(EncodingAuto)
+	for _, name := range []string{"latin1", "gbk"} {
+		if auto[name+".c"] != auto[name+"_utf8.c"] {
+			t.Errorf("auto: %s hashes differently from its UTF-8 copy", name)
+		}
+	}
+	if forced := hashes("latin1"); forced["latin1.c"] != forced["latin1_utf8.c"] {
+		t.Errorf("latin1: file hashes differently from its UTF-8 copy")
+	}
+	if raw := hashes(EncodingRaw); raw["latin1.c"] == raw["latin1_utf8.c"] {
+		t.Errorf("raw: latin1 file hashes like its UTF-8 copy")
+	}
+}
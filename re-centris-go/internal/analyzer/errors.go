@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// Error policies accepted by ParseErrorPolicy
+const (
+	// ErrorPolicyFailFast aborts on the first file that fails to analyze
+	ErrorPolicyFailFast = "fail-fast"
+	// ErrorPolicySkip skips failed files and records them in the error report
+	ErrorPolicySkip = "skip-and-report"
+	// errorPolicyMaxErrors skips failed files until more than N failed
+	errorPolicyMaxErrors = "max-errors="
+)
+
+// ErrorPolicy decides how AnalyzeDirectory handles files that fail to analyze
+type ErrorPolicy struct {
+	// Skip continues past failed files instead of aborting the run
+	Skip bool
+	// MaxErrors aborts a skipping run once more than MaxErrors files failed
+	// to analyze. Zero means no limit.
+	MaxErrors int
+}
+
+// ParseErrorPolicy parses "fail-fast", "skip-and-report" or "max-errors=N".
+// An empty string is fail-fast.
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch {
+	case s == "" || s == ErrorPolicyFailFast:
+		return ErrorPolicy{}, nil
+	case s == ErrorPolicySkip:
+		return ErrorPolicy{Skip: true}, nil
+	case strings.HasPrefix(s, errorPolicyMaxErrors):
+		n, err := strconv.Atoi(strings.TrimPrefix(s, errorPolicyMaxErrors))
+		if err != nil || n <= 0 {
+			return ErrorPolicy{}, fmt.Errorf("invalid error limit: %s", s)
+		}
+		return ErrorPolicy{Skip: true, MaxErrors: n}, nil
+	default:
+		return ErrorPolicy{}, fmt.Errorf("unsupported error policy: %s", s)
+	}
+}
+
+// FileError records a file that failed to analyze
+type FileError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ErrorReport collects the files that failed to analyze. It is safe for
+// concurrent use, and a nil report discards all errors.
+type ErrorReport struct {
+	errors []FileError
+	mutex  sync.Mutex
+}
+
+// Add records the failure of a file
+func (r *ErrorReport) Add(path string, err error) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.errors = append(r.errors, FileError{Path: path, Error: err.Error()})
+}
+
+// Errors returns the recorded failures sorted by path
+func (r *ErrorReport) Errors() []FileError {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	errors := append([]FileError(nil), r.errors...)
+	sort.SliceStable(errors, func(i, j int) bool {
+		return errors[i].Path < errors[j].Path
+	})
+	return errors
+}
+
+// WriteJSON writes the recorded failures to path
+func (r *ErrorReport) WriteJSON(path string) error {
+	errors := r.Errors()
+	if errors == nil {
+		errors = []FileError{}
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Count  int         `json:"count"`
+		Errors []FileError `json:"errors"`
+	}{len(errors), errors}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal error report: %v", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write error report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorPolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ErrorPolicy
+	}{
+		{"", ErrorPolicy{}},
+		{"fail-fast", ErrorPolicy{}},
+		{"skip-and-report", ErrorPolicy{Skip: true}},
+		{"max-errors=3", ErrorPolicy{Skip: true, MaxErrors: 3}},
+	}
+	for _, tt := range tests {
+		got, err := ParseErrorPolicy(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseErrorPolicy(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"max-errors=0", "max-errors=x", "ignore"} {
+		if _, err := ParseErrorPolicy(in); err == nil {
+			t.Errorf("ParseErrorPolicy(%q) error = nil", in)
+		}
+	}
+}
+
+func TestAnalyzeDirectoryErrorPolicy(t *testing.T) {
+	dir := t.TempDir()
+	// Dangling links pass the walk but fail to open
+	for _, name := range []string{"a.c", "b.c"} {
+		if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	languages := map[string][]string{"cpp": {".c"}}
+
+	a := New(AnalyzerOptions{MaxWorkers: 2, Languages: languages})
+	if _, err := a.AnalyzeDirectory(context.Background(), dir); err == nil {
+		t.Error("AnalyzeDirectory() with fail-fast error = nil")
+	}
+
+	report := &ErrorReport{}
+	a = New(AnalyzerOptions{MaxWorkers: 2, Languages: languages, ErrorPolicy: ErrorPolicy{Skip: true}, Errors: report})
+	if _, err := a.AnalyzeDirectory(context.Background(), dir); err != nil {
+		t.Fatalf("AnalyzeDirectory() with skip error = %v", err)
+	}
+	errors := report.Errors()
+	if len(errors) != 2 || !strings.HasSuffix(errors[0].Path, "a.c") || errors[0].Error == "" {
+		t.Errorf("Errors() = %+v", errors)
+	}
+
+	a = New(AnalyzerOptions{MaxWorkers: 2, Languages: languages, ErrorPolicy: ErrorPolicy{Skip: true, MaxErrors: 1}})
+	if _, err := a.AnalyzeDirectory(context.Background(), dir); err == nil {
+		t.Error("AnalyzeDirectory() over max errors error = nil")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package analyzer
+
+import "os"
+
+// fileID identifies a file independently of the path it was reached by
+type fileID struct {
+	path string
+}
+
+// fileIDOf returns the resolved path of a file; hard links are not detected
+func fileIDOf(path string, info os.FileInfo) fileID {
+	return fileIDOfPath(path)
+}
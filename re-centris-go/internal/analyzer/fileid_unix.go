@@ -0,0 +1,25 @@
+//go:build unix
+
+package analyzer
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file independently of the path it was reached by
+type fileID struct {
+	dev  uint64
+	ino  uint64
+	path string
+}
+
+// fileIDOf returns the device and inode of a file, falling back to its path
+// on file systems without inodes
+func fileIDOf(path string, info os.FileInfo) fileID {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileIDOfPath(path)
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+}
@@ -0,0 +1,176 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// gitIgnoreFile is the name of the files read with the GitIgnore option
+const gitIgnoreFile = ".gitignore"
+
+// DefaultExcludes are the exclude patterns used by the command line by default
+var DefaultExcludes = []string{".git/", "node_modules/", "build/"}
+
+// ignoreRule is a pattern in .gitignore syntax. Patterns without a slash
+// match the name of a file or directory at any depth, all others the path
+// relative to the directory they were defined in. "**" matches any number
+// of directories, a trailing "/" only matches directories and a leading "!"
+// re-includes paths excluded by an earlier rule.
+type ignoreRule struct {
+	segments []string
+	base     string // directory of the rule relative to the walked directory
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreRules parses patterns defined in the directory base, which is
+// slash-separated and relative to the walked directory. Blank lines and
+// comments are ignored.
+func parseIgnoreRules(patterns []string, base string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, pattern := range patterns {
+		pattern = strings.TrimRight(pattern, " \t\r")
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(pattern, "!") {
+			rule.negate = true
+			pattern = pattern[1:]
+		} else if strings.HasPrefix(pattern, `\`) {
+			pattern = pattern[1:]
+		}
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+		if strings.Contains(pattern, "/") {
+			rule.anchored = true
+			pattern = strings.TrimPrefix(pattern, "/")
+		}
+		if pattern == "" {
+			continue
+		}
+
+		rule.segments = strings.Split(pattern, "/")
+		for _, segment := range rule.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// match reports whether the rule matches a slash-separated path relative
+// to the walked directory
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range segments {
+				if matchSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// ignored applies rules in order, the last matching rule deciding
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.match(rel, isDir) {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// included reports whether a file or one of its parent directories matches
+// the include rules; without include rules all files are included
+func included(rules []ignoreRule, rel string) bool {
+	if len(rules) == 0 || ignored(rules, rel, false) {
+		return true
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if ignored(rules, dir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// readGitIgnore reads the .gitignore file of dir, whose path relative to
+// the walked directory is base. A missing file yields no rules.
+func readGitIgnore(dir, base string) []ignoreRule {
+	file, err := os.Open(filepath.Join(dir, gitIgnoreFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to read .gitignore",
+				zap.String("dir", dir),
+				zap.Error(err))
+		}
+		return nil
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	rules, err := parseIgnoreRules(lines, base)
+	if err != nil {
+		logger.Warn("Ignoring invalid .gitignore",
+			zap.String("dir", dir),
+			zap.Error(err))
+		return nil
+	}
+	return rules
+}
@@ -0,0 +1,103 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules, err := parseIgnoreRules([]string{"# comment", "build/", "*.gen.c", "/top.c", "docs/**/*.c", "!keep.gen.c"}, "")
+	if err != nil {
+		t.Fatalf("parseIgnoreRules() error = %v", err)
+	}
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"build", true, true},
+		{"src/build", true, true},
+		{"src/build", false, false},
+		{"src/a.gen.c", false, true},
+		{"src/keep.gen.c", false, false},
+		{"top.c", false, true},
+		{"src/top.c", false, false},
+		{"docs/x/y/z.c", false, true},
+		{"docs/z.c", false, true},
+		{"src/a.c", false, false},
+	}
+	for _, tt := range tests {
+		if got := ignored(rules, tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+
+	include, err := parseIgnoreRules([]string{"src/", "*.h"}, "")
+	if err != nil {
+		t.Fatalf("parseIgnoreRules() error = %v", err)
+	}
+	for rel, want := range map[string]bool{
+		"src/a.c":     true,
+		"lib/src/b.c": true,
+		"src":         false,
+		"lib/a.h":     true,
+		"lib/a.c":     false,
+	} {
+		if got := included(include, rel); got != want {
+			t.Errorf("included(%q) = %v, want %v", rel, got, want)
+		}
+	}
+
+	if _, err := parseIgnoreRules([]string{"[x"}, ""); err == nil {
+		t.Error("parseIgnoreRules() with invalid pattern error = nil")
+	}
+}
+
+func TestWalkDirectoryIgnore(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":             "*.tmp.c\n",
+		"src/a.c":                "",
+		"src/b.tmp.c":            "",
+		"src/sub/.gitignore":     "local.c\n",
+		"src/sub/local.c":        "",
+		"local.c":                "",
+		"node_modules/x/y.c":     "",
+		"third_party/lib/z.c":    "",
+		"third_party/lib/keep.c": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := New(AnalyzerOptions{
+		Languages: map[string][]string{"cpp": {".c"}},
+		Exclude:   append([]string{"third_party/", "!third_party/lib/keep.c"}, DefaultExcludes...),
+		GitIgnore: true,
+	})
+	var got []string
+	err := a.WalkDirectory(context.Background(), root, func(path string, info os.FileInfo) error {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDirectory() error = %v", err)
+	}
+
+	// Excluded directories are not entered, so their files cannot be re-included
+	want := []string{"local.c", "src/a.c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkDirectory() = %v, want %v", got, want)
+	}
+}
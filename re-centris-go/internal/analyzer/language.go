@@ -0,0 +1,170 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// minLanguageScore is the keyword score a language needs to be detected
+const minLanguageScore = 3
+
+// unsniffedExtensions are extensions of documentation, data and patch
+// files, whose content is never sniffed because code snippets in them
+// would be taken for source files
+var unsniffedExtensions = map[string]bool{
+	".md": true, ".markdown": true, ".txt": true, ".rst": true, ".adoc": true, ".tex": true,
+	".html": true, ".htm": true, ".xml": true, ".json": true, ".yaml": true, ".yml": true,
+	".toml": true, ".ini": true, ".cfg": true, ".conf": true, ".csv": true, ".log": true,
+	".patch": true, ".diff": true, ".orig": true, ".rej": true, ".svg": true,
+}
+
+// languageKeyword is a line pattern hinting at a language
+type languageKeyword struct {
+	language string
+	pattern  *regexp.Regexp
+	weight   int
+}
+
+// languageKeywords score the lines of a file for each language. Python
+// imports have no trailing semicolon, which tells them from Java imports;
+// unindented function definitions are C, as Java methods are in classes.
+var languageKeywords = []languageKeyword{
+	{"cpp", regexp.MustCompile(`^\s*#\s*(include\s*[<"]|define\s+\w|ifn?def\s+\w|endif\b|pragma\s+once)`), 2},
+	{"cpp", regexp.MustCompile(`^\s*(typedef\s|struct\s+\w+\s*\{|namespace\s+\w+\s*\{|template\s*<|extern\s+"C")`), 1},
+	{"cpp", regexp.MustCompile(`\bstd::\w`), 1},
+	{"cpp", regexp.MustCompile(`^(static\s+|inline\s+|const\s+|unsigned\s+)*\w+[\s*]+\w+\s*\([\w\s,*\[\]]*\)\s*\{?\s*$`), 1},
+	{"java", regexp.MustCompile(`^\s*package\s+[\w.]+\s*;`), 3},
+	{"java", regexp.MustCompile(`^\s*import\s+(static\s+)?[\w.]+(\.\*)?\s*;`), 2},
+	{"java", regexp.MustCompile(`^\s*(public|private|protected)\s+(abstract\s+|final\s+|static\s+)*(class|interface|enum)\s+\w+`), 2},
+	{"java", regexp.MustCompile(`^\s*@Override\b`), 1},
+	{"python", regexp.MustCompile(`^\s*(async\s+)?def\s+\w+\s*\(.*\)\s*(->\s*[^:]+)?:\s*(#.*)?$`), 2},
+	{"python", regexp.MustCompile(`^\s*(from\s+[\w.]+\s+import\s+[\w*(]|import\s+[\w.]+(\s+as\s+\w+)?\s*$)`), 2},
+	{"python", regexp.MustCompile(`^\s*class\s+\w+(\(.*\))?\s*:\s*$`), 2},
+	{"python", regexp.MustCompile(`__name__\s*==\s*['"]__main__['"]`), 3},
+	{"python", regexp.MustCompile(`^\s*(elif\s.*|else|try|finally|except.*):\s*$`), 1},
+}
+
+// modeLanguages maps the language names of Emacs and Vim modelines
+var modeLanguages = map[string]string{
+	"c": "cpp", "c++": "cpp", "cpp": "cpp", "cc": "cpp",
+	"java": "java", "python": "python", "python3": "python",
+}
+
+var (
+	emacsMode = regexp.MustCompile(`-\*-\s*(?:mode:\s*)?([\w+]+)\s*(?:;.*)?-\*-`)
+	vimMode   = regexp.MustCompile(`\bvim?:.*\b(?:ft|filetype|syntax)=([\w+]+)`)
+)
+
+// DetectLanguage returns the language of source code from its first bytes,
+// or an empty string if it is not recognized. It looks at a shebang line,
+// then an Emacs or Vim modeline, then scores keyword patterns per line.
+func DetectLanguage(head []byte) string {
+	if len(head) > sniffSize {
+		head = head[:sniffSize]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return ""
+	}
+	lines := strings.Split(string(head), "\n")
+
+	// A script names its interpreter; other interpreters are unsupported
+	if strings.HasPrefix(lines[0], "#!") {
+		if strings.HasPrefix(shebangInterpreter(lines[0]), "python") {
+			return "python"
+		}
+		return ""
+	}
+
+	for i := 0; i < len(lines) && i < 5; i++ {
+		for _, mode := range []*regexp.Regexp{emacsMode, vimMode} {
+			if m := mode.FindStringSubmatch(lines[i]); m != nil {
+				if language, ok := modeLanguages[strings.ToLower(m[1])]; ok {
+					return language
+				}
+			}
+		}
+	}
+
+	scores := make(map[string]int)
+	for _, line := range lines {
+		for _, k := range languageKeywords {
+			if k.pattern.MatchString(line) {
+				scores[k.language] += k.weight
+			}
+		}
+	}
+	best, second := "", 0
+	for _, language := range []string{"cpp", "java", "python"} {
+		switch score := scores[language]; {
+		case best == "" || score > scores[best]:
+			second = scores[best]
+			best = language
+		case score > second:
+			second = score
+		}
+	}
+	if scores[best] < minLanguageScore || scores[best] == second {
+		return ""
+	}
+	return best
+}
+
+// shebangInterpreter returns the program name of the interpreter of a
+// shebang line, looking through env and its options
+func shebangInterpreter(line string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	for i, field := range fields {
+		name := filepath.Base(field)
+		if i == 0 && name == "env" {
+			continue
+		}
+		if i > 0 && (strings.HasPrefix(field, "-") || strings.Contains(field, "=")) {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// sniffable reports whether the content of a file without a supported
+// extension may be sniffed for its language
+func sniffable(path string) bool {
+	return !unsniffedExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// LanguageOf returns the language of a file by its extension or, with
+// SniffLanguages, by its content if the extension is not supported, or an
+// empty string if neither tells
+func (a *Analyzer) LanguageOf(path string) string {
+	if language := a.Language(path); language != "" || !a.opts.SniffLanguages || !sniffable(path) {
+		return language
+	}
+
+	file, err := fsutil.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return ""
+	}
+	return a.sniffLanguage(head[:n])
+}
+
+// sniffLanguage returns the language detected from content if it is one
+// of the analyzed languages
+func (a *Analyzer) sniffLanguage(content []byte) string {
+	language := DetectLanguage(content)
+	if _, ok := a.opts.Languages[language]; !ok {
+		return ""
+	}
+	return language
+}
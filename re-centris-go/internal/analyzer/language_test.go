@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"shebang", "#!/usr/bin/python3\nprint('hi')\n", "python"},
+		{"env shebang", "#!/usr/bin/env -S python3 -u\nprint('hi')\n", "python"},
+		{"shell", "#!/bin/sh\n#include <stdio.h>\n#define X 1\n", ""},
+		{"emacs", "/* -*- mode: c++; indent-tabs-mode: nil -*- */\nint x;\n", "cpp"},
+		{"vim", "// vim: set ft=java :\nint x;\n", "java"},
+		{"header", "#ifndef FOO_H\n#define FOO_H\n#include <stddef.h>\nint foo(void);\n#endif\n", "cpp"},
+		{"java", "package org.example;\n\nimport java.util.List;\n\npublic class Foo {\n}\n", "java"},
+		{"python", "import os\nfrom sys import argv\n\ndef main():\n    pass\n\nif __name__ == '__main__':\n    main()\n", "python"},
+		{"definitions", "static int add(int a, int b)\n{\n}\n\nint main(void)\n{\n}\n\nchar *name(unsigned n) {\n}\n", "cpp"},
+		{"prose", "This is a README without any code.\nIt should stay unknown.\n", ""},
+		{"binary", "\x7fELF\x00\x00#include <stdio.h>\n#define X\n", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage([]byte(tt.content)); got != tt.want {
+			t.Errorf("DetectLanguage(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWalkDirectorySniffLanguages(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.c":    "int main(void) { return 0; }\n",
+		"config":    "#ifndef CONFIG_H\n#define CONFIG_H\n#define VERSION 2\n#endif\n",
+		"tool":      "#!/usr/bin/env python3\nprint('hi')\n",
+		"notes.txt": "#include <stdio.h>\n#define X 1\n#include <stdlib.h>\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sniff := range []bool{false, true} {
+		a := New(AnalyzerOptions{Languages: map[string][]string{"cpp": {".c"}}, SniffLanguages: sniff})
+		var paths []string
+		err := a.WalkDirectory(context.Background(), dir, func(path string, info os.FileInfo) error {
+			paths = append(paths, filepath.Base(path))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkDirectory failed: %v", err)
+		}
+		sort.Strings(paths)
+
+		// Python is not enabled, so the script stays unsupported
+		want := []string{"main.c"}
+		if sniff {
+			want = []string{"config", "main.c"}
+		}
+		if len(paths) != len(want) || paths[0] != want[0] || paths[len(paths)-1] != want[len(want)-1] {
+			t.Errorf("sniff %v: walked %v, want %v", sniff, paths, want)
+		}
+	}
+}
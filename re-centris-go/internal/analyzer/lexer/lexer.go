@@ -0,0 +1,250 @@
+// Package lexer finds the comments and string literals of source code, so
+// that normalization, tokenization and parsing agree on where they start
+// and end. Comment markers inside literals and quotes inside comments are
+// left alone; escapes, C++ raw strings and digit separators and triple
+// quoted strings are handled.
+package lexer
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Kind classifies a span of source code
+type Kind int
+
+// Span kinds
+const (
+	Code Kind = iota
+	Comment
+	Literal
+)
+
+// Syntax describes the comments and literals of a language family
+type Syntax struct {
+	LineComment  string
+	BlockComment [2]string
+	// Quotes are the characters delimiting string and character literals
+	Quotes string
+	// LineContinuation extends line comments over a backslash-newline
+	LineContinuation bool
+	// TripleQuotes allows literals delimited by three quotes spanning lines
+	TripleQuotes bool
+	// RawStrings allows C++ raw string literals, such as R"x(...)x"
+	RawStrings bool
+	// DigitSeparators allows quotes between digits, as in 1'000
+	DigitSeparators bool
+}
+
+// syntaxes holds the syntax of the supported languages
+var syntaxes = map[string]Syntax{
+	"cpp": {
+		LineComment:      "//",
+		BlockComment:     [2]string{"/*", "*/"},
+		Quotes:           `"'`,
+		LineContinuation: true,
+		RawStrings:       true,
+		DigitSeparators:  true,
+	},
+	"java": {
+		LineComment:  "//",
+		BlockComment: [2]string{"/*", "*/"},
+		Quotes:       `"'`,
+		TripleQuotes: true,
+	},
+	"python": {
+		LineComment:  "#",
+		Quotes:       `"'`,
+		TripleQuotes: true,
+	},
+	"cmake": {
+		LineComment:  "#",
+		BlockComment: [2]string{"#[[", "]]"},
+		Quotes:       `"`,
+	},
+}
+
+// ForLanguage returns the syntax of a language. Languages without known
+// syntax use C-style comments and literals.
+func ForLanguage(language string) Syntax {
+	if s, ok := syntaxes[language]; ok {
+		return s
+	}
+	return syntaxes["cpp"]
+}
+
+// Span returns the kind and end offset of the comment or literal starting
+// at offset i of src, or Code and i if none starts there. Unterminated
+// block comments and multi-line literals extend to the end of src, other
+// literals to the end of their line.
+func (s Syntax) Span(src []byte, i int) (Kind, int) {
+	rest := src[i:]
+	if len(rest) == 0 || !s.starts(rest[0]) {
+		return Code, i
+	}
+
+	if start, stop := s.BlockComment[0], s.BlockComment[1]; start != "" && bytes.HasPrefix(rest, []byte(start)) {
+		if end := bytes.Index(rest[len(start):], []byte(stop)); end >= 0 {
+			return Comment, i + len(start) + end + len(stop)
+		}
+		return Comment, len(src)
+	}
+	if s.LineComment != "" && bytes.HasPrefix(rest, []byte(s.LineComment)) {
+		return Comment, s.lineCommentEnd(src, i)
+	}
+
+	quote := rest[0]
+	if strings.IndexByte(s.Quotes, quote) < 0 {
+		return Code, i
+	}
+	switch {
+	case quote == '\'' && s.DigitSeparators && inNumber(src, i):
+		return Code, i
+	case quote == '"' && s.RawStrings && rawPrefix(src, i):
+		if end, ok := rawStringEnd(src, i); ok {
+			return Literal, end
+		}
+	case s.TripleQuotes && len(rest) >= 3 && rest[1] == quote && rest[2] == quote:
+		delimiter := rest[:3]
+		for j := 3; j < len(rest); j++ {
+			switch {
+			case rest[j] == '\\':
+				j++
+			case bytes.HasPrefix(rest[j:], delimiter):
+				return Literal, i + j + 3
+			}
+		}
+		return Literal, len(src)
+	}
+
+	for j := 1; j < len(rest); j++ {
+		switch rest[j] {
+		case '\\':
+			j++
+		case quote:
+			return Literal, i + j + 1
+		case '\n':
+			return Literal, i + j
+		}
+	}
+	return Literal, len(src)
+}
+
+// starts reports whether a comment or literal may start with c
+func (s Syntax) starts(c byte) bool {
+	return s.LineComment != "" && c == s.LineComment[0] ||
+		s.BlockComment[0] != "" && c == s.BlockComment[0][0] ||
+		strings.IndexByte(s.Quotes, c) >= 0
+}
+
+// lineCommentEnd returns the offset of the newline ending the line comment
+// at i, following continuations if the syntax allows them
+func (s Syntax) lineCommentEnd(src []byte, i int) int {
+	for {
+		end := bytes.IndexByte(src[i:], '\n')
+		if end < 0 {
+			return len(src)
+		}
+		end += i
+		if !s.LineContinuation || !continued(src[:end]) {
+			return end
+		}
+		i = end + 1
+	}
+}
+
+// continued reports whether a line ends with a backslash, before an
+// optional carriage return
+func continued(line []byte) bool {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	return len(line) > 0 && line[len(line)-1] == '\\'
+}
+
+// inNumber reports whether offset i follows the digits of a number, where
+// a quote is a digit separator
+func inNumber(src []byte, i int) bool {
+	j := i
+	for j > 0 && (isWord(src[j-1]) || src[j-1] == '.' || src[j-1] == '\'') {
+		j--
+	}
+	return j < i && src[j] >= '0' && src[j] <= '9'
+}
+
+// rawPrefix reports whether the quote at i opens a raw string literal
+func rawPrefix(src []byte, i int) bool {
+	j := i
+	for j > 0 && isWord(src[j-1]) {
+		j--
+	}
+	switch string(src[j:i]) {
+	case "R", "LR", "uR", "UR", "u8R":
+		return true
+	}
+	return false
+}
+
+// rawStringEnd returns the end offset of the raw string literal whose
+// quote is at i, reporting whether it is well-formed
+func rawStringEnd(src []byte, i int) (int, bool) {
+	open := bytes.IndexByte(src[i:], '(')
+	if open < 0 || open > 17 || bytes.ContainsAny(src[i+1:i+open], " \\)\n") {
+		return 0, false
+	}
+	delimiter := append([]byte(")"), src[i+1:i+open]...)
+	delimiter = append(delimiter, '"')
+	end := bytes.Index(src[i+open:], delimiter)
+	if end < 0 {
+		return len(src), true
+	}
+	return i + open + end + len(delimiter), true
+}
+
+// Scan calls fn for the consecutive spans of code, comments and literals
+// of src
+func (s Syntax) Scan(src []byte, fn func(kind Kind, start, end int)) {
+	code := 0
+	for i := 0; i < len(src); {
+		kind, end := s.Span(src, i)
+		if kind == Code {
+			i++
+			continue
+		}
+		if code < i {
+			fn(Code, code, i)
+		}
+		fn(kind, i, end)
+		i, code = end, end
+	}
+	if code < len(src) {
+		fn(Code, code, len(src))
+	}
+}
+
+// Strip returns src with its comments replaced by a space and, if literals
+// is set, the content of its literals removed, leaving their quotes. The
+// newlines of removed text are kept, so line numbers are preserved.
+func (s Syntax) Strip(src []byte, literals bool) []byte {
+	out := make([]byte, 0, len(src))
+	s.Scan(src, func(kind Kind, start, end int) {
+		text := src[start:end]
+		switch {
+		case kind == Comment:
+			if newlines := bytes.Count(text, []byte("\n")); newlines > 0 {
+				out = append(out, bytes.Repeat([]byte("\n"), newlines)...)
+			} else {
+				out = append(out, ' ')
+			}
+		case kind == Literal && literals:
+			out = append(out, text[0], text[0])
+			out = append(out, bytes.Repeat([]byte("\n"), bytes.Count(text, []byte("\n")))...)
+		default:
+			out = append(out, text...)
+		}
+	})
+	return out
+}
+
+// isWord reports whether c may be part of an identifier or number
+func isWord(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,62 @@
+package lexer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		want     []string // comments (C) and literals (L) in order
+	}{
+		{"comment markers in strings", "cpp", `url = "http://x/*y*/"; /* a "quote */ x = '/'; // it's`,
+			[]string{`L"http://x/*y*/"`, `C/* a "quote */`, `L'/'`, `C// it's`}},
+		{"escapes", "cpp", `s = "a\"// b\\"; // c`, []string{`L"a\"// b\\"`, `C// c`}},
+		{"line continuation", "cpp", "// one \\\n two\nthree; // four\r\n",
+			[]string{"C// one \\\n two", "C// four\r"}},
+		{"raw strings", "cpp", `s = R"x(a ")// b)x"; t = u8R"(/*)"; // c`,
+			[]string{`L"x(a ")// b)x"`, `L"(/*)"`, `C// c`}},
+		{"digit separators", "cpp", `n = 1'000'000 + 0x1'F; c = 'x'; // d`, []string{`L'x'`, `C// d`}},
+		{"unterminated string", "cpp", "s = \"abc\n// c", []string{`L"abc`, "C// c"}},
+		{"unterminated comment", "cpp", "x; /* y", []string{"C/* y"}},
+		{"python", "python", "s = '#' # c\nd = \"\"\"doc \"q\" # \"\"\"\n", []string{`L'#'`, "C# c", `L"""doc "q" # """`}},
+		{"java text block", "java", "s = \"\"\"\n  // x\n  \"\"\"; // y", []string{"L\"\"\"\n  // x\n  \"\"\"", "C// y"}},
+		{"cmake", "cmake", "set(X \"a#b\") # c\n#[[ block\n]] find_package(Y) # d", []string{`L"a#b"`, "C# c", "C#[[ block\n]]", "C# d"}},
+	}
+
+	for _, tt := range tests {
+		src := []byte(tt.src)
+		var got []string
+		var joined bytes.Buffer
+		ForLanguage(tt.language).Scan(src, func(kind Kind, start, end int) {
+			joined.Write(src[start:end])
+			switch kind {
+			case Comment:
+				got = append(got, "C"+string(src[start:end]))
+			case Literal:
+				got = append(got, "L"+string(src[start:end]))
+			}
+		})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Scan() = %q, want %q", tt.name, got, tt.want)
+		}
+		if joined.String() != tt.src {
+			t.Errorf("%s: spans do not cover the source", tt.name)
+		}
+	}
+}
+
+func TestStrip(t *testing.T) {
+	src := "int f() { /* multi\nline */ return g(\"//\", 'x'); } // end\n"
+	cpp := ForLanguage("cpp")
+	if got, want := string(cpp.Strip([]byte(src), false)), "int f() { \n return g(\"//\", 'x'); }  \n"; got != want {
+		t.Errorf("Strip() = %q, want %q", got, want)
+	}
+	if got, want := string(cpp.Strip([]byte(src), true)), "int f() { \n return g(\"\", ''); }  \n"; got != want {
+		t.Errorf("Strip(literals) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+// Package normalize rewrites source code into a canonical form before it is
+// hashed, so that copies differing only in comments, layout or identifier
+// names hash alike.
+package normalize
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/lexer"
+)
+
+// Identifier is the placeholder substituted for identifiers
+const Identifier = "_"
+
+// Options selects the normalization steps
+type Options struct {
+	// StripComments removes comments
+	StripComments bool `mapstructure:"strip_comments"`
+	// CollapseWhitespace removes whitespace except a single space between
+	// two words
+	CollapseWhitespace bool `mapstructure:"collapse_whitespace"`
+	// AbstractIdentifiers replaces identifiers other than keywords with a
+	// placeholder
+	AbstractIdentifiers bool `mapstructure:"abstract_identifiers"`
+}
+
+// Enabled reports whether any step is selected
+func (o Options) Enabled() bool {
+	return o.StripComments || o.CollapseWhitespace || o.AbstractIdentifiers
+}
+
+// Describe returns a stable description of the normalization of each
+// language, such as "cpp=strip_comments+collapse_whitespace", or "none".
+// Languages without any step are left out.
+func Describe(options map[string]Options) string {
+	var languages []string
+	for language, opts := range options {
+		if !opts.Enabled() {
+			continue
+		}
+		var steps []string
+		if opts.StripComments {
+			steps = append(steps, "strip_comments")
+		}
+		if opts.CollapseWhitespace {
+			steps = append(steps, "collapse_whitespace")
+		}
+		if opts.AbstractIdentifiers {
+			steps = append(steps, "abstract_identifiers")
+		}
+		languages = append(languages, language+"="+strings.Join(steps, "+"))
+	}
+	if len(languages) == 0 {
+		return "none"
+	}
+	sort.Strings(languages)
+	return strings.Join(languages, ",")
+}
+
+// Normalizer normalizes source code of one language
+type Normalizer struct {
+	opts     Options
+	syntax   lexer.Syntax
+	keywords map[string]bool
+}
+
+// New creates a Normalizer for a language. Languages without known syntax
+// use C-style comments and no keywords.
+func New(language string, opts Options) *Normalizer {
+	return &Normalizer{opts: opts, syntax: lexer.ForLanguage(language), keywords: keywordSets[language]}
+}
+
+// Normalize returns the normalized form of content. String and character
+// literals are kept verbatim.
+func (n *Normalizer) Normalize(content []byte) []byte {
+	if !n.opts.Enabled() {
+		return content
+	}
+
+	out := make([]byte, 0, len(content))
+	pendingSpace := false
+
+	// emit appends a token, separating it from the previous one by a single
+	// space if whitespace is collapsed and both sides are word characters
+	emit := func(token []byte) {
+		if pendingSpace && len(out) > 0 && isWord(out[len(out)-1]) && isWord(token[0]) {
+			out = append(out, ' ')
+		}
+		pendingSpace = false
+		out = append(out, token...)
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		rest := content[i:]
+
+		switch kind, end := n.syntax.Span(content, i); {
+		case kind == lexer.Comment:
+			if !n.opts.StripComments {
+				emit(content[i:end])
+			} else {
+				pendingSpace = true
+			}
+			i = end
+
+		case kind == lexer.Literal:
+			emit(content[i:end])
+			i = end
+
+		case isSpace(c):
+			end := 1
+			for end < len(rest) && isSpace(rest[end]) {
+				end++
+			}
+			if n.opts.CollapseWhitespace {
+				pendingSpace = true
+			} else {
+				out = append(out, rest[:end]...)
+			}
+			i += end
+
+		case isWord(c):
+			end := 1
+			for end < len(rest) && isWord(rest[end]) {
+				end++
+			}
+			word := rest[:end]
+			if n.opts.AbstractIdentifiers && isIdentifierStart(c) && !n.keywords[string(word)] {
+				word = []byte(Identifier)
+			}
+			emit(word)
+			i += end
+
+		default:
+			emit(rest[:1])
+			i++
+		}
+	}
+
+	return out
+}
+
+// isSpace reports whether c is white space
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// isWord reports whether c may be part of an identifier or number
+func isWord(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// isIdentifierStart reports whether c may start an identifier
+func isIdentifierStart(c byte) bool {
+	return isWord(c) && (c < '0' || c > '9')
+}
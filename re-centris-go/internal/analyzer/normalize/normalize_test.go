@@ -0,0 +1,31 @@
+package normalize
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	original := "int add(int a, int b) {\n    // sum\n    return a + b; /* done */\n}\n"
+	modified := "int sum(int x,int y){return x+y;}"
+
+	n := New("cpp", Options{StripComments: true, CollapseWhitespace: true, AbstractIdentifiers: true})
+	if got, want := string(n.Normalize([]byte(original))), "int _(int _,int _){return _+_;}"; got != want {
+		t.Errorf("Normalize() = %q, want %q", got, want)
+	}
+	if a, b := n.Normalize([]byte(original)), n.Normalize([]byte(modified)); string(a) != string(b) {
+		t.Errorf("Normalize() differs for renamed copy: %q != %q", a, b)
+	}
+
+	// Literals are kept and comment markers inside them are not comments
+	n = New("cpp", Options{StripComments: true})
+	if got, want := string(n.Normalize([]byte(`s = "// not a comment"; // comment`))), `s = "// not a comment"; `; got != want {
+		t.Errorf("Normalize() = %q, want %q", got, want)
+	}
+
+	n = New("python", Options{StripComments: true, CollapseWhitespace: true})
+	if got, want := string(n.Normalize([]byte("def f(x):  # double\n    return x // 2\n"))), "def f(x):return x//2"; got != want {
+		t.Errorf("Normalize(python) = %q, want %q", got, want)
+	}
+
+	if got := New("cpp", Options{}).Normalize([]byte(original)); string(got) != original {
+		t.Errorf("Normalize() without options = %q", got)
+	}
+}
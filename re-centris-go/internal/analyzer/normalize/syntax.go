@@ -0,0 +1,31 @@
+package normalize
+
+import "strings"
+
+// keywordSets holds the keywords of the supported languages
+var keywordSets = map[string]map[string]bool{
+	"cpp": keywords(`alignas alignof asm auto bool break case catch char class const
+		constexpr const_cast continue decltype default delete do double dynamic_cast else
+		enum explicit extern false float for friend goto if inline int long mutable
+		namespace new noexcept nullptr operator private protected public register
+		reinterpret_cast return short signed sizeof static static_assert static_cast
+		struct switch template this throw true try typedef typeid typename union
+		unsigned using virtual void volatile while`),
+	"java": keywords(`abstract assert boolean break byte case catch char class const
+		continue default do double else enum extends false final finally float for goto
+		if implements import instanceof int interface long native new null package
+		private protected public return short static strictfp super switch synchronized
+		this throw throws transient true try var void volatile while`),
+	"python": keywords(`False None True and as assert async await break class continue
+		def del elif else except finally for from global if import in is lambda
+		nonlocal not or pass raise return try while with yield`),
+}
+
+// keywords builds a keyword set from a whitespace-separated list
+func keywords(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
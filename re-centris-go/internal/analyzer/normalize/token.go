@@ -0,0 +1,123 @@
+package normalize
+
+import (
+	"bytes"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/lexer"
+)
+
+// TokenKind classifies a token
+type TokenKind int
+
+// Token kinds
+const (
+	Keyword TokenKind = iota
+	Ident
+	Number
+	String
+	Operator
+)
+
+// Token is a lexical token of source code
+type Token struct {
+	Kind TokenKind
+	Text string
+}
+
+// Placeholders substituted for tokens whose text is abstracted in a token
+// stream
+const (
+	identPlaceholder  = "ID"
+	numberPlaceholder = "N"
+	stringPlaceholder = "S"
+)
+
+// Tokenize splits source code of a language into tokens, dropping comments
+// and white space. Operators are single punctuation characters.
+func Tokenize(language string, content []byte) []Token {
+	var tokens []Token
+	scan(language, content, func(kind TokenKind, text []byte) {
+		tokens = append(tokens, Token{Kind: kind, Text: string(text)})
+	})
+	return tokens
+}
+
+// scan calls fn for every token of source code of a language. text points
+// into content.
+func scan(language string, content []byte, fn func(kind TokenKind, text []byte)) {
+	n := New(language, Options{StripComments: true})
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		rest := content[i:]
+
+		switch kind, end := n.syntax.Span(content, i); {
+		case kind == lexer.Comment:
+			i = end
+
+		case kind == lexer.Literal:
+			fn(String, content[i:end])
+			i = end
+
+		case isSpace(c):
+			i++
+
+		case isWord(c):
+			end := 1
+			for end < len(rest) && (isWord(rest[end]) || !isIdentifierStart(c) && n.numberSeparator(rest[end:])) {
+				end++
+			}
+			word := rest[:end]
+			kind := Ident
+			switch {
+			case !isIdentifierStart(c):
+				kind = Number
+			case n.keywords[string(word)]:
+				kind = Keyword
+			}
+			fn(kind, word)
+			i += end
+
+		default:
+			fn(Operator, rest[:1])
+			i++
+		}
+	}
+}
+
+// numberSeparator reports whether s continues a number with a decimal
+// point or a digit separator followed by a digit
+func (n *Normalizer) numberSeparator(s []byte) bool {
+	return s[0] == '.' || n.syntax.DigitSeparators && s[0] == '\'' && len(s) > 1 && isWord(s[1])
+}
+
+// TokenStream renders the tokens of source code with identifiers, numbers
+// and literals replaced by placeholders, one space between tokens. Code
+// differing only in formatting, comments and names yields the same stream.
+func TokenStream(language string, content []byte) []byte {
+	var b bytes.Buffer
+	WriteTokenStream(&b, language, content)
+	return b.Bytes()
+}
+
+// WriteTokenStream writes the token stream of source code to b, see
+// TokenStream. It lets callers reuse buffers across files.
+func WriteTokenStream(b *bytes.Buffer, language string, content []byte) {
+	first := true
+	scan(language, content, func(kind TokenKind, text []byte) {
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		switch kind {
+		case Ident:
+			b.WriteString(identPlaceholder)
+		case Number:
+			b.WriteString(numberPlaceholder)
+		case String:
+			b.WriteString(stringPlaceholder)
+		default:
+			b.Write(text)
+		}
+	})
+}
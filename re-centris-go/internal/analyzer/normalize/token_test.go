@@ -0,0 +1,20 @@
+package normalize
+
+import "testing"
+
+func TestTokenStream(t *testing.T) {
+	original := "int add(int a, int b) {\n    // sum\n    return a + b * 2; /* done */\n}\n"
+	modified := "int sum(int x,int y){return x+y*10;}"
+
+	if got, want := string(TokenStream("cpp", []byte(original))), "int ID ( int ID , int ID ) { return ID + ID * N ; }"; got != want {
+		t.Errorf("TokenStream() = %q, want %q", got, want)
+	}
+	if a, b := TokenStream("cpp", []byte(original)), TokenStream("cpp", []byte(modified)); string(a) != string(b) {
+		t.Errorf("TokenStream() differs for renamed copy: %q != %q", a, b)
+	}
+
+	tokens := Tokenize("python", []byte("x = 'a # b' # c\n"))
+	if len(tokens) != 3 || tokens[2].Kind != String || tokens[2].Text != "'a # b'" {
+		t.Errorf("Tokenize(python) = %v", tokens)
+	}
+}
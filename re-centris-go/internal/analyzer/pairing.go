@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+)
+
+// headerExtensions and sourceExtensions are the extensions of the C/C++
+// headers and implementation files paired by PairDeclarations
+var (
+	headerExtensions = map[string]bool{".h": true, ".hh": true, ".hpp": true, ".hxx": true}
+	sourceExtensions = map[string]bool{".c": true, ".cc": true, ".cpp": true, ".cxx": true}
+)
+
+// PairDeclarations links the functions defined in C/C++ implementation
+// files to their prototypes in the headers of the same name and directory,
+// such as foo.cpp and foo.h, setting their Declaration. A definition of
+// Class::method matches a declaration of method in class Class, and names
+// qualified by a namespace on only one side still match; of overloads,
+// the declaration with the same signature is linked, or else the first.
+func PairDeclarations(files []*FileInfo) {
+	headers := make(map[string][]*FileInfo)
+	for _, file := range files {
+		if headerExtensions[strings.ToLower(filepath.Ext(file.Path))] && len(file.Declarations) > 0 {
+			stem := strings.TrimSuffix(file.Path, filepath.Ext(file.Path))
+			headers[stem] = append(headers[stem], file)
+		}
+	}
+	if len(headers) == 0 {
+		return
+	}
+
+	for _, file := range files {
+		if !sourceExtensions[strings.ToLower(filepath.Ext(file.Path))] {
+			continue
+		}
+		pairs := headers[strings.TrimSuffix(file.Path, filepath.Ext(file.Path))]
+		for i := range file.Functions {
+			fn := &file.Functions[i]
+			for _, header := range pairs {
+				if d, ok := findDeclaration(header.Declarations, fn.Name, fn.Signature); ok {
+					fn.Declaration = fmt.Sprintf("%s:%d", filepath.Base(header.Path), d.Line)
+					break
+				}
+			}
+		}
+	}
+}
+
+// findDeclaration returns the declaration of a function, comparing
+// qualified names by their common suffix and preferring the declaration
+// with the same signature
+func findDeclaration(declarations []parser.Declaration, name, signature string) (parser.Declaration, bool) {
+	var (
+		first parser.Declaration
+		found bool
+	)
+	for _, d := range declarations {
+		if d.Name != name && !strings.HasSuffix(d.Name, "::"+name) && !strings.HasSuffix(name, "::"+d.Name) {
+			continue
+		}
+		if d.Signature == signature {
+			return d, true
+		}
+		if !found {
+			first, found = d, true
+		}
+	}
+	return first, found
+}
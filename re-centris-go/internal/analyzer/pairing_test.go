@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPairDeclarations(t *testing.T) {
+	dir := t.TempDir()
+	body := strings.Repeat("\tcount = count * 31 + (int)sizeof(buffer) - offset;\n", 4)
+	files := map[string]string{
+		"buffer.h": "#ifndef BUFFER_H\n#define BUFFER_H\n\nnamespace io {\nclass Buffer {\npublic:\n\tint fill(const char *data);\n\tvoid clear();\n};\n}\n\nint buffer_count(void);\n\n#endif\n" + strings.Repeat("/* padding */\n", 4),
+		"buffer.cpp": "#include \"buffer.h\"\n\nint io::Buffer::fill(const char *data)\n{\n" + body + "\treturn count;\n}\n\n" +
+			"int buffer_count(void)\n{\n" + body + "\treturn count;\n}\n\n" +
+			"static int helper(int x)\n{\n" + body + "\treturn x;\n}\n",
+		"other.c": "int buffer_count(void)\n{\n" + body + "\treturn 0;\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, pair := range []bool{false, true} {
+		a := New(AnalyzerOptions{MaxWorkers: 2, Languages: map[string][]string{"cpp": {".c", ".cpp", ".h"}}, PairDeclarations: pair})
+		analyzed, err := a.AnalyzeDirectory(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("AnalyzeDirectory failed: %v", err)
+		}
+
+		got := make(map[string]string)
+		for _, file := range analyzed {
+			for _, fn := range file.Functions {
+				got[filepath.Base(file.Path)+":"+fn.Name] = fn.Declaration
+			}
+			if filepath.Base(file.Path) == "buffer.h" && len(file.Functions) != 0 {
+				t.Errorf("buffer.h: prototypes taken for %d functions", len(file.Functions))
+			}
+		}
+		want := map[string]string{
+			"buffer.cpp:io::Buffer::fill": "buffer.h:7",
+			"buffer.cpp:buffer_count":     "buffer.h:12",
+			"buffer.cpp:helper":           "",
+			"other.c:buffer_count":        "",
+		}
+		for key, declaration := range want {
+			if !pair {
+				declaration = ""
+			}
+			if d, ok := got[key]; !ok || d != declaration {
+				t.Errorf("pair %v: %s declared at %q, want %q", pair, key, d, declaration)
+			}
+		}
+	}
+}
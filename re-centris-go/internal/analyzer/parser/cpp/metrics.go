@@ -0,0 +1,63 @@
+package cpp
+
+// decisions are the keywords adding a path through a function, counted in
+// its cyclomatic complexity along with &&, || and ?
+var decisions = map[string]bool{
+	"if": true, "for": true, "while": true, "case": true, "catch": true,
+}
+
+// operators are the two-byte tokens, counted once; paired quotes are the
+// literals the scanner replaces strings and characters with
+var operators = map[string]bool{
+	"&&": true, "||": true, "==": true, "!=": true, "<=": true, ">=": true,
+	"++": true, "--": true, "->": true, "::": true, "<<": true, ">>": true,
+	"+=": true, "-=": true, "*=": true, "/=": true, "%=": true, "&=": true,
+	"|=": true, "^=": true, `""`: true, "''": true,
+}
+
+// measure adds a byte of code in the body of a function to its token
+// count, cyclomatic complexity and nesting depth
+func (s *scanner) measure(c byte) {
+	f := s.function
+	if isIdent(c) || c == '.' && len(s.word) > 0 && s.word[0] >= '0' && s.word[0] <= '9' {
+		if len(s.word) == 0 {
+			f.Tokens++
+		}
+		s.word = append(s.word, c)
+		return
+	}
+	if len(s.word) > 0 {
+		s.endToken()
+	}
+
+	if s.prev != 0 && operators[string([]byte{s.prev, c})] {
+		if c == s.prev && (c == '&' || c == '|') {
+			f.Complexity++
+		}
+		// Only << and >> continue, as in <<= and >>=
+		if c != s.prev || c != '<' && c != '>' {
+			s.prev = 0
+		}
+	} else {
+		f.Tokens++
+		s.prev = c
+	}
+	switch c {
+	case '?':
+		f.Complexity++
+	case '{':
+		f.Depth = max(f.Depth, s.body-1)
+	}
+}
+
+// endToken ends the identifier or operator being measured, counting the
+// decision keywords
+func (s *scanner) endToken() {
+	if len(s.word) > 0 {
+		if decisions[string(s.word)] {
+			s.function.Complexity++
+		}
+		s.word = s.word[:0]
+	}
+	s.prev = 0
+}
@@ -0,0 +1,49 @@
+package cpp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMeasure(t *testing.T) {
+	src := `int getter() const { return value_; }
+
+int parse(const char *s, int n)
+{
+	int total = 0; // if (n) { for }
+	for (int i = 0; i < n && s[i]; i++) {
+		switch (s[i]) {
+		case '-':
+			total -= 1;
+			break;
+		case '+':
+			if (total > 9 || strict) {
+				total = "{if}" ? 1.5 : 0;
+			}
+			break;
+		}
+	}
+	while (total >= 0x10)
+		total >>= 1;
+	return total;
+}
+`
+	functions, _, _ := scan(context.Background(), []byte(src))
+	if len(functions) != 2 {
+		t.Fatalf("scan() found %d functions, want 2", len(functions))
+	}
+
+	want := []struct{ complexity, tokens, depth int }{
+		// return value_ ;
+		{1, 3, 0},
+		// for, &&, case, case, if, ||, ?, while
+		{9, 80, 3},
+	}
+	for i, w := range want {
+		f := functions[i]
+		if f.Complexity != w.complexity || f.Tokens != w.tokens || f.Depth != w.depth {
+			t.Errorf("%s: complexity %d, tokens %d, depth %d, want %d, %d, %d",
+				f.Name, f.Complexity, f.Tokens, f.Depth, w.complexity, w.tokens, w.depth)
+		}
+	}
+}
@@ -6,14 +6,14 @@ import (
 )
 
 func TestCPPParser_GetLanguage(t *testing.T) {
-	parser := New()
+	parser := New(Options{})
 	if lang := parser.GetLanguage(); lang != "cpp" {
 		t.Errorf("GetLanguage() = %v, want cpp", lang)
 	}
 }
 
 func TestCPPParser_GetExtensions(t *testing.T) {
-	parser := New()
+	parser := New(Options{})
 	exts := parser.GetExtensions()
 	expected := []string{".c", ".cc", ".cpp", ".cxx", ".h", ".hpp"}
 
@@ -56,18 +56,24 @@ func TestCPPParser_Parse(t *testing.T) {
 					virtual void process() = 0;
 				};
 			`,
-			wantFunctions: 2,
-			wantNames:     []string{"add", "process"},
+			wantFunctions: 1,
+			wantNames:     []string{"add"},
 		},
 		{
 			name: "multiple functions",
 			code: `
-				void init() {}
+				void init() {
+					counter = 0;
+					buffer = allocate_buffer(DEFAULT_SIZE);
+				}
 				int calculate(double x) {
 					return static_cast<int>(x);
 				}
 				namespace test {
-					void helper() {}
+					void helper() {
+						log_message("helper called", counter);
+						counter += 1;
+					}
 				}
 			`,
 			wantFunctions: 3,
@@ -86,7 +92,7 @@ func TestCPPParser_Parse(t *testing.T) {
 		},
 	}
 
-	parser := New()
+	parser := New(Options{})
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			reader := strings.NewReader(tt.code)
@@ -160,7 +166,7 @@ func TestCPPParser_ParseEdgeCases(t *testing.T) {
 		},
 	}
 
-	parser := New()
+	parser := New(Options{})
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			reader := strings.NewReader(tt.code)
@@ -192,7 +198,7 @@ func BenchmarkCPPParser_Parse(b *testing.B) {
 		}
 	`
 	
-	parser := New()
+	parser := New(Options{})
 	b.ResetTimer()
 	
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,166 @@
+package cpp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/lexer"
+)
+
+// Modes of handling conditional compilation before parsing
+const (
+	// ConditionalsStrip keeps the first branch of each conditional whose
+	// condition is not a literal 0, so alternative versions of a function do
+	// not unbalance its braces
+	ConditionalsStrip = "strip"
+	// ConditionalsAll keeps the code of every branch, finding the functions
+	// defined in each at the risk of unbalanced braces
+	ConditionalsAll = "all"
+)
+
+// ParseConditionals validates a mode of handling conditional compilation;
+// empty means ConditionalsStrip
+func ParseConditionals(mode string) (string, error) {
+	switch mode = strings.ToLower(mode); mode {
+	case "", ConditionalsStrip:
+		return ConditionalsStrip, nil
+	case ConditionalsAll:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported conditionals mode: %s", mode)
+}
+
+// ConditionalsKey returns the mode recorded in cache keys and fingerprints:
+// empty for ConditionalsStrip, so keys of default analyses are unchanged
+func ConditionalsKey(mode string) string {
+	if mode, _ = ParseConditionals(mode); mode == ConditionalsStrip {
+		return ""
+	}
+	return mode
+}
+
+// conditional is an #if block being preprocessed
+type conditional struct {
+	outer bool // the enclosing code is kept
+	keep  bool // the current branch is kept
+	taken bool // a branch has been kept
+}
+
+// Preprocess returns src with its preprocessor directives blanked out and,
+// with ConditionalsStrip, the branches not kept. Blanked lines keep their
+// newline so that line numbers are preserved. Directives in block comments
+// and raw strings are left alone; conditions other than a literal 0 are not
+// evaluated.
+func Preprocess(src []byte, mode string) []byte {
+	mode, _ = ParseConditionals(mode)
+	out := make([]byte, 0, len(src))
+	var stack []conditional
+	keep := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].keep
+	}
+	inSpan := multilineSpans(src)
+
+	for offset := 0; offset < len(src); {
+		line, rest, newline := cutLine(src[offset:])
+		start := offset
+		offset = len(src) - len(rest)
+
+		trimmed := bytes.TrimLeft(line, " \t")
+		if inSpan(start) || len(trimmed) == 0 || trimmed[0] != '#' {
+			if keep() {
+				out = append(out, line...)
+			}
+			if newline {
+				out = append(out, '\n')
+			}
+			continue
+		}
+
+		// A directive continues over lines ending with a backslash
+		directive := string(trimmed)
+		for newline && bytes.HasSuffix(line, []byte("\\")) && offset < len(src) {
+			out = append(out, '\n')
+			line, rest, newline = cutLine(src[offset:])
+			offset = len(src) - len(rest)
+			directive += string(line)
+		}
+		if newline {
+			out = append(out, '\n')
+		}
+		name, arg := splitDirective(directive)
+		if mode == ConditionalsAll {
+			continue
+		}
+
+		switch {
+		case name == "if" || name == "ifdef" || name == "ifndef":
+			k := keep() && !zeroCondition(name, arg)
+			stack = append(stack, conditional{outer: keep(), keep: k, taken: k})
+		case (name == "elif" || name == "elifdef" || name == "elifndef" || name == "else") && len(stack) > 0:
+			c := &stack[len(stack)-1]
+			c.keep = c.outer && !c.taken && !(name != "else" && zeroCondition(name, arg))
+			c.taken = c.taken || c.keep
+		case name == "endif" && len(stack) > 0:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return out
+}
+
+// cutLine splits the first line off src, reporting whether it ended with
+// a newline
+func cutLine(src []byte) (line, rest []byte, newline bool) {
+	if i := bytes.IndexByte(src, '\n'); i >= 0 {
+		return src[:i], src[i+1:], true
+	}
+	return src, nil, false
+}
+
+// splitDirective returns the name and argument of a directive, without a
+// trailing comment
+func splitDirective(directive string) (name, arg string) {
+	text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(directive), "#"))
+	i := 0
+	for i < len(text) && isIdent(text[i]) {
+		i++
+	}
+	name, arg = text[:i], text[i:]
+	if comment := strings.Index(arg, "//"); comment >= 0 {
+		arg = arg[:comment]
+	}
+	if comment := strings.Index(arg, "/*"); comment >= 0 {
+		arg = arg[:comment]
+	}
+	return name, strings.TrimSpace(arg)
+}
+
+// zeroCondition reports whether a conditional is literally false, as in
+// #if 0 which disables code
+func zeroCondition(name, arg string) bool {
+	if name != "if" && name != "elif" {
+		return false
+	}
+	arg = strings.ReplaceAll(arg, " ", "")
+	return arg == "0" || arg == "(0)"
+}
+
+// multilineSpans returns a function reporting whether an offset of src,
+// visited in increasing order, is inside a comment or literal continuing
+// over a line break
+func multilineSpans(src []byte) func(offset int) bool {
+	type span struct{ start, end int }
+	var spans []span
+	syntax.Scan(src, func(kind lexer.Kind, start, end int) {
+		if kind != lexer.Code && bytes.IndexByte(src[start:end], '\n') >= 0 {
+			spans = append(spans, span{start, end})
+		}
+	})
+	next := 0
+	return func(offset int) bool {
+		for next < len(spans) && spans[next].end <= offset {
+			next++
+		}
+		return next < len(spans) && spans[next].start < offset
+	}
+}
@@ -0,0 +1,109 @@
+package cpp
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ifdefHeavy mixes the conditional compilation patterns of portable C
+// libraries: alternative signatures, per-platform definitions, braces
+// opened in branches, disabled code and multi-line macros
+const ifdefHeavy = `#include "config.h"
+#ifndef LIB_H
+#define LIB_H
+
+#define SWAP(a, b) do { \
+	int t = (a); (a) = (b); (b) = t; \
+} while (0)
+
+#ifdef _WIN32
+static int open_file(const wchar_t *path, int flags)
+{
+#else
+static int open_file(const char *path, int flags)
+{
+#endif
+	return do_open(path, flags);
+}
+
+#if defined(HAVE_MMAP)
+void *map_pages(size_t n) { return mmap(NULL, n, PROT_READ, MAP_PRIVATE, -1, 0); }
+#elif defined(_WIN32)
+void *map_pages(size_t n) { return VirtualAlloc(NULL, n, MEM_COMMIT, PAGE_READWRITE); }
+#else
+void *map_pages(size_t n) { return malloc(n); }
+#endif
+
+int checksum(const unsigned char *buf, size_t len)
+{
+	int sum = 0;
+#if USE_SIMD
+	if (len >= 16) {
+#else
+	if (len > 0) {
+#endif
+		while (len--)
+			sum += *buf++;
+	}
+	return sum;
+}
+
+#if 0
+int legacy(void) {
+	return -1;
+#else
+/*
+#else
+*/
+int current(void) {
+	return 1;
+#endif
+}
+
+#endif /* LIB_H */
+`
+
+func TestPreprocess(t *testing.T) {
+	names := func(mode string) []string {
+		var got []string
+		functions, _, _ := scan(context.Background(), Preprocess([]byte(ifdefHeavy), mode))
+		for _, f := range functions {
+			got = append(got, f.Name)
+		}
+		return got
+	}
+
+	if got, want := names(ConditionalsStrip), []string{"open_file", "map_pages", "checksum", "current"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("strip: found %v, want %v", got, want)
+	}
+	// Every alternative is kept, but the two opening braces of open_file
+	// would swallow the rest of the file
+	section := ifdefHeavy[strings.Index(ifdefHeavy, "#if defined(HAVE_MMAP)"):strings.Index(ifdefHeavy, "int checksum")]
+	all, _, _ := scan(context.Background(), Preprocess([]byte(section), ConditionalsAll))
+	if len(all) != 3 || all[0].Name != "map_pages" || all[2].StartLine != 6 {
+		t.Errorf("all: found %d functions, want every map_pages", len(all))
+	}
+
+	for _, mode := range []string{ConditionalsStrip, ConditionalsAll} {
+		out := Preprocess([]byte(ifdefHeavy), mode)
+		if bytes.Count(out, []byte("\n")) != strings.Count(ifdefHeavy, "\n") {
+			t.Errorf("%s: line count changed", mode)
+		}
+		if bytes.Contains(out, []byte("#define")) || bytes.Contains(out, []byte("#endif\n")) {
+			t.Errorf("%s: directives left in output", mode)
+		}
+	}
+
+	functions, _, _ := scan(context.Background(), Preprocess([]byte(ifdefHeavy), ConditionalsStrip))
+	checksum := functions[2]
+	if checksum.StartLine != 27 || checksum.EndLine != 39 || strings.Contains(checksum.Content, "len > 0") {
+		t.Errorf("checksum = [%d, %d] %q", checksum.StartLine, checksum.EndLine, checksum.Content)
+	}
+
+	if _, err := ParseConditionals("evaluate"); err == nil {
+		t.Error("ParseConditionals accepted an unknown mode")
+	}
+}
@@ -0,0 +1,594 @@
+package cpp
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/lexer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+)
+
+// maxHeaderSize bounds the declaration text kept before a brace; longer
+// runs without a statement boundary are not declarations
+const maxHeaderSize = 4096
+
+// syntax locates the comments and literals of C/C++ source
+var syntax = lexer.ForLanguage("cpp")
+
+var (
+	// namePattern extracts the declarator name before a parameter list:
+	// a possibly qualified identifier, destructor or operator
+	namePattern = regexp.MustCompile(`((?:[A-Za-z_]\w*(?:\s*<[^<>;{}]*>)?\s*::\s*)*~?(?:operator\s*(?:\(\s*\)|\[\s*\]|[^\s\w(]+|[A-Za-z_]\w*)|[A-Za-z_]\w*)(?:\s*<[^<>;{}]*>)?)\s*$`)
+
+	// containerPattern matches the headers of scopes holding declarations
+	containerPattern = regexp.MustCompile(`^(?:(?:typedef|export|inline)\s+)*(?:namespace\b|(?:class|struct|union|enum)\b[^=()]*$|extern\s*""\s*$)`)
+
+	// templatePrefix matches the start of a template parameter list
+	templatePrefix = regexp.MustCompile(`^template\s*<`)
+
+	// specifiers matches the pure, defaulted and deleted specifiers ending
+	// a declaration
+	specifiers = regexp.MustCompile(`=\s*(?:0|default|delete)\s*$`)
+
+	// containerNamePattern extracts the name of a namespace or class
+	containerNamePattern = regexp.MustCompile(`^(?:(?:typedef|export|inline)\s+)*(?:namespace|class|struct|union)\s+(?:\[\[[^\]]*\]\]\s*)?(?:[A-Z_][A-Z0-9_]*\s+)?([A-Za-z_][\w:]*)`)
+)
+
+// notNames are words followed by parentheses that do not name a function
+var notNames = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"sizeof": true, "alignof": true, "alignas": true, "decltype": true, "typeof": true,
+	"__attribute__": true, "__declspec": true, "noexcept": true, "throw": true,
+	"asm": true, "__asm__": true, "void": true, "new": true, "delete": true, "do": true, "else": true,
+}
+
+// qualifiers may follow the parameter list of a function definition
+var qualifiers = map[string]bool{
+	"const": true, "volatile": true, "noexcept": true, "throw": true, "override": true,
+	"final": true, "mutable": true, "try": true, "__attribute__": true, "__declspec": true,
+}
+
+// accessSpecifiers end a declaration in a class like a semicolon
+var accessSpecifiers = map[string]bool{"public:": true, "protected:": true, "private:": true}
+
+// Kinds of the scope a brace opens
+const (
+	scopeBlock = iota
+	scopeContainer
+	scopeFunction
+)
+
+// scanCheckInterval is the number of bytes scanned between checks of
+// whether the context of the scan is done
+const scanCheckInterval = 64 << 10
+
+// scanner finds the function definitions of C/C++ source in a single pass
+// over its bytes, tracking preprocessor directives, comments and literals
+// (see lexer.Syntax) and brace depth. The code since the last declaration boundary (;, { or } at
+// namespace or class level) is kept as the header of the next brace, which
+// tells function bodies from namespaces, classes and initializers.
+type scanner struct {
+	src       []byte
+	pos       int
+	line      int
+	lineStart int
+	blank     bool // only whitespace since lineStart
+
+	header      []byte
+	headerLine  int
+	headerStart int // offset of the line the header starts on
+	overflow    bool
+	nested      int // depth of member initializer braces in the header
+
+	body       int      // brace depth inside a function or block
+	containers []string // names of the enclosing namespaces and classes
+	function   *parser.Function
+	start      int    // offset of the line the function starts on
+	word       []byte // identifier or number being measured in the body
+	prev       byte   // operator byte that may pair with the next one
+
+	functions    []parser.Function
+	declarations []parser.Declaration
+}
+
+// scan returns the functions defined in src and those only declared, or
+// the error of ctx once it is done
+func scan(ctx context.Context, src []byte) ([]parser.Function, []parser.Declaration, error) {
+	s := &scanner{src: src, line: 1, blank: true}
+	check := 0
+	for s.pos < len(src) {
+		if s.pos >= check {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+			check = s.pos + scanCheckInterval
+		}
+		c := src[s.pos]
+		switch {
+		case c == '\n':
+			s.pos++
+			s.newline()
+			s.space()
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			s.pos++
+			s.space()
+		case c == '\\' && s.peek(1) == '\n':
+			s.pos += 2
+			s.newline()
+		case c == '#' && s.blank:
+			s.directive()
+		case c == '/' || c == '"' || c == '\'':
+			s.span(c)
+		default:
+			s.pos++
+			s.code(c)
+		}
+	}
+	return s.functions, s.declarations, nil
+}
+
+func (s *scanner) peek(n int) byte {
+	if s.pos+n < len(s.src) {
+		return s.src[s.pos+n]
+	}
+	return 0
+}
+
+// newline records the start of a line at pos
+func (s *scanner) newline() {
+	s.line++
+	s.lineStart = s.pos
+	s.blank = true
+}
+
+// skipTo moves to end, counting the lines passed
+func (s *scanner) skipTo(end int) {
+	for s.pos < end {
+		i := bytes.IndexByte(s.src[s.pos:end], '\n')
+		if i < 0 {
+			s.pos = end
+			break
+		}
+		s.pos += i + 1
+		s.newline()
+	}
+	s.blank = false
+}
+
+// lineEnd returns the offset of the newline ending the line at i, or the
+// end of the source
+func (s *scanner) lineEnd(i int) int {
+	if end := bytes.IndexByte(s.src[i:], '\n'); end >= 0 {
+		return i + end
+	}
+	return len(s.src)
+}
+
+// span skips the comment or literal at pos, separating the tokens around
+// a comment and adding an empty literal to the header, or handles c as code
+// if neither starts there
+func (s *scanner) span(c byte) {
+	switch kind, end := syntax.Span(s.src, s.pos); kind {
+	case lexer.Comment:
+		s.skipTo(end)
+		s.space()
+	case lexer.Literal:
+		s.skipTo(end)
+		s.code(c)
+		s.code(c)
+	default:
+		s.pos++
+		s.code(c)
+	}
+}
+
+// directive skips a preprocessor directive at pos, following line
+// continuations; conditionals are resolved by Preprocess beforehand
+func (s *scanner) directive() {
+	end := s.pos
+	for {
+		end = s.lineEnd(end)
+		if end == len(s.src) || s.src[end-1] != '\\' {
+			break
+		}
+		end++
+	}
+	s.skipTo(end)
+	s.space()
+}
+
+// space separates the tokens of the header
+func (s *scanner) space() {
+	if s.function != nil {
+		s.endToken()
+	}
+	if s.body == 0 && len(s.header) > 0 && s.header[len(s.header)-1] != ' ' {
+		s.appendHeader(' ')
+	}
+}
+
+func (s *scanner) appendHeader(c byte) {
+	if len(s.header) == 0 {
+		s.headerLine, s.headerStart = s.line, s.lineStart
+	}
+	if len(s.header) >= maxHeaderSize {
+		s.overflow = true
+		return
+	}
+	s.header = append(s.header, c)
+}
+
+func (s *scanner) resetHeader() {
+	s.header, s.overflow, s.nested = s.header[:0], false, 0
+}
+
+// code handles a byte of code outside comments and literals
+func (s *scanner) code(c byte) {
+	s.blank = false
+	if s.body > 0 {
+		switch c {
+		case '{':
+			s.body++
+		case '}':
+			if s.body--; s.body == 0 {
+				s.closeBody()
+				return
+			}
+		}
+		if s.function != nil {
+			s.measure(c)
+		}
+		return
+	}
+	if s.nested > 0 {
+		s.appendHeader(c)
+		switch c {
+		case '{':
+			s.nested++
+		case '}':
+			s.nested--
+		}
+		return
+	}
+
+	switch c {
+	case '{':
+		s.open()
+	case '}':
+		if len(s.containers) > 0 {
+			s.containers = s.containers[:len(s.containers)-1]
+		}
+		s.resetHeader()
+	case ';':
+		s.declare()
+		s.resetHeader()
+	case ':':
+		s.appendHeader(c)
+		if accessSpecifiers[strings.TrimSpace(string(s.header))] {
+			s.resetHeader()
+		}
+	default:
+		s.appendHeader(c)
+	}
+}
+
+// open classifies the scope of a brace by its header
+func (s *scanner) open() {
+	header := strings.TrimSpace(string(s.header))
+	if !s.overflow && memberInitializer(header) {
+		s.appendHeader('{')
+		s.nested = 1
+		return
+	}
+
+	kind, d := scopeBlock, declarator{}
+	if !s.overflow {
+		kind, d = classify(header)
+	}
+	switch kind {
+	case scopeContainer:
+		s.containers = append(s.containers, containerName(header))
+	case scopeFunction:
+		scope := s.scope()
+		if i := strings.LastIndex(d.name, "::"); i >= 0 {
+			scope = joinScope(scope, d.name[:i])
+		}
+		s.function = &parser.Function{Name: d.name, StartLine: s.headerLine, Scope: scope, Signature: d.signature(), Complexity: 1}
+		s.start = s.headerStart
+		s.body = 1
+	default:
+		s.body = 1
+	}
+	s.resetHeader()
+}
+
+// declare records the function declared by the header ending at a
+// semicolon, if any, qualified by the enclosing namespaces and classes
+func (s *scanner) declare() {
+	if s.overflow || len(s.header) == 0 {
+		return
+	}
+	enclosing := ""
+	if len(s.containers) > 0 {
+		enclosing = s.containers[len(s.containers)-1]
+	}
+	if d, ok := prototype(strings.TrimSpace(string(s.header)), enclosing); ok {
+		s.declarations = append(s.declarations, parser.Declaration{
+			Name:      joinScope(s.scope(), d.name),
+			Line:      s.headerLine,
+			Signature: d.signature(),
+		})
+	}
+}
+
+// scope returns the qualified name of the enclosing namespaces and classes
+func (s *scanner) scope() string {
+	var scope string
+	for _, name := range s.containers {
+		scope = joinScope(scope, name)
+	}
+	return scope
+}
+
+// joinScope qualifies a name by a scope, either of which may be empty
+func joinScope(scope, name string) string {
+	if scope == "" || name == "" {
+		return scope + name
+	}
+	return scope + "::" + name
+}
+
+// closeBody records the function whose body closes at pos
+func (s *scanner) closeBody() {
+	if f := s.function; f != nil {
+		s.endToken()
+		end := s.lineEnd(s.pos - 1)
+		if end < len(s.src) {
+			end++
+		}
+		f.EndLine = s.line
+		f.Content = string(s.src[s.start:end])
+		if hash, err := tlsh.New([]byte(f.Content)); err == nil {
+			f.Hash = hash.String()
+		}
+		s.functions = append(s.functions, *f)
+		s.function = nil
+	}
+	s.resetHeader()
+}
+
+// classify returns the kind of scope opened after a header and, for
+// functions, their declarator
+func classify(header string) (int, declarator) {
+	templates, header := splitTemplates(header)
+	if d, ok := parseDeclarator(header, false); ok {
+		d.templates = templates
+		return scopeFunction, d
+	}
+	if containerPattern.MatchString(header) {
+		return scopeContainer, declarator{}
+	}
+	return scopeBlock, declarator{}
+}
+
+// containerName returns the name of the namespace or class a header opens,
+// or an empty string if it is anonymous or another scope
+func containerName(header string) string {
+	if m := containerNamePattern.FindStringSubmatch(stripTemplates(header)); m != nil && m[1] != "final" {
+		return m[1]
+	}
+	return ""
+}
+
+// stripTemplates removes leading template parameter lists
+func stripTemplates(header string) string {
+	_, header = splitTemplates(header)
+	return header
+}
+
+// splitTemplates splits the leading template parameter lists off a header
+func splitTemplates(header string) (templates []string, rest string) {
+	for {
+		loc := templatePrefix.FindStringIndex(header)
+		if loc == nil {
+			return templates, header
+		}
+		depth, i := 1, loc[1]
+		for ; i < len(header) && depth > 0; i++ {
+			switch header[i] {
+			case '<':
+				depth++
+			case '>':
+				depth--
+			}
+		}
+		templates = append(templates, header[loc[1]:max(i-1, loc[1])])
+		header = strings.TrimSpace(header[i:])
+	}
+}
+
+// group is a top-level pair of parentheses in a header
+type group struct{ open, close int }
+
+// groups returns the top-level parenthesized groups of a header, and the
+// offset of a constructor's member initializer list or the header length
+func groups(header string) ([]group, int) {
+	var gs []group
+	depth, open := 0, 0
+	for i := 0; i < len(header); i++ {
+		switch c := header[i]; {
+		case c == '(':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case c == ')' && depth > 0:
+			if depth--; depth == 0 {
+				gs = append(gs, group{open, i})
+			}
+		case c == ':' && depth == 0 && len(gs) > 0:
+			if i+1 < len(header) && header[i+1] == ':' {
+				i++
+				continue
+			}
+			if i > 0 && header[i-1] == ':' {
+				continue
+			}
+			return gs, i
+		}
+	}
+	return gs, len(header)
+}
+
+// memberInitializer reports whether a brace after header initializes a
+// member in the initializer list of a constructor, as in a(x), b{y}
+func memberInitializer(header string) bool {
+	_, cut := groups(header)
+	if cut == len(header) {
+		return false
+	}
+	last := header[len(header)-1]
+	return isIdent(last) || last == '>'
+}
+
+// declarator is the name and parameter list of a function declaration,
+// with the text before the name and after the parameters
+type declarator struct {
+	name, prefix, params, tail string
+	templates                  []string // template parameter lists
+}
+
+// prototype returns the declarator of the function a header ending at a
+// semicolon declares, if it is a prototype rather than a variable or a
+// macro call: it has a return type, unless it names a constructor of the
+// enclosing class, and no literal arguments
+func prototype(header, enclosing string) (declarator, bool) {
+	templates, header := splitTemplates(header)
+	if strings.HasPrefix(header, "typedef ") || strings.HasPrefix(header, "using ") {
+		return declarator{}, false
+	}
+	d, ok := parseDeclarator(header, true)
+	if !ok || strings.Contains(d.params, `""`) || strings.Contains(d.params, "''") {
+		return declarator{}, false
+	}
+	if params := strings.TrimSpace(d.params); params != "" && params[0] >= '0' && params[0] <= '9' {
+		return declarator{}, false
+	}
+	if strings.TrimSpace(d.prefix) == "" && !strings.Contains(d.name, "::") && d.name != enclosing && d.name != "~"+enclosing {
+		return declarator{}, false
+	}
+	d.templates = templates
+	return d, true
+}
+
+// parseDeclarator finds the declarator of the function a header declares.
+// Definitions allow only qualifiers after the parameters; declarations
+// also pure, defaulted and deleted specifiers.
+func parseDeclarator(header string, declaration bool) (declarator, bool) {
+	gs, cut := groups(header)
+	for _, g := range gs {
+		if g.open >= cut {
+			break
+		}
+		before := header[:g.open]
+		m := namePattern.FindStringSubmatchIndex(before)
+		if m == nil {
+			continue
+		}
+		name := strings.Join(strings.Fields(before[m[2]:m[3]]), "")
+		if notNames[name] || strings.Contains(before[:m[2]], "=") {
+			continue
+		}
+		tail := header[g.close+1 : cut]
+		if declaration {
+			if cut < len(header) {
+				return declarator{}, false
+			}
+			tail = specifiers.ReplaceAllString(tail, "")
+		}
+		if validTail(tail) {
+			return declarator{name: name, prefix: before[:m[2]], params: header[g.open+1 : g.close], tail: tail}, true
+		}
+	}
+	return declarator{}, false
+}
+
+// validTail reports whether the text after a parameter list consists of
+// qualifiers, attributes, a trailing return type and macros only
+func validTail(tail string) bool {
+	for i := 0; i < len(tail); {
+		c := tail[i]
+		switch {
+		case c == ' ' || c == '&':
+			i++
+		case strings.HasPrefix(tail[i:], "->"):
+			return !strings.ContainsAny(tail[i:], "=;")
+		case strings.HasPrefix(tail[i:], "[["):
+			end := strings.Index(tail[i:], "]]")
+			if end < 0 {
+				return false
+			}
+			i += end + 2
+		case isIdent(c):
+			j := i
+			for j < len(tail) && isIdent(tail[j]) {
+				j++
+			}
+			word := tail[i:j]
+			if !qualifiers[word] && !isMacro(word) {
+				return false
+			}
+			i = j
+			for i < len(tail) && tail[i] == ' ' {
+				i++
+			}
+			if i < len(tail) && tail[i] == '(' {
+				end := closing(tail, i)
+				if end < 0 {
+					return false
+				}
+				i = end + 1
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// closing returns the offset of the parenthesis closing the one at open
+func closing(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isMacro reports whether a word looks like a macro, such as __THROW or
+// ZEXPORT
+func isMacro(word string) bool {
+	if strings.HasPrefix(word, "__") {
+		return true
+	}
+	for i := 0; i < len(word); i++ {
+		if c := word[i]; !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
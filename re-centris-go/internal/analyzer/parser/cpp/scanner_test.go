@@ -0,0 +1,159 @@
+package cpp
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+)
+
+func TestScan(t *testing.T) {
+	src := `#include <stdio.h>
+#define BLOCK { int x; }
+
+/* int commented(void) { */
+static const char *
+split_name(const char *s,
+           int n)
+{
+	const char *brace = "}{";
+	char c = '}';
+	return brace + n + c;
+}
+
+#ifdef WIDE
+int branch(int a, int b) {
+#else
+int branch(int a) {
+#endif
+	return a;
+}
+
+#if 0
+int disabled(void) {
+#endif
+
+namespace outer {
+class Point : public Base {
+public:
+	Point(int x, int y) : Base(), x_{x}, y_(y) {
+		auto f = [](int v) { return v; };
+	}
+	int x() const noexcept { return x_; }
+	virtual void draw() = 0;
+private:
+	int x_, y_;
+};
+}
+
+extern "C" {
+template <typename T>
+T max_of(T a, T b) { return a > b ? a : b; }
+}
+
+static const struct ops table = { .open = open_file };
+int counts[] = { 1'000, 2 };
+
+const char *raw() { return R"x(})x"; }
+bool operator==(const Point &a, const Point &b) { return a.x() == b.x(); }
+`
+	want := []struct {
+		name       string
+		start, end int
+	}{
+		{"split_name", 5, 12},
+		{"branch", 15, 20},
+		{"Point", 29, 31},
+		{"x", 32, 32},
+		{"max_of", 40, 41},
+		{"raw", 47, 47},
+		{"operator==", 48, 48},
+	}
+
+	functions, _, _ := scan(context.Background(), Preprocess([]byte(src), ConditionalsStrip))
+	if len(functions) != len(want) {
+		names := make([]string, len(functions))
+		for i, f := range functions {
+			names[i] = f.Name
+		}
+		t.Fatalf("scan() found %v, want %d functions", names, len(want))
+	}
+	for i, w := range want {
+		f := functions[i]
+		if f.Name != w.name || f.StartLine != w.start || f.EndLine != w.end {
+			t.Errorf("function %d = %s [%d, %d], want %s [%d, %d]", i, f.Name, f.StartLine, f.EndLine, w.name, w.start, w.end)
+		}
+	}
+	if !strings.HasPrefix(functions[0].Content, "static const char *\nsplit_name(") {
+		t.Errorf("content of split_name starts with %q", functions[0].Content[:20])
+	}
+}
+
+func TestScanPathological(t *testing.T) {
+	inputs := map[string]string{
+		"parentheses":  strings.Repeat("(", 100000) + "{",
+		"header":       strings.Repeat("int a ", 100000) + "() {}",
+		"braces":       strings.Repeat("{", 100000),
+		"unterminated": `void f() { const char *s = "` + strings.Repeat("x", 100000),
+		"comment":      "/*" + strings.Repeat("/ *", 100000),
+	}
+	for name, input := range inputs {
+		start := time.Now()
+		functions, _, _ := scan(context.Background(), []byte(input))
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: scan took %v", name, elapsed)
+		}
+		if len(functions) != 0 {
+			t.Errorf("%s: scan found %d functions", name, len(functions))
+		}
+	}
+
+	// A scan stops once its context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := scan(ctx, []byte(inputs["braces"])); err != context.Canceled {
+		t.Errorf("scan() with a cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestScanDeclarations(t *testing.T) {
+	src := `#include <string>
+namespace geo {
+class Shape {
+public:
+	Shape(int sides);
+	virtual ~Shape() = default;
+	virtual double area() const = 0;
+	int sides() const { return sides_; }
+	static Shape *parse(const std::string &spec,
+	                    int flags);
+private:
+	int sides_;
+	std::string name_("shape");
+};
+}
+
+extern "C" int shape_count(void);
+typedef int (*visitor)(struct shape *);
+DECLARE_REGISTRY(shapes);
+int limit(42);
+`
+	functions, declarations, _ := scan(context.Background(), Preprocess([]byte(src), ConditionalsStrip))
+	if len(functions) != 1 || functions[0].Name != "sides" {
+		t.Errorf("scan() found %d functions, want sides", len(functions))
+	}
+
+	want := []parser.Declaration{
+		{Name: "geo::Shape::Shape", Line: 5, Signature: "(int)"},
+		{Name: "geo::Shape::~Shape", Line: 6, Signature: "()"},
+		{Name: "geo::Shape::area", Line: 7, Signature: "() const"},
+		{Name: "geo::Shape::parse", Line: 9, Signature: "(const std::string&,int)"},
+		{Name: "shape_count", Line: 17, Signature: "()"},
+	}
+	if !reflect.DeepEqual(declarations, want) {
+		t.Errorf("scan() declarations = %v, want %v", declarations, want)
+	}
+}
@@ -0,0 +1,177 @@
+package cpp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// paramToken matches the tokens of a parameter declaration: possibly
+// qualified identifiers, ellipses and single punctuation characters
+var paramToken = regexp.MustCompile(`[A-Za-z_]\w*(?:\s*::\s*~?[A-Za-z_]\w*)*|\.\.\.|::|\S`)
+
+// typeWords are the words of built-in types and qualifiers, which are
+// never parameter names
+var typeWords = map[string]bool{
+	"void": true, "bool": true, "char": true, "short": true, "int": true, "long": true,
+	"float": true, "double": true, "signed": true, "unsigned": true, "auto": true,
+	"wchar_t": true, "char8_t": true, "char16_t": true, "char32_t": true,
+	"const": true, "volatile": true, "struct": true, "union": true, "enum": true,
+	"class": true, "typename": true, "register": true, "restrict": true,
+}
+
+// elaborating are the words that do not make a type on their own, so the
+// identifier after them is a type rather than a parameter name
+var elaborating = map[string]bool{
+	"const": true, "volatile": true, "struct": true, "union": true, "enum": true,
+	"class": true, "typename": true, "register": true, "restrict": true,
+}
+
+// signature returns the normalized parameter types of a declarator, such
+// as (const char*,int) const, preceded by the kinds of its template
+// parameters, such as <typename,int>. Parameter names, default arguments
+// and whitespace are dropped, so a prototype and its definition agree.
+func (d declarator) signature() string {
+	var b strings.Builder
+	for _, list := range d.templates {
+		kinds := splitParams(list)
+		for i, p := range kinds {
+			kinds[i] = templateKind(p)
+		}
+		b.WriteString("<" + strings.Join(kinds, ",") + ">")
+	}
+
+	params := splitParams(d.params)
+	for i, p := range params {
+		params[i] = normalizeParam(p)
+	}
+	if len(params) == 1 && params[0] == "void" {
+		params = nil
+	}
+	b.WriteString("(" + strings.Join(params, ",") + ")")
+
+	// cv- and ref-qualifiers overload member functions
+	tail := d.tail
+	if i := strings.Index(tail, "->"); i >= 0 {
+		tail = tail[:i]
+	}
+	for _, token := range paramToken.FindAllString(stripGroups(tail), -1) {
+		switch token {
+		case "const", "volatile":
+			b.WriteString(" " + token)
+		case "&":
+			b.WriteString("&")
+		}
+	}
+	return b.String()
+}
+
+// splitParams splits a parameter list at its top-level commas, dropping
+// default arguments
+func splitParams(list string) []string {
+	var params []string
+	depth, start, value := 0, 0, -1
+	flush := func(end int) {
+		if value >= 0 {
+			end = value
+		}
+		if p := strings.TrimSpace(list[start:end]); p != "" {
+			params = append(params, p)
+		}
+	}
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '(', '<', '[', '{':
+			depth++
+		case ')', '>', ']', '}':
+			depth--
+		case '=':
+			if depth == 0 && value < 0 {
+				value = i
+			}
+		case ',':
+			if depth == 0 {
+				flush(i)
+				start, value = i+1, -1
+			}
+		}
+	}
+	flush(len(list))
+	return params
+}
+
+// normalizeParam returns the type of a parameter declaration: its tokens
+// without the parameter name, with spaces only between words
+func normalizeParam(param string) string {
+	tokens := paramToken.FindAllString(param, -1)
+
+	// The name is the last identifier outside template arguments and array
+	// bounds that follows a type
+	name, typed, depth := -1, false, 0
+	for i, token := range tokens {
+		switch token {
+		case "<", "[":
+			depth++
+		case ">", "]":
+			depth--
+		default:
+			if depth != 0 || !isIdent(token[0]) || token[0] >= '0' && token[0] <= '9' {
+				continue
+			}
+			if typed && !typeWords[token] {
+				name = i
+			}
+			if !elaborating[token] {
+				typed = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, token := range tokens {
+		if i == name {
+			continue
+		}
+		if b.Len() > 0 && isIdent(token[0]) {
+			if last := b.String()[b.Len()-1]; isIdent(last) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(strings.Join(strings.Fields(token), ""))
+	}
+	return b.String()
+}
+
+// templateKind returns the kind of a template parameter: typename for type
+// parameters however introduced, template for template template
+// parameters and the type of non-type parameters
+func templateKind(param string) string {
+	switch fields := strings.Fields(param); {
+	case len(fields) == 0:
+		return ""
+	case strings.HasPrefix(fields[0], "template"):
+		return "template"
+	case strings.HasPrefix(fields[0], "typename") || strings.HasPrefix(fields[0], "class"):
+		if strings.Contains(param, "...") {
+			return "typename..."
+		}
+		return "typename"
+	}
+	return normalizeParam(param)
+}
+
+// stripGroups removes parenthesized groups, such as noexcept(...)
+func stripGroups(s string) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package cpp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	src := `namespace geo {
+struct Vec {
+	double dot(const Vec &other) const { return x * other.x + y * other.y; }
+	Vec &scale(double by) & { x *= by; return *this; }
+	double x, y;
+};
+
+int clamp(int value, int lo = 0, int hi = 255) { return value < lo ? lo : value > hi ? hi : value; }
+double clamp(double value, double lo, double hi) { return value < lo ? lo : value > hi ? hi : value; }
+}
+
+template <typename T, int N>
+T sum(const T (&values)[N]) { T total{}; for (int i = 0; i < N; ++i) total += values[i]; return total; }
+
+int geo::Vec::norm(void) const noexcept(true) { return 0; }
+static unsigned long hash(struct entry *e, std::map<int, std::string> seen, void (*visit)(int), ...) { return 0; }
+`
+	want := []string{
+		"geo::Vec::dot(const Vec&) const",
+		"geo::Vec::scale(double)&",
+		"geo::clamp(int,int,int)",
+		"geo::clamp(double,double,double)",
+		"sum<typename,int>(const T(&)[N])",
+		"geo::Vec::norm() const",
+		"hash(struct entry*,std::map<int,std::string>,void(*)(int),...)",
+	}
+
+	functions, _, _ := scan(context.Background(), []byte(src))
+	if len(functions) != len(want) {
+		t.Fatalf("scan() found %d functions, want %d", len(functions), len(want))
+	}
+	for i, f := range functions {
+		if got := f.Identity(); got != want[i] {
+			t.Errorf("function %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"context"
 	"io"
+	"strings"
 )
 
 // Function represents a parsed function
@@ -11,6 +13,49 @@ type Function struct {
 	EndLine   int
 	Content   string
 	Hash      string
+	// Declaration locates the prototype of the function as file:line, if
+	// it was linked to one
+	Declaration string `json:",omitempty"`
+	// Scope is the qualified namespace and class of the function, such as
+	// geo::Shape, and Signature its normalized parameter types, such as
+	// (int,const char*); they tell overloads apart, see Identity
+	Scope     string `json:",omitempty"`
+	Signature string `json:",omitempty"`
+	// Complexity is the cyclomatic complexity of the body, Tokens its
+	// number of tokens and Depth the deepest nesting of blocks in it; they
+	// are zero if the parser does not measure functions
+	Complexity int `json:",omitempty"`
+	Tokens     int `json:",omitempty"`
+	Depth      int `json:",omitempty"`
+}
+
+// Identity returns the qualified name of the function with its signature,
+// such as geo::Shape::area() const, or its name if the parser does not
+// record signatures
+func (f Function) Identity() string {
+	return Identity(f.Name, f.Scope, f.Signature)
+}
+
+// Identity returns the qualified name of a function with its signature
+func Identity(name, scope, signature string) string {
+	if signature == "" {
+		return name
+	}
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		name = name[i+2:]
+	}
+	if scope != "" {
+		name = scope + "::" + name
+	}
+	return name + signature
+}
+
+// Declaration represents a function declared without a body, such as a
+// prototype in a header
+type Declaration struct {
+	Name      string
+	Line      int
+	Signature string
 }
 
 // Parser defines the interface for language-specific parsers
@@ -25,6 +70,22 @@ type Parser interface {
 	GetExtensions() []string
 }
 
+// DeclarationParser is implemented by parsers that tell the prototypes of
+// functions from their definitions
+type DeclarationParser interface {
+	// ParseWithDeclarations returns the functions defined in the source
+	// code and the functions only declared
+	ParseWithDeclarations(reader io.Reader) ([]Function, []Declaration, error)
+}
+
+// ContextParser is implemented by parsers that stop parsing once a context
+// is done, such as when the analysis of a file times out
+type ContextParser interface {
+	// ParseContext returns the functions defined in the source code and
+	// the functions only declared, or the error of ctx once it is done
+	ParseContext(ctx context.Context, reader io.Reader) ([]Function, []Declaration, error)
+}
+
 // Registry maintains a map of language parsers
 type Registry struct {
 	parsers map[string]Parser
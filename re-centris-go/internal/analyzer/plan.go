@@ -0,0 +1,172 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// LanguageStats summarizes the files of one language
+type LanguageStats struct {
+	Language    string `json:"language"`
+	Files       int    `json:"files"`
+	Lines       int64  `json:"lines"`
+	Bytes       int64  `json:"bytes"`
+	KnownFiles  int    `json:"known_files"`
+	Comparisons int64  `json:"comparisons"`
+}
+
+// Plan previews the scope of a scan
+type Plan struct {
+	Languages   []LanguageStats `json:"languages"`
+	TotalFiles  int             `json:"total_files"`
+	TotalLines  int64           `json:"total_lines"`
+	TotalBytes  int64           `json:"total_bytes"`
+	Comparisons int64           `json:"comparisons"`
+	Estimated   time.Duration   `json:"-"`
+}
+
+// CountFiles counts the supported files of a directory per language
+func (a *Analyzer) CountFiles(ctx context.Context, dir string) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := a.WalkDirectory(ctx, dir, func(path string, info os.FileInfo) error {
+		counts[a.LanguageOf(path)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// PlanDirectory computes the files, lines and bytes per language of a
+// directory without hashing it. known holds the number of known files per
+// language, from which the expected number of file comparisons follows.
+func (a *Analyzer) PlanDirectory(ctx context.Context, dir string, known map[string]int) (*Plan, error) {
+	stats := make(map[string]*LanguageStats)
+
+	err := a.WalkDirectory(ctx, dir, func(path string, info os.FileInfo) error {
+		language := a.LanguageOf(path)
+		s, ok := stats[language]
+		if !ok {
+			s = &LanguageStats{Language: language}
+			stats[language] = s
+		}
+
+		lines, err := countLines(path)
+		if err != nil {
+			return err
+		}
+
+		s.Files++
+		s.Lines += lines
+		s.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	for _, s := range stats {
+		// Every target file is compared with every known file of its language
+		s.KnownFiles = known[s.Language]
+		s.Comparisons = int64(s.Files) * int64(s.KnownFiles)
+
+		plan.Languages = append(plan.Languages, *s)
+		plan.TotalFiles += s.Files
+		plan.TotalLines += s.Lines
+		plan.TotalBytes += s.Bytes
+		plan.Comparisons += s.Comparisons
+	}
+
+	// Sort languages by file count (descending)
+	sort.Slice(plan.Languages, func(i, j int) bool {
+		if plan.Languages[i].Files != plan.Languages[j].Files {
+			return plan.Languages[i].Files > plan.Languages[j].Files
+		}
+		return plan.Languages[i].Language < plan.Languages[j].Language
+	})
+
+	plan.Estimated = a.estimate(plan)
+	return plan, nil
+}
+
+// estimate predicts the duration of a scan by timing hashing and distance
+// computation on this machine and scaling them to the plan
+func (a *Analyzer) estimate(plan *Plan) time.Duration {
+	if plan.TotalFiles == 0 {
+		return 0
+	}
+
+	// Time hashing of a synthetic sample
+	sample := make([]byte, 64<<10)
+	for i := range sample {
+		sample[i] = byte(i*7 + i/13)
+	}
+	start := time.Now()
+	hash, err := tlsh.New(sample)
+	if err != nil {
+		return 0
+	}
+	perByte := float64(time.Since(start)) / float64(len(sample))
+
+	// Time distance computation
+	const rounds = 10000
+	start = time.Now()
+	for i := 0; i < rounds; i++ {
+		hash.Distance(hash)
+	}
+	perComparison := float64(time.Since(start)) / rounds
+
+	workers := a.opts.MaxWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	total := perByte*float64(plan.TotalBytes) + perComparison*float64(plan.Comparisons)
+	return time.Duration(total / float64(workers))
+}
+
+// countLines counts the newline-terminated lines of a file, plus a final
+// unterminated line
+func countLines(path string) (int64, error) {
+	file, err := fsutil.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var (
+		lines int64
+		last  byte = '\n'
+		buf        = make([]byte, 32<<10)
+		r          = bufio.NewReader(file)
+	)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file: %v", err)
+		}
+	}
+
+	if last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+)
+
+// Kinds of files skipped by the analyzer
+const (
+	KindTooSmall  = "too_small"
+	KindBinary    = "binary"
+	KindMinified  = "minified"
+	KindGenerated = "generated"
+	KindOversize  = "oversize"
+	KindTimeout   = "timeout"
+)
+
+const (
+	// sniffSize is the number of leading bytes searched for NUL bytes and
+	// generated code markers
+	sniffSize = 8000
+
+	// minifiedLineLength is the average line length above which a file
+	// counts as minified
+	minifiedLineLength = 300
+
+	// minifiedMinSize is the size below which files are never minified
+	minifiedMinSize = 1024
+)
+
+// generatedMarkers are comments tools put at the top of generated files
+var generatedMarkers = [][]byte{
+	[]byte("DO NOT EDIT"),
+	[]byte("@generated"),
+	[]byte("automatically generated"),
+	[]byte("auto-generated"),
+	[]byte("autogenerated"),
+}
+
+// SkippedError is returned for files the analyzer skips by their content
+type SkippedError struct {
+	Kind string
+}
+
+func (e *SkippedError) Error() string {
+	return "skipped " + e.Kind + " file"
+}
+
+// IsSkipped reports whether an analysis error means the file was skipped,
+// because it is too small to hash, larger than MaxFileSize, stopped after
+// FileTimeout or classified as binary, minified or generated, rather than
+// failed
+func IsSkipped(err error) bool {
+	var skipped *SkippedError
+	return err == tlsh.ErrDataTooSmall || errors.As(err, &skipped)
+}
+
+// Classify returns the kind of content that is not worth hashing, or an
+// empty string for regular source code. Binary files contain a NUL byte
+// near the start, minified files have very long lines on average and
+// generated files carry a generator marker in their first lines.
+func Classify(content []byte) string {
+	head := content
+	if len(head) > sniffSize {
+		head = head[:sniffSize]
+	}
+
+	if bytes.IndexByte(head, 0) >= 0 {
+		return KindBinary
+	}
+
+	if len(content) >= minifiedMinSize {
+		lines := bytes.Count(content, []byte("\n")) + 1
+		if len(content)/lines > minifiedLineLength {
+			return KindMinified
+		}
+	}
+
+	// Only the first lines carry generator markers, later mentions are
+	// usually documentation
+	for i, n := 0, 0; i < len(head) && n < 5; n++ {
+		end := bytes.IndexByte(head[i:], '\n')
+		if end < 0 {
+			end = len(head) - i
+		}
+		line := head[i : i+end]
+		for _, marker := range generatedMarkers {
+			if bytes.Contains(line, marker) {
+				return KindGenerated
+			}
+		}
+		i += end + 1
+	}
+
+	return ""
+}
+
+// skipStats counts the files skipped by kind
+type skipStats struct {
+	counts map[string]int64
+	mutex  sync.Mutex
+}
+
+// add counts a skipped file if err is a skip
+func (s *skipStats) add(err error) {
+	kind := KindTooSmall
+	var skipped *SkippedError
+	if errors.As(err, &skipped) {
+		kind = skipped.Kind
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[string]int64)
+	}
+	s.counts[kind]++
+}
+
+// SkipStats returns the number of files skipped by kind since the analyzer
+// was created
+func (a *Analyzer) SkipStats() map[string]int64 {
+	a.skipped.mutex.Lock()
+	defer a.skipped.mutex.Unlock()
+
+	stats := make(map[string]int64, len(a.skipped.counts))
+	for kind, n := range a.skipped.counts {
+		stats[kind] = n
+	}
+	return stats
+}
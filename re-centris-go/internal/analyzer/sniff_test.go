@@ -0,0 +1,27 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	source := strings.Repeat("int add(int a, int b) { return a + b; }\n", 50)
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"source", source, ""},
+		{"binary", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR" + source, KindBinary},
+		{"minified", strings.Repeat("var a=function(b){return b*2};", 200), KindMinified},
+		{"generated", "// Code generated by protoc-gen-go. DO NOT EDIT.\n" + source, KindGenerated},
+		{"marker in body", source + "// this is not auto-generated\n", ""},
+	}
+	for _, tt := range tests {
+		if got := Classify([]byte(tt.content)); got != tt.want {
+			t.Errorf("Classify(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
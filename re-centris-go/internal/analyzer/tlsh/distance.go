@@ -0,0 +1,50 @@
+package tlsh
+
+import "encoding/binary"
+
+// Masks for comparing the buckets of two hashes eight at a time as uint64
+// words
+const (
+	highBits  = 0x8080808080808080 // top bit of every byte
+	evenBytes = 0x00ff00ff00ff00ff // every other byte, widened to 16 bits
+	laneSum   = 0x0001000100010001 // adds the four 16-bit lanes of a word
+)
+
+// DistanceMany returns the distances between target and each candidate,
+// as Distance does. Nil hashes yield -1. It is meant for comparing one
+// hash against many, such as all known files or functions of a language.
+func DistanceMany(target *TLSH, candidates []*TLSH) []int {
+	distances := make([]int, len(candidates))
+	for i, candidate := range candidates {
+		if target == nil || candidate == nil {
+			distances[i] = -1
+			continue
+		}
+		distances[i] = distance(target, candidate)
+	}
+	return distances
+}
+
+// distance computes Distance for two non-nil hashes. Differences are taken
+// on bytes, as the original implementation does.
+func distance(t, other *TLSH) int {
+	header := int(t.LValue-other.LValue) + int(t.Q1Ratio-other.Q1Ratio) + int(t.Q2Ratio-other.Q2Ratio)
+	return header*12 + bucketDistance(&t.Buckets, &other.Buckets)
+}
+
+// bucketDistance returns the sum of the byte differences a[i]-b[i] of two
+// bucket arrays. Eight buckets are subtracted at once without borrows
+// crossing byte boundaries, then the bytes are summed in 16-bit lanes,
+// which cannot overflow for 256 buckets.
+func bucketDistance(a, b *[bucketCount]byte) int {
+	var sum uint64
+	for i := 0; i < bucketCount; i += 16 {
+		x0, y0 := binary.LittleEndian.Uint64(a[i:]), binary.LittleEndian.Uint64(b[i:])
+		x1, y1 := binary.LittleEndian.Uint64(a[i+8:]), binary.LittleEndian.Uint64(b[i+8:])
+		d0 := ((x0 | highBits) - (y0 &^ highBits)) ^ ((x0 ^ ^y0) & highBits)
+		d1 := ((x1 | highBits) - (y1 &^ highBits)) ^ ((x1 ^ ^y1) & highBits)
+		sum += d0&evenBytes + (d0>>8)&evenBytes
+		sum += d1&evenBytes + (d1>>8)&evenBytes
+	}
+	return int((sum * laneSum) >> 48)
+}
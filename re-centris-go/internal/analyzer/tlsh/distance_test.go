@@ -0,0 +1,94 @@
+package tlsh
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// referenceDistance is the straightforward per-bucket implementation
+func referenceDistance(t, other *TLSH) int {
+	lDiff := math.Abs(float64(t.LValue - other.LValue))
+	bucketDiff := 0
+	for i := 0; i < bucketCount; i++ {
+		bucketDiff += int(math.Abs(float64(t.Buckets[i] - other.Buckets[i])))
+	}
+	q1Diff := math.Abs(float64(t.Q1Ratio - other.Q1Ratio))
+	q2Diff := math.Abs(float64(t.Q2Ratio - other.Q2Ratio))
+	return int(lDiff*12 + float64(bucketDiff) + (q1Diff+q2Diff)*12)
+}
+
+// randomHashes returns n hashes with random header and bucket values
+func randomHashes(n int) []*TLSH {
+	rng := rand.New(rand.NewSource(1))
+	hashes := make([]*TLSH, n)
+	for i := range hashes {
+		h := &TLSH{
+			LValue:  byte(rng.Intn(32)),
+			Q1Ratio: byte(rng.Intn(16)),
+			Q2Ratio: byte(rng.Intn(16)),
+		}
+		for j := range h.Buckets {
+			h.Buckets[j] = byte(rng.Intn(4))
+		}
+		hashes[i] = h
+	}
+	return hashes
+}
+
+func TestDistanceMany(t *testing.T) {
+	hashes := randomHashes(50)
+	hashes = append(hashes, nil)
+
+	for _, target := range hashes[:10] {
+		distances := DistanceMany(target, hashes)
+		for i, candidate := range hashes {
+			want := -1
+			if candidate != nil {
+				want = referenceDistance(target, candidate)
+			}
+			if distances[i] != want {
+				t.Fatalf("DistanceMany()[%d] = %d, want %d", i, distances[i], want)
+			}
+			if got := target.Distance(candidate); got != want {
+				t.Fatalf("Distance() = %d, want %d", got, want)
+			}
+			if candidate != nil {
+				if got := target.DistanceRaw(candidate.AppendRaw(nil)); got != want {
+					t.Fatalf("DistanceRaw() = %d, want %d", got, want)
+				}
+			}
+		}
+	}
+
+	if got := DistanceMany(nil, hashes[:1]); got[0] != -1 {
+		t.Errorf("DistanceMany(nil) = %v, want [-1]", got)
+	}
+
+	raw := hashes[1].AppendRaw(nil)
+	if allocs := testing.AllocsPerRun(10, func() { hashes[0].DistanceRaw(raw) }); allocs != 0 {
+		t.Errorf("DistanceRaw() allocates %v times", allocs)
+	}
+	if got := hashes[0].DistanceRaw(raw[1:]); got != -1 {
+		t.Errorf("DistanceRaw() of a truncated hash = %d, want -1", got)
+	}
+}
+
+func BenchmarkDistance(b *testing.B) {
+	hashes := randomHashes(2)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = hashes[0].Distance(hashes[1])
+	}
+}
+
+func BenchmarkDistanceMany(b *testing.B) {
+	hashes := randomHashes(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = DistanceMany(hashes[0], hashes)
+	}
+}
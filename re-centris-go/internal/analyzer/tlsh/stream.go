@@ -0,0 +1,54 @@
+package tlsh
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// Hasher computes the TLSH hash of data written to it in chunks, equal to
+// New of the concatenated data, without holding the data in memory
+type Hasher struct {
+	state  state
+	recent [windowSize + 1]byte // the last bytes written, by position
+	length int
+	sum    hash.Hash
+}
+
+// NewHasher creates an empty Hasher
+func NewHasher() *Hasher {
+	return &Hasher{sum: sha256.New()}
+}
+
+// Write adds data to the hash. It never fails.
+func (h *Hasher) Write(p []byte) (int, error) {
+	h.sum.Write(p)
+	const n = len(h.recent)
+	for _, b := range p {
+		// Like New, a window is counted once the byte after it arrives
+		if i := h.length - windowSize; i >= 0 {
+			triplet := (int(h.recent[i%n]) << 16) | (int(h.recent[(i+2)%n]) << 8) | int(h.recent[(i+4)%n])
+			h.state.buckets[triplet%bucketCount]++
+		}
+		h.recent[h.length%n] = b
+		h.length++
+	}
+	return len(p), nil
+}
+
+// Sum256 returns the SHA-256 of the data written so far
+func (h *Hasher) Sum256() [sha256.Size]byte {
+	var sum [sha256.Size]byte
+	h.sum.Sum(sum[:0])
+	return sum
+}
+
+// Hash returns the TLSH hash of the data written so far
+func (h *Hasher) Hash() (*TLSH, error) {
+	if h.length < minDataLength {
+		return nil, ErrDataTooSmall
+	}
+	tlsh := &TLSH{DataLength: h.length}
+	s := h.state
+	finish(tlsh, &s, h.Sum256()[0])
+	return tlsh, nil
+}
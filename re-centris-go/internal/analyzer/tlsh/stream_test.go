@@ -0,0 +1,32 @@
+package tlsh
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHasher(t *testing.T) {
+	data := bytes.Repeat([]byte("int add(int a, int b) { return a + b; }\n"), 100)
+	want, err := New(data)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, chunk := range []int{1, 7, 64, len(data)} {
+		h := NewHasher()
+		for i := 0; i < len(data); i += chunk {
+			h.Write(data[i:min(i+chunk, len(data))])
+		}
+		got, err := h.Hash()
+		if err != nil {
+			t.Fatalf("Hash failed: %v", err)
+		}
+		if got.String() != want.String() || got.DataLength != want.DataLength {
+			t.Errorf("chunks of %d: hash %s, want %s", chunk, got, want)
+		}
+	}
+
+	if _, err := NewHasher().Hash(); err != ErrDataTooSmall {
+		t.Errorf("empty Hash() error = %v, want ErrDataTooSmall", err)
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"encoding/hex"
 	"math"
 	"sort"
+	"sync"
 )
 
 const (
 	bucketCount   = 256
 	windowSize    = 5
 	minDataLength = 50
+
+	// RawSize is the length of a hash in the form of AppendRaw
+	RawSize = bucketCount/2 + 4
 )
 
 // TLSH represents a Trend Micro Locality Sensitive Hash
@@ -24,6 +28,17 @@ type TLSH struct {
 	DataLength int
 }
 
+// state holds the scratch counters of a hash computation. States are
+// pooled since a corpus build hashes every file and function.
+type state struct {
+	buckets [bucketCount]int
+	sorted  [bucketCount]int
+}
+
+var states = sync.Pool{
+	New: func() any { return new(state) },
+}
+
 // New creates a new TLSH hash from a byte slice
 func New(data []byte) (*TLSH, error) {
 	if len(data) < minDataLength {
@@ -34,8 +49,12 @@ func New(data []byte) (*TLSH, error) {
 		DataLength: len(data),
 	}
 
+	s := states.Get().(*state)
+	defer states.Put(s)
+	s.buckets = [bucketCount]int{}
+
 	// Calculate sliding window
-	buckets := make([]int, bucketCount)
+	buckets := s.buckets[:]
 	for i := 0; i < len(data)-windowSize; i++ {
 		window := data[i : i+windowSize]
 		triplet := (int(window[0]) << 16) | (int(window[2]) << 8) | int(window[4])
@@ -43,8 +62,18 @@ func New(data []byte) (*TLSH, error) {
 		buckets[bucket]++
 	}
 
+	sum := sha256.Sum256(data)
+	finish(tlsh, s, sum[0])
+	return tlsh, nil
+}
+
+// finish computes the quartile ratios, bucket values, checksum and L-value
+// of a hash of tlsh.DataLength bytes from the bucket counts of s
+func finish(tlsh *TLSH, s *state, checksum byte) {
+	buckets := s.buckets[:]
+
 	// Calculate quartiles
-	sortedBuckets := make([]int, len(buckets))
+	sortedBuckets := s.sorted[:]
 	copy(sortedBuckets, buckets)
 	sort.Ints(sortedBuckets)
 
@@ -73,15 +102,10 @@ func New(data []byte) (*TLSH, error) {
 		}
 	}
 
-	// Calculate checksum
-	h := sha256.New()
-	h.Write(data)
-	tlsh.Checksum = h.Sum(nil)[0]
+	tlsh.Checksum = checksum
 
 	// Calculate L-Value (log base 2 of the file size)
-	tlsh.LValue = byte(math.Log2(float64(len(data))))
-
-	return tlsh, nil
+	tlsh.LValue = byte(math.Log2(float64(tlsh.DataLength)))
 }
 
 // Distance calculates the distance between two TLSH hashes
@@ -89,22 +113,7 @@ func (t *TLSH) Distance(other *TLSH) int {
 	if t == nil || other == nil {
 		return -1
 	}
-
-	// Calculate L-Value difference
-	lDiff := math.Abs(float64(t.LValue - other.LValue))
-
-	// Calculate bucket difference
-	bucketDiff := 0
-	for i := 0; i < bucketCount; i++ {
-		bucketDiff += int(math.Abs(float64(t.Buckets[i] - other.Buckets[i])))
-	}
-
-	// Calculate quartile ratio difference
-	q1Diff := math.Abs(float64(t.Q1Ratio - other.Q1Ratio))
-	q2Diff := math.Abs(float64(t.Q2Ratio - other.Q2Ratio))
-
-	// Weighted sum of differences
-	return int(lDiff*12 + float64(bucketDiff) + (q1Diff+q2Diff)*12)
+	return distance(t, other)
 }
 
 // String returns the hex representation of the TLSH hash
@@ -113,16 +122,63 @@ func (t *TLSH) String() string {
 		return ""
 	}
 
-	result := make([]byte, bucketCount/2+4)
-	result[0] = t.Checksum
-	result[1] = t.LValue
-	result[2] = t.Q1Ratio
-	result[3] = t.Q2Ratio
+	return hex.EncodeToString(t.AppendRaw(nil))
+}
+
+// AppendRaw appends the RawSize bytes of the hash that String encodes in
+// hex, for storing hashes in fixed-width records
+func (t *TLSH) AppendRaw(b []byte) []byte {
+	b = append(b, t.Checksum, t.LValue, t.Q1Ratio, t.Q2Ratio)
 
 	// Pack buckets (2 buckets per byte)
 	for i := 0; i < bucketCount/2; i++ {
-		result[i+4] = (t.Buckets[i*2] << 4) | t.Buckets[i*2+1]
+		b = append(b, (t.Buckets[i*2]<<4)|t.Buckets[i*2+1])
+	}
+	return b
+}
+
+// DistanceRaw calculates the distance to a hash in the form of AppendRaw
+// without allocating, so that hashes can be compared where they are stored.
+// Malformed hashes yield -1.
+func (t *TLSH) DistanceRaw(raw []byte) int {
+	if t == nil || len(raw) != RawSize {
+		return -1
+	}
+	var other TLSH
+	unpack(&other, raw)
+	return distance(t, &other)
+}
+
+// Parse parses the hex representation produced by String back into a TLSH hash
+func Parse(s string) (*TLSH, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidHash
 	}
 
-	return hex.EncodeToString(result)
-} 
\ No newline at end of file
+	return ParseRaw(raw)
+}
+
+// ParseRaw parses the form of AppendRaw back into a TLSH hash
+func ParseRaw(raw []byte) (*TLSH, error) {
+	if len(raw) != RawSize {
+		return nil, ErrInvalidHash
+	}
+	t := &TLSH{}
+	unpack(t, raw)
+	return t, nil
+}
+
+// unpack sets the header and buckets of t from the raw form of a hash
+func unpack(t *TLSH, raw []byte) {
+	t.Checksum = raw[0]
+	t.LValue = raw[1]
+	t.Q1Ratio = raw[2]
+	t.Q2Ratio = raw[3]
+
+	// Unpack buckets (2 buckets per byte)
+	for i := 0; i < bucketCount/2; i++ {
+		t.Buckets[i*2] = raw[i+4] >> 4
+		t.Buckets[i*2+1] = raw[i+4] & 0x0f
+	}
+}
@@ -104,13 +104,38 @@ func TestTLSHEdgeCases(t *testing.T) {
 	}
 }
 
+func TestParse(t *testing.T) {
+	hash, err := New([]byte("This is a test string that is long enough to generate a TLSH hash"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	parsed, err := Parse(hash.String())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if parsed.String() != hash.String() {
+		t.Errorf("Parse() round trip = %v, want %v", parsed.String(), hash.String())
+	}
+	if dist := hash.Distance(parsed); dist != 0 {
+		t.Errorf("Distance to parsed hash = %v, want 0", dist)
+	}
+
+	for _, invalid := range []string{"", "zz", "abcd"} {
+		if _, err := Parse(invalid); err != ErrInvalidHash {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidHash", invalid, err)
+		}
+	}
+}
+
 func BenchmarkTLSH(b *testing.B) {
 	data := []byte(`This is a test string that is long enough to generate a TLSH hash.
 		We need to make it even longer to ensure we have enough data for meaningful benchmarks.
 		Adding more text to make it more realistic and provide better performance measurements.`)
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, _ = New(data)
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,242 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// Symlink policies of WalkDirectory
+const (
+	// SymlinkFollow follows symbolic links resolving below the walked directory
+	SymlinkFollow = "follow"
+	// SymlinkSkip ignores symbolic links
+	SymlinkSkip = "skip"
+)
+
+// walker walks a directory tree, visiting every directory and file once
+type walker struct {
+	a     *Analyzer
+	ctx   context.Context
+	top   string // the walked directory as given
+	root  string // the walked directory with symbolic links resolved
+	dirs  map[fileID]bool
+	files map[fileID]bool
+	// include and exclude hold the Include and Exclude options
+	include []ignoreRule
+	exclude []ignoreRule
+	failed  *atomic.Int64
+	fn      func(path string, info os.FileInfo) error
+}
+
+// WalkDirectory calls fn for every supported file in a directory and its
+// subdirectories, honoring the Skip, Symlinks, Include, Exclude and
+// GitIgnore options. Symbolic links are only followed if they resolve below
+// dir, and every directory is entered once so link cycles terminate. A file
+// reachable through several hard or symbolic links is reported once, under
+// the first path found.
+func (a *Analyzer) WalkDirectory(ctx context.Context, dir string, fn func(path string, info os.FileInfo) error) error {
+	return a.walkDirectory(ctx, dir, &atomic.Int64{}, fn)
+}
+
+// walkDirectory implements WalkDirectory. Unreadable paths are handled by
+// the error policy and counted in failed.
+func (a *Analyzer) walkDirectory(ctx context.Context, dir string, failed *atomic.Int64, fn func(path string, info os.FileInfo) error) error {
+	switch a.opts.Symlinks {
+	case "", SymlinkFollow, SymlinkSkip:
+	default:
+		return fmt.Errorf("unsupported symlink policy: %s", a.opts.Symlinks)
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		root, err = filepath.Abs(root)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to walk directory: %v", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("failed to walk directory: %v", err)
+	}
+
+	include, err := parseIgnoreRules(a.opts.Include, "")
+	if err != nil {
+		return fmt.Errorf("invalid include pattern: %v", err)
+	}
+	exclude, err := parseIgnoreRules(a.opts.Exclude, "")
+	if err != nil {
+		return fmt.Errorf("invalid exclude pattern: %v", err)
+	}
+
+	w := &walker{
+		a:       a,
+		ctx:     ctx,
+		top:     dir,
+		root:    root,
+		dirs:    make(map[fileID]bool),
+		files:   make(map[fileID]bool),
+		include: include,
+		exclude: exclude,
+		failed:  failed,
+		fn:      fn,
+	}
+
+	if !info.IsDir() {
+		err = w.visitFile(dir, info)
+	} else {
+		w.dirs[fileIDOf(root, info)] = true
+		err = w.walkDir(dir, "", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to walk directory: %v", err)
+	}
+
+	return nil
+}
+
+// walkDir visits the entries of a directory in lexical order. rel is the
+// slash-separated path of dir below the walked directory and gitignore
+// holds the rules of the .gitignore files of its parents.
+func (w *walker) walkDir(dir, rel string, gitignore []ignoreRule) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if dir == w.top {
+			return err
+		}
+		return w.a.fileError(dir, err, w.failed)
+	}
+
+	if w.a.opts.GitIgnore {
+		if rules := readGitIgnore(dir, rel); len(rules) > 0 {
+			gitignore = append(gitignore[:len(gitignore):len(gitignore)], rules...)
+		}
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		entryRel := entry.Name()
+		if rel != "" {
+			entryRel = rel + "/" + entryRel
+		}
+
+		info, err := entry.Info()
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			info, err = w.resolve(path)
+		}
+		if err != nil {
+			if err := w.a.fileError(path, err, w.failed); err != nil {
+				return err
+			}
+			continue
+		}
+		if info == nil {
+			continue
+		}
+
+		if ignored(w.exclude, entryRel, info.IsDir()) || ignored(gitignore, entryRel, info.IsDir()) {
+			continue
+		}
+
+		if !info.IsDir() {
+			if !included(w.include, entryRel) {
+				continue
+			}
+			if err := w.visitFile(path, info); err != nil {
+				return err
+			}
+			continue
+		}
+
+		id := fileIDOf(path, info)
+		if w.dirs[id] {
+			logger.Debug("Skipping directory visited before",
+				zap.String("path", path))
+			continue
+		}
+		w.dirs[id] = true
+
+		if err := w.walkDir(path, entryRel, gitignore); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the info of the target of a symbolic link, or nil if the
+// link is skipped by the symlink policy or points outside the root
+func (w *walker) resolve(path string) (os.FileInfo, error) {
+	if w.a.opts.Symlinks == SymlinkSkip {
+		return nil, nil
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		target, err = filepath.Abs(target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve symlink: %v", err)
+	}
+	if !within(w.root, target) {
+		logger.Warn("Skipping symlink pointing outside the walked directory",
+			zap.String("path", path),
+			zap.String("target", target))
+		return nil, nil
+	}
+
+	return os.Stat(target)
+}
+
+// visitFile calls fn for a supported regular file seen for the first time
+func (w *walker) visitFile(path string, info os.FileInfo) error {
+	if !info.Mode().IsRegular() || w.a.LanguageOf(path) == "" {
+		return nil
+	}
+	if w.a.opts.Skip != nil && w.a.opts.Skip(path) {
+		return nil
+	}
+
+	id := fileIDOf(path, info)
+	if w.files[id] {
+		logger.Debug("Skipping file linked from another path",
+			zap.String("path", path))
+		return nil
+	}
+	w.files[id] = true
+
+	// Check if context is cancelled
+	select {
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	default:
+	}
+
+	return w.fn(path, info)
+}
+
+// fileIDOfPath identifies a file by its resolved path
+func fileIDOfPath(path string) fileID {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return fileID{path: path}
+}
+
+// within reports whether path is root or below it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,68 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkDirectoryLinks(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "src"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{filepath.Join(root, "src", "a.c"), filepath.Join(outside, "secret.c")} {
+		if err := os.WriteFile(path, []byte("int main() {}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		filepath.Join(root, "src", "loop"):  root,                              // cycle
+		filepath.Join(root, "escape"):       outside,                           // outside the root
+		filepath.Join(root, "src", "sym.c"): filepath.Join(root, "src", "a.c"), // alias
+		filepath.Join(root, "lib"):          filepath.Join(root, "src"),        // directory alias
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	if err := os.Link(filepath.Join(root, "src", "a.c"), filepath.Join(root, "src", "b.c")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "c.c"), []byte("int c() {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(policy string) []string {
+		a := New(AnalyzerOptions{Languages: map[string][]string{"cpp": {".c"}}, Symlinks: policy})
+		var paths []string
+		err := a.WalkDirectory(context.Background(), root, func(path string, info os.FileInfo) error {
+			rel, _ := filepath.Rel(root, path)
+			paths = append(paths, rel)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkDirectory(%s) error = %v", policy, err)
+		}
+		return paths
+	}
+
+	// lib/ aliases src/, so its files are reported under the first path found
+	want := []string{"lib/a.c", "lib/c.c"}
+	if got := walk(SymlinkFollow); !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkDirectory(follow) = %v, want %v", got, want)
+	}
+
+	want = []string{"src/a.c", "src/c.c"}
+	if got := walk(SymlinkSkip); !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkDirectory(skip) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,218 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/bodies"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+const (
+	// FilesParquet is the name of the file metadata table
+	FilesParquet = "files.parquet"
+	// FunctionsParquet is the name of the function metadata table
+	FunctionsParquet = "functions.parquet"
+	// FilesJSON is the name of the file metadata table in JSON format
+	FilesJSON = "files.json"
+	// FunctionsJSON is the name of the function metadata table in JSON format
+	FunctionsJSON = "functions.json"
+)
+
+// FileRecord is a row of the file metadata table
+type FileRecord struct {
+	Path      string `parquet:"path,dict" json:"path"`
+	Component string `parquet:"component,dict" json:"component,omitempty"`
+	Version   string `parquet:"version,dict" json:"version,omitempty"`
+	Language  string `parquet:"language,dict" json:"language"`
+	Hash      string `parquet:"hash" json:"hash"`
+	Size      int64  `parquet:"size" json:"size"`
+}
+
+// FunctionRecord is a row of the function metadata table
+type FunctionRecord struct {
+	Path      string `parquet:"path,dict" json:"path"`
+	Component string `parquet:"component,dict" json:"component,omitempty"`
+	Version   string `parquet:"version,dict" json:"version,omitempty"`
+	Language  string `parquet:"language,dict" json:"language"`
+	Name      string `parquet:"name,dict" json:"name"`
+	StartLine int32  `parquet:"start_line" json:"start_line"`
+	EndLine   int32  `parquet:"end_line" json:"end_line"`
+	Hash      string `parquet:"hash" json:"hash"`
+	Size      int64  `parquet:"size" json:"size"`
+
+	// Declaration locates the prototype of the function in a header as
+	// file:line, see analyzer.PairDeclarations
+	Declaration string `parquet:"declaration,dict" json:"declaration,omitempty"`
+	// Scope and Signature tell overloads apart, see parser.Function
+	Scope     string `parquet:"scope,dict" json:"scope,omitempty"`
+	Signature string `parquet:"signature,dict" json:"signature,omitempty"`
+	// Complexity, Tokens and Depth measure the function body
+	Complexity int32 `parquet:"complexity" json:"complexity,omitempty"`
+	Tokens     int32 `parquet:"tokens" json:"tokens,omitempty"`
+	Depth      int32 `parquet:"depth" json:"depth,omitempty"`
+	// Body is the digest of the function body in the body store, see
+	// Tables.Bodies
+	Body string `parquet:"body" json:"body,omitempty"`
+}
+
+// Tables holds the rows of the file and function metadata tables
+type Tables struct {
+	Files     []FileRecord
+	Functions []FunctionRecord
+	// Versions optionally maps components to their version or commit. It
+	// may be set after the rows are added, up to when they are written.
+	Versions map[string]string
+	// Bodies records the digest of each function body, under which the
+	// body store keeps it, see bodies.Store
+	Bodies bool
+	mutex  sync.Mutex
+}
+
+// Add appends a file and its functions to the tables. The component is the
+// first path element of the file below root.
+func (t *Tables) Add(root string, file *analyzer.FileInfo) {
+	component := ComponentOf(root, file.Path)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.Files = append(t.Files, FileRecord{
+		Path:      file.Path,
+		Component: component,
+		Language:  file.Language,
+		Hash:      file.Hash.String(),
+		Size:      file.Size,
+	})
+
+	for _, fn := range file.Functions {
+		if fn.Hash == "" {
+			continue
+		}
+		var body string
+		if t.Bodies {
+			body = bodies.Digest(fn.Content)
+		}
+		t.Functions = append(t.Functions, FunctionRecord{
+			Path:        file.Path,
+			Component:   component,
+			Language:    file.Language,
+			Name:        fn.Name,
+			StartLine:   int32(fn.StartLine),
+			EndLine:     int32(fn.EndLine),
+			Hash:        fn.Hash,
+			Size:        int64(len(fn.Content)),
+			Declaration: fn.Declaration,
+			Scope:       fn.Scope,
+			Signature:   fn.Signature,
+			Complexity:  int32(fn.Complexity),
+			Tokens:      int32(fn.Tokens),
+			Depth:       int32(fn.Depth),
+			Body:        body,
+		})
+	}
+}
+
+// WriteParquet writes the tables as zstd-compressed Parquet files into dir
+func (t *Tables) WriteParquet(dir string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.setVersions()
+	t.sort()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	compression := parquet.Compression(&parquet.Zstd)
+
+	if err := parquet.WriteFile(filepath.Join(dir, FilesParquet), t.Files, compression); err != nil {
+		return fmt.Errorf("failed to write file table: %v", err)
+	}
+	if err := parquet.WriteFile(filepath.Join(dir, FunctionsParquet), t.Functions, compression); err != nil {
+		return fmt.Errorf("failed to write function table: %v", err)
+	}
+
+	return nil
+}
+
+// WriteJSON writes the tables as JSON files into dir
+func (t *Tables) WriteJSON(dir string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.setVersions()
+	t.sort()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	if err := writeJSON(filepath.Join(dir, FilesJSON), t.Files); err != nil {
+		return fmt.Errorf("failed to write file table: %v", err)
+	}
+	if err := writeJSON(filepath.Join(dir, FunctionsJSON), t.Functions); err != nil {
+		return fmt.Errorf("failed to write function table: %v", err)
+	}
+
+	return nil
+}
+
+// setVersions fills in the version of the component of each row; the
+// caller must hold the mutex
+func (t *Tables) setVersions() {
+	for i := range t.Files {
+		t.Files[i].Version = t.Versions[t.Files[i].Component]
+	}
+	for i := range t.Functions {
+		t.Functions[i].Version = t.Versions[t.Functions[i].Component]
+	}
+}
+
+// sort orders the rows by path, and functions by their position in the
+// file, since files are added in the order they finish processing; the
+// caller must hold the mutex
+func (t *Tables) sort() {
+	sort.Slice(t.Files, func(i, j int) bool {
+		return t.Files[i].Path < t.Files[j].Path
+	})
+	sort.SliceStable(t.Functions, func(i, j int) bool {
+		x, y := t.Functions[i], t.Functions[j]
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.StartLine < y.StartLine
+	})
+}
+
+// writeJSON marshals v as indented JSON into path
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0644)
+}
+
+// ComponentOf returns the first path element of path below root, or an
+// empty string if path is not inside a subdirectory of root
+func ComponentOf(root, path string) string {
+	relPath, err := filepath.Rel(root, path)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.SplitN(filepath.ToSlash(relPath), "/", 2)
+	if len(parts) < 2 || parts[0] == ".." {
+		return ""
+	}
+	return parts[0]
+}
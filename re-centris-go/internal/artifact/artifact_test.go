@@ -0,0 +1,55 @@
+package artifact
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+)
+
+func TestWriteParquet(t *testing.T) {
+	hash, err := tlsh.New([]byte("This is a test string that is long enough to generate a TLSH hash"))
+	if err != nil {
+		t.Fatalf("tlsh.New() error = %v", err)
+	}
+
+	tables := &Tables{}
+	tables.Add("/corpus", &analyzer.FileInfo{
+		Path:     "/corpus/zlib/inflate.c",
+		Language: "cpp",
+		Hash:     hash,
+		Size:     1234,
+		Functions: []parser.Function{
+			{Name: "inflate", StartLine: 10, EndLine: 20, Hash: hash.String(), Content: "int inflate() {}"},
+			{Name: "tiny", StartLine: 30, EndLine: 31},
+		},
+	})
+
+	// Versions are known once all files are added
+	tables.Versions = map[string]string{"zlib": "v1.3"}
+
+	dir := t.TempDir()
+	if err := tables.WriteParquet(dir); err != nil {
+		t.Fatalf("WriteParquet() error = %v", err)
+	}
+
+	files, err := parquet.ReadFile[FileRecord](filepath.Join(dir, FilesParquet))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Component != "zlib" || files[0].Version != "v1.3" || files[0].Size != 1234 {
+		t.Errorf("file records = %+v", files)
+	}
+
+	functions, err := parquet.ReadFile[FunctionRecord](filepath.Join(dir, FunctionsParquet))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	// Functions without a hash are not written
+	if len(functions) != 1 || functions[0].Name != "inflate" || functions[0].EndLine != 20 || functions[0].Version != "v1.3" {
+		t.Errorf("function records = %+v", functions)
+	}
+}
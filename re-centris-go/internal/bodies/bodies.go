@@ -0,0 +1,171 @@
+// Package bodies stores the source of functions content-addressed by the
+// SHA-256 of their text. The same function recurs in many versions and
+// repositories of a corpus; its body is stored once, and the signature
+// records of each file and version reference it by digest.
+package bodies
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// DirName is the directory of the body store in preprocessor output
+const DirName = "bodies"
+
+var (
+	// EncodeAll and DecodeAll may be called concurrently
+	encoder, _ = zstd.NewWriter(nil)
+	decoder, _ = zstd.NewReader(nil)
+)
+
+// Stats counts the bodies put into a store
+type Stats struct {
+	Stored int64 `json:"stored"` // bodies written
+	Shared int64 `json:"shared"` // bodies stored already
+	Bytes  int64 `json:"bytes"`  // compressed bytes written
+}
+
+// PruneStats describes a pruning of a store
+type PruneStats struct {
+	Kept    int   `json:"kept"`
+	Removed int   `json:"removed"`
+	Freed   int64 `json:"freed"` // bytes of the removed bodies
+}
+
+// Store is a content-addressed store of function bodies in a directory.
+// Bodies are zstd-compressed and named by the digest of their text, in
+// the layout of the snapshot object store. A Store is safe for concurrent
+// use, except that Prune must not run while bodies are put.
+type Store struct {
+	dir string
+
+	mutex sync.Mutex
+	known map[string]bool // digests stored, or found stored, by this Store
+	stats Stats
+}
+
+// Open returns the store in dir. The directory is created with the first
+// body.
+func Open(dir string) *Store {
+	return &Store{dir: dir, known: make(map[string]bool)}
+}
+
+// Digest returns the digest a body is stored under
+func Digest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Put stores a body unless it is stored already, and returns its digest
+func (s *Store) Put(content string) (string, error) {
+	digest := Digest(content)
+
+	s.mutex.Lock()
+	known := s.known[digest]
+	s.known[digest] = true
+	s.mutex.Unlock()
+
+	path := s.path(digest)
+	if !known {
+		_, err := os.Stat(path)
+		known = err == nil
+	}
+	if known {
+		s.mutex.Lock()
+		s.stats.Shared++
+		s.mutex.Unlock()
+		return digest, nil
+	}
+
+	compressed := encoder.EncodeAll([]byte(content), nil)
+	if err := fsutil.WriteFileAtomic(path, compressed, 0644); err != nil {
+		s.mutex.Lock()
+		delete(s.known, digest)
+		s.mutex.Unlock()
+		return "", fmt.Errorf("failed to write function body: %v", err)
+	}
+
+	s.mutex.Lock()
+	s.stats.Stored++
+	s.stats.Bytes += int64(len(compressed))
+	s.mutex.Unlock()
+	return digest, nil
+}
+
+// Get returns the body stored under a digest, reporting a body not
+// matching its digest as corrupt
+func (s *Store) Get(digest string) (string, error) {
+	if len(digest) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid body digest: %q", digest)
+	}
+	path := s.path(digest)
+	compressed, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read function body: %v", err)
+	}
+	data, err := decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return "", &fsutil.CorruptError{Path: path, Err: err}
+	}
+	if content := string(data); Digest(content) == digest {
+		return content, nil
+	}
+	return "", &fsutil.CorruptError{Path: path, Err: fmt.Errorf("content does not match its digest")}
+}
+
+// Stats returns the counts of the bodies put so far
+func (s *Store) Stats() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+// Prune removes the bodies for which referenced returns false
+func (s *Store) Prune(referenced func(digest string) bool) (*PruneStats, error) {
+	stats := &PruneStats{}
+	err := filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		// Skip directories and bodies still being written
+		if err != nil || entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return err
+		}
+		if referenced(entry.Name()) {
+			stats.Kept++
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		stats.Removed++
+		stats.Freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune function bodies: %v", err)
+	}
+
+	s.mutex.Lock()
+	s.known = make(map[string]bool)
+	s.mutex.Unlock()
+	return stats, nil
+}
+
+// path returns the file of a body, fanned out by its first byte
+func (s *Store) path(digest string) string {
+	return filepath.Join(s.dir, digest[:2], digest)
+}
@@ -0,0 +1,64 @@
+package bodies
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DirName)
+	s := Open(dir)
+
+	add := "int add(int a, int b) {\n\treturn a + b;\n}\n"
+	sub := "int sub(int a, int b) {\n\treturn a - b;\n}\n"
+	digest, err := s.Put(add)
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if digest != Digest(add) {
+		t.Errorf("Put() = %s, want %s", digest, Digest(add))
+	}
+	if _, err := s.Put(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	// A body put again, by this or another store, is not written again
+	if again, _ := s.Put(add); again != digest {
+		t.Errorf("Put() of the same body = %s", again)
+	}
+	if _, err := Open(dir).Put(add); err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(); stats.Stored != 2 || stats.Shared != 1 {
+		t.Errorf("Stats() = %+v, want 2 stored and 1 shared", stats)
+	}
+
+	if content, err := Open(dir).Get(digest); err != nil || content != add {
+		t.Errorf("Get() = %q, %v", content, err)
+	}
+	if err := os.WriteFile(s.path(Digest(sub)), encoder.EncodeAll([]byte("tampered"), nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var corrupt *fsutil.CorruptError
+	if _, err := s.Get(Digest(sub)); !errors.As(err, &corrupt) {
+		t.Errorf("Get() of a tampered body = %v, want a CorruptError", err)
+	}
+
+	stats, err := s.Prune(func(d string) bool { return d == digest })
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if stats.Kept != 1 || stats.Removed != 1 {
+		t.Errorf("Prune() = %+v, want 1 kept and 1 removed", stats)
+	}
+	if _, err := s.Get(Digest(sub)); err == nil {
+		t.Error("Get() found a pruned body")
+	}
+	if _, err := Open(filepath.Join(dir, "missing")).Prune(func(string) bool { return false }); err != nil {
+		t.Errorf("Prune() of a missing store failed: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package calibrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultStep is the default similarity step of the threshold sweep
+const defaultStep = 0.01
+
+// Sample is a labeled file of the calibration set
+type Sample struct {
+	Path   string
+	Reused bool // true if the file is known to reuse code of the corpus
+}
+
+// Recommendation is the recommended similarity threshold for a language
+type Recommendation struct {
+	Language  string  `json:"language"`
+	Threshold float64 `json:"threshold"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Samples   int     `json:"samples"`
+	// Pairs and FalsePositiveRate are set by CalibrateCorpus instead of
+	// the labeled sample statistics
+	Pairs             int     `json:"pairs,omitempty"`
+	FalsePositiveRate float64 `json:"false_positive_rate,omitempty"`
+}
+
+// Usable reports whether the recommendation is backed by data: reused
+// samples that matched, or sampled corpus pairs
+func (r Recommendation) Usable() bool {
+	return r.F1 > 0 || r.Pairs > 0
+}
+
+// CalibratorOptions contains options for the calibrator
+type CalibratorOptions struct {
+	// Analyzer configures how samples and known files are analyzed; it
+	// should match the detector's so the thresholds apply to detection
+	Analyzer      analyzer.AnalyzerOptions
+	KnownFilesDir string
+	Step          float64 // similarity step of the sweep, defaults to 0.01
+}
+
+// Calibrator recommends similarity thresholds from a labeled sample
+type Calibrator struct {
+	opts     CalibratorOptions
+	analyzer *analyzer.Analyzer
+}
+
+// scoredSample is a sample with the similarity of its best known match
+type scoredSample struct {
+	similarity float64
+	reused     bool
+}
+
+// New creates a new Calibrator
+func New(opts CalibratorOptions) *Calibrator {
+	if opts.Step <= 0 {
+		opts.Step = defaultStep
+	}
+
+	return &Calibrator{
+		opts:     opts,
+		analyzer: analyzer.New(opts.Analyzer),
+	}
+}
+
+// Calibrate sweeps similarity thresholds over the labeled samples and
+// recommends the threshold with the best F1 score for each language
+func (c *Calibrator) Calibrate(ctx context.Context, samples []Sample) ([]Recommendation, error) {
+	knownFiles, err := c.analyzer.AnalyzeDirectory(ctx, c.opts.KnownFilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known files: %v", err)
+	}
+
+	var (
+		scored    = make(map[string][]scoredSample)
+		scoredMux sync.Mutex
+	)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.opts.Analyzer.MaxWorkers)
+
+	for _, sample := range samples {
+		sample := sample // Create new variable for goroutine
+		if c.analyzer.LanguageOf(sample.Path) == "" {
+			continue
+		}
+
+		g.Go(func() error {
+			file, err := c.analyzer.AnalyzeFile(ctx, sample.Path)
+			if err != nil {
+				if analyzer.IsSkipped(err) {
+					// Skip files that are too small or not worth hashing
+					return nil
+				}
+				logger.Warn("Failed to analyze sample",
+					zap.String("path", sample.Path),
+					zap.Error(err))
+				return nil
+			}
+
+			s := scoredSample{
+				similarity: bestSimilarity(file, knownFiles),
+				reused:     sample.Reused,
+			}
+
+			scoredMux.Lock()
+			scored[file.Language] = append(scored[file.Language], s)
+			scoredMux.Unlock()
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("error while scoring samples: %v", err)
+	}
+
+	recommendations := make([]Recommendation, 0, len(scored))
+	for language, samples := range scored {
+		rec := sweep(samples, c.opts.Step)
+		rec.Language = language
+		recommendations = append(recommendations, rec)
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Language < recommendations[j].Language
+	})
+
+	return recommendations, nil
+}
+
+// SamplesFromDir returns all files below dir as samples with the given label
+func SamplesFromDir(dir string, reused bool) ([]Sample, error) {
+	var samples []Sample
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			samples = append(samples, Sample{Path: path, Reused: reused})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk sample directory: %v", err)
+	}
+	return samples, nil
+}
+
+// bestSimilarity returns the similarity of the closest known file of the
+// same language, using the same distance scale as the detector
+func bestSimilarity(file *analyzer.FileInfo, knownFiles []*analyzer.FileInfo) float64 {
+	best := 0.0
+	for _, known := range knownFiles {
+		if known.Language != file.Language || known.Path == file.Path {
+			continue
+		}
+		distance := file.Hash.Distance(known.Hash)
+		if distance < 0 {
+			continue
+		}
+		if similarity := 1.0 - float64(distance)/100.0; similarity > best {
+			best = similarity
+		}
+	}
+	return best
+}
+
+// sweep evaluates every threshold from 0 to 1 in steps and returns the one
+// with the highest F1 score. When a range of thresholds ties for the best
+// score, the middle of the range is recommended to leave a margin on both sides.
+func sweep(samples []scoredSample, step float64) Recommendation {
+	best := Recommendation{Samples: len(samples), Threshold: 1}
+	lowest := best.Threshold
+
+	steps := int(1/step + 0.5)
+	for i := steps; i >= 0; i-- {
+		threshold := math.Round(float64(i)*step*1e6) / 1e6
+
+		var tp, fp, fn int
+		for _, s := range samples {
+			predicted := s.similarity >= threshold
+			switch {
+			case predicted && s.reused:
+				tp++
+			case predicted && !s.reused:
+				fp++
+			case !predicted && s.reused:
+				fn++
+			}
+		}
+
+		var precision, recall, f1 float64
+		if tp+fp > 0 {
+			precision = float64(tp) / float64(tp+fp)
+		}
+		if tp+fn > 0 {
+			recall = float64(tp) / float64(tp+fn)
+		}
+		if precision+recall > 0 {
+			f1 = 2 * precision * recall / (precision + recall)
+		}
+
+		if f1 > best.F1 {
+			best.Threshold = threshold
+			best.Precision = precision
+			best.Recall = recall
+			best.F1 = f1
+			lowest = threshold
+		} else if f1 == best.F1 && f1 > 0 && lowest-threshold < step*1.5 {
+			lowest = threshold
+		}
+	}
+
+	// Recommend the middle of the tied range, rounded to the step
+	mid := (best.Threshold + lowest) / 2
+	best.Threshold = math.Round(math.Round(mid/step)*step*1e6) / 1e6
+
+	return best
+}
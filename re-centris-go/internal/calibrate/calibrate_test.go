@@ -0,0 +1,118 @@
+package calibrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+)
+
+func TestSweep(t *testing.T) {
+	samples := []scoredSample{
+		{similarity: 0.95, reused: true},
+		{similarity: 0.90, reused: true},
+		{similarity: 0.85, reused: true},
+		{similarity: 0.70, reused: false},
+		{similarity: 0.60, reused: false},
+		{similarity: 0.88, reused: false},
+	}
+
+	rec := sweep(samples, 0.01)
+	if rec.Samples != len(samples) {
+		t.Errorf("Samples = %v, want %v", rec.Samples, len(samples))
+	}
+	if rec.Recall != 1 {
+		t.Errorf("Recall = %v, want 1", rec.Recall)
+	}
+	if math.Abs(rec.Precision-0.75) > 1e-9 {
+		t.Errorf("Precision = %v, want 0.75", rec.Precision)
+	}
+	// Thresholds 0.71-0.85 all score best, so the middle is recommended
+	if rec.Threshold != 0.78 {
+		t.Errorf("Threshold = %v, want 0.78", rec.Threshold)
+	}
+}
+
+func TestSweepWithoutReusedSamples(t *testing.T) {
+	rec := sweep([]scoredSample{{similarity: 0.5}}, 0.01)
+	if rec.F1 != 0 || rec.Threshold != 1 {
+		t.Errorf("sweep() = %+v, want threshold 1 with F1 0", rec)
+	}
+}
+
+func TestThresholdForRate(t *testing.T) {
+	similarities := make([]float64, 100)
+	for i := range similarities {
+		similarities[i] = float64(i) / 100
+	}
+
+	// At most 5 of the 100 unrelated pairs (0.95-0.99) may match
+	rec := thresholdForRate(similarities, 0.05, 0.01)
+	if rec.Threshold != 0.95 {
+		t.Errorf("Threshold = %v, want 0.95", rec.Threshold)
+	}
+	if rec.FalsePositiveRate != 0.05 || rec.Pairs != 100 {
+		t.Errorf("FalsePositiveRate = %v, Pairs = %v", rec.FalsePositiveRate, rec.Pairs)
+	}
+
+	if rec := thresholdForRate(nil, 0.05, 0.01); rec.Usable() {
+		t.Errorf("recommendation without pairs is usable: %+v", rec)
+	}
+}
+
+func TestCalibrateNormalized(t *testing.T) {
+	var code, commented strings.Builder
+	for i := 0; i < 30; i++ {
+		line := fmt.Sprintf("int f%d(int x) { return x * %d + %d; }\n", i, i+3, i*7)
+		code.WriteString(line)
+		fmt.Fprintf(&commented, "/* f%d scales x by %d and adds an offset */\n%s", i, i+3, line)
+	}
+	var unrelated strings.Builder
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&unrelated, "static char *name%d = \"value number %d\";\n", i, i*i)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"known/acme%lib/lib.c": commented.String(),
+		"samples/reused.c":     code.String(),
+		"samples/original.c":   unrelated.String(),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New(CalibratorOptions{
+		Analyzer: analyzer.AnalyzerOptions{
+			MaxWorkers: 2,
+			Languages:  analyzer.DefaultLanguages(),
+			Normalize: map[string]normalize.Options{
+				"cpp": {StripComments: true, CollapseWhitespace: true},
+			},
+		},
+		KnownFilesDir: filepath.Join(dir, "known"),
+	})
+	recommendations, err := c.Calibrate(context.Background(), []Sample{
+		{Path: filepath.Join(dir, "samples/reused.c"), Reused: true},
+		{Path: filepath.Join(dir, "samples/original.c")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only with the comments stripped does the reused sample match
+	if len(recommendations) != 1 || recommendations[0].F1 != 1 {
+		t.Errorf("Calibrate() = %+v, want a cpp recommendation with F1 1", recommendations)
+	}
+}
@@ -0,0 +1,116 @@
+package calibrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/artifact"
+)
+
+const (
+	// DefaultPairs is the default number of file pairs sampled per language
+	DefaultPairs = 1000
+	// DefaultFalsePositiveRate is the default rate of unrelated pairs
+	// allowed to match
+	DefaultFalsePositiveRate = 0.01
+)
+
+// CalibrateCorpus recommends thresholds without a labeled sample. Files of
+// different components of the corpus are assumed not to share code, so for
+// each language it samples up to pairs such file pairs and recommends the
+// lowest threshold at which at most maxFPR of them would match. Sampling is
+// seeded, so the same corpus yields the same recommendations.
+func (c *Calibrator) CalibrateCorpus(ctx context.Context, pairs int, maxFPR float64) ([]Recommendation, error) {
+	if pairs <= 0 {
+		pairs = DefaultPairs
+	}
+	if maxFPR <= 0 {
+		maxFPR = DefaultFalsePositiveRate
+	}
+
+	knownFiles, err := c.analyzer.AnalyzeDirectory(ctx, c.opts.KnownFilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known files: %v", err)
+	}
+
+	byLanguage := make(map[string][]*analyzer.FileInfo)
+	for _, file := range knownFiles {
+		byLanguage[file.Language] = append(byLanguage[file.Language], file)
+	}
+
+	recommendations := make([]Recommendation, 0, len(byLanguage))
+	for language, files := range byLanguage {
+		similarities := c.unrelatedSimilarities(files, pairs)
+		rec := thresholdForRate(similarities, maxFPR, c.opts.Step)
+		rec.Language = language
+		recommendations = append(recommendations, rec)
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Language < recommendations[j].Language
+	})
+
+	return recommendations, nil
+}
+
+// unrelatedSimilarities samples up to n pairs of files from different
+// components and returns their similarities
+func (c *Calibrator) unrelatedSimilarities(files []*analyzer.FileInfo, n int) []float64 {
+	components := make([]string, len(files))
+	for i, file := range files {
+		components[i] = artifact.ComponentOf(c.opts.KnownFilesDir, file.Path)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	var similarities []float64
+
+	// Give up after a bounded number of draws in corpora with few
+	// components, where most pairs share one
+	for draws := 0; len(similarities) < n && draws < 10*n && len(files) > 1; draws++ {
+		i, j := rng.Intn(len(files)), rng.Intn(len(files))
+		if components[i] == components[j] {
+			continue
+		}
+		distance := files[i].Hash.Distance(files[j].Hash)
+		if distance < 0 {
+			continue
+		}
+		similarities = append(similarities, math.Max(0, 1.0-float64(distance)/100.0))
+	}
+
+	return similarities
+}
+
+// thresholdForRate returns the lowest threshold, rounded up to the step, at
+// which at most maxFPR of the similarities of unrelated pairs match
+func thresholdForRate(similarities []float64, maxFPR, step float64) Recommendation {
+	rec := Recommendation{Pairs: len(similarities), Threshold: 1}
+	if len(similarities) == 0 {
+		return rec
+	}
+
+	sort.Sort(sort.Reverse(sort.Float64Slice(similarities)))
+
+	// Up to allowed pairs may match; the threshold must exclude the next one
+	allowed := int(maxFPR * float64(len(similarities)))
+	if allowed >= len(similarities) {
+		allowed = len(similarities) - 1
+	}
+	cutoff := similarities[allowed]
+	threshold := math.Min(1, math.Floor(cutoff/step+1+1e-9)*step)
+	rec.Threshold = math.Round(threshold*1e6) / 1e6
+
+	matched := 0
+	for _, s := range similarities {
+		if s >= rec.Threshold {
+			matched++
+		}
+	}
+	rec.FalsePositiveRate = float64(matched) / float64(len(similarities))
+
+	return rec
+}
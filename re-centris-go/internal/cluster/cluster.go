@@ -0,0 +1,247 @@
+// Package cluster groups similar files and functions within one codebase,
+// as connected components of the graph linking items within a TLSH
+// distance.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DefaultFileDistance is the default maximum TLSH distance of two
+	// similar files
+	DefaultFileDistance = 30
+	// DefaultFunctionDistance is the default maximum TLSH distance of two
+	// similar functions
+	DefaultFunctionDistance = 30
+)
+
+// Member is a file, or a function of a file, in a cluster
+type Member struct {
+	File     string `json:"file"`
+	Function string `json:"function,omitempty"`
+	Lines    *Lines `json:"lines,omitempty"`
+}
+
+// Lines is an inclusive range of 1-based line numbers
+type Lines struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Cluster is a group of similar files or functions. Every member is within
+// the distance of at least one other member, so the members of a long
+// chain may be further apart; MaxDistance is the largest distance of the
+// links that formed the cluster.
+type Cluster struct {
+	Language    string   `json:"language"`
+	Members     []Member `json:"members"`
+	MaxDistance int      `json:"max_distance"`
+}
+
+// Report holds the file and function clusters of a codebase, largest first
+type Report struct {
+	Files     []Cluster `json:"files"`
+	Functions []Cluster `json:"functions,omitempty"`
+}
+
+// ClustererOptions contains options for the clusterer
+type ClustererOptions struct {
+	Analyzer analyzer.AnalyzerOptions
+	// FileDistance and FunctionDistance are the maximum TLSH distances of
+	// similar files and functions, defaulting to 30
+	FileDistance     int
+	FunctionDistance int
+	// Functions also clusters the functions of the files
+	Functions bool
+	// MinSize is the smallest cluster reported, defaults to 2
+	MinSize int
+}
+
+// Clusterer groups similar files and functions of a codebase
+type Clusterer struct {
+	opts     ClustererOptions
+	analyzer *analyzer.Analyzer
+}
+
+// item is a hashed file or function
+type item struct {
+	member   Member
+	language string
+	hash     *tlsh.TLSH
+}
+
+// New creates a new Clusterer
+func New(opts ClustererOptions) *Clusterer {
+	if opts.FileDistance <= 0 {
+		opts.FileDistance = DefaultFileDistance
+	}
+	if opts.FunctionDistance <= 0 {
+		opts.FunctionDistance = DefaultFunctionDistance
+	}
+	if opts.MinSize < 2 {
+		opts.MinSize = 2
+	}
+
+	return &Clusterer{
+		opts:     opts,
+		analyzer: analyzer.New(opts.Analyzer),
+	}
+}
+
+// ClusterDirectory analyzes the files of a directory and clusters them
+func (c *Clusterer) ClusterDirectory(ctx context.Context, dir string) (*Report, error) {
+	files, err := c.analyzer.AnalyzeDirectory(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze directory: %v", err)
+	}
+
+	var fileItems, functionItems []item
+	for _, file := range files {
+		if file.Hash != nil {
+			fileItems = append(fileItems, item{member: Member{File: file.Path}, language: file.Language, hash: file.Hash})
+		}
+		if !c.opts.Functions {
+			continue
+		}
+		for _, fn := range file.Functions {
+			hash, err := tlsh.Parse(fn.Hash)
+			if err != nil {
+				continue
+			}
+			functionItems = append(functionItems, item{
+				member:   Member{File: file.Path, Function: fn.Name, Lines: &Lines{Start: fn.StartLine, End: fn.EndLine}},
+				language: file.Language,
+				hash:     hash,
+			})
+		}
+	}
+
+	report := &Report{}
+	if report.Files, err = c.cluster(ctx, fileItems, c.opts.FileDistance); err != nil {
+		return nil, err
+	}
+	if c.opts.Functions {
+		if report.Functions, err = c.cluster(ctx, functionItems, c.opts.FunctionDistance); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// link is an edge of the distance graph
+type link struct {
+	a, b     int
+	distance int
+}
+
+// cluster returns the connected components of items of the same language
+// within maxDistance of each other
+func (c *Clusterer) cluster(ctx context.Context, items []item, maxDistance int) ([]Cluster, error) {
+	hashes := make([]*tlsh.TLSH, len(items))
+	for i := range items {
+		hashes[i] = items[i].hash
+	}
+
+	// Each item is compared with the items after it
+	links := make([][]link, len(items))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(c.opts.Analyzer.MaxWorkers, 1))
+	for i := range items {
+		i := i
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for j, distance := range tlsh.DistanceMany(hashes[i], hashes[i+1:]) {
+				j += i + 1
+				if distance >= 0 && distance <= maxDistance && items[i].language == items[j].language {
+					links[i] = append(links[i], link{a: i, b: j, distance: distance})
+				}
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	parent := make([]int, len(items))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	maxLink := make(map[int]int)
+	for _, itemLinks := range links {
+		for _, l := range itemLinks {
+			ra, rb := find(l.a), find(l.b)
+			if ra != rb {
+				parent[rb] = ra
+				maxLink[ra] = max(maxLink[ra], maxLink[rb])
+			}
+			maxLink[ra] = max(maxLink[ra], l.distance)
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range items {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+
+	var clusters []Cluster
+	for root, members := range groups {
+		if len(members) < c.opts.MinSize {
+			continue
+		}
+		cl := Cluster{Language: items[root].language, MaxDistance: maxLink[root]}
+		for _, i := range members {
+			cl.Members = append(cl.Members, items[i].member)
+		}
+		sort.Slice(cl.Members, func(i, j int) bool {
+			a, b := cl.Members[i], cl.Members[j]
+			if a.File != b.File {
+				return a.File < b.File
+			}
+			return a.Lines != nil && b.Lines != nil && a.Lines.Start < b.Lines.Start
+		})
+		clusters = append(clusters, cl)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		a, b := clusters[i], clusters[j]
+		if len(a.Members) != len(b.Members) {
+			return len(a.Members) > len(b.Members)
+		}
+		return a.Members[0].File < b.Members[0].File
+	})
+	return clusters, nil
+}
+
+// SaveReport saves a cluster report to a JSON file
+func SaveReport(report *Report, outputPath string) error {
+	if report.Files == nil {
+		report.Files = []Cluster{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster report: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cluster report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+// source returns C functions long enough to be hashed
+func source(name string, n, seed int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "int %s%d(int x, int y)\n{\n", name, i)
+		for j := 0; j < 6; j++ {
+			fmt.Fprintf(&b, "    x = x * %d + y / %d - %d;\n    if (x > %d) { y ^= x << %d; }\n", seed+i+j, j+1, seed*j, seed*100+i, j%5)
+		}
+		b.WriteString("    return x + y;\n}\n\n")
+	}
+	return b.String()
+}
+
+func TestClusterDirectory(t *testing.T) {
+	dir := t.TempDir()
+	shared := source("shared", 1, 7)
+	files := map[string]string{
+		"a.c":     source("util", 4, 1) + shared,
+		"old/a.c": strings.Replace(source("util", 4, 1), "return x + y;", "return x - y;", 1) + shared,
+		"c.c":     table("unrelated", 200) + shared,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New(ClustererOptions{
+		Analyzer:  analyzer.AnalyzerOptions{MaxWorkers: 2, Languages: analyzer.DefaultLanguages()},
+		Functions: true,
+	})
+	report, err := c.ClusterDirectory(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Files) != 1 || len(report.Files[0].Members) != 2 ||
+		report.Files[0].Members[0].File != filepath.Join(dir, "a.c") || report.Files[0].Members[1].File != filepath.Join(dir, "old/a.c") {
+		t.Fatalf("file clusters = %+v, want a.c and old/a.c", report.Files)
+	}
+
+	// The copy of the shared function in c.c is clustered with the others
+	for _, cl := range report.Functions {
+		files := make(map[string]bool)
+		for _, m := range cl.Members {
+			if m.Function == "shared0" {
+				files[filepath.Base(m.File)] = true
+			}
+		}
+		if files["c.c"] {
+			if !files["a.c"] {
+				t.Errorf("function cluster = %+v, want shared0 of a.c and c.c", cl)
+			}
+			return
+		}
+	}
+	t.Errorf("function clusters = %+v, want one with shared0 of c.c", report.Functions)
+}
+
+// table returns C data definitions unlike the functions of source
+func table(name string, n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "static const struct entry %s[] = {\n", name)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "    { \"%s_%d\", %d, 0x%04x, NULL },\n", name, i, i*i, i*7919)
+	}
+	b.WriteString("};\n")
+	return b.String()
+}
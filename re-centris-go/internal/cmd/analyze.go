@@ -2,9 +2,14 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
 
 	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/artifact"
 	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/manifest"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -23,24 +28,55 @@ func init() {
 	rootCmd.AddCommand(analyzeCmd)
 
 	analyzeCmd.Flags().StringP("output", "o", "./analysis", "Output directory for analysis results")
-	analyzeCmd.Flags().IntP("workers", "w", 5, "Number of parallel workers")
+	analyzeCmd.Flags().IntP("workers", "w", 0, "Number of parallel workers (0 = available CPUs)")
+	analyzeCmd.Flags().String("format", "json", "Output format (json, parquet)")
+	analyzeCmd.Flags().Bool("pair-declarations", false, "Link C/C++ definitions to their prototypes in the header of the same name and directory")
 
-	viper.BindPFlag("analyze.output", analyzeCmd.Flags().Lookup("output"))
-	viper.BindPFlag("analyze.workers", analyzeCmd.Flags().Lookup("workers"))
+	addErrorPolicyFlags(analyzeCmd)
 }
 
-func runAnalyze(cmd *cobra.Command, args []string) error {
+func runAnalyze(cmd *cobra.Command, args []string) (err error) {
 	// Get target directory
 	targetDir := args[0]
+	outputDir := viper.GetString("analyze.output")
+
+	run := startRun("analyze", args, filepath.Join(outputDir, manifest.RunFileName))
+	defer func() { finishRun(err) }()
+
+	reporter, err := progressReporter()
+	if err != nil {
+		return err
+	}
+
+	policy, errorReport, err := errorPolicy("analyze")
+	if err != nil {
+		return err
+	}
 
 	// Create analyzer options
 	opts := analyzer.AnalyzerOptions{
-		MaxWorkers: viper.GetInt("analyze.workers"),
-		Languages: map[string][]string{
-			"cpp": {".c", ".cc", ".cpp", ".cxx", ".h", ".hpp"},
-			"java": {".java"},
-			"python": {".py"},
-		},
+		MaxWorkers:       workers("analyze.workers"),
+		Pool:             sharedPool(),
+		Cache:            diskCache(),
+		Languages:        languageExtensions(),
+		Progress:         reporter,
+		Symlinks:         viper.GetString("symlinks"),
+		Include:          viper.GetStringSlice("include"),
+		Exclude:          viper.GetStringSlice("exclude"),
+		GitIgnore:        viper.GetBool("gitignore"),
+		NoSniff:          viper.GetBool("no_sniff"),
+		SniffLanguages:   viper.GetBool("sniff_languages"),
+		Normalize:        normalizeOptions(),
+		SignatureMode:    viper.GetString("signature_mode"),
+		Encoding:         viper.GetString("encoding"),
+		Conditionals:     viper.GetString("conditionals"),
+		MaxFileSize:      viper.GetInt64("max_file_size"),
+		ChunkedSize:      viper.GetInt64("chunked_size"),
+		FileTimeout:      viper.GetDuration("file_timeout"),
+		SlowFiles:        slowFileReport(),
+		ErrorPolicy:      policy,
+		Errors:           errorReport,
+		PairDeclarations: viper.GetBool("analyze.pair_declarations"),
 	}
 
 	// Create analyzer
@@ -51,12 +87,57 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 		zap.String("directory", targetDir))
 
 	files, err := a.AnalyzeDirectory(context.Background(), targetDir)
+	if reportErr := writeErrorReport(errorReport, "analyze", outputDir); reportErr != nil {
+		logger.Error("Failed to write error report", zap.Error(reportErr))
+	}
 	if err != nil {
 		return err
 	}
 
+	// Write file and function tables
+	tables := &artifact.Tables{}
+	for _, file := range files {
+		tables.Add(targetDir, file)
+	}
+
+	switch format := viper.GetString("analyze.format"); format {
+	case "json":
+		err = tables.WriteJSON(outputDir)
+	case "parquet":
+		err = tables.WriteParquet(outputDir)
+	default:
+		err = fmt.Errorf("unsupported output format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	run.Outputs = append(run.Outputs, outputDir)
+	run.Counts["files"] = len(tables.Files)
+	run.Counts["functions"] = len(tables.Functions)
+
+	logSkipStats(a.SkipStats())
 	logger.Info("Code analysis completed",
-		zap.Int("total_files", len(files)))
+		zap.Int("total_files", len(files)),
+		zap.String("output", outputDir))
 
 	return nil
-} 
\ No newline at end of file
+}
+
+// logSkipStats logs how many files of each kind were skipped
+func logSkipStats(stats map[string]int64) {
+	if len(stats) == 0 {
+		return
+	}
+
+	kinds := make([]string, 0, len(stats))
+	for kind := range stats {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	fields := make([]zap.Field, 0, len(kinds))
+	for _, kind := range kinds {
+		fields = append(fields, zap.Int64(kind, stats[kind]))
+	}
+	logger.Info("Skipped files", fields...)
+}
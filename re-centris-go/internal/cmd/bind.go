@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// envPrefix prefixes the environment variables overriding config keys, for
+// example RE_CENTRIS_DETECT_THRESHOLD for detect.threshold
+const envPrefix = "RE_CENTRIS"
+
+// configKeyAnnotation names the config key of a flag whose key does not
+// follow from its name
+const configKeyAnnotation = "re-centris/config-key"
+
+// configKey binds the flag name of flags to key instead of the key derived
+// from the flag name
+func configKey(flags *pflag.FlagSet, name, key string) {
+	flags.SetAnnotation(name, configKeyAnnotation, []string{key})
+}
+
+// bindConfig sets up the sources of every config key for the command
+// being run. Values are taken from, in order of precedence: --set, flags,
+// RE_CENTRIS_* environment variables, the config file and the defaults of
+// the config schema.
+//
+// A flag is bound to the key of its command section and name, for example
+// --function-threshold of detect to detect.function_threshold, if the schema
+// has that key; configKey names the key of flags for which it does not.
+// Only the flags of cmd and the persistent flags of its parents are bound,
+// as flags of several commands may share a key.
+func bindConfig(cmd *cobra.Command) {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+
+	bound := make(map[string]bool)
+	bind := func(c *cobra.Command, flags *pflag.FlagSet) {
+		section := strings.Join(strings.Fields(c.CommandPath())[1:], ".")
+		flags.VisitAll(func(flag *pflag.Flag) {
+			key := flagKey(section, flag)
+			if config.Known(key) {
+				viper.BindPFlag(key, flag)
+				bound[key] = true
+			}
+		})
+	}
+	// Flags of the command override the persistent flags of its parents
+	var parents []*cobra.Command
+	for c := cmd; c != nil; c = c.Parent() {
+		parents = append([]*cobra.Command{c}, parents...)
+	}
+	for _, c := range parents {
+		bind(c, c.PersistentFlags())
+	}
+	bind(cmd, cmd.LocalNonPersistentFlags())
+
+	// Keys without a flag get the schema defaults, so that they can be set
+	// from the environment and are known to UnmarshalKey
+	for key, value := range config.DefaultConfig().Settings() {
+		if !bound[key] {
+			viper.SetDefault(key, value)
+		}
+	}
+}
+
+// flagKey returns the config key of a flag of the command section
+func flagKey(section string, flag *pflag.Flag) string {
+	if keys := flag.Annotations[configKeyAnnotation]; len(keys) > 0 {
+		return keys[0]
+	}
+	key := strings.ReplaceAll(flag.Name, "-", "_")
+	if section == "" {
+		return key
+	}
+	return section + "." + key
+}
+
+// applySetFlags applies the key=value overrides of --set
+func applySetFlags(overrides []string) error {
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return fmt.Errorf("invalid --set %q, expected key=value", override)
+		}
+		key = strings.TrimSpace(key)
+		if !config.Known(key) {
+			return fmt.Errorf("invalid --set %q: unknown config key %s", override, key)
+		}
+		viper.Set(key, value)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/common/cache"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the analysis cache",
+}
+
+var cacheGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Evict least recently used entries from the analysis cache",
+	Long: `Remove the least recently used entries of the analysis cache in
+--cache-dir until it fits cache.max_size. Entries are keyed by content hash
+and analyzer options, so stale entries are never read, only evicted.`,
+	Args: cobra.NoArgs,
+	RunE: runCacheGC,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheGCCmd)
+
+	cacheCmd.PersistentFlags().Int64("max-size", cache.DefaultMaxSize, "Maximum cache size in bytes")
+}
+
+func runCacheGC(cmd *cobra.Command, args []string) error {
+	dir := viper.GetString("cache.dir")
+	if dir == "" {
+		return fmt.Errorf("no cache directory configured, use --cache-dir")
+	}
+
+	c, err := cache.NewDisk(cache.DiskOptions{
+		Dir:     dir,
+		MaxSize: viper.GetInt64("cache.max_size"),
+	})
+	if err != nil {
+		return err
+	}
+	stats, err := c.GC()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Analysis cache collected",
+		zap.String("dir", dir),
+		zap.Int("entries", stats.Entries),
+		zap.Int64("size", stats.Size),
+		zap.Int("evicted", stats.Evicted),
+		zap.Int64("freed", stats.Freed))
+	return nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/calibrate"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Recommend similarity thresholds from a labeled sample",
+	Long: `Sweep similarity thresholds over a labeled sample of known reused and
+known original files and recommend a threshold per language with precision
+and recall estimates. Use --write to store the thresholds in the config file.
+
+Without a labeled sample, pairs of files from different components of the
+corpus are sampled as unrelated code, and the lowest threshold at which at
+most --max-fpr of them match is recommended per language.`,
+	Args: cobra.NoArgs,
+	RunE: runCalibrate,
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+
+	calibrateCmd.Flags().String("reused", "", "Directory of files known to reuse corpus code")
+	calibrateCmd.Flags().String("original", "", "Directory of files known to be original")
+	calibrateCmd.Flags().StringP("known-files", "k", "", "Directory containing known files (default is detect.known_files)")
+	calibrateCmd.Flags().Float64("step", 0.01, "Similarity step of the threshold sweep")
+	calibrateCmd.Flags().Bool("write", false, "Write the recommended thresholds into the config file")
+	calibrateCmd.Flags().Int("pairs", calibrate.DefaultPairs, "File pairs sampled per language without a labeled sample")
+	calibrateCmd.Flags().Float64("max-fpr", calibrate.DefaultFalsePositiveRate, "Rate of unrelated pairs allowed to match without a labeled sample")
+
+	calibrateCmd.MarkFlagsRequiredTogether("reused", "original")
+}
+
+func runCalibrate(cmd *cobra.Command, args []string) error {
+	knownFilesDir := cmd.Flag("known-files").Value.String()
+	if knownFilesDir == "" {
+		knownFilesDir = viper.GetString("detect.known_files")
+	}
+
+	step, _ := cmd.Flags().GetFloat64("step")
+	c := calibrate.New(calibrate.CalibratorOptions{
+		Analyzer:      detectorOptions().AnalyzerOptions(),
+		KnownFilesDir: knownFilesDir,
+		Step:          step,
+	})
+
+	var recommendations []calibrate.Recommendation
+	if cmd.Flag("reused").Changed {
+		reused, err := calibrate.SamplesFromDir(cmd.Flag("reused").Value.String(), true)
+		if err != nil {
+			return err
+		}
+		original, err := calibrate.SamplesFromDir(cmd.Flag("original").Value.String(), false)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Starting threshold calibration",
+			zap.Int("reused_samples", len(reused)),
+			zap.Int("original_samples", len(original)))
+
+		recommendations, err = c.Calibrate(context.Background(), append(reused, original...))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-10s %-10s %-10s %-10s %-10s %s\n", "LANGUAGE", "THRESHOLD", "PRECISION", "RECALL", "F1", "SAMPLES")
+		for _, rec := range recommendations {
+			fmt.Printf("%-10s %-10.2f %-10.3f %-10.3f %-10.3f %d\n",
+				rec.Language, rec.Threshold, rec.Precision, rec.Recall, rec.F1, rec.Samples)
+		}
+	} else {
+		pairs, _ := cmd.Flags().GetInt("pairs")
+		maxFPR, _ := cmd.Flags().GetFloat64("max-fpr")
+
+		logger.Info("Starting threshold calibration from corpus samples",
+			zap.String("known_files_dir", knownFilesDir),
+			zap.Int("pairs", pairs))
+
+		var err error
+		recommendations, err = c.CalibrateCorpus(context.Background(), pairs, maxFPR)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-10s %-10s %-10s %s\n", "LANGUAGE", "THRESHOLD", "FPR", "PAIRS")
+		for _, rec := range recommendations {
+			fmt.Printf("%-10s %-10.2f %-10.4f %d\n",
+				rec.Language, rec.Threshold, rec.FalsePositiveRate, rec.Pairs)
+		}
+	}
+
+	if write, _ := cmd.Flags().GetBool("write"); write {
+		return writeThresholds(recommendations)
+	}
+	return nil
+}
+
+// writeThresholds stores the recommended thresholds in the config file in use
+func writeThresholds(recommendations []calibrate.Recommendation) error {
+	if viper.ConfigFileUsed() == "" {
+		return fmt.Errorf("no config file in use, pass --config to write thresholds")
+	}
+
+	values := make(map[string]interface{})
+	for _, rec := range recommendations {
+		// Languages without reused matches or sampled pairs give no usable
+		// recommendation
+		if !rec.Usable() {
+			continue
+		}
+		values["detect.thresholds."+rec.Language] = rec.Threshold
+	}
+
+	if err := config.SetValues(viper.ConfigFileUsed(), profile, values); err != nil {
+		return err
+	}
+
+	logger.Info("Thresholds written to config",
+		zap.String("config", viper.ConfigFileUsed()),
+		zap.String("profile", profile))
+	return nil
+}
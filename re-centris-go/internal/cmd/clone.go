@@ -1 +1,71 @@
- 
\ No newline at end of file
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/collector/clone"
+	"github.com/re-centris/re-centris-go/internal/collector/metadata"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone [repo-list-file]",
+	Short: "Clone open source repositories",
+	Long: `Clone all repositories listed in a file (one URL per line)
+into the output directory.
+
+With --metadata, the description, license, stars, archived status and
+latest release of GitHub repositories are fetched into the metadata file
+of the output directory and reported with the detected components. Set
+GITHUB_TOKEN to raise the API rate limit.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runClone,
+}
+
+func init() {
+	rootCmd.AddCommand(cloneCmd)
+
+	cloneCmd.Flags().StringP("output", "o", "./repos", "Output directory for cloned repositories")
+	cloneCmd.Flags().IntP("workers", "w", 0, "Number of parallel workers (0 = available CPUs)")
+	cloneCmd.Flags().Bool("tags", false, "Clone the history and tags of each repository for preprocess --versions")
+	cloneCmd.Flags().Bool("metadata", false, "Fetch repository metadata (stars, archived status, latest release) from the GitHub API")
+}
+
+func runClone(cmd *cobra.Command, args []string) error {
+	// Read repository list
+	urls, err := clone.ReadRepoList(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Clone repositories
+	logger.Info("Starting repository cloning",
+		zap.Int("repositories", len(urls)))
+
+	opts := clone.CloneOptions{
+		TargetDir:  viper.GetString("clone.output"),
+		MaxWorkers: workers("clone.workers"),
+		Pool:       sharedPool(),
+		Tags:       viper.GetBool("clone.tags"),
+	}
+	if err := clone.CloneRepositories(context.Background(), urls, opts); err != nil {
+		return err
+	}
+
+	// Fetch repository metadata
+	if viper.GetBool("clone.metadata") {
+		client := metadata.NewClient(metadata.ClientOptions{Token: os.Getenv("GITHUB_TOKEN")})
+		if err := metadata.Collect(context.Background(), client, urls, opts.TargetDir); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Repository cloning completed",
+		zap.String("output", opts.TargetDir))
+
+	return nil
+}
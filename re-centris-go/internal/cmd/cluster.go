@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/re-centris/re-centris-go/internal/cluster"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster [target-dir]",
+	Short: "Group similar files and functions within a codebase",
+	Long: `Group the similar files, and with --functions the similar functions,
+of one codebase into clusters: connected components of the graph linking
+items of the same language within a TLSH distance. Useful to find internal
+duplication for deduplication and refactoring.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCluster,
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+
+	clusterCmd.Flags().StringP("output", "o", "clusters.json", "Output file for the clusters")
+	clusterCmd.Flags().IntP("workers", "w", 0, "Number of parallel workers (0 = available CPUs)")
+	clusterCmd.Flags().Int("file-distance", cluster.DefaultFileDistance, "Maximum TLSH distance of two similar files")
+	clusterCmd.Flags().Int("function-distance", cluster.DefaultFunctionDistance, "Maximum TLSH distance of two similar functions")
+	clusterCmd.Flags().Bool("functions", true, "Also cluster the functions of the files")
+	clusterCmd.Flags().Int("min-size", 2, "Smallest cluster reported")
+}
+
+func runCluster(cmd *cobra.Command, args []string) error {
+	analyzerOpts := detectorOptions().AnalyzerOptions()
+	analyzerOpts.MaxWorkers = workers("cluster.workers")
+
+	c := cluster.New(cluster.ClustererOptions{
+		Analyzer:         analyzerOpts,
+		FileDistance:     viper.GetInt("cluster.file_distance"),
+		FunctionDistance: viper.GetInt("cluster.function_distance"),
+		Functions:        viper.GetBool("cluster.functions"),
+		MinSize:          viper.GetInt("cluster.min_size"),
+	})
+
+	logger.Info("Starting clustering",
+		zap.String("target", args[0]))
+
+	report, err := c.ClusterDirectory(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	outputFile := viper.GetString("cluster.output")
+	if err := cluster.SaveReport(report, outputFile); err != nil {
+		return err
+	}
+
+	logger.Info("Clustering completed",
+		zap.Int("file_clusters", len(report.Files)),
+		zap.Int("function_clusters", len(report.Functions)),
+		zap.String("output_file", outputFile))
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration file",
+	// The file is checked by the subcommands instead of before every command
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		setupCommand(cmd)
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [config-file]",
+	Short: "Check a configuration file for unknown keys and invalid values",
+	Long: `Check a configuration file against the configuration schema, reporting
+all unknown keys, values of the wrong type and invalid values at once. The
+file is checked with the files it extends, alone and with each of its
+profiles. It defaults to --config or $HOME/.re-centris.yaml.`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runConfigValidate,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	path := viper.ConfigFileUsed()
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
+		return fmt.Errorf("no config file found, pass one or use --config")
+	}
+
+	if _, err := config.Load(path, ""); err != nil {
+		return err
+	}
+	profiles, err := config.Profiles(path)
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		if _, err := config.Load(path, p); err != nil {
+			return err
+		}
+	}
+
+	if len(profiles) > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: valid, with profiles %s\n", path, strings.Join(profiles, ", "))
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: valid\n", path)
+	}
+	return nil
+}
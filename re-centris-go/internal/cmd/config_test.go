@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/config"
+	"github.com/spf13/viper"
+)
+
+func TestBindConfig(t *testing.T) {
+	t.Setenv("RE_CENTRIS_DETECT_VULNS_ENDPOINT", "https://osv.example.com/v1/query")
+	t.Setenv("RE_CENTRIS_DETECT_THRESHOLD", "0.7")
+	bindConfig(detectCmd)
+
+	for _, key := range viper.AllKeys() {
+		if !config.Known(key) {
+			t.Errorf("key %s is not in the config schema", key)
+		}
+	}
+
+	// Flags named after their key and flags with a configKey
+	detectCmd.Flags().Set("function-threshold", "12")
+	if got := viper.GetInt("detect.function_threshold"); got != 12 {
+		t.Errorf("detect.function_threshold = %d, want 12", got)
+	}
+	bindConfig(serveCmd)
+	serveCmd.Flags().Set("repo-list", "repos.txt")
+	if got := viper.GetString("serve.refresh.repo_list"); got != "repos.txt" {
+		t.Errorf("serve.refresh.repo_list = %q, want repos.txt", got)
+	}
+
+	// Flags of the command being run are bound, not those of other
+	// commands sharing their key
+	bindConfig(pipelineCmd)
+	pipelineCmd.Flags().Set("preprocess-output", "pipeline-sigs")
+	preprocessCmd.Flags().Set("output", "preprocess-sigs")
+	if got := viper.GetString("preprocess.output"); got != "pipeline-sigs" {
+		t.Errorf("preprocess.output = %q, want the pipeline flag value", got)
+	}
+	bindConfig(detectCmd)
+
+	// The environment overrides defaults and is overridden by flags and --set
+	if got := viper.GetString("detect.vulns.endpoint"); got != "https://osv.example.com/v1/query" {
+		t.Errorf("detect.vulns.endpoint = %q, want the environment value", got)
+	}
+	if got := viper.GetFloat64("detect.threshold"); got != 0.7 {
+		t.Errorf("detect.threshold = %v, want 0.7", got)
+	}
+	detectCmd.Flags().Set("threshold", "0.75")
+	if got := viper.GetFloat64("detect.threshold"); got != 0.75 {
+		t.Errorf("detect.threshold = %v, want the flag value 0.75", got)
+	}
+	if err := applySetFlags([]string{"detect.threshold=0.9"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := viper.GetFloat64("detect.threshold"); got != 0.9 {
+		t.Errorf("detect.threshold = %v, want the --set value 0.9", got)
+	}
+
+	if err := applySetFlags([]string{"detect.treshold=0.9"}); err == nil {
+		t.Error("applySetFlags() with an unknown key succeeded")
+	}
+}
+
+func TestLanguageExtensions(t *testing.T) {
+	defer viper.Set("languages", nil)
+	viper.Set("languages", map[string]interface{}{
+		"cpp":    map[string]interface{}{"extensions": []string{".c"}},
+		"python": map[string]interface{}{"enabled": false},
+	})
+
+	languages := languageExtensions()
+	if len(languages["cpp"]) != 1 || len(languages["java"]) != 1 {
+		t.Errorf("languageExtensions() = %v, want cpp and java without an enabled key enabled", languages)
+	}
+	if _, ok := languages["python"]; ok {
+		t.Errorf("languageExtensions() = %v, want python disabled", languages)
+	}
+}
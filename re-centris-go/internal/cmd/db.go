@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/re-centris/re-centris-go/internal/artifact"
+	"github.com/re-centris/re-centris-go/internal/bodies"
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"github.com/re-centris/re-centris-go/internal/sigindex"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain the signature database",
+}
+
+var dbGCCmd = &cobra.Command{
+	Use:   "gc [signatures-directory]",
+	Short: "Remove stale signatures and compact the signature database",
+	Long: `Remove the signatures no longer referenced by the current components and
+versions from sharded preprocessor output, by default detect.signatures or
+preprocess.output, and rewrite its shards compactly.
+
+The current components are the directories of --corpus if set, and the
+repositories of the corpus manifest otherwise; without either, only
+superseded records and unreferenced shards are removed. Records written
+without a component by older versions are attributed by their path below
+--corpus, and kept if that fails. Version signatures of other components
+are removed, and versions beyond --max-versions (default
+preprocess.max_versions) are dropped, oldest first. Stored function bodies
+no longer referenced are removed too.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDBGC,
+}
+
+var dbBodyCmd = &cobra.Command{
+	Use:   "body <digest> [signatures-directory]",
+	Short: "Print a function body stored by preprocess --store-bodies",
+	Long: `Print the function body stored under a digest, as referenced by the body
+field of the function signatures, from sharded or JSON preprocessor output,
+by default detect.signatures or preprocess.output.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runDBBody,
+}
+
+var dbIndexCmd = &cobra.Command{
+	Use:   "index [signatures-directory]",
+	Short: "Build the signature index searched by detect --signature-index",
+	Long: `Write the known files and function signatures of sharded preprocessor
+output, by default detect.signatures or preprocess.output, into a compact
+binary index that detect --signature-index maps into memory instead of
+loading the signatures. The index is written to --output, by default
+` + sigindex.FileName + ` in the signatures directory, and must be rebuilt
+when the signatures change. A Bloom filter of the content digests of the
+known files is written next to it, with the extension .bloom, so that
+detect --exact-first only looks up the exact copies of target files it may
+contain.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDBIndex,
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbGCCmd)
+	dbCmd.AddCommand(dbBodyCmd)
+	dbCmd.AddCommand(dbIndexCmd)
+
+	dbGCCmd.Flags().String("corpus", "", "Corpus directory whose subdirectories are the current components")
+	dbGCCmd.Flags().Int("max-versions", 0, "Most recent versions kept per component (default preprocess.max_versions, 0 = all)")
+	dbGCCmd.Flags().Int64("shard-size", 0, "Uncompressed size of a rewritten shard in bytes (default preprocess.shard_size)")
+
+	dbIndexCmd.Flags().StringP("output", "o", "", "Index file (default "+sigindex.FileName+" in the signatures directory)")
+	dbIndexCmd.Flags().Float64("bloom-false-positives", 0.01, "False positive rate of the Bloom filter of file digests written next to the index")
+}
+
+// signaturesDir returns the signature database named by the argument at
+// i, by default detect.signatures or preprocess.output
+func signaturesDir(args []string, i int) string {
+	if len(args) > i {
+		return args[i]
+	}
+	if dir := viper.GetString("detect.signatures"); dir != "" {
+		return dir
+	}
+	return viper.GetString("preprocess.output")
+}
+
+func runDBGC(cmd *cobra.Command, args []string) error {
+	dir := signaturesDir(args, 0)
+	maxVersions := viper.GetInt("preprocess.max_versions")
+	if cmd.Flags().Changed("max-versions") {
+		maxVersions, _ = cmd.Flags().GetInt("max-versions")
+	}
+	shardSize := viper.GetInt64("preprocess.shard_size")
+	if cmd.Flags().Changed("shard-size") {
+		shardSize, _ = cmd.Flags().GetInt64("shard-size")
+	}
+
+	m, _, err := manifest.Read(dir)
+	if err != nil {
+		return err
+	}
+	corpus, _ := cmd.Flags().GetString("corpus")
+	components, err := currentComponents(corpus, m)
+	if err != nil {
+		return err
+	}
+	current := func(component string) bool {
+		return components == nil || component == "" || components[component]
+	}
+
+	// Bodies are referenced by the kept records and version signatures
+	referenced := make(map[string]bool)
+	stats, err := preprocessor.Compact(dir, shardSize, func(metadata *preprocessor.FileMetadata) bool {
+		if !current(recordComponent(corpus, metadata)) {
+			return false
+		}
+		for _, fn := range metadata.Functions {
+			if fn.Body != "" {
+				referenced[fn.Body] = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	removedFiles, removedVersions, err := versions.Prune(dir, current, maxVersions)
+	if err != nil {
+		return err
+	}
+	if err := pruneBodies(dir, referenced); err != nil {
+		return err
+	}
+
+	// Keep the manifest in line with the signatures it describes
+	repos := m.Repositories[:0]
+	for _, repo := range m.Repositories {
+		if current(repo.Name) {
+			repos = append(repos, repo)
+		}
+	}
+	if len(repos) != len(m.Repositories) || stats.Kept != m.TotalFiles || stats.Functions != m.TotalFunctions {
+		m.Repositories, m.TotalFiles, m.TotalFunctions = repos, stats.Kept, stats.Functions
+		hash, err := manifest.Write(dir, m)
+		if err != nil {
+			return err
+		}
+		logger.Info("Corpus manifest updated", zap.String("hash", hash))
+	}
+
+	logger.Info("Signature database collected",
+		zap.String("dir", dir),
+		zap.Int("files", stats.Kept),
+		zap.Int("stale", stats.Stale),
+		zap.Int("pruned", stats.Pruned),
+		zap.Int("shards_before", stats.ShardsBefore),
+		zap.Int("shards_after", stats.ShardsAfter),
+		zap.Int64("size_before", stats.SizeBefore),
+		zap.Int64("size_after", stats.SizeAfter),
+		zap.Int("removed_version_files", removedFiles),
+		zap.Int("removed_versions", removedVersions))
+	return nil
+}
+
+// pruneBodies removes the function bodies stored in dir that neither the
+// given records nor the version signatures reference
+func pruneBodies(dir string, referenced map[string]bool) error {
+	if _, err := os.Stat(filepath.Join(dir, bodies.DirName)); os.IsNotExist(err) {
+		return nil
+	}
+	signatures, err := versions.Read(dir)
+	if err != nil {
+		return err
+	}
+	for _, s := range signatures {
+		for _, digest := range s.Bodies {
+			referenced[digest] = true
+		}
+	}
+
+	stats, err := bodies.Open(filepath.Join(dir, bodies.DirName)).Prune(func(digest string) bool {
+		return referenced[digest]
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Function bodies collected",
+		zap.Int("kept", stats.Kept),
+		zap.Int("removed", stats.Removed),
+		zap.Int64("freed", stats.Freed))
+	return nil
+}
+
+func runDBBody(cmd *cobra.Command, args []string) error {
+	content, err := bodies.Open(filepath.Join(signaturesDir(args, 1), bodies.DirName)).Get(args[0])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(cmd.OutOrStdout(), content)
+	return err
+}
+
+func runDBIndex(cmd *cobra.Command, args []string) error {
+	dir := signaturesDir(args, 0)
+	path, _ := cmd.Flags().GetString("output")
+	if path == "" {
+		path = filepath.Join(dir, sigindex.FileName)
+	}
+
+	// The index records the manifest so that detection can tell it stale,
+	// and the frequency of functions so that common ones can be skipped
+	var opts sigindex.WriterOptions
+	opts.FalsePositives, _ = cmd.Flags().GetFloat64("bloom-false-positives")
+	if _, hash, err := manifest.Read(dir); err == nil {
+		opts.Manifest = hash
+	} else {
+		logger.Warn("Signatures have no corpus manifest", zap.String("dir", dir), zap.Error(err))
+	}
+	if frequency, err := preprocessor.ReadFunctionFrequency(dir); err == nil {
+		opts.Frequency = frequency.Functions
+	}
+
+	w, err := sigindex.Create(path, opts)
+	if err != nil {
+		return err
+	}
+	err = preprocessor.ReadShards(dir, func(metadata *preprocessor.FileMetadata) error {
+		file, err := metadata.FileInfo()
+		if err != nil {
+			return err
+		}
+		return w.Add(file)
+	})
+	if err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to read signatures: %v", err)
+	}
+	stats, err := w.Close()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Signature index written",
+		zap.String("index", path),
+		zap.Int("files", stats.Files),
+		zap.Int("functions", stats.Functions),
+		zap.Int("components", stats.Components),
+		zap.Int64("bytes", stats.Bytes),
+		zap.Int64("bloom_bytes", stats.BloomBytes))
+	return nil
+}
+
+// recordComponent returns the component of a signature record, derived
+// from its path below corpus if the record predates components. Records of
+// an unknown component are kept, reported as the empty component.
+func recordComponent(corpus string, metadata *preprocessor.FileMetadata) string {
+	if metadata.Component != "" || corpus == "" {
+		return metadata.Component
+	}
+	return artifact.ComponentOf(corpus, metadata.Path)
+}
+
+// currentComponents returns the components of the corpus: the directories
+// of corpus if set, otherwise the repositories of the manifest, or nil if
+// neither names any
+func currentComponents(corpus string, m *manifest.CorpusManifest) (map[string]bool, error) {
+	components := make(map[string]bool)
+	if corpus != "" {
+		entries, err := os.ReadDir(corpus)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus directory: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				components[entry.Name()] = true
+			}
+		}
+		return components, nil
+	}
+
+	for _, repo := range m.Repositories {
+		components[repo.Name] = true
+	}
+	if len(components) == 0 {
+		logger.Warn("Corpus manifest lists no repositories, keeping all components; use --corpus to remove stale ones")
+		return nil, nil
+	}
+	return components, nil
+}
@@ -2,19 +2,52 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 
-	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/artifact"
 	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/deps"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/license"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/provenance"
+	"github.com/re-centris/re-centris-go/internal/sbom"
+	"github.com/re-centris/re-centris-go/internal/scan"
+	"github.com/re-centris/re-centris-go/internal/vuln"
+	"github.com/re-centris/re-centris-go/internal/watch"
+	"github.com/re-centris/re-centris-go/internal/webhook"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 )
 
 var detectCmd = &cobra.Command{
 	Use:   "detect [target-files...]",
 	Short: "Detect code similarities",
 	Long: `Detect code similarities between target files and known files
-using TLSH hash comparison.`,
-	Args: cobra.MinimumNArgs(1),
+using TLSH hash comparison.
+
+With --watch, the files of a directory are detected and then re-detected
+whenever they change, printing new matches as they appear.
+
+With --manifest, the targets of a scan manifest are detected in one run
+against the known files loaded once, writing one result file per target
+to --results-dir and a summary of all targets to --output.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if watch, _ := cmd.Flags().GetString("watch"); watch != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		if manifest, _ := cmd.Flags().GetString("manifest"); manifest != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	RunE: runDetect,
 }
 
@@ -23,31 +56,128 @@ func init() {
 
 	detectCmd.Flags().StringP("known-files", "k", "./known-files", "Directory containing known files")
 	detectCmd.Flags().StringP("output", "o", "detection-results.json", "Output file for detection results")
-	detectCmd.Flags().IntP("workers", "w", 5, "Number of parallel workers")
+	detectCmd.Flags().IntP("workers", "w", 0, "Number of parallel workers (0 = available CPUs)")
 	detectCmd.Flags().Float64P("threshold", "t", 0.8, "Similarity threshold (0.0-1.0)")
+	detectCmd.Flags().Int("function-threshold", 30, "Maximum TLSH distance for function matches")
+	detectCmd.Flags().String("signatures", "", "Directory with sharded preprocessor output to use instead of known files")
+	detectCmd.Flags().String("snapshot", "", "Corpus snapshot ID in snapshot.store to use instead of known files, see snapshot")
+	detectCmd.Flags().String("index", "", "Known files index to load and update instead of analyzing known files, see index build")
+	detectCmd.Flags().String("signature-index", "", "Signature index to map into memory and search instead of loading signatures, see db index")
+	detectCmd.Flags().Int("batch-size", 0, "Stream known files in batches of this size to bound memory (0 = load all)")
+	detectCmd.Flags().String("format", "json", "Output format (json, jsonl, sarif, csv, markdown, github, github-check); - writes to stdout")
+	detectCmd.Flags().Bool("licenses", true, "Report licenses of detected components and license conflicts")
+	detectCmd.Flags().String("target-license", "", "SPDX license ID of the target, detected from a target directory if empty")
+	detectCmd.Flags().Bool("with-vulns", false, "Attach known vulnerabilities of detected components from OSV")
+	detectCmd.Flags().Bool("provenance", false, "Attach the commit, author and date introducing the matched code of targets in git repositories")
+	detectCmd.Flags().String("baseline", "", "Baseline file of accepted matches to suppress from the results")
+	detectCmd.Flags().Bool("update-baseline", false, "Regenerate the baseline file from the matches of this run")
+	detectCmd.Flags().String("target-root", "", "Directory baseline entries name target files relative to (default is the working directory)")
+	detectCmd.Flags().StringSlice("fail-on", nil, "Exit with the fail exit code if a result satisfies a rule, e.g. similarity>=0.9 (repeatable)")
+	detectCmd.Flags().Int("fail-exit-code", 2, "Exit code used when a --fail-on rule is satisfied")
+	detectCmd.Flags().StringSlice("webhook", nil, "Webhook URL notified with a summary when the run finishes (repeatable)")
+	detectCmd.Flags().String("watch", "", "Watch a directory and re-detect files as they change")
+	detectCmd.Flags().Bool("exact-first", false, "Report only the verbatim copies of files that have any, found by SHA-256 before the TLSH comparison")
+	detectCmd.Flags().StringSlice("clone-types", nil, "Report only matches of these clone types (exact, renamed, near-miss)")
+	detectCmd.Flags().Bool("diff-snippets", false, "Add unified diffs of matched functions to the match evidence")
+	detectCmd.Flags().Int("max-function-components", 0, "Ignore functions found in more components than this when identifying components (0 = no limit)")
+	detectCmd.Flags().Int("top-k", 0, "Report only the k matches with the highest similarity across all files (0 = all)")
+	detectCmd.Flags().Float64("min-similarity", 0, "Report only matches with at least this similarity")
+	detectCmd.Flags().Int("max-matches-per-file", 0, "Report at most this many matches per target file (0 = all)")
+	detectCmd.Flags().Int("modified-min-distance", 1, "Minimum TLSH distance of a function reported as modified")
+	detectCmd.Flags().Int("modified-max-distance", 0, "Report functions this close to a known function, but not identical, as modified (0 = off)")
+	detectCmd.Flags().StringSlice("owned", nil, "Repository URL or known file path prefix of our own code in the corpus (repeatable)")
+	detectCmd.Flags().String("owned-mode", detector.OwnedExclude, "Drop matches of owned code (exclude) or label them as internal (label)")
+	detectCmd.Flags().String("labels", "", "Labels file of false positives (default labels.json in --signatures), see labels")
+	detectCmd.Flags().String("false-positive-mode", detector.FalsePositiveSuppress, "Drop matches labelled as false positives (suppress) or flag and rank them last (downrank)")
+	detectCmd.Flags().String("directories", "", "Write a summary of the target directories that are copies of a component to this file")
+	detectCmd.Flags().Float64("directory-coverage", detector.DefaultDirectoryCoverage, "Share of the files of a directory that must belong to one component")
+	detectCmd.Flags().String("dependencies", "", "Cross-reference the dependencies declared in the target directories with the detected components and write the report to this file")
+	detectCmd.Flags().String("distances", "", "Export the TLSH distances between target and known functions to this file")
+	detectCmd.Flags().String("distances-format", detector.DistancesCSV, "Format of the distance export (csv, binary)")
+	detectCmd.Flags().Int("distances-max", 0, "Export only function pairs within this TLSH distance (0 = all pairs)")
+	detectCmd.Flags().Int("distance-batch", 0, "Hash pairs compared per call of the distance backend (0 = 1048576)")
+	detectCmd.Flags().String("manifest", "", "Detect the targets of a scan manifest in one run, see scan")
+	detectCmd.Flags().String("results-dir", "./results", "Directory receiving one result file per manifest target")
+	detectCmd.Flags().String("sbom", "", "Write an SBOM of the detected components instead of the results (cyclonedx, spdx)")
 
-	viper.BindPFlag("detect.known_files", detectCmd.Flags().Lookup("known-files"))
-	viper.BindPFlag("detect.output", detectCmd.Flags().Lookup("output"))
-	viper.BindPFlag("detect.workers", detectCmd.Flags().Lookup("workers"))
-	viper.BindPFlag("detect.threshold", detectCmd.Flags().Lookup("threshold"))
+	detectCmd.Flags().String("sign-key", "", "Sign the output with this ed25519 private key (PEM), see verify")
+
+	configKey(detectCmd.Flags(), "with-vulns", "detect.vulns.enabled")
+	configKey(detectCmd.Flags(), "sign-key", "signing.key")
+	configKey(detectCmd.Flags(), "webhook", "detect.webhook_urls")
 }
 
-func runDetect(cmd *cobra.Command, args []string) error {
+func runDetect(cmd *cobra.Command, args []string) (err error) {
+	// Parse fail rules before the detection runs
+	rules, err := failRules()
+	if err != nil {
+		return err
+	}
+
 	// Create detector options
-	opts := detector.DetectorOptions{
-		MaxWorkers:         viper.GetInt("detect.workers"),
-		SimilarityThreshold: viper.GetFloat64("detect.threshold"),
-		KnownFilesDir:      viper.GetString("detect.known_files"),
-		Languages: map[string][]string{
-			"cpp":    {".c", ".cc", ".cpp", ".cxx", ".h", ".hpp"},
-			"java":   {".java"},
-			"python": {".py"},
-		},
+	opts := detectorOptions()
+	if id := viper.GetString("detect.snapshot"); id != "" {
+		if err := useSnapshot(&opts, id); err != nil {
+			return err
+		}
+	}
+	outputFile := viper.GetString("detect.output")
+	run := startRun("detect", detectInputs(args, opts), runManifestPath(outputFile))
+	run.Snapshot = opts.CorpusSnapshot
+	defer func() { finishRun(err) }()
+	if err := detector.ParseCloneTypes(opts.CloneTypes); err != nil {
+		return err
+	}
+	if err := detector.ParseOwnedMode(opts.OwnedMode); err != nil {
+		return err
+	}
+	if err := detector.ParseFalsePositiveMode(opts.FalsePositiveMode); err != nil {
+		return err
+	}
+	if path := labelsPath(); path != "" {
+		if opts.FalsePositives, err = detector.LoadLabels(path); err != nil {
+			return err
+		}
+		run.Inputs = append(run.Inputs, path)
+	}
+	if opts.Progress, err = progressReporter(); err != nil {
+		return err
+	}
+
+	// Export function distances computed during the detection
+	if path := viper.GetString("detect.distances"); path != "" {
+		if opts.Distances, err = detector.NewDistanceWriter(path, viper.GetString("detect.distances_format"), viper.GetInt("detect.distances_max")); err != nil {
+			return err
+		}
+		defer closeDistances(opts.Distances, path)
+		run.Outputs = append(run.Outputs, path)
+	}
+
+	// Write JSONL results as each target file completes
+	watchDir, _ := cmd.Flags().GetString("watch")
+	var stream *streamWriter
+	if viper.GetString("detect.format") == detector.FormatJSONL && viper.GetString("detect.sbom") == "" &&
+		watchDir == "" && viper.GetString("detect.manifest") == "" {
+		if err := checkStreaming(); err != nil {
+			return err
+		}
+		stream = &streamWriter{rules: rules, components: make(map[string]bool)}
+		opts.Output = stream
 	}
 
 	// Create detector
 	d := detector.New(opts)
 
+	if watchDir != "" {
+		return watchDirectory(d, opts, watchDir)
+	}
+	if path := viper.GetString("detect.manifest"); path != "" {
+		return detectManifest(run, opts, path)
+	}
+	if stream != nil {
+		return detectStreaming(cmd, run, d, stream, args, opts)
+	}
+
 	// Detect similarities
 	logger.Info("Starting similarity detection",
 		zap.Int("target_files", len(args)),
@@ -57,15 +187,674 @@ func runDetect(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	run.CorpusManifest = d.CorpusManifest()
+
+	// Report licenses and license conflicts
+	if viper.GetBool("detect.licenses") {
+		if err := annotateLicenses(d, args, results, opts); err != nil {
+			return err
+		}
+	}
+
+	// Suppress accepted matches
+	if path := viper.GetString("detect.baseline"); path != "" {
+		if err := applyBaseline(d, results, path); err != nil {
+			return err
+		}
+	} else if viper.GetBool("detect.update_baseline") {
+		return fmt.Errorf("--update-baseline requires --baseline")
+	}
+
+	// Attach known vulnerabilities
+	if viper.GetBool("detect.vulns.enabled") {
+		if err := enrichVulnerabilities(results, opts); err != nil {
+			return err
+		}
+	}
+
+	// Attach the commits introducing the matched code
+	if viper.GetBool("detect.provenance") {
+		if err := provenance.Annotate(context.Background(), results); err != nil {
+			return err
+		}
+	}
 
 	// Save results
+	recordSummary(run, d.Summarize(results))
+	if viper.GetString("detect.sbom") != "" {
+		err = writeSBOM(args, results, opts, outputFile)
+	} else {
+		err = d.WriteResults(results, viper.GetString("detect.format"), outputFile)
+	}
+	if err != nil {
+		return err
+	}
+	if err := signOutput(outputFile); err != nil {
+		return err
+	}
+	run.Outputs = append(run.Outputs, outputFile)
+
+	logger.Info("Similarity detection completed",
+		zap.String("output_file", outputFile))
+
+	// Report vendored directories once instead of per file
+	if path := viper.GetString("detect.directories"); path != "" {
+		if err := writeDirectorySummaries(results, path); err != nil {
+			return err
+		}
+		run.Outputs = append(run.Outputs, path)
+	}
+
+	// Compare declared dependencies with the detected components
+	if path := viper.GetString("detect.dependencies"); path != "" {
+		if err := writeDependencyReport(args, results, opts, path); err != nil {
+			return err
+		}
+		run.Outputs = append(run.Outputs, path)
+	}
+
+	// Notify webhooks; a failed delivery does not fail the run
+	if err := notifyWebhooks(results, opts); err != nil {
+		logger.Warn("Webhook notification failed", zap.Error(err))
+	}
+
+	return checkFailRules(cmd, results, rules)
+}
+
+// useSnapshot loads the known files from a snapshot of the store,
+// checking it out if needed
+func useSnapshot(opts *detector.DetectorOptions, id string) error {
+	if viper.GetString("detect.signatures") != "" || viper.GetString("detect.index") != "" {
+		return fmt.Errorf("--snapshot cannot be combined with --signatures or --index")
+	}
+	store := snapshotStore()
+	snap, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+	if opts.SignatureDir, err = store.Checkout(snap.ID); err != nil {
+		return err
+	}
+	opts.CorpusSnapshot = snap.ID
+
+	logger.Info("Using corpus snapshot",
+		zap.String("id", snap.ID),
+		zap.String("dir", opts.SignatureDir))
+	return nil
+}
+
+// detectInputs returns the input paths of a detection: the targets and
+// the corpus they are compared to
+func detectInputs(args []string, opts detector.DetectorOptions) []string {
+	inputs := append([]string{}, args...)
+	for _, path := range []string{opts.KnownFilesDir, opts.SignatureDir, opts.IndexPath, opts.SignatureIndex, viper.GetString("detect.manifest")} {
+		if path != "" {
+			inputs = append(inputs, path)
+		}
+	}
+	return inputs
+}
+
+// recordSummary records the counts of a result summary in a run manifest
+func recordSummary(run *manifest.Run, summary *detector.ResultSummary) {
+	run.Counts["results"] = summary.Results
+	run.Counts["matched_files"] = summary.MatchedFiles
+	run.Counts["matches"] = summary.Matches
+	run.Counts["components"] = len(summary.Components)
+}
+
+// streamWriter annotates the results streamed by the detector with
+// licenses and fail rule violations before writing them
+type streamWriter struct {
+	d             *detector.Detector
+	w             detector.ResultWriter
+	licenses      map[string]string
+	targetLicense string
+	rules         []detector.FailRule
+	violations    []detector.RuleViolation
+	summary       detector.ResultSummary
+	components    map[string]bool
+}
+
+// Write annotates and writes a result
+func (s *streamWriter) Write(result *detector.DetectionResult) error {
+	results := []*detector.DetectionResult{result}
+	if s.licenses != nil {
+		s.d.AnnotateLicenses(results, s.licenses, s.targetLicense)
+		logLicenseConflicts(results)
+	}
+	s.violations = append(s.violations, detector.EvaluateRules(results, s.rules)...)
+	summary := s.d.Summarize(results)
+	s.summary.Results += summary.Results
+	s.summary.MatchedFiles += summary.MatchedFiles
+	s.summary.Matches += summary.Matches
+	for _, component := range summary.Components {
+		s.components[component] = true
+	}
+	return s.w.Write(result)
+}
+
+// Close completes the output
+func (s *streamWriter) Close() error {
+	return s.w.Close()
+}
+
+// checkStreaming rejects the settings needing all results at once, which
+// JSONL results written as each target file completes cannot honor
+func checkStreaming() error {
+	var hooks []webhook.Hook
+	if err := viper.UnmarshalKey("detect.webhooks", &hooks); err != nil {
+		return fmt.Errorf("failed to parse webhooks: %v", err)
+	}
+	set := map[string]bool{
+		"detect.top_k":           viper.GetInt("detect.top_k") > 0,
+		"detect.baseline":        viper.GetString("detect.baseline") != "",
+		"detect.update_baseline": viper.GetBool("detect.update_baseline"),
+		"detect.vulns.enabled":   viper.GetBool("detect.vulns.enabled"),
+		"detect.provenance":      viper.GetBool("detect.provenance"),
+		"detect.directories":     viper.GetString("detect.directories") != "",
+		"detect.dependencies":    viper.GetString("detect.dependencies") != "",
+		"detect.webhooks":        len(hooks) > 0 || len(viper.GetStringSlice("detect.webhook_urls")) > 0,
+	}
+	var keys []string
+	for key, ok := range set {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return fmt.Errorf("%s need all results at once and cannot be used with --format jsonl", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// detectStreaming detects the targets writing each result to the JSONL
+// output as soon as its target file completes
+func detectStreaming(cmd *cobra.Command, run *manifest.Run, d *detector.Detector, stream *streamWriter, args []string, opts detector.DetectorOptions) error {
 	outputFile := viper.GetString("detect.output")
-	if err := d.SaveResults(results, outputFile); err != nil {
+	w, err := d.NewResultWriter(detector.FormatJSONL, outputFile)
+	if err != nil {
 		return err
 	}
+	stream.d, stream.w = d, w
+	if viper.GetBool("detect.licenses") {
+		if stream.licenses, stream.targetLicense, err = componentLicenses(args, opts); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	logger.Info("Starting similarity detection",
+		zap.Int("target_files", len(args)),
+		zap.String("known_files_dir", opts.KnownFilesDir),
+		zap.String("output_file", outputFile))
+
+	if _, err := d.DetectSimilarity(context.Background(), args); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := signOutput(outputFile); err != nil {
+		return err
+	}
+	run.CorpusManifest = d.CorpusManifest()
+	run.Outputs = append(run.Outputs, outputFile)
+	stream.summary.Components = make([]string, 0, len(stream.components))
+	for component := range stream.components {
+		stream.summary.Components = append(stream.summary.Components, component)
+	}
+	recordSummary(run, &stream.summary)
 
 	logger.Info("Similarity detection completed",
 		zap.String("output_file", outputFile))
 
+	return reportViolations(cmd, stream.violations)
+}
+
+// watchDirectory detects the files of dir and re-detects them as they
+// change until interrupted, rewriting the output file after every change
+func watchDirectory(d *detector.Detector, opts detector.DetectorOptions, dir string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	knownFiles, err := d.LoadKnownFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load known files: %v", err)
+	}
+
+	w := watch.New(watch.WatcherOptions{
+		Dir:       dir,
+		Detector:  d,
+		Corpus:    d.NewCorpus(ctx, knownFiles),
+		Languages: opts.Languages,
+	})
+
+	outputFile := viper.GetString("detect.output")
+	format := viper.GetString("detect.format")
+	return w.Run(ctx, func(update *watch.Update) error {
+		for _, result := range update.Results {
+			for _, match := range update.NewMatches[result.TargetFile] {
+				fmt.Printf("+ %s: %s\n", result.TargetFile, d.MatchMessage(match))
+			}
+		}
+		for _, path := range update.Removed {
+			fmt.Printf("- %s: removed\n", path)
+		}
+		if err := d.WriteResults(update.Results, format, outputFile); err != nil {
+			return err
+		}
+		return signOutput(outputFile)
+	})
+}
+
+// detectManifest detects all targets of a scan manifest, writing their
+// results to the results directory and a summary to the output file
+func detectManifest(run *manifest.Run, opts detector.DetectorOptions, path string) error {
+	m, err := scan.ReadManifest(path)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Starting batch detection",
+		zap.Int("targets", len(m.Targets)),
+		zap.String("known_files_dir", opts.KnownFilesDir))
+
+	s := scan.New(scan.ScannerOptions{
+		Detector:   opts,
+		ResultsDir: viper.GetString("detect.results_dir"),
+		Format:     viper.GetString("detect.format"),
+	})
+	report, err := s.Run(context.Background(), m)
+	if err != nil {
+		return err
+	}
+
+	// Sign the result files of the targets and the summary, which names them
+	for _, tr := range report.Targets {
+		if tr.ResultFile == "" {
+			continue
+		}
+		if err := signOutput(tr.ResultFile); err != nil {
+			return err
+		}
+	}
+	outputFile := viper.GetString("detect.output")
+	if err := scan.SaveReport(report, outputFile); err != nil {
+		return err
+	}
+	if err := signOutput(outputFile); err != nil {
+		return err
+	}
+	run.Outputs = append(run.Outputs, viper.GetString("detect.results_dir"), outputFile)
+	run.Counts["targets"] = len(report.Targets)
+	run.Counts["failed_targets"] = report.Failed()
+
+	logger.Info("Batch detection completed",
+		zap.Bool("passed", report.Passed),
+		zap.String("results_dir", viper.GetString("detect.results_dir")),
+		zap.String("output_file", outputFile))
+
+	if !report.Passed {
+		return fmt.Errorf("%d of %d targets failed", report.Failed(), len(report.Targets))
+	}
 	return nil
-} 
\ No newline at end of file
+}
+
+// notifyWebhooks posts a summary of the results to the configured webhooks.
+// Webhooks are configured as detect.webhooks entries with a url and an
+// optional secret; URLs given on the command line are signed with
+// detect.webhook_secret, or RE_CENTRIS_WEBHOOK_SECRET as before env overrides.
+func notifyWebhooks(results []*detector.DetectionResult, opts detector.DetectorOptions) error {
+	var hooks []webhook.Hook
+	if err := viper.UnmarshalKey("detect.webhooks", &hooks); err != nil {
+		return fmt.Errorf("failed to parse webhooks: %v", err)
+	}
+
+	secret := viper.GetString("detect.webhook_secret")
+	if secret == "" {
+		secret = os.Getenv("RE_CENTRIS_WEBHOOK_SECRET")
+	}
+	for _, url := range viper.GetStringSlice("detect.webhook_urls") {
+		hooks = append(hooks, webhook.Hook{URL: url, Secret: secret})
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	notifier := webhook.New(webhook.NotifierOptions{
+		Hooks:   hooks,
+		Timeout: viper.GetDuration("detect.webhook_timeout"),
+	})
+	summary := notifier.Summarize(results, func(path string) string {
+		return artifact.ComponentOf(opts.KnownFilesDir, path)
+	})
+
+	return notifier.Notify(context.Background(), summary)
+}
+
+// failRules parses the configured fail rules
+func failRules() ([]detector.FailRule, error) {
+	var rules []detector.FailRule
+	for _, s := range viper.GetStringSlice("detect.fail_on") {
+		rule, err := detector.ParseFailRule(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// checkFailRules logs the results satisfying a fail rule and returns an
+// ExitError with the fail exit code if there are any
+func checkFailRules(cmd *cobra.Command, results []*detector.DetectionResult, rules []detector.FailRule) error {
+	return reportViolations(cmd, detector.EvaluateRules(results, rules))
+}
+
+// reportViolations logs fail rule violations and returns an ExitError with
+// the fail exit code if there are any
+func reportViolations(cmd *cobra.Command, violations []detector.RuleViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	for _, v := range violations {
+		logger.Warn("Fail rule satisfied",
+			zap.String("rule", v.Rule.String()),
+			zap.String("target_file", v.TargetFile),
+			zap.String("subject", v.Subject),
+			zap.Float64("value", v.Value))
+	}
+
+	// A failed gate is not a usage error
+	cmd.SilenceUsage = true
+	return &ExitError{
+		Code: viper.GetInt("detect.fail_exit_code"),
+		Err:  fmt.Errorf("%d results satisfy fail rules", len(violations)),
+	}
+}
+
+// detectorOptions returns the detector options of the detect configuration
+func detectorOptions() detector.DetectorOptions {
+	return detector.DetectorOptions{
+		MaxWorkers:            workers("detect.workers"),
+		Pool:                  sharedPool(),
+		Cache:                 diskCache(),
+		SimilarityThreshold:   viper.GetFloat64("detect.threshold"),
+		KnownFilesDir:         viper.GetString("detect.known_files"),
+		FunctionThreshold:     viper.GetInt("detect.function_threshold"),
+		SignatureDir:          viper.GetString("detect.signatures"),
+		IndexPath:             viper.GetString("detect.index"),
+		SignatureIndex:        viper.GetString("detect.signature_index"),
+		DistanceBatch:         viper.GetInt("detect.distance_batch"),
+		BatchSize:             viper.GetInt("detect.batch_size"),
+		LanguageThresholds:    languageThresholds(),
+		Languages:             languageExtensions(),
+		Symlinks:              viper.GetString("symlinks"),
+		Include:               viper.GetStringSlice("include"),
+		Exclude:               viper.GetStringSlice("exclude"),
+		GitIgnore:             viper.GetBool("gitignore"),
+		NoSniff:               viper.GetBool("no_sniff"),
+		SniffLanguages:        viper.GetBool("sniff_languages"),
+		Normalize:             normalizeOptions(),
+		SignatureMode:         viper.GetString("signature_mode"),
+		Encoding:              viper.GetString("encoding"),
+		Conditionals:          viper.GetString("conditionals"),
+		MaxFileSize:           viper.GetInt64("max_file_size"),
+		ChunkedSize:           viper.GetInt64("chunked_size"),
+		FileTimeout:           viper.GetDuration("file_timeout"),
+		SlowFiles:             slowFileReport(),
+		CloneTypes:            viper.GetStringSlice("detect.clone_types"),
+		ExactFirst:            viper.GetBool("detect.exact_first"),
+		DiffSnippets:          viper.GetBool("detect.diff_snippets"),
+		MaxFunctionComponents: viper.GetInt("detect.max_function_components"),
+		MinSimilarity:         viper.GetFloat64("detect.min_similarity"),
+		MaxMatchesPerFile:     viper.GetInt("detect.max_matches_per_file"),
+		TopK:                  viper.GetInt("detect.top_k"),
+		ModifiedMinDistance:   viper.GetInt("detect.modified_min_distance"),
+		ModifiedMaxDistance:   viper.GetInt("detect.modified_max_distance"),
+		OwnedComponents:       viper.GetStringSlice("detect.owned"),
+		OwnedMode:             viper.GetString("detect.owned_mode"),
+		FalsePositiveMode:     viper.GetString("detect.false_positive_mode"),
+		TargetRoot:            viper.GetString("detect.target_root"),
+	}
+}
+
+// applyBaseline removes the matches accepted by the baseline file from the
+// results, regenerating the baseline first if requested
+func applyBaseline(d *detector.Detector, results []*detector.DetectionResult, path string) error {
+	var baseline *detector.Baseline
+	if viper.GetBool("detect.update_baseline") {
+		baseline = d.NewBaseline(results)
+		if err := baseline.Save(path); err != nil {
+			return err
+		}
+		logger.Info("Updated baseline",
+			zap.String("baseline", path),
+			zap.Int("entries", len(baseline.Entries)))
+	} else {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			logger.Warn("Baseline file does not exist, reporting all matches",
+				zap.String("baseline", path))
+			return nil
+		}
+		var err error
+		baseline, err = detector.LoadBaseline(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	suppressed := d.ApplyBaseline(results, baseline)
+	logger.Info("Suppressed baseline matches",
+		zap.String("baseline", path),
+		zap.Int("suppressed", suppressed))
+
+	return nil
+}
+
+// closeDistances completes the distance export; a failed export does not
+// fail the run, whose results are already written
+func closeDistances(w *detector.DistanceWriter, path string) {
+	if err := w.Close(); err != nil {
+		logger.Warn("Failed to export function distances", zap.Error(err))
+		return
+	}
+	logger.Info("Exported function distances",
+		zap.String("file", path),
+		zap.Int("pairs", w.Edges()))
+}
+
+// writeDirectorySummaries rolls the results up to directories and writes
+// the directories that are copies of a component
+func writeDirectorySummaries(results []*detector.DetectionResult, path string) error {
+	summaries := detector.AggregateDirectories(results, detector.AggregateOptions{
+		MinCoverage: viper.GetFloat64("detect.directory_coverage"),
+	})
+	for _, s := range summaries {
+		logger.Info("Directory is a copy of a component",
+			zap.String("summary", s.Summary))
+	}
+	return detector.WriteDirectorySummaries(summaries, path)
+}
+
+// writeDependencyReport cross-references the dependencies declared in the
+// target directories with the detected components and writes the report
+func writeDependencyReport(args []string, results []*detector.DetectionResult, opts detector.DetectorOptions, path string) error {
+	var declared []deps.Dependency
+	dirs := 0
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err != nil || !info.IsDir() {
+			continue
+		}
+		found, err := deps.Parse(arg)
+		if err != nil {
+			return err
+		}
+		declared = append(declared, found...)
+		dirs++
+	}
+	if dirs == 0 {
+		return fmt.Errorf("--dependencies requires a target directory")
+	}
+
+	report := deps.Correlate(declared, deps.Components(results, opts.KnownFilesDir))
+	for _, component := range report.Undeclared {
+		logger.Warn("Detected component is not declared as a dependency",
+			zap.String("component", component))
+	}
+	for _, c := range report.Declared {
+		if len(c.Copies) > 0 {
+			logger.Warn("Managed dependency is also copied into the source",
+				zap.String("component", c.Component),
+				zap.String("dependency", c.Dependency.Name),
+				zap.Int("files", len(c.Copies)))
+		}
+	}
+	for _, dep := range report.Unused {
+		logger.Info("Declared dependency was not detected in the code",
+			zap.String("dependency", dep.Name),
+			zap.String("source", dep.Source))
+	}
+	return deps.SaveReport(report, path)
+}
+
+// writeSBOM writes an SBOM of the components identified in the results
+func writeSBOM(args []string, results []*detector.DetectionResult, opts detector.DetectorOptions, outputFile string) error {
+	repos, err := corpusRepositories(opts)
+	if err != nil {
+		return err
+	}
+
+	exporter := sbom.New(sbom.ExporterOptions{
+		KnownFilesDir: opts.KnownFilesDir,
+		Repositories:  repos,
+		TargetName:    targetName(args),
+	})
+
+	switch format := viper.GetString("detect.sbom"); format {
+	case sbom.FormatCycloneDX:
+		return exporter.WriteCycloneDX(results, outputFile)
+	case sbom.FormatSPDX:
+		return exporter.WriteSPDX(results, outputFile)
+	default:
+		return fmt.Errorf("unsupported SBOM format: %s", format)
+	}
+}
+
+// enrichVulnerabilities attaches the OSV vulnerabilities of the matched
+// components to the results
+func enrichVulnerabilities(results []*detector.DetectionResult, opts detector.DetectorOptions) error {
+	repos, err := corpusRepositories(opts)
+	if err != nil {
+		return err
+	}
+
+	enricher := vuln.New(vuln.EnricherOptions{
+		Client: vuln.ClientOptions{
+			Endpoint: viper.GetString("detect.vulns.endpoint"),
+			CacheDir: viper.GetString("detect.vulns.cache_dir"),
+			CacheTTL: viper.GetDuration("detect.vulns.cache_ttl"),
+			Offline:  viper.GetBool("detect.vulns.offline"),
+		},
+		KnownFilesDir: opts.KnownFilesDir,
+		Repositories:  repos,
+	})
+
+	return enricher.Enrich(context.Background(), results)
+}
+
+// annotateLicenses adds the licenses of the target and of the matched
+// components to the results and logs license conflicts
+func annotateLicenses(d *detector.Detector, args []string, results []*detector.DetectionResult, opts detector.DetectorOptions) error {
+	licenses, targetLicense, err := componentLicenses(args, opts)
+	if err != nil {
+		return err
+	}
+
+	d.AnnotateLicenses(results, licenses, targetLicense)
+	logLicenseConflicts(results)
+	return nil
+}
+
+// componentLicenses returns the licenses of the known components and the
+// license of the target
+func componentLicenses(args []string, opts detector.DetectorOptions) (map[string]string, string, error) {
+	repos, err := corpusRepositories(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Repositories of the corpus manifest carry their license; directories
+	// of the known files are scanned directly
+	licenses := make(map[string]string)
+	if detector.CorpusDir(opts) == "" {
+		licenses = license.DetectComponents(opts.KnownFilesDir)
+	}
+	for _, repo := range repos {
+		if repo.License != "" {
+			licenses[repo.Name] = repo.License
+		}
+	}
+
+	targetLicense := viper.GetString("detect.target_license")
+	if targetLicense == "" && len(args) == 1 {
+		if info, err := os.Stat(args[0]); err == nil && info.IsDir() {
+			targetLicense = license.Detect(args[0])
+		}
+	}
+
+	return licenses, targetLicense, nil
+}
+
+// logLicenseConflicts logs the license conflicts of results
+func logLicenseConflicts(results []*detector.DetectionResult) {
+	for _, result := range results {
+		if result.License == nil {
+			continue
+		}
+		for _, conflict := range result.License.Conflicts {
+			logger.Warn("License conflict",
+				zap.String("target_file", result.TargetFile),
+				zap.String("conflict", conflict))
+		}
+	}
+}
+
+// corpusRepositories returns the repositories of the known corpus, from the
+// corpus manifest of the signatures or from the cloned repositories
+func corpusRepositories(opts detector.DetectorOptions) ([]manifest.Repository, error) {
+	if dir := detector.CorpusDir(opts); dir != "" {
+		m, _, err := manifest.Read(dir)
+		if err != nil {
+			return nil, err
+		}
+		return m.Repositories, nil
+	}
+	return manifest.CollectRepositories(context.Background(), opts.KnownFilesDir)
+}
+
+// targetName names the scanned target after a single target argument or
+// the working directory
+func targetName(args []string) string {
+	path := "."
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return filepath.Base(abs)
+	}
+	return filepath.Base(path)
+}
+
+// languageThresholds returns the per-language similarity thresholds of the config
+func languageThresholds() map[string]float64 {
+	thresholds := make(map[string]float64)
+	for language := range viper.GetStringMap("detect.thresholds") {
+		thresholds[language] = viper.GetFloat64("detect.thresholds." + language)
+	}
+	return thresholds
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff old-results new-results",
+	Short: "Compare the results of two detection runs",
+	Long: `Compare two detection result files, e.g. of two releases of a product.
+Reports added, removed and changed matches, and components that appeared,
+disappeared or changed version.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("output", "o", "results-diff.json", "Output file for the diff; - writes to stdout")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	diff, err := detector.DiffResults(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if err := detector.WriteDiff(diff, output); err != nil {
+		return err
+	}
+
+	logger.Info("Compared detection results",
+		zap.Int("added_matches", len(diff.AddedMatches)),
+		zap.Int("removed_matches", len(diff.RemovedMatches)),
+		zap.Int("changed_matches", len(diff.ChangedMatches)),
+		zap.Int("component_changes", len(diff.ComponentChanges)))
+
+	return nil
+}
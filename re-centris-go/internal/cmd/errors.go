@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// errorReportFile is the default name of the error report in the output directory
+const errorReportFile = "errors.json"
+
+// addErrorPolicyFlags adds the error policy flags of a command, bound to the
+// error_policy and error_report keys of its section
+func addErrorPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("error-policy", analyzer.ErrorPolicyFailFast, "How to handle files that fail to analyze (fail-fast, skip-and-report, max-errors=N)")
+	cmd.Flags().String("error-report", "", "File listing the skipped files (default errors.json in the output directory)")
+}
+
+// errorPolicy returns the configured error policy below the config key
+// prefix, and an error report if the policy skips failed files
+func errorPolicy(prefix string) (analyzer.ErrorPolicy, *analyzer.ErrorReport, error) {
+	policy, err := analyzer.ParseErrorPolicy(viper.GetString(prefix + ".error_policy"))
+	if err != nil {
+		return policy, nil, err
+	}
+	if !policy.Skip {
+		return policy, nil, nil
+	}
+	return policy, &analyzer.ErrorReport{}, nil
+}
+
+// writeErrorReport writes the error report of a run, if any, to the
+// configured path or into outputDir
+func writeErrorReport(report *analyzer.ErrorReport, prefix, outputDir string) error {
+	if report == nil {
+		return nil
+	}
+
+	path := viper.GetString(prefix + ".error_report")
+	if path == "" {
+		path = filepath.Join(outputDir, errorReportFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create error report directory: %v", err)
+	}
+	if err := report.WriteJSON(path); err != nil {
+		return err
+	}
+
+	if errors := report.Errors(); len(errors) > 0 {
+		logger.Warn("Some files failed to analyze",
+			zap.Int("count", len(errors)),
+			zap.String("report", path))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/index"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// defaultIndexPath is the index file used when detect.index is not set
+const defaultIndexPath = "known-files.index"
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the persistent known files index",
+}
+
+var indexBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build or update the known files index",
+	Long: `Analyze the known files directory into a persistent index, so that
+detect --index does not re-analyze it on every run. An existing index is
+updated: unchanged files are reused and changed files re-analyzed.`,
+	Args: cobra.NoArgs,
+	RunE: runIndexBuild,
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexBuildCmd)
+
+	indexBuildCmd.Flags().StringP("known-files", "k", "", "Directory containing known files (default is detect.known_files)")
+	indexBuildCmd.Flags().StringP("output", "o", "", "Index file (default is detect.index or "+defaultIndexPath+")")
+}
+
+func runIndexBuild(cmd *cobra.Command, args []string) error {
+	opts := detectorOptions()
+	if dir, _ := cmd.Flags().GetString("known-files"); dir != "" {
+		opts.KnownFilesDir = dir
+	}
+
+	path, _ := cmd.Flags().GetString("output")
+	if path == "" {
+		path = viper.GetString("detect.index")
+	}
+	if path == "" {
+		path = defaultIndexPath
+	}
+
+	x := index.New(index.IndexOptions{
+		Dir:      opts.KnownFilesDir,
+		Analyzer: opts.AnalyzerOptions(),
+	})
+	idx, stats, err := x.Update(context.Background(), index.Open(path))
+	if err != nil {
+		return err
+	}
+	if err := idx.Save(path); err != nil {
+		return err
+	}
+
+	logger.Info("Known files index written",
+		zap.String("index", path),
+		zap.Int("files", idx.Len()),
+		zap.Int("reused", stats.Reused),
+		zap.Int("analyzed", stats.Analyzed),
+		zap.Int("removed", stats.Removed))
+	return nil
+}
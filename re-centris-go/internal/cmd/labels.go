@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var labelsCmd = &cobra.Command{
+	Use:   "labels",
+	Short: "Manage matches labelled as false positives",
+	Long: `Manage the labels file of the signature database, which records matches
+labelled as false positives by the TLSH digests of the target and known
+files. detect drops these matches, or with --false-positive-mode downrank
+flags them and ranks them last, wherever the same code is detected again.
+
+The labels file is --labels, by default detect.labels or labels.json in
+detect.signatures.`,
+}
+
+var labelsImportCmd = &cobra.Command{
+	Use:   "import results-file...",
+	Short: "Label the matches flagged as false positives in result files",
+	Long: `Label the matches of detection result files whose "false_positive" field
+is set to true, e.g. by editing the file, as false positives.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runLabelsImport,
+}
+
+var labelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the matches labelled as false positives",
+	Args:  cobra.NoArgs,
+	RunE:  runLabelsList,
+}
+
+var labelsRemoveCmd = &cobra.Command{
+	Use:   "remove number...",
+	Short: "Remove labels by their number in labels list",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runLabelsRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(labelsCmd)
+	labelsCmd.AddCommand(labelsImportCmd, labelsListCmd, labelsRemoveCmd)
+
+	labelsCmd.PersistentFlags().String("labels", "", "Labels file (default is detect.labels, or labels.json in detect.signatures)")
+	labelsImportCmd.Flags().StringP("known-files", "k", "", "Directory of the known files the results were detected against (default is detect.known_files)")
+}
+
+// labelsPath returns the labels file: detect.labels, or the labels file
+// of the signature database, or "" if neither is set
+func labelsPath() string {
+	if path := viper.GetString("detect.labels"); path != "" {
+		return path
+	}
+	if dir := viper.GetString("detect.signatures"); dir != "" {
+		return filepath.Join(dir, detector.LabelsFileName)
+	}
+	return ""
+}
+
+// commandLabelsPath returns the labels file of a labels command
+func commandLabelsPath(cmd *cobra.Command) (string, error) {
+	path, _ := cmd.Flags().GetString("labels")
+	if path == "" {
+		path = labelsPath()
+	}
+	if path == "" {
+		return "", fmt.Errorf("specify the labels file with --labels, detect.labels or detect.signatures")
+	}
+	return path, nil
+}
+
+func runLabelsImport(cmd *cobra.Command, args []string) error {
+	path, err := commandLabelsPath(cmd)
+	if err != nil {
+		return err
+	}
+	labels, err := detector.LoadLabels(path)
+	if err != nil {
+		return err
+	}
+	knownFiles := viper.GetString("detect.known_files")
+	if dir, _ := cmd.Flags().GetString("known-files"); dir != "" {
+		knownFiles = dir
+	}
+	d := detector.New(detector.DetectorOptions{KnownFilesDir: knownFiles})
+
+	added, unhashed := 0, 0
+	for _, file := range args {
+		err := detector.ReadResults(file, func(result *detector.DetectionResult) error {
+			if result.Hash == "" {
+				for _, match := range result.Matches {
+					if match.FalsePositive {
+						unhashed++
+					}
+				}
+			}
+			added += d.LabelResults([]*detector.DetectionResult{result}, labels)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if unhashed > 0 {
+		logger.Warn("Skipped false positives of results without a target hash, re-run detect to label them",
+			zap.Int("matches", unhashed))
+	}
+	if err := labels.Save(path); err != nil {
+		return err
+	}
+
+	logger.Info("Imported false positive labels",
+		zap.String("labels", path),
+		zap.Int("added", added),
+		zap.Int("total", len(labels.Labels)))
+	return nil
+}
+
+func runLabelsList(cmd *cobra.Command, args []string) error {
+	path, err := commandLabelsPath(cmd)
+	if err != nil {
+		return err
+	}
+	labels, err := detector.LoadLabels(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-4s %-20s %-32s %-32s %s\n", "#", "COMPONENT", "TARGET", "KNOWN FILE", "LABELED")
+	for i, label := range labels.Labels {
+		fmt.Printf("%-4d %-20s %-32s %-32s %s\n", i+1, label.Component, label.Target, label.KnownFile, label.LabeledAt.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func runLabelsRemove(cmd *cobra.Command, args []string) error {
+	path, err := commandLabelsPath(cmd)
+	if err != nil {
+		return err
+	}
+	labels, err := detector.LoadLabels(path)
+	if err != nil {
+		return err
+	}
+
+	// Look the labels up before removing any, so numbers do not shift
+	var remove []detector.Label
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(labels.Labels) {
+			return fmt.Errorf("invalid label number: %s", arg)
+		}
+		remove = append(remove, labels.Labels[n-1])
+	}
+	for _, label := range remove {
+		labels.Remove(label.TargetHash, label.KnownHash)
+	}
+	if err := labels.Save(path); err != nil {
+		return err
+	}
+
+	logger.Info("Removed false positive labels",
+		zap.String("labels", path),
+		zap.Int("removed", len(remove)),
+		zap.Int("total", len(labels.Labels)))
+	return nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge [result-files...]",
+	Short: "Merge detection result files",
+	Long: `Merge several detection result files into a single file.
+Results are streamed, so the inputs may be larger than memory.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMerge,
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().StringP("output", "o", "merged_results.json", "Output file for merged results")
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	count, err := detector.MergeResults(output, args)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Merged detection results",
+		zap.Int("inputs", len(args)),
+		zap.Int("results", count),
+		zap.String("output", output))
+
+	return nil
+}
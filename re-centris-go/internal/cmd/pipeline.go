@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var pipelineCmd = &cobra.Command{
+	Use:     "pipeline [target-directory]",
+	Aliases: []string{"run"},
+	Short:   "Run the full workflow",
+	Long: `Run the full workflow (clone, collect, preprocess, detect) driven by
+the config file. Use --from and --until to run only part of the stages.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPipeline,
+}
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+
+	pipelineCmd.Flags().String("from", string(pipeline.StageClone), "First stage to run (clone, collect, preprocess, detect)")
+	pipelineCmd.Flags().String("until", string(pipeline.StageDetect), "Last stage to run (clone, collect, preprocess, detect)")
+	pipelineCmd.Flags().String("repo-list", "./repo_list.txt", "File containing repository URLs to clone")
+	pipelineCmd.Flags().String("preprocess-output", "./data/preprocessed", "Output directory for preprocessed metadata")
+	pipelineCmd.Flags().IntP("workers", "w", 0, "Number of parallel workers (0 = available CPUs)")
+
+	configKey(pipelineCmd.Flags(), "repo-list", "clone.repo_list")
+	configKey(pipelineCmd.Flags(), "preprocess-output", "preprocess.output")
+}
+
+func runPipeline(cmd *cobra.Command, args []string) error {
+	from, err := pipeline.ParseStage(cmd.Flag("from").Value.String())
+	if err != nil {
+		return err
+	}
+	until, err := pipeline.ParseStage(cmd.Flag("until").Value.String())
+	if err != nil {
+		return err
+	}
+
+	// Target directory from arguments overrides the config file
+	targetDir := viper.GetString("pipeline.target")
+	if len(args) > 0 {
+		targetDir = args[0]
+	}
+
+	opts := pipeline.PipelineOptions{
+		RepoListFile:          viper.GetString("clone.repo_list"),
+		RepoDir:               viper.GetString("clone.output"),
+		PreprocessDir:         viper.GetString("preprocess.output"),
+		TargetDir:             targetDir,
+		ResultFile:            viper.GetString("detect.output"),
+		MaxWorkers:            workers("pipeline.workers"),
+		Languages:             languageExtensions(),
+		SimilarityThreshold:   viper.GetFloat64("detect.threshold"),
+		LanguageThresholds:    languageThresholds(),
+		FunctionThreshold:     viper.GetInt("detect.function_threshold"),
+		Resume:                viper.GetBool("preprocess.resume"),
+		ConfigHash:            manifest.HashConfig(viper.AllSettings()),
+		From:                  from,
+		Until:                 until,
+		Symlinks:              viper.GetString("symlinks"),
+		Include:               viper.GetStringSlice("include"),
+		Exclude:               viper.GetStringSlice("exclude"),
+		GitIgnore:             viper.GetBool("gitignore"),
+		NoSniff:               viper.GetBool("no_sniff"),
+		SniffLanguages:        viper.GetBool("sniff_languages"),
+		Normalize:             normalizeOptions(),
+		SignatureMode:         viper.GetString("signature_mode"),
+		Encoding:              viper.GetString("encoding"),
+		Conditionals:          viper.GetString("conditionals"),
+		MaxFileSize:           viper.GetInt64("max_file_size"),
+		ChunkedSize:           viper.GetInt64("chunked_size"),
+		FileTimeout:           viper.GetDuration("file_timeout"),
+		SlowFiles:             slowFileReport(),
+		MaxConcurrency:        viper.GetInt("max_concurrency"),
+		Cache:                 diskCache(),
+		MinFunctionLines:      viper.GetInt("preprocess.min_function_lines"),
+		MinFunctionTokens:     viper.GetInt("preprocess.min_function_tokens"),
+		MinFunctionComplexity: viper.GetInt("preprocess.min_function_complexity"),
+		StoreBodies:           viper.GetBool("preprocess.store_bodies"),
+	}
+
+	logger.Info("Starting pipeline",
+		zap.String("from", string(from)),
+		zap.String("until", string(until)))
+
+	if err := pipeline.New(opts).Run(context.Background()); err != nil {
+		return err
+	}
+
+	logger.Info("Pipeline completed",
+		zap.String("output_file", opts.ResultFile))
+
+	return nil
+}
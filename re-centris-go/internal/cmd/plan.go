@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [target-dir]",
+	Short: "Preview the scope of a scan",
+	Long: `Show the files per language, total lines of code and the expected
+number of comparisons against the known corpus for a target directory,
+together with an estimate of the scan duration. The corpus is taken from
+the detect configuration.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPlan,
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	opts := detectorOptions()
+
+	a := analyzer.New(analyzer.AnalyzerOptions{
+		MaxWorkers: opts.MaxWorkers,
+		Languages:  opts.Languages,
+	})
+
+	// Count the known files per language
+	known, err := knownFileCounts(ctx, a, opts.SignatureDir, opts.KnownFilesDir)
+	if err != nil {
+		logger.Warn("Failed to count known files, comparisons are not estimated",
+			zap.Error(err))
+	}
+
+	plan, err := a.PlanDirectory(ctx, args[0], known)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "LANGUAGE\tFILES\tLINES\tBYTES\tKNOWN FILES\tCOMPARISONS\t")
+	for _, s := range plan.Languages {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t\n", s.Language, s.Files, s.Lines, s.Bytes, s.KnownFiles, s.Comparisons)
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t%d\t\t%d\t\n", plan.TotalFiles, plan.TotalLines, plan.TotalBytes, plan.Comparisons)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nEstimated duration: %s with %d workers\n", plan.Estimated.Round(time.Millisecond), opts.MaxWorkers)
+	return nil
+}
+
+// knownFileCounts counts the known files per language in the signature
+// directory if set, otherwise in the known files directory
+func knownFileCounts(ctx context.Context, a *analyzer.Analyzer, signatureDir, knownDir string) (map[string]int, error) {
+	if signatureDir == "" {
+		return a.CountFiles(ctx, knownDir)
+	}
+
+	index, err := preprocessor.ReadShardIndex(signatureDir)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for path := range index.Files {
+		if language := a.Language(path); language != "" {
+			counts[language]++
+		}
+	}
+	return counts, nil
+}
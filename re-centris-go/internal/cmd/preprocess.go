@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var preprocessCmd = &cobra.Command{
+	Use:   "preprocess [directory]",
+	Short: "Preprocess source code files",
+	Long: `Preprocess source code files in a directory and write signature
+metadata for every file. Interrupted runs can be continued with --resume.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPreprocess,
+}
+
+func init() {
+	rootCmd.AddCommand(preprocessCmd)
+
+	preprocessCmd.Flags().StringP("output", "o", "./data/preprocessed", "Output directory for preprocessed metadata")
+	preprocessCmd.Flags().IntP("workers", "w", 0, "Number of parallel workers (0 = available CPUs)")
+	preprocessCmd.Flags().Bool("resume", false, "Resume an interrupted run from its last checkpoint")
+	preprocessCmd.Flags().Int("checkpoint-interval", 1000, "Number of files processed between checkpoints")
+	preprocessCmd.Flags().String("format", "json", "Output format (json, sharded, parquet)")
+	preprocessCmd.Flags().Int64("shard-size", 64<<20, "Uncompressed size of a shard in bytes for sharded output")
+	preprocessCmd.Flags().Bool("versions", false, "Collect function signatures of every tagged version of each repository")
+	preprocessCmd.Flags().Int("max-versions", 0, "Most recent versions collected per repository (0 = all)")
+	preprocessCmd.Flags().Bool("store-bodies", false, "Store function bodies once each, content-addressed, in <output>/bodies")
+	preprocessCmd.Flags().Int("min-function-lines", 0, "Omit functions spanning fewer lines from the signatures (0 = keep all)")
+	preprocessCmd.Flags().Int("min-function-tokens", 0, "Omit functions with fewer tokens from the signatures (0 = keep all)")
+	preprocessCmd.Flags().Int("min-function-complexity", 0, "Omit functions of lower cyclomatic complexity from the signatures (0 = keep all)")
+
+	addErrorPolicyFlags(preprocessCmd)
+}
+
+func runPreprocess(cmd *cobra.Command, args []string) (err error) {
+	// Get source directory
+	sourceDir := args[0]
+	outputDir := viper.GetString("preprocess.output")
+
+	run := startRun("preprocess", args, filepath.Join(outputDir, manifest.RunFileName))
+	defer func() { finishRun(err) }()
+
+	reporter, err := progressReporter()
+	if err != nil {
+		return err
+	}
+
+	policy, errorReport, err := errorPolicy("preprocess")
+	if err != nil {
+		return err
+	}
+
+	// Create preprocessor
+	p := preprocessor.New(preprocessor.PreprocessorOptions{
+		MaxWorkers:            workers("preprocess.workers"),
+		Pool:                  sharedPool(),
+		Cache:                 diskCache(),
+		OutputDir:             outputDir,
+		Languages:             languageExtensions(),
+		Resume:                viper.GetBool("preprocess.resume"),
+		CheckpointInterval:    viper.GetInt("preprocess.checkpoint_interval"),
+		OutputFormat:          viper.GetString("preprocess.format"),
+		ShardSize:             viper.GetInt64("preprocess.shard_size"),
+		ConfigHash:            run.ConfigHash,
+		Progress:              reporter,
+		Symlinks:              viper.GetString("symlinks"),
+		Include:               viper.GetStringSlice("include"),
+		Exclude:               viper.GetStringSlice("exclude"),
+		GitIgnore:             viper.GetBool("gitignore"),
+		NoSniff:               viper.GetBool("no_sniff"),
+		SniffLanguages:        viper.GetBool("sniff_languages"),
+		Normalize:             normalizeOptions(),
+		SignatureMode:         viper.GetString("signature_mode"),
+		Encoding:              viper.GetString("encoding"),
+		Conditionals:          viper.GetString("conditionals"),
+		MaxFileSize:           viper.GetInt64("max_file_size"),
+		ChunkedSize:           viper.GetInt64("chunked_size"),
+		FileTimeout:           viper.GetDuration("file_timeout"),
+		SlowFiles:             slowFileReport(),
+		ErrorPolicy:           policy,
+		Errors:                errorReport,
+		Versions:              viper.GetBool("preprocess.versions"),
+		MaxVersions:           viper.GetInt("preprocess.max_versions"),
+		MinFunctionLines:      viper.GetInt("preprocess.min_function_lines"),
+		MinFunctionTokens:     viper.GetInt("preprocess.min_function_tokens"),
+		MinFunctionComplexity: viper.GetInt("preprocess.min_function_complexity"),
+		StoreBodies:           viper.GetBool("preprocess.store_bodies"),
+	})
+
+	// Preprocess directory
+	logger.Info("Starting preprocessing",
+		zap.String("directory", sourceDir))
+
+	err = p.ProcessDirectory(context.Background(), sourceDir)
+	if reportErr := writeErrorReport(errorReport, "preprocess", outputDir); reportErr != nil {
+		logger.Error("Failed to write error report", zap.Error(reportErr))
+	}
+	if err != nil {
+		return err
+	}
+
+	run.Outputs = append(run.Outputs, outputDir)
+	if m, hash, err := manifest.Read(outputDir); err == nil {
+		run.CorpusManifest = hash
+		run.Counts["repositories"] = len(m.Repositories)
+		run.Counts["files"] = m.TotalFiles
+		run.Counts["functions"] = m.TotalFunctions
+	}
+
+	logSkipStats(p.SkipStats())
+	logger.Info("Preprocessing completed",
+		zap.String("output", outputDir))
+
+	return nil
+}
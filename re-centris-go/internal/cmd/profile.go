@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// profiler holds the profiles started for the running command
+type profiler struct {
+	cpu    *os.File
+	server *http.Server
+}
+
+// profiling is the profiler of the running command, stopped by Execute
+var profiling *profiler
+
+// startProfiling starts the profiles selected by the pprof, cpuprofile and
+// memprofile flags
+func startProfiling() error {
+	p := &profiler{}
+	profiling = p
+
+	if addr := viper.GetString("pprof"); addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to start pprof server: %v", err)
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		p.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		logger.Info("pprof server listening",
+			zap.String("addr", listener.Addr().String()))
+		go func() {
+			if err := p.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				logger.Warn("pprof server failed", zap.Error(err))
+			}
+		}()
+	}
+
+	if path := viper.GetString("cpuprofile"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create CPU profile: %v", err)
+		}
+		if err := runtimepprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to start CPU profile: %v", err)
+		}
+		p.cpu = file
+	}
+
+	return nil
+}
+
+// stopProfiling stops the CPU profile and pprof server and writes the heap
+// profile. It is a no-op if no profiling was started.
+func stopProfiling() {
+	p := profiling
+	if p == nil {
+		return
+	}
+	profiling = nil
+
+	if p.cpu != nil {
+		runtimepprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			logger.Error("Failed to write CPU profile", zap.Error(err))
+		}
+	}
+
+	if path := viper.GetString("memprofile"); path != "" {
+		if err := writeHeapProfile(path); err != nil {
+			logger.Error("Failed to write memory profile", zap.Error(err))
+		}
+	}
+
+	if p.server != nil {
+		p.server.Close()
+	}
+}
+
+// writeHeapProfile writes a heap profile reflecting all completed garbage
+// collections to path
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	runtime.GC()
+	return runtimepprof.WriteHeapProfile(file)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Look up the known signatures nearest to a hash or file",
+	Long: `Print the known files and functions nearest to a TLSH hash (--hash), to a
+file (--file) or to a function of it (--file with --function), with their
+distances, without a full detect run. Known files are loaded as detect
+loads them, from --known-files, --signatures or --snapshot.`,
+	Args: cobra.NoArgs,
+	RunE: runQuery,
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().String("hash", "", "TLSH hash to look up")
+	queryCmd.Flags().String("file", "", "File whose hash is looked up")
+	queryCmd.Flags().String("function", "", "Look up this function of --file instead of the whole file")
+	queryCmd.Flags().IntP("top", "n", 10, "Nearest files and functions printed of each kind (0 = all)")
+	queryCmd.Flags().Int("max-distance", 0, "Only print signatures within this distance (0 = any)")
+	queryCmd.Flags().String("format", "text", "Output format (text, json)")
+	queryCmd.Flags().StringP("known-files", "k", "", "Directory containing known files (default is detect.known_files)")
+	queryCmd.Flags().String("signatures", "", "Sharded preprocessor output to use instead of known files (default is detect.signatures)")
+	queryCmd.Flags().String("snapshot", "", "Corpus snapshot ID to use instead of known files (default is detect.snapshot)")
+}
+
+// queryResult is the JSON output of query
+type queryResult struct {
+	Hash      string              `json:"hash"`
+	Files     []detector.Neighbor `json:"files"`
+	Functions []detector.Neighbor `json:"functions"`
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	hashFlag, _ := cmd.Flags().GetString("hash")
+	file, _ := cmd.Flags().GetString("file")
+	function, _ := cmd.Flags().GetString("function")
+	top, _ := cmd.Flags().GetInt("top")
+	maxDistance, _ := cmd.Flags().GetInt("max-distance")
+	format, _ := cmd.Flags().GetString("format")
+	switch {
+	case (hashFlag == "") == (file == ""):
+		return fmt.Errorf("specify either --hash or --file")
+	case function != "" && file == "":
+		return fmt.Errorf("--function requires --file")
+	case format != "text" && format != "json":
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+
+	opts := detectorOptions()
+	if dir, _ := cmd.Flags().GetString("known-files"); dir != "" {
+		opts.KnownFilesDir = dir
+	}
+	if dir, _ := cmd.Flags().GetString("signatures"); dir != "" {
+		opts.SignatureDir = dir
+	}
+	if id, _ := cmd.Flags().GetString("snapshot"); id != "" {
+		viper.Set("detect.snapshot", id)
+	}
+	if id := viper.GetString("detect.snapshot"); id != "" {
+		if err := useSnapshot(&opts, id); err != nil {
+			return err
+		}
+	}
+
+	// Compute the queried hash; files are compared to known files and
+	// functions to known functions, a bare hash to both
+	var hash *tlsh.TLSH
+	files, functions := true, true
+	var err error
+	if hashFlag != "" {
+		if hash, err = tlsh.Parse(hashFlag); err != nil {
+			return fmt.Errorf("invalid hash: %v", err)
+		}
+	} else {
+		if hash, err = queryFileHash(opts, file, function); err != nil {
+			return err
+		}
+		files, functions = function == "", function != ""
+	}
+
+	d := detector.New(opts)
+	ctx := context.Background()
+	knownFiles, err := d.LoadKnownFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load known files: %v", err)
+	}
+	corpus := d.NewCorpus(ctx, knownFiles)
+
+	result := queryResult{Hash: hash.String(), Files: []detector.Neighbor{}, Functions: []detector.Neighbor{}}
+	nearFiles, nearFunctions := d.Nearest(corpus, hash, top, maxDistance)
+	if files {
+		result.Files = nearFiles
+	}
+	if functions {
+		result.Functions = nearFunctions
+	}
+
+	logger.Debug("Queried known signatures",
+		zap.String("hash", result.Hash),
+		zap.Int("known_files", corpus.Files()))
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	printNeighbors(result)
+	return nil
+}
+
+// queryFileHash analyzes a file and returns its hash, or that of the named
+// function of it
+func queryFileHash(opts detector.DetectorOptions, path, function string) (*tlsh.TLSH, error) {
+	info, err := analyzer.New(opts.AnalyzerOptions()).AnalyzeFile(context.Background(), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze %s: %v", path, err)
+	}
+	if function == "" {
+		if info.Hash == nil {
+			return nil, fmt.Errorf("%s is too small or uniform to be hashed", path)
+		}
+		return info.Hash, nil
+	}
+
+	for _, fn := range info.Functions {
+		if fn.Name != function {
+			continue
+		}
+		if fn.Hash == "" {
+			return nil, fmt.Errorf("function %s of %s is too small to be hashed", function, path)
+		}
+		return tlsh.Parse(fn.Hash)
+	}
+	return nil, fmt.Errorf("function %s not found in %s", function, path)
+}
+
+// printNeighbors prints the result of a query as tables
+func printNeighbors(result queryResult) {
+	fmt.Printf("Hash %s\n", result.Hash)
+	if len(result.Files) > 0 {
+		fmt.Printf("\n%-8s %-20s %s\n", "DISTANCE", "COMPONENT", "FILE")
+		for _, n := range result.Files {
+			fmt.Printf("%-8d %-20s %s\n", n.Distance, n.Component, n.File)
+		}
+	}
+	if len(result.Functions) > 0 {
+		fmt.Printf("\n%-8s %-20s %-24s %s\n", "DISTANCE", "COMPONENT", "FUNCTION", "LOCATION")
+		for _, n := range result.Functions {
+			fmt.Printf("%-8d %-20s %-24s %s:%d-%d\n", n.Distance, n.Component, n.Function, n.File, n.StartLine, n.EndLine)
+		}
+	}
+	if len(result.Files) == 0 && len(result.Functions) == 0 {
+		fmt.Println("No known signatures found")
+	}
+}
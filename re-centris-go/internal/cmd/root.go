@@ -1,26 +1,54 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser/cpp"
+	"github.com/re-centris/re-centris-go/internal/common/cache"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/common/progress"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"github.com/re-centris/re-centris-go/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
 var (
 	cfgFile string
+	profile string
 	rootCmd = &cobra.Command{
 		Use:   "re-centris",
 		Short: "Re-Centris is a code analysis and dependency detection tool",
 		Long: `Re-Centris is a tool based on TLSH (Trend Micro Locality Sensitive Hash) 
 for analyzing source code and detecting dependencies. It can identify open source 
 components used in codebases, detect code clones, and analyze dependencies.`,
+		PersistentPreRunE: validateGlobalFlags,
 	}
 )
 
+// ExitError is an error that exits the program with a specific code, such as
+// a failed CI gate
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
+	defer stopProfiling()
 	return rootCmd.Execute()
 }
 
@@ -28,8 +56,38 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.re-centris.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Config file profile to apply, e.g. firmware (or RE_CENTRIS_PROFILE)")
+	rootCmd.PersistentFlags().Bool("debug", false, "Enable debug logging")
+	rootCmd.PersistentFlags().String("progress", progress.ModeAuto, "Progress output on stderr (auto, bar, json, none)")
+	rootCmd.PersistentFlags().String("symlinks", analyzer.SymlinkFollow, "Symbolic links in analyzed directories (follow, skip)")
+	rootCmd.PersistentFlags().StringSlice("include", nil, "Only analyze files matching these patterns (.gitignore syntax)")
+	rootCmd.PersistentFlags().StringSlice("exclude", analyzer.DefaultExcludes, "Skip files and directories matching these patterns (.gitignore syntax)")
+	rootCmd.PersistentFlags().Bool("gitignore", false, "Skip paths ignored by .gitignore files")
+	rootCmd.PersistentFlags().Bool("no-sniff", false, "Analyze binary, minified and generated files instead of skipping them")
+	rootCmd.PersistentFlags().Bool("sniff-languages", false, "Detect the language of files without a supported extension from their content")
+	rootCmd.PersistentFlags().String("signature-mode", analyzer.SignatureBytes, "What signatures are computed over (bytes, tokens)")
+	rootCmd.PersistentFlags().Int64("max-file-size", 0, "Skip files larger than this many bytes (0 = no limit)")
+	rootCmd.PersistentFlags().Int64("chunked-size", analyzer.DefaultChunkedSize, "Hash files of at least this many bytes in chunks, without normalization or functions (0 = never)")
+	rootCmd.PersistentFlags().Duration("file-timeout", 0, "Stop the analysis of a file taking longer and skip it (0 = no limit)")
+	rootCmd.PersistentFlags().Duration("slow-file-threshold", 10*time.Second, "Record files taking at least this long to analyze in the slow_files of the run manifest (0 = only timeouts)")
+	rootCmd.PersistentFlags().String("encoding", analyzer.EncodingAuto, "Encoding of sources that are not UTF-8: auto detects it, raw hashes bytes as read, or a charset such as gbk or latin1")
+	rootCmd.PersistentFlags().String("conditionals", cpp.ConditionalsStrip, "Conditional compilation in C/C++ sources: strip keeps the first branch of each #if, all keeps every branch")
+	rootCmd.PersistentFlags().Int("max-concurrency", 0, "Number of tasks all stages run at once (0 = available CPUs)")
+	rootCmd.PersistentFlags().Bool("fsync", false, "Flush outputs to disk before renaming them into place, so they survive a power loss")
+	rootCmd.PersistentFlags().String("run-manifest", "", "Write the run manifest of analyze, preprocess and detect to this file (default next to the output)")
+	rootCmd.PersistentFlags().String("cache-dir", "", "Cache file analyses in this directory across runs")
+	rootCmd.PersistentFlags().String("pprof", "", "Serve pprof profiles on this address, e.g. :6060")
+	rootCmd.PersistentFlags().String("cpuprofile", "", "Write a CPU profile to this file")
+	rootCmd.PersistentFlags().String("memprofile", "", "Write a heap profile to this file when the command exits")
+	rootCmd.PersistentFlags().StringArray("set", nil, "Set a config key, e.g. detect.vulns.endpoint=URL (repeatable, overrides flags)")
+
+	configKey(rootCmd.PersistentFlags(), "cache-dir", "cache.dir")
 }
 
+// configErr is the error loading the config file, reported before the
+// command runs
+var configErr error
+
 func initConfig() {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
@@ -42,9 +100,166 @@ func initConfig() {
 		viper.SetConfigName(".re-centris")
 	}
 
-	viper.AutomaticEnv()
+	if profile == "" {
+		profile = os.Getenv(envPrefix + "_PROFILE")
+	}
 
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		configErr = loadConfig(viper.ConfigFileUsed(), profile)
+	} else if profile != "" {
+		configErr = fmt.Errorf("no config file found to select profile %s from", profile)
+	}
+}
+
+// setupCommand binds the config keys of the command being run and sets up
+// the logger
+func setupCommand(cmd *cobra.Command) {
+	bindConfig(cmd)
+	logger.Init(viper.GetBool("debug"))
+}
+
+// loadConfig validates the config file and replaces the settings viper read
+// from it with the file resolved with its extends and the profile
+func loadConfig(path, profile string) error {
+	if _, err := config.Load(path, profile); err != nil {
+		return err
+	}
+	settings, err := config.Resolve(path, profile)
+	if err != nil {
+		return err
 	}
-} 
\ No newline at end of file
+
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %v", err)
+	}
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	return nil
+}
+
+// validateGlobalFlags checks the config file and the persistent flags
+// shared by all commands, and starts the selected profiles
+func validateGlobalFlags(cmd *cobra.Command, args []string) error {
+	setupCommand(cmd)
+	if configErr != nil {
+		return configErr
+	}
+	overrides, _ := cmd.Flags().GetStringArray("set")
+	if err := applySetFlags(overrides); err != nil {
+		return err
+	}
+	if _, err := analyzer.ParseSignatureMode(viper.GetString("signature_mode")); err != nil {
+		return err
+	}
+	if _, err := analyzer.ParseEncoding(viper.GetString("encoding")); err != nil {
+		return err
+	}
+	if _, err := cpp.ParseConditionals(viper.GetString("conditionals")); err != nil {
+		return err
+	}
+	fsutil.SetSync(viper.GetBool("fsync"))
+	return startProfiling()
+}
+
+// workers returns the number of workers configured under key, defaulting to
+// the CPUs available to the process
+func workers(key string) int {
+	if n := viper.GetInt(key); n > 0 {
+		return n
+	}
+	return workpool.DefaultSize()
+}
+
+var (
+	pool     *workpool.Pool
+	poolOnce sync.Once
+)
+
+// sharedPool returns the pool shared by all stages of the command, limited
+// to max_concurrency tasks at once
+func sharedPool() *workpool.Pool {
+	poolOnce.Do(func() {
+		pool = workpool.New(viper.GetInt("max_concurrency"))
+	})
+	return pool
+}
+
+// diskCache returns the analysis cache configured under cache.dir, or nil
+// if caching is disabled or the cache cannot be opened
+func diskCache() *cache.DiskCache {
+	dir := viper.GetString("cache.dir")
+	if dir == "" {
+		return nil
+	}
+	c, err := cache.NewDisk(cache.DiskOptions{
+		Dir:     dir,
+		MaxSize: viper.GetInt64("cache.max_size"),
+	})
+	if err != nil {
+		logger.Warn("Analysis cache disabled", zap.Error(err))
+		return nil
+	}
+	return c
+}
+
+// progressReporter returns the progress reporter of the configured mode,
+// also recording the stages of the run manifest, or nil if progress is
+// disabled and no run manifest is started
+func progressReporter() (*progress.Reporter, error) {
+	renderer, err := progress.RendererForMode(viper.GetString("progress"))
+	if err != nil {
+		return nil, err
+	}
+	// Log lines on the terminal of a progress bar clear and redraw it
+	if i, ok := renderer.(progress.Interrupter); ok {
+		logger.SetInterrupt(i.Interrupt)
+	}
+	// Record the stage timings of the run manifest
+	if currentRun != nil {
+		if renderer == nil {
+			renderer = currentRun.stages
+		} else {
+			renderer = progress.Tee(renderer, currentRun.stages)
+		}
+	}
+	if renderer == nil {
+		return nil, nil
+	}
+	return progress.New(renderer), nil
+}
+
+// normalizeOptions returns the per-language normalization configured under
+// the normalize key, for example normalize.cpp.strip_comments
+func normalizeOptions() map[string]normalize.Options {
+	var opts map[string]normalize.Options
+	if err := viper.UnmarshalKey("normalize", &opts); err != nil {
+		logger.Warn("Ignoring invalid normalize configuration", zap.Error(err))
+		return nil
+	}
+	return opts
+}
+
+// languageExtensions returns the file extensions of the languages to
+// analyze. Languages configured under languages can be disabled or given
+// other extensions; the others keep their defaults.
+func languageExtensions() map[string][]string {
+	languages := analyzer.DefaultLanguages()
+	var settings map[string]config.LanguageSettings
+	if err := viper.UnmarshalKey("languages", &settings); err != nil {
+		logger.Warn("Ignoring invalid languages configuration", zap.Error(err))
+		return languages
+	}
+	for name, s := range settings {
+		switch {
+		case s.Disabled():
+			delete(languages, name)
+		case len(s.Extensions) > 0:
+			languages[name] = s.Extensions
+		}
+	}
+	return languages
+}
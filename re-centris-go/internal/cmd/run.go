@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/common/progress"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// runRecord collects the run manifest of the running command
+type runRecord struct {
+	run    *manifest.Run
+	stages *progress.Recorder
+	slow   *analyzer.SlowFileReport
+	path   string
+}
+
+// currentRun is the run manifest started by startRun, if any
+var currentRun *runRecord
+
+// startRun starts the run manifest of a command. finishRun writes it to
+// the run_manifest setting if set and to path otherwise; no manifest is
+// written if both are empty or "-".
+func startRun(command string, inputs []string, path string) *manifest.Run {
+	if override := viper.GetString("run_manifest"); override != "" {
+		path = override
+	}
+	currentRun = &runRecord{
+		run:    manifest.NewRun(command, manifest.HashConfig(viper.AllSettings()), inputs),
+		stages: progress.NewRecorder(),
+		slow:   analyzer.NewSlowFileReport(viper.GetDuration("slow_file_threshold")),
+		path:   path,
+	}
+	return currentRun.run
+}
+
+// finishRun completes the run manifest with the stage timings and the
+// error the command failed with, and writes it. A failed write is logged
+// but does not fail the command.
+func finishRun(err error) {
+	rec := currentRun
+	if rec == nil || rec.path == "" || rec.path == "-" {
+		return
+	}
+	for _, e := range rec.stages.Stages() {
+		rec.run.AddStage(e.Stage, e.Completed, e.Elapsed)
+	}
+	for _, f := range rec.slow.Files() {
+		rec.run.SlowFiles = append(rec.run.SlowFiles, manifest.SlowFile{
+			Path:       f.Path,
+			DurationMS: f.Duration.Milliseconds(),
+			TimedOut:   f.TimedOut,
+		})
+	}
+	if len(rec.run.SlowFiles) > 0 {
+		logger.Warn("Slow files recorded in the run manifest",
+			zap.Int("files", len(rec.run.SlowFiles)),
+			zap.String("slowest", rec.run.SlowFiles[0].Path))
+	}
+	rec.run.Finish(err)
+
+	if err := manifest.WriteRun(rec.path, rec.run); err != nil {
+		logger.Warn("Failed to write run manifest", zap.Error(err))
+		return
+	}
+	logger.Info("Run manifest written", zap.String("file", rec.path))
+}
+
+// slowFileReport returns the slow file report of the running command, or
+// nil if it records no run
+func slowFileReport() *analyzer.SlowFileReport {
+	if currentRun == nil {
+		return nil
+	}
+	return currentRun.slow
+}
+
+// runManifestPath returns the path of the run manifest of an output file,
+// or "" for standard output
+func runManifestPath(output string) string {
+	if output == "" || output == "-" {
+		return ""
+	}
+	return output + manifest.RunSuffix
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [manifest-file]",
+	Short: "Scan a batch of targets described by a manifest",
+	Long: `Scan all targets listed in a YAML scan manifest. Targets may be
+directories, zip or tar archives, or git repositories at a specific ref,
+each with its own include/exclude patterns and policy. The known corpus
+and detector settings are taken from the detect configuration.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScan,
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+
+	scanCmd.Flags().StringP("output", "o", "scan-report.json", "Output file for the consolidated scan report")
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	// Read scan manifest
+	m, err := scan.ReadManifest(args[0])
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Starting batch scan",
+		zap.Int("targets", len(m.Targets)))
+
+	s := scan.New(scan.ScannerOptions{
+		Detector: detectorOptions(),
+	})
+	report, err := s.Run(context.Background(), m)
+	if err != nil {
+		return err
+	}
+
+	// Save report
+	outputFile := viper.GetString("scan.output")
+	if err := scan.SaveReport(report, outputFile); err != nil {
+		return err
+	}
+
+	logger.Info("Batch scan completed",
+		zap.Bool("passed", report.Passed),
+		zap.String("output_file", outputFile))
+
+	if !report.Passed {
+		return fmt.Errorf("%d of %d targets failed", report.Failed(), len(report.Targets))
+	}
+
+	return nil
+}
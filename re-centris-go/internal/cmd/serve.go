@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/re-centris/re-centris-go/internal/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve analysis and detection over a REST API",
+	Long: `Serve analysis and detection over a REST API. The known files are
+loaded once at startup and kept in memory, so repeated detections are fast.
+
+Endpoints:
+  POST /analyze     analyze uploaded files (multipart "file" parts)
+  POST /detect      detect known code in uploaded files
+  GET  /components  list the known components
+  GET  /health      report server status
+  GET  /metrics     Prometheus metrics
+  GET  /refresh     report the corpus refresh status
+  POST /refresh     start a corpus refresh now
+
+With --allow-paths, /analyze and /detect also accept a JSON body
+{"paths": [...]} naming files on the server.
+
+With --refresh-schedule, the repositories of --repo-list are updated into
+the known files directory on a cron-style schedule, e.g. "0 3 * * *" or
+"@every 6h". Changed repositories are re-analyzed and the new corpus
+replaces the old one without interrupting requests.`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("addr", ":8080", "Address to listen on")
+	serveCmd.Flags().Int64("max-upload-size", 32<<20, "Maximum request body size in bytes")
+	serveCmd.Flags().Bool("allow-paths", false, "Allow requests to name files on the server instead of uploading them")
+	serveCmd.Flags().String("refresh-schedule", "", "Cron-style schedule for refreshing the corpus, disabled if empty")
+	serveCmd.Flags().String("repo-list", "", "Repository list file updated by corpus refreshes")
+
+	configKey(serveCmd.Flags(), "refresh-schedule", "serve.refresh.schedule")
+	configKey(serveCmd.Flags(), "repo-list", "serve.refresh.repo_list")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := server.New(server.ServerOptions{
+		Addr:          viper.GetString("serve.addr"),
+		Detector:      detectorOptions(),
+		MaxUploadSize: viper.GetInt64("serve.max_upload_size"),
+		AllowPaths:    viper.GetBool("serve.allow_paths"),
+		Refresh: server.RefreshOptions{
+			Schedule: viper.GetString("serve.refresh.schedule"),
+			RepoList: viper.GetString("serve.refresh.repo_list"),
+			Workers:  viper.GetInt("serve.refresh.workers"),
+		},
+	})
+
+	if err := s.Load(ctx); err != nil {
+		return err
+	}
+
+	return s.ListenAndServe(ctx)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/snapshot"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage immutable corpus snapshots",
+	Long: `Keep immutable snapshots of preprocessed corpora in a content-addressed
+store, so that detect --snapshot runs against exactly the signatures a
+snapshot ID names. Repositories unchanged between snapshots are stored once.`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create [preprocessed-directory]",
+	Short: "Store sharded preprocessor output as a snapshot",
+	Long: `Store the sharded preprocessor output (preprocess --format sharded) of a
+directory, by default preprocess.output, as a snapshot and print its ID.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSnapshotCreate,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snapshots of the store, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  runSnapshotList,
+}
+
+var snapshotCheckoutCmd = &cobra.Command{
+	Use:   "checkout snapshot-id",
+	Short: "Print the directory holding a snapshot as preprocessor output",
+	Long: `Materialize a snapshot as sharded preprocessor output inside the store,
+if not done before, and print the directory, e.g. for detect --signatures.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotCheckout,
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:   "delete snapshot-id...",
+	Short: "Delete snapshots; their objects are removed by gc",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runSnapshotDelete,
+}
+
+var snapshotGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove old snapshots and unreferenced objects",
+	Long: `Delete all but the --keep newest snapshots, if set, and remove the
+objects and checkouts no remaining snapshot references. Do not run it while
+other commands create or check out snapshots.`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotGC,
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotCreateCmd, snapshotListCmd, snapshotCheckoutCmd, snapshotDeleteCmd, snapshotGCCmd)
+
+	snapshotCmd.PersistentFlags().String("store", "./data/snapshots", "Snapshot store directory")
+	snapshotGCCmd.Flags().Int("keep", 0, "Number of newest snapshots to keep (0 = all)")
+
+	configKey(snapshotGCCmd.Flags(), "keep", "snapshot.keep")
+}
+
+// snapshotStore returns the configured snapshot store
+func snapshotStore() *snapshot.Store {
+	return snapshot.Open(viper.GetString("snapshot.store"))
+}
+
+func runSnapshotCreate(cmd *cobra.Command, args []string) error {
+	dir := viper.GetString("preprocess.output")
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	snap, err := snapshotStore().Create(dir)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Snapshot created",
+		zap.String("id", snap.ID),
+		zap.String("source", dir),
+		zap.Int("repositories", len(snap.Repositories)))
+	fmt.Println(snap.ID)
+	return nil
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) error {
+	snapshots, err := snapshotStore().List()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-16s %-20s %-6s %-8s %s\n", "ID", "CREATED", "REPOS", "FILES", "SOURCE")
+	for _, snap := range snapshots {
+		files := 0
+		for _, repo := range snap.Repositories {
+			files += repo.Files
+		}
+		fmt.Printf("%-16s %-20s %-6d %-8d %s\n",
+			snap.ID[:16], snap.CreatedAt.Local().Format(time.DateTime), len(snap.Repositories), files, snap.Source)
+	}
+	return nil
+}
+
+func runSnapshotCheckout(cmd *cobra.Command, args []string) error {
+	dir, err := snapshotStore().Checkout(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(dir)
+	return nil
+}
+
+func runSnapshotDelete(cmd *cobra.Command, args []string) error {
+	store := snapshotStore()
+	for _, id := range args {
+		if err := store.Delete(id); err != nil {
+			return err
+		}
+		logger.Info("Snapshot deleted", zap.String("id", id))
+	}
+	return nil
+}
+
+func runSnapshotGC(cmd *cobra.Command, args []string) error {
+	stats, err := snapshotStore().GC(viper.GetInt("snapshot.keep"))
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Snapshot store collected",
+		zap.String("store", viper.GetString("snapshot.store")),
+		zap.Int("snapshots", stats.Snapshots),
+		zap.Int("deleted", stats.Deleted),
+		zap.Int("objects", stats.Objects),
+		zap.Int("removed", stats.Removed),
+		zap.Int64("freed", stats.Freed))
+	return nil
+}
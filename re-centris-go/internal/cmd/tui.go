@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/tui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui results-file",
+	Short: "Browse detection results interactively",
+	Long: `Browse the matches of a detection result file in the terminal, grouped by
+component, and drill into target files and their matched functions.
+
+Matches marked as false positives (space) are written to the baseline file
+(--baseline, default detect.baseline), so detect --baseline suppresses
+them, and to the labels file (--labels, default detect.labels or
+labels.json in detect.signatures), so detect suppresses them wherever the
+same code is detected again; w saves and q quits, saving unsaved marks.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTUI,
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+
+	tuiCmd.Flags().String("baseline", "", "Baseline file receiving the false positives (default is detect.baseline)")
+	tuiCmd.Flags().String("labels", "", "Labels file receiving the false positives (default is detect.labels, or labels.json in detect.signatures)")
+	tuiCmd.Flags().StringP("known-files", "k", "", "Directory of the known files the results were detected against (default is detect.known_files)")
+	tuiCmd.Flags().String("target-root", "", "Directory baseline entries name target files relative to (default is detect.target_root, or the working directory)")
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	path := viper.GetString("detect.baseline")
+	if p, _ := cmd.Flags().GetString("baseline"); p != "" {
+		path = p
+	}
+	labelsFile := labelsPath()
+	if p, _ := cmd.Flags().GetString("labels"); p != "" {
+		labelsFile = p
+	}
+	if path == "" && labelsFile == "" {
+		return fmt.Errorf("specify the baseline file with --baseline or the labels file with --labels")
+	}
+	knownFiles := viper.GetString("detect.known_files")
+	if dir, _ := cmd.Flags().GetString("known-files"); dir != "" {
+		knownFiles = dir
+	}
+
+	var results []*detector.DetectionResult
+	err := detector.ReadResults(args[0], func(result *detector.DetectionResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	targetRoot := viper.GetString("detect.target_root")
+	if dir, _ := cmd.Flags().GetString("target-root"); dir != "" {
+		targetRoot = dir
+	}
+	d := detector.New(detector.DetectorOptions{KnownFilesDir: knownFiles, TargetRoot: targetRoot})
+	opts := tui.Options{
+		Results:      results,
+		Detector:     d,
+		BaselinePath: path,
+		LabelsPath:   labelsFile,
+	}
+	if path != "" {
+		opts.Baseline = d.NewBaseline(nil)
+		if _, err := os.Stat(path); err == nil {
+			if opts.Baseline, err = detector.LoadBaseline(path); err != nil {
+				return err
+			}
+		}
+	}
+	if labelsFile != "" {
+		if opts.Labels, err = detector.LoadLabels(labelsFile); err != nil {
+			return err
+		}
+	}
+
+	if err := tui.New(opts).Run(); err != nil {
+		return err
+	}
+
+	logger.Info("Closed results browser",
+		zap.String("baseline", path),
+		zap.String("labels", labelsFile))
+	return nil
+}
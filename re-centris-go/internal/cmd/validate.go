@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var validateResultsCmd = &cobra.Command{
+	Use:   "validate-results [result-files...]",
+	Short: "Validate detection result files against the result schema",
+	Long: `Validate detection result files against the published JSON Schema of
+detection results. Use --schema to print the schema instead.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if schema, _ := cmd.Flags().GetBool("schema"); schema {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: runValidateResults,
+}
+
+func init() {
+	rootCmd.AddCommand(validateResultsCmd)
+
+	validateResultsCmd.Flags().Bool("schema", false, "Print the result JSON Schema and exit")
+}
+
+func runValidateResults(cmd *cobra.Command, args []string) error {
+	if schema, _ := cmd.Flags().GetBool("schema"); schema {
+		_, err := os.Stdout.Write(detector.ResultSchema)
+		return err
+	}
+
+	invalid := 0
+	for _, path := range args {
+		violations, err := detector.ValidateResults(path)
+		if err != nil {
+			return err
+		}
+		for _, violation := range violations {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, violation)
+		}
+		if len(violations) > 0 {
+			invalid++
+		}
+
+		logger.Info("Validated detection results",
+			zap.String("file", path),
+			zap.Int("violations", len(violations)))
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d result files do not match schema version %s", invalid, len(args), detector.SchemaVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/signing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [signed-files...]",
+	Short: "Verify the signatures of result and SBOM files",
+	Long: `Verify result and SBOM files signed by detect --sign-key against their
+signature files (the file name with .sig appended), reporting the tool
+version and time of signing.
+
+With --public-key, signatures must be made with that key. Without it the
+key embedded in the signature is used, which detects modified files but
+does not authenticate who signed them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().String("public-key", "", "ed25519 public key (PEM) the files must be signed with")
+
+	configKey(verifyCmd.Flags(), "public-key", "signing.public_key")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	var trusted ed25519.PublicKey
+	if path := viper.GetString("signing.public_key"); path != "" {
+		key, err := signing.LoadPublicKey(path)
+		if err != nil {
+			return err
+		}
+		trusted = key
+	} else {
+		logger.Warn("No public key given, signatures are checked with their embedded keys")
+	}
+
+	failed := 0
+	for _, path := range args {
+		s, err := signing.VerifyFile(path, trusted)
+		if err != nil {
+			logger.Error("Verification failed",
+				zap.String("file", path),
+				zap.Error(err))
+			failed++
+			continue
+		}
+		logger.Info("Signature verified",
+			zap.String("file", path),
+			zap.String("tool_version", s.ToolVersion),
+			zap.Time("signed_at", s.SignedAt))
+	}
+
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d of %d files failed verification", failed, len(args))
+	}
+	return nil
+}
+
+// signOutput signs an output file with the configured signing key, if any
+func signOutput(path string) error {
+	keyPath := viper.GetString("signing.key")
+	if keyPath == "" {
+		return nil
+	}
+	if path == "-" {
+		return fmt.Errorf("standard output cannot be signed, write the output to a file")
+	}
+
+	key, err := signing.LoadPrivateKey(keyPath)
+	if err != nil {
+		return err
+	}
+	if _, err := signing.SignFile(path, key); err != nil {
+		return err
+	}
+	logger.Info("Signed output",
+		zap.String("file", path),
+		zap.String("signature", path+signing.SignatureSuffix))
+	return nil
+}
@@ -1,16 +1,19 @@
 package clone
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
+	"github.com/re-centris/re-centris-go/internal/common/gitutil"
 	"github.com/re-centris/re-centris-go/internal/common/logger"
-	"golang.org/x/sync/errgroup"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"go.uber.org/zap"
 )
 
 // RepoInfo contains information about a repository
@@ -24,6 +27,12 @@ type RepoInfo struct {
 type CloneOptions struct {
 	TargetDir  string
 	MaxWorkers int
+	// Pool, if set, is shared with other stages and limits the
+	// repositories cloned at once across them
+	Pool *workpool.Pool
+	// Tags clones the history and tags of each repository, with file
+	// contents fetched on demand, so that versions can be collected
+	Tags bool
 }
 
 // ParseRepoURL parses a GitHub repository URL and returns RepoInfo
@@ -46,31 +55,52 @@ func ParseRepoURL(url string) (*RepoInfo, error) {
 	}, nil
 }
 
-// CloneRepository clones a single repository
-func CloneRepository(ctx context.Context, info *RepoInfo, targetDir string) error {
+// ReadRepoList reads repository URLs from a file, one URL per line.
+// Empty lines and lines starting with '#' are ignored.
+func ReadRepoList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository list: %v", err)
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read repository list: %v", err)
+	}
+
+	return urls, nil
+}
+
+// CloneRepository clones a single repository. Only the latest commit is
+// cloned unless tags is set, see CloneOptions.Tags.
+func CloneRepository(ctx context.Context, info *RepoInfo, targetDir string, tags bool) error {
 	folderName := fmt.Sprintf("%s%%%s", info.Author, info.Name)
 	targetPath := filepath.Join(targetDir, folderName)
 
 	// Check if repository already exists
 	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
-		logger.Info("Repository already exists, skipping", 
+		logger.Info("Repository already exists, skipping",
 			zap.String("repo", folderName))
 		return nil
 	}
 
-	// Prepare git clone command
-	cmd := exec.CommandContext(ctx, "git", "clone",
-		"--depth", "1",
-		"--single-branch",
-		"--no-tags",
-		info.URL,
-		targetPath,
-	)
-
-	// Execute command
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to clone repository %s: %v\nOutput: %s", 
-			info.URL, err, string(output))
+	// Clone repository
+	args := []string{"clone", "--depth", "1", "--single-branch", "--no-tags"}
+	if tags {
+		args = []string{"clone", "--filter=blob:none"}
+	}
+	if err := gitutil.Run(ctx, "", append(args, info.URL, targetPath)...); err != nil {
+		return fmt.Errorf("failed to clone repository %s: %v", info.URL, err)
 	}
 
 	logger.Info("Successfully cloned repository",
@@ -86,8 +116,7 @@ func CloneRepositories(ctx context.Context, urls []string, opts CloneOptions) er
 	}
 
 	// Create error group with context
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(opts.MaxWorkers)
+	g, ctx := workpool.WithContext(ctx, opts.Pool, opts.MaxWorkers)
 
 	// Process each repository URL
 	for _, url := range urls {
@@ -101,7 +130,7 @@ func CloneRepositories(ctx context.Context, urls []string, opts CloneOptions) er
 				return err
 			}
 
-			return CloneRepository(ctx, info, opts.TargetDir)
+			return CloneRepository(ctx, info, opts.TargetDir, opts.Tags)
 		})
 	}
 
@@ -111,4 +140,94 @@ func CloneRepositories(ctx context.Context, urls []string, opts CloneOptions) er
 	}
 
 	return nil
-} 
\ No newline at end of file
+}
+
+// UpdateRepository clones a repository or, if it already exists, fetches
+// and checks out the latest commit of its default branch. It reports
+// whether the working tree changed.
+func UpdateRepository(ctx context.Context, info *RepoInfo, targetDir string) (bool, error) {
+	folderName := fmt.Sprintf("%s%%%s", info.Author, info.Name)
+	targetPath := filepath.Join(targetDir, folderName)
+
+	if _, err := os.Stat(targetPath); os.IsNotExist(err) {
+		if err := CloneRepository(ctx, info, targetDir, false); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	before, err := gitutil.Output(ctx, targetPath, "rev-parse", "HEAD")
+	if err != nil {
+		return false, fmt.Errorf("failed to read commit of %s: %v", folderName, err)
+	}
+	if err := gitutil.Run(ctx, targetPath, "fetch", "--depth", "1", "--no-tags", "origin"); err != nil {
+		return false, fmt.Errorf("failed to fetch repository %s: %v", info.URL, err)
+	}
+	after, err := gitutil.Output(ctx, targetPath, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return false, fmt.Errorf("failed to read fetched commit of %s: %v", folderName, err)
+	}
+	if before == after {
+		return false, nil
+	}
+
+	if err := gitutil.Run(ctx, targetPath, "reset", "--hard", "FETCH_HEAD"); err != nil {
+		return false, fmt.Errorf("failed to update repository %s: %v", info.URL, err)
+	}
+
+	logger.Info("Updated repository",
+		zap.String("repo", folderName),
+		zap.String("commit", after))
+	return true, nil
+}
+
+// UpdateRepositories updates multiple repositories in parallel and returns
+// the directory names of the repositories that changed. A repository that
+// fails to update is logged and skipped, so one unreachable remote does
+// not hold back the others.
+func UpdateRepositories(ctx context.Context, urls []string, opts CloneOptions) ([]string, error) {
+	if err := os.MkdirAll(opts.TargetDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create target directory: %v", err)
+	}
+
+	var (
+		changed    []string
+		changedMux sync.Mutex
+	)
+
+	g, ctx := workpool.WithContext(ctx, opts.Pool, opts.MaxWorkers)
+
+	for _, url := range urls {
+		url := url // Create new variable for goroutine
+		g.Go(func() error {
+			info, err := ParseRepoURL(url)
+			if err != nil {
+				logger.Error("Failed to parse repository URL",
+					zap.String("url", url),
+					zap.Error(err))
+				return nil
+			}
+
+			updated, err := UpdateRepository(ctx, info, opts.TargetDir)
+			if err != nil {
+				logger.Error("Failed to update repository",
+					zap.String("url", url),
+					zap.Error(err))
+				return nil
+			}
+			if updated {
+				changedMux.Lock()
+				changed = append(changed, fmt.Sprintf("%s%%%s", info.Author, info.Name))
+				changedMux.Unlock()
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("error while updating repositories: %v", err)
+	}
+
+	sort.Strings(changed)
+	return changed, nil
+}
@@ -0,0 +1,177 @@
+// Package metadata fetches repository metadata from the GitHub API, so that
+// reports can flag abandoned or unmaintained components.
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/collector/clone"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultEndpoint is the base URL of the public GitHub API
+	DefaultEndpoint = "https://api.github.com"
+
+	// defaultTimeout is the timeout of a single API request
+	defaultTimeout = 30 * time.Second
+)
+
+// repository is the part of a GitHub repository response used here
+type repository struct {
+	Description string     `json:"description"`
+	Stars       int        `json:"stargazers_count"`
+	Archived    bool       `json:"archived"`
+	PushedAt    *time.Time `json:"pushed_at"`
+	License     *struct {
+		SPDXID string `json:"spdx_id"`
+	} `json:"license"`
+}
+
+// release is the part of a GitHub release response used here
+type release struct {
+	TagName     string     `json:"tag_name"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// ClientOptions contains options for the metadata client
+type ClientOptions struct {
+	Endpoint string // API base URL, defaults to the public GitHub API
+	Token    string // API token, raising the rate limit if set
+	Timeout  time.Duration
+}
+
+// Client fetches repository metadata from the GitHub API
+type Client struct {
+	opts ClientOptions
+	http *http.Client
+}
+
+// NewClient creates a new metadata client
+func NewClient(opts ClientOptions) *Client {
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	return &Client{
+		opts: opts,
+		http: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+// Fetch returns the metadata of a GitHub repository. A repository without
+// releases has no latest release.
+func (c *Client) Fetch(ctx context.Context, owner, name string) (*manifest.Metadata, error) {
+	var repo repository
+	if _, err := c.get(ctx, fmt.Sprintf("/repos/%s/%s", owner, name), &repo); err != nil {
+		return nil, err
+	}
+
+	m := &manifest.Metadata{
+		Description: repo.Description,
+		Stars:       repo.Stars,
+		Archived:    repo.Archived,
+		PushedAt:    repo.PushedAt,
+		FetchedAt:   time.Now().UTC(),
+	}
+	// GitHub reports unrecognized licenses as NOASSERTION
+	if repo.License != nil && repo.License.SPDXID != "NOASSERTION" {
+		m.License = repo.License.SPDXID
+	}
+
+	var latest release
+	found, err := c.get(ctx, fmt.Sprintf("/repos/%s/%s/releases/latest", owner, name), &latest)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		m.LatestRelease = latest.TagName
+		m.LatestReleaseDate = latest.PublishedAt
+	}
+
+	return m, nil
+}
+
+// get decodes the response of an API path into v. It reports false if the
+// resource does not exist.
+func (c *Client) get(ctx context.Context, path string, v interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.opts.Endpoint, "/")+path, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create metadata request: %v", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch repository metadata: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("metadata request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("failed to parse metadata response: %v", err)
+	}
+	return true, nil
+}
+
+// Collect fetches the metadata of GitHub repositories and stores it in the
+// metadata file of the clone directory, keyed by the directory name of each
+// repository. Repositories on other hosts, or whose metadata cannot be
+// fetched, are logged and skipped; their previous metadata is kept.
+func Collect(ctx context.Context, c *Client, urls []string, dir string) error {
+	metadata, err := manifest.ReadMetadata(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		if !strings.Contains(url, "github.com") {
+			logger.Debug("Skipping metadata of repository not hosted on GitHub",
+				zap.String("url", url))
+			continue
+		}
+		info, err := clone.ParseRepoURL(url)
+		if err != nil {
+			logger.Warn("Failed to parse repository URL",
+				zap.String("url", url),
+				zap.Error(err))
+			continue
+		}
+
+		m, err := c.Fetch(ctx, info.Author, info.Name)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			logger.Warn("Failed to fetch repository metadata",
+				zap.String("url", url),
+				zap.Error(err))
+			continue
+		}
+		metadata[fmt.Sprintf("%s%%%s", info.Author, info.Name)] = m
+	}
+
+	return manifest.WriteMetadata(dir, metadata)
+}
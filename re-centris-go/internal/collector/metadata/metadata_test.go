@@ -0,0 +1,57 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/manifest"
+)
+
+func TestCollect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("request %s without token", r.URL.Path)
+		}
+		switch r.URL.Path {
+		case "/repos/acme/lib":
+			w.Write([]byte(`{"description": "A library", "stargazers_count": 42, "archived": true,
+				"pushed_at": "2021-03-04T05:06:07Z", "license": {"spdx_id": "MIT"}}`))
+		case "/repos/acme/lib/releases/latest":
+			w.Write([]byte(`{"tag_name": "v1.2.0", "published_at": "2020-01-02T03:04:05Z"}`))
+		case "/repos/acme/norelease":
+			w.Write([]byte(`{"stargazers_count": 1, "license": {"spdx_id": "NOASSERTION"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := NewClient(ClientOptions{Endpoint: server.URL, Token: "secret"})
+	urls := []string{
+		"https://github.com/acme/lib.git",
+		"https://github.com/acme/norelease",
+		"https://gitlab.com/acme/other",
+	}
+	if err := Collect(context.Background(), client, urls, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := manifest.ReadMetadata(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 2 {
+		t.Fatalf("got metadata of %d repositories, want 2: %v", len(metadata), metadata)
+	}
+	lib := metadata["acme%lib"]
+	if lib == nil || lib.Stars != 42 || !lib.Archived || lib.License != "MIT" || lib.LatestRelease != "v1.2.0" ||
+		lib.LatestReleaseDate == nil || lib.LatestReleaseDate.Year() != 2020 {
+		t.Errorf("metadata of acme%%lib = %+v", lib)
+	}
+	if m := metadata["acme%norelease"]; m == nil || m.License != "" || m.LatestRelease != "" || m.LatestReleaseDate != nil {
+		t.Errorf("metadata of acme%%norelease = %+v, want no license and no release", m)
+	}
+}
@@ -0,0 +1,280 @@
+// Package versions collects the function signatures of every tagged version
+// of a repository, which identify the version of a detected component.
+package versions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/bodies"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/gitutil"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// DirName is the directory of the preprocessor output holding the version
+// signatures, one file per component
+const DirName = "versions"
+
+// Version is a tagged version of a repository
+type Version struct {
+	Name   string    `json:"name"`
+	Commit string    `json:"commit"`
+	Date   time.Time `json:"date"`
+}
+
+// Signatures holds the function signatures of the versions of a component
+type Signatures struct {
+	Component string    `json:"component"`
+	Versions  []Version `json:"versions"`
+	// Functions maps each function signature to the indices of the
+	// versions containing it, in ascending order
+	Functions map[string][]int `json:"functions"`
+	// Bodies maps function signatures to the digest of their body in the
+	// body store, if bodies are stored, see bodies.Store
+	Bodies map[string]string `json:"bodies,omitempty"`
+}
+
+// CollectorOptions contains options for collecting version signatures
+type CollectorOptions struct {
+	// OutputDir is the directory the versions directory is created in
+	OutputDir string
+	// MaxVersions limits the versions collected per repository to the most
+	// recent ones (0 = all)
+	MaxVersions int
+	// Analyzer analyzes the files of each version
+	Analyzer *analyzer.Analyzer
+	// Resume skips components whose signatures were already written
+	Resume bool
+	// Bodies, if set, stores the bodies of the functions of each version
+	Bodies *bodies.Store
+}
+
+// Collector collects version signatures of repositories
+type Collector struct {
+	opts CollectorOptions
+}
+
+// New creates a new Collector
+func New(opts CollectorOptions) *Collector {
+	return &Collector{opts: opts}
+}
+
+// CollectVersionInfo returns the tags of the repository in dir that point
+// to commits, oldest first
+func CollectVersionInfo(ctx context.Context, dir string) ([]Version, error) {
+	output, err := gitutil.Output(ctx, dir, "for-each-ref", "--sort=creatordate",
+		"--format=%(refname:strip=2)%09%(if)%(*objectname)%(then)%(*objectname)%09%(*objecttype)%(else)%(objectname)%09%(objecttype)%(end)%09%(creatordate:unix)",
+		"refs/tags")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %v", err)
+	}
+
+	var versions []Version
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		// Tags of trees or blobs have no files to analyze
+		if len(fields) != 4 || fields[2] != "commit" {
+			continue
+		}
+		var seconds int64
+		fmt.Sscan(fields[3], &seconds)
+		versions = append(versions, Version{
+			Name:   fields[0],
+			Commit: fields[1],
+			Date:   time.Unix(seconds, 0).UTC(),
+		})
+	}
+	return versions, nil
+}
+
+// Collect collects and writes the signatures of the versions of the
+// repository in dir, named component. Repositories without tags are
+// skipped.
+func (c *Collector) Collect(ctx context.Context, dir, component string) error {
+	path := filepath.Join(c.opts.OutputDir, DirName, component+".json")
+	if c.opts.Resume {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+
+	versions, err := CollectVersionInfo(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("failed to collect versions of %s: %v", component, err)
+	}
+	if len(versions) == 0 {
+		logger.Debug("Repository has no tags, skipping versions",
+			zap.String("component", component))
+		return nil
+	}
+	if c.opts.MaxVersions > 0 && len(versions) > c.opts.MaxVersions {
+		versions = versions[len(versions)-c.opts.MaxVersions:]
+	}
+
+	signatures := &Signatures{
+		Component: component,
+		Versions:  versions,
+		Functions: make(map[string][]int),
+	}
+	if c.opts.Bodies != nil {
+		signatures.Bodies = make(map[string]string)
+	}
+	for i, version := range versions {
+		files, err := c.analyzeVersion(ctx, dir, version)
+		if err != nil {
+			return fmt.Errorf("failed to analyze %s %s: %v", component, version.Name, err)
+		}
+		for _, file := range files {
+			for _, fn := range file.Functions {
+				if fn.Hash == "" {
+					continue
+				}
+				// Versions are added in order, so the last index tells
+				// whether this version was already recorded
+				indices := signatures.Functions[fn.Hash]
+				if len(indices) == 0 || indices[len(indices)-1] != i {
+					signatures.Functions[fn.Hash] = append(indices, i)
+				}
+				if c.opts.Bodies != nil && signatures.Bodies[fn.Hash] == "" {
+					digest, err := c.opts.Bodies.Put(fn.Content)
+					if err != nil {
+						return err
+					}
+					signatures.Bodies[fn.Hash] = digest
+				}
+			}
+		}
+	}
+
+	if err := Write(c.opts.OutputDir, signatures); err != nil {
+		return err
+	}
+
+	logger.Info("Collected version signatures",
+		zap.String("component", component),
+		zap.Int("versions", len(versions)),
+		zap.Int("functions", len(signatures.Functions)))
+	return nil
+}
+
+// analyzeVersion analyzes the files of a version from an archive of its
+// commit, without checking it out
+func (c *Collector) analyzeVersion(ctx context.Context, dir string, version Version) ([]*analyzer.FileInfo, error) {
+	archive, err := os.CreateTemp("", "re-centris-version-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %v", err)
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+
+	if err := gitutil.Run(ctx, dir, "archive", "--format=tar", "-o", archive.Name(), version.Commit); err != nil {
+		return nil, fmt.Errorf("failed to archive commit %s: %v", version.Commit, err)
+	}
+	return c.opts.Analyzer.AnalyzeArchive(ctx, archive.Name())
+}
+
+// Write writes the signatures of a component to the versions directory of
+// dir
+func Write(dir string, s *Signatures) error {
+	versionsDir := filepath.Join(dir, DirName)
+	if err := os.MkdirAll(versionsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create versions directory: %v", err)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal version signatures: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(versionsDir, s.Component+".json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write version signatures: %v", err)
+	}
+	return nil
+}
+
+// Read reads the signatures of all components from the versions directory
+// of dir, keyed by component. A missing directory yields no signatures.
+func Read(dir string) (map[string]*Signatures, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, DirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions directory: %v", err)
+	}
+
+	signatures := make(map[string]*Signatures)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, DirName, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read version signatures: %v", err)
+		}
+		var s Signatures
+		if err := json.Unmarshal(data, &s); err != nil {
+			logger.Warn("Skipping corrupt version signatures",
+				zap.Error(&fsutil.CorruptError{Path: filepath.Join(dir, DirName, entry.Name()), Err: err}))
+			continue
+		}
+		signatures[s.Component] = &s
+	}
+	return signatures, nil
+}
+
+// Prune removes from the versions directory of dir the signatures of the
+// components keep rejects and, if maxVersions is positive, all but the
+// maxVersions most recent versions of the others. It returns the number of
+// component files and versions removed.
+func Prune(dir string, keep func(component string) bool, maxVersions int) (int, int, error) {
+	signatures, err := Read(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	removedFiles, removedVersions := 0, 0
+	for component, s := range signatures {
+		if !keep(component) {
+			if err := os.Remove(filepath.Join(dir, DirName, component+".json")); err != nil {
+				return removedFiles, removedVersions, fmt.Errorf("failed to remove version signatures: %v", err)
+			}
+			removedFiles++
+			removedVersions += len(s.Versions)
+			continue
+		}
+		if maxVersions <= 0 || len(s.Versions) <= maxVersions {
+			continue
+		}
+
+		// Versions are oldest first; drop the oldest and shift the indices
+		offset := len(s.Versions) - maxVersions
+		s.Versions = s.Versions[offset:]
+		for hash, indices := range s.Functions {
+			kept := indices[:0]
+			for _, i := range indices {
+				if i >= offset {
+					kept = append(kept, i-offset)
+				}
+			}
+			if len(kept) == 0 {
+				delete(s.Functions, hash)
+				delete(s.Bodies, hash)
+			} else {
+				s.Functions[hash] = kept
+			}
+		}
+		if err := Write(dir, s); err != nil {
+			return removedFiles, removedVersions, err
+		}
+		removedVersions += offset
+	}
+	return removedFiles, removedVersions, nil
+}
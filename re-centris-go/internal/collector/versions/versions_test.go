@@ -0,0 +1,125 @@
+package versions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+// function returns a C function long enough to be hashed
+func function(name string, seed int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "int %s(int x, int y)\n{\n", name)
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&b, "    x = x * %d + y / %d - %d;\n    if (x > %d) { y ^= x << %d; }\n", seed+i, i+1, seed*i, seed*100+i, i%5)
+	}
+	b.WriteString("    return x + y;\n}\n\n")
+	return b.String()
+}
+
+func TestCollect(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := filepath.Join(t.TempDir(), "acme%lib")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	commit := func(content, tag string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, "lib.c"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "lib.c")
+		git("commit", "-q", "-m", tag)
+		git("tag", "-a", "-m", tag, tag)
+	}
+
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	commit(function("shared", 1)+function("old", 2), "v1.0")
+	commit(function("shared", 1)+function("added", 3), "v1.1")
+
+	versions, err := CollectVersionInfo(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Name != "v1.0" || versions[1].Name != "v1.1" {
+		t.Fatalf("got versions %+v", versions)
+	}
+
+	out := t.TempDir()
+	collector := New(CollectorOptions{
+		OutputDir: out,
+		Analyzer:  analyzer.New(analyzer.AnalyzerOptions{MaxWorkers: 2, Languages: analyzer.DefaultLanguages()}),
+	})
+	if err := collector.Collect(context.Background(), repo, "acme%lib"); err != nil {
+		t.Fatal(err)
+	}
+
+	signatures, err := Read(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := signatures["acme%lib"]
+	if s == nil {
+		t.Fatalf("no signatures for acme%%lib in %v", signatures)
+	}
+	counts := make(map[string]int)
+	for _, indices := range s.Functions {
+		counts[fmt.Sprint(indices)]++
+	}
+	want := map[string]int{"[0 1]": 1, "[0]": 1, "[1]": 1}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Errorf("got version sets %v, want %v", counts, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	for _, s := range []*Signatures{
+		{
+			Component: "kept",
+			Versions:  []Version{{Name: "v1"}, {Name: "v2"}, {Name: "v3"}},
+			Functions: map[string][]int{"old": {0}, "both": {0, 2}, "new": {1, 2}},
+		},
+		{Component: "removed", Versions: []Version{{Name: "v1"}}, Functions: map[string][]int{"f": {0}}},
+	} {
+		if err := Write(dir, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, removed, err := Prune(dir, func(component string) bool { return component == "kept" }, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != 1 || removed != 2 {
+		t.Errorf("Prune() = %d files, %d versions, want 1 and 2", files, removed)
+	}
+
+	signatures, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := signatures["kept"]
+	if len(signatures) != 1 || s == nil || len(s.Versions) != 2 || s.Versions[0].Name != "v2" {
+		t.Fatalf("signatures = %+v, want kept with v2 and v3", signatures)
+	}
+	if _, ok := s.Functions["old"]; ok || fmt.Sprint(s.Functions["both"]) != "[1]" || fmt.Sprint(s.Functions["new"]) != "[0 1]" {
+		t.Errorf("functions = %v, want the indices of v2 and v3 only", s.Functions)
+	}
+}
@@ -0,0 +1,48 @@
+// Package bufpool reuses byte buffers across files, so that reading and
+// hashing a corpus does not allocate a fresh buffer per file.
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// MaxRetained is the capacity above which buffers are dropped instead of
+// returned to the pool, so a few huge files do not pin memory
+const MaxRetained = 4 << 20
+
+var pool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Get returns an empty buffer from the pool
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put returns a buffer to the pool. The buffer and slices of its content
+// must not be used afterwards.
+func Put(b *bytes.Buffer) {
+	if b == nil || b.Cap() > MaxRetained {
+		return
+	}
+	b.Reset()
+	pool.Put(b)
+}
+
+// ReadAll reads r until EOF into a buffer from the pool. size, if known,
+// is the expected number of bytes and saves growing the buffer.
+func ReadAll(r io.Reader, size int64) (*bytes.Buffer, error) {
+	b := Get()
+	if size > 0 && size < 1<<30 {
+		// ReadFrom wants room for at least bytes.MinRead more bytes
+		// before detecting EOF
+		b.Grow(int(size) + bytes.MinRead)
+	}
+	if _, err := b.ReadFrom(r); err != nil {
+		Put(b)
+		return nil, err
+	}
+	return b, nil
+}
@@ -3,106 +3,216 @@ package cache
 import (
 	"container/list"
 	"sync"
+	"time"
 )
 
-// Cache is a thread-safe LRU cache
-type Cache struct {
-	capacity int
-	items    map[string]*list.Element
-	queue    *list.List
-	mutex    sync.RWMutex
+// Options contains options for a cache
+type Options struct {
+	// MaxCost is the total cost of the entries kept; least recently used
+	// entries are evicted beyond it. Entries set with Set cost 1, so it is
+	// the number of entries unless SetWithCost is used.
+	MaxCost int64
+	// TTL, if set, expires entries this long after they were set
+	TTL time.Duration
+}
+
+// Cache is a thread-safe LRU cache with optional expiry
+type Cache[K comparable, V any] struct {
+	opts  Options
+	items map[K]*list.Element
+	queue *list.List
+	cost  int64
+	mutex sync.Mutex
+
+	// calls are the computations of GetOrCompute in flight by key
+	calls map[K]*call[V]
+	now   func() time.Time
 }
 
 // item represents a cache item
-type item struct {
-	key   string
-	value interface{}
+type item[K comparable, V any] struct {
+	key     K
+	value   V
+	cost    int64
+	expires time.Time // zero if the item does not expire
+}
+
+// call is a computation of a missing value shared by concurrent callers
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
 }
 
-// New creates a new cache with the given capacity
-func New(capacity int) *Cache {
-	return &Cache{
-		capacity: capacity,
-		items:    make(map[string]*list.Element),
-		queue:    list.New(),
+// New creates a new cache
+func New[K comparable, V any](opts Options) *Cache[K, V] {
+	return &Cache[K, V]{
+		opts:  opts,
+		items: make(map[K]*list.Element),
+		queue: list.New(),
+		calls: make(map[K]*call[V]),
+		now:   time.Now,
 	}
 }
 
 // Get retrieves a value from the cache
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mutex.RLock()
-	if element, exists := c.items[key]; exists {
-		c.mutex.RUnlock()
-		c.mutex.Lock()
-		c.queue.MoveToFront(element)
-		c.mutex.Unlock()
-		return element.Value.(*item).value, true
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.get(key)
+}
+
+// get retrieves a live value and marks it as recently used; the caller
+// holds the mutex
+func (c *Cache[K, V]) get(key K) (V, bool) {
+	element, exists := c.items[key]
+	if !exists {
+		var zero V
+		return zero, false
 	}
-	c.mutex.RUnlock()
-	return nil, false
+
+	it := element.Value.(*item[K, V])
+	if !it.expires.IsZero() && !c.now().Before(it.expires) {
+		c.remove(element)
+		var zero V
+		return zero, false
+	}
+
+	c.queue.MoveToFront(element)
+	return it.value, true
 }
 
-// Set adds or updates a value in the cache
-func (c *Cache) Set(key string, value interface{}) {
+// Set adds or updates a value in the cache with a cost of 1
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithCost(key, value, 1)
+}
+
+// SetWithCost adds or updates a value in the cache with the given cost,
+// such as its size in bytes. A value costing more than MaxCost is not
+// kept.
+func (c *Cache[K, V]) SetWithCost(key K, value V, cost int64) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	c.set(key, value, cost)
+}
+
+// set adds or updates a value; the caller holds the mutex
+func (c *Cache[K, V]) set(key K, value V, cost int64) {
+	var expires time.Time
+	if c.opts.TTL > 0 {
+		expires = c.now().Add(c.opts.TTL)
+	}
 
 	// If key exists, update its value and move to front
 	if element, exists := c.items[key]; exists {
+		it := element.Value.(*item[K, V])
+		c.cost += cost - it.cost
+		it.value, it.cost, it.expires = value, cost, expires
 		c.queue.MoveToFront(element)
-		element.Value.(*item).value = value
-		return
+	} else {
+		element := c.queue.PushFront(&item[K, V]{key: key, value: value, cost: cost, expires: expires})
+		c.items[key] = element
+		c.cost += cost
 	}
 
-	// Add new item
-	element := c.queue.PushFront(&item{key: key, value: value})
-	c.items[key] = element
-
-	// Remove oldest item if cache is full
-	if c.queue.Len() > c.capacity {
+	// Remove oldest items while the cache is over its cost
+	for c.cost > c.opts.MaxCost {
 		oldest := c.queue.Back()
-		if oldest != nil {
-			c.queue.Remove(oldest)
-			delete(c.items, oldest.Value.(*item).key)
+		if oldest == nil {
+			break
 		}
+		c.remove(oldest)
 	}
 }
 
+// GetOrCompute returns the cached value of key, computing and caching it
+// with compute on a miss. Concurrent misses for the same key compute once
+// and share the result. Errors are returned to all waiting callers and not
+// cached.
+func (c *Cache[K, V]) GetOrCompute(key K, compute func() (V, error)) (V, error) {
+	c.mutex.Lock()
+	if value, ok := c.get(key); ok {
+		c.mutex.Unlock()
+		return value, nil
+	}
+	if pending, ok := c.calls[key]; ok {
+		c.mutex.Unlock()
+		<-pending.done
+		return pending.value, pending.err
+	}
+
+	pending := &call[V]{done: make(chan struct{})}
+	c.calls[key] = pending
+	c.mutex.Unlock()
+
+	// A panicking compute releases the waiters without caching a value
+	computed := false
+	defer func() {
+		c.mutex.Lock()
+		delete(c.calls, key)
+		if computed && pending.err == nil {
+			c.set(key, pending.value, 1)
+		}
+		c.mutex.Unlock()
+		close(pending.done)
+	}()
+
+	pending.value, pending.err = compute()
+	computed = true
+	return pending.value, pending.err
+}
+
 // Delete removes a value from the cache
-func (c *Cache) Delete(key string) {
+func (c *Cache[K, V]) Delete(key K) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	if element, exists := c.items[key]; exists {
-		c.queue.Remove(element)
-		delete(c.items, key)
+		c.remove(element)
 	}
 }
 
+// remove removes an element; the caller holds the mutex
+func (c *Cache[K, V]) remove(element *list.Element) {
+	it := element.Value.(*item[K, V])
+	c.queue.Remove(element)
+	delete(c.items, it.key)
+	c.cost -= it.cost
+}
+
 // Clear removes all items from the cache
-func (c *Cache) Clear() {
+func (c *Cache[K, V]) Clear() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.items = make(map[string]*list.Element)
+	c.items = make(map[K]*list.Element)
 	c.queue = list.New()
+	c.cost = 0
 }
 
-// Len returns the number of items in the cache
-func (c *Cache) Len() int {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+// Len returns the number of items in the cache, including expired items
+// not yet removed
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	return len(c.items)
 }
 
+// Cost returns the total cost of the items in the cache
+func (c *Cache[K, V]) Cost() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cost
+}
+
 // Keys returns all keys in the cache
-func (c *Cache) Keys() []string {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+func (c *Cache[K, V]) Keys() []K {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	keys := make([]string, 0, len(c.items))
+	keys := make([]K, 0, len(c.items))
 	for key := range c.items {
 		keys = append(keys, key)
 	}
 	return keys
-} 
\ No newline at end of file
+}
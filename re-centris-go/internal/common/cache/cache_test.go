@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheCostAndTTL(t *testing.T) {
+	c := New[string, int](Options{MaxCost: 10, TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.SetWithCost("a", 1, 4)
+	c.SetWithCost("b", 2, 4)
+	c.Get("a") // b becomes the least recently used
+	c.SetWithCost("c", 3, 4)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %v, %v", v, ok)
+	}
+	if c.Cost() != 8 {
+		t.Errorf("Cost() = %d, want 8", c.Cost())
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to expire")
+	}
+}
+
+func TestCacheGetOrCompute(t *testing.T) {
+	c := New[int, string](Options{MaxCost: 10})
+
+	var computed atomic.Int64
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrCompute(1, func() (string, error) {
+				computed.Add(1)
+				<-release
+				return "one", nil
+			})
+			if err != nil || v != "one" {
+				t.Errorf("GetOrCompute() = %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if computed.Load() != 1 {
+		t.Errorf("expected one computation, got %d", computed.Load())
+	}
+	if v, ok := c.Get(1); !ok || v != "one" {
+		t.Errorf("Get(1) = %q, %v", v, ok)
+	}
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// DefaultMaxSize is the default size limit of a disk cache in bytes (1GB)
+const DefaultMaxSize = 1 << 30
+
+// DiskOptions contains options for a disk cache
+type DiskOptions struct {
+	// Dir holds the cache entries
+	Dir string
+	// MaxSize is the total size of entries kept by GC in bytes;
+	// 0 means DefaultMaxSize
+	MaxSize int64
+}
+
+// DiskCache is a cache of content-addressed entries persisted in a
+// directory. Entries are files named by their key; GC evicts the least
+// recently used ones once the cache exceeds its size limit. It is safe for
+// concurrent use by several processes.
+type DiskCache struct {
+	opts DiskOptions
+
+	mutex   sync.Mutex
+	written int64 // bytes written since the last GC
+}
+
+// GCStats summarizes a garbage collection of a disk cache
+type GCStats struct {
+	Entries int   // entries kept
+	Size    int64 // bytes kept
+	Evicted int   // entries removed
+	Freed   int64 // bytes removed
+}
+
+// NewDisk creates a disk cache, creating its directory if needed
+func NewDisk(opts DiskOptions) (*DiskCache, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	return &DiskCache{opts: opts}, nil
+}
+
+// path returns the file of an entry. Entries are spread over directories
+// named by the first two characters of their key.
+func (c *DiskCache) path(key string) string {
+	if len(key) < 3 {
+		return filepath.Join(c.opts.Dir, "_", key)
+	}
+	return filepath.Join(c.opts.Dir, key[:2], key[2:])
+}
+
+// Get returns the data of an entry and marks it as recently used
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put stores the data of an entry. A GC runs once a tenth of the size
+// limit has been written since the last one.
+func (c *DiskCache) Put(key string, data []byte) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+
+	c.mutex.Lock()
+	c.written += int64(len(data))
+	gc := c.written > c.opts.MaxSize/10
+	if gc {
+		c.written = 0
+	}
+	c.mutex.Unlock()
+
+	if gc {
+		if _, err := c.GC(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GC removes the least recently used entries until the cache fits its
+// size limit
+func (c *DiskCache) GC() (GCStats, error) {
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var (
+		entries []entry
+		stats   GCStats
+	)
+	err := filepath.WalkDir(c.opts.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed by a concurrent GC
+			}
+			return err
+		}
+		// Skip directories and files still being written
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		stats.Size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to read cache directory: %v", err)
+	}
+
+	// Oldest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, e := range entries {
+		if stats.Size <= c.opts.MaxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return stats, fmt.Errorf("failed to remove cache entry: %v", err)
+		}
+		stats.Size -= e.size
+		stats.Evicted++
+		stats.Freed += e.size
+	}
+	stats.Entries = len(entries) - stats.Evicted
+
+	return stats, nil
+}
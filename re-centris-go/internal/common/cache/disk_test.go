@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	c, err := NewDisk(DiskOptions{Dir: t.TempDir(), MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Get("abcdef"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	for _, key := range []string{"aa01", "aa02", "bb03"} {
+		if err := c.Put(key, []byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, ok := c.Get("aa02"); !ok || string(data) != "0123456789" {
+		t.Fatalf("Get() = %q, %v", data, ok)
+	}
+
+	// Make aa01 the least and aa02 the most recently used entry
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(c.path("aa01"), old, old)
+	os.Chtimes(c.path("bb03"), old.Add(time.Minute), old.Add(time.Minute))
+
+	c.opts.MaxSize = 20
+	stats, err := c.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Evicted != 1 || stats.Entries != 2 || stats.Size != 20 {
+		t.Errorf("unexpected GC stats: %+v", stats)
+	}
+	if _, ok := c.Get("aa01"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := c.Get("aa02"); !ok {
+		t.Error("expected the most recently used entry to be kept")
+	}
+}
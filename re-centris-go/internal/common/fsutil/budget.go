@@ -0,0 +1,81 @@
+package fsutil
+
+import (
+	"os"
+	"sync"
+)
+
+// reservedFiles is the part of the descriptor limit left to files opened
+// outside the budget, such as outputs, shards, logs and sockets
+const reservedFiles = 64
+
+// defaultFileLimit is the descriptor limit assumed when it cannot be read
+const defaultFileLimit = 1024
+
+// FileBudget limits the number of files opened through it at once. Opens
+// beyond the budget wait until another file is closed.
+type FileBudget struct {
+	slots chan struct{}
+}
+
+// NewFileBudget creates a budget of n open files; n <= 0 means
+// DefaultFileBudget
+func NewFileBudget(n int) *FileBudget {
+	if n <= 0 {
+		n = DefaultFileBudget()
+	}
+	return &FileBudget{slots: make(chan struct{}, n)}
+}
+
+// DefaultFileBudget returns the soft RLIMIT_NOFILE of the process minus a
+// reserve for files opened outside the budget
+func DefaultFileBudget() int {
+	limit := fileLimit()
+	return max(limit-min(reservedFiles, limit/2), 1)
+}
+
+// Size returns the number of files the budget allows open at once
+func (b *FileBudget) Size() int {
+	return cap(b.slots)
+}
+
+// Open opens a file for reading like os.Open, waiting while the budget is
+// exhausted. The slot is released when the file is closed.
+func (b *FileBudget) Open(path string) (*File, error) {
+	b.slots <- struct{}{}
+	file, err := os.Open(path)
+	if err != nil {
+		<-b.slots
+		return nil, err
+	}
+	return &File{File: file, budget: b}, nil
+}
+
+// File is a file opened through a FileBudget
+type File struct {
+	*os.File
+	budget *FileBudget
+	once   sync.Once
+}
+
+// Close closes the file and releases its slot of the budget
+func (f *File) Close() error {
+	err := f.File.Close()
+	f.once.Do(func() { <-f.budget.slots })
+	return err
+}
+
+var (
+	files     *FileBudget
+	filesOnce sync.Once
+)
+
+// Open opens a file for reading through the process-wide budget of
+// DefaultFileBudget files. Files read concurrently by workers should be
+// opened with it so that high concurrency does not exhaust descriptors.
+func Open(path string) (*File, error) {
+	filesOnce.Do(func() {
+		files = NewFileBudget(0)
+	})
+	return files.Open(path)
+}
@@ -0,0 +1,51 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	budget := NewFileBudget(1)
+	first, err := budget.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened := make(chan *File)
+	go func() {
+		second, err := budget.Open(path)
+		if err != nil {
+			t.Error(err)
+		}
+		opened <- second
+	}()
+
+	select {
+	case <-opened:
+		t.Fatal("second open did not wait for the budget")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first.Close()
+	first.Close() // closing twice releases the slot once
+	second := <-opened
+	second.Close()
+
+	if len(budget.slots) != 0 {
+		t.Errorf("expected all slots released, got %d", len(budget.slots))
+	}
+	if _, err := budget.Open(filepath.Join(t.TempDir(), "missing")); err == nil || len(budget.slots) != 0 {
+		t.Errorf("expected failed open to release its slot, got %v", err)
+	}
+	if DefaultFileBudget() < 1 {
+		t.Errorf("DefaultFileBudget() = %d, want at least 1", DefaultFileBudget())
+	}
+}
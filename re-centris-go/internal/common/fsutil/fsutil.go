@@ -0,0 +1,136 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+)
+
+// syncWrites is set by SetSync
+var syncWrites atomic.Bool
+
+// SetSync makes atomic writes fsync the file before renaming it into place
+// and its directory after, so that the new file also survives a power
+// loss, not only a crash of the process. It is off by default.
+func SetSync(enabled bool) {
+	syncWrites.Store(enabled)
+}
+
+// Syncing reports whether atomic writes fsync, see SetSync
+func Syncing() bool {
+	return syncWrites.Load()
+}
+
+// AtomicFile is a file written under a temporary name next to its path
+// and renamed into place by Commit, so that readers never see it partly
+// written
+type AtomicFile struct {
+	*os.File
+	path string
+	perm os.FileMode
+	done bool
+}
+
+// CreateAtomic creates a temporary file that Commit renames to path,
+// creating the parent directories of path
+func CreateAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: tmp, path: path, perm: perm}, nil
+}
+
+// Commit closes the file and renames it into place
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	tmpPath := f.File.Name()
+
+	if Syncing() {
+		if err := f.File.Sync(); err != nil {
+			f.File.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, f.perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if Syncing() {
+		return SyncDir(filepath.Dir(f.path))
+	}
+	return nil
+}
+
+// Abort closes and removes the file, leaving any previous file at its
+// path in place. It does nothing after Commit.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+// SyncDir fsyncs a directory, persisting the files created or renamed in
+// it. Directories cannot be synced on Windows, where it does nothing.
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it into place, so a crash never leaves a truncated file behind
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := CreateAtomic(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// CorruptError reports an artifact that exists but cannot be decoded,
+// typically a file that was being appended to when a run crashed
+type CorruptError struct {
+	Path string
+	Err  error
+}
+
+// Error describes the corrupt artifact and how to recover
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("%s is corrupt, delete or regenerate it: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the decoding error
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
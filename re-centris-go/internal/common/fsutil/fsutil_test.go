@@ -0,0 +1,48 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out", "results.json")
+	if err := WriteFileAtomic(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// An aborted write leaves the previous file in place
+	f, err := CreateAtomic(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("partial"))
+	f.Abort()
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("after Abort() file = %q, want old", data)
+	}
+
+	SetSync(true)
+	defer SetSync(false)
+	f, err = CreateAtomic(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("new"))
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("before Commit() file = %q, want old", data)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("after Commit() file = %q, want new", data)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory has %v, want only the output without temporary files", entries)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package fsutil
+
+// fileLimit returns the assumed limit on open files on platforms without
+// RLIMIT_NOFILE
+func fileLimit() int {
+	return defaultFileLimit
+}
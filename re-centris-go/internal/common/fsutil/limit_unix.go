@@ -0,0 +1,15 @@
+//go:build unix
+
+package fsutil
+
+import "syscall"
+
+// fileLimit returns the soft limit on open file descriptors. The Go runtime
+// raises it to the hard limit at startup.
+func fileLimit() int {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil || rlimit.Cur == 0 {
+		return defaultFileLimit
+	}
+	return int(min(rlimit.Cur, 1<<20))
+}
@@ -0,0 +1,50 @@
+package intern
+
+import (
+	"strings"
+	"sync"
+)
+
+// Pool is a thread-safe string interning pool. Strings interned through the
+// same pool share a single backing copy, which keeps repeated paths and names
+// in large index structures from being duplicated in memory.
+type Pool struct {
+	strings map[string]string
+	mutex   sync.RWMutex
+}
+
+// New creates a new interning pool
+func New() *Pool {
+	return &Pool{
+		strings: make(map[string]string),
+	}
+}
+
+// Intern returns the canonical copy of s
+func (p *Pool) Intern(s string) string {
+	p.mutex.RLock()
+	if interned, exists := p.strings[s]; exists {
+		p.mutex.RUnlock()
+		return interned
+	}
+	p.mutex.RUnlock()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if interned, exists := p.strings[s]; exists {
+		return interned
+	}
+
+	// Clone so the pool never retains a larger string s was sliced from
+	interned := strings.Clone(s)
+	p.strings[interned] = interned
+	return interned
+}
+
+// Len returns the number of interned strings
+func (p *Pool) Len() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return len(p.strings)
+}
@@ -0,0 +1,274 @@
+// Package jsonschema validates JSON documents against the subset of JSON
+// Schema used by the schemas Re-Centris publishes: type, enum, const,
+// properties, required, additionalProperties, items, minimum, maximum and
+// local $ref pointers into $defs.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	root *node
+}
+
+// node is a single schema object
+type node struct {
+	Ref                  string           `json:"$ref"`
+	Defs                 map[string]*node `json:"$defs"`
+	Type                 typeList         `json:"type"`
+	Enum                 []interface{}    `json:"enum"`
+	Const                interface{}      `json:"const"`
+	Properties           map[string]*node `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties *additional      `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	Minimum              *float64         `json:"minimum"`
+	Maximum              *float64         `json:"maximum"`
+}
+
+// typeList is a type keyword, which may be a single type or a list
+type typeList []string
+
+// UnmarshalJSON accepts both "string" and ["string", "null"]
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = typeList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// additional is an additionalProperties keyword, either false or a schema
+type additional struct {
+	forbidden bool
+	schema    *node
+}
+
+// UnmarshalJSON accepts a boolean or a schema
+func (a *additional) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.forbidden = !allowed
+		return nil
+	}
+	a.schema = &node{}
+	return json.Unmarshal(data, a.schema)
+}
+
+// Compile parses a JSON Schema document
+func Compile(data []byte) (*Schema, error) {
+	root := &node{}
+	if err := json.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %v", err)
+	}
+	return &Schema{root: root}, nil
+}
+
+// Def returns the schema of a definition in $defs
+func (s *Schema) Def(name string) (*Schema, error) {
+	if _, ok := s.root.Defs[name]; !ok {
+		return nil, fmt.Errorf("schema has no definition %q", name)
+	}
+	// The definition keeps resolving references against the root
+	return &Schema{root: &node{Ref: "#/$defs/" + name, Defs: s.root.Defs}}, nil
+}
+
+// Validate checks a JSON document and returns every violation found
+func (s *Schema) Validate(data []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to parse document: %v", err)
+	}
+	return s.ValidateValue(v), nil
+}
+
+// ValidateValue checks a decoded JSON value. Numbers must be decoded as
+// json.Number or float64.
+func (s *Schema) ValidateValue(v interface{}) []string {
+	var errs []string
+	s.validate(s.root, v, "", &errs)
+	return errs
+}
+
+// validate checks v against n, appending violations at path to errs
+func (s *Schema) validate(n *node, v interface{}, path string, errs *[]string) {
+	if n.Ref != "" {
+		ref, err := s.resolve(n.Ref)
+		if err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: %v", location(path), err))
+			return
+		}
+		n = ref
+	}
+
+	if len(n.Type) > 0 && !matchesType(n.Type, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", location(path), strings.Join(n.Type, " or "), typeOf(v)))
+		return
+	}
+	if n.Const != nil && !equal(n.Const, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %v", location(path), n.Const))
+	}
+	if len(n.Enum) > 0 {
+		found := false
+		for _, e := range n.Enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is not one of %v", location(path), v, n.Enum))
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(n, value, path, errs)
+	case []interface{}:
+		if n.Items != nil {
+			for i, item := range value {
+				s.validate(n.Items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	default:
+		if f, ok := number(v); ok {
+			if n.Minimum != nil && f < *n.Minimum {
+				*errs = append(*errs, fmt.Sprintf("%s: %v is less than %v", location(path), f, *n.Minimum))
+			}
+			if n.Maximum != nil && f > *n.Maximum {
+				*errs = append(*errs, fmt.Sprintf("%s: %v is greater than %v", location(path), f, *n.Maximum))
+			}
+		}
+	}
+}
+
+// validateObject checks the properties of an object
+func (s *Schema) validateObject(n *node, value map[string]interface{}, path string, errs *[]string) {
+	for _, name := range n.Required {
+		if _, ok := value[name]; !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", location(path), name))
+		}
+	}
+
+	// Check properties in a stable order so violations are reproducible
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := path + "." + name
+		if prop, ok := n.Properties[name]; ok {
+			s.validate(prop, value[name], child, errs)
+			continue
+		}
+		if n.AdditionalProperties == nil {
+			continue
+		}
+		if n.AdditionalProperties.forbidden {
+			*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", location(path), name))
+		} else if n.AdditionalProperties.schema != nil {
+			s.validate(n.AdditionalProperties.schema, value[name], child, errs)
+		}
+	}
+}
+
+// resolve looks up a local reference of the form #/$defs/name
+func (s *Schema) resolve(ref string) (*node, error) {
+	name := strings.TrimPrefix(ref, "#/$defs/")
+	if name == ref {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	def, ok := s.root.Defs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference %q", ref)
+	}
+	return def, nil
+}
+
+// matchesType reports whether v has one of the given JSON types
+func matchesType(types typeList, v interface{}) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual {
+			return true
+		}
+		if t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON type of a decoded value
+func typeOf(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// number returns the value of a decoded JSON number
+func number(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case float64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+// equal compares a schema constant with a decoded value
+func equal(want, v interface{}) bool {
+	if f, ok := number(v); ok {
+		w, ok := number(want)
+		return ok && w == f
+	}
+	return fmt.Sprint(want) == fmt.Sprint(v)
+}
+
+// location formats a value path for error messages
+func location(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return "$" + path
+}
@@ -0,0 +1,66 @@
+package jsonstream
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Array reads a JSON array from dec element by element. fn is called once
+// per element and must decode exactly one value from dec, which keeps only
+// a single element in memory at a time.
+func Array(dec *json.Decoder, fn func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, ']')
+}
+
+// Object reads a JSON object from dec key by key. fn is called with each
+// key and must decode exactly one value from dec. Values of unknown keys
+// can be skipped with Skip.
+func Object(dec *json.Decoder, fn func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read object key: %v", err)
+		}
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("unexpected object key: %v", token)
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, '}')
+}
+
+// Skip consumes the next value from dec without decoding it
+func Skip(dec *json.Decoder) error {
+	var raw json.RawMessage
+	return dec.Decode(&raw)
+}
+
+// expectDelim reads the next token and checks that it is delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read JSON: %v", err)
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
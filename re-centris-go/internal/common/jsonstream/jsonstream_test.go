@@ -0,0 +1,51 @@
+package jsonstream
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestObjectAndArray(t *testing.T) {
+	input := `{"name": "corpus", "skip": {"nested": [1, 2]}, "items": [{"id": 1}, {"id": 2}, {"id": 3}]}`
+	dec := json.NewDecoder(strings.NewReader(input))
+
+	var (
+		name string
+		ids  []int
+	)
+	err := Object(dec, func(key string) error {
+		switch key {
+		case "name":
+			return dec.Decode(&name)
+		case "items":
+			return Array(dec, func() error {
+				var item struct{ ID int }
+				if err := dec.Decode(&item); err != nil {
+					return err
+				}
+				ids = append(ids, item.ID)
+				return nil
+			})
+		default:
+			return Skip(dec)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Object() error = %v", err)
+	}
+
+	if name != "corpus" {
+		t.Errorf("name = %v, want corpus", name)
+	}
+	if len(ids) != 3 || ids[2] != 3 {
+		t.Errorf("ids = %v, want [1 2 3]", ids)
+	}
+}
+
+func TestArrayRejectsObject(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"a": 1}`))
+	if err := Array(dec, func() error { return Skip(dec) }); err == nil {
+		t.Error("Array() error = nil, want error for object input")
+	}
+}
@@ -2,12 +2,47 @@ package logger
 
 import (
 	"os"
+	"sync"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-var log *zap.Logger
+// log discards messages until Init is called, so packages can log from tests
+var log = zap.NewNop()
+
+// console is the standard output of the logger
+var console = &consoleWriter{}
+
+// consoleWriter writes log lines to stdout, around the interrupt function
+// if set
+type consoleWriter struct {
+	interrupt func(write func())
+	mutex     sync.Mutex
+}
+
+func (c *consoleWriter) Write(p []byte) (n int, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.interrupt == nil {
+		return os.Stdout.Write(p)
+	}
+	c.interrupt(func() { n, err = os.Stdout.Write(p) })
+	return n, err
+}
+
+func (c *consoleWriter) Sync() error {
+	return nil
+}
+
+// SetInterrupt makes the logger write to stdout through interrupt, such as
+// to clear and redraw a progress bar on the same terminal; nil writes
+// directly
+func SetInterrupt(interrupt func(write func())) {
+	console.mutex.Lock()
+	console.interrupt = interrupt
+	console.mutex.Unlock()
+}
 
 // Init initializes the logger
 func Init(debug bool) {
@@ -15,12 +50,16 @@ func Init(debug bool) {
 	if debug {
 		config.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
 	}
-	
-	config.OutputPaths = []string{"stdout", "re-centris.log"}
+
+	config.OutputPaths = []string{"re-centris.log"}
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	
+
+	// stdout goes through console, so progress bars can make way for it
+	stdout := zapcore.NewCore(zapcore.NewJSONEncoder(config.EncoderConfig), console, config.Level)
 	var err error
-	log, err = config.Build()
+	log, err = config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(stdout, core)
+	}))
 	if err != nil {
 		os.Exit(1)
 	}
@@ -54,4 +93,4 @@ func Fatal(msg string, fields ...zap.Field) {
 // Sync flushes any buffered log entries
 func Sync() error {
 	return log.Sync()
-} 
\ No newline at end of file
+}
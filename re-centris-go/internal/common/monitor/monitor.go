@@ -11,17 +11,17 @@ import (
 
 // Stats represents performance statistics
 type Stats struct {
-	Goroutines  int
-	Memory      uint64
-	CPU         float64
-	StartTime   time.Time
-	Operations  uint64
-	mutex       sync.RWMutex
+	Goroutines int
+	Memory     uint64
+	CPU        float64
+	StartTime  time.Time
+	Operations uint64
 }
 
 // Monitor handles performance monitoring
 type Monitor struct {
 	stats    *Stats
+	mutex    sync.RWMutex // guards stats
 	interval time.Duration
 	done     chan struct{}
 }
@@ -49,16 +49,16 @@ func (m *Monitor) Stop() {
 
 // GetStats returns current statistics
 func (m *Monitor) GetStats() Stats {
-	m.stats.mutex.RLock()
-	defer m.stats.mutex.RUnlock()
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 	return *m.stats
 }
 
 // IncrementOperations increments the operation counter
 func (m *Monitor) IncrementOperations() {
-	m.stats.mutex.Lock()
+	m.mutex.Lock()
 	m.stats.Operations++
-	m.stats.mutex.Unlock()
+	m.mutex.Unlock()
 }
 
 // monitor periodically collects performance metrics
@@ -78,8 +78,8 @@ func (m *Monitor) monitor() {
 
 // collectMetrics collects current performance metrics
 func (m *Monitor) collectMetrics() {
-	m.stats.mutex.Lock()
-	defer m.stats.mutex.Unlock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
 	// Get number of goroutines
 	m.stats.Goroutines = runtime.NumGoroutine()
@@ -115,4 +115,4 @@ func (m *Monitor) CheckMemoryLimit(limit float64) bool {
 	}
 
 	return true
-} 
\ No newline at end of file
+}
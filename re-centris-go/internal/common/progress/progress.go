@@ -0,0 +1,311 @@
+// Package progress reports the progress of long-running stages, such as
+// analyzing a corpus, to a renderer: a progress bar on a terminal or
+// machine-readable JSON events.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress modes selectable on the command line
+const (
+	// ModeAuto renders a bar if stderr is a terminal and nothing otherwise
+	ModeAuto = "auto"
+	// ModeBar always renders a bar
+	ModeBar = "bar"
+	// ModeJSON emits one JSON event per line
+	ModeJSON = "json"
+	// ModeNone disables progress reporting
+	ModeNone = "none"
+)
+
+// defaultInterval is the minimum time between two events of a stage
+const defaultInterval = 200 * time.Millisecond
+
+// Event is the state of a stage
+type Event struct {
+	Stage     string        `json:"stage"`
+	Total     int           `json:"total"`
+	Completed int           `json:"completed"`
+	Elapsed   time.Duration `json:"-"`
+	ETA       time.Duration `json:"-"`
+	Done      bool          `json:"done"`
+}
+
+// MarshalJSON encodes durations as milliseconds
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	v := struct {
+		event
+		Elapsed int64 `json:"elapsed_ms"`
+		ETA     int64 `json:"eta_ms"`
+	}{event(e), e.Elapsed.Milliseconds(), e.ETA.Milliseconds()}
+	return json.Marshal(v)
+}
+
+// Renderer displays progress events. Render is never called concurrently.
+type Renderer interface {
+	Render(e Event)
+}
+
+// Reporter tracks stages and passes their events to a renderer. A nil
+// Reporter discards all progress, so callers need not check for it.
+type Reporter struct {
+	renderer Renderer
+	interval time.Duration
+	mutex    sync.Mutex
+}
+
+// Stage is a unit of work with a known or growing number of items
+type Stage struct {
+	r         *Reporter
+	name      string
+	start     time.Time
+	total     int
+	completed int
+	last      time.Time
+}
+
+// New creates a Reporter rendering to r
+func New(r Renderer) *Reporter {
+	return &Reporter{renderer: r, interval: defaultInterval}
+}
+
+// ForMode creates a Reporter for a progress mode writing to stderr, or nil
+// if progress is disabled
+func ForMode(mode string) (*Reporter, error) {
+	r, err := RendererForMode(mode)
+	if r == nil || err != nil {
+		return nil, err
+	}
+	return New(r), nil
+}
+
+// RendererForMode returns the renderer of a progress mode writing to
+// stderr, or nil if progress is disabled
+func RendererForMode(mode string) (Renderer, error) {
+	switch mode {
+	case ModeAuto, "":
+		if !isTerminal(os.Stderr) {
+			return nil, nil
+		}
+		return NewBar(os.Stderr), nil
+	case ModeBar:
+		return NewBar(os.Stderr), nil
+	case ModeJSON:
+		return NewJSON(os.Stderr), nil
+	case ModeNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported progress mode: %s", mode)
+	}
+}
+
+// Stage starts a stage with total items; total may grow with AddTotal
+func (r *Reporter) Stage(name string, total int) *Stage {
+	if r == nil {
+		return nil
+	}
+	s := &Stage{r: r, name: name, start: time.Now(), total: total}
+	r.mutex.Lock()
+	s.render(true)
+	r.mutex.Unlock()
+	return s
+}
+
+// AddTotal adds items discovered while the stage runs
+func (s *Stage) AddTotal(n int) {
+	if s == nil {
+		return
+	}
+	s.r.mutex.Lock()
+	s.total += n
+	s.r.mutex.Unlock()
+}
+
+// Add marks n items as completed
+func (s *Stage) Add(n int) {
+	if s == nil {
+		return
+	}
+	s.r.mutex.Lock()
+	s.completed += n
+	s.render(false)
+	s.r.mutex.Unlock()
+}
+
+// Done finishes the stage
+func (s *Stage) Done() {
+	if s == nil {
+		return
+	}
+	s.r.mutex.Lock()
+	defer s.r.mutex.Unlock()
+
+	e := s.event()
+	e.Done = true
+	e.ETA = 0
+	s.r.renderer.Render(e)
+}
+
+// render passes the current state to the renderer unless the last event
+// is more recent than the interval; the caller must hold the mutex
+func (s *Stage) render(force bool) {
+	now := time.Now()
+	if !force && now.Sub(s.last) < s.r.interval {
+		return
+	}
+	s.last = now
+	s.r.renderer.Render(s.event())
+}
+
+// event returns the current state; the caller must hold the mutex
+func (s *Stage) event() Event {
+	e := Event{
+		Stage:     s.name,
+		Total:     s.total,
+		Completed: s.completed,
+		Elapsed:   time.Since(s.start),
+	}
+	if s.completed > 0 && s.total > s.completed {
+		e.ETA = time.Duration(float64(e.Elapsed) * float64(s.total-s.completed) / float64(s.completed))
+	}
+	return e
+}
+
+// isTerminal reports whether f is a character device
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// barWidth is the number of cells of a progress bar
+const barWidth = 30
+
+// Interrupter is a renderer sharing its terminal with other output.
+// Interrupt removes the rendered progress, calls write and renders it
+// again, so that lines written by write are not torn by the progress.
+type Interrupter interface {
+	Interrupt(write func())
+}
+
+// bar renders events as a single progress bar line, redrawn in place
+type bar struct {
+	w     io.Writer
+	line  string // the line currently drawn, empty after a finished stage
+	mutex sync.Mutex
+}
+
+// NewBar creates a renderer drawing a progress bar on a terminal
+func NewBar(w io.Writer) Renderer {
+	return &bar{w: w}
+}
+
+func (b *bar) Render(e Event) {
+	filled := 0
+	percent := 0.0
+	if e.Total > 0 {
+		percent = float64(e.Completed) / float64(e.Total)
+		if percent > 1 {
+			percent = 1
+		}
+		filled = int(percent * barWidth)
+	}
+
+	line := fmt.Sprintf("%-10s [%s%s] %d/%d %3.0f%%",
+		e.Stage,
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", barWidth-filled),
+		e.Completed, e.Total, percent*100)
+	switch {
+	case e.Done:
+		line += fmt.Sprintf(" in %s", e.Elapsed.Round(time.Second))
+	case e.ETA > 0:
+		line += fmt.Sprintf(" ETA %s", e.ETA.Round(time.Second))
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if e.Done {
+		fmt.Fprintf(b.w, "\r\033[K%s\n", line)
+		b.line = ""
+		return
+	}
+	fmt.Fprintf(b.w, "\r\033[K%s", line)
+	b.line = line
+}
+
+func (b *bar) Interrupt(write func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.line != "" {
+		fmt.Fprint(b.w, "\r\033[K")
+	}
+	write()
+	if b.line != "" {
+		fmt.Fprint(b.w, b.line)
+	}
+}
+
+// jsonRenderer writes one JSON event per line
+type jsonRenderer struct {
+	enc *json.Encoder
+}
+
+// NewJSON creates a renderer emitting machine-readable JSON events
+func NewJSON(w io.Writer) Renderer {
+	return &jsonRenderer{enc: json.NewEncoder(w)}
+}
+
+func (j *jsonRenderer) Render(e Event) {
+	j.enc.Encode(e)
+}
+
+// multi passes events to several renderers
+type multi []Renderer
+
+// Tee creates a renderer passing every event to each of renderers
+func Tee(renderers ...Renderer) Renderer {
+	return multi(renderers)
+}
+
+func (m multi) Render(e Event) {
+	for _, r := range m {
+		r.Render(e)
+	}
+}
+
+// Recorder is a renderer keeping the final event of every finished stage,
+// e.g. to report stage timings after a run
+type Recorder struct {
+	done  []Event
+	mutex sync.Mutex
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Render(e Event) {
+	if !e.Done {
+		return
+	}
+	r.mutex.Lock()
+	r.done = append(r.done, e)
+	r.mutex.Unlock()
+}
+
+// Stages returns the final events of the finished stages in the order
+// they finished
+func (r *Recorder) Stages() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Event(nil), r.done...)
+}
@@ -0,0 +1,82 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(NewJSON(&buf))
+	r.interval = 0
+
+	s := r.Stage("analyze", 2)
+	s.Add(1)
+	s.AddTotal(2)
+	s.Add(3)
+	s.Done()
+
+	var events []Event
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("events = %d, want 4", len(events))
+	}
+	last := events[len(events)-1]
+	if last.Stage != "analyze" || last.Total != 4 || last.Completed != 4 || !last.Done {
+		t.Errorf("last event = %+v, want analyze 4/4 done", last)
+	}
+
+	// A nil reporter discards progress
+	var nop *Reporter
+	nop.Stage("detect", 1).Add(1)
+}
+
+func TestBarInterrupt(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBar(&buf)
+	b.Render(Event{Stage: "analyze", Total: 2, Completed: 1})
+	b.(Interrupter).Interrupt(func() { buf.WriteString("log\n") })
+
+	// The bar is cleared before the log line and redrawn after it
+	want := "log\nanalyze    ["
+	if got := buf.String(); !strings.Contains(got, "\r\033[K"+want) {
+		t.Errorf("output = %q, want bar cleared around %q", got, "log\n")
+	}
+
+	buf.Reset()
+	b.Render(Event{Stage: "analyze", Total: 2, Completed: 2, Done: true})
+	buf.Reset()
+	b.(Interrupter).Interrupt(func() { buf.WriteString("log\n") })
+	if got := buf.String(); got != "log\n" {
+		t.Errorf("output after done = %q, want %q", got, "log\n")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder()
+	r := New(Tee(NewJSON(&buf), rec))
+
+	r.Stage("analyze", 1).Done()
+	s := r.Stage("detect", 2)
+	s.Add(2)
+	s.Done()
+
+	stages := rec.Stages()
+	if len(stages) != 2 || stages[0].Stage != "analyze" || stages[1].Stage != "detect" || stages[1].Completed != 2 {
+		t.Errorf("stages = %+v, want analyze and detect 2/2", stages)
+	}
+	if buf.Len() == 0 {
+		t.Error("events were not passed to every renderer")
+	}
+}
@@ -0,0 +1,38 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	start := time.Date(2024, time.March, 15, 10, 17, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, time.March, 16, 3, 0, 0, 0, time.UTC)},
+		{"30 2 * * 1-5", time.Date(2024, time.March, 18, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"@every 90m", start.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(start); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "@every -1h"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) error = nil, want error", spec)
+		}
+	}
+}
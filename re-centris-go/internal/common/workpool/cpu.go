@@ -0,0 +1,68 @@
+package workpool
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroup files holding the CPU quota of the process, for cgroup v2 and v1
+const (
+	cgroupV2CPUMax = "/sys/fs/cgroup/cpu.max"
+	cgroupV1Quota  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupV1Period = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+)
+
+// DefaultSize returns the number of CPUs available to the process:
+// GOMAXPROCS, lowered to the CPU quota of its cgroup if one is set, for
+// example by a container runtime. It is at least 1.
+func DefaultSize() int {
+	n := runtime.GOMAXPROCS(0)
+	if quota, ok := cgroupCPUs(); ok {
+		n = min(n, int(math.Ceil(quota)))
+	}
+	return max(n, 1)
+}
+
+// cgroupCPUs returns the CPU quota of the cgroup of the process in CPUs
+func cgroupCPUs() (float64, bool) {
+	if data, err := os.ReadFile(cgroupV2CPUMax); err == nil {
+		return parseCPUMax(string(data))
+	}
+
+	quota, err := os.ReadFile(cgroupV1Quota)
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile(cgroupV1Period)
+	if err != nil {
+		return 0, false
+	}
+	return parseQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// parseCPUMax parses the "<quota> <period>" content of a cgroup v2
+// cpu.max file; a quota of "max" means no limit
+func parseCPUMax(content string) (float64, bool) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return 0, false
+	}
+	return parseQuota(fields[0], fields[1])
+}
+
+// parseQuota returns quota/period in CPUs. Unlimited quotas are "max" in
+// cgroup v2 and negative in v1.
+func parseQuota(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
@@ -0,0 +1,111 @@
+// Package workpool shares a global concurrency limit between the worker
+// groups of the pipeline stages, so that stages running side by side do
+// not oversubscribe CPU and file handles.
+package workpool
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Pool is a global limit on the number of tasks running at once
+type Pool struct {
+	slots chan struct{}
+}
+
+// New creates a Pool running at most size tasks at once; size <= 0 means
+// DefaultSize
+func New(size int) *Pool {
+	if size <= 0 {
+		size = DefaultSize()
+	}
+	return &Pool{slots: make(chan struct{}, size)}
+}
+
+// Size returns the number of tasks the pool runs at once
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+// Running returns the number of tasks currently running
+func (p *Pool) Running() int {
+	return len(p.slots)
+}
+
+// Group runs the tasks of one stage. A task starts once both a slot of the
+// group and a slot of the pool are free; until then Go blocks, which pushes
+// back on the producer instead of queueing without bound.
+type Group struct {
+	g     *errgroup.Group
+	ctx   context.Context
+	pool  *Pool
+	local chan struct{}
+}
+
+// WithContext creates a Group drawing from pool with at most limit tasks of
+// its own running at once; limit <= 0 means no limit besides the pool. A nil
+// pool makes the Group behave like an errgroup.Group with SetLimit(limit).
+func WithContext(ctx context.Context, pool *Pool, limit int) (*Group, context.Context) {
+	g, ctx := errgroup.WithContext(ctx)
+	group := &Group{g: g, ctx: ctx, pool: pool}
+	switch {
+	case pool == nil:
+		g.SetLimit(limit)
+	case limit > 0:
+		group.local = make(chan struct{}, limit)
+	}
+	return group, ctx
+}
+
+// Go runs fn in a new goroutine, blocking until it may start. If the
+// context of the group is done first, fn is not run and the group fails
+// with the context error.
+func (g *Group) Go(fn func() error) {
+	if g.pool == nil {
+		g.g.Go(fn)
+		return
+	}
+
+	if g.local != nil {
+		select {
+		case g.local <- struct{}{}:
+		case <-g.ctx.Done():
+			g.fail()
+			return
+		}
+	}
+	select {
+	case g.pool.slots <- struct{}{}:
+	case <-g.ctx.Done():
+		g.release(false)
+		g.fail()
+		return
+	}
+
+	g.g.Go(func() error {
+		defer g.release(true)
+		return fn()
+	})
+}
+
+// Wait blocks until all tasks returned and returns the first error
+func (g *Group) Wait() error {
+	return g.g.Wait()
+}
+
+// release frees the slot of the group and, if held, of the pool
+func (g *Group) release(pool bool) {
+	if pool {
+		<-g.pool.slots
+	}
+	if g.local != nil {
+		<-g.local
+	}
+}
+
+// fail records the context error of the group
+func (g *Group) fail() {
+	err := context.Cause(g.ctx)
+	g.g.Go(func() error { return err })
+}
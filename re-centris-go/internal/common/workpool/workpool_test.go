@@ -0,0 +1,103 @@
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolLimitsGroups(t *testing.T) {
+	pool := New(2)
+
+	var running, peak atomic.Int64
+	task := func() error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	}
+
+	// Two stages with a local limit of 2 each share a pool of 2
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g, _ := WithContext(context.Background(), pool, 2)
+			for j := 0; j < 10; j++ {
+				g.Go(task)
+			}
+			if err := g.Wait(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 2 {
+		t.Errorf("expected at most 2 running tasks, got %d", peak.Load())
+	}
+	if pool.Running() != 0 {
+		t.Errorf("expected all slots released, got %d", pool.Running())
+	}
+}
+
+func TestGroupStopsWhenCancelled(t *testing.T) {
+	pool := New(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	g, _ := WithContext(ctx, pool, 1)
+
+	// Hold the only slot until the context is cancelled
+	release := make(chan struct{})
+	g.Go(func() error {
+		<-release
+		return nil
+	})
+
+	var ran atomic.Int64
+	time.AfterFunc(5*time.Millisecond, cancel)
+	g.Go(func() error {
+		ran.Add(1)
+		return nil
+	})
+	close(release)
+
+	if err := g.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if ran.Load() != 0 {
+		t.Errorf("expected the blocked task not to run, got %d", ran.Load())
+	}
+}
+
+func TestParseCPUMax(t *testing.T) {
+	tests := []struct {
+		content string
+		cpus    float64
+		ok      bool
+	}{
+		{"200000 100000\n", 2, true},
+		{"150000 100000", 1.5, true},
+		{"max 100000\n", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		cpus, ok := parseCPUMax(tt.content)
+		if cpus != tt.cpus || ok != tt.ok {
+			t.Errorf("parseCPUMax(%q) = %v, %v, want %v, %v", tt.content, cpus, ok, tt.cpus, tt.ok)
+		}
+	}
+
+	if n := DefaultSize(); n < 1 {
+		t.Errorf("DefaultSize() = %d, want at least 1", n)
+	}
+}
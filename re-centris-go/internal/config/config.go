@@ -1,65 +1,290 @@
+// Package config defines the schema of the configuration file. Keys are the
+// ones the commands read through viper, so a file accepted by Load
+// configures the CLI exactly as written.
 package config
 
+import (
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser/cpp"
+	"github.com/re-centris/re-centris-go/internal/cluster"
+	"github.com/re-centris/re-centris-go/internal/common/cache"
+	"github.com/re-centris/re-centris-go/internal/common/progress"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"github.com/re-centris/re-centris-go/internal/webhook"
+)
+
 // Config represents the main configuration structure
 type Config struct {
-	Paths       PathConfig       `yaml:"paths"`
-	Performance PerformanceConfig `yaml:"performance"`
-	Languages   LanguagesConfig  `yaml:"languages"`
+	Debug             bool          `mapstructure:"debug"`
+	Progress          string        `mapstructure:"progress"`
+	Symlinks          string        `mapstructure:"symlinks"`
+	Include           []string      `mapstructure:"include"`
+	Exclude           []string      `mapstructure:"exclude"`
+	Gitignore         bool          `mapstructure:"gitignore"`
+	NoSniff           bool          `mapstructure:"no_sniff"`
+	SniffLanguages    bool          `mapstructure:"sniff_languages"`
+	SignatureMode     string        `mapstructure:"signature_mode"`
+	Encoding          string        `mapstructure:"encoding"`
+	Conditionals      string        `mapstructure:"conditionals"`
+	MaxFileSize       int64         `mapstructure:"max_file_size"`
+	ChunkedSize       int64         `mapstructure:"chunked_size"`
+	MaxConcurrency    int           `mapstructure:"max_concurrency"`
+	Fsync             bool          `mapstructure:"fsync"`
+	RunManifest       string        `mapstructure:"run_manifest"`
+	Pprof             string        `mapstructure:"pprof"`
+	CPUProfile        string        `mapstructure:"cpuprofile"`
+	MemProfile        string        `mapstructure:"memprofile"`
+	FileTimeout       time.Duration `mapstructure:"file_timeout"`
+	SlowFileThreshold time.Duration `mapstructure:"slow_file_threshold"`
+
+	Cache     CacheConfig                  `mapstructure:"cache"`
+	Signing   SigningConfig                `mapstructure:"signing"`
+	Snapshot  SnapshotConfig               `mapstructure:"snapshot"`
+	Languages map[string]LanguageSettings  `mapstructure:"languages"`
+	Normalize map[string]normalize.Options `mapstructure:"normalize"`
+
+	Clone      CloneConfig      `mapstructure:"clone"`
+	Analyze    AnalyzeConfig    `mapstructure:"analyze"`
+	Preprocess PreprocessConfig `mapstructure:"preprocess"`
+	Detect     DetectConfig     `mapstructure:"detect"`
+	Pipeline   PipelineConfig   `mapstructure:"pipeline"`
+	Scan       ScanConfig       `mapstructure:"scan"`
+	Cluster    ClusterConfig    `mapstructure:"cluster"`
+	Serve      ServeConfig      `mapstructure:"serve"`
 }
 
-// PathConfig contains all path-related configurations
-type PathConfig struct {
-	RepoPath    string `yaml:"repo_path"`
-	TagDatePath string `yaml:"tag_date_path"`
-	ResultPath  string `yaml:"result_path"`
+// CacheConfig contains settings for the analysis cache
+type CacheConfig struct {
+	Dir     string `mapstructure:"dir"`
+	MaxSize int64  `mapstructure:"max_size"`
 }
 
-// PerformanceConfig contains performance-related settings
-type PerformanceConfig struct {
-	MaxWorkers   int     `yaml:"max_workers"`
-	CacheSize    int     `yaml:"cache_size"`
-	MemoryLimit  float64 `yaml:"memory_limit"`
+// SigningConfig contains the ed25519 keys signing and verifying result and
+// SBOM files
+type SigningConfig struct {
+	Key       string `mapstructure:"key"`
+	PublicKey string `mapstructure:"public_key"`
 }
 
-// LanguagesConfig contains settings for supported languages
-type LanguagesConfig struct {
-	CPP    LanguageSettings `yaml:"cpp"`
-	Java   LanguageSettings `yaml:"java"`
-	Python LanguageSettings `yaml:"python"`
+// SnapshotConfig contains settings for the corpus snapshot store
+type SnapshotConfig struct {
+	Store string `mapstructure:"store"`
+	Keep  int    `mapstructure:"keep"`
 }
 
-// LanguageSettings contains settings for a specific language
+// LanguageSettings contains settings for a specific language. A language
+// without an enabled key stays enabled.
 type LanguageSettings struct {
-	Enabled    bool     `yaml:"enabled"`
-	Extensions []string `yaml:"extensions"`
+	Enabled    *bool    `mapstructure:"enabled"`
+	Extensions []string `mapstructure:"extensions"`
 }
 
-// DefaultConfig returns a default configuration
+// Disabled reports whether the language is explicitly disabled
+func (s LanguageSettings) Disabled() bool {
+	return s.Enabled != nil && !*s.Enabled
+}
+
+// CloneConfig contains settings for the clone command
+type CloneConfig struct {
+	RepoList string `mapstructure:"repo_list"`
+	Output   string `mapstructure:"output"`
+	Workers  int    `mapstructure:"workers"`
+	Tags     bool   `mapstructure:"tags"`
+	Metadata bool   `mapstructure:"metadata"`
+}
+
+// AnalyzeConfig contains settings for the analyze command
+type AnalyzeConfig struct {
+	Output           string `mapstructure:"output"`
+	Workers          int    `mapstructure:"workers"`
+	Format           string `mapstructure:"format"`
+	ErrorPolicy      string `mapstructure:"error_policy"`
+	ErrorReport      string `mapstructure:"error_report"`
+	PairDeclarations bool   `mapstructure:"pair_declarations"`
+}
+
+// PreprocessConfig contains settings for the preprocess command
+type PreprocessConfig struct {
+	Output                string `mapstructure:"output"`
+	Workers               int    `mapstructure:"workers"`
+	Resume                bool   `mapstructure:"resume"`
+	CheckpointInterval    int    `mapstructure:"checkpoint_interval"`
+	Format                string `mapstructure:"format"`
+	ShardSize             int64  `mapstructure:"shard_size"`
+	ErrorPolicy           string `mapstructure:"error_policy"`
+	ErrorReport           string `mapstructure:"error_report"`
+	Versions              bool   `mapstructure:"versions"`
+	MaxVersions           int    `mapstructure:"max_versions"`
+	MinFunctionLines      int    `mapstructure:"min_function_lines"`
+	MinFunctionTokens     int    `mapstructure:"min_function_tokens"`
+	MinFunctionComplexity int    `mapstructure:"min_function_complexity"`
+	StoreBodies           bool   `mapstructure:"store_bodies"`
+}
+
+// DetectConfig contains settings for the detect command
+type DetectConfig struct {
+	KnownFiles            string             `mapstructure:"known_files"`
+	Output                string             `mapstructure:"output"`
+	Workers               int                `mapstructure:"workers"`
+	Threshold             float64            `mapstructure:"threshold"`
+	Thresholds            map[string]float64 `mapstructure:"thresholds"`
+	FunctionThreshold     int                `mapstructure:"function_threshold"`
+	Signatures            string             `mapstructure:"signatures"`
+	Snapshot              string             `mapstructure:"snapshot"`
+	Index                 string             `mapstructure:"index"`
+	SignatureIndex        string             `mapstructure:"signature_index"`
+	BatchSize             int                `mapstructure:"batch_size"`
+	Format                string             `mapstructure:"format"`
+	SBOM                  string             `mapstructure:"sbom"`
+	CloneTypes            []string           `mapstructure:"clone_types"`
+	ExactFirst            bool               `mapstructure:"exact_first"`
+	DiffSnippets          bool               `mapstructure:"diff_snippets"`
+	MaxFunctionComponents int                `mapstructure:"max_function_components"`
+	TopK                  int                `mapstructure:"top_k"`
+	MinSimilarity         float64            `mapstructure:"min_similarity"`
+	MaxMatchesPerFile     int                `mapstructure:"max_matches_per_file"`
+	ModifiedMinDistance   int                `mapstructure:"modified_min_distance"`
+	ModifiedMaxDistance   int                `mapstructure:"modified_max_distance"`
+	Owned                 []string           `mapstructure:"owned"`
+	OwnedMode             string             `mapstructure:"owned_mode"`
+	Labels                string             `mapstructure:"labels"`
+	FalsePositiveMode     string             `mapstructure:"false_positive_mode"`
+	Directories           string             `mapstructure:"directories"`
+	DirectoryCoverage     float64            `mapstructure:"directory_coverage"`
+	Dependencies          string             `mapstructure:"dependencies"`
+	Distances             string             `mapstructure:"distances"`
+	DistancesFormat       string             `mapstructure:"distances_format"`
+	DistancesMax          int                `mapstructure:"distances_max"`
+	DistanceBatch         int                `mapstructure:"distance_batch"`
+	Manifest              string             `mapstructure:"manifest"`
+	ResultsDir            string             `mapstructure:"results_dir"`
+	Licenses              bool               `mapstructure:"licenses"`
+	TargetLicense         string             `mapstructure:"target_license"`
+	Provenance            bool               `mapstructure:"provenance"`
+	Baseline              string             `mapstructure:"baseline"`
+	UpdateBaseline        bool               `mapstructure:"update_baseline"`
+	TargetRoot            string             `mapstructure:"target_root"`
+	FailOn                []string           `mapstructure:"fail_on"`
+	FailExitCode          int                `mapstructure:"fail_exit_code"`
+	Webhooks              []webhook.Hook     `mapstructure:"webhooks"`
+	WebhookURLs           []string           `mapstructure:"webhook_urls"`
+	WebhookSecret         string             `mapstructure:"webhook_secret"`
+	WebhookTimeout        time.Duration      `mapstructure:"webhook_timeout"`
+	Vulns                 VulnsConfig        `mapstructure:"vulns"`
+}
+
+// VulnsConfig contains settings for vulnerability lookups
+type VulnsConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Endpoint string        `mapstructure:"endpoint"`
+	CacheDir string        `mapstructure:"cache_dir"`
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	Offline  bool          `mapstructure:"offline"`
+}
+
+// PipelineConfig contains settings for the pipeline command
+type PipelineConfig struct {
+	Target  string `mapstructure:"target"`
+	Workers int    `mapstructure:"workers"`
+}
+
+// ScanConfig contains settings for the scan command
+type ScanConfig struct {
+	Output string `mapstructure:"output"`
+}
+
+// ClusterConfig contains settings for the cluster command
+type ClusterConfig struct {
+	Output           string `mapstructure:"output"`
+	Workers          int    `mapstructure:"workers"`
+	FileDistance     int    `mapstructure:"file_distance"`
+	FunctionDistance int    `mapstructure:"function_distance"`
+	Functions        bool   `mapstructure:"functions"`
+	MinSize          int    `mapstructure:"min_size"`
+}
+
+// ServeConfig contains settings for the serve command
+type ServeConfig struct {
+	Addr          string        `mapstructure:"addr"`
+	MaxUploadSize int64         `mapstructure:"max_upload_size"`
+	AllowPaths    bool          `mapstructure:"allow_paths"`
+	Refresh       RefreshConfig `mapstructure:"refresh"`
+}
+
+// RefreshConfig contains settings for scheduled corpus refreshes
+type RefreshConfig struct {
+	Schedule string `mapstructure:"schedule"`
+	RepoList string `mapstructure:"repo_list"`
+	Workers  int    `mapstructure:"workers"`
+}
+
+// DefaultConfig returns the configuration used when neither the file nor
+// flags set a key
 func DefaultConfig() *Config {
+	languages := make(map[string]LanguageSettings)
+	for name, extensions := range analyzer.DefaultLanguages() {
+		languages[name] = LanguageSettings{Extensions: extensions}
+	}
+
 	return &Config{
-		Paths: PathConfig{
-			RepoPath:    "./repos",
-			TagDatePath: "./data/repo_date",
-			ResultPath:  "./data/repo_functions",
+		Progress:          progress.ModeAuto,
+		Symlinks:          analyzer.SymlinkFollow,
+		Exclude:           analyzer.DefaultExcludes,
+		SignatureMode:     analyzer.SignatureBytes,
+		Encoding:          analyzer.EncodingAuto,
+		Conditionals:      cpp.ConditionalsStrip,
+		ChunkedSize:       analyzer.DefaultChunkedSize,
+		Cache:             CacheConfig{MaxSize: cache.DefaultMaxSize},
+		Snapshot:          SnapshotConfig{Store: "./data/snapshots"},
+		Languages:         languages,
+		SlowFileThreshold: 10 * time.Second,
+
+		Clone: CloneConfig{
+			RepoList: "./repo_list.txt",
+			Output:   "./repos",
 		},
-		Performance: PerformanceConfig{
-			MaxWorkers:   0, // 0 means use number of CPU cores
-			CacheSize:    1000,
-			MemoryLimit: 0.8,
+		Analyze: AnalyzeConfig{
+			Output:      "./analysis",
+			Format:      "json",
+			ErrorPolicy: analyzer.ErrorPolicyFailFast,
 		},
-		Languages: LanguagesConfig{
-			CPP: LanguageSettings{
-				Enabled:    true,
-				Extensions: []string{".c", ".cc", ".cpp", ".cxx", ".h", ".hpp"},
-			},
-			Java: LanguageSettings{
-				Enabled:    false,
-				Extensions: []string{".java"},
-			},
-			Python: LanguageSettings{
-				Enabled:    false,
-				Extensions: []string{".py"},
-			},
+		Preprocess: PreprocessConfig{
+			Output:             "./data/preprocessed",
+			CheckpointInterval: 1000,
+			Format:             preprocessor.FormatJSON,
+			ShardSize:          64 << 20,
+			ErrorPolicy:        analyzer.ErrorPolicyFailFast,
+		},
+		Detect: DetectConfig{
+			KnownFiles:          "./known-files",
+			Output:              "detection-results.json",
+			Threshold:           0.8,
+			FunctionThreshold:   30,
+			ModifiedMinDistance: 1,
+			OwnedMode:           detector.OwnedExclude,
+			FalsePositiveMode:   detector.FalsePositiveSuppress,
+			DirectoryCoverage:   detector.DefaultDirectoryCoverage,
+			ResultsDir:          "./results",
+			DistancesFormat:     detector.DistancesCSV,
+			Format:              detector.FormatJSON,
+			Licenses:            true,
+			FailExitCode:        2,
+		},
+		Scan: ScanConfig{Output: "scan-report.json"},
+		Cluster: ClusterConfig{
+			Output:           "clusters.json",
+			FileDistance:     cluster.DefaultFileDistance,
+			FunctionDistance: cluster.DefaultFunctionDistance,
+			Functions:        true,
+			MinSize:          2,
+		},
+		Serve: ServeConfig{
+			Addr:          ":8080",
+			MaxUploadSize: 32 << 20,
 		},
 	}
-} 
\ No newline at end of file
+}
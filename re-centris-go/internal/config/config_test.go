@@ -0,0 +1,200 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "..", "config.yaml"), "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Detect.Threshold != 0.8 || cfg.Languages["java"].Disabled() {
+		t.Errorf("Load() = %+v, want the values of config.yaml", cfg.Detect)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+paths:
+  repo_path: ./repos
+detect:
+  treshold: 0.9
+  function_threshold: thirty
+  format: xml
+  thresholds:
+    cpp: 1.5
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(path, "")
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Load() error = %v, want *Error", err)
+	}
+	want := []string{
+		"paths: no longer supported",
+		"detect.treshold: unknown key, did you mean detect.threshold?",
+		"detect.function_threshold: expected type 'int'",
+		"detect.format: unsupported value \"xml\"",
+		"detect.thresholds.cpp: must be between 0 and 1",
+	}
+	if len(e.Problems) != len(want) {
+		t.Fatalf("Load() problems = %q, want %d", e.Problems, len(want))
+	}
+	for _, w := range want {
+		if !strings.Contains(e.Error(), w) {
+			t.Errorf("Load() error = %v, want it to contain %q", e, w)
+		}
+	}
+}
+
+func TestDefaultConfigValid(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("DefaultConfig().Validate() = %v", err)
+	}
+}
+
+func TestResolveProfiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("shared.yaml", `
+detect:
+  threshold: 0.7
+  workers: 4
+`)
+	path := write("config.yaml", `
+extends: shared.yaml
+detect:
+  threshold: 0.8
+profiles:
+  firmware:
+    languages:
+      java: {enabled: false}
+      python: {enabled: false}
+    detect:
+      known_files: ./corpora/firmware
+  java:
+    detect:
+      known_files: ./corpora/java
+      threshold: 0.9
+`)
+
+	names, err := Profiles(path)
+	if err != nil || strings.Join(names, ",") != "firmware,java" {
+		t.Errorf("Profiles() = %v, %v, want firmware and java", names, err)
+	}
+
+	cfg, err := Load(path, "firmware")
+	if err != nil {
+		t.Fatalf("Load(firmware) error = %v", err)
+	}
+	if cfg.Detect.KnownFiles != "./corpora/firmware" || cfg.Detect.Threshold != 0.8 || cfg.Detect.Workers != 4 {
+		t.Errorf("Load(firmware) detect = %+v, want the profile merged over the file and shared.yaml", cfg.Detect)
+	}
+	if !cfg.Languages["java"].Disabled() || cfg.Languages["cpp"].Disabled() {
+		t.Errorf("Load(firmware) languages = %+v, want only java and python disabled", cfg.Languages)
+	}
+
+	if err := SetValues(path, "java", map[string]interface{}{"detect.thresholds.java": 0.85}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(path, "java")
+	if err != nil {
+		t.Fatalf("Load(java) error = %v", err)
+	}
+	if cfg.Detect.Threshold != 0.9 || cfg.Detect.Thresholds["java"] != 0.85 {
+		t.Errorf("Load(java) detect = %+v, want the profile and the written threshold", cfg.Detect)
+	}
+
+	if _, err := Resolve(path, "python"); err == nil || !strings.Contains(err.Error(), "firmware, java") {
+		t.Errorf("Resolve(python) error = %v, want the defined profiles", err)
+	}
+
+	write("a.yaml", "extends: b.yaml\n")
+	write("b.yaml", "extends: a.yaml\n")
+	if _, err := Resolve(filepath.Join(dir, "a.yaml"), ""); err == nil {
+		t.Error("Resolve() of files extending each other succeeded")
+	}
+}
+
+func TestSetValuesKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `# Re-Centris Configuration
+
+# Detection settings
+detect:
+  threshold: 0.8  # Similarity threshold
+  output: "results.json"
+  thresholds:
+    cpp: 0.7  # calibrated
+
+  vulns:
+    enabled: false
+languages:
+  cpp:
+    enabled: true
+normalize: {}  # steps per language
+cache:
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]interface{}{
+		"detect.thresholds.cpp":  0.75,
+		"detect.thresholds.java": 0.85,
+		"detect.output":          "out.json",
+		"normalize.cpp":          []string{"identifiers"},
+		"cache.dir":              "cache",
+	}
+	if err := SetValues(path, "", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetValues(path, "java", map[string]interface{}{"detect.threshold": 0.9}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Re-Centris Configuration
+
+# Detection settings
+detect:
+  threshold: 0.8  # Similarity threshold
+  output: "out.json"
+  thresholds:
+    cpp: 0.75  # calibrated
+    java: 0.85
+
+  vulns:
+    enabled: false
+languages:
+  cpp:
+    enabled: true
+normalize:  # steps per language
+  cpp:
+    - identifiers
+cache:
+  dir: cache
+profiles:
+  java:
+    detect:
+      threshold: 0.9
+`
+	if string(data) != want {
+		t.Errorf("SetValues() wrote\n%s\nwant\n%s", data, want)
+	}
+}
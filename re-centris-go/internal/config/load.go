@@ -0,0 +1,272 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// removedKeys are sections of earlier versions of the file that no command
+// reads, with what replaces them
+var removedKeys = map[string]string{
+	"paths":       "set the output of each command instead, e.g. clone.output and preprocess.output",
+	"performance": "use max_concurrency, the workers of each command and cache.max_size",
+}
+
+// Error lists the problems found in a configuration
+type Error struct {
+	// File is the configuration file, if the configuration was loaded from one
+	File string
+	// Profile is the profile selected in File, if any
+	Profile  string
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	switch {
+	case e.File != "" && e.Profile != "":
+		fmt.Fprintf(&b, "invalid configuration in profile %s of %s:", e.Profile, e.File)
+	case e.File != "":
+		fmt.Fprintf(&b, "invalid configuration in %s:", e.File)
+	default:
+		b.WriteString("invalid configuration:")
+	}
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// Load reads and validates a configuration file with the files it extends
+// and the settings of profile, if not empty. Keys missing from the file keep
+// their defaults; unknown keys and values of the wrong type are errors, so
+// that a misspelled key does not silently fall back to its default.
+func Load(path, profile string) (*Config, error) {
+	settings, err := Resolve(path, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := Decode(settings)
+	if e, ok := err.(*Error); ok {
+		e.File = path
+		e.Profile = profile
+	}
+	return cfg, err
+}
+
+// Decode decodes settings keyed as in the configuration file over the
+// defaults and validates the result
+func Decode(settings map[string]interface{}) (*Config, error) {
+	cfg := DefaultConfig()
+	var unknown []string
+	unusedKeys(settings, reflect.TypeOf(cfg).Elem(), "", &unknown)
+	problems := unknownKeys(unknown)
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     cfg,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config decoder: %v", err)
+	}
+	if err := decoder.Decode(settings); err != nil {
+		if e, ok := err.(*mapstructure.Error); ok {
+			for _, problem := range e.Errors {
+				problems = append(problems, decodeProblem(problem))
+			}
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err.(*Error).Problems...)
+	}
+	if len(problems) > 0 {
+		return cfg, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// decodeProblem rewrites a decoding error such as "'detect.threshold'
+// expected type 'float64', ..." to start with the key like other problems
+func decodeProblem(problem string) string {
+	problem = strings.TrimPrefix(problem, "error decoding ")
+	if !strings.HasPrefix(problem, "'") {
+		return problem
+	}
+	key, rest, ok := strings.Cut(problem[1:], "'")
+	if !ok {
+		return problem
+	}
+	return key + ":" + strings.TrimPrefix(rest, ":")
+}
+
+// unusedKeys appends the keys of value that no field of type t decodes
+func unusedKeys(value interface{}, t reflect.Type, prefix string, unused *[]string) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		unusedKeys(value, t.Elem(), prefix, unused)
+	case reflect.Slice:
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				unusedKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", prefix, i), unused)
+			}
+		}
+	case reflect.Map:
+		if m, ok := value.(map[string]interface{}); ok {
+			for key, v := range m {
+				unusedKeys(v, t.Elem(), prefix+"."+key, unused)
+			}
+		}
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if prefix != "" {
+			prefix += "."
+		}
+		for key, v := range m {
+			if field, ok := fieldByKey(t, key); ok {
+				unusedKeys(v, field.Type, prefix+key, unused)
+			} else {
+				*unused = append(*unused, prefix+key)
+			}
+		}
+	}
+}
+
+// unknownKeys describes the keys not in the schema, suggesting the removed
+// key replacements or close known keys
+func unknownKeys(keys []string) []string {
+	sort.Strings(keys)
+	known := Keys()
+
+	var problems []string
+	for _, key := range keys {
+		if hint, ok := removedKeys[key]; ok {
+			problems = append(problems, fmt.Sprintf("%s: no longer supported, %s", key, hint))
+			continue
+		}
+		problem := fmt.Sprintf("%s: unknown key", key)
+		if s := suggest(key, known); s != "" {
+			problem += fmt.Sprintf(", did you mean %s?", s)
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// Keys returns the keys of the schema, such as detect.threshold. Keys below
+// maps, such as normalize.cpp.strip_comments, are left out.
+func Keys() []string {
+	var keys []string
+	collectKeys(reflect.TypeOf(Config{}), "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// Known reports whether key is a key of the schema or below a map of it,
+// such as detect.thresholds.cpp. Sections such as detect are not keys.
+func Known(key string) bool {
+	t := reflect.TypeOf(Config{})
+	for _, part := range strings.Split(key, ".") {
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+			continue
+		case reflect.Struct:
+		default:
+			return false
+		}
+		field, ok := fieldByKey(t, part)
+		if !ok {
+			return false
+		}
+		t = field.Type
+	}
+	return t.Kind() != reflect.Struct
+}
+
+// Settings returns the values of the configuration by key, such as
+// detect.threshold. Maps and slices are single values.
+func (c *Config) Settings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	collectSettings(reflect.ValueOf(c).Elem(), "", settings)
+	return settings
+}
+
+// collectSettings adds the fields of struct value v to settings
+func collectSettings(v reflect.Value, prefix string, settings map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == t.PkgPath() {
+			collectSettings(v.Field(i), key+".", settings)
+			continue
+		}
+		settings[key] = v.Field(i).Interface()
+	}
+}
+
+// fieldByKey returns the field of struct type t decoding key
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(t.Field(i).Tag.Get("mapstructure"), key) {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// collectKeys appends the keys of the fields of struct type t
+func collectKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		*keys = append(*keys, key)
+		if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == t.PkgPath() {
+			collectKeys(field.Type, key+".", keys)
+		}
+	}
+}
+
+// suggest returns the known key closest to key, if it is a likely typo
+func suggest(key string, known []string) string {
+	best, bestDistance := "", 3
+	for _, k := range known {
+		if d := editDistance(key, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
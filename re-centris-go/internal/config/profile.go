@@ -0,0 +1,535 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Keys of the configuration file resolved before decoding
+const (
+	// ProfilesKey holds named sets of settings merged over the rest of the
+	// file when selected, e.g. profiles.firmware
+	ProfilesKey = "profiles"
+	// ExtendsKey names files whose settings the file extends. Paths are
+	// relative to the file; later files and the file itself take precedence.
+	ExtendsKey = "extends"
+)
+
+// Resolve reads a configuration file with the files it extends and, if
+// profile is not empty, merges the settings of the profile over them. The
+// result holds neither profiles nor extends.
+func Resolve(path, profile string) (map[string]interface{}, error) {
+	settings, err := resolveFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := profileSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	delete(settings, ProfilesKey)
+	if profile == "" {
+		return settings, nil
+	}
+
+	overrides, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q, %s defines %s", profile, path, profileList(profiles))
+	}
+	return merge(settings, overrides), nil
+}
+
+// Profiles returns the names of the profiles defined by a configuration
+// file and the files it extends
+func Profiles(path string) ([]string, error) {
+	settings, err := resolveFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := profileSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	return profileNames(profiles), nil
+}
+
+// resolveFile reads a file merged over the files it extends. Files being
+// resolved are in stack to detect cycles.
+func resolveFile(path string, stack []string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config file path: %v", err)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("config file %s extends itself through %s", path, strings.Join(stack, " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	settings, err := readSettings(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var extends []string
+	switch v := settings[ExtendsKey].(type) {
+	case nil:
+	case string:
+		extends = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s must list file names, got %v", path, ExtendsKey, item)
+			}
+			extends = append(extends, s)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %s must be a file name or a list of file names", path, ExtendsKey)
+	}
+	delete(settings, ExtendsKey)
+
+	resolved := make(map[string]interface{})
+	for _, base := range extends {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(path), base)
+		}
+		baseSettings, err := resolveFile(base, stack)
+		if err != nil {
+			return nil, err
+		}
+		resolved = merge(resolved, baseSettings)
+	}
+	return merge(resolved, settings), nil
+}
+
+// readSettings reads the settings of a configuration file
+func readSettings(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if settings == nil {
+		settings = make(map[string]interface{})
+	}
+	return settings, nil
+}
+
+// profileSettings returns the settings of each profile
+func profileSettings(settings map[string]interface{}) (map[string]map[string]interface{}, error) {
+	profiles := make(map[string]map[string]interface{})
+	if settings[ProfilesKey] == nil {
+		return profiles, nil
+	}
+
+	m, ok := settings[ProfilesKey].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must map profile names to settings", ProfilesKey)
+	}
+	for name, v := range m {
+		if v == nil {
+			v = map[string]interface{}{}
+		}
+		p, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a map of settings", ProfilesKey, name)
+		}
+		if _, ok := p[ExtendsKey]; ok {
+			return nil, fmt.Errorf("%s.%s: profiles can't extend files, put %s at the top of the file", ProfilesKey, name, ExtendsKey)
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// profileNames returns the names of profiles in order
+func profileNames(profiles map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profileList describes the names of profiles
+func profileList(profiles map[string]map[string]interface{}) string {
+	if len(profiles) == 0 {
+		return "no profiles"
+	}
+	return "profiles " + strings.Join(profileNames(profiles), ", ")
+}
+
+// SetValues sets keys such as detect.thresholds.cpp in a configuration
+// file, below profiles.<profile> if profile is not empty. Only the text of
+// the values changes: existing values are replaced where they are and new
+// keys are added at the end of their section, so comments, blank lines and
+// the order of keys are kept. The files it extends are left unchanged.
+func SetValues(path, profile string, values map[string]interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	var root *yaml.Node
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return fmt.Errorf("config file %s must hold a map of settings", path)
+		}
+	}
+
+	var prefix []string
+	if profile != "" {
+		prefix = []string{ProfilesKey, profile}
+	}
+
+	// New keys are added in a reproducible order
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	e := newFileEdit(data)
+	for _, key := range keys {
+		var value yaml.Node
+		if err := value.Encode(values[key]); err != nil {
+			return fmt.Errorf("failed to encode %s: %v", key, err)
+		}
+		parts := append(append([]string{}, prefix...), strings.Split(key, ".")...)
+		if err := e.set(root, parts, &value); err != nil {
+			return fmt.Errorf("failed to set %s in %s: %v", key, path, err)
+		}
+	}
+
+	edited, err := e.apply()
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, edited, 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// fileEdit collects the edits SetValues makes to the text of a file
+type fileEdit struct {
+	// lines holds the lines of the file with their line endings
+	lines        []string
+	replacements []replacement
+	// additions are keyed by the map node, or the key of the empty
+	// section, the keys are added to
+	additions map[*yaml.Node]*addition
+}
+
+// replacement replaces the text of a value between two rune offsets of a
+// line
+type replacement struct {
+	line, start, end int
+	text             string
+}
+
+// addition adds keys after a line, indented as the keys of their section
+type addition struct {
+	after  int
+	indent int
+	keys   *yaml.Node
+}
+
+// newFileEdit prepares the edit of a file
+func newFileEdit(data []byte) *fileEdit {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return &fileEdit{lines: lines, additions: make(map[*yaml.Node]*addition)}
+}
+
+// set sets the key at the path parts below the root map node, which is nil
+// for an empty file
+func (e *fileEdit) set(root *yaml.Node, parts []string, value *yaml.Node) error {
+	if root == nil {
+		return e.add(nil, len(e.lines)-1, 0, parts, value)
+	}
+
+	m := root
+	for i, part := range parts {
+		key, v := lookup(m, part)
+		switch {
+		case v == nil:
+			if m.Style&yaml.FlowStyle != 0 || len(m.Content) == 0 {
+				return fmt.Errorf("can't add keys to the flow map at line %d", m.Line)
+			}
+			indent := m.Content[0].Column - 1
+			return e.add(m, e.sectionEnd(m, indent), indent, parts[i:], value)
+		case i == len(parts)-1:
+			return e.replace(v, value)
+		case v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle != 0 && len(v.Content) == 0:
+			// An empty section such as "thresholds: {}" becomes a block
+			if _, ok := e.additions[key]; !ok {
+				if err := e.clear(v); err != nil {
+					return err
+				}
+			}
+			return e.add(key, key.Line-1, key.Column+1, parts[i+1:], value)
+		case v.Kind == yaml.MappingNode:
+			m = v
+		case v.Kind == yaml.ScalarNode && v.Tag == "!!null" && v.Value == "":
+			// An empty section such as "thresholds:"
+			return e.add(key, key.Line-1, key.Column+1, parts[i+1:], value)
+		default:
+			return fmt.Errorf("%s at line %d is not a map", strings.Join(parts[:i+1], "."), v.Line)
+		}
+	}
+	return nil
+}
+
+// lookup returns the key and value nodes of key in the map node m
+func lookup(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// sectionEnd returns the index of the last line of the map node m, whose
+// keys are indented by indent columns
+func (e *fileEdit) sectionEnd(m *yaml.Node, indent int) int {
+	end := lastLine(m) - 1
+	// Continuation lines of a multi-line value are indented further
+	for end+1 < len(e.lines) {
+		line := e.lines[end+1]
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(trimmed, "#") || len(line)-len(trimmed) <= indent {
+			break
+		}
+		end++
+	}
+	return end
+}
+
+// lastLine returns the line of the last node below n
+func lastLine(n *yaml.Node) int {
+	last := n.Line
+	for _, c := range n.Content {
+		last = max(last, lastLine(c))
+	}
+	return last
+}
+
+// add adds the key at the path parts after a line of the file
+func (e *fileEdit) add(section *yaml.Node, after, indent int, parts []string, value *yaml.Node) error {
+	a, ok := e.additions[section]
+	if !ok {
+		a = &addition{after: after, indent: indent, keys: mappingNode()}
+		e.additions[section] = a
+	}
+	m := a.keys
+	for _, part := range parts[:len(parts)-1] {
+		m = sectionNode(m, part)
+	}
+	setValue(m, parts[len(parts)-1], value)
+	return nil
+}
+
+// replace replaces the text of the scalar v with value, keeping the quotes
+// of a string
+func (e *fileEdit) replace(v, value *yaml.Node) error {
+	if v.Kind != yaml.ScalarNode || v.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return fmt.Errorf("the value at line %d is not a single-line value", v.Line)
+	}
+	line := []rune(e.lines[v.Line-1])
+	start := v.Column - 1
+	end, ok := scalarEnd(line, start, v)
+	if !ok {
+		return fmt.Errorf("the value at line %d is not a single-line value", v.Line)
+	}
+
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!str" && v.Tag == "!!str" {
+		value.Style = v.Style
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if strings.Contains(text, "\n") {
+		return fmt.Errorf("the value for line %d does not fit on a line", v.Line)
+	}
+	if start == end {
+		// The value of "key:" has no text
+		text = " " + text
+	}
+	e.replacements = append(e.replacements, replacement{line: v.Line - 1, start: start, end: end, text: text})
+	return nil
+}
+
+// clear removes the text of the empty flow map v
+func (e *fileEdit) clear(v *yaml.Node) error {
+	line := []rune(e.lines[v.Line-1])
+	start := v.Column - 1
+	end := start + 1
+	if start > 0 && line[start-1] == ' ' {
+		start--
+	}
+	for end < len(line) && line[end] == ' ' {
+		end++
+	}
+	if line[v.Column-1] != '{' || end >= len(line) || line[end] != '}' {
+		return fmt.Errorf("the map at line %d is not a single-line value", v.Line)
+	}
+	e.replacements = append(e.replacements, replacement{line: v.Line - 1, start: start, end: end + 1})
+	return nil
+}
+
+// scalarEnd returns the rune offset after the text of the scalar v, which
+// starts at start of line
+func scalarEnd(line []rune, start int, v *yaml.Node) (int, bool) {
+	switch {
+	case v.Style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, true
+			}
+		}
+	case v.Style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] != '\'' {
+				continue
+			}
+			if i+1 < len(line) && line[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	default:
+		// A plain scalar on one line is its text
+		end := start + len([]rune(v.Value))
+		if end <= len(line) && string(line[start:end]) == v.Value {
+			return end, true
+		}
+	}
+	return 0, false
+}
+
+// apply returns the text of the file with the edits applied
+func (e *fileEdit) apply() ([]byte, error) {
+	sort.Slice(e.replacements, func(i, j int) bool {
+		if e.replacements[i].line != e.replacements[j].line {
+			return e.replacements[i].line < e.replacements[j].line
+		}
+		return e.replacements[i].start > e.replacements[j].start
+	})
+	for _, r := range e.replacements {
+		line := []rune(e.lines[r.line])
+		e.lines[r.line] = string(line[:r.start]) + r.text + string(line[r.end:])
+	}
+
+	// Keys of nested sections added after the same line come before the
+	// keys of their parents
+	additions := make([]*addition, 0, len(e.additions))
+	for _, a := range e.additions {
+		additions = append(additions, a)
+	}
+	sort.Slice(additions, func(i, j int) bool {
+		if additions[i].after != additions[j].after {
+			return additions[i].after > additions[j].after
+		}
+		if additions[i].indent != additions[j].indent {
+			return additions[i].indent < additions[j].indent
+		}
+		return additions[i].keys.Content[0].Value > additions[j].keys.Content[0].Value
+	})
+	for _, a := range additions {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(a.keys); err != nil {
+			return nil, err
+		}
+		enc.Close()
+
+		var lines []string
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			lines = append(lines, strings.Repeat(" ", a.indent)+strings.TrimSuffix(line, "\n")+"\n")
+		}
+		if a.after >= 0 && !strings.HasSuffix(e.lines[a.after], "\n") {
+			e.lines[a.after] += "\n"
+		}
+		rest := append(lines, e.lines[a.after+1:]...)
+		e.lines = append(e.lines[:a.after+1], rest...)
+	}
+	return []byte(strings.Join(e.lines, "")), nil
+}
+
+// mappingNode returns an empty map node
+func mappingNode() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+// sectionNode returns the map node below key of the map node m, adding it
+// if needed
+func sectionNode(m *yaml.Node, key string) *yaml.Node {
+	if _, s := lookup(m, key); s != nil {
+		return s
+	}
+	s := mappingNode()
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, s)
+	return s
+}
+
+// setValue sets key of the map node m to value
+func setValue(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// merge returns base with overrides merged over it. Maps are merged key by
+// key; other values, including lists, are replaced. Neither argument is
+// modified.
+func merge(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		baseMap, ok1 := merged[k].(map[string]interface{})
+		overrideMap, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			merged[k] = merge(baseMap, overrideMap)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser/cpp"
+	"github.com/re-centris/re-centris-go/internal/common/progress"
+	"github.com/re-centris/re-centris-go/internal/common/schedule"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"github.com/re-centris/re-centris-go/internal/sbom"
+)
+
+// validator collects the problems of a configuration
+type validator struct {
+	problems []string
+}
+
+// addf records a problem with the value of key
+func (v *validator) addf(key, format string, args ...interface{}) {
+	v.problems = append(v.problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+// oneOf checks that the value of key is one of values
+func (v *validator) oneOf(key, value string, values ...string) {
+	for _, allowed := range values {
+		if value == allowed {
+			return
+		}
+	}
+	v.addf(key, "unsupported value %q, expected one of %s", value, strings.Join(values, ", "))
+}
+
+// nonNegative checks that the value of key is not negative
+func (v *validator) nonNegative(key string, value int64) {
+	if value < 0 {
+		v.addf(key, "must not be negative, got %d", value)
+	}
+}
+
+// fraction checks that the value of key is between 0 and 1
+func (v *validator) fraction(key string, value float64) {
+	if value < 0 || value > 1 {
+		v.addf(key, "must be between 0 and 1, got %g", value)
+	}
+}
+
+// check records err, if any, as a problem with the value of key
+func (v *validator) check(key string, err error) {
+	if err != nil {
+		v.addf(key, "%v", err)
+	}
+}
+
+// httpURL checks that the value of key is an http or https URL
+func (v *validator) httpURL(key, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.addf(key, "invalid URL %q, expected an http or https URL", value)
+	}
+}
+
+// language checks that key names a supported language
+func (v *validator) language(key, name string) {
+	if _, ok := analyzer.DefaultLanguages()[name]; !ok {
+		v.addf(key, "unsupported language %q, expected one of %s", name, strings.Join(languageNames(), ", "))
+	}
+}
+
+// languageNames returns the supported languages in order
+func languageNames() []string {
+	var names []string
+	for name := range analyzer.DefaultLanguages() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the values of the configuration, reporting all problems
+// found at once as an *Error
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.oneOf("progress", c.Progress, progress.ModeAuto, progress.ModeBar, progress.ModeJSON, progress.ModeNone)
+	v.oneOf("symlinks", c.Symlinks, analyzer.SymlinkFollow, analyzer.SymlinkSkip)
+	_, err := analyzer.ParseSignatureMode(c.SignatureMode)
+	v.check("signature_mode", err)
+	_, err = analyzer.ParseEncoding(c.Encoding)
+	v.check("encoding", err)
+	_, err = cpp.ParseConditionals(c.Conditionals)
+	v.check("conditionals", err)
+	v.nonNegative("max_file_size", c.MaxFileSize)
+	v.nonNegative("chunked_size", c.ChunkedSize)
+	v.nonNegative("file_timeout", int64(c.FileTimeout))
+	v.nonNegative("slow_file_threshold", int64(c.SlowFileThreshold))
+	v.nonNegative("max_concurrency", int64(c.MaxConcurrency))
+	v.nonNegative("cache.max_size", c.Cache.MaxSize)
+
+	for name, settings := range c.Languages {
+		key := "languages." + name
+		v.language(key, name)
+		for _, ext := range settings.Extensions {
+			if !strings.HasPrefix(ext, ".") {
+				v.addf(key+".extensions", "extension %q must start with a dot", ext)
+			}
+		}
+	}
+	for name := range c.Normalize {
+		v.language("normalize."+name, name)
+	}
+
+	if c.Snapshot.Store == "" {
+		v.addf("snapshot.store", "must not be empty")
+	}
+	v.nonNegative("snapshot.keep", int64(c.Snapshot.Keep))
+
+	v.nonNegative("clone.workers", int64(c.Clone.Workers))
+
+	v.nonNegative("analyze.workers", int64(c.Analyze.Workers))
+	v.oneOf("analyze.format", c.Analyze.Format, "json", "parquet")
+	_, err = analyzer.ParseErrorPolicy(c.Analyze.ErrorPolicy)
+	v.check("analyze.error_policy", err)
+
+	v.nonNegative("preprocess.workers", int64(c.Preprocess.Workers))
+	v.oneOf("preprocess.format", c.Preprocess.Format, preprocessor.FormatJSON, preprocessor.FormatSharded, preprocessor.FormatParquet)
+	if c.Preprocess.CheckpointInterval <= 0 {
+		v.addf("preprocess.checkpoint_interval", "must be positive, got %d", c.Preprocess.CheckpointInterval)
+	}
+	if c.Preprocess.ShardSize <= 0 {
+		v.addf("preprocess.shard_size", "must be positive, got %d", c.Preprocess.ShardSize)
+	}
+	_, err = analyzer.ParseErrorPolicy(c.Preprocess.ErrorPolicy)
+	v.check("preprocess.error_policy", err)
+	v.nonNegative("preprocess.max_versions", int64(c.Preprocess.MaxVersions))
+	v.nonNegative("preprocess.min_function_lines", int64(c.Preprocess.MinFunctionLines))
+	v.nonNegative("preprocess.min_function_tokens", int64(c.Preprocess.MinFunctionTokens))
+	v.nonNegative("preprocess.min_function_complexity", int64(c.Preprocess.MinFunctionComplexity))
+
+	c.Detect.validate(v)
+
+	v.nonNegative("pipeline.workers", int64(c.Pipeline.Workers))
+
+	v.nonNegative("cluster.workers", int64(c.Cluster.Workers))
+	v.nonNegative("cluster.file_distance", int64(c.Cluster.FileDistance))
+	v.nonNegative("cluster.function_distance", int64(c.Cluster.FunctionDistance))
+	v.nonNegative("cluster.min_size", int64(c.Cluster.MinSize))
+
+	if c.Serve.Addr == "" {
+		v.addf("serve.addr", "must not be empty")
+	}
+	if c.Serve.MaxUploadSize <= 0 {
+		v.addf("serve.max_upload_size", "must be positive, got %d", c.Serve.MaxUploadSize)
+	}
+	if c.Serve.Refresh.Schedule != "" {
+		_, err := schedule.Parse(c.Serve.Refresh.Schedule)
+		v.check("serve.refresh.schedule", err)
+	}
+	v.nonNegative("serve.refresh.workers", int64(c.Serve.Refresh.Workers))
+
+	if len(v.problems) > 0 {
+		sort.Strings(v.problems)
+		return &Error{Problems: v.problems}
+	}
+	return nil
+}
+
+// validate checks the detect settings
+func (d *DetectConfig) validate(v *validator) {
+	v.nonNegative("detect.workers", int64(d.Workers))
+	v.fraction("detect.threshold", d.Threshold)
+	for name, threshold := range d.Thresholds {
+		key := "detect.thresholds." + name
+		v.language(key, name)
+		v.fraction(key, threshold)
+	}
+	v.nonNegative("detect.function_threshold", int64(d.FunctionThreshold))
+	v.nonNegative("detect.batch_size", int64(d.BatchSize))
+	if d.Snapshot != "" && (d.Signatures != "" || d.Index != "") {
+		v.addf("detect.snapshot", "cannot be combined with detect.signatures or detect.index")
+	}
+	if d.SignatureIndex != "" && d.Index != "" {
+		v.addf("detect.signature_index", "cannot be combined with detect.index")
+	}
+	if d.SignatureIndex != "" && d.Distances != "" {
+		v.addf("detect.distances", "cannot be exported when searching detect.signature_index")
+	}
+	v.oneOf("detect.format", d.Format, detector.FormatJSON, detector.FormatJSONL, detector.FormatSARIF, detector.FormatCSV,
+		detector.FormatMarkdown, detector.FormatGitHub, detector.FormatGitHubCheck)
+	if d.SBOM != "" {
+		v.oneOf("detect.sbom", d.SBOM, sbom.FormatCycloneDX, sbom.FormatSPDX)
+	}
+	v.check("detect.clone_types", detector.ParseCloneTypes(d.CloneTypes))
+	v.nonNegative("detect.max_function_components", int64(d.MaxFunctionComponents))
+	v.nonNegative("detect.top_k", int64(d.TopK))
+	v.fraction("detect.min_similarity", d.MinSimilarity)
+	v.nonNegative("detect.max_matches_per_file", int64(d.MaxMatchesPerFile))
+	v.check("detect.owned_mode", detector.ParseOwnedMode(d.OwnedMode))
+	v.check("detect.false_positive_mode", detector.ParseFalsePositiveMode(d.FalsePositiveMode))
+	v.fraction("detect.directory_coverage", d.DirectoryCoverage)
+	v.oneOf("detect.distances_format", d.DistancesFormat, detector.DistancesCSV, detector.DistancesBinary)
+	v.nonNegative("detect.distances_max", int64(d.DistancesMax))
+	v.nonNegative("detect.distance_batch", int64(d.DistanceBatch))
+	v.nonNegative("detect.modified_min_distance", int64(d.ModifiedMinDistance))
+	v.nonNegative("detect.modified_max_distance", int64(d.ModifiedMaxDistance))
+	if d.ModifiedMaxDistance > 0 && d.ModifiedMinDistance > d.ModifiedMaxDistance {
+		v.addf("detect.modified_min_distance", "must not exceed detect.modified_max_distance %d, got %d", d.ModifiedMaxDistance, d.ModifiedMinDistance)
+	}
+
+	for i, rule := range d.FailOn {
+		_, err := detector.ParseFailRule(rule)
+		v.check(fmt.Sprintf("detect.fail_on[%d]", i), err)
+	}
+	if d.FailExitCode < 1 || d.FailExitCode > 255 {
+		v.addf("detect.fail_exit_code", "must be between 1 and 255, got %d", d.FailExitCode)
+	}
+
+	for i, hook := range d.Webhooks {
+		v.httpURL(fmt.Sprintf("detect.webhooks[%d].url", i), hook.URL)
+	}
+	for i, u := range d.WebhookURLs {
+		v.httpURL(fmt.Sprintf("detect.webhook_urls[%d]", i), u)
+	}
+	v.nonNegative("detect.webhook_timeout", int64(d.WebhookTimeout))
+
+	if d.Vulns.Endpoint != "" {
+		v.httpURL("detect.vulns.endpoint", d.Vulns.Endpoint)
+	}
+	v.nonNegative("detect.vulns.cache_ttl", int64(d.Vulns.CacheTTL))
+}
@@ -0,0 +1,172 @@
+package deps
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// Report cross-references the declared dependencies of a target with the
+// components detected in its code
+type Report struct {
+	// Declared lists the dependencies whose component was detected
+	Declared []Correlation `json:"declared"`
+	// Undeclared lists the detected components no manifest declares and
+	// found outside the directories package managers install into,
+	// typically copied source
+	Undeclared []string `json:"undeclared"`
+	// Unused lists the declared dependencies whose code was not detected
+	Unused []Dependency `json:"unused"`
+}
+
+// Correlation is a declared dependency and the component detected for it
+type Correlation struct {
+	Dependency Dependency `json:"dependency"`
+	Component  string     `json:"component"`
+	// Copies lists the target files of the component outside the
+	// directories package managers install into, i.e. copied source of a
+	// managed dependency
+	Copies []string `json:"copies,omitempty"`
+}
+
+// managedDirs are the directories package managers install dependencies
+// into; detected code below them is managed, not copied. Code is often
+// committed to vendor and _deps by hand, so below them it is only managed
+// if a dependency of the given manager declares its component.
+var managedDirs = map[string]string{
+	"vendor":          ManagerGo,    // go mod vendor
+	"node_modules":    "",           // npm
+	"vcpkg_installed": "",           // vcpkg manifest mode
+	"_deps":           ManagerCMake, // CMake FetchContent
+}
+
+// Components returns the components detected in the results, by their
+// component attributions and file matches, with the target files each was
+// detected in
+func Components(results []*detector.DetectionResult, knownFilesDir string) map[string][]string {
+	components := make(map[string][]string)
+	add := func(component, file string) {
+		files := components[component]
+		if len(files) == 0 || files[len(files)-1] != file {
+			components[component] = append(files, file)
+		}
+	}
+	for _, result := range results {
+		for _, c := range result.Components {
+			add(c.Component, result.TargetFile)
+		}
+		for _, m := range result.Matches {
+			if component := m.ComponentIn(knownFilesDir); component != "" {
+				add(component, result.TargetFile)
+			}
+		}
+	}
+	return components
+}
+
+// Correlate matches declared dependencies to detected components by name,
+// by the last element of a module or package path, or by the repository
+// name of their URL. Components stored as author%name are compared by
+// name; case, '-', '_' and '.' are ignored.
+func Correlate(deps []Dependency, detected map[string][]string) *Report {
+	components := make([]string, 0, len(detected))
+	for component := range detected {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	byKey := make(map[string]string, len(components))
+	for _, component := range components {
+		name := component
+		if i := strings.LastIndex(name, "%"); i >= 0 {
+			name = name[i+1:]
+		}
+		byKey[nameKey(name)] = component
+	}
+
+	report := &Report{
+		Declared:   []Correlation{},
+		Undeclared: []string{},
+		Unused:     []Dependency{},
+	}
+	declared := make(map[string]bool)
+	for _, dep := range deps {
+		component, ok := "", false
+		for _, name := range dep.names() {
+			if component, ok = byKey[nameKey(name)]; ok {
+				break
+			}
+		}
+		if !ok {
+			report.Unused = append(report.Unused, dep)
+			continue
+		}
+		declared[component] = true
+
+		c := Correlation{Dependency: dep, Component: component}
+		for _, file := range detected[component] {
+			if !managed(file, dep.Manager) {
+				c.Copies = append(c.Copies, file)
+			}
+		}
+		report.Declared = append(report.Declared, c)
+	}
+	// Code only found where package managers install is managed, even
+	// if no parsed manifest declares it
+	for _, component := range components {
+		if declared[component] {
+			continue
+		}
+		for _, file := range detected[component] {
+			if !managed(file, "") {
+				report.Undeclared = append(report.Undeclared, component)
+				break
+			}
+		}
+	}
+	return report
+}
+
+// names returns the names a dependency may be stored under in the corpus
+func (d Dependency) names() []string {
+	names := []string{d.Name, path.Base(d.Name)}
+	if d.URL != "" {
+		names = append(names, strings.TrimSuffix(path.Base(strings.TrimSuffix(d.URL, "/")), ".git"))
+	}
+	return names
+}
+
+// managed reports whether a target file lies below a directory package
+// managers install into, for a file of a component declared by manager or
+// of an undeclared component if manager is empty
+func managed(file, manager string) bool {
+	for _, dir := range strings.Split(filepath.ToSlash(file), "/") {
+		if required, ok := managedDirs[dir]; ok && (required == "" || required == manager) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameKey normalizes a package or repository name for comparison
+func nameKey(name string) string {
+	return strings.NewReplacer("-", "", "_", "", ".", "").Replace(strings.ToLower(name))
+}
+
+// SaveReport writes a dependency report as JSON
+func SaveReport(report *Report, outputPath string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dependency report: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dependency report: %v", err)
+	}
+	return nil
+}
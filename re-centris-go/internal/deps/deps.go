@@ -0,0 +1,299 @@
+// Package deps parses the dependencies declared in a target (conan, vcpkg,
+// CMake FetchContent and ExternalProject, go.sum and package-lock.json) and
+// cross-references them with the components detected in its code.
+package deps
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/lexer"
+	"golang.org/x/mod/semver"
+)
+
+// Package managers of declared dependencies
+const (
+	ManagerConan = "conan"
+	ManagerVcpkg = "vcpkg"
+	ManagerCMake = "cmake"
+	ManagerGo    = "go"
+	ManagerNpm   = "npm"
+)
+
+// Dependency is a dependency declared in a manifest of the target
+type Dependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Manager string `json:"manager"`
+	// Source is the manifest file declaring the dependency
+	Source string `json:"source"`
+}
+
+var (
+	// conanReference matches a conan reference such as "zlib/1.2.13@user/channel"
+	conanReference = regexp.MustCompile(`^([A-Za-z0-9_.+-]+)/([^@#\s]+)`)
+	// conanRequire matches the quoted references of a conanfile.py
+	conanRequire = regexp.MustCompile(`["']([A-Za-z0-9_.+-]+/[^"'@#\s]+)(?:@[^"']*)?["']`)
+	// cmakeCall matches a FetchContent_Declare or ExternalProject_Add call
+	cmakeCall = regexp.MustCompile(`(?is)\b(FetchContent_Declare|ExternalProject_Add)\s*\(([^)]*)\)`)
+)
+
+// Parse returns the dependencies declared in the manifests below dir.
+// Version control and build directories are not searched.
+func Parse(dir string) ([]Dependency, error) {
+	var deps []Dependency
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case ".git", "build", "node_modules", "vendor":
+				if path != dir {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		var parse func(string) ([]Dependency, error)
+		name := info.Name()
+		switch {
+		case name == "conanfile.txt":
+			parse = parseConanfileTxt
+		case name == "conanfile.py":
+			parse = parseConanfilePy
+		case name == "vcpkg.json":
+			parse = parseVcpkg
+		case name == "CMakeLists.txt" || strings.HasSuffix(name, ".cmake"):
+			parse = parseCMake
+		case name == "go.sum":
+			parse = parseGoSum
+		case name == "package-lock.json":
+			parse = parsePackageLock
+		default:
+			return nil
+		}
+
+		found, err := parse(path)
+		if err != nil {
+			return err
+		}
+		deps = append(deps, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dependency manifests: %v", err)
+	}
+
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Name != deps[j].Name {
+			return deps[i].Name < deps[j].Name
+		}
+		if deps[i].Source != deps[j].Source {
+			return deps[i].Source < deps[j].Source
+		}
+		return deps[i].Version < deps[j].Version
+	})
+	return deps, nil
+}
+
+// parseConanfileTxt parses the [requires] section of a conanfile.txt
+func parseConanfileTxt(path string) ([]Dependency, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		deps     []Dependency
+		requires bool
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			requires = line == "[requires]" || line == "[tool_requires]" || line == "[build_requires]"
+			continue
+		}
+		if !requires || line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := conanReference.FindStringSubmatch(line); m != nil {
+			deps = append(deps, Dependency{Name: m[1], Version: m[2], Manager: ManagerConan, Source: path})
+		}
+	}
+	return deps, scanner.Err()
+}
+
+// parseConanfilePy parses the quoted references of a conanfile.py, as in
+// requires = "zlib/1.2.13" or self.requires("zlib/1.2.13")
+func parseConanfilePy(path string) ([]Dependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var deps []Dependency
+	for _, m := range conanRequire.FindAllStringSubmatch(string(data), -1) {
+		if ref := conanReference.FindStringSubmatch(m[1]); ref != nil {
+			deps = append(deps, Dependency{Name: ref[1], Version: ref[2], Manager: ManagerConan, Source: path})
+		}
+	}
+	return deps, nil
+}
+
+// parseVcpkg parses the dependencies of a vcpkg.json manifest, which are
+// names or objects with a name and an optional minimum version
+func parseVcpkg(path string) ([]Dependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Dependencies []json.RawMessage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var deps []Dependency
+	for _, raw := range manifest.Dependencies {
+		dep := Dependency{Manager: ManagerVcpkg, Source: path}
+		var entry struct {
+			Name    string `json:"name"`
+			Version string `json:"version>="`
+		}
+		if err := json.Unmarshal(raw, &dep.Name); err != nil {
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				return nil, fmt.Errorf("failed to parse dependency of %s: %v", path, err)
+			}
+			dep.Name, dep.Version = entry.Name, entry.Version
+		}
+		if dep.Name != "" {
+			deps = append(deps, dep)
+		}
+	}
+	return deps, nil
+}
+
+// parseCMake parses the FetchContent_Declare and ExternalProject_Add calls
+// of a CMake file, taking the name, repository or archive URL and tag
+func parseCMake(path string) ([]Dependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var deps []Dependency
+	for _, call := range cmakeCall.FindAllStringSubmatch(string(lexer.ForLanguage("cmake").Strip(data, false)), -1) {
+		args := strings.Fields(call[2])
+		if len(args) == 0 {
+			continue
+		}
+		dep := Dependency{Name: args[0], Manager: ManagerCMake, Source: path}
+		for i := 1; i+1 < len(args); i++ {
+			value := strings.Trim(args[i+1], `"`)
+			switch args[i] {
+			case "GIT_REPOSITORY", "URL":
+				dep.URL = value
+			case "GIT_TAG":
+				dep.Version = value
+			}
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// parseGoSum parses the modules of a go.sum file. Each module is reported
+// once, with the last version listed.
+func parseGoSum(path string) ([]Dependency, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		deps  []Dependency
+		index = make(map[string]int)
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		module, version := fields[0], strings.TrimSuffix(fields[1], "/go.mod")
+		if i, ok := index[module]; ok {
+			if semver.Compare(version, deps[i].Version) > 0 {
+				deps[i].Version = version
+			}
+			continue
+		}
+		index[module] = len(deps)
+		deps = append(deps, Dependency{Name: module, Version: version, URL: moduleURL(module), Manager: ManagerGo, Source: path})
+	}
+	return deps, scanner.Err()
+}
+
+// moduleURL returns the repository URL of a module on a known code host
+func moduleURL(module string) string {
+	parts := strings.Split(module, "/")
+	switch parts[0] {
+	case "github.com", "gitlab.com", "bitbucket.org":
+		if len(parts) >= 3 {
+			return "https://" + strings.Join(parts[:3], "/")
+		}
+	}
+	return ""
+}
+
+// parsePackageLock parses the packages of a package-lock.json file: the
+// packages map of lockfile versions 2 and 3, else the dependencies map of
+// version 1
+func parsePackageLock(path string) ([]Dependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	type lockedPackage struct {
+		Version string `json:"version"`
+	}
+	var lock struct {
+		Packages     map[string]lockedPackage `json:"packages"`
+		Dependencies map[string]lockedPackage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var deps []Dependency
+	if len(lock.Packages) > 0 {
+		for key, pkg := range lock.Packages {
+			// The root package has the empty key; nested packages are
+			// installed below node_modules of their dependents
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 {
+				continue
+			}
+			deps = append(deps, Dependency{Name: key[i+len("node_modules/"):], Version: pkg.Version, Manager: ManagerNpm, Source: path})
+		}
+	} else {
+		for name, pkg := range lock.Dependencies {
+			deps = append(deps, Dependency{Name: name, Version: pkg.Version, Manager: ManagerNpm, Source: path})
+		}
+	}
+	return deps, nil
+}
@@ -0,0 +1,133 @@
+package deps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseAndCorrelate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"conanfile.txt":  "[requires]\nzlib/1.2.13\n# comment\nopenssl/3.0.8@acme/stable\n\n[generators]\ncmake\n",
+		"app/vcpkg.json": `{"name": "app", "dependencies": ["fmt", {"name": "libpng", "version>=": "1.6.39"}]}`,
+		"CMakeLists.txt": `include(FetchContent)
+# FetchContent_Declare(ignored GIT_REPOSITORY https://example.com/ignored.git)
+FetchContent_Declare(
+  json
+  GIT_REPOSITORY https://github.com/nlohmann/json.git
+  GIT_TAG v3.11.2
+)
+`,
+		"build/conanfile.txt": "[requires]\nboost/1.81.0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	declared, err := Parse(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"zlib": "1.2.13", "openssl": "3.0.8", "fmt": "", "libpng": "1.6.39", "json": "v3.11.2"}
+	if len(declared) != len(want) {
+		t.Fatalf("Parse() = %+v, want %v", declared, want)
+	}
+	for _, dep := range declared {
+		if version, ok := want[dep.Name]; !ok || dep.Version != version {
+			t.Errorf("dependency %+v, want version %q", dep, version)
+		}
+	}
+
+	report := Correlate(declared, map[string][]string{
+		"madler%zlib":   {"src/inflate.c"},
+		"nlohmann%json": {"_deps/json-src/json.hpp"},
+		"sqlite":        {"src/sqlite3.c"},
+	})
+	if len(report.Declared) != 2 {
+		t.Fatalf("Declared = %+v, want json and zlib", report.Declared)
+	}
+	if len(report.Declared[0].Copies) != 0 || len(report.Declared[1].Copies) != 1 {
+		t.Errorf("Declared = %+v, want a copy of zlib only", report.Declared)
+	}
+	if len(report.Undeclared) != 1 || report.Undeclared[0] != "sqlite" {
+		t.Errorf("Undeclared = %v, want sqlite", report.Undeclared)
+	}
+	if len(report.Unused) != 3 {
+		t.Errorf("Unused = %+v, want openssl, fmt and libpng", report.Unused)
+	}
+}
+
+func TestParseLockfiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.sum": `github.com/pkg/errors v0.9.0/go.mod h1:a=
+github.com/pkg/errors v0.9.1 h1:b=
+github.com/pkg/errors v0.9.1/go.mod h1:c=
+golang.org/x/sys v0.10.0 h1:d=
+golang.org/x/sys v0.9.0 h1:e=
+`,
+		"web/package-lock.json": `{"lockfileVersion": 3, "packages": {
+  "": {"name": "web"},
+  "node_modules/lodash": {"version": "4.17.21"},
+  "node_modules/@babel/core": {"version": "7.21.0"},
+  "node_modules/@babel/core/node_modules/semver": {"version": "6.3.0"}
+}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	declared, err := Parse(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"github.com/pkg/errors": "v0.9.1",
+		"golang.org/x/sys":      "v0.10.0",
+		"lodash":                "4.17.21",
+		"@babel/core":           "7.21.0",
+		"semver":                "6.3.0",
+	}
+	if len(declared) != len(want) {
+		t.Fatalf("Parse() = %+v, want %v", declared, want)
+	}
+	for _, dep := range declared {
+		if version, ok := want[dep.Name]; !ok || dep.Version != version {
+			t.Errorf("dependency %+v, want version %q", dep, version)
+		}
+	}
+
+	report := Correlate(declared, map[string][]string{
+		"pkg%errors":      {"vendor/github.com/pkg/errors/errors.go"},
+		"lodash%lodash":   {"web/node_modules/lodash/lodash.js", "web/static/lodash.min.js"},
+		"babel%babel":     {"web/node_modules/@babel/core/index.js"},
+		"madler%zlib":     {"native/zlib/inflate.c"},
+		"golang%sys":      {"vendor/golang.org/x/sys/unix.go"},
+		"tj%commander.js": {"web/lib/commander.js"},
+		"google%re2":      {"vendor/re2/re2.cc"},
+	})
+	copies := make(map[string][]string)
+	for _, c := range report.Declared {
+		copies[c.Component] = c.Copies
+	}
+	if len(copies) != 3 || len(copies["pkg%errors"]) != 0 || len(copies["golang%sys"]) != 0 ||
+		len(copies["lodash%lodash"]) != 1 || copies["lodash%lodash"][0] != "web/static/lodash.min.js" {
+		t.Errorf("Declared = %+v, want managed errors and sys and a copy of lodash", report.Declared)
+	}
+	if len(report.Undeclared) != 3 || report.Undeclared[0] != "google%re2" || report.Undeclared[1] != "madler%zlib" {
+		t.Errorf("Undeclared = %v, want re2, zlib and commander.js but not the managed babel", report.Undeclared)
+	}
+}
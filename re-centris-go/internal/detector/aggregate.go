@@ -0,0 +1,229 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// DefaultDirectoryCoverage is the default share of the files of a
+// directory that must belong to one component for the directory to be
+// reported as a copy of it
+const DefaultDirectoryCoverage = 0.8
+
+// DirectorySummary reports a target directory whose files are mostly a
+// copy of one component
+type DirectorySummary struct {
+	Directory string `json:"directory"`
+	Component string `json:"component"`
+	PURL      string `json:"purl,omitempty"`
+	// Version is the version range most files of the directory matched
+	Version string `json:"version,omitempty"`
+	// Files counts the detected files below the directory and
+	// MatchedFiles those attributed to the component
+	Files        int     `json:"files"`
+	MatchedFiles int     `json:"matched_files"`
+	Coverage     float64 `json:"coverage"`
+	Summary      string  `json:"summary"`
+}
+
+// AggregateOptions contains options for rolling results up to directories
+type AggregateOptions struct {
+	// MinCoverage is the share of files that must belong to the component,
+	// defaults to DefaultDirectoryCoverage
+	MinCoverage float64
+	// MinFiles is the number of files a directory needs to be reported,
+	// defaults to 2
+	MinFiles int
+}
+
+// fileAttribution is the component a target file is attributed to
+type fileAttribution struct {
+	component string
+	purl      string
+	version   string
+}
+
+// directoryCount counts the files below a directory by component
+type directoryCount struct {
+	files      int
+	components map[string]int
+	purls      map[string]string
+	versions   map[string]map[string]int
+}
+
+// AggregateDirectories rolls the per-file results up to the directories of
+// the targets. A directory is reported if enough of its files belong to one
+// component; its subdirectories are then not reported for that component,
+// so a vendored subtree is reported once. Directories above the common
+// directory of all targets are not considered.
+func AggregateDirectories(results []*DetectionResult, opts AggregateOptions) []DirectorySummary {
+	if opts.MinCoverage <= 0 {
+		opts.MinCoverage = DefaultDirectoryCoverage
+	}
+	if opts.MinFiles <= 0 {
+		opts.MinFiles = 2
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	root := filepath.Dir(results[0].TargetFile)
+	for _, result := range results[1:] {
+		root = commonDir(root, filepath.Dir(result.TargetFile))
+	}
+
+	counts := make(map[string]*directoryCount)
+	for _, result := range results {
+		attribution, ok := attribute(result)
+		for dir := filepath.Dir(result.TargetFile); ; dir = filepath.Dir(dir) {
+			c := counts[dir]
+			if c == nil {
+				c = &directoryCount{
+					components: make(map[string]int),
+					purls:      make(map[string]string),
+					versions:   make(map[string]map[string]int),
+				}
+				counts[dir] = c
+			}
+			c.files++
+			if ok {
+				c.components[attribution.component]++
+				if attribution.purl != "" {
+					c.purls[attribution.component] = attribution.purl
+				}
+				if attribution.version != "" {
+					if c.versions[attribution.component] == nil {
+						c.versions[attribution.component] = make(map[string]int)
+					}
+					c.versions[attribution.component][attribution.version]++
+				}
+			}
+			if dir == root || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+
+	// Visit parents before their subdirectories
+	dirs := make([]string, 0, len(counts))
+	for dir := range counts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var summaries []DirectorySummary
+	reported := make(map[string][]string) // component to reported directories
+	for _, dir := range dirs {
+		c := counts[dir]
+		if c.files < opts.MinFiles {
+			continue
+		}
+		component, matched := topComponent(c.components)
+		coverage := float64(matched) / float64(c.files)
+		if component == "" || coverage < opts.MinCoverage || below(dir, reported[component]) {
+			continue
+		}
+		reported[component] = append(reported[component], dir)
+
+		s := DirectorySummary{
+			Directory:    dir,
+			Component:    component,
+			PURL:         c.purls[component],
+			Version:      topVersion(c.versions[component]),
+			Files:        c.files,
+			MatchedFiles: matched,
+			Coverage:     coverage,
+		}
+		s.Summary = fmt.Sprintf("%s is %.0f%% %s", dir, 100*coverage, component)
+		if s.Version != "" {
+			s.Summary += " " + s.Version
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+// attribute returns the component a result is attributed to: the component
+// of its most similar match, else its best scored component
+func attribute(result *DetectionResult) (fileAttribution, bool) {
+	var a fileAttribution
+	if len(result.Matches) > 0 && result.Matches[0].Component != "" {
+		a.component, a.purl = result.Matches[0].Component, result.Matches[0].PURL
+	} else if len(result.Components) > 0 {
+		a.component, a.purl = result.Components[0].Component, result.Components[0].PURL
+	} else {
+		return a, false
+	}
+	for _, c := range result.Components {
+		if c.Component == a.component && c.Version != nil {
+			a.version = c.Version.Range
+		}
+	}
+	return a, true
+}
+
+// topComponent returns the component with the most files, the first by
+// name among equals
+func topComponent(components map[string]int) (string, int) {
+	var (
+		top   string
+		count int
+	)
+	for component, n := range components {
+		if n > count || (n == count && component < top) {
+			top, count = component, n
+		}
+	}
+	return top, count
+}
+
+// topVersion returns the version range reported by the most files
+func topVersion(versions map[string]int) string {
+	top, _ := topComponent(versions)
+	return top
+}
+
+// below reports whether dir is one of dirs or below one of them
+func below(dir string, dirs []string) bool {
+	for _, parent := range dirs {
+		if parent == "." && !filepath.IsAbs(dir) {
+			return true
+		}
+		if dir == parent || strings.HasPrefix(dir, strings.TrimSuffix(parent, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// commonDir returns the deepest directory containing both a and b
+func commonDir(a, b string) string {
+	for !(a == b || below(b, []string{a})) {
+		parent := filepath.Dir(a)
+		if parent == a {
+			return a
+		}
+		a = parent
+	}
+	return a
+}
+
+// WriteDirectorySummaries writes directory summaries as JSON
+func WriteDirectorySummaries(summaries []DirectorySummary, path string) error {
+	if summaries == nil {
+		summaries = []DirectorySummary{}
+	}
+	data, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal directory summaries: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write directory summaries: %v", err)
+	}
+	return nil
+}
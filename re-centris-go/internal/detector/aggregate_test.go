@@ -0,0 +1,39 @@
+package detector
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAggregateDirectories(t *testing.T) {
+	result := func(path, component string) *DetectionResult {
+		r := &DetectionResult{TargetFile: filepath.FromSlash(path)}
+		if component != "" {
+			r.Matches = []Match{{Component: component}}
+			r.Components = []ComponentMatch{{Component: component, Version: &ComponentVersion{Range: "1.2.11"}}}
+		}
+		return r
+	}
+	results := []*DetectionResult{
+		result("src/main.c", ""),
+		result("src/util.c", ""),
+		result("src/third_party/zlib/inflate.c", "zlib"),
+		result("src/third_party/zlib/deflate.c", "zlib"),
+		result("src/third_party/zlib/contrib/minizip/zip.c", "zlib"),
+		result("src/third_party/zlib/contrib/minizip/unzip.c", "zlib"),
+		result("src/third_party/zlib/examples/gun.c", ""),
+		result("src/third_party/lz4/lz4.c", "lz4"),
+	}
+
+	summaries := AggregateDirectories(results, AggregateOptions{})
+	if len(summaries) != 1 {
+		t.Fatalf("AggregateDirectories() = %+v, want only the zlib directory", summaries)
+	}
+	s := summaries[0]
+	if s.Directory != filepath.FromSlash("src/third_party/zlib") || s.Files != 5 || s.MatchedFiles != 4 || s.Version != "1.2.11" {
+		t.Errorf("AggregateDirectories() = %+v, want 4 of 5 files of src/third_party/zlib", s)
+	}
+	if want := filepath.FromSlash("src/third_party/zlib") + " is 80% zlib 1.2.11"; s.Summary != want {
+		t.Errorf("Summary = %q, want %q", s.Summary, want)
+	}
+}
@@ -0,0 +1,124 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// defaultDistanceBatch is the number of hash pairs compared per call of
+// the distance backend when DistanceBatch is not set
+const defaultDistanceBatch = 1 << 20
+
+// DistanceBackend computes the TLSH distances between batches of target
+// and known hashes. Large deployments may implement it to offload the
+// comparison to vector units, GPUs or a remote service; GoDistances, the
+// default, computes them in process. It must be safe for concurrent use.
+type DistanceBackend interface {
+	// Distances returns one row per target holding its distances to all
+	// candidates, as tlsh.Distance computes them: -1 where a hash is nil
+	Distances(ctx context.Context, targets, candidates []*tlsh.TLSH) ([][]int, error)
+}
+
+// GoDistances is the pure Go distance backend
+type GoDistances struct{}
+
+// Distances implements DistanceBackend
+func (GoDistances) Distances(ctx context.Context, targets, candidates []*tlsh.TLSH) ([][]int, error) {
+	rows := make([][]int, len(targets))
+	for i, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rows[i] = tlsh.DistanceMany(target, candidates)
+	}
+	return rows, nil
+}
+
+// distanceBackend returns the configured distance backend
+func (d *Detector) distanceBackend() DistanceBackend {
+	if d.opts.DistanceBackend == nil {
+		return GoDistances{}
+	}
+	return d.opts.DistanceBackend
+}
+
+// batchDistances calls fn with the distances between targets and
+// candidates, one batch of candidates starting at start at a time, so that
+// a backend call compares at most DistanceBatch pairs. A batch the backend
+// fails on is logged and computed by GoDistances instead, which yields the
+// same distances. Once ctx is done, the remaining batches are skipped.
+func (d *Detector) batchDistances(ctx context.Context, targets, candidates []*tlsh.TLSH, fn func(start int, rows [][]int)) {
+	if len(targets) == 0 || len(candidates) == 0 {
+		return
+	}
+	pairs := d.opts.DistanceBatch
+	if pairs <= 0 {
+		pairs = defaultDistanceBatch
+	}
+	size := max(pairs/len(targets), 1)
+
+	backend := d.distanceBackend()
+	for start := 0; start < len(candidates); start += size {
+		batch := candidates[start:min(start+size, len(candidates))]
+		rows, err := backend.Distances(ctx, targets, batch)
+		if err == nil {
+			err = checkRows(rows, len(targets), len(batch))
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("Distance backend failed, computing the batch in Go",
+				zap.Int("targets", len(targets)),
+				zap.Int("candidates", len(batch)),
+				zap.Error(err))
+			rows, _ = GoDistances{}.Distances(context.Background(), targets, batch)
+		}
+		fn(start, rows)
+	}
+}
+
+// checkRows reports an error if a backend returned distances of another
+// shape than targets by candidates
+func checkRows(rows [][]int, targets, candidates int) error {
+	if len(rows) != targets {
+		return fmt.Errorf("backend returned %d rows for %d targets", len(rows), targets)
+	}
+	for _, row := range rows {
+		if len(row) != candidates {
+			return fmt.Errorf("backend returned %d distances for %d candidates", len(row), candidates)
+		}
+	}
+	return nil
+}
+
+// similarFiles returns the known files of the language of a target file
+// within maxDistance of it, as analyzer.FindSimilarFiles does, comparing
+// them with the distance backend
+func (d *Detector) similarFiles(ctx context.Context, target *analyzer.FileInfo, knownFiles []*analyzer.FileInfo, maxDistance int) []*analyzer.FileInfo {
+	var (
+		files  []*analyzer.FileInfo
+		hashes []*tlsh.TLSH
+	)
+	for _, file := range knownFiles {
+		if file.Path != target.Path && file.Language == target.Language {
+			files = append(files, file)
+			hashes = append(hashes, file.Hash)
+		}
+	}
+
+	var similar []*analyzer.FileInfo
+	d.batchDistances(ctx, []*tlsh.TLSH{target.Hash}, hashes, func(start int, rows [][]int) {
+		for j, distance := range rows[0] {
+			if distance >= 0 && distance <= maxDistance {
+				similar = append(similar, files[start+j])
+			}
+		}
+	})
+	return similar
+}
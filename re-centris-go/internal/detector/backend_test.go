@@ -0,0 +1,126 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+)
+
+// countingBackend records the batches it is called with, failing them if
+// fail is set
+type countingBackend struct {
+	mutex   sync.Mutex
+	calls   int
+	maxPair int
+	fail    bool
+}
+
+func (b *countingBackend) Distances(ctx context.Context, targets, candidates []*tlsh.TLSH) ([][]int, error) {
+	b.mutex.Lock()
+	b.calls++
+	b.maxPair = max(b.maxPair, len(targets)*len(candidates))
+	b.mutex.Unlock()
+	if b.fail {
+		return nil, fmt.Errorf("device lost")
+	}
+	return GoDistances{}.Distances(ctx, targets, candidates)
+}
+
+func TestDistanceBackend(t *testing.T) {
+	known := t.TempDir()
+	target := filepath.Join(t.TempDir(), "copy.c")
+	statements := map[string]string{
+		"zlib":  "    crc = table[(crc ^ buf[%d]) & 0xff] ^ (crc >> 8);\n    if (len < %d) { return crc; }\n",
+		"png":   "    row[%d] = (png_byte)((row[%d] + prior[i]) & 0xff);\n    while (width-- > 0) { width /= 2; }\n",
+		"expat": "    if (ptr == end) { *next = ptr; tok = XML_TOK_PARTIAL_%d; }\n    switch (BYTE_TYPE(enc, ptr + %d)) { case BT_LT: break; }\n",
+	}
+	for component, statement := range statements {
+		var source strings.Builder
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(&source, "int %s_%d(unsigned char *buf, int len)\n{\n", component, i)
+			for j := 0; j < 6; j++ {
+				fmt.Fprintf(&source, statement, i*7+j, j+i)
+			}
+			source.WriteString("    return 0;\n}\n\n")
+		}
+		path := filepath.Join(known, component, "src.c")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if component == "png" {
+			if err := os.WriteFile(target, []byte(source.String()), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	detect := func(backend DistanceBackend, batch int) []*DetectionResult {
+		results, err := New(DetectorOptions{
+			MaxWorkers:          2,
+			SimilarityThreshold: 0.8,
+			Languages:           analyzer.DefaultLanguages(),
+			KnownFilesDir:       known,
+			DistanceBackend:     backend,
+			DistanceBatch:       batch,
+		}).DetectSimilarity(context.Background(), []string{target})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	want := detect(nil, 0)
+	if len(want) != 1 || len(want[0].Matches) != 1 || len(want[0].Components) == 0 {
+		t.Fatalf("got results %+v, want one match", want)
+	}
+
+	// Three target functions are compared with nine known functions in
+	// batches of at most four pairs, one candidate at a time
+	backend := &countingBackend{}
+	if got := detect(backend, 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("results with a backend = %+v, want %+v", got[0], want[0])
+	}
+	if backend.calls < 9 || backend.maxPair > 4 {
+		t.Errorf("backend got %d calls of up to %d pairs, want at least 9 of at most 4", backend.calls, backend.maxPair)
+	}
+
+	// Failed batches are computed in Go
+	if got := detect(&countingBackend{fail: true}, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("results with a failing backend = %+v, want %+v", got[0], want[0])
+	}
+}
+
+func TestBatchDistancesCancelled(t *testing.T) {
+	hash, err := tlsh.New([]byte(strings.Repeat("int f(int x) { return x * 2 + 1; }\n", 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A backend failing because ctx is done is not replaced by GoDistances
+	d := New(DetectorOptions{DistanceBackend: &countingBackend{fail: true}, DistanceBatch: 1})
+	batches := 0
+	d.batchDistances(ctx, []*tlsh.TLSH{hash}, []*tlsh.TLSH{hash, hash}, func(int, [][]int) { batches++ })
+	if batches != 0 {
+		t.Errorf("batchDistances() computed %d batches after cancellation, want 0", batches)
+	}
+
+	// Files without a hash are at distance -1, not similar
+	target := &analyzer.FileInfo{Path: "t.c", Language: "cpp", Hash: hash}
+	known := []*analyzer.FileInfo{{Path: "k.c", Language: "cpp", Hash: hash}, {Path: "empty.c", Language: "cpp"}}
+	if similar := New(DetectorOptions{}).similarFiles(context.Background(), target, known, 10); len(similar) != 1 || similar[0].Path != "k.c" {
+		t.Errorf("similarFiles() = %v, want k.c only", similar)
+	}
+}
@@ -0,0 +1,185 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// baselineVersion is the version of the baseline file format
+const baselineVersion = 1
+
+// Baseline is a set of accepted matches that are suppressed from results
+type Baseline struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+
+	accepted map[BaselineEntry]struct{}
+}
+
+// BaselineEntry identifies an accepted match by the target file, relative
+// to the target root, the matched component and the TLSH digest of the
+// matched known file. A known file that changes gets a new digest, so its
+// match is reported again.
+type BaselineEntry struct {
+	Target    string `json:"target"`
+	Component string `json:"component"`
+	Hash      string `json:"hash"`
+}
+
+// NewBaseline creates a baseline accepting every match of the results
+func (d *Detector) NewBaseline(results []*DetectionResult) *Baseline {
+	b := &Baseline{Version: baselineVersion, Entries: []BaselineEntry{}}
+	for _, result := range results {
+		for _, match := range result.Matches {
+			b.Accept(d.BaselineEntry(result, match))
+		}
+	}
+	return b
+}
+
+// LoadBaseline reads a baseline file
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %v", err)
+	}
+
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline: %v", err)
+	}
+	if b.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version: %d", b.Version)
+	}
+
+	entries := b.Entries
+	b.Entries = []BaselineEntry{}
+	for _, entry := range entries {
+		b.Accept(entry)
+	}
+
+	return &b, nil
+}
+
+// Save writes the baseline to path
+func (b *Baseline) Save(path string) error {
+	// Sort entries so regenerated baselines produce small diffs
+	sort.Slice(b.Entries, func(i, j int) bool {
+		x, y := b.Entries[i], b.Entries[j]
+		if x.Target != y.Target {
+			return x.Target < y.Target
+		}
+		if x.Component != y.Component {
+			return x.Component < y.Component
+		}
+		return x.Hash < y.Hash
+	})
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal baseline: %v", err)
+	}
+
+	// Create parent directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %v", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write baseline: %v", err)
+	}
+
+	return nil
+}
+
+// ApplyBaseline removes the matches accepted by the baseline from the
+// results and returns the number of suppressed matches
+func (d *Detector) ApplyBaseline(results []*DetectionResult, b *Baseline) int {
+	suppressed := 0
+	for _, result := range results {
+		matches := result.Matches[:0]
+		for _, match := range result.Matches {
+			if b.Accepts(d.BaselineEntry(result, match)) {
+				suppressed++
+				continue
+			}
+			matches = append(matches, match)
+		}
+		result.Matches = matches
+		result.MatchCount = len(matches)
+	}
+	return suppressed
+}
+
+// BaselineEntry returns the baseline entry of a match. Matches outside the
+// known files directory have no component and are keyed by the known file.
+func (d *Detector) BaselineEntry(result *DetectionResult, match Match) BaselineEntry {
+	component := d.matchComponent(match)
+	if component == "" {
+		component = match.File
+	}
+	return BaselineEntry{
+		Target:    d.baselineTarget(result.TargetFile),
+		Component: component,
+		Hash:      match.Hash,
+	}
+}
+
+// baselineTarget returns the path of a target file relative to the target
+// root, so that entries match however the target files are named
+func (d *Detector) baselineTarget(path string) string {
+	root := d.opts.TargetRoot
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Accept accepts an entry unless it is already accepted
+func (b *Baseline) Accept(entry BaselineEntry) {
+	if b.accepted == nil {
+		b.accepted = make(map[BaselineEntry]struct{})
+	}
+	if _, ok := b.accepted[entry]; ok {
+		return
+	}
+	b.accepted[entry] = struct{}{}
+	b.Entries = append(b.Entries, entry)
+}
+
+// Reject removes an entry from the baseline if it is accepted
+func (b *Baseline) Reject(entry BaselineEntry) {
+	if !b.Accepts(entry) {
+		return
+	}
+	delete(b.accepted, entry)
+	for i, accepted := range b.Entries {
+		if accepted == entry {
+			b.Entries = append(b.Entries[:i], b.Entries[i+1:]...)
+			break
+		}
+	}
+}
+
+// Accepts reports whether an entry is accepted
+func (b *Baseline) Accepts(entry BaselineEntry) bool {
+	_, ok := b.accepted[entry]
+	return ok
+}
@@ -0,0 +1,58 @@
+package detector
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	d := New(DetectorOptions{KnownFilesDir: "/known"})
+	accepted := []*DetectionResult{{
+		TargetFile: "src/inflate.c",
+		Matches:    []Match{{File: "/known/zlib/inflate.c", Hash: "T1A"}},
+		MatchCount: 1,
+	}}
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := d.NewBaseline(accepted).Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline() error = %v", err)
+	}
+
+	results := []*DetectionResult{{
+		TargetFile: "src/inflate.c",
+		Matches: []Match{
+			{File: "/known/zlib/inflate.c", Hash: "T1A"},
+			{File: "/known/zlib/inflate.c", Hash: "T1B"},
+			{File: "/known/libpng/png.c", Hash: "T1A"},
+		},
+		MatchCount: 3,
+	}}
+	if suppressed := d.ApplyBaseline(results, baseline); suppressed != 1 {
+		t.Errorf("ApplyBaseline() = %d, want 1", suppressed)
+	}
+	if got := results[0]; got.MatchCount != 2 || got.Matches[0].Hash != "T1B" || got.Matches[1].File != "/known/libpng/png.c" {
+		t.Errorf("remaining matches = %+v, want the changed zlib file and libpng", got.Matches)
+	}
+}
+
+func TestBaselineTarget(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		root, path, want string
+	}{
+		{"", "src/inflate.c", "src/inflate.c"},
+		{"", "./src/../src/inflate.c", "src/inflate.c"},
+		{root, filepath.Join(root, "src", "inflate.c"), "src/inflate.c"},
+	}
+	for _, tt := range tests {
+		d := New(DetectorOptions{TargetRoot: tt.root})
+		entry := d.BaselineEntry(&DetectionResult{TargetFile: tt.path}, Match{File: "/known/zlib/inflate.c"})
+		if entry.Target != tt.want {
+			t.Errorf("BaselineEntry(%q).Target = %q, want %q", tt.path, entry.Target, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package detector
+
+import (
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+// Clone types of a match, from most to least confident
+const (
+	// CloneExact means the files are byte-for-byte identical (type 1)
+	CloneExact = "exact"
+	// CloneRenamed means the files have the same token stream, differing
+	// only in layout, comments, names or literals (type 2)
+	CloneRenamed = "renamed"
+	// CloneNearMiss means the files are within the TLSH distance threshold
+	// but differ in their token streams (type 3)
+	CloneNearMiss = "near-miss"
+)
+
+// ParseCloneTypes validates a list of clone types
+func ParseCloneTypes(types []string) error {
+	for _, t := range types {
+		switch t {
+		case CloneExact, CloneRenamed, CloneNearMiss:
+		default:
+			return fmt.Errorf("unsupported clone type: %s", t)
+		}
+	}
+	return nil
+}
+
+// classifyClone returns the clone type of a match. Known files loaded from
+// signatures written before digests were recorded fall back to the TLSH
+// distance, where an identical digest counts as renamed. Files whose
+// normalized digests are both known and differ are modified, near-miss
+// clones even at distance 0.
+func classifyClone(target, known *analyzer.FileInfo, distance int) string {
+	switch {
+	case target.Digest != "" && target.Digest == known.Digest:
+		return CloneExact
+	case target.NormalizedDigest != "" && target.NormalizedDigest == known.NormalizedDigest:
+		return CloneRenamed
+	case distance == 0 && (target.NormalizedDigest == "" || known.NormalizedDigest == ""):
+		return CloneRenamed
+	default:
+		return CloneNearMiss
+	}
+}
+
+// keepClone reports whether matches of a clone type are reported
+func (d *Detector) keepClone(cloneType string) bool {
+	if len(d.opts.CloneTypes) == 0 {
+		return true
+	}
+	for _, t := range d.opts.CloneTypes {
+		if t == cloneType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package detector
+
+import (
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+func TestClassifyClone(t *testing.T) {
+	target := &analyzer.FileInfo{Digest: "a", NormalizedDigest: "n"}
+	tests := []struct {
+		known    *analyzer.FileInfo
+		distance int
+		want     string
+	}{
+		{&analyzer.FileInfo{Digest: "a", NormalizedDigest: "n"}, 0, CloneExact},
+		{&analyzer.FileInfo{Digest: "b", NormalizedDigest: "n"}, 12, CloneRenamed},
+		{&analyzer.FileInfo{Digest: "b", NormalizedDigest: "m"}, 12, CloneNearMiss},
+		// Differing token streams that hash alike
+		{&analyzer.FileInfo{Digest: "b", NormalizedDigest: "m"}, 0, CloneNearMiss},
+		// Signatures without digests
+		{&analyzer.FileInfo{}, 0, CloneRenamed},
+		{&analyzer.FileInfo{}, 5, CloneNearMiss},
+	}
+	for _, tt := range tests {
+		if got := classifyClone(target, tt.known, tt.distance); got != tt.want {
+			t.Errorf("classifyClone(%+v, %d) = %s, want %s", tt.known, tt.distance, got, tt.want)
+		}
+	}
+
+	if err := ParseCloneTypes([]string{CloneExact, "similar"}); err == nil {
+		t.Error("ParseCloneTypes() accepted an unknown clone type")
+	}
+}
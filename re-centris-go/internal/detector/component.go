@@ -0,0 +1,373 @@
+package detector
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/artifact"
+	"github.com/re-centris/re-centris-go/internal/common/intern"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/purl"
+	"go.uber.org/zap"
+)
+
+// defaultFunctionThreshold is the default maximum TLSH distance for two functions to match
+const defaultFunctionThreshold = 30
+
+// ComponentMatch represents the attribution of a target file to a known component
+type ComponentMatch struct {
+	Component        string  `json:"component"`
+	PURL             string  `json:"purl,omitempty"`
+	MatchedFunctions int     `json:"matched_functions"`
+	Score            float64 `json:"score"`
+	// Version is set if the corpus has version signatures of the component
+	Version *ComponentVersion `json:"version,omitempty"`
+	// Internal marks an owned component
+	Internal bool `json:"internal,omitempty"`
+	// Metadata is set if it was fetched from the host of the repository
+	Metadata *manifest.Metadata `json:"metadata,omitempty"`
+}
+
+// knownFunction is a function signature belonging to a known component
+type knownFunction struct {
+	component string
+	file      string
+	name      string
+	scope     string
+	signature string
+	startLine int
+	endLine   int
+	hash      *tlsh.TLSH
+}
+
+// functionHit is the closest function of a known file matching a target
+// function. It copies the location of the known function so that it does
+// not retain the index.
+type functionHit struct {
+	component string
+	name      string
+	scope     string
+	signature string
+	startLine int
+	endLine   int
+	distance  int
+}
+
+// functionMatch records the components and known files containing a
+// function of a target file
+type functionMatch struct {
+	target     parser.Function
+	components map[string]struct{}
+	files      map[string]functionHit
+}
+
+// componentIndex holds the function signatures of all known components
+type componentIndex struct {
+	functions []knownFunction
+	// hashes are the hashes of functions, compared in one batch
+	hashes     []*tlsh.TLSH
+	components int
+	// names are the components with function signatures
+	names map[string]struct{}
+}
+
+// componentOf returns the component a known file belongs to, which is the
+// first path element below the known files directory
+func (d *Detector) componentOf(path string) string {
+	return artifact.ComponentOf(d.opts.KnownFilesDir, path)
+}
+
+// knownComponent returns the component of a known file, as recorded with
+// its signatures or else derived from its path
+func (d *Detector) knownComponent(file *analyzer.FileInfo) string {
+	if file.Component != "" {
+		return file.Component
+	}
+	return d.componentOf(file.Path)
+}
+
+// matchComponent returns the component of the known file of a match
+func (d *Detector) matchComponent(match Match) string {
+	return match.ComponentIn(d.opts.KnownFilesDir)
+}
+
+// setRepositories records the package URLs, metadata and owned components
+// of the corpus repositories
+func (d *Detector) setRepositories(repos []manifest.Repository) {
+	d.purls = make(map[string]string, len(repos))
+	d.metadata = make(map[string]*manifest.Metadata)
+	for _, repo := range repos {
+		if repo.Metadata != nil {
+			d.metadata[repo.Name] = repo.Metadata
+		}
+		if repo.PURL != "" {
+			d.purls[repo.Name] = repo.PURL
+		} else {
+			d.purls[repo.Name] = purl.ForComponent(repo.Name, repo.URL, repo.Commit)
+		}
+	}
+	d.setOwned(repos)
+}
+
+// loadRepositories loads the repositories of the corpus unless they are
+// already known. The preprocessor records them in the corpus manifest of
+// the signatures; only known files analyzed directly have their
+// repositories collected, which runs git and license detection.
+func (d *Detector) loadRepositories(ctx context.Context) {
+	if d.purls != nil {
+		return
+	}
+	if dir := CorpusDir(d.opts); dir != "" {
+		var repos []manifest.Repository
+		if m, _, err := manifest.Read(dir); err == nil {
+			repos = m.Repositories
+		} else {
+			logger.Debug("Corpus has no manifest listing its repositories",
+				zap.String("dir", dir),
+				zap.Error(err))
+		}
+		d.setRepositories(repos)
+		return
+	}
+	repos, err := manifest.CollectRepositories(ctx, d.opts.KnownFilesDir)
+	if err != nil {
+		logger.Debug("Failed to collect known repositories",
+			zap.String("dir", d.opts.KnownFilesDir),
+			zap.Error(err))
+	}
+	d.setRepositories(repos)
+}
+
+// CorpusDir returns the directory holding the corpus manifest of
+// preprocessed known files: the signature directory, or the directory of
+// the signature index. It is empty for known files analyzed directly.
+func CorpusDir(opts DetectorOptions) string {
+	switch {
+	case opts.SignatureDir != "":
+		return opts.SignatureDir
+	case opts.SignatureIndex != "":
+		return filepath.Dir(opts.SignatureIndex)
+	}
+	return ""
+}
+
+// componentPURL returns the package URL of a component. Components without
+// a known repository get an unversioned purl derived from their name.
+func (d *Detector) componentPURL(component string) string {
+	if component == "" {
+		return ""
+	}
+	if p, ok := d.purls[component]; ok {
+		return p
+	}
+	return purl.ForComponent(component, "", "")
+}
+
+// buildComponentIndex collects the function signatures of all known files
+// grouped by component
+func (d *Detector) buildComponentIndex(knownFiles []*analyzer.FileInfo) *componentIndex {
+	index := &componentIndex{}
+	components := make(map[string]struct{})
+
+	// Component names are sliced from each file's relative path, so intern
+	// them to avoid retaining one path per indexed function
+	names := intern.New()
+
+	for _, file := range knownFiles {
+		component := d.knownComponent(file)
+		if component == "" {
+			continue
+		}
+		component = names.Intern(component)
+
+		for _, fn := range file.Functions {
+			if d.common(d.frequency[fn.Hash]) {
+				continue
+			}
+			hash, err := tlsh.Parse(fn.Hash)
+			if err != nil {
+				continue
+			}
+			index.functions = append(index.functions, knownFunction{
+				component: component,
+				file:      file.Path,
+				name:      fn.Name,
+				scope:     names.Intern(fn.Scope),
+				signature: names.Intern(fn.Signature),
+				startLine: fn.StartLine,
+				endLine:   fn.EndLine,
+				hash:      hash,
+			})
+			index.hashes = append(index.hashes, hash)
+			components[component] = struct{}{}
+		}
+	}
+
+	index.components = len(components)
+	index.names = components
+	return index
+}
+
+// matchFunctions finds the known components and files containing each
+// function of a target file. Functions without any match are omitted.
+func (d *Detector) matchFunctions(ctx context.Context, target *analyzer.FileInfo, index *componentIndex) []functionMatch {
+	if index == nil || index.components == 0 {
+		return nil
+	}
+	return d.matchedFunctions(d.addFunctionMatches(ctx, nil, target, index))
+}
+
+// addFunctionMatches adds the matches of the functions of a target file in
+// index to matches, which holds one entry per target function or is nil.
+// Matches of several indexes accumulate, see matchedFunctions. The
+// functions of the target are compared in one batch, see batchDistances.
+func (d *Detector) addFunctionMatches(ctx context.Context, matches []functionMatch, target *analyzer.FileInfo, index *componentIndex) []functionMatch {
+	if matches == nil {
+		matches = make([]functionMatch, len(target.Functions))
+	}
+
+	var (
+		hashes    []*tlsh.TLSH
+		positions []int // of the hashed functions in target.Functions
+	)
+	for i, fn := range target.Functions {
+		if hash, err := tlsh.Parse(fn.Hash); err == nil {
+			hashes = append(hashes, hash)
+			positions = append(positions, i)
+		}
+	}
+
+	threshold := d.functionThreshold()
+	d.batchDistances(ctx, hashes, index.hashes, func(start int, rows [][]int) {
+		for k, distances := range rows {
+			fn := target.Functions[positions[k]]
+			m := &matches[positions[k]]
+			if d.opts.Distances != nil {
+				d.opts.Distances.add(target.Path, fn, index.functions[start:], distances)
+			}
+			for j, distance := range distances {
+				if distance < 0 || distance > threshold {
+					continue
+				}
+				m.add(fn, &index.functions[start+j], distance)
+			}
+		}
+	})
+
+	return matches
+}
+
+// add records a known function within the threshold of the target function
+func (m *functionMatch) add(target parser.Function, known *knownFunction, distance int) {
+	if m.components == nil {
+		m.target = target
+		m.components = make(map[string]struct{})
+		m.files = make(map[string]functionHit)
+	}
+	m.components[known.component] = struct{}{}
+	if hit, ok := m.files[known.file]; !ok || distance < hit.distance {
+		m.files[known.file] = functionHit{
+			component: known.component,
+			name:      known.name,
+			scope:     known.scope,
+			signature: known.signature,
+			startLine: known.startLine,
+			endLine:   known.endLine,
+			distance:  distance,
+		}
+	}
+}
+
+// matchedFunctions returns the accumulated function matches, omitting
+// functions without any match and those too common to identify a component
+func (d *Detector) matchedFunctions(matches []functionMatch) []functionMatch {
+	var matched []functionMatch
+	for _, m := range matches {
+		if len(m.components) > 0 && !d.common(len(m.components)) {
+			matched = append(matched, m)
+		}
+	}
+	return matched
+}
+
+// common reports whether a function found in n components is too common to
+// identify a component, see DetectorOptions.MaxFunctionComponents
+func (d *Detector) common(n int) bool {
+	return d.opts.MaxFunctionComponents > 0 && n > d.opts.MaxFunctionComponents
+}
+
+// functionThreshold returns the maximum TLSH distance for two functions to match
+func (d *Detector) functionThreshold() int {
+	if d.opts.FunctionThreshold <= 0 {
+		return defaultFunctionThreshold
+	}
+	return d.opts.FunctionThreshold
+}
+
+// scoreComponents attributes the matched functions of a target file to known
+// components. Each matched function is weighted by the inverse of how many
+// components contain it, so rare, component-specific functions dominate the
+// score while generic helpers shared by many components contribute little.
+// Scores are normalized by the total weight of all matched functions of the
+// target. n is the number of components in the index.
+func scoreComponents(matches []functionMatch, n int) []ComponentMatch {
+	var (
+		scores      = make(map[string]float64)
+		matched     = make(map[string]int)
+		totalWeight float64
+	)
+
+	for _, m := range matches {
+		weight := inverseComponentFrequency(n, len(m.components))
+		for component := range m.components {
+			scores[component] += weight
+			matched[component]++
+		}
+		totalWeight += weight
+	}
+
+	if totalWeight == 0 {
+		return nil
+	}
+
+	results := make([]ComponentMatch, 0, len(scores))
+	for component, score := range scores {
+		results = append(results, ComponentMatch{
+			Component:        component,
+			MatchedFunctions: matched[component],
+			Score:            score / totalWeight,
+		})
+	}
+
+	// Sort components by score (descending)
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Component < results[j].Component
+	})
+
+	return results
+}
+
+// withRepositories sets the package URLs and repository metadata of
+// component matches
+func (d *Detector) withRepositories(matches []ComponentMatch) []ComponentMatch {
+	for i := range matches {
+		matches[i].PURL = d.componentPURL(matches[i].Component)
+		matches[i].Metadata = d.metadata[matches[i].Component]
+	}
+	return matches
+}
+
+// inverseComponentFrequency returns the IDF weight of a function found in
+// df out of n components
+func inverseComponentFrequency(n, df int) float64 {
+	return math.Log(float64(n+1) / float64(df))
+}
@@ -0,0 +1,128 @@
+package detector
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+)
+
+func hashOf(t *testing.T, s string) string {
+	t.Helper()
+	hash, err := tlsh.New([]byte(s))
+	if err != nil {
+		t.Fatalf("tlsh.New() error = %v", err)
+	}
+	return hash.String()
+}
+
+func TestScoreComponents(t *testing.T) {
+	var (
+		helper = hashOf(t, "static int helper_max(int a, int b) { return a > b ? a : b; } /* common */")
+		rareA  = hashOf(t, "int component_a_specific(struct ctx *c) { return c->flags & CTX_FLAG_READY; }")
+		rareB  = hashOf(t, "void component_b_specific(char *buf, size_t len) { memset(buf, 0x5a, len); }")
+	)
+
+	knownDir := "/known"
+	d := New(DetectorOptions{KnownFilesDir: knownDir})
+
+	knownFiles := []*analyzer.FileInfo{
+		{Path: filepath.Join(knownDir, "a", "a.c"), Functions: []parser.Function{{Hash: helper}, {Hash: rareA}}},
+		{Path: filepath.Join(knownDir, "b", "b.c"), Functions: []parser.Function{{Hash: helper}, {Hash: rareB}}},
+		{Path: filepath.Join(knownDir, "c", "c.c"), Functions: []parser.Function{{Hash: helper}}},
+	}
+	index := d.buildComponentIndex(knownFiles)
+	if index.components != 3 {
+		t.Fatalf("index.components = %v, want 3", index.components)
+	}
+
+	target := &analyzer.FileInfo{
+		Path:      "target.c",
+		Functions: []parser.Function{{Hash: helper}, {Hash: rareA}},
+	}
+
+	results := scoreComponents(d.matchFunctions(context.Background(), target, index), index.components)
+	if len(results) != 3 {
+		t.Fatalf("scoreComponents() returned %d components, want 3", len(results))
+	}
+	if results[0].Component != "a" {
+		t.Errorf("top component = %v, want a", results[0].Component)
+	}
+	if results[0].MatchedFunctions != 2 {
+		t.Errorf("matched functions of a = %v, want 2", results[0].MatchedFunctions)
+	}
+
+	// The shared helper alone must contribute far less than the rare function
+	for _, r := range results[1:] {
+		if r.Score >= results[0].Score/2 {
+			t.Errorf("component %v score = %v, want well below %v", r.Component, r.Score, results[0].Score)
+		}
+	}
+}
+
+func TestMaxFunctionComponents(t *testing.T) {
+	var (
+		helper = hashOf(t, "static int helper_max(int a, int b) { return a > b ? a : b; } /* common */")
+		rareA  = hashOf(t, "int component_a_specific(struct ctx *c) { return c->flags & CTX_FLAG_READY; }")
+	)
+
+	knownDir := "/known"
+	knownFiles := []*analyzer.FileInfo{
+		{Path: filepath.Join(knownDir, "a", "a.c"), Functions: []parser.Function{{Hash: helper}, {Hash: rareA}}},
+		{Path: filepath.Join(knownDir, "b", "b.c"), Functions: []parser.Function{{Hash: helper}}},
+	}
+	target := &analyzer.FileInfo{
+		Path:      "target.c",
+		Functions: []parser.Function{{Hash: helper}, {Hash: rareA}},
+	}
+
+	// Functions matching more components than the limit are ignored
+	d := New(DetectorOptions{KnownFilesDir: knownDir, MaxFunctionComponents: 1})
+	index := d.buildComponentIndex(knownFiles)
+	results := scoreComponents(d.matchFunctions(context.Background(), target, index), index.components)
+	if len(results) != 1 || results[0].Component != "a" || results[0].MatchedFunctions != 1 {
+		t.Errorf("scoreComponents() = %+v, want only a with 1 function", results)
+	}
+
+	// Precomputed frequencies drop common functions from the index
+	d.frequency = map[string]int{helper: 2}
+	if index := d.buildComponentIndex(knownFiles); len(index.functions) != 1 {
+		t.Errorf("index has %d functions, want 1", len(index.functions))
+	}
+}
+
+func TestComponentOf(t *testing.T) {
+	d := New(DetectorOptions{KnownFilesDir: "/known"})
+
+	tests := map[string]string{
+		"/known/openssl/crypto/aes.c": "openssl",
+		"/known/top-level.c":          "",
+		"/elsewhere/zlib/inflate.c":   "",
+	}
+	for path, want := range tests {
+		if got := d.componentOf(path); got != want {
+			t.Errorf("componentOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestLoadRepositoriesFromManifest(t *testing.T) {
+	dir := t.TempDir()
+	m := manifest.New()
+	m.Repositories = []manifest.Repository{{Name: "zlib", PURL: "pkg:github/madler/zlib@abc"}}
+	if _, err := manifest.Write(dir, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// The repositories of an indexed corpus come from its manifest, not
+	// from the known files directory
+	d := New(DetectorOptions{KnownFilesDir: t.TempDir(), SignatureIndex: filepath.Join(dir, "signatures.idx")})
+	d.loadRepositories(context.Background())
+	if got := d.componentPURL("zlib"); got != "pkg:github/madler/zlib@abc" {
+		t.Errorf("componentPURL(zlib) = %q, want the purl of the manifest", got)
+	}
+}
@@ -0,0 +1,45 @@
+package detector
+
+import "math"
+
+// Weights of the confidence score, a logistic function of the signals of a
+// match. They are picked by hand, not fitted to labelled matches, so that
+// an identical digest alone scores about 0.95, a file at the distance
+// threshold without shared functions about 0.02, and a file at the
+// threshold sharing several long functions unique to its component about
+// 0.6.
+const (
+	confidenceBias         = -4.0
+	confidenceDistance     = 7.0
+	confidenceCoMatching   = 2.0
+	confidenceLength       = 1.5
+	confidenceRarity       = 1.5
+	confidenceLengthScale  = 20.0 // lines of shared functions
+	confidenceCoMatchScale = 3.0  // co-matching functions
+)
+
+// confidence returns a heuristic score between 0 and 1 of how likely a
+// match is a genuine reuse of the known file, combining its relative TLSH
+// distance, the length and corpus frequency of the shared functions and the
+// number of target functions found in the same component. Each signal is
+// scaled to [0, 1], so the score is comparable across languages and
+// thresholds, but it is not a calibrated probability.
+func confidence(e *Explanation) float64 {
+	closeness := 1.0
+	if e.MaxDistance > 0 {
+		closeness = math.Max(0, 1-float64(e.Distance)/float64(e.MaxDistance))
+	}
+	z := confidenceBias + confidenceDistance*closeness +
+		confidenceCoMatching*saturate(float64(e.ComponentFunctions), confidenceCoMatchScale)
+	if e.SharedFunctions > 0 {
+		z += confidenceLength * saturate(float64(e.MatchedLines), confidenceLengthScale)
+		// Functions found in a single component are the strongest evidence
+		z += confidenceRarity / math.Max(e.MeanFrequency, 1)
+	}
+	return math.Round(1/(1+math.Exp(-z))*1000) / 1000
+}
+
+// saturate maps a non-negative count to [0, 1), reaching 0.63 at scale
+func saturate(x, scale float64) float64 {
+	return 1 - math.Exp(-x/scale)
+}
@@ -0,0 +1,24 @@
+package detector
+
+import "testing"
+
+func TestConfidence(t *testing.T) {
+	identical := confidence(&Explanation{Distance: 0, MaxDistance: 20})
+	threshold := confidence(&Explanation{Distance: 20, MaxDistance: 20})
+	corroborated := confidence(&Explanation{
+		Distance: 20, MaxDistance: 20,
+		SharedFunctions: 5, RareFunctions: 5, MatchedLines: 75, MeanFrequency: 1, ComponentFunctions: 5,
+	})
+	common := confidence(&Explanation{
+		Distance: 20, MaxDistance: 20,
+		SharedFunctions: 5, MatchedLines: 75, MeanFrequency: 8, ComponentFunctions: 5,
+	})
+
+	if identical < 0.9 || threshold > 0.05 {
+		t.Errorf("confidence of identical = %v, at threshold = %v, want above 0.9 and below 0.05", identical, threshold)
+	}
+	if !(threshold < common && common < corroborated) {
+		t.Errorf("confidence at threshold = %v, with common functions = %v, with rare functions = %v, want increasing",
+			threshold, common, corroborated)
+	}
+}
@@ -0,0 +1,83 @@
+package detector
+
+import (
+	"context"
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/sigindex"
+)
+
+// Corpus holds analyzed known files together with the function index built
+// from them, so that repeated detections do not rebuild the index
+type Corpus struct {
+	files      []*analyzer.FileInfo
+	digests    map[string][]*analyzer.FileInfo
+	index      *componentIndex
+	components []CorpusComponent
+	// mapped, if set, holds the known files instead of files and index
+	mapped *sigindex.Index
+}
+
+// CorpusComponent summarizes a known component of a corpus
+type CorpusComponent struct {
+	Name      string `json:"name"`
+	PURL      string `json:"purl,omitempty"`
+	Files     int    `json:"files"`
+	Functions int    `json:"functions"`
+}
+
+// NewCorpus prepares known files for detection. It also collects the
+// repositories of the known files directory unless they are already known
+// from a corpus manifest.
+func (d *Detector) NewCorpus(ctx context.Context, knownFiles []*analyzer.FileInfo) *Corpus {
+	c := &Corpus{
+		files:   knownFiles,
+		digests: digestIndex(knownFiles),
+		index:   d.buildComponentIndex(knownFiles),
+	}
+	d.loadRepositories(ctx)
+
+	summaries := make(map[string]*CorpusComponent)
+	for _, file := range knownFiles {
+		name := d.knownComponent(file)
+		if name == "" {
+			continue
+		}
+		summary, ok := summaries[name]
+		if !ok {
+			summary = &CorpusComponent{Name: name, PURL: d.componentPURL(name)}
+			summaries[name] = summary
+		}
+		summary.Files++
+		summary.Functions += len(file.Functions)
+	}
+
+	c.components = make([]CorpusComponent, 0, len(summaries))
+	for _, summary := range summaries {
+		c.components = append(c.components, *summary)
+	}
+	sort.Slice(c.components, func(i, j int) bool {
+		return c.components[i].Name < c.components[j].Name
+	})
+
+	return c
+}
+
+// Files returns the number of known files in the corpus
+func (c *Corpus) Files() int {
+	if c.mapped != nil {
+		return c.mapped.Files()
+	}
+	return len(c.files)
+}
+
+// KnownFiles returns the known files of the corpus
+func (c *Corpus) KnownFiles() []*analyzer.FileInfo {
+	return c.files
+}
+
+// Components returns the known components of the corpus sorted by name
+func (c *Corpus) Components() []CorpusComponent {
+	return c.components
+}
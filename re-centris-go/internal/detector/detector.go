@@ -2,121 +2,364 @@ package detector
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"math"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/artifact"
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+	"github.com/re-centris/re-centris-go/internal/common/cache"
 	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/common/progress"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
 // DetectionResult represents the result of a code similarity detection
 type DetectionResult struct {
-	TargetFile  string           `json:"target_file"`
-	Matches     []Match          `json:"matches"`
-	TotalFiles  int             `json:"total_files"`
-	MatchCount  int             `json:"match_count"`
+	// SchemaVersion is the version of the result schema, see ResultSchema
+	SchemaVersion  string           `json:"schema_version"`
+	TargetFile     string           `json:"target_file"`
+	Digest         string           `json:"digest,omitempty"`
+	Hash           string           `json:"hash,omitempty"`
+	CorpusManifest string           `json:"corpus_manifest,omitempty"`
+	CorpusSnapshot string           `json:"corpus_snapshot,omitempty"`
+	Matches        []Match          `json:"matches"`
+	TotalFiles     int              `json:"total_files"`
+	MatchCount     int              `json:"match_count"`
+	Components     []ComponentMatch `json:"components,omitempty"`
+	// OmittedMatches is the number of matches dropped by the output limits
+	OmittedMatches int `json:"omitted_matches,omitempty"`
+	// Duplicates lists the other target files with the same content
+	Duplicates []string `json:"duplicates,omitempty"`
+	// ModifiedFunctions lists functions likely patched locally, see
+	// DetectorOptions.ModifiedMaxDistance
+	ModifiedFunctions []ModifiedFunction `json:"modified_functions,omitempty"`
+	// Vulnerabilities lists known vulnerabilities of the matched components
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+	// License holds the licenses of the target and its matched components
+	License *LicenseInfo `json:"license,omitempty"`
+}
+
+// Vulnerability is a known vulnerability affecting a matched component
+type Vulnerability struct {
+	ID        string   `json:"id"`
+	Component string   `json:"component"`
+	Summary   string   `json:"summary,omitempty"`
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+// Provenance tells when and by whom the matched code of a target file was
+// introduced, from the git history of the target
+type Provenance struct {
+	// Commit introduced most of the matched lines
+	Commit  string    `json:"commit"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Summary string    `json:"summary"`
+	// Lines counts the matched lines introduced by the commit out of
+	// TotalLines committed matched lines
+	Lines      int `json:"lines"`
+	TotalLines int `json:"total_lines"`
 }
 
 // Match represents a single match in the detection result
 type Match struct {
-	File       string  `json:"file"`
+	File string `json:"file"`
+	// Component is the component the known file belongs to, if any
+	Component  string  `json:"component,omitempty"`
 	Similarity float64 `json:"similarity"`
 	Distance   int     `json:"distance"`
+	// Confidence is a heuristic score between 0 and 1 of how likely the
+	// match is genuine reuse, combining the distance with the evidence of
+	// shared functions
+	Confidence float64 `json:"confidence,omitempty"`
+	// Hash is the TLSH digest of the known file
+	Hash string `json:"hash,omitempty"`
+	PURL string `json:"purl,omitempty"`
+	// CloneType is CloneExact, CloneRenamed or CloneNearMiss
+	CloneType string `json:"clone_type,omitempty"`
+	// Evidence lists the matched functions with their line ranges
+	Evidence    []Evidence   `json:"evidence,omitempty"`
+	Explanation *Explanation `json:"explanation,omitempty"`
+	// Internal marks a match of an owned component
+	Internal bool `json:"internal,omitempty"`
+	// FalsePositive marks a match labelled as a false positive, see Labels
+	FalsePositive bool `json:"false_positive,omitempty"`
+	// Provenance is set for targets in git repositories if requested
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// ComponentIn returns the component of the known file of a match. Results
+// without a recorded component derive it from the path of the known file
+// below knownFilesDir.
+func (m Match) ComponentIn(knownFilesDir string) string {
+	if m.Component != "" {
+		return m.Component
+	}
+	return artifact.ComponentOf(knownFilesDir, m.File)
 }
 
 // DetectorOptions contains options for the detector
 type DetectorOptions struct {
-	MaxWorkers      int
+	MaxWorkers          int
 	SimilarityThreshold float64
-	Languages       map[string][]string
-	KnownFilesDir   string
+	Languages           map[string][]string
+	KnownFilesDir       string
+	// FunctionThreshold is the maximum TLSH distance for two functions to match
+	FunctionThreshold int
+	// SignatureDir, if set, loads known files from sharded preprocessor output
+	SignatureDir string
+	// BatchSize, if set, streams known files in batches of this size
+	// instead of loading them all, keeping at most MaxMatchesPerFile (or
+	// 100) matches per target file in memory
+	BatchSize int
+	// IndexPath, if set, loads known files from a persistent index that is
+	// updated with the changes of KnownFilesDir, see package index
+	IndexPath string
+	// SignatureIndex, if set, searches the known files of the signature
+	// index at this path, mapped into memory instead of loaded, see package
+	// sigindex. The corpus metadata is read from SignatureDir if set, and
+	// BatchSize does not apply.
+	SignatureIndex string
+	// LanguageThresholds overrides SimilarityThreshold for specific languages
+	LanguageThresholds map[string]float64
+	// CorpusManifest is the hash of the corpus manifest stamped on results.
+	// It is read from SignatureDir when empty.
+	CorpusManifest string
+	// CorpusSnapshot is the ID of the snapshot SignatureDir is checked out
+	// from, if any, stamped on results, see package snapshot
+	CorpusSnapshot string
+	// Progress, if set, reports the progress of analysis and detection
+	Progress *progress.Reporter
+	// Pool, if set, is shared with other stages and limits the known files
+	// analyzed and batches compared at once across them
+	Pool *workpool.Pool
+	// Cache, if set, persists the analysis of file contents across runs
+	Cache *cache.DiskCache
+	// Symlinks, Include, Exclude, GitIgnore and NoSniff select the analyzed
+	// files, see analyzer.AnalyzerOptions
+	Symlinks  string
+	Include   []string
+	Exclude   []string
+	GitIgnore bool
+	NoSniff   bool
+	// SniffLanguages detects the language of files without a supported
+	// extension from their content
+	SniffLanguages bool
+	// Normalize selects the normalization applied before hashing by language
+	Normalize map[string]normalize.Options
+	// SignatureMode is the signature mode of the analyzer, see analyzer.SignatureBytes
+	SignatureMode string
+	// Encoding is the source encoding of the analyzer, see analyzer.ParseEncoding
+	Encoding string
+	// Conditionals is the conditional compilation mode of the C/C++ parser,
+	// see cpp.ParseConditionals
+	Conditionals string
+	// MaxFileSize and ChunkedSize limit the memory taken by large files,
+	// see analyzer.AnalyzerOptions
+	MaxFileSize int64
+	ChunkedSize int64
+	// FileTimeout and SlowFiles bound and report the time taken by a
+	// file, see analyzer.AnalyzerOptions
+	FileTimeout time.Duration
+	SlowFiles   *analyzer.SlowFileReport
+	// CloneTypes, if set, keeps only matches of these clone types
+	CloneTypes []string
+	// DiffSnippets adds a unified diff to the evidence of each match
+	DiffSnippets bool
+	// MinSimilarity, MaxMatchesPerFile and TopK, if set, limit the matches
+	// reported: matches below MinSimilarity are dropped, at most
+	// MaxMatchesPerFile are kept per target file and at most TopK overall
+	MinSimilarity     float64
+	MaxMatchesPerFile int
+	TopK              int
+	// MaxFunctionComponents, if set, ignores functions found in more
+	// components than this when identifying components
+	MaxFunctionComponents int
+	// ModifiedMinDistance and ModifiedMaxDistance, if ModifiedMaxDistance
+	// is set, report target functions whose closest function of a
+	// component is within this TLSH distance band as modified. Distances
+	// above FunctionThreshold never match.
+	ModifiedMinDistance int
+	ModifiedMaxDistance int
+	// OwnedComponents lists our own code in the corpus, as repository URLs
+	// or path prefixes of known files. Depending on OwnedMode, OwnedExclude
+	// by default, their matches are dropped or labelled as internal.
+	OwnedComponents []string
+	OwnedMode       string
+	// FalsePositives, if set, holds the matches labelled as false
+	// positives. Depending on FalsePositiveMode, FalsePositiveSuppress by
+	// default, they are dropped or flagged and ranked last.
+	FalsePositives    *Labels
+	FalsePositiveMode string
+	// TargetRoot is the directory baseline entries name target files
+	// relative to, the working directory if empty
+	TargetRoot string
+	// ExactFirst looks up verbatim copies of target files by their SHA-256
+	// first and reports only those for files that have any, skipping the
+	// TLSH comparison against the whole corpus, or the batch when streaming
+	ExactFirst bool
+	// Distances, if set, exports the distances between target and known
+	// functions computed during detection
+	Distances *DistanceWriter
+	// DistanceBackend, if set, computes the TLSH distances between target
+	// and known files and functions instead of GoDistances, comparing at
+	// most DistanceBatch pairs (or 1<<20) per call. The signature index
+	// compares hashes where they are mapped and does not use it.
+	DistanceBackend DistanceBackend
+	DistanceBatch   int
+	// Output, if set, receives each result as soon as it is complete
+	// instead of the results being returned, so that they are not all held
+	// in memory. Steps across results, Duplicates and TopK, are skipped.
+	Output ResultWriter
 }
 
 // Detector handles code similarity detection
 type Detector struct {
 	opts     DetectorOptions
 	analyzer *analyzer.Analyzer
+	hooks    hooks
+	purls    map[string]string // package URLs of known components
+	// frequency is the number of components containing each function
+	// signature shared by several, as counted by the preprocessor
+	frequency map[string]int
+	// versions holds the version signatures of known components
+	versions map[string]*versions.Signatures
+	// owned holds the components owned by repository URL
+	owned map[string]bool
+	// metadata holds the host metadata of known repositories
+	metadata map[string]*manifest.Metadata
 }
 
 // New creates a new Detector
 func New(opts DetectorOptions) *Detector {
 	return &Detector{
-		opts: opts,
-		analyzer: analyzer.New(analyzer.AnalyzerOptions{
-			MaxWorkers: opts.MaxWorkers,
-			Languages:  opts.Languages,
-		}),
+		opts:     opts,
+		analyzer: analyzer.New(opts.AnalyzerOptions()),
+	}
+}
+
+// CorpusManifest returns the hash of the corpus manifest stamped on
+// results, which is read with the known files unless set in the options
+func (d *Detector) CorpusManifest() string {
+	return d.opts.CorpusManifest
+}
+
+// WithThreshold returns a detector sharing the loaded corpus metadata of d
+// that uses threshold for all languages. Hooks are not shared.
+func (d *Detector) WithThreshold(threshold float64) *Detector {
+	opts := d.opts
+	opts.SimilarityThreshold = threshold
+	opts.LanguageThresholds = nil
+	return &Detector{
+		opts:      opts,
+		analyzer:  d.analyzer,
+		purls:     d.purls,
+		frequency: d.frequency,
+		versions:  d.versions,
+		owned:     d.owned,
+		metadata:  d.metadata,
+	}
+}
+
+// AnalyzerOptions returns the options of the analyzer hashing target and
+// known files. Other analyzers feeding the detector must use them, since
+// files are only comparable when hashed alike.
+func (o DetectorOptions) AnalyzerOptions() analyzer.AnalyzerOptions {
+	return analyzer.AnalyzerOptions{
+		MaxWorkers:     o.MaxWorkers,
+		Pool:           o.Pool,
+		Cache:          o.Cache,
+		Languages:      o.Languages,
+		Progress:       o.Progress,
+		Symlinks:       o.Symlinks,
+		Include:        o.Include,
+		Exclude:        o.Exclude,
+		GitIgnore:      o.GitIgnore,
+		NoSniff:        o.NoSniff,
+		SniffLanguages: o.SniffLanguages,
+		Normalize:      o.Normalize,
+		SignatureMode:  o.SignatureMode,
+		Encoding:       o.Encoding,
+		Conditionals:   o.Conditionals,
+		MaxFileSize:    o.MaxFileSize,
+		ChunkedSize:    o.ChunkedSize,
+		FileTimeout:    o.FileTimeout,
+		SlowFiles:      o.SlowFiles,
 	}
 }
 
 // DetectSimilarity detects code similarity between target files and known files
 func (d *Detector) DetectSimilarity(ctx context.Context, targetFiles []string) ([]*DetectionResult, error) {
+	if d.opts.SignatureIndex != "" {
+		return d.detectMapped(ctx, targetFiles)
+	}
+	if d.opts.BatchSize > 0 {
+		return d.detectStreaming(ctx, targetFiles)
+	}
+
 	// Load known files
-	knownFiles, err := d.loadKnownFiles(ctx)
+	knownFiles, err := d.LoadKnownFiles(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load known files: %v", err)
 	}
 
+	return d.DetectWithKnownFiles(ctx, targetFiles, knownFiles)
+}
+
+// DetectWithKnownFiles detects code similarity between target files and
+// already analyzed known files
+func (d *Detector) DetectWithKnownFiles(ctx context.Context, targetFiles []string, knownFiles []*analyzer.FileInfo) ([]*DetectionResult, error) {
+	return d.DetectWithCorpus(ctx, targetFiles, d.NewCorpus(ctx, knownFiles))
+}
+
+// DetectWithCorpus detects code similarity between target files and a
+// prepared corpus. It is safe to call concurrently with the same corpus.
+func (d *Detector) DetectWithCorpus(ctx context.Context, targetFiles []string, corpus *Corpus) ([]*DetectionResult, error) {
 	// Process target files in parallel
 	var (
-		results []*DetectionResult
+		results    []*DetectionResult
 		resultsMux sync.Mutex
 	)
 
 	g, ctx := errgroup.WithContext(ctx)
 	g.SetLimit(d.opts.MaxWorkers)
 
+	stage := d.opts.Progress.Stage("detect", len(targetFiles))
+
 	for _, targetFile := range targetFiles {
 		targetFile := targetFile // Create new variable for goroutine
 		g.Go(func() error {
-			// Analyze target file
-			fileInfo, err := d.analyzer.AnalyzeFile(ctx, targetFile)
+			defer stage.Add(1)
+
+			fileInfos, err := d.analyzeTarget(ctx, targetFile)
 			if err != nil {
-				logger.Error("Failed to analyze target file",
-					zap.String("file", targetFile),
-					zap.Error(err))
 				return err
 			}
 
-			// Find similar files
-			similar := d.analyzer.FindSimilarFiles(fileInfo, knownFiles, 
-				int(100 * (1 - d.opts.SimilarityThreshold)))
-
-			// Create matches
-			matches := make([]Match, len(similar))
-			for i, s := range similar {
-				distance := fileInfo.Hash.Distance(s.Hash)
-				similarity := 1.0 - float64(distance)/100.0
-				matches[i] = Match{
-					File:       s.Path,
-					Similarity: similarity,
-					Distance:   distance,
-				}
-			}
+			for _, fileInfo := range fileInfos {
+				result := d.detectFile(ctx, fileInfo, corpus)
 
-			// Sort matches by similarity (descending)
-			sort.Slice(matches, func(i, j int) bool {
-				return matches[i].Similarity > matches[j].Similarity
-			})
-
-			// Create result
-			result := &DetectionResult{
-				TargetFile:  targetFile,
-				Matches:     matches,
-				TotalFiles:  len(knownFiles),
-				MatchCount:  len(matches),
+				// Add to results
+				resultsMux.Lock()
+				if d.opts.Output != nil {
+					err = d.opts.Output.Write(result)
+				} else {
+					results = append(results, result)
+				}
+				resultsMux.Unlock()
+				if err != nil {
+					return err
+				}
 			}
 
-			// Add to results
-			resultsMux.Lock()
-			results = append(results, result)
-			resultsMux.Unlock()
-
 			return nil
 		})
 	}
@@ -124,32 +367,203 @@ func (d *Detector) DetectSimilarity(ctx context.Context, targetFiles []string) (
 	if err := g.Wait(); err != nil {
 		return nil, fmt.Errorf("error while detecting similarities: %v", err)
 	}
+	stage.Done()
 
+	SortResults(results)
+	markDuplicates(results)
+	d.limitTopK(results)
 	return results, nil
 }
 
-// loadKnownFiles loads all known files from the specified directory
-func (d *Detector) loadKnownFiles(ctx context.Context) ([]*analyzer.FileInfo, error) {
-	return d.analyzer.AnalyzeDirectory(ctx, d.opts.KnownFilesDir)
+// analyzeTarget analyzes a target file. Archives are analyzed in memory,
+// each entry becoming a target. Skipped files yield no targets.
+func (d *Detector) analyzeTarget(ctx context.Context, targetFile string) ([]*analyzer.FileInfo, error) {
+	if analyzer.IsArchive(targetFile) {
+		infos, err := d.analyzer.AnalyzeArchive(ctx, targetFile)
+		if err != nil {
+			logger.Error("Failed to analyze target archive",
+				zap.String("file", targetFile),
+				zap.Error(err))
+			return nil, err
+		}
+		return infos, nil
+	}
+
+	fileInfo, err := d.analyzer.AnalyzeFile(ctx, targetFile)
+	if err != nil {
+		if analyzer.IsSkipped(err) {
+			// Skip files that are too small or not worth hashing
+			return nil, nil
+		}
+		logger.Error("Failed to analyze target file",
+			zap.String("file", targetFile),
+			zap.Error(err))
+		return nil, err
+	}
+	return []*analyzer.FileInfo{fileInfo}, nil
 }
 
-// SaveResults saves detection results to a JSON file
-func (d *Detector) SaveResults(results []*DetectionResult, outputPath string) error {
-	// Create parent directories if they don't exist
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directories: %v", err)
+// SortResults sorts results by target file so that the output of parallel
+// detection is reproducible
+func SortResults(results []*DetectionResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].TargetFile < results[j].TargetFile
+	})
+}
+
+// sortMatches sorts matches by similarity (descending), breaking ties by
+// known file
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].FalsePositive != matches[j].FalsePositive {
+			return matches[j].FalsePositive
+		}
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].File < matches[j].File
+	})
+}
+
+// candidate is a known file within the distance threshold of a target
+type candidate struct {
+	file      string
+	component string
+	hash      string
+	distance  int
+	cloneType string
+}
+
+// detectFile matches an analyzed target file against the corpus
+func (d *Detector) detectFile(ctx context.Context, fileInfo *analyzer.FileInfo, corpus *Corpus) *DetectionResult {
+	if corpus.mapped != nil {
+		return d.detectMappedFile(fileInfo, corpus.mapped)
 	}
+	candidates := d.candidates(ctx, fileInfo, corpus.files, corpus.digests)
+	functions := d.matchFunctions(ctx, fileInfo, corpus.index)
+	return d.newResult(fileInfo, candidates, functions, len(corpus.files), corpus.index.components)
+}
 
-	// Marshal results to JSON
-	data, err := json.MarshalIndent(results, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %v", err)
+// maxDistance returns the maximum TLSH distance of a match for a language
+func (d *Detector) maxDistance(language string) int {
+	return int(math.Round(100 * (1 - d.thresholdFor(language))))
+}
+
+// candidates returns the known files similar to a target file whose clone
+// type is reported
+func (d *Detector) candidates(ctx context.Context, fileInfo *analyzer.FileInfo, knownFiles []*analyzer.FileInfo, digests map[string][]*analyzer.FileInfo) []candidate {
+	similar := d.exactCopies(fileInfo, digests)
+	if len(similar) == 0 {
+		similar = d.similarFiles(ctx, fileInfo, knownFiles, d.maxDistance(fileInfo.Language))
 	}
+	return d.classifyCandidates(fileInfo, similar)
+}
 
-	// Write to file
-	if err := os.WriteFile(outputPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write results: %v", err)
+// classifyCandidates returns the candidates of the known files similar to
+// a target file whose clone type is reported
+func (d *Detector) classifyCandidates(fileInfo *analyzer.FileInfo, similar []*analyzer.FileInfo) []candidate {
+	candidates := make([]candidate, 0, len(similar))
+	for _, s := range similar {
+		distance := fileInfo.Hash.Distance(s.Hash)
+		cloneType := classifyClone(fileInfo, s, distance)
+		if !d.keepClone(cloneType) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			file:      s.Path,
+			component: d.knownComponent(s),
+			hash:      s.Hash.String(),
+			distance:  distance,
+			cloneType: cloneType,
+		})
+	}
+	return candidates
+}
+
+// newResult builds the detection result of a target file from its
+// candidates and function matches. totalFiles and components count the
+// known files and the components with function signatures.
+func (d *Detector) newResult(fileInfo *analyzer.FileInfo, candidates []candidate, functions []functionMatch, totalFiles, components int) *DetectionResult {
+	maxDistance := d.maxDistance(fileInfo.Language)
+	if len(d.opts.OwnedComponents) > 0 && d.opts.OwnedMode != OwnedLabel {
+		candidates, functions = d.excludeOwned(candidates, functions)
+	}
+	targetHash := ""
+	if fileInfo.Hash != nil {
+		targetHash = fileInfo.Hash.String()
+	}
+	downrank := d.opts.FalsePositiveMode == FalsePositiveDownrank
+	if d.opts.FalsePositives != nil && !downrank {
+		candidates = d.excludeFalsePositives(targetHash, candidates)
+	}
+
+	// Create matches
+	matches := make([]Match, 0, len(candidates))
+	for _, c := range candidates {
+		explanation := d.explain(c.distance, maxDistance, c.file, c.component, functions)
+		matches = append(matches, Match{
+			File:        c.file,
+			Component:   c.component,
+			Similarity:  1.0 - float64(c.distance)/100.0,
+			Distance:    c.distance,
+			Hash:        c.hash,
+			PURL:        d.componentPURL(c.component),
+			CloneType:   c.cloneType,
+			Evidence:    d.evidence(fileInfo.Path, c.file, functions),
+			Explanation: explanation,
+			Confidence:  confidence(explanation),
+		})
 	}
 
-	return nil
-} 
\ No newline at end of file
+	if downrank {
+		d.labelFalsePositives(targetHash, matches)
+	}
+	sortMatches(matches)
+
+	// Create result
+	result := &DetectionResult{
+		SchemaVersion:     SchemaVersion,
+		TargetFile:        fileInfo.Path,
+		Digest:            fileInfo.Digest,
+		Hash:              targetHash,
+		CorpusManifest:    d.opts.CorpusManifest,
+		CorpusSnapshot:    d.opts.CorpusSnapshot,
+		Matches:           matches,
+		TotalFiles:        totalFiles,
+		MatchCount:        len(matches),
+		Components:        d.withVersions(d.withRepositories(scoreComponents(functions, components)), functions),
+		ModifiedFunctions: d.modifiedFunctions(functions),
+	}
+	if len(d.opts.OwnedComponents) > 0 && d.opts.OwnedMode == OwnedLabel {
+		d.labelOwned(result)
+	}
+	d.limitMatches(result)
+
+	d.hooks.notify(fileInfo, result)
+	return result
+}
+
+// thresholdFor returns the similarity threshold for a language
+func (d *Detector) thresholdFor(language string) float64 {
+	if threshold, ok := d.opts.LanguageThresholds[language]; ok {
+		return threshold
+	}
+	return d.opts.SimilarityThreshold
+}
+
+// LoadKnownFiles loads all known files from the known files directory, the
+// signature directory or the known files index
+func (d *Detector) LoadKnownFiles(ctx context.Context) ([]*analyzer.FileInfo, error) {
+	if d.opts.SignatureDir != "" {
+		return d.loadSignatures()
+	}
+	if d.opts.IndexPath != "" {
+		return d.loadIndex(ctx)
+	}
+	return d.analyzer.AnalyzeDirectory(ctx, d.opts.KnownFilesDir)
+}
+
+// SaveResults saves detection results to a JSON file
+func (d *Detector) SaveResults(results []*DetectionResult, outputPath string) error {
+	return d.WriteResults(results, FormatJSON, outputPath)
+}
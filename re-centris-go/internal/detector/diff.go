@@ -0,0 +1,201 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Statuses of a component in a result diff
+const (
+	ComponentAdded          = "added"
+	ComponentRemoved        = "removed"
+	ComponentVersionChanged = "version_changed"
+)
+
+// ResultDiff lists the differences between two detection runs
+type ResultDiff struct {
+	AddedMatches     []MatchChange     `json:"added_matches"`
+	RemovedMatches   []MatchChange     `json:"removed_matches"`
+	ChangedMatches   []MatchChange     `json:"changed_matches"`
+	ComponentChanges []ComponentChange `json:"component_changes"`
+}
+
+// MatchChange is a match of a target file to a known file present in only
+// one run, or whose similarity or clone type changed between the runs
+type MatchChange struct {
+	TargetFile    string  `json:"target_file"`
+	File          string  `json:"file"`
+	Component     string  `json:"component,omitempty"`
+	OldSimilarity float64 `json:"old_similarity,omitempty"`
+	NewSimilarity float64 `json:"new_similarity,omitempty"`
+	OldCloneType  string  `json:"old_clone_type,omitempty"`
+	NewCloneType  string  `json:"new_clone_type,omitempty"`
+}
+
+// ComponentChange is a component identified in only one run, or whose
+// estimated version changed between the runs
+type ComponentChange struct {
+	Component  string `json:"component"`
+	Status     string `json:"status"`
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+}
+
+// runSummary holds the matches and component versions of a detection run
+type runSummary struct {
+	matches    map[matchKey]Match
+	components map[string]map[string]int // component to version ranges by file count
+}
+
+// matchKey identifies a match across runs
+type matchKey struct {
+	target string
+	file   string
+}
+
+// DiffResults compares the detection results of an old and a new run.
+// Matches are identified by target file and known file. The version of a
+// component is the range estimated for most of its files.
+func DiffResults(oldPath, newPath string) (*ResultDiff, error) {
+	old, err := summarizeRun(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := summarizeRun(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ResultDiff{
+		AddedMatches:     []MatchChange{},
+		RemovedMatches:   []MatchChange{},
+		ChangedMatches:   []MatchChange{},
+		ComponentChanges: []ComponentChange{},
+	}
+	for key, m := range cur.matches {
+		change := MatchChange{
+			TargetFile:    key.target,
+			File:          key.file,
+			Component:     m.Component,
+			NewSimilarity: m.Similarity,
+			NewCloneType:  m.CloneType,
+		}
+		prev, ok := old.matches[key]
+		if !ok {
+			diff.AddedMatches = append(diff.AddedMatches, change)
+			continue
+		}
+		if prev.Similarity != m.Similarity || prev.CloneType != m.CloneType {
+			change.OldSimilarity, change.OldCloneType = prev.Similarity, prev.CloneType
+			diff.ChangedMatches = append(diff.ChangedMatches, change)
+		}
+	}
+	for key, m := range old.matches {
+		if _, ok := cur.matches[key]; !ok {
+			diff.RemovedMatches = append(diff.RemovedMatches, MatchChange{
+				TargetFile:    key.target,
+				File:          key.file,
+				Component:     m.Component,
+				OldSimilarity: m.Similarity,
+				OldCloneType:  m.CloneType,
+			})
+		}
+	}
+
+	for component, versions := range cur.components {
+		newVersion := topVersion(versions)
+		prev, ok := old.components[component]
+		switch {
+		case !ok:
+			diff.ComponentChanges = append(diff.ComponentChanges, ComponentChange{
+				Component: component, Status: ComponentAdded, NewVersion: newVersion,
+			})
+		case topVersion(prev) != newVersion:
+			diff.ComponentChanges = append(diff.ComponentChanges, ComponentChange{
+				Component: component, Status: ComponentVersionChanged, OldVersion: topVersion(prev), NewVersion: newVersion,
+			})
+		}
+	}
+	for component, versions := range old.components {
+		if _, ok := cur.components[component]; !ok {
+			diff.ComponentChanges = append(diff.ComponentChanges, ComponentChange{
+				Component: component, Status: ComponentRemoved, OldVersion: topVersion(versions),
+			})
+		}
+	}
+
+	for _, changes := range [][]MatchChange{diff.AddedMatches, diff.RemovedMatches, diff.ChangedMatches} {
+		sortMatchChanges(changes)
+	}
+	sort.Slice(diff.ComponentChanges, func(i, j int) bool {
+		return diff.ComponentChanges[i].Component < diff.ComponentChanges[j].Component
+	})
+	return diff, nil
+}
+
+// summarizeRun reads the matches and component versions of a result file
+func summarizeRun(path string) (*runSummary, error) {
+	s := &runSummary{
+		matches:    make(map[matchKey]Match),
+		components: make(map[string]map[string]int),
+	}
+	addComponent := func(component, version string) {
+		if s.components[component] == nil {
+			s.components[component] = make(map[string]int)
+		}
+		if version != "" {
+			s.components[component][version]++
+		}
+	}
+
+	err := ReadResults(path, func(result *DetectionResult) error {
+		for _, m := range result.Matches {
+			s.matches[matchKey{target: result.TargetFile, file: m.File}] = m
+			if m.Component != "" {
+				addComponent(m.Component, "")
+			}
+		}
+		for _, c := range result.Components {
+			version := ""
+			if c.Version != nil {
+				version = c.Version.Range
+			}
+			addComponent(c.Component, version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// sortMatchChanges sorts match changes by target file and known file
+func sortMatchChanges(changes []MatchChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].TargetFile != changes[j].TargetFile {
+			return changes[i].TargetFile < changes[j].TargetFile
+		}
+		return changes[i].File < changes[j].File
+	})
+}
+
+// WriteDiff writes a result diff as JSON. The path "-" writes to standard
+// output.
+func WriteDiff(diff *ResultDiff, path string) error {
+	file, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(diff); err != nil {
+		abortFile(file)
+		return fmt.Errorf("failed to write diff: %v", err)
+	}
+	return closeOutput(file, writer)
+}
@@ -0,0 +1,56 @@
+package detector
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	d := New(DetectorOptions{})
+	dir := t.TempDir()
+	write := func(name string, results []*DetectionResult) string {
+		path := filepath.Join(dir, name)
+		if err := d.SaveResults(results, path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	zlib := func(version string) []ComponentMatch {
+		return []ComponentMatch{{Component: "zlib", Version: &ComponentVersion{Range: version}}}
+	}
+
+	oldPath := write("old.json", []*DetectionResult{
+		{TargetFile: "inflate.c", Matches: []Match{{File: "/known/zlib/inflate.c", Component: "zlib", Similarity: 0.9}}, Components: zlib("1.2.11")},
+		{TargetFile: "lz4.c", Matches: []Match{{File: "/known/lz4/lz4.c", Component: "lz4", Similarity: 1}}},
+	})
+	newPath := write("new.json", []*DetectionResult{
+		{TargetFile: "inflate.c", Matches: []Match{{File: "/known/zlib/inflate.c", Component: "zlib", Similarity: 1}}, Components: zlib("1.2.13")},
+		{TargetFile: "deflate.c", Matches: []Match{{File: "/known/zlib/deflate.c", Component: "zlib", Similarity: 0.95}}, Components: zlib("1.2.13")},
+	})
+
+	diff, err := DiffResults(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.AddedMatches) != 1 || diff.AddedMatches[0].TargetFile != "deflate.c" {
+		t.Errorf("AddedMatches = %+v, want deflate.c", diff.AddedMatches)
+	}
+	if len(diff.RemovedMatches) != 1 || diff.RemovedMatches[0].Component != "lz4" {
+		t.Errorf("RemovedMatches = %+v, want lz4.c", diff.RemovedMatches)
+	}
+	if len(diff.ChangedMatches) != 1 || diff.ChangedMatches[0].OldSimilarity != 0.9 || diff.ChangedMatches[0].NewSimilarity != 1 {
+		t.Errorf("ChangedMatches = %+v, want inflate.c from 0.9 to 1", diff.ChangedMatches)
+	}
+	want := []ComponentChange{
+		{Component: "lz4", Status: ComponentRemoved},
+		{Component: "zlib", Status: ComponentVersionChanged, OldVersion: "1.2.11", NewVersion: "1.2.13"},
+	}
+	if len(diff.ComponentChanges) != len(want) {
+		t.Fatalf("ComponentChanges = %+v, want %+v", diff.ComponentChanges, want)
+	}
+	for i := range want {
+		if diff.ComponentChanges[i] != want[i] {
+			t.Errorf("ComponentChanges[%d] = %+v, want %+v", i, diff.ComponentChanges[i], want[i])
+		}
+	}
+}
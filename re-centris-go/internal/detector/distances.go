@@ -0,0 +1,219 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+)
+
+// Formats of a distance export
+const (
+	// DistancesCSV writes one CSV row per pair of functions
+	DistancesCSV = "csv"
+	// DistancesBinary writes the compact binary edge list described at
+	// DistanceWriter
+	DistancesBinary = "binary"
+)
+
+// distanceMagic starts a binary distance export, followed by the format
+// version
+const distanceMagic = "RCDM\x01"
+
+// Record tags of the binary distance export
+const (
+	distanceTarget byte = 'T'
+	distanceKnown  byte = 'K'
+	distanceEdge   byte = 'E'
+)
+
+// distanceColumns are the columns of the CSV distance export
+var distanceColumns = []string{
+	"target_file", "target_function", "target_start_line", "target_end_line",
+	"component", "known_file", "known_function", "known_start_line", "known_end_line",
+	"distance",
+}
+
+// functionKey identifies a function across batches of known files
+type functionKey struct {
+	file      string
+	name      string
+	startLine int
+}
+
+// DistanceWriter exports the TLSH distances between target and known
+// functions computed during detection, for all pairs or only those within
+// a maximum distance. Set it as DetectorOptions.Distances; it is safe for
+// concurrent use and Close must be called after the detection.
+//
+// The binary format starts with "RCDM" and the version byte 1, followed by
+// records tagged with one byte. A 'T' (target) or 'K' (known) record
+// defines a function before its first edge: a uint32 id, then the file,
+// the name (and for known functions the component before them) as uint16
+// length-prefixed strings, and the uint32 start and end lines. An 'E'
+// record is an edge: the uint32 target and known ids and the uint16
+// distance. Integers are little-endian; ids count from 0 per kind.
+type DistanceWriter struct {
+	format      string
+	maxDistance int
+	file        *outputFile
+	writer      *bufio.Writer
+	csv         *csv.Writer
+	targets     map[functionKey]uint32
+	known       map[functionKey]uint32
+	edges       int
+	err         error
+	mutex       sync.Mutex
+}
+
+// NewDistanceWriter creates a distance export at path in the given format.
+// Pairs further apart than maxDistance are skipped unless it is 0 or less.
+func NewDistanceWriter(path, format string, maxDistance int) (*DistanceWriter, error) {
+	if format != DistancesCSV && format != DistancesBinary {
+		return nil, fmt.Errorf("unsupported distance format: %s", format)
+	}
+	file, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &DistanceWriter{
+		format:      format,
+		maxDistance: maxDistance,
+		file:        file,
+		writer:      bufio.NewWriter(file),
+		targets:     make(map[functionKey]uint32),
+		known:       make(map[functionKey]uint32),
+	}
+	if format == DistancesCSV {
+		w.csv = csv.NewWriter(w.writer)
+		w.err = w.csv.Write(distanceColumns)
+	} else {
+		_, w.err = w.writer.WriteString(distanceMagic)
+	}
+	if w.err != nil {
+		abortFile(file)
+		return nil, fmt.Errorf("failed to write distances: %v", w.err)
+	}
+	return w, nil
+}
+
+// add exports the distances of a target function to known functions, in
+// the same order. A failed write is reported by Close.
+func (w *DistanceWriter) add(targetFile string, fn parser.Function, functions []knownFunction, distances []int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for j, distance := range distances {
+		if w.err != nil {
+			return
+		}
+		if distance < 0 || w.maxDistance > 0 && distance > w.maxDistance {
+			continue
+		}
+		known := &functions[j]
+		if w.csv != nil {
+			w.err = w.csv.Write([]string{
+				targetFile, fn.Name, strconv.Itoa(fn.StartLine), strconv.Itoa(fn.EndLine),
+				known.component, known.file, known.name, strconv.Itoa(known.startLine), strconv.Itoa(known.endLine),
+				strconv.Itoa(distance),
+			})
+		} else {
+			w.writeEdge(targetFile, fn, known, distance)
+		}
+		w.edges++
+	}
+}
+
+// writeEdge writes a binary edge, defining its functions first if needed
+func (w *DistanceWriter) writeEdge(targetFile string, fn parser.Function, known *knownFunction, distance int) {
+	targetKey := functionKey{file: targetFile, name: fn.Name, startLine: fn.StartLine}
+	targetID, ok := w.targets[targetKey]
+	if !ok {
+		targetID = uint32(len(w.targets))
+		w.targets[targetKey] = targetID
+		w.writeFunction(distanceTarget, targetID, "", targetFile, fn.Name, fn.StartLine, fn.EndLine)
+	}
+	knownKey := functionKey{file: known.file, name: known.name, startLine: known.startLine}
+	knownID, ok := w.known[knownKey]
+	if !ok {
+		knownID = uint32(len(w.known))
+		w.known[knownKey] = knownID
+		w.writeFunction(distanceKnown, knownID, known.component, known.file, known.name, known.startLine, known.endLine)
+	}
+
+	w.writeByte(distanceEdge)
+	w.writeInt(binary.LittleEndian.AppendUint32(nil, targetID))
+	w.writeInt(binary.LittleEndian.AppendUint32(nil, knownID))
+	w.writeInt(binary.LittleEndian.AppendUint16(nil, uint16(distance)))
+}
+
+// writeFunction writes a binary function definition
+func (w *DistanceWriter) writeFunction(tag byte, id uint32, component, file, name string, startLine, endLine int) {
+	w.writeByte(tag)
+	w.writeInt(binary.LittleEndian.AppendUint32(nil, id))
+	if tag == distanceKnown {
+		w.writeString(component)
+	}
+	w.writeString(file)
+	w.writeString(name)
+	w.writeInt(binary.LittleEndian.AppendUint32(nil, uint32(startLine)))
+	w.writeInt(binary.LittleEndian.AppendUint32(nil, uint32(endLine)))
+}
+
+// writeByte writes a byte unless a write failed before
+func (w *DistanceWriter) writeByte(b byte) {
+	if w.err == nil {
+		w.err = w.writer.WriteByte(b)
+	}
+}
+
+// writeInt writes an encoded integer unless a write failed before
+func (w *DistanceWriter) writeInt(b []byte) {
+	if w.err == nil {
+		_, w.err = w.writer.Write(b)
+	}
+}
+
+// writeString writes a string prefixed with its uint16 length, truncating
+// longer strings
+func (w *DistanceWriter) writeString(s string) {
+	if len(s) > 0xffff {
+		s = s[:0xffff]
+	}
+	w.writeInt(binary.LittleEndian.AppendUint16(nil, uint16(len(s))))
+	if w.err == nil {
+		_, w.err = w.writer.WriteString(s)
+	}
+}
+
+// Edges returns the number of pairs exported so far
+func (w *DistanceWriter) Edges() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.edges
+}
+
+// Close flushes and closes the export, returning the first failed write
+func (w *DistanceWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.csv != nil && w.err == nil {
+		w.csv.Flush()
+		w.err = w.csv.Error()
+	}
+	if w.err != nil {
+		abortFile(w.file)
+		return fmt.Errorf("failed to write distances: %v", w.err)
+	}
+	if err := w.writer.Flush(); err != nil {
+		abortFile(w.file)
+		return fmt.Errorf("failed to write distances: %v", err)
+	}
+	return closeFile(w.file)
+}
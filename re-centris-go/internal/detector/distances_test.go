@@ -0,0 +1,75 @@
+package detector
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+)
+
+func TestDistanceWriter(t *testing.T) {
+	body := strings.Repeat("    len = ssl_read_record(s, buf, len); if (len < 0) goto err;\n", 6)
+	var (
+		original = hashOf(t, "int ssl3_read_bytes(SSL *s, unsigned char *buf, int len) {\n"+body+"    return len;\n}")
+		other    = hashOf(t, strings.Repeat("static const char *names[] = { \"alpha\", \"beta\", \"gamma\", \"delta\" };\n", 8))
+	)
+	knownDir := "/known"
+	known := []*analyzer.FileInfo{{
+		Path: filepath.Join(knownDir, "openssl", "s3_pkt.c"),
+		Functions: []parser.Function{
+			{Name: "ssl3_read_bytes", StartLine: 100, EndLine: 120, Hash: original},
+			{Name: "names", StartLine: 130, EndLine: 140, Hash: other},
+		},
+	}}
+	target := &analyzer.FileInfo{
+		Path:      "vendor/s3_pkt.c",
+		Functions: []parser.Function{{Name: "copy", StartLine: 10, EndLine: 30, Hash: original}},
+	}
+
+	run := func(format string, maxDistance int) string {
+		path := filepath.Join(t.TempDir(), "distances")
+		w, err := NewDistanceWriter(path, format, maxDistance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := New(DetectorOptions{KnownFilesDir: knownDir, Distances: w})
+		d.matchFunctions(context.Background(), target, d.buildComponentIndex(known))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// The full matrix has a row per pair
+	rows, err := csv.NewReader(strings.NewReader(run(DistancesCSV, 0))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][0] != "vendor/s3_pkt.c" || rows[1][6] != "ssl3_read_bytes" || rows[1][9] != "0" || rows[2][6] != "names" {
+		t.Errorf("CSV distances = %v, want both pairs", rows)
+	}
+
+	// The edge list keeps the close pair
+	data := run(DistancesBinary, 30)
+	if !strings.HasPrefix(data, distanceMagic) {
+		t.Fatalf("binary distances start with %q", data[:5])
+	}
+	edge := data[len(data)-11:]
+	if edge[0] != distanceEdge || binary.LittleEndian.Uint32([]byte(edge[1:])) != 0 ||
+		binary.LittleEndian.Uint32([]byte(edge[5:])) != 0 || binary.LittleEndian.Uint16([]byte(edge[9:])) != 0 {
+		t.Errorf("binary distances end with edge %q, want target 0 to known 0 at distance 0", edge)
+	}
+	if strings.Contains(data, "names") || strings.Count(data, string(distanceEdge)+"\x00\x00\x00\x00\x00\x00\x00\x00") != 1 {
+		t.Errorf("binary distances = %q, want only the close pair", data)
+	}
+}
@@ -0,0 +1,225 @@
+package detector
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// maxDiffLines is the length of the longest function diffed for a snippet
+const maxDiffLines = 500
+
+// diffContext is the number of unchanged lines around changes in a snippet
+const diffContext = 3
+
+// LineRange is an inclusive range of 1-based line numbers
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// TargetLines returns the lines of the target file of the first function
+// of a match, or line 1 if the match has no function evidence
+func (m Match) TargetLines() LineRange {
+	if len(m.Evidence) == 0 || m.Evidence[0].TargetLines.Start <= 0 {
+		return LineRange{Start: 1, End: 1}
+	}
+	lines := m.Evidence[0].TargetLines
+	if lines.End < lines.Start {
+		lines.End = lines.Start
+	}
+	return lines
+}
+
+// Evidence is a function of the target file matching a function of the
+// known file, locating the match on both sides
+type Evidence struct {
+	Function      string    `json:"function"`
+	TargetLines   LineRange `json:"target_lines"`
+	KnownFunction string    `json:"known_function"`
+	KnownLines    LineRange `json:"known_lines"`
+	Distance      int       `json:"distance"`
+	// QualifiedFunction and KnownQualifiedFunction add the scope and
+	// signature to the names, telling overloads apart, if they are known
+	QualifiedFunction      string `json:"qualified_function,omitempty"`
+	KnownQualifiedFunction string `json:"known_qualified_function,omitempty"`
+	// Diff is a unified diff from the target to the known function, set
+	// with DetectorOptions.DiffSnippets
+	Diff string `json:"diff,omitempty"`
+}
+
+// evidence collects the function matches between a target file and a known
+// file, ordered by target line
+func (d *Detector) evidence(targetFile, knownFile string, functions []functionMatch) []Evidence {
+	var (
+		evidence    []Evidence
+		targetLines []string
+		knownLines  []string
+	)
+	if d.opts.DiffSnippets {
+		// Function bodies are not kept in the corpus and parsed contents
+		// may omit the signature, so diff the line ranges of the files.
+		// Archive entries and removed files get no diff.
+		targetLines, _ = readLines(targetFile)
+		knownLines, _ = readLines(knownFile)
+	}
+
+	for _, fn := range functions {
+		hit, ok := fn.files[knownFile]
+		if !ok {
+			continue
+		}
+		e := Evidence{
+			Function:               fn.target.Name,
+			TargetLines:            LineRange{Start: fn.target.StartLine, End: fn.target.EndLine},
+			KnownFunction:          hit.name,
+			KnownLines:             LineRange{Start: hit.startLine, End: hit.endLine},
+			Distance:               hit.distance,
+			QualifiedFunction:      qualifiedName(fn.target.Name, fn.target.Scope, fn.target.Signature),
+			KnownQualifiedFunction: qualifiedName(hit.name, hit.scope, hit.signature),
+		}
+
+		target, ok := lineRange(targetLines, e.TargetLines)
+		if known, ok2 := lineRange(knownLines, e.KnownLines); ok && ok2 {
+			e.Diff = unifiedDiff(target, known, targetFile, knownFile, e.TargetLines.Start, e.KnownLines.Start)
+		}
+
+		evidence = append(evidence, e)
+	}
+
+	sort.Slice(evidence, func(i, j int) bool {
+		return evidence[i].TargetLines.Start < evidence[j].TargetLines.Start
+	})
+	return evidence
+}
+
+// readLines reads the lines of a file
+func readLines(path string) ([]string, error) {
+	file, err := fsutil.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// lineRange slices the lines of a range, reporting false if it is out of
+// bounds
+func lineRange(lines []string, r LineRange) ([]string, bool) {
+	if r.Start < 1 || r.End < r.Start || r.End > len(lines) {
+		return nil, false
+	}
+	return lines[r.Start-1 : r.End], true
+}
+
+// unifiedDiff returns a unified diff from lines a to lines b, which start
+// at lines aStart and bStart of the files aName and bName. It is empty if
+// the lines are equal or too long to diff.
+func unifiedDiff(a, b []string, aName, bName string, aStart, bStart int) string {
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return ""
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Edit script: ' ' keeps, '-' deletes a[i], '+' inserts b[j]
+	type edit struct {
+		op   byte
+		i, j int
+	}
+	var edits []edit
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', i, j})
+			i++
+			changed = true
+		default:
+			edits = append(edits, edit{'+', i, j})
+			j++
+			changed = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	for start := 0; start < len(edits); {
+		// Find the next change and the extent of its hunk, merging changes
+		// separated by less than twice the context
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+		from := max(start-diffContext, 0)
+		to := min(end+diffContext, len(edits))
+
+		var aLines, bLines int
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				aLines++
+			}
+			if e.op != '-' {
+				bLines++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart+edits[from].i, aLines, bStart+edits[from].j, bLines)
+		for _, e := range edits[from:to] {
+			switch e.op {
+			case '+':
+				out.WriteString("+" + b[e.j] + "\n")
+			case '-':
+				out.WriteString("-" + a[e.i] + "\n")
+			default:
+				out.WriteString(" " + a[e.i] + "\n")
+			}
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
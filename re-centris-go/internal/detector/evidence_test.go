@@ -0,0 +1,16 @@
+package detector
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []string{"int f(int a) {", "  int b = a;", "  return b;", "}"}
+	b := []string{"int f(int a) {", "  int c = a;", "  return c;", "}"}
+
+	want := "--- t.c\n+++ k.c\n@@ -10,4 +20,4 @@\n int f(int a) {\n-  int b = a;\n-  return b;\n+  int c = a;\n+  return c;\n }\n"
+	if got := unifiedDiff(a, b, "t.c", "k.c", 10, 20); got != want {
+		t.Errorf("unifiedDiff() = %q, want %q", got, want)
+	}
+	if got := unifiedDiff(a, a, "t.c", "k.c", 1, 1); got != "" {
+		t.Errorf("unifiedDiff() of equal lines = %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package detector
+
+import (
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+// digestIndex groups known files by the SHA-256 of their content
+func digestIndex(files []*analyzer.FileInfo) map[string][]*analyzer.FileInfo {
+	index := make(map[string][]*analyzer.FileInfo)
+	for _, file := range files {
+		if file.Digest != "" {
+			index[file.Digest] = append(index[file.Digest], file)
+		}
+	}
+	return index
+}
+
+// exactCopies returns the known files identical to a target file. They are
+// only looked up with DetectorOptions.ExactFirst, so that the TLSH
+// comparison can be skipped for verbatim copies.
+func (d *Detector) exactCopies(fileInfo *analyzer.FileInfo, digests map[string][]*analyzer.FileInfo) []*analyzer.FileInfo {
+	if !d.opts.ExactFirst || fileInfo.Digest == "" {
+		return nil
+	}
+	var copies []*analyzer.FileInfo
+	for _, file := range digests[fileInfo.Digest] {
+		if file.Path != fileInfo.Path {
+			copies = append(copies, file)
+		}
+	}
+	return copies
+}
+
+// markDuplicates lists, on every result, the other target files with the
+// same content
+func markDuplicates(results []*DetectionResult) {
+	groups := make(map[string][]string)
+	for _, result := range results {
+		if result.Digest != "" {
+			groups[result.Digest] = append(groups[result.Digest], result.TargetFile)
+		}
+	}
+
+	for _, result := range results {
+		group := groups[result.Digest]
+		if len(group) < 2 {
+			continue
+		}
+		result.Duplicates = make([]string, 0, len(group)-1)
+		for _, file := range group {
+			if file != result.TargetFile {
+				result.Duplicates = append(result.Duplicates, file)
+			}
+		}
+		sort.Strings(result.Duplicates)
+	}
+}
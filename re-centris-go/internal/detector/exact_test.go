@@ -0,0 +1,72 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+func TestExactFirst(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "int f%d(int x) { if (x > %d) return x * %d + 7; return x - %d; }\n", i, i*3, i+2, i)
+	}
+	source := b.String()
+	nearMiss := strings.Replace(source, "return x - 0;", "return x - 9;", 1)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"known/zlib/lib.c":  source,
+		"known/other/lib.c": nearMiss,
+		"target/a.c":        source,
+		"target/copy/a.c":   source,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	targets := []string{filepath.Join(dir, "target/a.c"), filepath.Join(dir, "target/copy/a.c")}
+
+	detect := func(exactFirst bool) []*DetectionResult {
+		d := New(DetectorOptions{
+			MaxWorkers:          2,
+			SimilarityThreshold: 0.5,
+			Languages:           analyzer.DefaultLanguages(),
+			KnownFilesDir:       filepath.Join(dir, "known"),
+			ExactFirst:          exactFirst,
+		})
+		knownFiles, err := d.LoadKnownFiles(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := d.DetectWithKnownFiles(context.Background(), targets, knownFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	if results := detect(false); len(results[0].Matches) != 2 {
+		t.Fatalf("matches without exact-first = %+v, want the copy and the near-miss", results[0].Matches)
+	}
+
+	results := detect(true)
+	for _, result := range results {
+		if len(result.Matches) != 1 || result.Matches[0].Component != "zlib" || result.Matches[0].CloneType != CloneExact {
+			t.Errorf("matches of %s = %+v, want only the exact copy in zlib", result.TargetFile, result.Matches)
+		}
+		if len(result.Duplicates) != 1 || result.Duplicates[0] == result.TargetFile {
+			t.Errorf("duplicates of %s = %v, want the other target", result.TargetFile, result.Duplicates)
+		}
+	}
+}
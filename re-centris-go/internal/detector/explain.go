@@ -0,0 +1,93 @@
+package detector
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Reasons a known file matched a target file
+const (
+	// ReasonIdenticalDigest means both files have the same TLSH digest
+	ReasonIdenticalDigest = "identical_digest"
+	// ReasonTLSHDistance means the TLSH distance is within the threshold
+	ReasonTLSHDistance = "tlsh_distance"
+)
+
+// Explanation describes why a known file matched a target file, so that a
+// reviewer can judge a finding without knowing the scoring internals
+type Explanation struct {
+	Reason      string `json:"reason"`
+	Distance    int    `json:"distance"`
+	MaxDistance int    `json:"max_distance"`
+	// SharedFunctions is the number of target functions also found in the known file
+	SharedFunctions int `json:"shared_functions"`
+	// RareFunctions is the number of shared functions found in no other component
+	RareFunctions int `json:"rare_functions"`
+	// MatchedLines is the number of lines of the shared functions
+	MatchedLines int `json:"matched_lines"`
+	// MeanFrequency is the mean number of components the shared functions
+	// are found in
+	MeanFrequency float64 `json:"mean_frequency,omitempty"`
+	// ComponentFunctions is the number of target functions found in the
+	// component of the known file, in this or other files
+	ComponentFunctions int    `json:"component_functions"`
+	Summary            string `json:"summary"`
+}
+
+// explain builds the explanation of a match from the file distance and the
+// function matches of the target file
+func (d *Detector) explain(distance, maxDistance int, knownFile, component string, functions []functionMatch) *Explanation {
+	e := &Explanation{
+		Reason:      ReasonTLSHDistance,
+		Distance:    distance,
+		MaxDistance: maxDistance,
+	}
+	if distance == 0 {
+		e.Reason = ReasonIdenticalDigest
+	}
+
+	frequency := 0
+	for _, fn := range functions {
+		if _, ok := fn.components[component]; ok && component != "" {
+			e.ComponentFunctions++
+		}
+		if _, ok := fn.files[knownFile]; !ok {
+			continue
+		}
+		e.SharedFunctions++
+		e.MatchedLines += fn.target.EndLine - fn.target.StartLine + 1
+		frequency += len(fn.components)
+		if len(fn.components) == 1 {
+			e.RareFunctions++
+		}
+	}
+	if e.SharedFunctions > 0 {
+		e.MeanFrequency = math.Round(float64(frequency)/float64(e.SharedFunctions)*100) / 100
+	}
+
+	var parts []string
+	if e.Reason == ReasonIdenticalDigest {
+		parts = append(parts, "identical TLSH digest")
+	} else {
+		parts = append(parts, fmt.Sprintf("TLSH distance %d within threshold %d", distance, maxDistance))
+	}
+	if e.SharedFunctions > 0 {
+		part := fmt.Sprintf("%d shared %s", e.SharedFunctions, plural(e.SharedFunctions, "function", "functions"))
+		if e.RareFunctions > 0 {
+			part += fmt.Sprintf(", %d of them unique to %s", e.RareFunctions, component)
+		}
+		parts = append(parts, part)
+	}
+	e.Summary = strings.Join(parts, "; ")
+
+	return e
+}
+
+// plural returns singular for a count of one, otherwise plural
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,166 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// githubMaxAnnotations is the maximum number of annotations GitHub accepts
+// in a single check run update
+const githubMaxAnnotations = 50
+
+// githubWriter writes one GitHub Actions workflow command per match, so
+// that matched target files are annotated in pull requests. Commands only
+// take effect when printed by a workflow step, so the output is usually "-".
+type githubWriter struct {
+	d      *Detector
+	file   *outputFile
+	writer *bufio.Writer
+}
+
+// newGitHubWriter creates a workflow command writer at path
+func (d *Detector) newGitHubWriter(path string) (*githubWriter, error) {
+	file, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	return &githubWriter{d: d, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// Write emits an annotation for every match of a result
+func (w *githubWriter) Write(result *DetectionResult) error {
+	for _, match := range result.Matches {
+		command := "notice"
+		if sarifLevel(match.Similarity) == "warning" {
+			command = "warning"
+		}
+
+		lines := match.TargetLines()
+		line := fmt.Sprintf("::%s file=%s,line=%d,endLine=%d,title=%s::%s\n",
+			command,
+			githubProperty(filepath.ToSlash(result.TargetFile)),
+			lines.Start, lines.End,
+			githubProperty("Known code reuse"),
+			githubData(w.d.annotationMessage(match)))
+		if _, err := w.writer.WriteString(line); err != nil {
+			return fmt.Errorf("failed to write results: %v", err)
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes the output
+func (w *githubWriter) Close() error {
+	return closeOutput(w.file, w.writer)
+}
+
+// annotationMessage describes a match, with the explanation if there is one
+func (d *Detector) annotationMessage(match Match) string {
+	message := d.MatchMessage(match)
+	if match.Explanation != nil && match.Explanation.Summary != "" {
+		message += "\n" + match.Explanation.Summary
+	}
+	return message
+}
+
+// githubData escapes the message of a workflow command
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty escapes a property value of a workflow command
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// githubCheckOutput is the output object of a GitHub check run
+type githubCheckOutput struct {
+	Title       string                  `json:"title"`
+	Summary     string                  `json:"summary"`
+	Annotations []githubCheckAnnotation `json:"annotations"`
+}
+
+// githubCheckAnnotation is a check run annotation
+type githubCheckAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title"`
+	Message         string `json:"message"`
+}
+
+// githubCheckWriter collects annotations and writes a check run output
+// object on Close, ready to be sent to the GitHub Checks API
+type githubCheckWriter struct {
+	d       *Detector
+	path    string
+	output  githubCheckOutput
+	files   int
+	matches int
+}
+
+// newGitHubCheckWriter creates a check run writer for path
+func (d *Detector) newGitHubCheckWriter(path string) *githubCheckWriter {
+	return &githubCheckWriter{
+		d:      d,
+		path:   path,
+		output: githubCheckOutput{Annotations: []githubCheckAnnotation{}},
+	}
+}
+
+// Write adds the matches of a result as annotations
+func (w *githubCheckWriter) Write(result *DetectionResult) error {
+	if len(result.Matches) > 0 {
+		w.files++
+	}
+	for _, match := range result.Matches {
+		w.matches++
+		// Further annotations have to be sent in additional updates
+		if len(w.output.Annotations) == githubMaxAnnotations {
+			continue
+		}
+
+		level := "notice"
+		if sarifLevel(match.Similarity) == "warning" {
+			level = "warning"
+		}
+		lines := match.TargetLines()
+		w.output.Annotations = append(w.output.Annotations, githubCheckAnnotation{
+			Path:            filepath.ToSlash(result.TargetFile),
+			StartLine:       lines.Start,
+			EndLine:         lines.End,
+			AnnotationLevel: level,
+			Title:           "Known code reuse",
+			Message:         w.d.annotationMessage(match),
+		})
+	}
+	return nil
+}
+
+// Close writes the check run output
+func (w *githubCheckWriter) Close() error {
+	w.output.Title = fmt.Sprintf("%d known code matches", w.matches)
+	w.output.Summary = fmt.Sprintf("Re-Centris found %d matches with known open source code in %d files.", w.matches, w.files)
+	if w.matches > len(w.output.Annotations) {
+		w.output.Summary += fmt.Sprintf(" The first %d are annotated.", len(w.output.Annotations))
+	}
+
+	data, err := json.MarshalIndent(w.output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal check run: %v", err)
+	}
+
+	file, err := createOutput(w.path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		abortFile(file)
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	return closeFile(file)
+}
@@ -0,0 +1,42 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteResultsGitHub(t *testing.T) {
+	d := New(DetectorOptions{KnownFilesDir: "/known"})
+	results := []*DetectionResult{{
+		TargetFile: "src/a,b.c",
+		Matches: []Match{
+			{File: "/known/zlib/inflate.c", Similarity: 0.98},
+			{
+				File: "/known/zlib/deflate.c", Similarity: 0.82, Explanation: &Explanation{Summary: "100% shared"},
+				Evidence: []Evidence{{Function: "deflate", TargetLines: LineRange{Start: 12, End: 40}}},
+			},
+		},
+	}}
+
+	path := filepath.Join(t.TempDir(), "annotations.txt")
+	if err := d.WriteResults(results, FormatGitHub, path); err != nil {
+		t.Fatalf("WriteResults() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read annotations: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("annotations = %d, want 2", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], "::warning file=src/a%2Cb.c,line=1,endLine=1,") {
+		t.Errorf("first annotation = %q, want warning on src/a%%2Cb.c", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "::notice file=src/a%2Cb.c,line=12,endLine=40,") || !strings.HasSuffix(lines[1], "%0A100%25 shared") {
+		t.Errorf("second annotation = %q, want notice with escaped explanation", lines[1])
+	}
+}
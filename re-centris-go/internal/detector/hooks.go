@@ -0,0 +1,67 @@
+package detector
+
+import (
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+// FileAnalyzedHook is called after a target file has been analyzed
+type FileAnalyzedHook func(file *analyzer.FileInfo)
+
+// MatchFoundHook is called for every match of a target file
+type MatchFoundHook func(targetFile string, match Match)
+
+// ComponentDetectedHook is called for every component a target file is attributed to
+type ComponentDetectedHook func(targetFile string, component ComponentMatch)
+
+// hooks holds the callbacks registered on a Detector. Hooks are called from
+// the detection workers, so they may run concurrently and must be safe for
+// concurrent use.
+type hooks struct {
+	fileAnalyzed      []FileAnalyzedHook
+	matchFound        []MatchFoundHook
+	componentDetected []ComponentDetectedHook
+	mutex             sync.RWMutex
+}
+
+// OnFileAnalyzed registers a hook called after each target file is analyzed
+func (d *Detector) OnFileAnalyzed(hook FileAnalyzedHook) {
+	d.hooks.mutex.Lock()
+	defer d.hooks.mutex.Unlock()
+	d.hooks.fileAnalyzed = append(d.hooks.fileAnalyzed, hook)
+}
+
+// OnMatchFound registers a hook called for each match found
+func (d *Detector) OnMatchFound(hook MatchFoundHook) {
+	d.hooks.mutex.Lock()
+	defer d.hooks.mutex.Unlock()
+	d.hooks.matchFound = append(d.hooks.matchFound, hook)
+}
+
+// OnComponentDetected registers a hook called for each detected component
+func (d *Detector) OnComponentDetected(hook ComponentDetectedHook) {
+	d.hooks.mutex.Lock()
+	defer d.hooks.mutex.Unlock()
+	d.hooks.componentDetected = append(d.hooks.componentDetected, hook)
+}
+
+// notify runs all registered hooks for a completed target file
+func (h *hooks) notify(file *analyzer.FileInfo, result *DetectionResult) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for _, hook := range h.fileAnalyzed {
+		hook(file)
+	}
+	for _, match := range result.Matches {
+		for _, hook := range h.matchFound {
+			hook(result.TargetFile, match)
+		}
+	}
+	for _, component := range result.Components {
+		for _, hook := range h.componentDetected {
+			hook(result.TargetFile, component)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package detector
+
+import (
+	"context"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/index"
+	"go.uber.org/zap"
+)
+
+// loadIndex loads known files from the index at IndexPath, updating it
+// with the changes of the known files directory. A missing or unreadable
+// index is rebuilt.
+func (d *Detector) loadIndex(ctx context.Context) ([]*analyzer.FileInfo, error) {
+	previous := index.Open(d.opts.IndexPath)
+	x := index.New(index.IndexOptions{
+		Dir:      d.opts.KnownFilesDir,
+		Analyzer: d.opts.AnalyzerOptions(),
+	})
+	idx, stats, err := x.Update(ctx, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous == nil || stats.Changed() {
+		if err := idx.Save(d.opts.IndexPath); err != nil {
+			return nil, err
+		}
+	}
+	logger.Info("Loaded known files index",
+		zap.String("index", d.opts.IndexPath),
+		zap.Int("files", idx.Len()),
+		zap.Int("reused", stats.Reused),
+		zap.Int("analyzed", stats.Analyzed),
+		zap.Int("removed", stats.Removed))
+
+	return idx.FileInfos()
+}
@@ -0,0 +1,143 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ResultSummary is the final record of a JSONL result file
+type ResultSummary struct {
+	Results      int      `json:"results"`
+	MatchedFiles int      `json:"matched_files"`
+	Matches      int      `json:"matches"`
+	Components   []string `json:"components"`
+}
+
+// jsonlRecord is a line of a JSONL result file: a result, or the summary
+// record ending the file
+type jsonlRecord struct {
+	*DetectionResult
+	Summary *ResultSummary `json:"summary,omitempty"`
+}
+
+// jsonlWriter writes one detection result per line, followed by a summary
+// record when closed
+type jsonlWriter struct {
+	file    *outputFile
+	writer  *bufio.Writer
+	summary *summaryCounter
+}
+
+// summaryCounter accumulates the summary of results
+type summaryCounter struct {
+	d          *Detector
+	summary    ResultSummary
+	components map[string]bool
+}
+
+// newSummaryCounter creates an empty summaryCounter
+func (d *Detector) newSummaryCounter() *summaryCounter {
+	return &summaryCounter{d: d, components: make(map[string]bool)}
+}
+
+// add counts a result
+func (c *summaryCounter) add(result *DetectionResult) {
+	c.summary.Results++
+	if len(result.Matches) > 0 {
+		c.summary.MatchedFiles++
+	}
+	c.summary.Matches += len(result.Matches)
+	for _, component := range c.d.matchedComponents(result) {
+		c.components[component] = true
+	}
+}
+
+// result returns the summary of the results counted so far
+func (c *summaryCounter) result() *ResultSummary {
+	summary := c.summary
+	summary.Components = make([]string, 0, len(c.components))
+	for component := range c.components {
+		summary.Components = append(summary.Components, component)
+	}
+	sort.Strings(summary.Components)
+	return &summary
+}
+
+// Summarize counts the results, matched files, matches and matched
+// components of results
+func (d *Detector) Summarize(results []*DetectionResult) *ResultSummary {
+	c := d.newSummaryCounter()
+	for _, result := range results {
+		c.add(result)
+	}
+	return c.result()
+}
+
+// newJSONLWriter creates a JSONL result file at path. Results are written
+// to the file as they are detected rather than renamed into place, so that
+// a crashed run keeps the complete lines written before it.
+func (d *Detector) newJSONLWriter(path string) (*jsonlWriter, error) {
+	file, err := createStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonlWriter{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		summary: d.newSummaryCounter(),
+	}, nil
+}
+
+// Write appends a result line to the file
+func (w *jsonlWriter) Write(result *DetectionResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %v", err)
+	}
+	if _, err := w.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	if err := w.file.sync(w.writer); err != nil {
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+
+	w.summary.add(result)
+	return nil
+}
+
+// Close writes the summary record and closes the file
+func (w *jsonlWriter) Close() error {
+	data, err := json.Marshal(jsonlRecord{Summary: w.summary.result()})
+	if err != nil {
+		abortFile(w.file)
+		return fmt.Errorf("failed to marshal summary: %v", err)
+	}
+	if _, err := w.writer.Write(append(data, '\n')); err != nil {
+		abortFile(w.file)
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	return closeOutput(w.file, w.writer)
+}
+
+// readJSONL calls fn for each result of a JSONL result file, skipping the
+// summary record
+func readJSONL(dec *json.Decoder, fn func(*DetectionResult) error) error {
+	for {
+		var record jsonlRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to parse result: %v", err)
+		}
+		if record.Summary != nil || record.DetectionResult == nil {
+			continue
+		}
+		if err := fn(record.DetectionResult); err != nil {
+			return err
+		}
+	}
+}
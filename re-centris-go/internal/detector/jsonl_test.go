@@ -0,0 +1,86 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONLOutput(t *testing.T) {
+	known := t.TempDir()
+	dir := filepath.Join(known, "comp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "x.c"), source(0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targets := t.TempDir()
+	var files []string
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(targets, fmt.Sprintf("t%d.c", i))
+		if err := os.WriteFile(path, source(i), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	w, err := New(DetectorOptions{}).NewResultWriter(FormatJSONL, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(DetectorOptions{
+		MaxWorkers:          2,
+		SimilarityThreshold: 0.5,
+		KnownFilesDir:       known,
+		Languages:           map[string][]string{"cpp": {".c"}},
+		Output:              w,
+	})
+	results, err := d.DetectSimilarity(context.Background(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Results are readable before the writer is closed
+	if data, err := os.ReadFile(output); err != nil || strings.Count(string(data), "\n") != 3 {
+		t.Errorf("output before Close = %q, %v, want the 3 results", data, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("DetectSimilarity() returned %d results, want them written to the output", len(results))
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 3 results and the summary:\n%s", len(lines), data)
+	}
+	var record jsonlRecord
+	if err := json.Unmarshal([]byte(lines[3]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if s := record.Summary; s == nil || s.Results != 3 || s.MatchedFiles != 3 || len(s.Components) != 1 || s.Components[0] != "comp" {
+		t.Errorf("summary = %+v, want 3 matched results of comp", record.Summary)
+	}
+
+	read := 0
+	err = ReadResults(output, func(result *DetectionResult) error {
+		if len(result.Matches) == 0 {
+			t.Errorf("read result %s without matches", result.TargetFile)
+		}
+		read++
+		return nil
+	})
+	if err != nil || read != 3 {
+		t.Errorf("ReadResults() read %d results, error %v, want 3", read, err)
+	}
+}
@@ -0,0 +1,214 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// LabelsFileName is the name of the labels file in a signature database
+const LabelsFileName = "labels.json"
+
+// labelsVersion is the version of the labels file format
+const labelsVersion = 1
+
+// Handling of matches labelled as false positives
+const (
+	// FalsePositiveSuppress drops labelled matches
+	FalsePositiveSuppress = "suppress"
+	// FalsePositiveDownrank keeps labelled matches, flagged as false
+	// positives and ranked after the others
+	FalsePositiveDownrank = "downrank"
+)
+
+// ParseFalsePositiveMode checks a false positive mode, empty meaning
+// FalsePositiveSuppress
+func ParseFalsePositiveMode(mode string) error {
+	switch mode {
+	case "", FalsePositiveSuppress, FalsePositiveDownrank:
+		return nil
+	default:
+		return fmt.Errorf("unsupported false positive mode: %s", mode)
+	}
+}
+
+// Labels holds the matches labelled as false positives. Unlike a baseline,
+// which is tied to the target paths of a project, labels are keyed by the
+// TLSH digests of the target and known files, so they apply to the same
+// code wherever it is detected.
+type Labels struct {
+	Version int     `json:"version"`
+	Labels  []Label `json:"labels"`
+
+	pairs map[labelPair]int
+}
+
+// Label is a match labelled as a false positive. Target, KnownFile and
+// Component describe where it was labelled.
+type Label struct {
+	TargetHash string    `json:"target_hash"`
+	KnownHash  string    `json:"known_hash"`
+	Target     string    `json:"target,omitempty"`
+	KnownFile  string    `json:"known_file,omitempty"`
+	Component  string    `json:"component,omitempty"`
+	LabeledAt  time.Time `json:"labeled_at"`
+}
+
+type labelPair struct {
+	target, known string
+}
+
+// NewLabels creates an empty set of labels
+func NewLabels() *Labels {
+	return &Labels{Version: labelsVersion, Labels: []Label{}}
+}
+
+// LoadLabels reads a labels file; a missing file holds no labels
+func LoadLabels(path string) (*Labels, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewLabels(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels: %v", err)
+	}
+
+	var l Labels
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("failed to parse labels: %v", err)
+	}
+	if l.Version != labelsVersion {
+		return nil, fmt.Errorf("unsupported labels version: %d", l.Version)
+	}
+
+	labels := l.Labels
+	l.Labels = []Label{}
+	for _, label := range labels {
+		l.Add(label)
+	}
+	return &l, nil
+}
+
+// Save writes the labels to path, ordered by digests
+func (l *Labels) Save(path string) error {
+	sort.Slice(l.Labels, func(i, j int) bool {
+		x, y := l.Labels[i], l.Labels[j]
+		if x.TargetHash != y.TargetHash {
+			return x.TargetHash < y.TargetHash
+		}
+		return x.KnownHash < y.KnownHash
+	})
+	l.reindex()
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal labels: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write labels: %v", err)
+	}
+	return nil
+}
+
+// Add labels a match as a false positive and reports whether it was not
+// labelled yet
+func (l *Labels) Add(label Label) bool {
+	if l.pairs == nil {
+		l.reindex()
+	}
+	pair := labelPair{label.TargetHash, label.KnownHash}
+	if _, ok := l.pairs[pair]; ok {
+		return false
+	}
+	if label.LabeledAt.IsZero() {
+		label.LabeledAt = time.Now().UTC()
+	}
+	l.pairs[pair] = len(l.Labels)
+	l.Labels = append(l.Labels, label)
+	return true
+}
+
+// Remove removes the label of a match and reports whether it had one
+func (l *Labels) Remove(targetHash, knownHash string) bool {
+	if !l.IsFalsePositive(targetHash, knownHash) {
+		return false
+	}
+	i := l.pairs[labelPair{targetHash, knownHash}]
+	l.Labels = append(l.Labels[:i], l.Labels[i+1:]...)
+	l.reindex()
+	return true
+}
+
+// IsFalsePositive reports whether the match of a target file with a known
+// file, by their TLSH digests, is labelled as a false positive
+func (l *Labels) IsFalsePositive(targetHash, knownHash string) bool {
+	if l == nil || targetHash == "" || knownHash == "" {
+		return false
+	}
+	_, ok := l.pairs[labelPair{targetHash, knownHash}]
+	return ok
+}
+
+// reindex rebuilds the lookup of labels by digests
+func (l *Labels) reindex() {
+	l.pairs = make(map[labelPair]int, len(l.Labels))
+	for i, label := range l.Labels {
+		l.pairs[labelPair{label.TargetHash, label.KnownHash}] = i
+	}
+}
+
+// LabelResults labels the matches of results flagged as false positives,
+// e.g. by editing a result file, and returns the number of new labels.
+// Results without the digest of their target file cannot be labelled.
+func (d *Detector) LabelResults(results []*DetectionResult, l *Labels) int {
+	added := 0
+	for _, result := range results {
+		if result.Hash == "" {
+			continue
+		}
+		for _, match := range result.Matches {
+			if match.FalsePositive && l.Add(d.Label(result, match)) {
+				added++
+			}
+		}
+	}
+	return added
+}
+
+// Label returns the label of a match as a false positive
+func (d *Detector) Label(result *DetectionResult, match Match) Label {
+	return Label{
+		TargetHash: result.Hash,
+		KnownHash:  match.Hash,
+		Target:     filepath.ToSlash(result.TargetFile),
+		KnownFile:  match.File,
+		Component:  d.matchComponent(match),
+	}
+}
+
+// excludeFalsePositives drops the candidates labelled as false positives
+func (d *Detector) excludeFalsePositives(targetHash string, candidates []candidate) []candidate {
+	kept := candidates[:0]
+	for _, c := range candidates {
+		if !d.opts.FalsePositives.IsFalsePositive(targetHash, c.hash) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// labelFalsePositives flags the matches labelled as false positives
+func (d *Detector) labelFalsePositives(targetHash string, matches []Match) {
+	for i := range matches {
+		m := &matches[i]
+		m.FalsePositive = d.opts.FalsePositives.IsFalsePositive(targetHash, m.Hash)
+	}
+}
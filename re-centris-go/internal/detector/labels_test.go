@@ -0,0 +1,82 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+func TestFalsePositiveLabels(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "int f%d(int x) { if (x > %d) return x * %d + 7; return x - %d; }\n", i, i*3, i+2, i)
+	}
+	source := b.String()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"known/zlib/lib.c":  source,
+		"known/other/lib.c": strings.Replace(source, "return x - 0;", "return x - 9;", 1),
+		"target/a.c":        source,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	detect := func(labels *Labels, mode string) *DetectionResult {
+		d := New(DetectorOptions{
+			MaxWorkers:          2,
+			SimilarityThreshold: 0.5,
+			Languages:           analyzer.DefaultLanguages(),
+			KnownFilesDir:       filepath.Join(dir, "known"),
+			FalsePositives:      labels,
+			FalsePositiveMode:   mode,
+		})
+		results, err := d.DetectSimilarity(context.Background(), []string{filepath.Join(dir, "target/a.c")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results[0]
+	}
+
+	// Flag the zlib match as a user would in the result file, then import it
+	result := detect(nil, "")
+	if result.Hash == "" || len(result.Matches) != 2 {
+		t.Fatalf("result = %+v, want a target hash and two matches", result)
+	}
+	for i := range result.Matches {
+		result.Matches[i].FalsePositive = result.Matches[i].Component == "zlib"
+	}
+	path := filepath.Join(dir, LabelsFileName)
+	labels := NewLabels()
+	d := New(DetectorOptions{KnownFilesDir: filepath.Join(dir, "known")})
+	if added := d.LabelResults([]*DetectionResult{result}, labels); added != 1 {
+		t.Fatalf("LabelResults() = %d, want 1", added)
+	}
+	if err := labels.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if labels, err := LoadLabels(path); err != nil {
+		t.Fatal(err)
+	} else if len(labels.Labels) != 1 || labels.Labels[0].Component != "zlib" {
+		t.Fatalf("LoadLabels() = %+v, want the zlib match", labels.Labels)
+	}
+
+	if got := detect(labels, FalsePositiveSuppress); len(got.Matches) != 1 || got.Matches[0].Component != "other" {
+		t.Errorf("suppressed matches = %+v, want only other", got.Matches)
+	}
+	got := detect(labels, FalsePositiveDownrank)
+	if len(got.Matches) != 2 || got.Matches[0].Component != "other" || !got.Matches[1].FalsePositive {
+		t.Errorf("downranked matches = %+v, want other first and zlib flagged last", got.Matches)
+	}
+}
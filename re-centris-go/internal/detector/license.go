@@ -0,0 +1,69 @@
+package detector
+
+import (
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/license"
+)
+
+// LicenseInfo describes the licenses involved in a result
+type LicenseInfo struct {
+	Target     string            `json:"target,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+	Conflicts  []string          `json:"conflicts,omitempty"`
+}
+
+// AnnotateLicenses records the licenses of the target file and of its
+// matched components in every result, together with warnings about licenses
+// that cannot be combined. licenses maps components to SPDX license IDs;
+// targetLicense is used for target files without an SPDX header.
+func (d *Detector) AnnotateLicenses(results []*DetectionResult, licenses map[string]string, targetLicense string) {
+	for _, result := range results {
+		components := d.matchedComponents(result)
+		if len(components) == 0 {
+			continue
+		}
+
+		info := &LicenseInfo{
+			Target:     license.FileHeader(result.TargetFile),
+			Components: make(map[string]string),
+		}
+		if info.Target == "" {
+			info.Target = targetLicense
+		}
+
+		for _, component := range components {
+			id, ok := licenses[component]
+			if !ok {
+				continue
+			}
+			info.Components[component] = id
+			if warning := license.Conflict(info.Target, id); warning != "" {
+				info.Conflicts = append(info.Conflicts, component+": "+warning)
+			}
+		}
+
+		result.License = info
+	}
+}
+
+// matchedComponents returns the distinct, sorted components matched by a
+// result, by function attribution or through matched known files
+func (d *Detector) matchedComponents(result *DetectionResult) []string {
+	seen := make(map[string]bool)
+	for _, c := range result.Components {
+		seen[c.Component] = true
+	}
+	for _, match := range result.Matches {
+		if component := d.matchComponent(match); component != "" {
+			seen[component] = true
+		}
+	}
+
+	components := make([]string, 0, len(seen))
+	for component := range seen {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	return components
+}
@@ -0,0 +1,73 @@
+package detector
+
+import "sort"
+
+// limitMatches drops the matches of a result below MinSimilarity and beyond
+// MaxMatchesPerFile. Matches must be sorted.
+func (d *Detector) limitMatches(result *DetectionResult) {
+	matches := result.Matches
+	if d.opts.MinSimilarity > 0 {
+		n := sort.Search(len(matches), func(i int) bool {
+			return matches[i].Similarity < d.opts.MinSimilarity
+		})
+		matches = matches[:n]
+	}
+	if max := d.opts.MaxMatchesPerFile; max > 0 && len(matches) > max {
+		matches = matches[:max]
+	}
+	d.setMatches(result, matches)
+}
+
+// limitTopK keeps the TopK matches with the highest similarity across all
+// results, breaking ties by target and known file
+func (d *Detector) limitTopK(results []*DetectionResult) {
+	k := d.opts.TopK
+	if k <= 0 {
+		return
+	}
+
+	type ranked struct {
+		result *DetectionResult
+		match  Match
+	}
+	var all []ranked
+	for _, result := range results {
+		for _, match := range result.Matches {
+			all = append(all, ranked{result, match})
+		}
+	}
+	if len(all) <= k {
+		return
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.match.Similarity != b.match.Similarity {
+			return a.match.Similarity > b.match.Similarity
+		}
+		if a.result.TargetFile != b.result.TargetFile {
+			return a.result.TargetFile < b.result.TargetFile
+		}
+		return a.match.File < b.match.File
+	})
+
+	kept := make(map[*DetectionResult][]Match)
+	for _, r := range all[:k] {
+		kept[r.result] = append(kept[r.result], r.match)
+	}
+	for _, result := range results {
+		matches := kept[result]
+		sortMatches(matches)
+		d.setMatches(result, matches)
+	}
+}
+
+// setMatches replaces the matches of a result, counting the dropped ones
+func (d *Detector) setMatches(result *DetectionResult, matches []Match) {
+	if matches == nil {
+		matches = []Match{}
+	}
+	result.OmittedMatches += len(result.Matches) - len(matches)
+	result.Matches = matches
+	result.MatchCount = len(matches)
+}
@@ -0,0 +1,29 @@
+package detector
+
+import "testing"
+
+func TestLimits(t *testing.T) {
+	newResults := func() []*DetectionResult {
+		return []*DetectionResult{
+			{TargetFile: "a.c", Matches: []Match{{File: "x", Similarity: 0.95}, {File: "y", Similarity: 0.85}, {File: "z", Similarity: 0.82}}},
+			{TargetFile: "b.c", Matches: []Match{{File: "x", Similarity: 0.9}}},
+		}
+	}
+
+	d := New(DetectorOptions{MinSimilarity: 0.84, MaxMatchesPerFile: 1})
+	results := newResults()
+	d.limitMatches(results[0])
+	if got := results[0]; len(got.Matches) != 1 || got.MatchCount != 1 || got.OmittedMatches != 2 {
+		t.Errorf("limitMatches() = %+v, want 1 match and 2 omitted", got)
+	}
+
+	d = New(DetectorOptions{TopK: 2})
+	results = newResults()
+	d.limitTopK(results)
+	if len(results[0].Matches) != 1 || results[0].Matches[0].File != "x" || len(results[1].Matches) != 1 {
+		t.Errorf("limitTopK() kept %v and %v, want the 0.95 and 0.9 matches", results[0].Matches, results[1].Matches)
+	}
+	if results[0].OmittedMatches != 2 {
+		t.Errorf("OmittedMatches = %d, want 2", results[0].OmittedMatches)
+	}
+}
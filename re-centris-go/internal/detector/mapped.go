@@ -0,0 +1,133 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/sigindex"
+	"go.uber.org/zap"
+)
+
+// detectMapped detects target files against the signature index at
+// SignatureIndex, which is mapped into memory for the detection
+func (d *Detector) detectMapped(ctx context.Context, targetFiles []string) ([]*DetectionResult, error) {
+	if d.opts.Distances != nil {
+		return nil, fmt.Errorf("distances cannot be exported when searching a signature index")
+	}
+	corpus, err := d.openMapped(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known files: %v", err)
+	}
+	defer corpus.mapped.Close()
+
+	return d.DetectWithCorpus(ctx, targetFiles, corpus)
+}
+
+// openMapped opens the signature index as a corpus, reading the corpus
+// metadata from SignatureDir if set. An index built from another corpus
+// manifest than that of SignatureDir is stale.
+func (d *Detector) openMapped(ctx context.Context) (*Corpus, error) {
+	manifest := ""
+	if d.opts.SignatureDir != "" {
+		var err error
+		if manifest, err = d.readCorpusMetadata(); err != nil {
+			return nil, err
+		}
+	}
+
+	x, err := sigindex.Open(d.opts.SignatureIndex)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != "" && x.Manifest() != manifest {
+		x.Close()
+		return nil, fmt.Errorf("signature index %s was not built from the signatures in %s; rebuild it with db index",
+			d.opts.SignatureIndex, d.opts.SignatureDir)
+	}
+	if d.opts.CorpusManifest == "" {
+		d.opts.CorpusManifest = x.Manifest()
+	}
+	d.loadRepositories(ctx)
+
+	// Exact copies are only looked up for the digests the filter may hold
+	bloom := false
+	if d.opts.ExactFirst {
+		if bloom, err = x.LoadBloom(); err != nil {
+			logger.Warn("Ignoring Bloom filter of signature index, rebuild it with db index", zap.Error(err))
+		}
+	}
+
+	logger.Info("Opened signature index",
+		zap.String("index", d.opts.SignatureIndex),
+		zap.Int("files", x.Files()),
+		zap.Int("functions", x.Functions()),
+		zap.Bool("mapped", x.Mapped()),
+		zap.Bool("bloom", bloom))
+	return &Corpus{mapped: x}, nil
+}
+
+// detectMappedFile matches an analyzed target file against a signature
+// index. Only the known files and functions within the thresholds are
+// copied out of the index. With ExactFirst, the Bloom filter of the index
+// rules out exact copies of most targets without scanning its files.
+func (d *Detector) detectMappedFile(fileInfo *analyzer.FileInfo, x *sigindex.Index) *DetectionResult {
+	var similar []*analyzer.FileInfo
+	if d.opts.ExactFirst && fileInfo.Digest != "" {
+		x.FilesWithDigest(fileInfo.Digest, fileInfo.Path, func(i int) {
+			similar = append(similar, x.File(i))
+		})
+	}
+	if len(similar) == 0 && fileInfo.Hash != nil {
+		x.SimilarFiles(fileInfo.Hash, fileInfo.Language, fileInfo.Path, d.maxDistance(fileInfo.Language), func(i, _ int) {
+			similar = append(similar, x.File(i))
+		})
+	}
+
+	candidates := d.classifyCandidates(fileInfo, similar)
+	functions := d.matchMappedFunctions(fileInfo, x)
+	return d.newResult(fileInfo, candidates, functions, x.Files(), x.Components())
+}
+
+// matchMappedFunctions finds the known components and files containing
+// each function of a target file in a signature index, as matchFunctions
+// does for an in-memory index
+func (d *Detector) matchMappedFunctions(target *analyzer.FileInfo, x *sigindex.Index) []functionMatch {
+	if x.Components() == 0 {
+		return nil
+	}
+
+	matches := make([]functionMatch, len(target.Functions))
+	threshold := d.functionThreshold()
+	for i, fn := range target.Functions {
+		hash, err := tlsh.Parse(fn.Hash)
+		if err != nil {
+			continue
+		}
+		x.SimilarFunctions(hash, threshold, func(j, distance int) {
+			if d.common(x.FunctionComponents(j)) {
+				return
+			}
+			known := x.Function(j)
+			component := known.Component
+			if component == "" {
+				component = d.componentOf(known.File)
+			}
+			if component == "" {
+				return
+			}
+			matches[i].add(fn, &knownFunction{
+				component: component,
+				file:      known.File,
+				name:      known.Name,
+				scope:     known.Scope,
+				signature: known.Signature,
+				startLine: known.StartLine,
+				endLine:   known.EndLine,
+			}, distance)
+		})
+	}
+	return d.matchedFunctions(matches)
+}
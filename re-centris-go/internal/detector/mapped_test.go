@@ -0,0 +1,108 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"github.com/re-centris/re-centris-go/internal/sigindex"
+)
+
+func TestDetectMapped(t *testing.T) {
+	var source strings.Builder
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(&source, "int g%d(int x, int y)\n{\n", i)
+		for j := 0; j < 6; j++ {
+			fmt.Fprintf(&source, "    y = y * %d - x / %d;\n    if (y < %d) { x |= y >> %d; }\n", i+j, j+2, i*50+j, j%3)
+		}
+		source.WriteString("    return x - y;\n}\n\n")
+	}
+
+	corpus := t.TempDir()
+	target := filepath.Join(t.TempDir(), "copy.c")
+	for _, path := range []string{filepath.Join(corpus, "zlib", "lib.c"), filepath.Join(corpus, "png", "util.c"), target} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signatures := t.TempDir()
+	p := preprocessor.New(preprocessor.PreprocessorOptions{
+		MaxWorkers:         2,
+		OutputDir:          signatures,
+		Languages:          analyzer.DefaultLanguages(),
+		CheckpointInterval: 1000,
+		OutputFormat:       preprocessor.FormatSharded,
+		ShardSize:          1 << 20,
+	})
+	if err := p.ProcessDirectory(context.Background(), corpus); err != nil {
+		t.Fatal(err)
+	}
+
+	_, hash, err := manifest.Read(signatures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(signatures, sigindex.FileName)
+	w, err := sigindex.Create(path, sigindex.WriterOptions{Manifest: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = preprocessor.ReadShards(signatures, func(metadata *preprocessor.FileMetadata) error {
+		file, err := metadata.FileInfo()
+		if err != nil {
+			return err
+		}
+		return w.Add(file)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	detect := func(index string) ([]*DetectionResult, error) {
+		return New(DetectorOptions{
+			MaxWorkers:          2,
+			SimilarityThreshold: 0.8,
+			Languages:           analyzer.DefaultLanguages(),
+			SignatureDir:        signatures,
+			SignatureIndex:      index,
+		}).DetectSimilarity(context.Background(), []string{target})
+	}
+	want, err := detect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := detect(path)
+	if err != nil {
+		t.Fatalf("DetectSimilarity() with a signature index failed: %v", err)
+	}
+	if len(got) != 1 || len(got[0].Matches) != 2 || len(got[0].Components) != 2 {
+		t.Fatalf("got results %+v, want two matches and components", got)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results with a signature index = %+v, want %+v", got[0], want[0])
+	}
+
+	// Signatures changed after the index was built
+	m, _, _ := manifest.Read(signatures)
+	m.TotalFiles++
+	if _, err := manifest.Write(signatures, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := detect(path); err == nil || !strings.Contains(err.Error(), "db index") {
+		t.Errorf("DetectSimilarity() with a stale index = %v, want an error", err)
+	}
+}
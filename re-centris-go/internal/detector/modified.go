@@ -0,0 +1,86 @@
+package detector
+
+import (
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+)
+
+// ModifiedFunction is a target function similar but not identical to a
+// function of a known component, such as a locally patched copy
+type ModifiedFunction struct {
+	Function      string    `json:"function"`
+	Lines         LineRange `json:"lines"`
+	Component     string    `json:"component"`
+	KnownFile     string    `json:"known_file"`
+	KnownFunction string    `json:"known_function"`
+	KnownLines    LineRange `json:"known_lines"`
+	Distance      int       `json:"distance"`
+	// QualifiedFunction and KnownQualifiedFunction tell overloads apart,
+	// see Evidence
+	QualifiedFunction      string `json:"qualified_function,omitempty"`
+	KnownQualifiedFunction string `json:"known_qualified_function,omitempty"`
+}
+
+// qualifiedName returns the identity of a function, see parser.Identity,
+// or an empty string if its signature is not known
+func qualifiedName(name, scope, signature string) string {
+	if signature == "" {
+		return ""
+	}
+	return parser.Identity(name, scope, signature)
+}
+
+// modifiedFunctions returns the matched functions whose closest known
+// function lies in the modified distance band, ordered by line. Functions
+// identical to a known function, or to a function of any collected version
+// of its component, are unmodified copies.
+func (d *Detector) modifiedFunctions(functions []functionMatch) []ModifiedFunction {
+	if d.opts.ModifiedMaxDistance <= 0 {
+		return nil
+	}
+
+	var modified []ModifiedFunction
+	for _, fn := range functions {
+		var (
+			closest   functionHit
+			knownFile string
+			found     bool
+		)
+		for file, hit := range fn.files {
+			if !found || hit.distance < closest.distance || (hit.distance == closest.distance && file < knownFile) {
+				closest, knownFile, found = hit, file, true
+			}
+		}
+		if !found || closest.distance < d.opts.ModifiedMinDistance || closest.distance > d.opts.ModifiedMaxDistance {
+			continue
+		}
+		if closest.distance == 0 || d.inVersion(closest.component, fn.target.Hash) {
+			continue
+		}
+
+		modified = append(modified, ModifiedFunction{
+			Function:               fn.target.Name,
+			Lines:                  LineRange{Start: fn.target.StartLine, End: fn.target.EndLine},
+			Component:              closest.component,
+			KnownFile:              knownFile,
+			KnownFunction:          closest.name,
+			KnownLines:             LineRange{Start: closest.startLine, End: closest.endLine},
+			Distance:               closest.distance,
+			QualifiedFunction:      qualifiedName(fn.target.Name, fn.target.Scope, fn.target.Signature),
+			KnownQualifiedFunction: qualifiedName(closest.name, closest.scope, closest.signature),
+		})
+	}
+
+	sort.Slice(modified, func(i, j int) bool {
+		return modified[i].Lines.Start < modified[j].Lines.Start
+	})
+	return modified
+}
+
+// inVersion reports whether a function signature belongs to a collected
+// version of a component
+func (d *Detector) inVersion(component, hash string) bool {
+	s := d.versions[component]
+	return s != nil && len(s.Functions[hash]) > 0
+}
@@ -0,0 +1,48 @@
+package detector
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+)
+
+func TestModifiedFunctions(t *testing.T) {
+	body := strings.Repeat("    len = ssl_read_record(s, buf, len); if (len < 0) goto err;\n", 6)
+	var (
+		original = hashOf(t, "int ssl3_read_bytes(SSL *s, unsigned char *buf, int len) {\n"+body+"    return len;\n}")
+		patched  = hashOf(t, "int ssl3_read_bytes(SSL *s, unsigned char *buf, int len) {\n"+body+"    if (len > MAX) abort();\n    return len;\n}")
+		older    = hashOf(t, "int ssl3_read_bytes(SSL *s, unsigned char *buf, int len) {\n"+body+"    return len + 0;\n}")
+	)
+
+	knownDir := "/known"
+	d := New(DetectorOptions{KnownFilesDir: knownDir, ModifiedMinDistance: 1, ModifiedMaxDistance: 30})
+	index := d.buildComponentIndex([]*analyzer.FileInfo{{
+		Path:      filepath.Join(knownDir, "openssl", "s3_pkt.c"),
+		Functions: []parser.Function{{Name: "ssl3_read_bytes", StartLine: 100, EndLine: 120, Hash: original}},
+	}})
+	d.versions = map[string]*versions.Signatures{
+		"openssl": {Versions: []versions.Version{{Name: "1.0.2"}}, Functions: map[string][]int{older: {0}}},
+	}
+
+	target := &analyzer.FileInfo{
+		Path: "vendor/s3_pkt.c",
+		Functions: []parser.Function{
+			{Name: "ssl3_read_bytes", StartLine: 10, EndLine: 31, Hash: patched},
+			{Name: "copy", StartLine: 40, EndLine: 60, Hash: original},
+			{Name: "old_copy", StartLine: 70, EndLine: 90, Hash: older},
+		},
+	}
+	modified := d.modifiedFunctions(d.matchFunctions(context.Background(), target, index))
+	if len(modified) != 1 {
+		t.Fatalf("modifiedFunctions() = %+v, want only the patched function", modified)
+	}
+	m := modified[0]
+	if m.Function != "ssl3_read_bytes" || m.Component != "openssl" || m.KnownLines.Start != 100 || m.Distance == 0 {
+		t.Errorf("modifiedFunctions() = %+v, want ssl3_read_bytes of openssl", m)
+	}
+}
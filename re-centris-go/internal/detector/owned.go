@@ -0,0 +1,159 @@
+package detector
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/manifest"
+)
+
+// Handling of matches of owned components
+const (
+	// OwnedExclude drops matches of owned components
+	OwnedExclude = "exclude"
+	// OwnedLabel keeps matches of owned components, labelled as internal
+	OwnedLabel = "label"
+)
+
+// ParseOwnedMode checks an owned components mode, empty meaning OwnedExclude
+func ParseOwnedMode(mode string) error {
+	switch mode {
+	case "", OwnedExclude, OwnedLabel:
+		return nil
+	default:
+		return fmt.Errorf("unsupported owned components mode: %s", mode)
+	}
+}
+
+// isRepoURL reports whether an owned components entry is a repository URL
+// rather than a path prefix
+func isRepoURL(entry string) bool {
+	return strings.Contains(entry, "://") || strings.HasPrefix(entry, "git@")
+}
+
+// normalizeRepoURL reduces a repository URL to host and path, so that the
+// https and ssh URLs of a repository compare equal
+func normalizeRepoURL(url string) string {
+	url = strings.ToLower(strings.TrimSpace(url))
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	} else if strings.HasPrefix(url, "git@") {
+		url = strings.Replace(url, ":", "/", 1)
+	}
+	if i := strings.Index(url, "@"); i >= 0 && i < strings.Index(url+"/", "/") {
+		url = url[i+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+}
+
+// setOwned records the corpus repositories whose URL is an owned component
+func (d *Detector) setOwned(repos []manifest.Repository) {
+	urls := make(map[string]bool)
+	for _, entry := range d.opts.OwnedComponents {
+		if isRepoURL(entry) {
+			urls[normalizeRepoURL(entry)] = true
+		}
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	d.owned = make(map[string]bool)
+	for _, repo := range repos {
+		if repo.URL != "" && urls[normalizeRepoURL(repo.URL)] {
+			d.owned[repo.Name] = true
+		}
+	}
+	// Cloned repositories are stored in directories named author%name
+	for url := range urls {
+		parts := strings.Split(url, "/")
+		if len(parts) >= 3 {
+			d.owned[parts[len(parts)-2]+"%"+parts[len(parts)-1]] = true
+		}
+	}
+}
+
+// isOwned reports whether a known file belongs to an owned component, by
+// the repository URL of its component or by a path prefix matching the
+// file path or its path below the known files directory
+func (d *Detector) isOwned(file, component string) bool {
+	if component != "" && d.owned[component] {
+		return true
+	}
+
+	paths := []string{filepath.Clean(file)}
+	if rel, err := filepath.Rel(d.opts.KnownFilesDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		paths = append(paths, rel)
+	}
+	for _, entry := range d.opts.OwnedComponents {
+		if isRepoURL(entry) {
+			continue
+		}
+		prefix := filepath.Clean(entry)
+		for _, path := range paths {
+			if path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excludeOwned drops the candidates and function matches of owned
+// components
+func (d *Detector) excludeOwned(candidates []candidate, functions []functionMatch) ([]candidate, []functionMatch) {
+	kept := candidates[:0]
+	for _, c := range candidates {
+		if !d.isOwned(c.file, c.component) {
+			kept = append(kept, c)
+		}
+	}
+
+	keptFunctions := functions[:0]
+	for _, m := range functions {
+		for file, hit := range m.files {
+			if d.isOwned(file, hit.component) {
+				delete(m.files, file)
+			}
+		}
+		for component := range m.components {
+			if !hasHit(m, component) {
+				delete(m.components, component)
+			}
+		}
+		if len(m.components) > 0 {
+			keptFunctions = append(keptFunctions, m)
+		}
+	}
+	return kept, keptFunctions
+}
+
+// hasHit reports whether a function match still has a known file of a
+// component
+func hasHit(m functionMatch, component string) bool {
+	for _, hit := range m.files {
+		if hit.component == component {
+			return true
+		}
+	}
+	return false
+}
+
+// labelOwned marks the matches and components of owned components as
+// internal reuse
+func (d *Detector) labelOwned(result *DetectionResult) {
+	for i := range result.Matches {
+		m := &result.Matches[i]
+		m.Internal = d.isOwned(m.File, m.Component)
+	}
+	for i := range result.Components {
+		c := &result.Components[i]
+		c.Internal = d.owned[c.Component] || d.ownedByPath(c.Component)
+	}
+}
+
+// ownedByPath reports whether a path prefix covers a whole component
+func (d *Detector) ownedByPath(component string) bool {
+	return component != "" && d.isOwned(filepath.Join(d.opts.KnownFilesDir, component), "")
+}
@@ -0,0 +1,78 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+func TestOwnedComponents(t *testing.T) {
+	dir := t.TempDir()
+	knownDir := filepath.Join(dir, "known")
+	var targets []string
+	for i, component := range []string{"acme%app", "zlib"} {
+		var b strings.Builder
+		for j := 0; j < 20; j++ {
+			fmt.Fprintf(&b, "int %s_f%d(int x) { if (x > %d) return x * %d + 7; return x - %d; }\n", component[len(component)-3:], j, j*3+i, j+2, j*i)
+		}
+		for _, path := range []string{filepath.Join(knownDir, component, "lib.c"), filepath.Join(dir, "target", fmt.Sprintf("lib%d.c", i))} {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		targets = append(targets, filepath.Join(dir, "target", fmt.Sprintf("lib%d.c", i)))
+	}
+
+	detect := func(mode string) map[string][]Match {
+		d := New(DetectorOptions{
+			MaxWorkers:          2,
+			SimilarityThreshold: 0.8,
+			Languages:           analyzer.DefaultLanguages(),
+			KnownFilesDir:       knownDir,
+			OwnedComponents:     []string{"https://github.com/Acme/app.git"},
+			OwnedMode:           mode,
+		})
+		knownFiles, err := d.LoadKnownFiles(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := d.DetectWithKnownFiles(context.Background(), targets, knownFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches := make(map[string][]Match)
+		for _, result := range results {
+			matches[filepath.Base(result.TargetFile)] = result.Matches
+		}
+		return matches
+	}
+
+	excluded := detect(OwnedExclude)
+	if len(excluded["lib0.c"]) != 0 || len(excluded["lib1.c"]) != 1 {
+		t.Errorf("exclude mode matches = %+v, want only zlib", excluded)
+	}
+
+	labelled := detect(OwnedLabel)
+	if len(labelled["lib0.c"]) != 1 || !labelled["lib0.c"][0].Internal {
+		t.Errorf("label mode matches of lib0.c = %+v, want an internal match", labelled["lib0.c"])
+	}
+	if len(labelled["lib1.c"]) != 1 || labelled["lib1.c"][0].Internal {
+		t.Errorf("label mode matches of lib1.c = %+v, want an external match", labelled["lib1.c"])
+	}
+}
+
+func TestNormalizeRepoURL(t *testing.T) {
+	for _, url := range []string{"git@github.com:Acme/App.git", "ssh://git@github.com/acme/app", "https://github.com/acme/app/"} {
+		if got := normalizeRepoURL(url); got != "github.com/acme/app" {
+			t.Errorf("normalizeRepoURL(%q) = %q, want github.com/acme/app", url, got)
+		}
+	}
+}
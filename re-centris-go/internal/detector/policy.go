@@ -0,0 +1,151 @@
+package detector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fields that fail rules can test
+const (
+	// FieldSimilarity is the similarity of a match
+	FieldSimilarity = "similarity"
+	// FieldConfidence is the confidence of a match
+	FieldConfidence = "confidence"
+	// FieldDistance is the TLSH distance of a match
+	FieldDistance = "distance"
+	// FieldScore is the attribution score of a component
+	FieldScore = "score"
+	// FieldMatches is the number of matches of a target file
+	FieldMatches = "matches"
+	// FieldVulnerabilities is the number of vulnerabilities of a target file
+	FieldVulnerabilities = "vulnerabilities"
+	// FieldConflicts is the number of license conflicts of a target file
+	FieldConflicts = "conflicts"
+)
+
+// ruleOperators are the comparison operators of fail rules. Two-character
+// operators come first so that ">=" is not parsed as ">".
+var ruleOperators = []string{">=", "<=", "==", ">", "<"}
+
+// FailRule is a condition on detection results, such as similarity>=0.9,
+// that fails a run when any result satisfies it
+type FailRule struct {
+	Field    string
+	Operator string
+	Value    float64
+}
+
+// RuleViolation is a result that satisfies a fail rule
+type RuleViolation struct {
+	Rule       FailRule
+	TargetFile string
+	// Subject is the known file or component that satisfied the rule, if any
+	Subject string
+	Value   float64
+}
+
+// ParseFailRule parses a rule of the form <field><operator><value>
+func ParseFailRule(s string) (FailRule, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	for _, op := range ruleOperators {
+		i := strings.Index(s, op)
+		if i <= 0 {
+			continue
+		}
+
+		rule := FailRule{Field: s[:i], Operator: op}
+		switch rule.Field {
+		case FieldSimilarity, FieldConfidence, FieldDistance, FieldScore, FieldMatches, FieldVulnerabilities, FieldConflicts:
+		default:
+			return FailRule{}, fmt.Errorf("unknown field in fail rule %q: %s", s, rule.Field)
+		}
+
+		value, err := strconv.ParseFloat(s[i+len(op):], 64)
+		if err != nil {
+			return FailRule{}, fmt.Errorf("invalid value in fail rule %q: %v", s, err)
+		}
+		rule.Value = value
+		return rule, nil
+	}
+	return FailRule{}, fmt.Errorf("invalid fail rule %q: expected <field><operator><value>", s)
+}
+
+// String formats the rule as it is parsed
+func (r FailRule) String() string {
+	return r.Field + r.Operator + strconv.FormatFloat(r.Value, 'g', -1, 64)
+}
+
+// holds reports whether a value satisfies the rule
+func (r FailRule) holds(value float64) bool {
+	switch r.Operator {
+	case ">=":
+		return value >= r.Value
+	case "<=":
+		return value <= r.Value
+	case "==":
+		return value == r.Value
+	case ">":
+		return value > r.Value
+	case "<":
+		return value < r.Value
+	}
+	return false
+}
+
+// EvaluateRules returns every result satisfying one of the rules
+func EvaluateRules(results []*DetectionResult, rules []FailRule) []RuleViolation {
+	var violations []RuleViolation
+	for _, rule := range rules {
+		for _, result := range results {
+			violations = append(violations, rule.evaluate(result)...)
+		}
+	}
+	return violations
+}
+
+// evaluate returns the violations of the rule in a single result
+func (r FailRule) evaluate(result *DetectionResult) []RuleViolation {
+	var violations []RuleViolation
+	check := func(subject string, value float64) {
+		if r.holds(value) {
+			violations = append(violations, RuleViolation{
+				Rule:       r,
+				TargetFile: result.TargetFile,
+				Subject:    subject,
+				Value:      value,
+			})
+		}
+	}
+
+	switch r.Field {
+	case FieldSimilarity:
+		for _, match := range result.Matches {
+			check(match.File, match.Similarity)
+		}
+	case FieldConfidence:
+		for _, match := range result.Matches {
+			check(match.File, match.Confidence)
+		}
+	case FieldDistance:
+		for _, match := range result.Matches {
+			check(match.File, float64(match.Distance))
+		}
+	case FieldScore:
+		for _, component := range result.Components {
+			check(component.Component, component.Score)
+		}
+	case FieldMatches:
+		check("", float64(len(result.Matches)))
+	case FieldVulnerabilities:
+		check("", float64(len(result.Vulnerabilities)))
+	case FieldConflicts:
+		conflicts := 0
+		if result.License != nil {
+			conflicts = len(result.License.Conflicts)
+		}
+		check("", float64(conflicts))
+	}
+
+	return violations
+}
@@ -0,0 +1,47 @@
+package detector
+
+import "testing"
+
+func TestParseFailRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    FailRule
+		wantErr bool
+	}{
+		{rule: "similarity>=0.9", want: FailRule{Field: FieldSimilarity, Operator: ">=", Value: 0.9}},
+		{rule: "distance < 10", want: FailRule{Field: FieldDistance, Operator: "<", Value: 10}},
+		{rule: "conflicts>0", want: FailRule{Field: FieldConflicts, Operator: ">", Value: 0}},
+		{rule: "severity>=high", wantErr: true},
+		{rule: "similarity", wantErr: true},
+		{rule: ">=0.9", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFailRule(tt.rule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFailRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFailRule(%q) = %+v, want %+v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	results := []*DetectionResult{{
+		TargetFile: "src/inflate.c",
+		Matches: []Match{
+			{File: "/known/zlib/inflate.c", Similarity: 0.95},
+			{File: "/known/zlib/deflate.c", Similarity: 0.85},
+		},
+	}}
+
+	violations := EvaluateRules(results, []FailRule{
+		{Field: FieldSimilarity, Operator: ">=", Value: 0.9},
+		{Field: FieldMatches, Operator: ">", Value: 5},
+	})
+	if len(violations) != 1 || violations[0].Subject != "/known/zlib/inflate.c" {
+		t.Errorf("EvaluateRules() = %+v, want the inflate.c match", violations)
+	}
+}
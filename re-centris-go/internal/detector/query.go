@@ -0,0 +1,87 @@
+package detector
+
+import (
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+)
+
+// Kinds of a Neighbor
+const (
+	NeighborFile     = "file"
+	NeighborFunction = "function"
+)
+
+// Neighbor is a known file or function close to a queried signature
+type Neighbor struct {
+	Kind      string `json:"kind"`
+	Component string `json:"component,omitempty"`
+	File      string `json:"file"`
+	Function  string `json:"function,omitempty"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Hash      string `json:"hash"`
+	Distance  int    `json:"distance"`
+}
+
+// Nearest returns the known files and functions of a corpus closest to a
+// TLSH hash, nearest first, at most k of each kind if k is positive and
+// only those within maxDistance if it is positive. Functions common to
+// many components are not indexed, so they are never returned.
+func (d *Detector) Nearest(corpus *Corpus, hash *tlsh.TLSH, k, maxDistance int) (files, functions []Neighbor) {
+	hashes := make([]*tlsh.TLSH, len(corpus.files))
+	for i, file := range corpus.files {
+		hashes[i] = file.Hash
+	}
+	for i, distance := range tlsh.DistanceMany(hash, hashes) {
+		if distance < 0 || maxDistance > 0 && distance > maxDistance {
+			continue
+		}
+		file := corpus.files[i]
+		files = append(files, Neighbor{
+			Kind:      NeighborFile,
+			Component: d.knownComponent(file),
+			File:      file.Path,
+			Hash:      file.Hash.String(),
+			Distance:  distance,
+		})
+	}
+
+	for i, distance := range tlsh.DistanceMany(hash, corpus.index.hashes) {
+		if distance < 0 || maxDistance > 0 && distance > maxDistance {
+			continue
+		}
+		fn := &corpus.index.functions[i]
+		functions = append(functions, Neighbor{
+			Kind:      NeighborFunction,
+			Component: fn.component,
+			File:      fn.file,
+			Function:  fn.name,
+			StartLine: fn.startLine,
+			EndLine:   fn.endLine,
+			Hash:      fn.hash.String(),
+			Distance:  distance,
+		})
+	}
+
+	return nearestFirst(files, k), nearestFirst(functions, k)
+}
+
+// nearestFirst sorts neighbors by distance, then location, and keeps the
+// k nearest if k is positive
+func nearestFirst(neighbors []Neighbor, k int) []Neighbor {
+	sort.Slice(neighbors, func(i, j int) bool {
+		a, b := neighbors[i], neighbors[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.StartLine < b.StartLine
+	})
+	if k > 0 && len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
@@ -0,0 +1,56 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+func TestNearest(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "int f%d(int x) { if (x > %d) return x * %d + 7; return x - %d; }\n", i, i*3, i+2, i)
+	}
+	source := b.String()
+	rewritten := strings.ReplaceAll(source, "return x -", "x = x << 2; while (x > 100) x /= 3; return x +")
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{"zlib/lib.c": source, "other/lib.c": rewritten} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := New(DetectorOptions{
+		MaxWorkers:    2,
+		Languages:     analyzer.DefaultLanguages(),
+		KnownFilesDir: dir,
+	})
+	knownFiles, err := d.LoadKnownFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpus := d.NewCorpus(context.Background(), knownFiles)
+	hash := knownFiles[0].Hash
+	want := d.knownComponent(knownFiles[0])
+
+	files, _ := d.Nearest(corpus, hash, 0, 0)
+	if len(files) != 2 || files[0].Distance != 0 || files[0].Component != want || files[1].Distance == 0 {
+		t.Fatalf("nearest files = %+v, want %s at distance 0 and the other further", files, want)
+	}
+	if files, _ := d.Nearest(corpus, hash, 1, 0); len(files) != 1 || files[0].Component != want {
+		t.Errorf("nearest file = %+v, want only %s", files, want)
+	}
+	if files, _ := d.Nearest(corpus, hash, 0, files[1].Distance-1); len(files) != 1 {
+		t.Errorf("files within distance %d = %+v, want one", files[1].Distance-1, files)
+	}
+}
@@ -0,0 +1,247 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/jsonstream"
+)
+
+// ReadResults reads a detection result file, a JSON array or JSONL, and
+// calls fn for each result. Results are decoded one at a time, so files
+// larger than memory can be read.
+func ReadResults(path string, fn func(*DetectionResult) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open results: %v", err)
+	}
+	defer file.Close()
+
+	// Errors of fn are returned as they are; others mean a damaged file
+	var fnErr error
+	call := func(result *DetectionResult) error {
+		fnErr = fn(result)
+		return fnErr
+	}
+
+	reader := bufio.NewReader(file)
+	dec := json.NewDecoder(reader)
+	if isJSONL(reader) {
+		err = readJSONL(dec, call)
+	} else {
+		err = jsonstream.Array(dec, func() error {
+			var result DetectionResult
+			if err := dec.Decode(&result); err != nil {
+				return fmt.Errorf("failed to parse result: %v", err)
+			}
+			return call(&result)
+		})
+	}
+	if fnErr != nil {
+		return fmt.Errorf("failed to read results %s: %v", path, fnErr)
+	}
+	if err != nil {
+		return &fsutil.CorruptError{Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// isJSONL reports whether a result file starts with an object rather than
+// an array
+func isJSONL(reader *bufio.Reader) bool {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		reader.UnreadByte()
+		return b == '{'
+	}
+}
+
+// jsonWriter writes detection results to a JSON array one result at a time
+type jsonWriter struct {
+	file    *outputFile
+	writer  *bufio.Writer
+	written int
+}
+
+// newJSONWriter creates a JSON result file at path
+func newJSONWriter(path string) (*jsonWriter, error) {
+	file, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &jsonWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+	if _, err := w.writer.WriteString("["); err != nil {
+		abortFile(file)
+		return nil, fmt.Errorf("failed to write results: %v", err)
+	}
+
+	return w, nil
+}
+
+// Write appends a result to the file
+func (w *jsonWriter) Write(result *DetectionResult) error {
+	data, err := json.MarshalIndent(result, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %v", err)
+	}
+
+	separator := "\n  "
+	if w.written > 0 {
+		separator = ",\n  "
+	}
+	if _, err := w.writer.WriteString(separator); err != nil {
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+
+	w.written++
+	return nil
+}
+
+// Close terminates the JSON array and closes the file
+func (w *jsonWriter) Close() error {
+	closing := "]\n"
+	if w.written > 0 {
+		closing = "\n]\n"
+	}
+	if _, err := w.writer.WriteString(closing); err != nil {
+		abortFile(w.file)
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	return closeOutput(w.file, w.writer)
+}
+
+// outputFile is standard output, a file written atomically, which
+// replaces the file at its path only once complete, or a stream written
+// to its path as it goes
+type outputFile struct {
+	io.Writer
+	atomic *fsutil.AtomicFile
+	stream *os.File
+}
+
+// createOutput creates an output file, creating parent directories. The
+// path "-" writes to standard output.
+func createOutput(path string) (*outputFile, error) {
+	if path == "-" {
+		return &outputFile{Writer: os.Stdout}, nil
+	}
+
+	file, err := fsutil.CreateAtomic(path, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create results: %v", err)
+	}
+	return &outputFile{Writer: file, atomic: file}, nil
+}
+
+// createStream creates an output file written to its path directly, for
+// records readable while it is written. The path "-" writes to standard
+// output.
+func createStream(path string) (*outputFile, error) {
+	if path == "-" {
+		return &outputFile{Writer: os.Stdout}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create results: %v", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create results: %v", err)
+	}
+	return &outputFile{Writer: file, stream: file}, nil
+}
+
+// sync flushes writer and, if writes are synced, fsyncs a stream
+func (f *outputFile) sync(writer *bufio.Writer) error {
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if f.stream != nil && fsutil.Syncing() {
+		return f.stream.Sync()
+	}
+	return nil
+}
+
+// closeOutput flushes buffered output and closes the file
+func closeOutput(file *outputFile, writer *bufio.Writer) error {
+	if err := writer.Flush(); err != nil {
+		abortFile(file)
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	return closeFile(file)
+}
+
+// closeFile renames a complete output file other than standard output into
+// place
+func closeFile(file *outputFile) error {
+	if file.stream != nil {
+		if fsutil.Syncing() {
+			if err := file.stream.Sync(); err != nil {
+				file.stream.Close()
+				return fmt.Errorf("failed to close results: %v", err)
+			}
+		}
+		if err := file.stream.Close(); err != nil {
+			return fmt.Errorf("failed to close results: %v", err)
+		}
+		return nil
+	}
+	if file.atomic == nil {
+		return nil
+	}
+	if err := file.atomic.Commit(); err != nil {
+		return fmt.Errorf("failed to close results: %v", err)
+	}
+	return nil
+}
+
+// abortFile discards an output file that could not be completed, keeping
+// the previous file at its path. A stream keeps the records written.
+func abortFile(file *outputFile) {
+	if file.stream != nil {
+		file.stream.Close()
+	}
+	if file.atomic != nil {
+		file.atomic.Abort()
+	}
+}
+
+// MergeResults streams the results of several result files into a single file
+func MergeResults(outputPath string, inputPaths []string) (int, error) {
+	w, err := newJSONWriter(outputPath)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, path := range inputPaths {
+		if err := ReadResults(path, w.Write); err != nil {
+			w.Close()
+			return 0, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return w.written, nil
+}
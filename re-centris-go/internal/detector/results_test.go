@@ -0,0 +1,73 @@
+package detector
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeResults(t *testing.T) {
+	dir := t.TempDir()
+	d := New(DetectorOptions{})
+
+	first := filepath.Join(dir, "first.json")
+	if err := d.SaveResults([]*DetectionResult{
+		{TargetFile: "a.c", Matches: []Match{{File: "known/a.c", Similarity: 0.9, Distance: 10}}, MatchCount: 1},
+		{TargetFile: "b.c"},
+	}, first); err != nil {
+		t.Fatalf("SaveResults() error = %v", err)
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	if err := d.SaveResults(nil, empty); err != nil {
+		t.Fatalf("SaveResults() error = %v", err)
+	}
+
+	merged := filepath.Join(dir, "merged.json")
+	count, err := MergeResults(merged, []string{first, empty, first})
+	if err != nil {
+		t.Fatalf("MergeResults() error = %v", err)
+	}
+	if count != 4 {
+		t.Errorf("MergeResults() = %v, want 4", count)
+	}
+
+	var targets []string
+	err = ReadResults(merged, func(result *DetectionResult) error {
+		targets = append(targets, result.TargetFile)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadResults() error = %v", err)
+	}
+
+	want := []string{"a.c", "b.c", "a.c", "b.c"}
+	if len(targets) != len(want) {
+		t.Fatalf("ReadResults() read %v, want %v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("result %d target = %v, want %v", i, targets[i], want[i])
+		}
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	results := []*DetectionResult{{TargetFile: "b.c"}, {TargetFile: "a.c"}}
+	SortResults(results)
+	if results[0].TargetFile != "a.c" || results[1].TargetFile != "b.c" {
+		t.Errorf("SortResults() order = %s, %s", results[0].TargetFile, results[1].TargetFile)
+	}
+
+	matches := []Match{
+		{File: "known/z.c", Similarity: 0.9},
+		{File: "known/y.c", Similarity: 0.8},
+		{File: "known/x.c", Similarity: 0.9},
+	}
+	sortMatches(matches)
+	want := []string{"known/x.c", "known/z.c", "known/y.c"}
+	for i, m := range matches {
+		if m.File != want[i] {
+			t.Errorf("sortMatches()[%d] = %s, want %s", i, m.File, want[i])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/version"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// sarifRuleID identifies the rule reported for every match
+	sarifRuleID = "known-code-reuse"
+)
+
+// sarifLog is the root object of a SARIF file
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SaveSARIF saves detection results as a SARIF 2.1.0 log
+func (d *Detector) SaveSARIF(results []*DetectionResult, outputPath string) error {
+	return d.WriteResults(results, FormatSARIF, outputPath)
+}
+
+// sarifWriter collects detection results and writes them as a SARIF log on
+// Close. Each match becomes a result located in the target file, carrying
+// the matched known file, its component and the similarity score.
+type sarifWriter struct {
+	d    *Detector
+	path string
+	run  sarifRun
+}
+
+// newSARIFWriter creates a SARIF writer for path
+func (d *Detector) newSARIFWriter(path string) *sarifWriter {
+	return &sarifWriter{
+		d:    d,
+		path: path,
+		run: sarifRun{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:    "re-centris",
+					Version: version.Version,
+					Rules: []sarifRule{{
+						ID:               sarifRuleID,
+						Name:             "KnownCodeReuse",
+						ShortDescription: sarifMessage{Text: "File is similar to a file of a known open source component"},
+					}},
+				},
+			},
+			Results: []sarifResult{},
+		},
+	}
+}
+
+// Write adds the matches of a result to the log
+func (w *sarifWriter) Write(result *DetectionResult) error {
+	for _, match := range result.Matches {
+		w.run.Results = append(w.run.Results, w.d.sarifResult(result, match))
+	}
+	return nil
+}
+
+// Close writes the log
+func (w *sarifWriter) Close() error {
+	data, err := json.MarshalIndent(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{w.run},
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SARIF log: %v", err)
+	}
+
+	// Create parent directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %v", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(w.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write SARIF log: %v", err)
+	}
+
+	return nil
+}
+
+// sarifResult converts a match of a target file into a SARIF result
+func (d *Detector) sarifResult(result *DetectionResult, match Match) sarifResult {
+	component := d.matchComponent(match)
+
+	properties := map[string]interface{}{
+		"knownFile":  match.File,
+		"similarity": match.Similarity,
+		"confidence": match.Confidence,
+		"distance":   match.Distance,
+	}
+	if component != "" {
+		properties["component"] = component
+	}
+	if match.Explanation != nil {
+		properties["explanation"] = match.Explanation.Summary
+	}
+
+	location := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(result.TargetFile)},
+	}
+	// Matches with function evidence point to the first matched function
+	if len(match.Evidence) > 0 {
+		lines := match.TargetLines()
+		location.Region = &sarifRegion{StartLine: lines.Start, EndLine: lines.End}
+	}
+
+	return sarifResult{
+		RuleID:     sarifRuleID,
+		Level:      sarifLevel(match.Similarity),
+		Message:    sarifMessage{Text: d.MatchMessage(match)},
+		Locations:  []sarifLocation{{PhysicalLocation: location}},
+		Properties: properties,
+	}
+}
+
+// MatchMessage describes a match in one sentence
+func (d *Detector) MatchMessage(match Match) string {
+	if component := d.matchComponent(match); component != "" {
+		return fmt.Sprintf("Similar to known file %s of component %s (similarity %.2f)",
+			match.File, component, match.Similarity)
+	}
+	return fmt.Sprintf("Similar to known file %s (similarity %.2f)", match.File, match.Similarity)
+}
+
+// sarifLevel maps a similarity score to a SARIF level; near-identical
+// copies are reported as warnings, weaker matches as notes
+func sarifLevel(similarity float64) string {
+	if similarity >= 0.95 {
+		return "warning"
+	}
+	return "note"
+}
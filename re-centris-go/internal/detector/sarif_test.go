@@ -0,0 +1,60 @@
+package detector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveSARIF(t *testing.T) {
+	d := New(DetectorOptions{KnownFilesDir: "/known"})
+	results := []*DetectionResult{
+		{
+			TargetFile: filepath.Join("src", "inflate.c"),
+			Matches: []Match{
+				{File: "/known/zlib/inflate.c", Similarity: 0.98, Distance: 2, Evidence: []Evidence{
+					{Function: "inflate", TargetLines: LineRange{Start: 10, End: 42}},
+				}},
+				{File: "/known/other.c", Similarity: 0.85, Distance: 15},
+			},
+		},
+		{TargetFile: "src/main.c"},
+	}
+
+	path := filepath.Join(t.TempDir(), "results.sarif")
+	if err := d.SaveSARIF(results, path); err != nil {
+		t.Fatalf("SaveSARIF() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read SARIF log: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("failed to parse SARIF log: %v", err)
+	}
+
+	if log.Version != sarifVersion || len(log.Runs) != 1 {
+		t.Fatalf("SARIF log version = %v with %d runs, want %v with 1 run", log.Version, len(log.Runs), sarifVersion)
+	}
+	got := log.Runs[0].Results
+	if len(got) != 2 {
+		t.Fatalf("SARIF results = %d, want 2", len(got))
+	}
+
+	first := got[0]
+	if uri := first.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "src/inflate.c" {
+		t.Errorf("location uri = %v, want src/inflate.c", uri)
+	}
+	if region := first.Locations[0].PhysicalLocation.Region; region == nil || region.StartLine != 10 || region.EndLine != 42 {
+		t.Errorf("location region = %+v, want lines 10-42", region)
+	}
+	if first.Level != "warning" || first.Properties["component"] != "zlib" {
+		t.Errorf("first result level = %v, component = %v, want warning, zlib", first.Level, first.Properties["component"])
+	}
+	if _, ok := got[1].Properties["component"]; ok || got[1].Level != "note" {
+		t.Errorf("second result = %+v, want note without component", got[1])
+	}
+}
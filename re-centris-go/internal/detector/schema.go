@@ -0,0 +1,66 @@
+package detector
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/common/jsonschema"
+	"github.com/re-centris/re-centris-go/internal/common/jsonstream"
+)
+
+// SchemaVersion is the version of the detection result schema. It changes
+// whenever a field is removed or its meaning changes; fields may be added
+// without a version change.
+const SchemaVersion = "1.0"
+
+// ResultSchema is the JSON Schema of a detection result file
+//
+//go:embed results.schema.json
+var ResultSchema []byte
+
+// ValidateResults checks a detection result file against ResultSchema and
+// returns every violation found. Results are validated one at a time, so
+// files larger than memory can be checked.
+func ValidateResults(path string) ([]string, error) {
+	schema, err := jsonschema.Compile(ResultSchema)
+	if err != nil {
+		return nil, err
+	}
+	result, err := schema.Def("result")
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open results: %v", err)
+	}
+	defer file.Close()
+
+	var (
+		violations []string
+		index      int
+	)
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+	dec.UseNumber()
+	err = jsonstream.Array(dec, func() error {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("failed to parse result: %v", err)
+		}
+		for _, violation := range result.ValidateValue(v) {
+			violations = append(violations, fmt.Sprintf("result %d: %s", index, violation))
+		}
+		index++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results %s: %v", path, err)
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,67 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateResults(t *testing.T) {
+	d := New(DetectorOptions{KnownFilesDir: "/known"})
+	results := []*DetectionResult{
+		{
+			SchemaVersion: SchemaVersion,
+			TargetFile:    "src/inflate.c",
+			Matches: []Match{{
+				File:       "/known/zlib/inflate.c",
+				Similarity: 0.98,
+				Distance:   2,
+				Evidence: []Evidence{{
+					Function:      "inflate",
+					TargetLines:   LineRange{Start: 10, End: 40},
+					KnownFunction: "inflate",
+					KnownLines:    LineRange{Start: 12, End: 42},
+					Distance:      3,
+				}},
+				Explanation: &Explanation{Reason: ReasonTLSHDistance, Distance: 2, MaxDistance: 20},
+			}},
+			TotalFiles: 10,
+			MatchCount: 1,
+			Components: []ComponentMatch{{Component: "zlib", MatchedFunctions: 3, Score: 1}},
+			License:    &LicenseInfo{Components: map[string]string{"zlib": "Zlib"}},
+		},
+	}
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "results.json")
+	if err := d.SaveResults(results, valid); err != nil {
+		t.Fatalf("SaveResults() error = %v", err)
+	}
+	violations, err := ValidateResults(valid)
+	if err != nil {
+		t.Fatalf("ValidateResults() error = %v", err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("ValidateResults() = %v, want no violations", violations)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	data := `[{"schema_version": "0.1", "target_file": "a.c", "matches": [{"file": "b.c", "similarity": "high", "distance": 1}], "total_files": 1}]`
+	if err := os.WriteFile(invalid, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	violations, err = ValidateResults(invalid)
+	if err != nil {
+		t.Fatalf("ValidateResults() error = %v", err)
+	}
+	want := []string{"match_count", "schema_version", "similarity"}
+	if len(violations) != len(want) {
+		t.Fatalf("ValidateResults() = %v, want %d violations", violations, len(want))
+	}
+	for _, field := range want {
+		if !strings.Contains(strings.Join(violations, "\n"), field) {
+			t.Errorf("ValidateResults() = %v, want a violation for %s", violations, field)
+		}
+	}
+}
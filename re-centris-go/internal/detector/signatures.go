@@ -0,0 +1,137 @@
+package detector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"go.uber.org/zap"
+)
+
+// loadSignatures loads known files from the sharded preprocessor output
+// instead of analyzing the known files directory
+func (d *Detector) loadSignatures() ([]*analyzer.FileInfo, error) {
+	var files []*analyzer.FileInfo
+	err := d.readSignatures(func(file *analyzer.FileInfo) error {
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Shards hold records in the order the preprocessor finished them
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, nil
+}
+
+// readSignatures calls fn for every known file of the sharded preprocessor
+// output, in shard order
+func (d *Detector) readSignatures(fn func(*analyzer.FileInfo) error) error {
+	if _, err := d.readCorpusMetadata(); err != nil {
+		return err
+	}
+
+	err := preprocessor.ReadShards(d.opts.SignatureDir, func(metadata *preprocessor.FileMetadata) error {
+		file, err := metadata.FileInfo()
+		if err != nil {
+			return err
+		}
+		return fn(file)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read signatures: %v", err)
+	}
+	return nil
+}
+
+// readCorpusMetadata reads the corpus manifest, function frequency and
+// version signatures of the signature directory. It returns the hash of
+// the manifest, or "" if there is none.
+func (d *Detector) readCorpusMetadata() (string, error) {
+	// Stamp results with the manifest of the corpus the signatures belong
+	// to, and take the component repositories from it
+	m, hash, err := manifest.Read(d.opts.SignatureDir)
+	switch {
+	case err == nil:
+		if err := d.checkManifest(m); err != nil {
+			return "", err
+		}
+		if d.opts.CorpusManifest == "" {
+			d.opts.CorpusManifest = hash
+		}
+		d.setRepositories(m.Repositories)
+	case d.opts.CorpusManifest == "":
+		logger.Warn("Signatures have no corpus manifest",
+			zap.String("dir", d.opts.SignatureDir),
+			zap.Error(err))
+	}
+
+	// Common functions are dropped from the component index
+	var corrupt *fsutil.CorruptError
+	if frequency, err := preprocessor.ReadFunctionFrequency(d.opts.SignatureDir); err == nil {
+		d.frequency = frequency.Functions
+	} else if errors.As(err, &corrupt) {
+		logger.Warn("Skipping corrupt function frequency", zap.Error(err))
+	} else {
+		logger.Debug("Signatures have no function frequency",
+			zap.String("dir", d.opts.SignatureDir),
+			zap.Error(err))
+	}
+
+	// Version signatures identify the versions of matched components
+	if signatures, err := versions.Read(d.opts.SignatureDir); err == nil {
+		d.versions = signatures
+	} else {
+		logger.Warn("Failed to read version signatures",
+			zap.String("dir", d.opts.SignatureDir),
+			zap.Error(err))
+	}
+	return hash, nil
+}
+
+// describeEncoding names an encoding key, see analyzer.EncodingKey
+func describeEncoding(key string) string {
+	if key == "" {
+		return analyzer.EncodingRaw
+	}
+	return key
+}
+
+// checkManifest reports an error if the signatures of a corpus were hashed
+// with other settings than the targets, since distances between them would
+// be meaningless. Normalization does not apply in token mode.
+func (d *Detector) checkManifest(m *manifest.CorpusManifest) error {
+	mode, _ := analyzer.ParseSignatureMode(d.opts.SignatureMode)
+	corpusMode, _ := analyzer.ParseSignatureMode(m.SignatureMode)
+	if mode != corpusMode {
+		return fmt.Errorf("signatures in %s were built with signature mode %s, but detection uses %s; set signature_mode to match",
+			d.opts.SignatureDir, corpusMode, mode)
+	}
+
+	if mode == analyzer.SignatureBytes && m.Normalization != "" {
+		if normalization := normalize.Describe(d.opts.Normalize); normalization != m.Normalization {
+			return fmt.Errorf("signatures in %s were built with normalization %s, but detection uses %s; set normalize to match",
+				d.opts.SignatureDir, m.Normalization, normalization)
+		}
+	}
+
+	// Only sources that are not UTF-8 hash differently, so this is no error
+	if encoding := analyzer.EncodingKey(d.opts.Encoding); encoding != m.Encoding {
+		logger.Warn("Signatures were built with another source encoding, re-run preprocess to match non-UTF-8 sources",
+			zap.String("signatures", d.opts.SignatureDir),
+			zap.String("corpus_encoding", describeEncoding(m.Encoding)),
+			zap.String("encoding", describeEncoding(encoding)))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+)
+
+func TestCheckManifest(t *testing.T) {
+	stripped := map[string]normalize.Options{"cpp": {StripComments: true}}
+
+	tests := []struct {
+		name     string
+		opts     DetectorOptions
+		manifest manifest.CorpusManifest
+		wantErr  bool
+	}{
+		{"raw", DetectorOptions{}, manifest.CorpusManifest{Normalization: "none"}, false},
+		{"older manifest", DetectorOptions{}, manifest.CorpusManifest{}, false},
+		{"same normalization", DetectorOptions{Normalize: stripped}, manifest.CorpusManifest{Normalization: normalize.Describe(stripped)}, false},
+		{"normalized corpus", DetectorOptions{}, manifest.CorpusManifest{Normalization: normalize.Describe(stripped)}, true},
+		{"normalized targets", DetectorOptions{Normalize: stripped}, manifest.CorpusManifest{Normalization: "none"}, true},
+		{"token corpus", DetectorOptions{}, manifest.CorpusManifest{SignatureMode: analyzer.SignatureTokens}, true},
+		{"tokens ignore normalization", DetectorOptions{SignatureMode: analyzer.SignatureTokens, Normalize: stripped},
+			manifest.CorpusManifest{Normalization: "none", SignatureMode: analyzer.SignatureTokens}, false},
+	}
+	for _, tt := range tests {
+		err := New(tt.opts).checkManifest(&tt.manifest)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkManifest() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSignaturesRecordComponents(t *testing.T) {
+	var source strings.Builder
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(&source, "int f%d(int x, int y)\n{\n", i)
+		for j := 0; j < 6; j++ {
+			fmt.Fprintf(&source, "    x = x * %d + y / %d;\n    if (x > %d) { y ^= x << %d; }\n", i+j, j+1, i*100+j, j%5)
+		}
+		source.WriteString("    return x + y;\n}\n\n")
+	}
+
+	corpus := t.TempDir()
+	known := filepath.Join(corpus, "acme%lib", "lib.c")
+	target := filepath.Join(t.TempDir(), "copy.c")
+	for _, path := range []string{known, target} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signatures := t.TempDir()
+	p := preprocessor.New(preprocessor.PreprocessorOptions{
+		MaxWorkers:         2,
+		OutputDir:          signatures,
+		Languages:          analyzer.DefaultLanguages(),
+		CheckpointInterval: 1000,
+		OutputFormat:       preprocessor.FormatSharded,
+		ShardSize:          1 << 20,
+	})
+	if err := p.ProcessDirectory(context.Background(), corpus); err != nil {
+		t.Fatal(err)
+	}
+
+	// The known files directory does not match the preprocessed corpus
+	d := New(DetectorOptions{
+		MaxWorkers:          2,
+		SimilarityThreshold: 0.8,
+		FunctionThreshold:   30,
+		Languages:           analyzer.DefaultLanguages(),
+		KnownFilesDir:       "./known-files",
+		SignatureDir:        signatures,
+	})
+	results, err := d.DetectSimilarity(context.Background(), []string{target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 1 {
+		t.Fatalf("got results %+v, want one match", results)
+	}
+	if got := results[0].Matches[0].Component; got != "acme%lib" {
+		t.Errorf("match component = %q, want acme%%lib", got)
+	}
+	if len(results[0].Components) != 1 || results[0].Components[0].Component != "acme%lib" {
+		t.Errorf("got components %+v, want acme%%lib", results[0].Components)
+	}
+}
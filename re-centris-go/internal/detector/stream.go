@@ -0,0 +1,226 @@
+package detector
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"github.com/re-centris/re-centris-go/internal/index"
+)
+
+// defaultStreamMatches is the number of matches kept per target file in
+// streaming detection when MaxMatchesPerFile is not set
+const defaultStreamMatches = 100
+
+// candidateHeap is a min-heap of candidates ordered from the worst match,
+// the one with the largest distance and, among equal distances, the last
+// known file
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int { return len(h) }
+func (h candidateHeap) Less(i, j int) bool {
+	if h[i].distance != h[j].distance {
+		return h[i].distance > h[j].distance
+	}
+	return h[i].file > h[j].file
+}
+func (h candidateHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *candidateHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// streamTarget accumulates the matches of a target file over the batches
+// of known files
+type streamTarget struct {
+	file      *analyzer.FileInfo
+	top       candidateHeap
+	functions []functionMatch
+}
+
+// add keeps a candidate if it is among the best limit candidates so far
+func (t *streamTarget) add(c candidate, limit int) {
+	if len(t.top) < limit {
+		heap.Push(&t.top, c)
+		return
+	}
+	worst := t.top[0]
+	if c.distance < worst.distance || c.distance == worst.distance && c.file < worst.file {
+		t.top[0] = c
+		heap.Fix(&t.top, 0)
+	}
+}
+
+// detectStreaming detects target files against known files read in batches
+// of BatchSize, so that only one batch of known files is held in memory
+// together with the best matches of each target
+func (d *Detector) detectStreaming(ctx context.Context, targetFiles []string) ([]*DetectionResult, error) {
+	var targets []*streamTarget
+	for _, targetFile := range targetFiles {
+		fileInfos, err := d.analyzeTarget(ctx, targetFile)
+		if err != nil {
+			return nil, fmt.Errorf("error while detecting similarities: %v", err)
+		}
+		for _, fileInfo := range fileInfos {
+			targets = append(targets, &streamTarget{file: fileInfo})
+		}
+	}
+
+	limit := d.opts.MaxMatchesPerFile
+	if limit <= 0 {
+		limit = defaultStreamMatches
+	}
+
+	total := 0
+	components := make(map[string]struct{})
+	stage := d.opts.Progress.Stage("detect", 0)
+
+	err := d.streamKnownFiles(ctx, func(batch []*analyzer.FileInfo) error {
+		stage.AddTotal(len(batch))
+		defer stage.Add(len(batch))
+
+		total += len(batch)
+		index := d.buildComponentIndex(batch)
+		digests := digestIndex(batch)
+		for name := range index.names {
+			components[name] = struct{}{}
+		}
+
+		// Each target is only updated by its own worker
+		g, ctx := workpool.WithContext(ctx, d.opts.Pool, max(d.opts.MaxWorkers, 1))
+		for _, t := range targets {
+			t := t
+			g.Go(func() error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				for _, c := range d.candidates(ctx, t.file, batch, digests) {
+					t.add(c, limit)
+				}
+				if index.components > 0 {
+					t.functions = d.addFunctionMatches(ctx, t.functions, t.file, index)
+				}
+				return nil
+			})
+		}
+		return g.Wait()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while detecting similarities: %v", err)
+	}
+	stage.Done()
+
+	d.loadRepositories(ctx)
+
+	var results []*DetectionResult
+	for _, t := range targets {
+		result := d.newResult(t.file, []candidate(t.top), d.matchedFunctions(t.functions), total, len(components))
+		if d.opts.Output == nil {
+			results = append(results, result)
+		} else if err := d.opts.Output.Write(result); err != nil {
+			return nil, err
+		}
+		t.top, t.functions = nil, nil
+	}
+
+	SortResults(results)
+	markDuplicates(results)
+	d.limitTopK(results)
+	return results, nil
+}
+
+// streamKnownFiles calls fn with the known files in batches of BatchSize,
+// read from the signature directory, the index or, analyzing them batch by
+// batch, the known files directory
+func (d *Detector) streamKnownFiles(ctx context.Context, fn func([]*analyzer.FileInfo) error) error {
+	size := d.opts.BatchSize
+	batch := make([]*analyzer.FileInfo, 0, size)
+	add := func(file *analyzer.FileInfo) error {
+		batch = append(batch, file)
+		if len(batch) < size {
+			return nil
+		}
+		err := fn(batch)
+		batch = make([]*analyzer.FileInfo, 0, size)
+		return err
+	}
+
+	var err error
+	switch {
+	case d.opts.SignatureDir != "":
+		err = d.readSignatures(add)
+	case d.opts.IndexPath != "":
+		// The index is read as is; index build updates it
+		_, err = index.Read(d.opts.IndexPath, func(entry *index.Entry) error {
+			file, err := entry.FileInfo()
+			if err != nil {
+				return err
+			}
+			return add(file)
+		})
+	default:
+		err = d.analyzeKnownFiles(ctx, size, add)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// analyzeKnownFiles analyzes the known files directory in batches of size
+// files, calling fn for every analyzed file
+func (d *Detector) analyzeKnownFiles(ctx context.Context, size int, fn func(*analyzer.FileInfo) error) error {
+	var paths []string
+	err := d.analyzer.WalkDirectory(ctx, d.opts.KnownFilesDir, func(path string, info os.FileInfo) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(paths); start += size {
+		chunk := paths[start:min(start+size, len(paths))]
+		files := make([]*analyzer.FileInfo, len(chunk))
+
+		g, ctx := workpool.WithContext(ctx, d.opts.Pool, max(d.opts.MaxWorkers, 1))
+		for i, path := range chunk {
+			i, path := i, path
+			g.Go(func() error {
+				file, err := d.analyzer.AnalyzeFile(ctx, path)
+				if err != nil {
+					if analyzer.IsSkipped(err) {
+						return nil
+					}
+					return err
+				}
+				files[i] = file
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if file == nil {
+				continue
+			}
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// source returns C source long enough to be hashed, varying with n
+func source(n int) []byte {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "int helper_%d(int x) { return x * %d + %d; }\n", i, i, i*7)
+	}
+	fmt.Fprintf(&b, "int variant(void) { return %d; }\n", n)
+	return []byte(b.String())
+}
+
+func TestDetectStreaming(t *testing.T) {
+	known := t.TempDir()
+	for i := 0; i < 5; i++ {
+		dir := filepath.Join(known, fmt.Sprintf("comp%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "x.c"), source(i), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := filepath.Join(t.TempDir(), "target.c")
+	if err := os.WriteFile(target, source(0), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DetectorOptions{
+		MaxWorkers:          2,
+		SimilarityThreshold: 0.5,
+		KnownFilesDir:       known,
+		Languages:           map[string][]string{"cpp": {".c"}},
+	}
+	want, err := New(opts).DetectSimilarity(context.Background(), []string{target})
+	if err != nil {
+		t.Fatalf("DetectSimilarity() error = %v", err)
+	}
+	if len(want) != 1 || len(want[0].Matches) < 2 {
+		t.Fatalf("DetectSimilarity() = %+v, want several matches", want)
+	}
+
+	opts.BatchSize = 2
+	got, err := New(opts).DetectSimilarity(context.Background(), []string{target})
+	if err != nil {
+		t.Fatalf("DetectSimilarity() streaming error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("streaming results differ:\n got %+v\nwant %+v", got[0], want[0])
+	}
+
+	// Only the best matches are kept per target
+	opts.MaxMatchesPerFile = 1
+	got, err = New(opts).DetectSimilarity(context.Background(), []string{target})
+	if err != nil {
+		t.Fatalf("DetectSimilarity() streaming error = %v", err)
+	}
+	if len(got[0].Matches) != 1 || got[0].Matches[0].File != want[0].Matches[0].File {
+		t.Errorf("streaming matches = %+v, want the best match only", got[0].Matches)
+	}
+}
@@ -0,0 +1,135 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tableColumns are the columns of the CSV and Markdown exports
+var tableColumns = []string{"target_file", "known_file", "component", "purl", "similarity", "distance", "explanation", "confidence"}
+
+// tableRow returns the cells of a match row
+func (d *Detector) tableRow(result *DetectionResult, match Match) []string {
+	explanation := ""
+	if match.Explanation != nil {
+		explanation = match.Explanation.Summary
+	}
+
+	return []string{
+		result.TargetFile,
+		match.File,
+		d.matchComponent(match),
+		match.PURL,
+		strconv.FormatFloat(match.Similarity, 'f', 4, 64),
+		strconv.Itoa(match.Distance),
+		explanation,
+		strconv.FormatFloat(match.Confidence, 'f', 3, 64),
+	}
+}
+
+// csvWriter writes one CSV row per match
+type csvWriter struct {
+	d    *Detector
+	file *outputFile
+	csv  *csv.Writer
+}
+
+// newCSVWriter creates a CSV result file at path
+func (d *Detector) newCSVWriter(path string) (*csvWriter, error) {
+	file, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &csvWriter{d: d, file: file, csv: csv.NewWriter(file)}
+	if err := w.csv.Write(tableColumns); err != nil {
+		abortFile(file)
+		return nil, fmt.Errorf("failed to write results: %v", err)
+	}
+	return w, nil
+}
+
+// Write appends the matches of a result
+func (w *csvWriter) Write(result *DetectionResult) error {
+	for _, match := range result.Matches {
+		if err := w.csv.Write(w.d.tableRow(result, match)); err != nil {
+			return fmt.Errorf("failed to write results: %v", err)
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes the file
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		abortFile(w.file)
+		return fmt.Errorf("failed to write results: %v", err)
+	}
+	return closeFile(w.file)
+}
+
+// markdownWriter writes a Markdown table with one row per match
+type markdownWriter struct {
+	d      *Detector
+	file   *outputFile
+	writer *bufio.Writer
+}
+
+// newMarkdownWriter creates a Markdown result file at path
+func (d *Detector) newMarkdownWriter(path string) (*markdownWriter, error) {
+	file, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &markdownWriter{d: d, file: file, writer: bufio.NewWriter(file)}
+
+	header := []string{"Target file", "Known file", "Component", "Package URL", "Similarity", "Distance", "Explanation", "Confidence"}
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	w.row(header)
+	w.row(separator)
+
+	return w, nil
+}
+
+// Write appends the matches of a result
+func (w *markdownWriter) Write(result *DetectionResult) error {
+	for _, match := range result.Matches {
+		cells := w.d.tableRow(result, match)
+		for i, cell := range cells {
+			cells[i] = markdownEscape(cell)
+		}
+		// Code spans keep paths with underscores from being formatted
+		for _, i := range []int{0, 1} {
+			if cells[i] != "" {
+				cells[i] = "`" + cells[i] + "`"
+			}
+		}
+		w.row(cells)
+	}
+	return nil
+}
+
+// Close flushes and closes the file
+func (w *markdownWriter) Close() error {
+	return closeOutput(w.file, w.writer)
+}
+
+// row writes a table row; write errors surface on Close
+func (w *markdownWriter) row(cells []string) {
+	w.writer.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+}
+
+// markdownEscape escapes characters that would break a table cell
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "`", "'")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,63 @@
+package detector
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteResultsTables(t *testing.T) {
+	d := New(DetectorOptions{KnownFilesDir: "/known"})
+	results := []*DetectionResult{
+		{
+			TargetFile: "src/inflate.c",
+			Matches: []Match{
+				{File: "/known/zlib/inflate.c", Similarity: 0.98, Confidence: 0.9, Distance: 2, Explanation: &Explanation{Summary: "a|b"}},
+			},
+		},
+		{TargetFile: "src/main.c"},
+	}
+	dir := t.TempDir()
+
+	csvPath := filepath.Join(dir, "results.csv")
+	if err := d.WriteResults(results, FormatCSV, csvPath); err != nil {
+		t.Fatalf("WriteResults(csv) error = %v", err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("CSV rows = %d, want header and 1 match", len(records))
+	}
+	if got := records[1]; got[2] != "zlib" || got[4] != "0.9800" || got[6] != "a|b" || got[7] != "0.900" {
+		t.Errorf("CSV row = %v, want component zlib, similarity 0.9800, confidence 0.900, explanation a|b", got)
+	}
+
+	mdPath := filepath.Join(dir, "results.md")
+	if err := d.WriteResults(results, FormatMarkdown, mdPath); err != nil {
+		t.Fatalf("WriteResults(markdown) error = %v", err)
+	}
+	data, err := os.ReadFile(mdPath)
+	if err != nil {
+		t.Fatalf("failed to read Markdown: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Markdown lines = %d, want 3", len(lines))
+	}
+	if !strings.Contains(lines[2], `a\|b`) {
+		t.Errorf("Markdown row = %q, want escaped pipe", lines[2])
+	}
+
+	if err := d.WriteResults(results, "xml", filepath.Join(dir, "results.xml")); err == nil {
+		t.Error("WriteResults(xml) error = nil, want unsupported format")
+	}
+}
@@ -0,0 +1,97 @@
+package detector
+
+import (
+	"math"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+)
+
+// ComponentVersion is the most likely version, or range of versions, of a
+// matched component, estimated from the version signatures of the corpus
+type ComponentVersion struct {
+	// Range names the versions, e.g. "1.1.1k" or "1.1.1k–1.1.1m"
+	Range     string    `json:"range"`
+	First     string    `json:"first"`
+	FirstDate time.Time `json:"first_date"`
+	Last      string    `json:"last"`
+	LastDate  time.Time `json:"last_date"`
+	// MatchedFunctions is the number of matched functions found in any
+	// version of the component
+	MatchedFunctions int `json:"matched_functions"`
+}
+
+// withVersions estimates the versions of component matches from the target
+// functions attributed to each component
+func (d *Detector) withVersions(matches []ComponentMatch, functions []functionMatch) []ComponentMatch {
+	if len(d.versions) == 0 {
+		return matches
+	}
+	for i := range matches {
+		s := d.versions[matches[i].Component]
+		if s == nil {
+			continue
+		}
+		var hashes []string
+		for _, m := range functions {
+			if _, ok := m.components[matches[i].Component]; ok {
+				hashes = append(hashes, m.target.Hash)
+			}
+		}
+		matches[i].Version = estimateVersion(s, hashes)
+	}
+	return matches
+}
+
+// estimateVersion scores every version of a component by the function
+// signatures it shares with the target. Like components, each function is
+// weighted by the inverse of how many versions contain it, so functions
+// unique to a few versions decide between them. The versions tied for the
+// best score form the reported range.
+func estimateVersion(s *versions.Signatures, hashes []string) *ComponentVersion {
+	scores := make([]float64, len(s.Versions))
+	matched := 0
+	for _, hash := range hashes {
+		indices := s.Functions[hash]
+		if len(indices) == 0 {
+			continue
+		}
+		matched++
+		weight := inverseComponentFrequency(len(s.Versions), len(indices))
+		for _, i := range indices {
+			if i < len(scores) {
+				scores[i] += weight
+			}
+		}
+	}
+	if matched == 0 {
+		return nil
+	}
+
+	best := 0.0
+	for _, score := range scores {
+		best = math.Max(best, score)
+	}
+	first, last := -1, -1
+	for i, score := range scores {
+		if best-score < 1e-9 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	v := &ComponentVersion{
+		Range:            s.Versions[first].Name,
+		First:            s.Versions[first].Name,
+		FirstDate:        s.Versions[first].Date,
+		Last:             s.Versions[last].Name,
+		LastDate:         s.Versions[last].Date,
+		MatchedFunctions: matched,
+	}
+	if last != first {
+		v.Range += "–" + s.Versions[last].Name
+	}
+	return v
+}
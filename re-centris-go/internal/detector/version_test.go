@@ -0,0 +1,40 @@
+package detector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+)
+
+func TestEstimateVersion(t *testing.T) {
+	date := func(day int) time.Time { return time.Date(2021, 3, day, 0, 0, 0, 0, time.UTC) }
+	s := &versions.Signatures{
+		Component: "openssl",
+		Versions: []versions.Version{
+			{Name: "1.1.1j", Date: date(1)},
+			{Name: "1.1.1k", Date: date(2)},
+			{Name: "1.1.1l", Date: date(3)},
+			{Name: "1.1.1m", Date: date(4)},
+		},
+		Functions: map[string][]int{
+			"shared":  {0, 1, 2, 3},
+			"since-k": {1, 2, 3},
+			"only-j":  {0},
+		},
+	}
+
+	v := estimateVersion(s, []string{"shared", "since-k", "unknown"})
+	if v == nil || v.Range != "1.1.1k–1.1.1m" || !v.FirstDate.Equal(date(2)) || !v.LastDate.Equal(date(4)) || v.MatchedFunctions != 2 {
+		t.Errorf("estimateVersion() = %+v, want 1.1.1k–1.1.1m from 2 functions", v)
+	}
+
+	// A function unique to one version outweighs one shared by the rest
+	if v := estimateVersion(s, []string{"shared", "only-j"}); v == nil || v.Range != "1.1.1j" {
+		t.Errorf("estimateVersion() = %+v, want 1.1.1j", v)
+	}
+
+	if v := estimateVersion(s, []string{"unknown"}); v != nil {
+		t.Errorf("estimateVersion() = %+v, want nil without known functions", v)
+	}
+}
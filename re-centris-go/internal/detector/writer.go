@@ -0,0 +1,70 @@
+package detector
+
+import (
+	"fmt"
+)
+
+// Output formats supported for detection results
+const (
+	// FormatJSON writes results as a JSON array
+	FormatJSON = "json"
+	// FormatJSONL writes one result per line followed by a summary record
+	FormatJSONL = "jsonl"
+	// FormatSARIF writes results as a SARIF 2.1.0 log
+	FormatSARIF = "sarif"
+	// FormatCSV writes one row per match
+	FormatCSV = "csv"
+	// FormatMarkdown writes a Markdown table with one row per match
+	FormatMarkdown = "markdown"
+	// FormatGitHub writes GitHub Actions workflow commands annotating matches
+	FormatGitHub = "github"
+	// FormatGitHubCheck writes the output object of a GitHub check run
+	FormatGitHubCheck = "github-check"
+)
+
+// ResultWriter writes detection results in an output format. Results are
+// written one at a time; Close must be called to complete the output.
+type ResultWriter interface {
+	Write(result *DetectionResult) error
+	Close() error
+}
+
+// NewResultWriter creates a writer for the given output format at path.
+// The path "-" writes to standard output.
+func (d *Detector) NewResultWriter(format, path string) (ResultWriter, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONWriter(path)
+	case FormatJSONL:
+		return d.newJSONLWriter(path)
+	case FormatSARIF:
+		return d.newSARIFWriter(path), nil
+	case FormatCSV:
+		return d.newCSVWriter(path)
+	case FormatMarkdown:
+		return d.newMarkdownWriter(path)
+	case FormatGitHub:
+		return d.newGitHubWriter(path)
+	case FormatGitHubCheck:
+		return d.newGitHubCheckWriter(path), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// WriteResults writes detection results to a file in the given output format
+func (d *Detector) WriteResults(results []*DetectionResult, format, outputPath string) error {
+	w, err := d.NewResultWriter(format, outputPath)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if err := w.Write(result); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	return w.Close()
+}
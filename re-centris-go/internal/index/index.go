@@ -0,0 +1,356 @@
+// Package index persists analyzed known files, so that detection does not
+// re-analyze the known files directory on every run. An index is updated
+// incrementally: files whose size and modification time are unchanged are
+// reused, changed files are reused if their content hash is unchanged and
+// re-analyzed otherwise.
+package index
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser/cpp"
+	"github.com/re-centris/re-centris-go/internal/common/bufpool"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"go.uber.org/zap"
+)
+
+// Version is the version of the index format
+const Version = 1
+
+// Header is the first record of an index file
+type Header struct {
+	Version int    `json:"version"`
+	Dir     string `json:"dir"`
+	// Fingerprint identifies the analyzer options the files were hashed
+	// with; an index built with other options is rebuilt
+	Fingerprint string `json:"fingerprint"`
+	Files       int    `json:"files"`
+}
+
+// Entry is an indexed file
+type Entry struct {
+	preprocessor.FileMetadata
+	ModTime int64 `json:"mod_time"`
+}
+
+// Index holds the indexed files of a known files directory
+type Index struct {
+	Header  Header
+	entries map[string]*Entry
+}
+
+// IndexOptions contains options for the indexer
+type IndexOptions struct {
+	// Dir is the known files directory
+	Dir string
+	// Analyzer are the options the files are analyzed with. They must
+	// match the options of the detector using the index.
+	Analyzer analyzer.AnalyzerOptions
+}
+
+// Stats counts the files of an update
+type Stats struct {
+	Reused   int `json:"reused"`
+	Analyzed int `json:"analyzed"`
+	Removed  int `json:"removed"`
+}
+
+// Changed reports whether the update changed the index
+func (s Stats) Changed() bool {
+	return s.Analyzed > 0 || s.Removed > 0
+}
+
+// Indexer builds and updates indexes
+type Indexer struct {
+	opts     IndexOptions
+	analyzer *analyzer.Analyzer
+}
+
+// New creates a new Indexer
+func New(opts IndexOptions) *Indexer {
+	return &Indexer{
+		opts:     opts,
+		analyzer: analyzer.New(opts.Analyzer),
+	}
+}
+
+// Fingerprint returns the fingerprint of the analyzer options that affect
+// file hashes
+func Fingerprint(opts analyzer.AnalyzerOptions) string {
+	data, _ := json.Marshal(struct {
+		Languages     map[string][]string
+		NoSniff       bool
+		Normalize     interface{}
+		SignatureMode string
+		Encoding      string `json:",omitempty"`
+		Conditionals  string `json:",omitempty"`
+		MaxFileSize   int64  `json:",omitempty"`
+		ChunkedSize   int64  `json:",omitempty"`
+	}{opts.Languages, opts.NoSniff, opts.Normalize, opts.SignatureMode, analyzer.EncodingKey(opts.Encoding), cpp.ConditionalsKey(opts.Conditionals), opts.MaxFileSize, opts.ChunkedSize})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Update returns the index of the known files directory, reusing the
+// unchanged files of previous, which may be nil. Files are analyzed in
+// parallel.
+func (x *Indexer) Update(ctx context.Context, previous *Index) (*Index, Stats, error) {
+	var stats Stats
+
+	fingerprint := Fingerprint(x.opts.Analyzer)
+	if previous != nil && (previous.Header.Fingerprint != fingerprint || previous.Header.Dir != x.opts.Dir) {
+		logger.Info("Rebuilding index for changed analyzer options or directory",
+			zap.String("dir", x.opts.Dir))
+		previous = nil
+	}
+
+	index := &Index{
+		Header:  Header{Version: Version, Dir: x.opts.Dir, Fingerprint: fingerprint},
+		entries: make(map[string]*Entry),
+	}
+	var mutex sync.Mutex
+
+	g, ctx := workpool.WithContext(ctx, x.opts.Analyzer.Pool, max(x.opts.Analyzer.MaxWorkers, 1))
+
+	err := x.analyzer.WalkDirectory(ctx, x.opts.Dir, func(path string, info os.FileInfo) error {
+		var old *Entry
+		if previous != nil {
+			old = previous.entries[path]
+		}
+
+		g.Go(func() error {
+			entry, reused, err := x.entry(ctx, path, info, old)
+			if err != nil {
+				if analyzer.IsSkipped(err) {
+					return nil
+				}
+				return err
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			index.entries[path] = entry
+			if reused {
+				stats.Reused++
+			} else {
+				stats.Analyzed++
+			}
+			return nil
+		})
+		return nil
+	})
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to index known files: %v", err)
+	}
+
+	if previous != nil {
+		for path := range previous.entries {
+			if _, ok := index.entries[path]; !ok {
+				stats.Removed++
+			}
+		}
+	}
+	index.Header.Files = len(index.entries)
+
+	return index, stats, nil
+}
+
+// entry returns the index entry of a file, reusing old if the file content
+// is unchanged
+func (x *Indexer) entry(ctx context.Context, path string, info os.FileInfo, old *Entry) (*Entry, bool, error) {
+	modTime := info.ModTime().UnixNano()
+	if old != nil && old.Size == info.Size() && old.ModTime == modTime {
+		return old, true, nil
+	}
+
+	if old != nil && old.Digest != "" {
+		sum, err := fileDigest(path)
+		if err != nil {
+			return nil, false, err
+		}
+		if sum == old.Digest {
+			entry := *old
+			entry.ModTime = modTime
+			return &entry, true, nil
+		}
+	}
+
+	file, err := x.analyzer.AnalyzeFile(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	return &Entry{FileMetadata: *preprocessor.NewFileMetadata(file), ModTime: modTime}, false, nil
+}
+
+// fileDigest returns the hex-encoded SHA-256 of a file's content
+func fileDigest(path string) (string, error) {
+	file, err := fsutil.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	defer file.Close()
+
+	content, err := bufpool.ReadAll(file, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	defer bufpool.Put(content)
+
+	sum := sha256.Sum256(content.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FileInfos returns the indexed files sorted by path
+func (i *Index) FileInfos() ([]*analyzer.FileInfo, error) {
+	files := make([]*analyzer.FileInfo, 0, len(i.entries))
+	for _, entry := range i.entries {
+		file, err := entry.FileInfo()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].Path < files[b].Path
+	})
+	return files, nil
+}
+
+// Len returns the number of indexed files
+func (i *Index) Len() int {
+	return len(i.entries)
+}
+
+// Load reads an index file
+func Load(path string) (*Index, error) {
+	index := &Index{entries: make(map[string]*Entry)}
+	header, err := Read(path, func(entry *Entry) error {
+		index.entries[entry.Path] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	index.Header = *header
+	return index, nil
+}
+
+// Read streams an index file, calling fn for every entry in path order
+// without holding the index in memory
+func Read(path string, fn func(*Entry) error) (*Header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %v", err)
+	}
+	defer file.Close()
+
+	decoder, err := zstd.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index decoder: %v", err)
+	}
+	defer decoder.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(decoder))
+	var header Header
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("failed to parse index header: %v", err)
+	}
+	if header.Version != Version {
+		return nil, fmt.Errorf("unsupported index version: %d", header.Version)
+	}
+
+	for {
+		var entry Entry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse index: %v", err)
+		}
+		if err := fn(&entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return &header, nil
+}
+
+// Open loads the index file at path for an update. It returns nil, so the
+// index is rebuilt, if the file does not exist or cannot be read.
+func Open(path string) *Index {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	index, err := Load(path)
+	if err != nil {
+		logger.Warn("Rebuilding unreadable index",
+			zap.String("index", path),
+			zap.Error(err))
+		return nil
+	}
+	return index
+}
+
+// Save writes the index to a file, replacing it atomically
+func (i *Index) Save(path string) error {
+	tmp, err := fsutil.CreateAtomic(path, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %v", err)
+	}
+
+	if err := i.write(tmp); err != nil {
+		tmp.Abort()
+		return err
+	}
+	if err := tmp.Commit(); err != nil {
+		return fmt.Errorf("failed to write index: %v", err)
+	}
+	return nil
+}
+
+// write encodes the header and the entries sorted by path
+func (i *Index) write(w io.Writer) error {
+	encoder, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to create index encoder: %v", err)
+	}
+
+	paths := make([]string, 0, len(i.entries))
+	for path := range i.entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	enc := json.NewEncoder(encoder)
+	if err := enc.Encode(i.Header); err != nil {
+		encoder.Close()
+		return fmt.Errorf("failed to write index: %v", err)
+	}
+	for _, path := range paths {
+		if err := enc.Encode(i.entries[path]); err != nil {
+			encoder.Close()
+			return fmt.Errorf("failed to write index: %v", err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to finish index: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+)
+
+// source returns C source long enough to be hashed
+func source(name string) []byte {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "int %s_%d(int x) { return x * %d + %d; }\n", name, i, i, i*7)
+	}
+	return []byte(b.String())
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, name+".c"), source(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	x := New(IndexOptions{
+		Dir: dir,
+		Analyzer: analyzer.AnalyzerOptions{
+			MaxWorkers: 2,
+			Languages:  map[string][]string{"cpp": {".c"}},
+		},
+	})
+	idx, stats, err := x.Update(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if stats.Analyzed != 3 || idx.Len() != 3 {
+		t.Fatalf("Update() stats = %+v, files = %d, want 3 analyzed", stats, idx.Len())
+	}
+
+	path := filepath.Join(t.TempDir(), "known.index")
+	if err := idx.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Touch a without changing it, change b and remove c
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a", "a.c"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b", "b.c"), source("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, stats, err = x.Update(context.Background(), loaded)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if want := (Stats{Reused: 1, Analyzed: 1, Removed: 1}); stats != want {
+		t.Errorf("Update() stats = %+v, want %+v", stats, want)
+	}
+
+	files, err := idx.FileInfos()
+	if err != nil {
+		t.Fatalf("FileInfos() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Hash == nil || files[1].Digest == "" {
+		t.Errorf("FileInfos() = %+v", files)
+	}
+}
@@ -0,0 +1,87 @@
+package license
+
+import (
+	"fmt"
+	"strings"
+)
+
+// family groups licenses by how they constrain the code they are combined with
+type family int
+
+const (
+	familyUnknown family = iota
+	familyPermissive
+	familyWeakCopyleft
+	familyGPL2
+	familyGPL3
+	familyAGPL
+)
+
+// familyOf classifies an SPDX license ID
+func familyOf(id string) family {
+	switch {
+	case id == "":
+		return familyUnknown
+	case strings.HasPrefix(id, "AGPL-"):
+		return familyAGPL
+	case strings.HasPrefix(id, "GPL-3.0"):
+		return familyGPL3
+	case strings.HasPrefix(id, "GPL-2.0"):
+		return familyGPL2
+	case strings.HasPrefix(id, "LGPL-"), strings.HasPrefix(id, "MPL-"):
+		return familyWeakCopyleft
+	default:
+		return familyPermissive
+	}
+}
+
+// Conflict returns a warning if code under the component license cannot be
+// included in a target under the target license, or an empty string. An
+// empty target license is treated as proprietary. Compound expressions are
+// checked by their most restrictive part, so the result errs on the side of
+// warning.
+func Conflict(target, component string) string {
+	c := mostRestrictive(component)
+	t := mostRestrictive(target)
+
+	switch c {
+	case familyAGPL:
+		if t != familyAGPL {
+			return fmt.Sprintf("%s code requires the target to be released under the AGPL", component)
+		}
+	case familyGPL3:
+		if t != familyGPL3 && t != familyAGPL {
+			return fmt.Sprintf("%s code requires the target to be released under GPL-3.0 or a compatible license", component)
+		}
+	case familyGPL2:
+		if strings.HasSuffix(component, "-only") && t == familyGPL3 {
+			return fmt.Sprintf("%s code is incompatible with a %s target", component, target)
+		}
+		if t != familyGPL2 && t != familyGPL3 && t != familyAGPL {
+			return fmt.Sprintf("%s code requires the target to be released under the GPL", component)
+		}
+	case familyPermissive:
+		// Apache-2.0 is incompatible with GPL-2.0-only
+		if strings.Contains(component, "Apache-2.0") && strings.Contains(target, "GPL-2.0-only") {
+			return fmt.Sprintf("%s code is incompatible with a %s target", component, target)
+		}
+	}
+
+	return ""
+}
+
+// mostRestrictive returns the most restrictive family of a license expression
+func mostRestrictive(expression string) family {
+	best := familyUnknown
+	for _, part := range strings.FieldsFunc(expression, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')'
+	}) {
+		if part == "AND" || part == "OR" || part == "WITH" {
+			continue
+		}
+		if f := familyOf(part); f > best {
+			best = f
+		}
+	}
+	return best
+}
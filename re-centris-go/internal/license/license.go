@@ -0,0 +1,195 @@
+package license
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// headerLines is the number of lines searched for an SPDX header
+const headerLines = 20
+
+// maxHeaderFiles limits the source files read when a directory has no license file
+const maxHeaderFiles = 200
+
+// spdxHeader matches SPDX license identifier comments in source files
+var spdxHeader = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+\-() ]+?)\s*(?:\*/|-->|$)`)
+
+// licenseFile matches the names of license files at the root of a repository
+var licenseFile = regexp.MustCompile(`(?i)^(licen[cs]e|copying|unlicense)([.\-_].*)?$`)
+
+// signature identifies a license by phrases of its text; all phrases must appear
+type signature struct {
+	id      string
+	phrases []string
+}
+
+// signatures are checked in order, so more specific licenses come first
+var signatures = []signature{
+	{"AGPL-3.0-only", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0-only", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1-only", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0-only", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0-only", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSL-1.0", []string{"boost software license"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"Zlib", []string{"this software is provided 'as-is'", "altered source versions must be plainly marked"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"MIT", []string{"permission is hereby granted, free of charge", "the above copyright notice and this permission notice shall be included"}},
+}
+
+// Detect returns the SPDX license ID of a repository or directory. License
+// files at the root are preferred; without them the most common SPDX header
+// of the source files is used. An empty string means no license was found.
+func Detect(dir string) string {
+	if id := detectFiles(dir); id != "" {
+		return id
+	}
+	return detectHeaders(dir)
+}
+
+// DetectComponents returns the license of every component directory below dir
+func DetectComponents(dir string) map[string]string {
+	licenses := make(map[string]string)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return licenses
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if id := Detect(filepath.Join(dir, entry.Name())); id != "" {
+			licenses[entry.Name()] = id
+		}
+	}
+
+	return licenses
+}
+
+// FileHeader returns the license of an SPDX-License-Identifier comment
+// in the first lines of a file
+func FileHeader(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < headerLines && scanner.Scan(); i++ {
+		if m := spdxHeader.FindStringSubmatch(scanner.Text()); m != nil {
+			return strings.TrimSpace(m[1])
+		}
+	}
+	return ""
+}
+
+// Identify returns the SPDX license ID of a license text
+func Identify(text string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	for _, s := range signatures {
+		matched := true
+		for _, phrase := range s.phrases {
+			if !strings.Contains(normalized, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return orLater(s.id, normalized)
+		}
+	}
+	return ""
+}
+
+// orLater converts an -only GPL family ID to -or-later if the text grants
+// use of later versions
+func orLater(id, normalized string) string {
+	if strings.HasSuffix(id, "-only") && strings.Contains(normalized, "any later version") {
+		return strings.TrimSuffix(id, "-only") + "-or-later"
+	}
+	return id
+}
+
+// detectFiles identifies the license files at the root of dir
+func detectFiles(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || !licenseFile.MatchString(entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if id := Identify(string(data)); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	return expression(ids)
+}
+
+// detectHeaders returns the most common SPDX header of the files in dir
+func detectHeaders(dir string) string {
+	counts := make(map[string]int)
+	read := 0
+
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if read >= maxHeaderFiles {
+			return filepath.SkipAll
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		read++
+		if id := FileHeader(path); id != "" {
+			counts[id]++
+		}
+		return nil
+	})
+
+	best, bestCount := "", 0
+	for id, count := range counts {
+		if count > bestCount || (count == bestCount && id < best) {
+			best, bestCount = id, count
+		}
+	}
+	return best
+}
+
+// expression combines the licenses of several license files, which
+// typically means the project is offered under all of them
+func expression(ids []string) string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	sort.Strings(unique)
+	return strings.Join(unique, " AND ")
+}
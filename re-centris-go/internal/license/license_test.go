@@ -0,0 +1,66 @@
+package license
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentify(t *testing.T) {
+	tests := map[string]string{
+		"Permission is hereby granted, free of charge, to any person obtaining a copy ... The above copyright notice and this permission notice shall be included in all copies": "MIT",
+		"Apache License\n  Version 2.0, January 2004": "Apache-2.0",
+		"GNU GENERAL PUBLIC LICENSE Version 2, June 1991 ... either version 2 of the License, or (at your option) any later version.": "GPL-2.0-or-later",
+		"GNU LESSER GENERAL PUBLIC LICENSE Version 2.1, February 1999":                                                                "LGPL-2.1-only",
+		"Redistribution and use in source and binary forms ... Neither the name of the copyright holder":                              "BSD-3-Clause",
+		"All rights reserved.": "",
+	}
+	for text, want := range tests {
+		if got := Identify(text); got != want {
+			t.Errorf("Identify(%.40q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+
+	// Without a license file the SPDX headers of the sources decide
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.c", "/* SPDX-License-Identifier: Zlib */\nint a;\n")
+	write("b.c", "// SPDX-License-Identifier: Zlib\nint b;\n")
+	write("c.c", "// SPDX-License-Identifier: MIT\nint c;\n")
+	if got := Detect(dir); got != "Zlib" {
+		t.Errorf("Detect() from headers = %q, want Zlib", got)
+	}
+
+	write("LICENSE", "Apache License\nVersion 2.0, January 2004\n")
+	if got := Detect(dir); got != "Apache-2.0" {
+		t.Errorf("Detect() from license file = %q, want Apache-2.0", got)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	tests := []struct {
+		target, component string
+		conflict          bool
+	}{
+		{"MIT", "BSD-3-Clause", false},
+		{"", "GPL-2.0-only", true},
+		{"GPL-3.0-or-later", "GPL-2.0-or-later", false},
+		{"GPL-3.0-only", "GPL-2.0-only", true},
+		{"GPL-2.0-only", "Apache-2.0", true},
+		{"MIT", "AGPL-3.0-only", true},
+		{"Apache-2.0", "LGPL-2.1-only", false},
+		{"MIT", "MIT AND GPL-3.0-only", true},
+	}
+	for _, tt := range tests {
+		if got := Conflict(tt.target, tt.component) != ""; got != tt.conflict {
+			t.Errorf("Conflict(%q, %q) = %v, want %v", tt.target, tt.component, got, tt.conflict)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/gitutil"
+	"github.com/re-centris/re-centris-go/internal/license"
+	"github.com/re-centris/re-centris-go/internal/purl"
+	"github.com/re-centris/re-centris-go/internal/version"
+)
+
+// FileName is the name of the manifest file written next to a corpus index
+const FileName = "manifest.json"
+
+// MetadataFileName is the name of the file holding the host metadata of
+// the repositories of a clone directory, see package collector/metadata
+const MetadataFileName = "metadata.json"
+
+// Repository describes a repository of the corpus pinned to a commit
+type Repository struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	License string `json:"license,omitempty"` // SPDX license ID
+	PURL    string `json:"purl,omitempty"`    // package URL pinned to the commit
+	// Metadata is set if it was fetched from the repository host
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// Metadata describes a repository as reported by its host
+type Metadata struct {
+	Description       string     `json:"description,omitempty"`
+	License           string     `json:"license,omitempty"` // SPDX license ID
+	Stars             int        `json:"stars"`
+	Archived          bool       `json:"archived"`
+	PushedAt          *time.Time `json:"pushed_at,omitempty"`
+	LatestRelease     string     `json:"latest_release,omitempty"`
+	LatestReleaseDate *time.Time `json:"latest_release_date,omitempty"`
+	FetchedAt         time.Time  `json:"fetched_at"`
+}
+
+// CorpusManifest records everything needed to reproduce a corpus index build
+type CorpusManifest struct {
+	ToolVersion    string       `json:"tool_version"`
+	GoVersion      string       `json:"go_version"`
+	CreatedAt      time.Time    `json:"created_at"`
+	ConfigHash     string       `json:"config_hash,omitempty"`
+	Normalization  string       `json:"normalization"`            // see normalize.Describe
+	SignatureMode  string       `json:"signature_mode,omitempty"` // see analyzer.SignatureBytes
+	Encoding       string       `json:"encoding,omitempty"`       // see analyzer.EncodingKey
+	Repositories   []Repository `json:"repositories"`
+	TotalFiles     int          `json:"total_files"`
+	TotalFunctions int          `json:"total_functions"`
+}
+
+// New creates a manifest stamped with the current tool versions
+func New() *CorpusManifest {
+	return &CorpusManifest{
+		ToolVersion: version.Version,
+		GoVersion:   runtime.Version(),
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// Write writes the manifest to dir and returns its hash
+func Write(dir string, m *CorpusManifest) (string, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, FileName), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %v", err)
+	}
+
+	return Hash(data), nil
+}
+
+// Read reads the manifest from dir and returns it together with its hash
+func Read(dir string) (*CorpusManifest, string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var m CorpusManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, "", &fsutil.CorruptError{Path: filepath.Join(dir, FileName), Err: err}
+	}
+
+	return &m, Hash(data), nil
+}
+
+// Hash returns the hex SHA256 of manifest data
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashConfig returns a stable hash of configuration settings
+func HashConfig(settings map[string]interface{}) string {
+	// encoding/json sorts map keys, which makes the hash stable
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return ""
+	}
+	return Hash(data)
+}
+
+// ReadMetadata reads the repository metadata of a clone directory by
+// repository directory name. A missing file yields no metadata.
+func ReadMetadata(dir string) (map[string]*Metadata, error) {
+	data, err := os.ReadFile(filepath.Join(dir, MetadataFileName))
+	if os.IsNotExist(err) {
+		return map[string]*Metadata{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository metadata: %v", err)
+	}
+
+	metadata := make(map[string]*Metadata)
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, &fsutil.CorruptError{Path: filepath.Join(dir, MetadataFileName), Err: err}
+	}
+	return metadata, nil
+}
+
+// WriteMetadata writes the repository metadata of a clone directory
+func WriteMetadata(dir string, metadata map[string]*Metadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal repository metadata: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, MetadataFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write repository metadata: %v", err)
+	}
+	return nil
+}
+
+// CollectRepositories returns the git repositories directly below dir
+// pinned to their current commits, with the metadata fetched at clone time
+func CollectRepositories(ctx context.Context, dir string) ([]Repository, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus directory: %v", err)
+	}
+	metadata, err := ReadMetadata(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []Repository
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		repoDir := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil {
+			continue
+		}
+
+		repo := Repository{
+			Name:     entry.Name(),
+			URL:      gitOutput(ctx, repoDir, "config", "--get", "remote.origin.url"),
+			Commit:   gitOutput(ctx, repoDir, "rev-parse", "HEAD"),
+			License:  license.Detect(repoDir),
+			Metadata: metadata[entry.Name()],
+		}
+		if repo.License == "" && repo.Metadata != nil {
+			repo.License = repo.Metadata.License
+		}
+		repo.PURL = purl.ForComponent(repo.Name, repo.URL, repo.Commit)
+		repos = append(repos, repo)
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name < repos[j].Name
+	})
+
+	return repos, nil
+}
+
+// gitOutput runs a git command in dir and returns its trimmed output,
+// or an empty string if the command fails
+func gitOutput(ctx context.Context, dir string, args ...string) string {
+	output, err := gitutil.Output(ctx, dir, args...)
+	if err != nil {
+		return ""
+	}
+	return output
+}
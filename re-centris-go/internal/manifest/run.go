@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/version"
+)
+
+const (
+	// RunFileName is the name of the run manifest written into an output
+	// directory
+	RunFileName = "run.json"
+	// RunSuffix is appended to the path of an output file to name its run
+	// manifest
+	RunSuffix = ".run.json"
+)
+
+// Run records a run of a command, so that its outputs can be reproduced
+// and audited
+type Run struct {
+	Command     string `json:"command"`
+	ToolVersion string `json:"tool_version"`
+	GoVersion   string `json:"go_version"`
+	ConfigHash  string `json:"config_hash"`
+	// CorpusManifest is the hash of the corpus manifest the run read or
+	// wrote, identifying the signature database
+	CorpusManifest string `json:"corpus_manifest,omitempty"`
+	// Snapshot is the ID of the corpus snapshot a detection read, if any
+	Snapshot   string         `json:"snapshot,omitempty"`
+	Inputs     []string       `json:"inputs"`
+	Outputs    []string       `json:"outputs"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	DurationMS int64          `json:"duration_ms"`
+	Stages     []StageTiming  `json:"stages"`
+	Counts     map[string]int `json:"counts"`
+	SlowFiles  []SlowFile     `json:"slow_files,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// StageTiming is the duration of a finished stage of a run
+type StageTiming struct {
+	Name       string `json:"name"`
+	Items      int    `json:"items"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// SlowFile is a file slow to analyze, or stopped after the file timeout
+type SlowFile struct {
+	Path       string `json:"path"`
+	DurationMS int64  `json:"duration_ms"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+}
+
+// NewRun starts the run manifest of a command
+func NewRun(command, configHash string, inputs []string) *Run {
+	return &Run{
+		Command:     command,
+		ToolVersion: version.Version,
+		GoVersion:   runtime.Version(),
+		ConfigHash:  configHash,
+		Inputs:      inputs,
+		Outputs:     []string{},
+		StartedAt:   time.Now().UTC(),
+		Stages:      []StageTiming{},
+		Counts:      make(map[string]int),
+	}
+}
+
+// AddStage records the duration of a finished stage
+func (r *Run) AddStage(name string, items int, elapsed time.Duration) {
+	r.Stages = append(r.Stages, StageTiming{Name: name, Items: items, DurationMS: elapsed.Milliseconds()})
+}
+
+// Finish stamps the end of the run and the error it failed with, if any
+func (r *Run) Finish(err error) {
+	r.FinishedAt = time.Now().UTC()
+	r.DurationMS = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// WriteRun writes a run manifest to path
+func WriteRun(path string, r *Run) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run manifest: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run manifest: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/collector/clone"
+	"github.com/re-centris/re-centris-go/internal/common/cache"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+	"go.uber.org/zap"
+)
+
+// Stage identifies a step of the workflow
+type Stage string
+
+const (
+	// StageClone clones the repositories of the repository list
+	StageClone Stage = "clone"
+	// StageCollect analyzes the cloned repositories
+	StageCollect Stage = "collect"
+	// StagePreprocess writes signature metadata for the collected files
+	StagePreprocess Stage = "preprocess"
+	// StageDetect detects known code in the target directory
+	StageDetect Stage = "detect"
+)
+
+// Stages lists all stages in execution order
+var Stages = []Stage{StageClone, StageCollect, StagePreprocess, StageDetect}
+
+// ParseStage converts a stage name into a Stage
+func ParseStage(name string) (Stage, error) {
+	for _, stage := range Stages {
+		if string(stage) == name {
+			return stage, nil
+		}
+	}
+	return "", fmt.Errorf("unknown pipeline stage: %s", name)
+}
+
+// PipelineOptions contains options for the pipeline
+type PipelineOptions struct {
+	RepoListFile        string
+	RepoDir             string
+	PreprocessDir       string
+	TargetDir           string
+	ResultFile          string
+	MaxWorkers          int
+	Languages           map[string][]string
+	SimilarityThreshold float64
+	LanguageThresholds  map[string]float64
+	FunctionThreshold   int
+	Resume              bool   // resume preprocessing from its last checkpoint
+	ConfigHash          string // configuration hash recorded in the corpus manifest
+	From                Stage  // first stage to run, defaults to clone
+	Until               Stage  // last stage to run, defaults to detect
+	// Symlinks, Include, Exclude, GitIgnore and NoSniff select the walked
+	// files of all stages, see analyzer.AnalyzerOptions
+	Symlinks  string
+	Include   []string
+	Exclude   []string
+	GitIgnore bool
+	NoSniff   bool
+	// SniffLanguages detects the language of files without a supported
+	// extension from their content
+	SniffLanguages bool
+	// Normalize selects the normalization applied before hashing by language
+	Normalize map[string]normalize.Options
+	// SignatureMode is the signature mode of the analyzer, see analyzer.SignatureBytes
+	SignatureMode string
+	// Encoding is the source encoding of the analyzer, see analyzer.ParseEncoding
+	Encoding string
+	// Conditionals is the conditional compilation mode of the C/C++ parser,
+	// see cpp.ParseConditionals
+	Conditionals string
+	// MinFunctionLines, MinFunctionTokens and MinFunctionComplexity drop
+	// trivial functions, see analyzer.AnalyzerOptions
+	MinFunctionLines      int
+	MinFunctionTokens     int
+	MinFunctionComplexity int
+	// StoreBodies stores the function bodies of the corpus, see
+	// preprocessor.PreprocessorOptions
+	StoreBodies bool
+	// MaxFileSize and ChunkedSize limit the memory taken by large files,
+	// see analyzer.AnalyzerOptions
+	MaxFileSize int64
+	ChunkedSize int64
+	// FileTimeout and SlowFiles bound and report the time taken by a
+	// file, see analyzer.AnalyzerOptions
+	FileTimeout time.Duration
+	SlowFiles   *analyzer.SlowFileReport
+	// MaxConcurrency is the number of tasks all stages run at once, on top of
+	// the MaxWorkers limit of each stage; 0 means workpool.DefaultSize
+	MaxConcurrency int
+	// Cache, if set, persists the analysis of file contents across runs
+	Cache *cache.DiskCache
+}
+
+// Pipeline runs the workflow from cloning to detection, passing
+// artifacts between stages
+type Pipeline struct {
+	opts       PipelineOptions
+	analyzer   *analyzer.Analyzer
+	pool       *workpool.Pool // shared by the workers of all stages
+	knownFiles []*analyzer.FileInfo
+	// corpusManifest is the manifest hash of the corpus built by the preprocess stage
+	corpusManifest string
+}
+
+// New creates a new Pipeline
+func New(opts PipelineOptions) *Pipeline {
+	if opts.From == "" {
+		opts.From = StageClone
+	}
+	if opts.Until == "" {
+		opts.Until = StageDetect
+	}
+
+	pool := workpool.New(opts.MaxConcurrency)
+
+	return &Pipeline{
+		opts: opts,
+		pool: pool,
+		analyzer: analyzer.New(analyzer.AnalyzerOptions{
+			MaxWorkers:            opts.MaxWorkers,
+			Pool:                  pool,
+			Cache:                 opts.Cache,
+			Languages:             opts.Languages,
+			Symlinks:              opts.Symlinks,
+			Include:               opts.Include,
+			Exclude:               opts.Exclude,
+			GitIgnore:             opts.GitIgnore,
+			NoSniff:               opts.NoSniff,
+			SniffLanguages:        opts.SniffLanguages,
+			Normalize:             opts.Normalize,
+			SignatureMode:         opts.SignatureMode,
+			Encoding:              opts.Encoding,
+			Conditionals:          opts.Conditionals,
+			MaxFileSize:           opts.MaxFileSize,
+			ChunkedSize:           opts.ChunkedSize,
+			FileTimeout:           opts.FileTimeout,
+			SlowFiles:             opts.SlowFiles,
+			MinFunctionLines:      opts.MinFunctionLines,
+			MinFunctionTokens:     opts.MinFunctionTokens,
+			MinFunctionComplexity: opts.MinFunctionComplexity,
+		}),
+	}
+}
+
+// Run executes the selected stages in order
+func (p *Pipeline) Run(ctx context.Context) error {
+	stages, err := p.selectStages()
+	if err != nil {
+		return err
+	}
+
+	for _, stage := range stages {
+		logger.Info("Starting pipeline stage", zap.String("stage", string(stage)))
+
+		if err := p.runStage(ctx, stage); err != nil {
+			return fmt.Errorf("pipeline stage %s failed: %v", stage, err)
+		}
+
+		logger.Info("Pipeline stage completed", zap.String("stage", string(stage)))
+	}
+
+	return nil
+}
+
+// selectStages returns the stages between From and Until
+func (p *Pipeline) selectStages() ([]Stage, error) {
+	from, until := -1, -1
+	for i, stage := range Stages {
+		if stage == p.opts.From {
+			from = i
+		}
+		if stage == p.opts.Until {
+			until = i
+		}
+	}
+
+	if from < 0 {
+		return nil, fmt.Errorf("unknown pipeline stage: %s", p.opts.From)
+	}
+	if until < 0 {
+		return nil, fmt.Errorf("unknown pipeline stage: %s", p.opts.Until)
+	}
+	if from > until {
+		return nil, fmt.Errorf("stage %s comes after stage %s", p.opts.From, p.opts.Until)
+	}
+
+	return Stages[from : until+1], nil
+}
+
+// runStage executes a single stage
+func (p *Pipeline) runStage(ctx context.Context, stage Stage) error {
+	switch stage {
+	case StageClone:
+		return p.clone(ctx)
+	case StageCollect:
+		return p.collect(ctx)
+	case StagePreprocess:
+		return p.preprocess(ctx)
+	case StageDetect:
+		return p.detect(ctx)
+	default:
+		return fmt.Errorf("unknown pipeline stage: %s", stage)
+	}
+}
+
+// clone clones all repositories of the repository list
+func (p *Pipeline) clone(ctx context.Context) error {
+	urls, err := clone.ReadRepoList(p.opts.RepoListFile)
+	if err != nil {
+		return err
+	}
+
+	return clone.CloneRepositories(ctx, urls, clone.CloneOptions{
+		TargetDir:  p.opts.RepoDir,
+		MaxWorkers: p.opts.MaxWorkers,
+		Pool:       p.pool,
+	})
+}
+
+// collect analyzes all cloned repositories
+func (p *Pipeline) collect(ctx context.Context) error {
+	files, err := p.analyzer.AnalyzeDirectory(ctx, p.opts.RepoDir)
+	if err != nil {
+		return err
+	}
+
+	p.knownFiles = files
+	logger.Info("Collected known files", zap.Int("total_files", len(files)))
+	return nil
+}
+
+// preprocess writes signature metadata for all collected files
+func (p *Pipeline) preprocess(ctx context.Context) error {
+	if err := p.ensureCollected(ctx); err != nil {
+		return err
+	}
+
+	// The detect stage loads the sharded output when the pipeline is
+	// resumed at it
+	pre := preprocessor.New(preprocessor.PreprocessorOptions{
+		MaxWorkers:            p.opts.MaxWorkers,
+		Pool:                  p.pool,
+		Cache:                 p.opts.Cache,
+		OutputDir:             p.opts.PreprocessDir,
+		Languages:             p.opts.Languages,
+		Resume:                p.opts.Resume,
+		ConfigHash:            p.opts.ConfigHash,
+		OutputFormat:          preprocessor.FormatSharded,
+		Symlinks:              p.opts.Symlinks,
+		Include:               p.opts.Include,
+		Exclude:               p.opts.Exclude,
+		GitIgnore:             p.opts.GitIgnore,
+		NoSniff:               p.opts.NoSniff,
+		SniffLanguages:        p.opts.SniffLanguages,
+		Normalize:             p.opts.Normalize,
+		SignatureMode:         p.opts.SignatureMode,
+		Encoding:              p.opts.Encoding,
+		Conditionals:          p.opts.Conditionals,
+		MaxFileSize:           p.opts.MaxFileSize,
+		ChunkedSize:           p.opts.ChunkedSize,
+		FileTimeout:           p.opts.FileTimeout,
+		SlowFiles:             p.opts.SlowFiles,
+		MinFunctionLines:      p.opts.MinFunctionLines,
+		MinFunctionTokens:     p.opts.MinFunctionTokens,
+		MinFunctionComplexity: p.opts.MinFunctionComplexity,
+		StoreBodies:           p.opts.StoreBodies,
+	})
+	if err := pre.ProcessFiles(ctx, p.opts.RepoDir, p.knownFiles); err != nil {
+		return err
+	}
+
+	_, hash, err := manifest.Read(p.opts.PreprocessDir)
+	if err != nil {
+		return err
+	}
+	p.corpusManifest = hash
+	return nil
+}
+
+// detect detects known code in all supported files of the target directory
+func (p *Pipeline) detect(ctx context.Context) error {
+	targets, err := p.targetFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := detector.DetectorOptions{
+		MaxWorkers:          p.opts.MaxWorkers,
+		Pool:                p.pool,
+		Cache:               p.opts.Cache,
+		SimilarityThreshold: p.opts.SimilarityThreshold,
+		LanguageThresholds:  p.opts.LanguageThresholds,
+		Languages:           p.opts.Languages,
+		KnownFilesDir:       p.opts.RepoDir,
+		FunctionThreshold:   p.opts.FunctionThreshold,
+		CorpusManifest:      p.corpusManifest,
+		Symlinks:            p.opts.Symlinks,
+		Include:             p.opts.Include,
+		Exclude:             p.opts.Exclude,
+		GitIgnore:           p.opts.GitIgnore,
+		NoSniff:             p.opts.NoSniff,
+		SniffLanguages:      p.opts.SniffLanguages,
+		Normalize:           p.opts.Normalize,
+		SignatureMode:       p.opts.SignatureMode,
+		Encoding:            p.opts.Encoding,
+		Conditionals:        p.opts.Conditionals,
+		MaxFileSize:         p.opts.MaxFileSize,
+		ChunkedSize:         p.opts.ChunkedSize,
+		FileTimeout:         p.opts.FileTimeout,
+		SlowFiles:           p.opts.SlowFiles,
+	}
+
+	// When resuming at detect, the corpus built by an earlier preprocess
+	// run is loaded instead of analyzing the repositories again
+	if p.knownFiles == nil && p.preprocessed() {
+		logger.Info("Loading preprocessed signatures",
+			zap.String("dir", p.opts.PreprocessDir))
+		opts.SignatureDir = p.opts.PreprocessDir
+	} else if err := p.ensureCollected(ctx); err != nil {
+		return err
+	}
+
+	d := detector.New(opts)
+	var results []*detector.DetectionResult
+	if opts.SignatureDir != "" {
+		results, err = d.DetectSimilarity(ctx, targets)
+	} else {
+		results, err = d.DetectWithKnownFiles(ctx, targets, p.knownFiles)
+	}
+	if err != nil {
+		return err
+	}
+
+	return d.SaveResults(results, p.opts.ResultFile)
+}
+
+// ensureCollected runs the collect stage when the pipeline was started
+// after it and no collected files are available yet
+func (p *Pipeline) ensureCollected(ctx context.Context) error {
+	if p.knownFiles != nil {
+		return nil
+	}
+	return p.collect(ctx)
+}
+
+// preprocessed reports whether the preprocess output directory holds the
+// shards and manifest of a completed preprocess run
+func (p *Pipeline) preprocessed() bool {
+	if p.opts.PreprocessDir == "" {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(p.opts.PreprocessDir, manifest.FileName)); err != nil {
+		return false
+	}
+	_, err := preprocessor.ReadShardIndex(p.opts.PreprocessDir)
+	return err == nil
+}
+
+// targetFiles returns the supported files of the target directory, walked
+// with the same options as the known files. An archive target is detected
+// as a whole.
+func (p *Pipeline) targetFiles(ctx context.Context) ([]string, error) {
+	if p.opts.TargetDir == "" {
+		return nil, fmt.Errorf("no target directory configured")
+	}
+	if analyzer.IsArchive(p.opts.TargetDir) {
+		return []string{p.opts.TargetDir}, nil
+	}
+
+	var targets []string
+	err := p.analyzer.WalkDirectory(ctx, p.opts.TargetDir, func(path string, info os.FileInfo) error {
+		targets = append(targets, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk target directory: %v", err)
+	}
+
+	return targets, nil
+}
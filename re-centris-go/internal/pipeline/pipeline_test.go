@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+func TestRunFromDetect(t *testing.T) {
+	dir := t.TempDir()
+	source := strings.Repeat("int f(int x) { return x * 3 + 7; }\n", 20)
+	files := map[string]string{
+		"repos/acme%lib/lib.c":   source,
+		"target/main.c":          source,
+		"target/vendor/vendor.c": source,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := PipelineOptions{
+		RepoDir:             filepath.Join(dir, "repos"),
+		PreprocessDir:       filepath.Join(dir, "preprocessed"),
+		TargetDir:           filepath.Join(dir, "target"),
+		ResultFile:          filepath.Join(dir, "results.json"),
+		MaxWorkers:          2,
+		Languages:           analyzer.DefaultLanguages(),
+		SimilarityThreshold: 0.8,
+		Exclude:             []string{"vendor/"},
+		From:                StageCollect,
+		Until:               StagePreprocess,
+	}
+	if err := New(opts).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Detection must not need the repositories once they are preprocessed
+	if err := os.RemoveAll(opts.RepoDir); err != nil {
+		t.Fatal(err)
+	}
+	opts.From, opts.Until = StageDetect, StageDetect
+	if err := New(opts).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(opts.ResultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []detector.DetectionResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || filepath.Base(results[0].TargetFile) != "main.c" {
+		t.Fatalf("got results %+v, want main.c only", results)
+	}
+	if len(results[0].Matches) != 1 || results[0].Matches[0].Component != "acme%lib" || results[0].CorpusManifest == "" {
+		t.Errorf("got result %+v, want a match in acme%%lib of the preprocessed corpus", results[0])
+	}
+}
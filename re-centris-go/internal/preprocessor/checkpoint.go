@@ -0,0 +1,198 @@
+package preprocessor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/jsonstream"
+)
+
+const (
+	// checkpointFile is the name of the checkpoint file in the output directory
+	checkpointFile = ".checkpoint.json"
+
+	// defaultCheckpointInterval is the default number of files between checkpoints
+	defaultCheckpointInterval = 1000
+)
+
+// checkpointState is the on-disk representation of a checkpoint
+type checkpointState struct {
+	Processed []string `json:"processed"`
+	Files     int      `json:"files"`
+	Functions int      `json:"functions"`
+}
+
+// checkpoint tracks the files processed by a run so that an interrupted
+// run can be resumed. The metadata of processed files is already written
+// to the output directory, so the list of processed files is all that is
+// needed to continue where a run stopped.
+type checkpoint struct {
+	path      string
+	interval  int
+	processed map[string]struct{}
+	files     int // files with written metadata
+	functions int // functions in written metadata
+	pending   int
+	flush     func() error // optional, makes written output durable before saving
+	mutex     sync.Mutex
+}
+
+// newCheckpoint creates a checkpoint in the output directory, loading the
+// previous state if resume is set
+func newCheckpoint(outputDir string, interval int, resume bool) (*checkpoint, error) {
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	cp := &checkpoint{
+		path:      filepath.Join(outputDir, checkpointFile),
+		interval:  interval,
+		processed: make(map[string]struct{}),
+	}
+
+	if !resume {
+		return cp, nil
+	}
+
+	file, err := os.Open(cp.path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	defer file.Close()
+
+	if err := cp.load(json.NewDecoder(bufio.NewReader(file))); err != nil {
+		return nil, &fsutil.CorruptError{Path: cp.path, Err: err}
+	}
+
+	return cp, nil
+}
+
+// load decodes a checkpoint state, adding processed paths to the set as
+// they are read instead of materializing the whole list first
+func (c *checkpoint) load(dec *json.Decoder) error {
+	return jsonstream.Object(dec, func(key string) error {
+		switch key {
+		case "processed":
+			return jsonstream.Array(dec, func() error {
+				var path string
+				if err := dec.Decode(&path); err != nil {
+					return err
+				}
+				c.processed[path] = struct{}{}
+				return nil
+			})
+		case "files":
+			return dec.Decode(&c.files)
+		case "functions":
+			return dec.Decode(&c.functions)
+		default:
+			return jsonstream.Skip(dec)
+		}
+	})
+}
+
+// Done reports whether a file has already been processed
+func (c *checkpoint) Done(path string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.processed[path]
+	return ok
+}
+
+// Len returns the number of processed files
+func (c *checkpoint) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.processed)
+}
+
+// Counts returns the number of files and functions with written metadata
+func (c *checkpoint) Counts() (files, functions int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.files, c.functions
+}
+
+// MarkWritten records a file whose metadata was written
+func (c *checkpoint) MarkWritten(path string, functions int) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.files++
+	c.functions += functions
+	return c.markDone(path)
+}
+
+// MarkDone records a processed file without written metadata
+func (c *checkpoint) MarkDone(path string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.markDone(path)
+}
+
+// markDone records a processed file and saves the checkpoint every interval
+// files; the caller must hold the mutex
+func (c *checkpoint) markDone(path string) error {
+	c.processed[path] = struct{}{}
+	c.pending++
+	if c.pending < c.interval {
+		return nil
+	}
+	return c.save()
+}
+
+// Save writes the checkpoint to disk
+func (c *checkpoint) Save() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.save()
+}
+
+// Remove deletes the checkpoint after a completed run
+func (c *checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checkpoint: %v", err)
+	}
+	return nil
+}
+
+// save writes the checkpoint to disk; the caller must hold the mutex
+func (c *checkpoint) save() error {
+	if c.flush != nil {
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+
+	state := checkpointState{
+		Processed: make([]string, 0, len(c.processed)),
+		Files:     c.files,
+		Functions: c.functions,
+	}
+	for path := range c.processed {
+		state.Processed = append(state.Processed, path)
+	}
+	sort.Strings(state.Processed)
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated checkpoint
+	if err := fsutil.WriteFileAtomic(c.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	c.pending = 0
+	return nil
+}
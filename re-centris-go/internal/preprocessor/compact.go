@@ -0,0 +1,122 @@
+package preprocessor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CompactStats describes a compaction of sharded output
+type CompactStats struct {
+	Kept         int   `json:"kept"`      // records kept
+	Functions    int   `json:"functions"` // functions of the kept records
+	Stale        int   `json:"stale"`     // records superseded by a later shard
+	Pruned       int   `json:"pruned"`    // records rejected by the keep function
+	ShardsBefore int   `json:"shards_before"`
+	ShardsAfter  int   `json:"shards_after"`
+	SizeBefore   int64 `json:"size_before"` // bytes of the shard files
+	SizeAfter    int64 `json:"size_after"`
+}
+
+// Compact rewrites the sharded output in dir into new shards of shardSize
+// holding only the current records for which keep returns true, or all of
+// them if keep is nil, and recomputes the function frequency. The old
+// shards and shards missing from the index, e.g. left by an interrupted
+// run, are removed after the new index is written, so an interrupted
+// compaction leaves the output readable.
+func Compact(dir string, shardSize int64, keep func(*FileMetadata) bool) (*CompactStats, error) {
+	if _, err := os.Stat(filepath.Join(dir, checkpointFile)); err == nil {
+		return nil, fmt.Errorf("%s has the checkpoint of an interrupted run, resume it before compacting", dir)
+	}
+	index, err := ReadShardIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	stats := &CompactStats{}
+	before, err := shardFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	stats.ShardsBefore = len(before)
+	for _, info := range before {
+		stats.SizeBefore += info.Size()
+	}
+
+	// New shards are numbered after the old ones, which stay in place
+	// until the new index replaces the old one
+	w, err := newShardWriter(dir, shardSize, false)
+	if err != nil {
+		return nil, err
+	}
+	w.next = nextShard(index.Shards)
+	frequency := newFrequencyCounter(dir)
+
+	for _, shard := range index.Shards {
+		shard := shard
+		err := readShard(filepath.Join(dir, shard), func(metadata *FileMetadata) error {
+			switch {
+			case index.Files[metadata.Path] != shard:
+				stats.Stale++
+				return nil
+			case keep != nil && !keep(metadata):
+				stats.Pruned++
+				return nil
+			}
+			stats.Kept++
+			stats.Functions += len(metadata.Functions)
+			frequency.add(metadata)
+			return w.Write(metadata)
+		})
+		if err != nil {
+			w.abort()
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		w.abort()
+		return nil, err
+	}
+	if err := WriteFunctionFrequency(dir, frequency.frequency()); err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]bool, len(w.index.Shards))
+	for _, shard := range w.index.Shards {
+		live[shard] = true
+	}
+	for name := range before {
+		if live[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("failed to remove shard: %v", err)
+		}
+	}
+
+	after, err := shardFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	stats.ShardsAfter = len(after)
+	for _, info := range after {
+		stats.SizeAfter += info.Size()
+	}
+	return stats, nil
+}
+
+// shardFiles returns the shard files in dir by name
+func shardFiles(dir string) (map[string]os.FileInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "shard-*.jsonl.zst"))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]os.FileInfo, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat shard: %v", err)
+		}
+		files[filepath.Base(path)] = info
+	}
+	return files, nil
+}
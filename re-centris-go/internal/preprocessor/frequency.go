@@ -0,0 +1,109 @@
+package preprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/artifact"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// FrequencyFile is the name of the function frequency file in the output
+// directory
+const FrequencyFile = "function_frequency.json"
+
+// FunctionFrequency records how many components of the corpus contain each
+// function signature. Only signatures found in more than one component are
+// listed.
+type FunctionFrequency struct {
+	Components int            `json:"components"`
+	Functions  map[string]int `json:"functions"`
+}
+
+// frequencyCounter collects the components containing each function
+// signature of a run
+type frequencyCounter struct {
+	root       string
+	functions  map[string]map[string]struct{}
+	components map[string]struct{}
+	mutex      sync.Mutex
+}
+
+// newFrequencyCounter creates a counter for the files below root
+func newFrequencyCounter(root string) *frequencyCounter {
+	return &frequencyCounter{
+		root:       root,
+		functions:  make(map[string]map[string]struct{}),
+		components: make(map[string]struct{}),
+	}
+}
+
+// add counts the functions of a file
+func (c *frequencyCounter) add(metadata *FileMetadata) {
+	component := metadata.Component
+	if component == "" {
+		component = artifact.ComponentOf(c.root, metadata.Path)
+	}
+	if component == "" {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.components[component] = struct{}{}
+	for _, fn := range metadata.Functions {
+		components := c.functions[fn.Hash]
+		if components == nil {
+			components = make(map[string]struct{})
+			c.functions[fn.Hash] = components
+		}
+		components[component] = struct{}{}
+	}
+}
+
+// frequency returns the function frequency counted so far
+func (c *frequencyCounter) frequency() *FunctionFrequency {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	f := &FunctionFrequency{
+		Components: len(c.components),
+		Functions:  make(map[string]int),
+	}
+	for hash, components := range c.functions {
+		if len(components) > 1 {
+			f.Functions[hash] = len(components)
+		}
+	}
+	return f
+}
+
+// WriteFunctionFrequency writes the function frequency file to dir
+func WriteFunctionFrequency(dir string, f *FunctionFrequency) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to marshal function frequency: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, FrequencyFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write function frequency: %v", err)
+	}
+	return nil
+}
+
+// ReadFunctionFrequency reads the function frequency file from dir
+func ReadFunctionFrequency(dir string) (*FunctionFrequency, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FrequencyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read function frequency: %v", err)
+	}
+
+	var f FunctionFrequency
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, &fsutil.CorruptError{Path: filepath.Join(dir, FrequencyFile), Err: err}
+	}
+	return &f, nil
+}
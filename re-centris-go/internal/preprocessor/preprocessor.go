@@ -6,92 +6,360 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
+	"time"
 
 	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/normalize"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/artifact"
+	"github.com/re-centris/re-centris-go/internal/bodies"
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+	"github.com/re-centris/re-centris-go/internal/common/cache"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
 	"github.com/re-centris/re-centris-go/internal/common/logger"
-	"golang.org/x/sync/errgroup"
+	"github.com/re-centris/re-centris-go/internal/common/progress"
+	"github.com/re-centris/re-centris-go/internal/common/workpool"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"go.uber.org/zap"
 )
 
 // FileMetadata contains metadata about a processed file
 type FileMetadata struct {
-	Path       string            `json:"path"`
-	Language   string            `json:"language"`
-	Hash       string            `json:"hash"`
-	Size       int64            `json:"size"`
-	Functions  []FunctionInfo    `json:"functions,omitempty"`
+	Path      string         `json:"path"`
+	Component string         `json:"component,omitempty"`
+	Language  string         `json:"language"`
+	Hash      string         `json:"hash"`
+	Size      int64          `json:"size"`
+	Functions []FunctionInfo `json:"functions,omitempty"`
+	// Digest and NormalizedDigest classify clones, see analyzer.FileInfo
+	Digest           string `json:"digest,omitempty"`
+	NormalizedDigest string `json:"normalized_digest,omitempty"`
 }
 
 // FunctionInfo contains information about a function
 type FunctionInfo struct {
-	Name       string `json:"name"`
-	StartLine  int    `json:"start_line"`
-	EndLine    int    `json:"end_line"`
-	Hash       string `json:"hash"`
+	Name      string `json:"name"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Hash      string `json:"hash"`
+	// Scope and Signature tell overloads apart, see parser.Function
+	Scope     string `json:"scope,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// Body is the digest of the function body in the body store, if
+	// bodies are stored, see bodies.Store
+	Body string `json:"body,omitempty"`
+	// Complexity, Tokens and Depth measure the function body
+	Complexity int `json:"complexity,omitempty"`
+	Tokens     int `json:"tokens,omitempty"`
+	Depth      int `json:"depth,omitempty"`
+}
+
+// NewFileMetadata returns the metadata of an analyzed file. Functions too
+// small to be hashed are omitted.
+func NewFileMetadata(file *analyzer.FileInfo) *FileMetadata {
+	metadata := &FileMetadata{
+		Path:             file.Path,
+		Language:         file.Language,
+		Hash:             file.Hash.String(),
+		Size:             file.Size,
+		Digest:           file.Digest,
+		NormalizedDigest: file.NormalizedDigest,
+	}
+
+	for _, fn := range file.Functions {
+		if fn.Hash == "" {
+			continue
+		}
+		metadata.Functions = append(metadata.Functions, FunctionInfo{
+			Name:       fn.Name,
+			StartLine:  fn.StartLine,
+			EndLine:    fn.EndLine,
+			Hash:       fn.Hash,
+			Scope:      fn.Scope,
+			Signature:  fn.Signature,
+			Complexity: fn.Complexity,
+			Tokens:     fn.Tokens,
+			Depth:      fn.Depth,
+		})
+	}
+
+	return metadata
+}
+
+// FileInfo converts metadata back into a FileInfo. Function contents are
+// not recorded, so they are empty.
+func (m *FileMetadata) FileInfo() (*analyzer.FileInfo, error) {
+	hash, err := tlsh.Parse(m.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash for %s: %v", m.Path, err)
+	}
+
+	functions := make([]parser.Function, len(m.Functions))
+	for i, fn := range m.Functions {
+		functions[i] = parser.Function{
+			Name:       fn.Name,
+			StartLine:  fn.StartLine,
+			EndLine:    fn.EndLine,
+			Hash:       fn.Hash,
+			Scope:      fn.Scope,
+			Signature:  fn.Signature,
+			Complexity: fn.Complexity,
+			Tokens:     fn.Tokens,
+			Depth:      fn.Depth,
+		}
+	}
+
+	return &analyzer.FileInfo{
+		Path:             m.Path,
+		Language:         m.Language,
+		Hash:             hash,
+		Size:             m.Size,
+		Functions:        functions,
+		Digest:           m.Digest,
+		NormalizedDigest: m.NormalizedDigest,
+		Component:        m.Component,
+	}, nil
 }
 
 // PreprocessorOptions contains options for the preprocessor
 type PreprocessorOptions struct {
-	MaxWorkers     int
-	OutputDir      string
-	Languages      map[string][]string
-	MinFileSize    int64
-	MaxFileSize    int64
+	MaxWorkers  int
+	OutputDir   string
+	Languages   map[string][]string
+	MinFileSize int64
+	// MaxFileSize and ChunkedSize limit the memory taken by large files,
+	// see analyzer.AnalyzerOptions
+	MaxFileSize int64
+	ChunkedSize int64
+	// FileTimeout and SlowFiles bound and report the time taken by a
+	// file, see analyzer.AnalyzerOptions
+	FileTimeout time.Duration
+	SlowFiles   *analyzer.SlowFileReport
+	// Resume continues an interrupted run from its last checkpoint
+	Resume bool
+	// CheckpointInterval is the number of files processed between checkpoints
+	CheckpointInterval int
+	// OutputFormat is FormatJSON (default), FormatSharded or FormatParquet
+	OutputFormat string
+	// ShardSize is the uncompressed size of a shard in bytes for FormatSharded
+	ShardSize int64
+	// ConfigHash identifies the configuration recorded in the corpus manifest
+	ConfigHash string
+	// Progress, if set, reports the progress of analysis and preprocessing
+	Progress *progress.Reporter
+	// Pool, if set, is shared with other stages and limits the files
+	// analyzed and processed at once across them
+	Pool *workpool.Pool
+	// Cache, if set, persists the analysis of file contents across runs
+	Cache *cache.DiskCache
+	// Symlinks, Include, Exclude, GitIgnore and NoSniff select the analyzed
+	// files and Normalize how they are hashed, see analyzer.AnalyzerOptions
+	Symlinks  string
+	Include   []string
+	Exclude   []string
+	GitIgnore bool
+	NoSniff   bool
+	Normalize map[string]normalize.Options
+	// SniffLanguages detects the language of files without a supported
+	// extension from their content
+	SniffLanguages bool
+	// SignatureMode is the signature mode of the analyzer, see analyzer.SignatureBytes
+	SignatureMode string
+	// Encoding is the source encoding of the analyzer, see analyzer.ParseEncoding
+	Encoding string
+	// Conditionals is the conditional compilation mode of the C/C++ parser,
+	// see cpp.ParseConditionals
+	Conditionals string
+	// MinFunctionLines, MinFunctionTokens and MinFunctionComplexity drop
+	// trivial functions, see analyzer.AnalyzerOptions
+	MinFunctionLines      int
+	MinFunctionTokens     int
+	MinFunctionComplexity int
+	// ErrorPolicy and Errors are passed on to the analyzer, see
+	// analyzer.AnalyzerOptions
+	ErrorPolicy analyzer.ErrorPolicy
+	Errors      *analyzer.ErrorReport
+	// Versions collects the function signatures of the tagged versions of
+	// each repository, limited to the MaxVersions most recent (0 = all)
+	Versions    bool
+	MaxVersions int
+	// StoreBodies stores the function bodies content-addressed in the
+	// bodies directory of OutputDir, referenced from the signatures
+	StoreBodies bool
 }
 
 // Preprocessor handles file preprocessing
 type Preprocessor struct {
-	opts     PreprocessorOptions
-	analyzer *analyzer.Analyzer
+	opts       PreprocessorOptions
+	analyzer   *analyzer.Analyzer
+	checkpoint *checkpoint
+	shards     *shardWriter
+	tables     *artifact.Tables
+	bodies     *bodies.Store
 }
 
 // New creates a new Preprocessor
 func New(opts PreprocessorOptions) *Preprocessor {
-	return &Preprocessor{
-		opts: opts,
-		analyzer: analyzer.New(analyzer.AnalyzerOptions{
-			MaxWorkers: opts.MaxWorkers,
-			Languages:  opts.Languages,
-		}),
+	p := &Preprocessor{opts: opts}
+	if opts.StoreBodies {
+		p.bodies = bodies.Open(filepath.Join(opts.OutputDir, bodies.DirName))
 	}
+	p.analyzer = analyzer.New(analyzer.AnalyzerOptions{
+		MaxWorkers:            opts.MaxWorkers,
+		Pool:                  opts.Pool,
+		Cache:                 opts.Cache,
+		Languages:             opts.Languages,
+		Skip:                  p.processed,
+		Symlinks:              opts.Symlinks,
+		Include:               opts.Include,
+		Exclude:               opts.Exclude,
+		GitIgnore:             opts.GitIgnore,
+		NoSniff:               opts.NoSniff,
+		SniffLanguages:        opts.SniffLanguages,
+		Normalize:             opts.Normalize,
+		SignatureMode:         opts.SignatureMode,
+		Encoding:              opts.Encoding,
+		Conditionals:          opts.Conditionals,
+		MaxFileSize:           opts.MaxFileSize,
+		ChunkedSize:           opts.ChunkedSize,
+		FileTimeout:           opts.FileTimeout,
+		SlowFiles:             opts.SlowFiles,
+		Progress:              opts.Progress,
+		ErrorPolicy:           opts.ErrorPolicy,
+		Errors:                opts.Errors,
+		MinFunctionLines:      opts.MinFunctionLines,
+		MinFunctionTokens:     opts.MinFunctionTokens,
+		MinFunctionComplexity: opts.MinFunctionComplexity,
+	})
+	return p
+}
+
+// SkipStats returns the number of files skipped by kind, see
+// analyzer.Analyzer.SkipStats
+func (p *Preprocessor) SkipStats() map[string]int64 {
+	return p.analyzer.SkipStats()
 }
 
 // ProcessDirectory processes all files in a directory
 func (p *Preprocessor) ProcessDirectory(ctx context.Context, dir string) error {
+	if err := p.openCheckpoint(); err != nil {
+		return err
+	}
+
+	// Analyze all files in directory that were not processed by a previous run
+	files, err := p.analyzer.AnalyzeDirectory(ctx, dir)
+	if err != nil {
+		p.saveCheckpoint()
+		return fmt.Errorf("failed to analyze directory: %v", err)
+	}
+
+	return p.processFiles(ctx, dir, files)
+}
+
+// ProcessFiles processes files that have already been analyzed from dir
+func (p *Preprocessor) ProcessFiles(ctx context.Context, dir string, files []*analyzer.FileInfo) error {
+	if err := p.openCheckpoint(); err != nil {
+		return err
+	}
+
+	return p.processFiles(ctx, dir, files)
+}
+
+// openCheckpoint creates the output directory and loads the checkpoint
+func (p *Preprocessor) openCheckpoint() error {
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(p.opts.OutputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %v", err)
 	}
 
-	// Analyze all files in directory
-	files, err := p.analyzer.AnalyzeDirectory(ctx, dir)
+	cp, err := newCheckpoint(p.opts.OutputDir, p.opts.CheckpointInterval, p.opts.Resume)
 	if err != nil {
-		return fmt.Errorf("failed to analyze directory: %v", err)
+		return err
+	}
+
+	p.shards, p.tables = nil, nil
+	switch p.opts.OutputFormat {
+	case "", FormatJSON:
+	case FormatParquet:
+		// Parquet tables are written once at the end of a run
+		if p.opts.Resume {
+			return fmt.Errorf("resume is not supported with parquet output")
+		}
+		p.tables = &artifact.Tables{Bodies: p.bodies != nil}
+	case FormatSharded:
+		p.shards, err = newShardWriter(p.opts.OutputDir, p.opts.ShardSize, p.opts.Resume)
+		if err != nil {
+			return err
+		}
+		// Records must be on disk before their files count as processed
+		cp.flush = p.shards.Flush
+	default:
+		return fmt.Errorf("unsupported output format: %s", p.opts.OutputFormat)
+	}
+	if p.opts.Resume {
+		logger.Info("Resuming from checkpoint",
+			zap.Int("processed_files", cp.Len()))
 	}
 
-	// Process files in parallel
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(p.opts.MaxWorkers)
+	p.checkpoint = cp
+	return nil
+}
+
+// saveCheckpoint saves the checkpoint of an interrupted run
+func (p *Preprocessor) saveCheckpoint() {
+	if err := p.checkpoint.Save(); err != nil {
+		logger.Error("Failed to save checkpoint", zap.Error(err))
+	}
+}
+
+// processed reports whether a file was processed by a previous run
+func (p *Preprocessor) processed(path string) bool {
+	return p.checkpoint != nil && p.checkpoint.Done(path)
+}
+
+// processFiles writes metadata for all files not yet processed
+// and the corpus manifest of dir
+func (p *Preprocessor) processFiles(ctx context.Context, dir string, files []*analyzer.FileInfo) error {
+	// Process files in parallel. The group context ends with Wait, so the
+	// git commands collecting repositories below run with ctx.
+	g, gctx := workpool.WithContext(ctx, p.opts.Pool, p.opts.MaxWorkers)
+
+	stage := p.opts.Progress.Stage("preprocess", len(files))
+	frequency := newFrequencyCounter(dir)
 
 	for _, file := range files {
 		file := file // Create new variable for goroutine
+		if p.processed(file.Path) {
+			stage.Add(1)
+			continue
+		}
+
 		g.Go(func() error {
-			// Skip files that are too small or too large
-			if file.Size < p.opts.MinFileSize || 
-			   (p.opts.MaxFileSize > 0 && file.Size > p.opts.MaxFileSize) {
-				return nil
+			defer stage.Add(1)
+
+			if err := gctx.Err(); err != nil {
+				return err
 			}
 
-			metadata := &FileMetadata{
-				Path:     file.Path,
-				Language: file.Language,
-				Hash:     file.Hash.String(),
-				Size:     file.Size,
+			// Skip files that are too small; the analyzer skips large ones
+			if file.Size < p.opts.MinFileSize {
+				return p.checkpoint.MarkDone(file.Path)
 			}
 
-			// Extract functions if supported
-			if funcs, err := p.extractFunctions(file); err == nil {
-				metadata.Functions = funcs
+			// Record the component, which the detector can't derive from
+			// a path relative to the working directory of this run
+			metadata := NewFileMetadata(file)
+			metadata.Component = artifact.ComponentOf(dir, file.Path)
+			frequency.add(metadata)
+			if err := p.storeBodies(file, metadata); err != nil {
+				return err
+			}
+
+			// Parquet tables are collected in memory
+			if p.tables != nil {
+				p.tables.Add(dir, file)
+				return p.checkpoint.MarkWritten(file.Path, len(metadata.Functions))
 			}
 
 			// Save metadata
@@ -102,28 +370,168 @@ func (p *Preprocessor) ProcessDirectory(ctx context.Context, dir string) error {
 				return err
 			}
 
-			return nil
+			return p.checkpoint.MarkWritten(file.Path, len(metadata.Functions))
 		})
 	}
 
-	return g.Wait()
+	if err := g.Wait(); err != nil {
+		p.saveCheckpoint()
+		return err
+	}
+	stage.Done()
+
+	if p.shards != nil {
+		if err := p.shards.Close(); err != nil {
+			return err
+		}
+	}
+	if p.bodies != nil {
+		stats := p.bodies.Stats()
+		logger.Info("Function bodies stored",
+			zap.Int64("stored", stats.Stored),
+			zap.Int64("shared", stats.Shared),
+			zap.Int64("bytes", stats.Bytes))
+	}
+
+	repos, err := manifest.CollectRepositories(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	if p.tables != nil {
+		p.tables.Versions = make(map[string]string)
+		for _, repo := range repos {
+			p.tables.Versions[repo.Name] = repo.Commit
+		}
+		if err := p.tables.WriteParquet(p.opts.OutputDir); err != nil {
+			return err
+		}
+	}
+
+	if err := p.writeFrequency(dir, frequency); err != nil {
+		return err
+	}
+
+	if err := p.writeManifest(repos); err != nil {
+		return err
+	}
+
+	if p.opts.Versions {
+		if err := p.collectVersions(ctx, dir, repos); err != nil {
+			return err
+		}
+	}
+
+	// The run completed, so the checkpoint is no longer needed
+	return p.checkpoint.Remove()
 }
 
-// extractFunctions extracts function information from a file
-func (p *Preprocessor) extractFunctions(file *analyzer.FileInfo) ([]FunctionInfo, error) {
-	// TODO: Implement function extraction using language-specific parsers
-	// This is a placeholder that should be replaced with actual implementation
-	return nil, nil
+// storeBodies puts the bodies of the functions of a file into the body
+// store, if bodies are stored, and references them from its metadata
+func (p *Preprocessor) storeBodies(file *analyzer.FileInfo, metadata *FileMetadata) error {
+	if p.bodies == nil {
+		return nil
+	}
+	i := 0
+	for _, fn := range file.Functions {
+		// NewFileMetadata omits the functions without a hash
+		if fn.Hash == "" {
+			continue
+		}
+		digest, err := p.bodies.Put(fn.Content)
+		if err != nil {
+			return err
+		}
+		metadata.Functions[i].Body = digest
+		i++
+	}
+	return nil
 }
 
-// saveMetadata saves file metadata to JSON file
+// writeFrequency writes the function frequency of the completed build. A
+// resumed run only counted the files it processed, so sharded output is
+// counted again from the shards.
+func (p *Preprocessor) writeFrequency(dir string, frequency *frequencyCounter) error {
+	if p.opts.Resume {
+		if p.shards == nil {
+			logger.Warn("Function frequency of a resumed run only counts the files it processed")
+		} else {
+			frequency = newFrequencyCounter(dir)
+			err := ReadShards(p.opts.OutputDir, func(metadata *FileMetadata) error {
+				frequency.add(metadata)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	f := frequency.frequency()
+	if err := WriteFunctionFrequency(p.opts.OutputDir, f); err != nil {
+		return err
+	}
+
+	logger.Info("Function frequency written",
+		zap.Int("components", f.Components),
+		zap.Int("shared_functions", len(f.Functions)))
+	return nil
+}
+
+// collectVersions writes the signatures of the tagged versions of each
+// repository, see versions.Collector
+func (p *Preprocessor) collectVersions(ctx context.Context, dir string, repos []manifest.Repository) error {
+	collector := versions.New(versions.CollectorOptions{
+		OutputDir:   p.opts.OutputDir,
+		MaxVersions: p.opts.MaxVersions,
+		Analyzer:    p.analyzer,
+		Resume:      p.opts.Resume,
+		Bodies:      p.bodies,
+	})
+	for _, repo := range repos {
+		if err := collector.Collect(ctx, filepath.Join(dir, repo.Name), repo.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeManifest records the corpus manifest of the completed build
+func (p *Preprocessor) writeManifest(repos []manifest.Repository) error {
+	m := manifest.New()
+	m.ConfigHash = p.opts.ConfigHash
+	m.Normalization = normalize.Describe(p.opts.Normalize)
+	m.SignatureMode, _ = analyzer.ParseSignatureMode(p.opts.SignatureMode)
+	m.Encoding = analyzer.EncodingKey(p.opts.Encoding)
+	m.Repositories = repos
+	m.TotalFiles, m.TotalFunctions = p.checkpoint.Counts()
+
+	hash, err := manifest.Write(p.opts.OutputDir, m)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Corpus manifest written",
+		zap.String("hash", hash),
+		zap.Int("repositories", len(repos)),
+		zap.Int("total_files", m.TotalFiles),
+		zap.Int("total_functions", m.TotalFunctions))
+
+	return nil
+}
+
+// saveMetadata saves file metadata to a JSON file or the current shard
 func (p *Preprocessor) saveMetadata(metadata *FileMetadata) error {
+	if p.shards != nil {
+		return p.shards.Write(metadata)
+	}
+
 	// Create output filename based on file path
 	relPath, err := filepath.Rel("/", metadata.Path)
 	if err != nil {
 		relPath = metadata.Path
 	}
-	outPath := filepath.Join(p.opts.OutputDir, 
+	outPath := filepath.Join(p.opts.OutputDir,
 		fmt.Sprintf("%s.json", filepath.ToSlash(relPath)))
 
 	// Create parent directories if they don't exist
@@ -138,9 +546,9 @@ func (p *Preprocessor) saveMetadata(metadata *FileMetadata) error {
 	}
 
 	// Write to file
-	if err := os.WriteFile(outPath, data, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(outPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write metadata: %v", err)
 	}
 
 	return nil
-} 
\ No newline at end of file
+}
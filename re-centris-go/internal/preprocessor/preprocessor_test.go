@@ -0,0 +1,63 @@
+package preprocessor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+)
+
+func TestProcessDirectoryManifest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	corpus := t.TempDir()
+	repo := filepath.Join(corpus, "acme%lib")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := strings.Repeat("int f(int x) { return x * 3 + 7; }\n", 20)
+	if err := os.WriteFile(filepath.Join(repo, "lib.c"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"remote", "add", "origin", "https://github.com/acme/lib.git"},
+		{"add", "lib.c"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	out := t.TempDir()
+	p := New(PreprocessorOptions{
+		MaxWorkers:         2,
+		OutputDir:          out,
+		Languages:          analyzer.DefaultLanguages(),
+		CheckpointInterval: 1000,
+	})
+	if err := p.ProcessDirectory(context.Background(), corpus); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _, err := manifest.Read(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Repositories) != 1 {
+		t.Fatalf("got repositories %+v, want acme%%lib", m.Repositories)
+	}
+	repoInfo := m.Repositories[0]
+	if repoInfo.URL != "https://github.com/acme/lib.git" || len(repoInfo.Commit) != 40 {
+		t.Errorf("got repository %+v, want its URL and commit", repoInfo)
+	}
+}
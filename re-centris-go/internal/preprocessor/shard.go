@@ -0,0 +1,415 @@
+package preprocessor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/jsonstream"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// shardIndexFile is the name of the index mapping file paths to shards
+	shardIndexFile = "index.json"
+
+	// defaultShardSize is the default uncompressed size of a shard in bytes
+	defaultShardSize = 64 << 20
+)
+
+// Output formats supported by the preprocessor
+const (
+	// FormatJSON writes one pretty-printed JSON file per source file
+	FormatJSON = "json"
+	// FormatSharded appends metadata records to zstd-compressed JSONL shards
+	FormatSharded = "sharded"
+	// FormatParquet writes file and function tables as Parquet files
+	FormatParquet = "parquet"
+)
+
+// ShardIndex maps the path of every processed file to the shard containing it
+type ShardIndex struct {
+	Shards []string          `json:"shards"`
+	Files  map[string]string `json:"files"`
+}
+
+// shardWriter appends metadata records to size-limited, zstd-compressed
+// JSONL shards and keeps an index of which shard holds which file
+type shardWriter struct {
+	dir     string
+	maxSize int64
+	index   ShardIndex
+	next    int // number of the next shard
+	file    *os.File
+	encoder *zstd.Encoder
+	written int64
+	listed  int // number of shards listed by the index on disk
+	mutex   sync.Mutex
+}
+
+// newShardWriter creates a shard writer in dir. Shards are rotated once
+// maxSize uncompressed bytes have been written to them. If resume is set,
+// the shards of a previous run are kept and new shards are appended.
+func newShardWriter(dir string, maxSize int64, resume bool) (*shardWriter, error) {
+	if maxSize <= 0 {
+		maxSize = defaultShardSize
+	}
+
+	w := &shardWriter{
+		dir:     dir,
+		maxSize: maxSize,
+		index: ShardIndex{
+			Files: make(map[string]string),
+		},
+	}
+
+	if !resume {
+		return w, nil
+	}
+
+	// Continue the existing index so new shards are appended
+	if _, err := os.Stat(filepath.Join(dir, shardIndexFile)); os.IsNotExist(err) {
+		return w, nil
+	}
+	index, err := ReadShardIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	w.index = *index
+	w.next = nextShard(index.Shards)
+
+	// Records flushed to the last shard since the index was written are
+	// missing from it
+	if len(index.Shards) > 0 {
+		if err := w.recoverShard(index.Shards[len(index.Shards)-1]); err != nil {
+			return nil, err
+		}
+	}
+	w.listed = len(w.index.Shards)
+
+	return w, nil
+}
+
+// recoverShard adds the records of a shard flushed by an interrupted run to
+// the index. A shard whose run never finished it ends in a truncated zstd
+// frame, so its records are rewritten into a complete shard of the same
+// name.
+func (w *shardWriter) recoverShard(shard string) error {
+	path := filepath.Join(w.dir, shard)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	output, err := fsutil.CreateAtomic(path, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create shard: %v", err)
+	}
+	defer output.Abort()
+	encoder, err := zstd.NewWriter(output)
+	if err != nil {
+		return fmt.Errorf("failed to create shard encoder: %v", err)
+	}
+
+	err = readShard(path, func(metadata *FileMetadata) error {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %v", err)
+		}
+		if _, err := encoder.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write shard: %v", err)
+		}
+		w.index.Files[metadata.Path] = shard
+		return nil
+	})
+	var corrupt *fsutil.CorruptError
+	if err == nil || !errors.As(err, &corrupt) {
+		encoder.Close()
+		return err
+	}
+
+	logger.Warn("Recovering the records of an unfinished shard",
+		zap.String("shard", path),
+		zap.Error(err))
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to finish shard: %v", err)
+	}
+	if err := output.Commit(); err != nil {
+		return fmt.Errorf("failed to write shard: %v", err)
+	}
+	return nil
+}
+
+// nextShard returns the number following the highest numbered of shards,
+// so that new shards never overwrite them
+func nextShard(shards []string) int {
+	next := 0
+	for _, shard := range shards {
+		var n int
+		if _, err := fmt.Sscanf(shard, "shard-%d.jsonl.zst", &n); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	return next
+}
+
+// ReadShardIndex reads the shard index in dir. The file map is decoded
+// entry by entry so that the index of a large corpus is never held in
+// memory twice.
+func ReadShardIndex(dir string) (*ShardIndex, error) {
+	file, err := os.Open(filepath.Join(dir, shardIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shard index: %v", err)
+	}
+	defer file.Close()
+
+	index := &ShardIndex{
+		Files: make(map[string]string),
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+	err = jsonstream.Object(dec, func(key string) error {
+		switch key {
+		case "shards":
+			return dec.Decode(&index.Shards)
+		case "files":
+			return jsonstream.Object(dec, func(path string) error {
+				var shard string
+				if err := dec.Decode(&shard); err != nil {
+					return err
+				}
+				index.Files[path] = shard
+				return nil
+			})
+		default:
+			return jsonstream.Skip(dec)
+		}
+	})
+	if err != nil {
+		return nil, &fsutil.CorruptError{Path: filepath.Join(dir, shardIndexFile), Err: err}
+	}
+
+	return index, nil
+}
+
+// Write appends a metadata record to the current shard
+func (w *shardWriter) Write(metadata *FileMetadata) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %v", err)
+	}
+	data = append(data, '\n')
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.encoder == nil || w.written >= w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.encoder.Write(data); err != nil {
+		return fmt.Errorf("failed to write shard: %v", err)
+	}
+	w.written += int64(len(data))
+	w.index.Files[metadata.Path] = w.index.Shards[len(w.index.Shards)-1]
+
+	return nil
+}
+
+// Close finishes the current shard and writes the index. Writing more
+// records after Close starts a new shard.
+func (w *shardWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.closeShard(); err != nil {
+		return err
+	}
+	if err := WriteShardIndex(w.dir, &w.index); err != nil {
+		return err
+	}
+	w.listed = len(w.index.Shards)
+	return nil
+}
+
+// Flush makes the records written so far durable without finishing the
+// current shard, for a checkpoint. The index is only written when shards
+// were added since it was last written, as records of the last shard are
+// recovered from the shard by a resumed run.
+func (w *shardWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return fmt.Errorf("failed to flush shard: %v", err)
+		}
+		if fsutil.Syncing() {
+			if err := w.file.Sync(); err != nil {
+				return fmt.Errorf("failed to sync shard: %v", err)
+			}
+		}
+	}
+	if len(w.index.Shards) == w.listed {
+		return nil
+	}
+	if err := WriteShardIndex(w.dir, &w.index); err != nil {
+		return err
+	}
+	w.listed = len(w.index.Shards)
+	return nil
+}
+
+// WriteShardIndex writes the shard index of the shards in dir
+func WriteShardIndex(dir string, index *ShardIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shard index: %v", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated index
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, shardIndexFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write shard index: %v", err)
+	}
+
+	return nil
+}
+
+// abort closes the current shard and removes the shards written, leaving
+// the index in place
+func (w *shardWriter) abort() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.closeShard()
+	for _, shard := range w.index.Shards {
+		os.Remove(filepath.Join(w.dir, shard))
+	}
+}
+
+// rotate closes the current shard and opens the next one; the caller must hold the mutex
+func (w *shardWriter) rotate() error {
+	if err := w.closeShard(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("shard-%05d.jsonl.zst", w.next)
+	w.next++
+	file, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create shard: %v", err)
+	}
+
+	encoder, err := zstd.NewWriter(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to create shard encoder: %v", err)
+	}
+
+	w.file = file
+	w.encoder = encoder
+	w.written = 0
+	w.index.Shards = append(w.index.Shards, name)
+	return nil
+}
+
+// closeShard flushes and closes the current shard; the caller must hold the mutex
+func (w *shardWriter) closeShard() error {
+	if w.encoder == nil {
+		return nil
+	}
+
+	if err := w.encoder.Close(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to finish shard: %v", err)
+	}
+	if fsutil.Syncing() {
+		if err := w.file.Sync(); err != nil {
+			w.file.Close()
+			return fmt.Errorf("failed to sync shard: %v", err)
+		}
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close shard: %v", err)
+	}
+
+	w.encoder = nil
+	w.file = nil
+	return nil
+}
+
+// ReadShards reads all metadata records of the sharded output in dir and
+// calls fn for each of them
+func ReadShards(dir string, fn func(*FileMetadata) error) error {
+	index, err := ReadShardIndex(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, shard := range index.Shards {
+		shard := shard
+		path := filepath.Join(dir, shard)
+		err := readShard(path, func(metadata *FileMetadata) error {
+			// Skip stale records of files rewritten to a later shard by a resumed run
+			if index.Files[metadata.Path] != shard {
+				return nil
+			}
+			return fn(metadata)
+		})
+		var corrupt *fsutil.CorruptError
+		if errors.As(err, &corrupt) {
+			logger.Warn("Skipping the rest of a corrupt shard",
+				zap.String("shard", path),
+				zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readShard reads all metadata records of a single shard. A shard that
+// cannot be decoded is reported as a fsutil.CorruptError after the records
+// before the damage.
+func readShard(path string, fn func(*FileMetadata) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open shard: %v", err)
+	}
+	defer file.Close()
+
+	decoder, err := zstd.NewReader(file)
+	if err != nil {
+		return &fsutil.CorruptError{Path: path, Err: err}
+	}
+	defer decoder.Close()
+
+	scanner := bufio.NewScanner(decoder)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var metadata FileMetadata
+		if err := json.Unmarshal(scanner.Bytes(), &metadata); err != nil {
+			return &fsutil.CorruptError{Path: path, Err: err}
+		}
+		if err := fn(&metadata); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return &fsutil.CorruptError{Path: path, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package preprocessor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+func TestShardWriter(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := newShardWriter(dir, 128, false)
+	if err != nil {
+		t.Fatalf("newShardWriter() error = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		metadata := &FileMetadata{Path: fmt.Sprintf("src/file%d.c", i), Language: "cpp", Size: int64(i)}
+		if err := w.Write(metadata); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(w.index.Shards) < 2 {
+		t.Errorf("got %d shards, want rotation into several shards", len(w.index.Shards))
+	}
+
+	// A resumed run rewriting a file must not produce duplicate records
+	w, err = newShardWriter(dir, 128, true)
+	if err != nil {
+		t.Fatalf("newShardWriter() error = %v", err)
+	}
+	if err := w.Write(&FileMetadata{Path: "src/file3.c", Language: "cpp", Size: 42}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	seen := make(map[string]int64)
+	err = ReadShards(dir, func(metadata *FileMetadata) error {
+		if _, exists := seen[metadata.Path]; exists {
+			t.Errorf("duplicate record for %s", metadata.Path)
+		}
+		seen[metadata.Path] = metadata.Size
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadShards() error = %v", err)
+	}
+
+	if len(seen) != 10 {
+		t.Errorf("ReadShards() returned %d files, want 10", len(seen))
+	}
+	if seen["src/file3.c"] != 42 {
+		t.Errorf("file3.c size = %d, want rewritten size 42", seen["src/file3.c"])
+	}
+
+	// A truncated shard is skipped and reported as corrupt
+	shard := filepath.Join(dir, w.index.Shards[0])
+	if err := os.WriteFile(shard, []byte("not zstd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var corrupt *fsutil.CorruptError
+	if err := readShard(shard, func(*FileMetadata) error { return nil }); !errors.As(err, &corrupt) {
+		t.Errorf("readShard() error = %v, want a CorruptError", err)
+	}
+	n := 0
+	err = ReadShards(dir, func(*FileMetadata) error {
+		n++
+		return nil
+	})
+	if err != nil || n == 0 || n >= 10 {
+		t.Errorf("ReadShards() read %d files, error %v, want the files of the other shards", n, err)
+	}
+}
+
+func TestShardWriterFlush(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := newShardWriter(dir, 1<<20, false)
+	if err != nil {
+		t.Fatalf("newShardWriter() error = %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		if err := w.Write(&FileMetadata{Path: fmt.Sprintf("src/file%d.c", i), Language: "cpp"}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		// Checkpoints keep appending to the current shard
+		if err := w.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+	}
+	if len(w.index.Shards) != 1 {
+		t.Errorf("got %d shards after flushing, want 1", len(w.index.Shards))
+	}
+
+	// A run interrupted after a flush leaves an unfinished shard, whose
+	// records a resumed run recovers
+	w.file.Close()
+	w, err = newShardWriter(dir, 1<<20, true)
+	if err != nil {
+		t.Fatalf("newShardWriter() error = %v", err)
+	}
+	if err := w.Write(&FileMetadata{Path: "src/file6.c", Language: "cpp"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	shard := filepath.Join(dir, w.index.Shards[0])
+	if err := readShard(shard, func(*FileMetadata) error { return nil }); err != nil {
+		t.Errorf("readShard() of the recovered shard error = %v", err)
+	}
+	n := 0
+	if err := ReadShards(dir, func(*FileMetadata) error {
+		n++
+		return nil
+	}); err != nil || n != 7 {
+		t.Errorf("ReadShards() read %d files, error %v, want 7", n, err)
+	}
+}
+
+func TestFrequencyCounter(t *testing.T) {
+	c := newFrequencyCounter("/known")
+	c.add(&FileMetadata{Path: "/known/a/x.c", Functions: []FunctionInfo{{Hash: "h1"}, {Hash: "h2"}}})
+	c.add(&FileMetadata{Path: "/known/a/y.c", Functions: []FunctionInfo{{Hash: "h2"}}})
+	c.add(&FileMetadata{Path: "/known/b/z.c", Functions: []FunctionInfo{{Hash: "h1"}}})
+
+	f := c.frequency()
+	if f.Components != 2 || len(f.Functions) != 1 || f.Functions["h1"] != 2 {
+		t.Errorf("frequency() = %+v, want only h1 in 2 of 2 components", f)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := newShardWriter(dir, 128, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		component := []string{"kept", "removed"}[i%2]
+		if err := w.Write(&FileMetadata{Path: fmt.Sprintf("%s/file%d.c", component, i), Component: component}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err = newShardWriter(dir, 128, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&FileMetadata{Path: "kept/file0.c", Component: "kept", Size: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Compact(dir, 1<<20, func(m *FileMetadata) bool { return m.Component == "kept" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Kept != 3 || stats.Stale != 1 || stats.Pruned != 3 || stats.ShardsAfter != 1 {
+		t.Errorf("Compact() = %+v, want 3 kept, 1 stale, 3 pruned in 1 shard", stats)
+	}
+
+	sizes := make(map[string]int64)
+	if err := ReadShards(dir, func(m *FileMetadata) error {
+		sizes[m.Path] = m.Size
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes["kept/file0.c"] != 42 {
+		t.Errorf("compacted records = %v, want the 3 current kept files", sizes)
+	}
+
+	// Shards appended after a compaction must not overwrite its shards
+	w, err = newShardWriter(dir, 128, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&FileMetadata{Path: "kept/new.c", Component: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	if err := ReadShards(dir, func(*FileMetadata) error {
+		n++
+		return nil
+	}); err != nil || n != 4 {
+		t.Errorf("ReadShards() read %d files, error %v, want 4", n, err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package provenance estimates when and by whom the matched code of targets
+// in git repositories was introduced, using git blame.
+package provenance
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/gitutil"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"go.uber.org/zap"
+)
+
+// uncommitted reports whether hash is the all-zero commit git blame
+// reports for lines not committed yet
+func uncommitted(hash string) bool {
+	return strings.Trim(hash, "0") == ""
+}
+
+// commitHash reports whether s is a SHA-1 or SHA-256 commit hash
+func commitHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// commit holds the blame information of a commit
+type commit struct {
+	author  string
+	date    time.Time
+	summary string
+}
+
+// blame holds the commit of every line of a file
+type blame struct {
+	lines   map[int]string // line number to commit hash
+	commits map[string]*commit
+}
+
+// Annotate attaches the provenance of the matched lines to the matches of
+// results whose target file is tracked in a git repository. The matched
+// lines are the target lines of the match evidence, or the whole file
+// without evidence. Targets outside git repositories are skipped.
+func Annotate(ctx context.Context, results []*detector.DetectionResult) error {
+	for _, result := range results {
+		if len(result.Matches) == 0 {
+			continue
+		}
+		if _, err := os.Stat(result.TargetFile); err != nil {
+			continue // archive entries have no file on disk
+		}
+
+		b, err := blameFile(ctx, result.TargetFile)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			logger.Debug("Failed to blame target file",
+				zap.String("file", result.TargetFile),
+				zap.Error(err))
+			continue
+		}
+
+		for i := range result.Matches {
+			m := &result.Matches[i]
+			m.Provenance = b.provenance(m.Evidence)
+		}
+	}
+	return nil
+}
+
+// blameFile runs git blame on a file in the repository containing it
+func blameFile(ctx context.Context, path string) (*blame, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	output, err := gitutil.Output(ctx, filepath.Dir(abs), "blame", "--line-porcelain", "--", filepath.Base(abs))
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(output), nil
+}
+
+// parseBlame parses the output of git blame --line-porcelain. Every line
+// of the file is preceded by a header naming its commit and, in line
+// porcelain mode, all information of the commit.
+func parseBlame(output string) *blame {
+	b := &blame{
+		lines:   make(map[int]string),
+		commits: make(map[string]*commit),
+	}
+
+	var current *commit
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			continue // line content
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			current.author = value
+		case "author-time":
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.date = time.Unix(seconds, 0).UTC()
+			}
+		case "summary":
+			current.summary = value
+		default:
+			// A header "<hash> <original line> <final line> [<lines>]"
+			fields := strings.Fields(line)
+			if !commitHash(key) || len(fields) < 3 {
+				continue
+			}
+			final, err := strconv.Atoi(fields[2])
+			if err != nil {
+				continue
+			}
+			b.lines[final] = key
+			if b.commits[key] == nil {
+				b.commits[key] = &commit{}
+			}
+			current = b.commits[key]
+		}
+	}
+	return b
+}
+
+// provenance returns the commit introducing most of the lines of the
+// evidence, the earliest among equals. Without evidence all lines count.
+func (b *blame) provenance(evidence []detector.Evidence) *detector.Provenance {
+	counts := make(map[string]int)
+	count := func(line int) {
+		if hash, ok := b.lines[line]; ok && !uncommitted(hash) {
+			counts[hash]++
+		}
+	}
+	if len(evidence) == 0 {
+		for line := range b.lines {
+			count(line)
+		}
+	}
+	seen := make(map[int]bool)
+	for _, e := range evidence {
+		for line := e.TargetLines.Start; line <= e.TargetLines.End; line++ {
+			if !seen[line] {
+				seen[line] = true
+				count(line)
+			}
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, len(counts))
+	total := 0
+	for hash, n := range counts {
+		hashes = append(hashes, hash)
+		total += n
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		a, c := hashes[i], hashes[j]
+		if counts[a] != counts[c] {
+			return counts[a] > counts[c]
+		}
+		if !b.commits[a].date.Equal(b.commits[c].date) {
+			return b.commits[a].date.Before(b.commits[c].date)
+		}
+		return a < c
+	})
+
+	top := hashes[0]
+	c := b.commits[top]
+	return &detector.Provenance{
+		Commit:     top,
+		Author:     c.author,
+		Date:       c.date,
+		Summary:    c.summary,
+		Lines:      counts[top],
+		TotalLines: total,
+	}
+}
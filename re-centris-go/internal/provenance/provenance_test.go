@@ -0,0 +1,81 @@
+package provenance
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+func TestAnnotate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	path := filepath.Join(repo, "lib.c")
+	commit := func(author, date, message string, lines []string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{
+			{"add", "lib.c"},
+			{"-c", "user.name=" + author, "-c", "user.email=test@example.com", "commit", "-q", "-m", message, "--date", date},
+		} {
+			cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+			cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+date)
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, output)
+			}
+		}
+	}
+
+	if output, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, output)
+	}
+	commit("alice", "2020-01-01T00:00:00Z", "Initial commit", []string{"int main(void)", "{", "    return 0;", "}"})
+	commit("bob", "2021-06-01T00:00:00Z", "Import zlib inflate", []string{
+		"int main(void)", "{", "    return 0;", "}",
+		"int inflate(z_stream *s)", "{", "    return inflate_fast(s);", "}",
+	})
+
+	results := []*detector.DetectionResult{{
+		TargetFile: path,
+		Matches: []detector.Match{
+			{File: "/known/zlib/inflate.c", Evidence: []detector.Evidence{{Function: "inflate", TargetLines: detector.LineRange{Start: 5, End: 8}}}},
+			{File: "/known/other/main.c"},
+		},
+	}}
+	if err := Annotate(context.Background(), results); err != nil {
+		t.Fatal(err)
+	}
+
+	p := results[0].Matches[0].Provenance
+	if p == nil || p.Author != "bob" || p.Summary != "Import zlib inflate" || p.Date.Year() != 2021 || p.Lines != 4 || p.TotalLines != 4 {
+		t.Errorf("provenance of the evidence = %+v, want bob's import of 4 lines", p)
+	}
+	// Without evidence all 8 lines count, and both commits added 4
+	p = results[0].Matches[1].Provenance
+	if p == nil || p.Author != "alice" || p.TotalLines != 8 {
+		t.Errorf("provenance of the file = %+v, want the earlier commit of alice", p)
+	}
+}
+
+func TestParseBlameSHA256(t *testing.T) {
+	hash := strings.Repeat("ab12", 16)
+	output := hash + " 1 1 1\nauthor carol\nauthor-time 1600000000\nsummary Import\n\tint x;\n" +
+		strings.Repeat("0", 64) + " 2 2 1\nauthor Not Committed Yet\n\tint y;\n"
+
+	b := parseBlame(output)
+	if b.lines[1] != hash || b.commits[hash] == nil || b.commits[hash].author != "carol" {
+		t.Errorf("parseBlame() = %+v, want line 1 of carol's SHA-256 commit", b)
+	}
+	if p := b.provenance(nil); p == nil || p.Commit != hash || p.TotalLines != 1 {
+		t.Errorf("provenance() = %+v, want carol's commit without the uncommitted line", p)
+	}
+}
@@ -0,0 +1,75 @@
+package purl
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ForComponent returns the canonical package URL of a known component.
+// dirName is the directory of the component in the corpus, repoURL and
+// version its repository and commit or release, both optional. GitHub
+// repositories become pkg:github purls, everything else pkg:generic with
+// the repository as vcs_url qualifier.
+func ForComponent(dirName, repoURL, version string) string {
+	namespace, name := Split(dirName, repoURL)
+
+	var b strings.Builder
+
+	_, _, github := githubRepo(repoURL)
+	generic := !github && (repoURL != "" || namespace == "")
+	switch {
+	case !generic:
+		fmt.Fprintf(&b, "pkg:github/%s/%s", url.PathEscape(strings.ToLower(namespace)), url.PathEscape(strings.ToLower(name)))
+	case namespace != "":
+		fmt.Fprintf(&b, "pkg:generic/%s/%s", url.PathEscape(namespace), url.PathEscape(name))
+	default:
+		fmt.Fprintf(&b, "pkg:generic/%s", url.PathEscape(name))
+	}
+
+	if version != "" {
+		fmt.Fprintf(&b, "@%s", url.PathEscape(version))
+	}
+
+	// Generic packages point at their sources
+	if generic && repoURL != "" {
+		fmt.Fprintf(&b, "?vcs_url=%s", url.QueryEscape(repoURL))
+	}
+
+	return b.String()
+}
+
+// Split returns the owner and name of a component. Cloned repositories are
+// stored in directories named author%name; otherwise the owner is taken from
+// the repository URL if possible.
+func Split(dirName, repoURL string) (namespace, name string) {
+	if i := strings.Index(dirName, "%"); i >= 0 {
+		return dirName[:i], dirName[i+1:]
+	}
+
+	if owner, repo, ok := githubRepo(repoURL); ok {
+		return owner, repo
+	}
+
+	return "", dirName
+}
+
+// githubRepo extracts the owner and repository name of a GitHub URL
+func githubRepo(repoURL string) (owner, name string, ok bool) {
+	rest := ""
+	for _, prefix := range []string{"https://github.com/", "http://github.com/", "git@github.com:", "ssh://git@github.com/"} {
+		if strings.HasPrefix(repoURL, prefix) {
+			rest = strings.TrimPrefix(repoURL, prefix)
+			break
+		}
+	}
+	if rest == "" {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.TrimSuffix(strings.TrimSuffix(rest, "/"), ".git"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
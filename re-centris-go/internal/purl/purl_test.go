@@ -0,0 +1,21 @@
+package purl
+
+import "testing"
+
+func TestForComponent(t *testing.T) {
+	tests := []struct {
+		dirName, repoURL, version string
+		want                      string
+	}{
+		{"madler%zlib", "https://github.com/madler/zlib.git", "v1.3", "pkg:github/madler/zlib@v1.3"},
+		{"zlib", "git@github.com:Madler/Zlib.git", "", "pkg:github/madler/zlib"},
+		{"openssl%openssl", "", "abc123", "pkg:github/openssl/openssl@abc123"},
+		{"libfoo", "https://example.org/libfoo.git", "1.0", "pkg:generic/libfoo@1.0?vcs_url=https%3A%2F%2Fexample.org%2Flibfoo.git"},
+		{"libbar", "", "", "pkg:generic/libbar"},
+	}
+	for _, tt := range tests {
+		if got := ForComponent(tt.dirName, tt.repoURL, tt.version); got != tt.want {
+			t.Errorf("ForComponent(%q, %q, %q) = %q, want %q", tt.dirName, tt.repoURL, tt.version, got, tt.want)
+		}
+	}
+}
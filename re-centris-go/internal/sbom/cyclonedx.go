@@ -0,0 +1,156 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/version"
+)
+
+// FormatCycloneDX is the name of the CycloneDX JSON format
+const FormatCycloneDX = "cyclonedx"
+
+const cycloneDXSpecVersion = "1.5"
+
+// cdxBOM is the root object of a CycloneDX document
+type cdxBOM struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string   `json:"timestamp"`
+	Tools     cdxTools `json:"tools"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string           `json:"type"`
+	BOMRef             string           `json:"bom-ref,omitempty"`
+	Name               string           `json:"name"`
+	Version            string           `json:"version,omitempty"`
+	PURL               string           `json:"purl,omitempty"`
+	Licenses           []cdxLicense     `json:"licenses,omitempty"`
+	ExternalReferences []cdxExternalRef `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty    `json:"properties,omitempty"`
+}
+
+// cdxLicense holds either a single license ID or a license expression
+type cdxLicense struct {
+	License    *cdxLicenseID `json:"license,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+}
+
+type cdxLicenseID struct {
+	ID string `json:"id"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WriteCycloneDX writes the components identified in the detection results
+// as a CycloneDX JSON document
+func (e *Exporter) WriteCycloneDX(results []*detector.DetectionResult, outputPath string) error {
+	serial, err := uuid()
+	if err != nil {
+		return err
+	}
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools: cdxTools{
+				Components: []cdxComponent{{
+					Type:    "application",
+					Name:    "re-centris",
+					Version: version.Version,
+				}},
+			},
+		},
+		Components: []cdxComponent{},
+	}
+
+	for _, c := range e.Components(results) {
+		component := cdxComponent{
+			Type:    "library",
+			BOMRef:  c.PURL,
+			Name:    c.Name,
+			Version: c.Version,
+			PURL:    c.PURL,
+			Properties: []cdxProperty{
+				{Name: "re-centris:confidence", Value: strconv.FormatFloat(c.Confidence, 'f', 4, 64)},
+				{Name: "re-centris:matched_files", Value: strconv.Itoa(c.MatchedFiles)},
+				{Name: "re-centris:matched_functions", Value: strconv.Itoa(c.MatchedFunctions)},
+			},
+		}
+		if c.License != "" {
+			if strings.Contains(c.License, " ") {
+				component.Licenses = []cdxLicense{{Expression: c.License}}
+			} else {
+				component.Licenses = []cdxLicense{{License: &cdxLicenseID{ID: c.License}}}
+			}
+		}
+		if c.URL != "" {
+			component.ExternalReferences = []cdxExternalRef{{Type: "vcs", URL: c.URL}}
+		}
+		bom.Components = append(bom.Components, component)
+	}
+
+	return writeJSON(bom, outputPath)
+}
+
+// writeJSON writes a document as indented JSON, creating parent directories
+func writeJSON(document interface{}, outputPath string) error {
+	// Create parent directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %v", err)
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SBOM: %v", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+
+	return nil
+}
+
+// uuid returns a random version 4 UUID
+func uuid() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
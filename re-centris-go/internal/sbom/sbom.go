@@ -0,0 +1,158 @@
+package sbom
+
+import (
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/purl"
+)
+
+// Component is a known OSS component identified in the detection results
+type Component struct {
+	Name             string
+	Version          string
+	URL              string
+	PURL             string
+	License          string  // SPDX license ID or expression
+	Confidence       float64 // highest attribution score of any target file
+	MatchedFiles     int     // target files attributed to the component
+	MatchedFunctions int     // functions of target files matching the component
+
+	key string // directory name of the component below the known files directory
+}
+
+// ExporterOptions contains options for SBOM exporters
+type ExporterOptions struct {
+	KnownFilesDir string                // directory of known components, used to attribute file matches
+	Repositories  []manifest.Repository // repository URLs and commits of the known components
+	TargetName    string                // name of the analyzed target, defaults to "target"
+}
+
+// Exporter converts detection results into software bills of materials
+type Exporter struct {
+	opts  ExporterOptions
+	repos map[string]manifest.Repository
+}
+
+// New creates a new Exporter
+func New(opts ExporterOptions) *Exporter {
+	if opts.TargetName == "" {
+		opts.TargetName = "target"
+	}
+
+	repos := make(map[string]manifest.Repository, len(opts.Repositories))
+	for _, repo := range opts.Repositories {
+		repos[repo.Name] = repo
+	}
+
+	return &Exporter{
+		opts:  opts,
+		repos: repos,
+	}
+}
+
+// Components aggregates the components identified in the detection results
+func (e *Exporter) Components(results []*detector.DetectionResult) []Component {
+	components := make(map[string]*Component)
+
+	get := func(name string) *Component {
+		c, ok := components[name]
+		if !ok {
+			c = e.newComponent(name)
+			components[name] = c
+		}
+		return c
+	}
+
+	for _, result := range results {
+		for _, a := range e.attribute(result) {
+			c := get(a.component)
+			c.MatchedFiles++
+			c.MatchedFunctions += a.functions
+			if a.confidence > c.Confidence {
+				c.Confidence = a.confidence
+			}
+		}
+	}
+
+	list := make([]Component, 0, len(components))
+	for _, c := range components {
+		list = append(list, *c)
+	}
+
+	// Sort components by name for reproducible documents
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// attribution links a target file to a component
+type attribution struct {
+	component  string
+	functions  int
+	confidence float64
+}
+
+// attribute returns the components a target file is attributed to. Function
+// level attributions are used where available; otherwise each component of
+// the matched known files is attributed once with its best similarity.
+func (e *Exporter) attribute(result *detector.DetectionResult) []attribution {
+	var attributions []attribution
+
+	if len(result.Components) > 0 {
+		for _, match := range result.Components {
+			attributions = append(attributions, attribution{
+				component:  match.Component,
+				functions:  match.MatchedFunctions,
+				confidence: match.Score,
+			})
+		}
+		return attributions
+	}
+
+	index := make(map[string]int)
+	for _, match := range result.Matches {
+		name := match.ComponentIn(e.opts.KnownFilesDir)
+		if name == "" {
+			continue
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(attributions)
+			index[name] = i
+			attributions = append(attributions, attribution{component: name})
+		}
+		if match.Similarity > attributions[i].confidence {
+			attributions[i].confidence = match.Similarity
+		}
+	}
+
+	return attributions
+}
+
+// newComponent creates a component with the name, version and purl derived
+// from its repository
+func (e *Exporter) newComponent(dirName string) *Component {
+	repo := e.repos[dirName]
+
+	c := &Component{
+		key:     dirName,
+		Name:    dirName,
+		Version: repo.Commit,
+		URL:     repo.URL,
+		PURL:    repo.PURL,
+		License: repo.License,
+	}
+
+	if namespace, name := purl.Split(dirName, repo.URL); namespace != "" {
+		c.Name = namespace + "/" + name
+	}
+	if c.PURL == "" {
+		c.PURL = purl.ForComponent(dirName, repo.URL, repo.Commit)
+	}
+
+	return c
+}
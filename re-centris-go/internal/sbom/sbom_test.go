@@ -0,0 +1,105 @@
+package sbom
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+)
+
+func TestComponents(t *testing.T) {
+	e := New(ExporterOptions{
+		KnownFilesDir: "/known",
+		Repositories: []manifest.Repository{
+			{Name: "madler%zlib", URL: "https://github.com/madler/zlib.git", Commit: "abc123"},
+			{Name: "libfoo", URL: "https://example.org/libfoo.git", Commit: "def456"},
+		},
+	})
+
+	results := []*detector.DetectionResult{
+		{
+			TargetFile: "inflate.c",
+			Components: []detector.ComponentMatch{{Component: "madler%zlib", MatchedFunctions: 3, Score: 0.9}},
+		},
+		{
+			TargetFile: "deflate.c",
+			Components: []detector.ComponentMatch{{Component: "madler%zlib", MatchedFunctions: 2, Score: 0.7}},
+		},
+		{
+			TargetFile: "foo.c",
+			Matches: []detector.Match{
+				{File: "/known/libfoo/a.c", Similarity: 0.85},
+				{File: "/known/libfoo/b.c", Similarity: 0.95},
+			},
+		},
+	}
+
+	components := e.Components(results)
+	if len(components) != 2 {
+		t.Fatalf("Components() returned %d components, want 2", len(components))
+	}
+
+	foo, zlib := components[0], components[1]
+	if zlib.Name != "madler/zlib" || zlib.PURL != "pkg:github/madler/zlib@abc123" {
+		t.Errorf("zlib = %+v, want name madler/zlib with github purl", zlib)
+	}
+	if zlib.MatchedFiles != 2 || zlib.MatchedFunctions != 5 || zlib.Confidence != 0.9 {
+		t.Errorf("zlib counts = %+v, want 2 files, 5 functions, confidence 0.9", zlib)
+	}
+
+	wantPURL := "pkg:generic/libfoo@def456?vcs_url=https%3A%2F%2Fexample.org%2Flibfoo.git"
+	if foo.PURL != wantPURL {
+		t.Errorf("libfoo purl = %v, want %v", foo.PURL, wantPURL)
+	}
+	if foo.MatchedFiles != 1 || foo.Confidence != 0.95 {
+		t.Errorf("libfoo counts = %+v, want 1 file, confidence 0.95", foo)
+	}
+}
+
+func TestWriteSPDX(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "inflate.c")
+	if err := os.WriteFile(target, []byte("int inflate(void) { return 0; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(ExporterOptions{KnownFilesDir: "/known", TargetName: "app"})
+	results := []*detector.DetectionResult{
+		{TargetFile: target, Components: []detector.ComponentMatch{{Component: "madler%zlib", MatchedFunctions: 1, Score: 1}}},
+		{TargetFile: filepath.Join(dir, "main.c")},
+	}
+
+	path := filepath.Join(dir, "sbom.spdx.json")
+	if err := e.WriteSPDX(results, path); err != nil {
+		t.Fatalf("WriteSPDX() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc spdxDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("failed to parse SPDX document: %v", err)
+	}
+
+	if len(doc.Packages) != 2 || len(doc.Files) != 1 {
+		t.Fatalf("SPDX document has %d packages and %d files, want 2 and 1", len(doc.Packages), len(doc.Files))
+	}
+
+	var contains, copies int
+	for _, r := range doc.Relationships {
+		switch {
+		case r.RelationshipType == "CONTAINS" && r.SPDXElementID == spdxTargetID:
+			contains++
+		case r.RelationshipType == "COPY_OF" && r.RelatedSPDXElement == "SPDXRef-Package-madler-zlib":
+			copies++
+		}
+	}
+	if contains != 2 || copies != 1 {
+		t.Errorf("relationships = %d CONTAINS and %d COPY_OF, want 2 and 1", contains, copies)
+	}
+}
@@ -0,0 +1,224 @@
+package sbom
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/version"
+)
+
+// FormatSPDX is the name of the SPDX JSON format
+const FormatSPDX = "spdx"
+
+const (
+	spdxVersion     = "SPDX-2.3"
+	spdxNoAssertion = "NOASSERTION"
+	spdxDocumentID  = "SPDXRef-DOCUMENT"
+	spdxTargetID    = "SPDXRef-Target"
+)
+
+// spdxInvalidID matches characters not allowed in SPDX identifiers
+var spdxInvalidID = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdxDocument is the root object of an SPDX document
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	DocumentDescribes []string           `json:"documentDescribes"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files,omitempty"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string            `json:"comment,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxFile struct {
+	SPDXID           string         `json:"SPDXID"`
+	FileName         string         `json:"fileName"`
+	Checksums        []spdxChecksum `json:"checksums"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	CopyrightText    string         `json:"copyrightText"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+	Comment            string `json:"comment,omitempty"`
+}
+
+// WriteSPDX writes the detection results as an SPDX 2.3 JSON document. The
+// analyzed target is described as a package that CONTAINS every detected
+// component, and each target file with matches is recorded as a COPY_OF
+// the components it was attributed to.
+func (e *Exporter) WriteSPDX(results []*detector.DetectionResult, outputPath string) error {
+	serial, err := uuid()
+	if err != nil {
+		return err
+	}
+
+	doc := spdxDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            spdxDocumentID,
+		Name:              e.opts.TargetName,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/re-centris-%s-%s", spdxInvalidID.ReplaceAllString(e.opts.TargetName, "-"), serial),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: re-centris-" + version.Version},
+		},
+		DocumentDescribes: []string{spdxTargetID},
+		Packages: []spdxPackage{{
+			SPDXID:           spdxTargetID,
+			Name:             e.opts.TargetName,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      spdxDocumentID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: spdxTargetID,
+		}},
+	}
+
+	// Describe every detected component as a package of the target
+	packageIDs := make(map[string]string)
+	for _, c := range e.Components(results) {
+		id := "SPDXRef-Package-" + spdxInvalidID.ReplaceAllString(c.key, "-")
+		packageIDs[c.key] = id
+
+		declared := spdxNoAssertion
+		if c.License != "" {
+			declared = c.License
+		}
+
+		downloadLocation := spdxNoAssertion
+		if c.URL != "" {
+			downloadLocation = "git+" + c.URL
+		}
+
+		doc.Packages = append(doc.Packages, spdxPackage{
+			SPDXID:           id,
+			Name:             c.Name,
+			VersionInfo:      c.Version,
+			DownloadLocation: downloadLocation,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  declared,
+			CopyrightText:    spdxNoAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.PURL,
+			}},
+			Comment: fmt.Sprintf("Detected by re-centris with confidence %.4f in %d files", c.Confidence, c.MatchedFiles),
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      spdxTargetID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: id,
+		})
+	}
+
+	// Record the target files copied from components
+	for i, result := range results {
+		attributions := e.attribute(result)
+		if len(attributions) == 0 {
+			continue
+		}
+
+		checksum, err := sha1File(result.TargetFile)
+		if err != nil {
+			return err
+		}
+
+		fileID := fmt.Sprintf("SPDXRef-File-%d", i)
+		doc.Files = append(doc.Files, spdxFile{
+			SPDXID:           fileID,
+			FileName:         spdxFileName(result.TargetFile),
+			Checksums:        []spdxChecksum{{Algorithm: "SHA1", ChecksumValue: checksum}},
+			LicenseConcluded: spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      spdxTargetID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: fileID,
+		})
+
+		for _, a := range attributions {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID:      fileID,
+				RelationshipType:   "COPY_OF",
+				RelatedSPDXElement: packageIDs[a.component],
+				Comment:            fmt.Sprintf("confidence %.4f", a.confidence),
+			})
+		}
+	}
+
+	return writeJSON(doc, outputPath)
+}
+
+// spdxFileName returns a file name in the relative "./" form SPDX expects
+func spdxFileName(path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "../") {
+		return path
+	}
+	return "./" + path
+}
+
+// sha1File returns the hex encoded SHA1 checksum of a file
+func sha1File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open target file: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read target file: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,178 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of scan targets
+const (
+	// KindDirectory is a directory on disk
+	KindDirectory = "directory"
+	// KindArchive is a zip or tar archive
+	KindArchive = "archive"
+	// KindGit is a git repository checked out at a ref
+	KindGit = "git"
+)
+
+// Manifest describes a batch of targets scanned together
+type Manifest struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// Target is a single entry of a scan manifest
+type Target struct {
+	Name    string   `yaml:"name"`
+	Path    string   `yaml:"path"`    // directory or archive
+	Git     string   `yaml:"git"`     // repository URL, instead of path
+	Ref     string   `yaml:"ref"`     // branch, tag or commit to check out
+	Include []string `yaml:"include"` // glob patterns of files to scan, all supported files if empty
+	Exclude []string `yaml:"exclude"` // glob patterns of files to skip
+	Policy  Policy   `yaml:"policy"`
+}
+
+// Policy decides whether the results of a target pass
+type Policy struct {
+	Threshold   float64  `yaml:"threshold"`     // similarity threshold, overrides the detector default
+	FailOnMatch bool     `yaml:"fail_on_match"` // fail if any known code is found
+	Allow       []string `yaml:"allow"`         // components that may be present without failing
+}
+
+// ReadManifest reads and validates a scan manifest
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan manifest: %v", err)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse scan manifest: %v", err)
+	}
+
+	// Relative paths are resolved against the manifest location
+	base := filepath.Dir(path)
+	for i := range m.Targets {
+		t := &m.Targets[i]
+		if t.Path != "" && !filepath.IsAbs(t.Path) {
+			t.Path = filepath.Join(base, t.Path)
+		}
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Validate checks that every target is well formed and uniquely named
+func (m *Manifest) Validate() error {
+	if len(m.Targets) == 0 {
+		return fmt.Errorf("scan manifest has no targets")
+	}
+
+	names := make(map[string]bool)
+	for i := range m.Targets {
+		t := &m.Targets[i]
+		if (t.Path == "") == (t.Git == "") {
+			return fmt.Errorf("target %d: exactly one of path and git must be set", i+1)
+		}
+		if t.Ref != "" && t.Git == "" {
+			return fmt.Errorf("target %d: ref requires a git target", i+1)
+		}
+		if strings.HasPrefix(t.Ref, "-") {
+			return fmt.Errorf("target %d: invalid ref %q", i+1, t.Ref)
+		}
+		if t.Policy.Threshold < 0 || t.Policy.Threshold > 1 {
+			return fmt.Errorf("target %d: threshold must be between 0 and 1", i+1)
+		}
+		for _, pattern := range append(append([]string{}, t.Include...), t.Exclude...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("target %d: invalid pattern %q", i+1, pattern)
+			}
+		}
+
+		if t.Name == "" {
+			t.Name = t.defaultName()
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate target name: %s", t.Name)
+		}
+		names[t.Name] = true
+	}
+
+	return nil
+}
+
+// Kind returns the kind of the target
+func (t *Target) Kind() string {
+	if t.Git != "" {
+		return KindGit
+	}
+	if archiveFormat(t.Path) != "" {
+		return KindArchive
+	}
+	return KindDirectory
+}
+
+// Source returns the location the target is read from
+func (t *Target) Source() string {
+	if t.Git == "" {
+		return t.Path
+	}
+	if t.Ref == "" {
+		return t.Git
+	}
+	return t.Git + "@" + t.Ref
+}
+
+// defaultName names a target after its path or repository
+func (t *Target) defaultName() string {
+	if t.Git != "" {
+		return strings.TrimSuffix(filepath.Base(strings.TrimSuffix(t.Git, "/")), ".git")
+	}
+	name := filepath.Base(t.Path)
+	if format := archiveFormat(name); format != "" {
+		name = strings.TrimSuffix(name, format)
+	}
+	return name
+}
+
+// matches reports whether a file, given relative to the target root,
+// is selected by the include and exclude patterns
+func (t *Target) matches(rel string) bool {
+	rel = filepath.ToSlash(rel)
+
+	if len(t.Include) > 0 && !matchAny(t.Include, rel) {
+		return false
+	}
+	return !matchAny(t.Exclude, rel)
+}
+
+// matchAny reports whether a path matches any pattern. Patterns without a
+// slash match the file name, all others the full relative path; a trailing
+// "/" selects a directory and everything below it.
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if dir := strings.TrimSuffix(pattern, "/"); rel == dir || strings.HasPrefix(rel, dir+"/") {
+				return true
+			}
+			continue
+		}
+
+		subject := rel
+		if !strings.Contains(pattern, "/") {
+			subject = filepath.Base(rel)
+		}
+		if ok, _ := filepath.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scan.yaml")
+	data := `targets:
+  - path: src
+    include: ["*.c", "lib/"]
+    exclude: ["*_test.c"]
+  - path: vendor.tar.gz
+  - git: https://github.com/madler/zlib.git
+    ref: v1.3
+    policy:
+      threshold: 0.9
+      fail_on_match: true
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	if len(m.Targets) != 3 {
+		t.Fatalf("ReadManifest() returned %d targets, want 3", len(m.Targets))
+	}
+
+	wantKinds := []string{KindDirectory, KindArchive, KindGit}
+	wantNames := []string{"src", "vendor", "zlib"}
+	for i, target := range m.Targets {
+		if target.Kind() != wantKinds[i] || target.Name != wantNames[i] {
+			t.Errorf("target %d = %v (%v), want %v (%v)", i, target.Name, target.Kind(), wantNames[i], wantKinds[i])
+		}
+	}
+	if m.Targets[0].Path != filepath.Join(dir, "src") {
+		t.Errorf("target path = %v, want it resolved against the manifest", m.Targets[0].Path)
+	}
+
+	src := m.Targets[0]
+	tests := map[string]bool{
+		"main.c":          true,
+		"main_test.c":     false,
+		"lib/util/x.h":    true,
+		"include/api.h":   false,
+		"deep/dir/inc.c":  true,
+		"lib/util_test.c": false,
+	}
+	for rel, want := range tests {
+		if got := src.matches(rel); got != want {
+			t.Errorf("matches(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestValidateRejectsInvalidTargets(t *testing.T) {
+	manifests := map[string]Manifest{
+		"empty":           {},
+		"path and git":    {Targets: []Target{{Path: "a", Git: "https://example.org/a.git"}}},
+		"ref without git": {Targets: []Target{{Path: "a", Ref: "v1"}}},
+		"option ref":      {Targets: []Target{{Git: "https://example.org/a.git", Ref: "--upload-pack=touch"}}},
+		"duplicate name":  {Targets: []Target{{Path: "x/a"}, {Path: "y/a"}}},
+	}
+	for name, m := range manifests {
+		if err := m.Validate(); err == nil {
+			t.Errorf("Validate() of %s manifest error = nil, want error", name)
+		}
+	}
+}
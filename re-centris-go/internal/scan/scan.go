@@ -0,0 +1,285 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"go.uber.org/zap"
+)
+
+// Report is the consolidated report of a batch scan
+type Report struct {
+	Targets []TargetReport `json:"targets"`
+	Passed  bool           `json:"passed"`
+}
+
+// Failed returns the number of targets that did not pass
+func (r *Report) Failed() int {
+	failed := 0
+	for _, t := range r.Targets {
+		if !t.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// TargetReport summarizes the scan of a single target
+type TargetReport struct {
+	Name       string                      `json:"name"`
+	Kind       string                      `json:"kind"`
+	Source     string                      `json:"source"`
+	Files      int                         `json:"files"`
+	Matches    int                         `json:"matches"`
+	Components []string                    `json:"components,omitempty"`
+	Violations []string                    `json:"violations,omitempty"`
+	Passed     bool                        `json:"passed"`
+	Error      string                      `json:"error,omitempty"`
+	ResultFile string                      `json:"result_file,omitempty"`
+	Results    []*detector.DetectionResult `json:"results,omitempty"`
+}
+
+// ScannerOptions contains options for the scanner
+type ScannerOptions struct {
+	Detector detector.DetectorOptions // settings shared by all targets
+	// ResultsDir, if set, receives one result file per target in Format
+	// instead of embedding the results in the report
+	ResultsDir string
+	Format     string
+}
+
+// Scanner runs the targets of a scan manifest against one known corpus
+type Scanner struct {
+	opts     ScannerOptions
+	analyzer *analyzer.Analyzer
+}
+
+// New creates a new Scanner
+func New(opts ScannerOptions) *Scanner {
+	return &Scanner{
+		opts:     opts,
+		analyzer: analyzer.New(opts.Detector.AnalyzerOptions()),
+	}
+}
+
+// Run scans all targets of the manifest. The known corpus is loaded and
+// indexed once and shared by all targets. A target that cannot be scanned,
+// or whose results cannot be saved, fails on its own without aborting the
+// batch.
+func (s *Scanner) Run(ctx context.Context, m *Manifest) (*Report, error) {
+	d := detector.New(s.opts.Detector)
+	knownFiles, err := d.LoadKnownFiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known files: %v", err)
+	}
+	corpus := d.NewCorpus(ctx, knownFiles)
+
+	if s.opts.ResultsDir != "" {
+		if err := os.MkdirAll(s.opts.ResultsDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create results directory: %v", err)
+		}
+	}
+
+	report := &Report{Passed: true}
+	for i := range m.Targets {
+		t := &m.Targets[i]
+
+		logger.Info("Scanning target",
+			zap.String("target", t.Name),
+			zap.String("kind", t.Kind()),
+			zap.String("source", t.Source()))
+
+		tr := s.scanTarget(ctx, t, d, corpus)
+		if tr.Error == "" && s.opts.ResultsDir != "" {
+			// The results stay in the report if they cannot be saved
+			if err := s.saveResults(d, &tr); err != nil {
+				tr.Error = err.Error()
+				tr.Passed = false
+			}
+		}
+		if tr.Error != "" {
+			logger.Error("Failed to scan target",
+				zap.String("target", t.Name),
+				zap.String("error", tr.Error))
+		}
+
+		report.Passed = report.Passed && tr.Passed
+		report.Targets = append(report.Targets, tr)
+	}
+
+	return report, nil
+}
+
+// scanTarget materializes, scans and evaluates a single target
+func (s *Scanner) scanTarget(ctx context.Context, t *Target, d *detector.Detector, corpus *detector.Corpus) TargetReport {
+	tr := TargetReport{
+		Name:   t.Name,
+		Kind:   t.Kind(),
+		Source: t.Source(),
+	}
+
+	root, cleanup, err := materialize(ctx, t)
+	if err != nil {
+		tr.Error = err.Error()
+		return tr
+	}
+	defer cleanup()
+
+	files, err := s.selectFiles(t, root)
+	if err != nil {
+		tr.Error = err.Error()
+		return tr
+	}
+	tr.Files = len(files)
+
+	if t.Policy.Threshold > 0 {
+		d = d.WithThreshold(t.Policy.Threshold)
+	}
+
+	results, err := d.DetectWithCorpus(ctx, files, corpus)
+	if err != nil {
+		tr.Error = err.Error()
+		return tr
+	}
+
+	// Report target files relative to the target root, since archives and
+	// repositories are scanned from temporary directories
+	for _, result := range results {
+		if rel, err := filepath.Rel(root, result.TargetFile); err == nil {
+			result.TargetFile = filepath.ToSlash(rel)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].TargetFile < results[j].TargetFile
+	})
+
+	tr.Results = results
+	s.evaluate(&tr, t.Policy)
+	return tr
+}
+
+// saveResults writes the results of a target to its result file in the
+// results directory and drops them from the report. Target names are
+// unique and escaped into file names, so result files never collide.
+func (s *Scanner) saveResults(d *detector.Detector, tr *TargetReport) error {
+	name := url.PathEscape(tr.Name)
+	path := filepath.Join(s.opts.ResultsDir, name+resultExtension(s.opts.Format))
+	if err := d.WriteResults(tr.Results, s.opts.Format, path); err != nil {
+		return fmt.Errorf("failed to save results of target %s: %v", tr.Name, err)
+	}
+	tr.ResultFile = path
+	tr.Results = nil
+	return nil
+}
+
+// resultExtension returns the file extension of an output format
+func resultExtension(format string) string {
+	switch format {
+	case detector.FormatJSONL:
+		return ".jsonl"
+	case detector.FormatSARIF:
+		return ".sarif"
+	case detector.FormatCSV:
+		return ".csv"
+	case detector.FormatMarkdown:
+		return ".md"
+	case detector.FormatGitHub:
+		return ".txt"
+	default:
+		return ".json"
+	}
+}
+
+// selectFiles returns the supported files of a target root that match the
+// include and exclude patterns of the target
+func (s *Scanner) selectFiles(t *Target, root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if s.analyzer.LanguageOf(path) != "" && t.matches(rel) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk target: %v", err)
+	}
+
+	return files, nil
+}
+
+// evaluate applies a policy to the results of a target
+func (s *Scanner) evaluate(tr *TargetReport, policy Policy) {
+	allowed := make(map[string]bool)
+	for _, component := range policy.Allow {
+		allowed[component] = true
+	}
+
+	components := make(map[string]bool)
+	for _, result := range tr.Results {
+		if result.MatchCount > 0 {
+			tr.Matches++
+		}
+		for _, c := range result.Components {
+			components[c.Component] = true
+		}
+	}
+	for component := range components {
+		tr.Components = append(tr.Components, component)
+	}
+	sort.Strings(tr.Components)
+
+	if policy.FailOnMatch && tr.Matches > 0 {
+		tr.Violations = append(tr.Violations, fmt.Sprintf("%d files match known code", tr.Matches))
+	}
+	if len(policy.Allow) > 0 {
+		for _, component := range tr.Components {
+			if !allowed[component] {
+				tr.Violations = append(tr.Violations, fmt.Sprintf("component %s is not allowed", component))
+			}
+		}
+	}
+
+	tr.Passed = len(tr.Violations) == 0
+}
+
+// SaveReport saves a scan report to a JSON file
+func SaveReport(report *Report, outputPath string) error {
+	// Create parent directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %v", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scan report: %v", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scan report: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+func TestRunResultsDir(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "int f%d(int x) { if (x > %d) return x * %d + 7; return x - %d; }\n", i, i*3, i+2, i)
+	}
+	source := []byte(b.String())
+
+	dir := t.TempDir()
+	for _, path := range []string{"known/acme%lib/lib.c", "first/lib.c", "second/src/lib.c"} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, source, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Manifest{Targets: []Target{
+		{Name: "team/lib", Path: filepath.Join(dir, "first")},
+		{Name: "team_lib", Path: filepath.Join(dir, "second")},
+		{Name: "unsaved", Path: filepath.Join(dir, "first")},
+	}}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in place of its result file fails only that target
+	results := filepath.Join(dir, "results")
+	if err := os.MkdirAll(filepath.Join(results, "unsaved.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := New(ScannerOptions{
+		Detector: detector.DetectorOptions{
+			MaxWorkers:          2,
+			SimilarityThreshold: 0.8,
+			Languages:           analyzer.DefaultLanguages(),
+			KnownFilesDir:       filepath.Join(dir, "known"),
+		},
+		ResultsDir: results,
+		Format:     detector.FormatJSON,
+	})
+	report, err := s.Run(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Targets) != 3 || report.Passed {
+		t.Fatalf("report = %+v, want 3 targets and a failure", report)
+	}
+	if tr := report.Targets[2]; tr.Error == "" || tr.Passed || len(tr.Results) != 1 {
+		t.Errorf("unsaved target = %+v, want a failure with the results embedded", tr)
+	}
+
+	for i, tr := range report.Targets[:2] {
+		if tr.Error != "" || tr.Matches != 1 || tr.Results != nil {
+			t.Errorf("target %s = %+v, want one match and no embedded results", tr.Name, tr)
+		}
+		if want := []string{"team%2Flib.json", "team_lib.json"}[i]; tr.ResultFile != filepath.Join(results, want) {
+			t.Errorf("target %s result file = %q", tr.Name, tr.ResultFile)
+		}
+		matches := 0
+		err := detector.ReadResults(tr.ResultFile, func(result *detector.DetectionResult) error {
+			matches += result.MatchCount
+			return nil
+		})
+		if err != nil || matches != 1 {
+			t.Errorf("target %s result file has %d matches, error %v", tr.Name, matches, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/common/gitutil"
+)
+
+// archiveFormat returns the archive extension of a path, or an empty
+// string if it is not a supported archive
+func archiveFormat(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return path[len(path)-len(ext):]
+		}
+	}
+	return ""
+}
+
+// materialize makes the files of a target available in a directory and
+// returns it together with a cleanup function removing temporary files
+func materialize(ctx context.Context, t *Target) (string, func(), error) {
+	if t.Kind() == KindDirectory {
+		info, err := os.Stat(t.Path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to access target: %v", err)
+		}
+		if !info.IsDir() {
+			return "", nil, fmt.Errorf("target is neither a directory nor a supported archive: %s", t.Path)
+		}
+		return t.Path, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "re-centris-scan-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	if t.Kind() == KindGit {
+		err = checkout(ctx, t.Git, t.Ref, dir)
+	} else {
+		err = extract(t.Path, dir)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return dir, cleanup, nil
+}
+
+// checkout clones a repository into dir and checks out ref. Neither the
+// URL nor the ref is taken for an option of git.
+func checkout(ctx context.Context, url, ref, dir string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid ref %q", ref)
+	}
+	if err := gitutil.Run(ctx, "", "clone", "--quiet", "--", url, dir); err != nil {
+		return fmt.Errorf("failed to clone repository %s: %v", url, err)
+	}
+	if ref == "" {
+		return nil
+	}
+	if err := gitutil.Run(ctx, dir, "checkout", "--quiet", ref, "--"); err != nil {
+		return fmt.Errorf("failed to check out %s: %v", ref, err)
+	}
+	return nil
+}
+
+// extract unpacks a zip or tar archive into dir
+func extract(path, dir string) error {
+	if strings.EqualFold(archiveFormat(path), ".zip") {
+		return extractZip(path, dir)
+	}
+	return extractTar(path, dir)
+}
+
+// extractZip unpacks a zip archive into dir
+func extractZip(path, dir string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from archive: %v", f.Name, err)
+		}
+		err = writeEntry(dir, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractTar unpacks a plain or gzip-compressed tar archive into dir
+func extractTar(path, dir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if format := strings.ToLower(archiveFormat(path)); format == ".tar.gz" || format == ".tgz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeEntry(dir, header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeEntry writes an archive entry below dir, rejecting entries that
+// would escape it
+func writeEntry(dir, name string, r io.Reader) error {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("archive entry escapes target directory: %s", name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %v", err)
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %v", name, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return fmt.Errorf("failed to extract %s: %v", name, err)
+	}
+	return nil
+}
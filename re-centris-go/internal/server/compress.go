@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// encodingZstd is the content coding name of Zstandard
+const encodingZstd = "zstd"
+
+// Limits of the zstd request body decoder. The window a frame may request
+// is bounded so a small body cannot make the server allocate large buffers.
+const (
+	maxDecoderWindow = 8 << 20
+	maxDecoderMemory = 64 << 20
+)
+
+// encoderPool reuses zstd encoders across responses; creating an encoder
+// allocates its window buffers
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return encoder
+	},
+}
+
+// Compress wraps a handler with transparent zstd compression. Request bodies
+// sent with "Content-Encoding: zstd" are decompressed before they reach the
+// handler, and responses are compressed when the client lists zstd in its
+// Accept-Encoding header. Signature payloads and results compress well, which
+// matters for clients on slow links.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Decompress the request body
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), encodingZstd) {
+			decoder, err := zstd.NewReader(r.Body,
+				zstd.WithDecoderMaxWindow(maxDecoderWindow),
+				zstd.WithDecoderMaxMemory(maxDecoderMemory),
+				zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid zstd request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			defer decoder.Close()
+
+			r.Body = io.NopCloser(decoder)
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsZstd(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encoder := encoderPool.Get().(*zstd.Encoder)
+		encoder.Reset(w)
+		defer encoderPool.Put(encoder)
+
+		cw := &compressWriter{ResponseWriter: w, encoder: encoder}
+		next.ServeHTTP(cw, r)
+		cw.Close()
+	})
+}
+
+// acceptsZstd reports whether an Accept-Encoding header allows zstd
+func acceptsZstd(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encodingZstd) {
+			continue
+		}
+		// A quality of zero explicitly refuses the coding
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if q, err := strconv.ParseFloat(value, 64); strings.EqualFold(name, "q") && err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressWriter compresses everything written to the response
+type compressWriter struct {
+	http.ResponseWriter
+	encoder     *zstd.Encoder
+	wroteHeader bool
+}
+
+// WriteHeader sets the content encoding before sending the headers
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	if header.Get("Content-Encoding") == "" && status != http.StatusNoContent && status != http.StatusNotModified {
+		header.Set("Content-Encoding", encodingZstd)
+		header.Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write compresses data into the response
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.Header().Get("Content-Encoding") != encodingZstd {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.encoder.Write(data)
+}
+
+// Flush writes buffered compressed data to the client
+func (w *compressWriter) Flush() {
+	if w.Header().Get("Content-Encoding") == encodingZstd {
+		w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the compressed stream. Handlers that never wrote a body
+// produce an empty, uncompressed response.
+func (w *compressWriter) Close() {
+	if !w.wroteHeader {
+		return
+	}
+	if w.Header().Get("Content-Encoding") == encodingZstd {
+		w.encoder.Close()
+	}
+}
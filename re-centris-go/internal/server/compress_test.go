@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	payload := strings.Repeat(`{"hash":"T1A2B3C4D5"}`, 100)
+
+	// Echo the request body back to the client
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+
+	encoder, _ := zstd.NewWriter(nil)
+	body := encoder.EncodeAll([]byte(payload), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", "zstd")
+	req.Header.Set("Accept-Encoding", "gzip, zstd")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "zstd" {
+		t.Fatalf("Content-Encoding = %q, want zstd", got)
+	}
+	if rec.Body.Len() >= len(payload) {
+		t.Errorf("compressed response is %d bytes, want less than %d", rec.Body.Len(), len(payload))
+	}
+
+	decoder, _ := zstd.NewReader(nil)
+	decoded, err := decoder.DecodeAll(rec.Body.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if string(decoded) != payload {
+		t.Errorf("response body does not match request payload")
+	}
+}
+
+func TestAcceptsZstd(t *testing.T) {
+	tests := map[string]bool{
+		"":                 false,
+		"gzip":             false,
+		"gzip, zstd":       true,
+		"ZSTD;q=0.5":       true,
+		"zstd;q=0, gzip":   false,
+		"zstd; q=0.0":      false,
+		"identity, br, gz": false,
+	}
+	for header, want := range tests {
+		if got := acceptsZstd(header); got != want {
+			t.Errorf("acceptsZstd(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestCompressRejectsLargeWindow(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+
+	var body bytes.Buffer
+	encoder, _ := zstd.NewWriter(&body, zstd.WithWindowSize(4*maxDecoderWindow), zstd.WithSingleSegment(false))
+	encoder.Write([]byte(strings.Repeat("x", 1<<20)))
+	encoder.Flush()
+	encoder.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Encoding", "zstd")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d for a frame exceeding the window limit", rec.Code, http.StatusBadRequest)
+	}
+}
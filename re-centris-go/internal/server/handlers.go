@@ -0,0 +1,282 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"go.uber.org/zap"
+)
+
+// pathsRequest names files on the server's file system
+type pathsRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// fileResponse is the analysis of a single file
+type fileResponse struct {
+	Path      string             `json:"path"`
+	Language  string             `json:"language"`
+	Hash      string             `json:"hash"`
+	Size      int64              `json:"size"`
+	Functions []functionResponse `json:"functions"`
+}
+
+// functionResponse is a function of an analyzed file
+type functionResponse struct {
+	Name       string `json:"name"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Hash       string `json:"hash"`
+	Scope      string `json:"scope,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+	Complexity int    `json:"complexity,omitempty"`
+	Tokens     int    `json:"tokens,omitempty"`
+	Depth      int    `json:"depth,omitempty"`
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// targets holds the files of a request. Uploaded files are stored in a
+// temporary directory and reported under their uploaded names; the entries
+// of uploaded archives as "<upload name>!<entry path>".
+type targets struct {
+	paths []string
+	names map[string]string // temporary path to uploaded name
+	dir   string
+}
+
+// name returns the name a target file is reported under
+func (t *targets) name(path string) string {
+	if name, ok := t.names[path]; ok {
+		return name
+	}
+	// Archive entries are named as if the archive was a directory
+	for upload, name := range t.names {
+		if entry, ok := strings.CutPrefix(path, upload+string(filepath.Separator)); ok && analyzer.IsArchive(upload) {
+			return name + "!" + filepath.ToSlash(entry)
+		}
+	}
+	return path
+}
+
+// cleanup removes uploaded files
+func (t *targets) cleanup() {
+	if t.dir != "" {
+		os.RemoveAll(t.dir)
+	}
+}
+
+// handleAnalyze analyzes uploaded or named files
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	t, err := s.readTargets(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer t.cleanup()
+
+	files := make([]fileResponse, 0, len(t.paths))
+	for _, path := range t.paths {
+		info, err := s.analyzer.AnalyzeFile(r.Context(), path)
+		if err != nil {
+			if analyzer.IsSkipped(err) {
+				continue
+			}
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("failed to analyze %s: %v", t.name(path), err))
+			return
+		}
+
+		file := fileResponse{
+			Path:      t.name(path),
+			Language:  info.Language,
+			Hash:      info.Hash.String(),
+			Size:      info.Size,
+			Functions: make([]functionResponse, 0, len(info.Functions)),
+		}
+		for _, fn := range info.Functions {
+			file.Functions = append(file.Functions, functionResponse{
+				Name:       fn.Name,
+				StartLine:  fn.StartLine,
+				EndLine:    fn.EndLine,
+				Hash:       fn.Hash,
+				Scope:      fn.Scope,
+				Signature:  fn.Signature,
+				Complexity: fn.Complexity,
+				Tokens:     fn.Tokens,
+				Depth:      fn.Depth,
+			})
+		}
+		files = append(files, file)
+	}
+
+	writeJSON(w, http.StatusOK, files)
+}
+
+// handleDetect detects known code in uploaded or named files
+func (s *Server) handleDetect(w http.ResponseWriter, r *http.Request) {
+	t, err := s.readTargets(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer t.cleanup()
+
+	state := s.current()
+	results, err := state.detector.DetectWithCorpus(r.Context(), t.paths, state.corpus)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	for _, result := range results {
+		result.TargetFile = t.name(result.TargetFile)
+	}
+	s.metrics.observeMatches(results)
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleComponents lists the known components of the corpus
+func (s *Server) handleComponents(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.current().corpus.Components())
+}
+
+// handleHealth reports that the server is up and its corpus is loaded
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	corpus := s.current().corpus
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":      "ok",
+		"known_files": corpus.Files(),
+		"components":  len(corpus.Components()),
+		"uptime":      time.Since(s.metrics.start).Round(time.Second).String(),
+	})
+}
+
+// handleMetrics writes metrics in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	corpus := s.current().corpus
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.write(w, map[string]float64{
+		"re_centris_known_files":        float64(corpus.Files()),
+		"re_centris_components":         float64(len(corpus.Components())),
+		"re_centris_goroutines":         float64(runtime.NumGoroutine()),
+		"re_centris_memory_alloc_bytes": float64(memStats.Alloc),
+	})
+}
+
+// readTargets reads the target files of a request, either uploaded as
+// multipart/form-data "file" parts or named in a JSON body
+func (s *Server) readTargets(w http.ResponseWriter, r *http.Request) (*targets, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxUploadSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		return s.readUploads(r)
+	case "application/json":
+		if !s.opts.AllowPaths {
+			return nil, errors.New("file paths are not allowed, upload the files instead")
+		}
+		var req pathsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("invalid request body: %v", err)
+		}
+		if len(req.Paths) == 0 {
+			return nil, errors.New("no paths given")
+		}
+		return &targets{paths: req.Paths}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type: %q", mediaType)
+	}
+}
+
+// readUploads stores the uploaded files of a multipart request
+func (s *Server) readUploads(r *http.Request) (*targets, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("invalid multipart body: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "re-centris-upload-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %v", err)
+	}
+	t := &targets{names: make(map[string]string), dir: dir}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.cleanup()
+			return nil, fmt.Errorf("invalid multipart body: %v", err)
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			continue
+		}
+
+		// Keep the extension, which selects the language parser; the index
+		// keeps uploads with the same name apart
+		name := part.FileName()
+		path := filepath.Join(dir, fmt.Sprintf("%d-%s", len(t.paths), filepath.Base(name)))
+		if err := saveUpload(path, part); err != nil {
+			t.cleanup()
+			return nil, err
+		}
+		t.paths = append(t.paths, path)
+		t.names[path] = name
+	}
+
+	if len(t.paths) == 0 {
+		t.cleanup()
+		return nil, errors.New("no files uploaded")
+	}
+	return t, nil
+}
+
+// saveUpload writes an uploaded file
+func saveUpload(path string, r io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to store upload: %v", err)
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to store upload: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to store upload: %v", err)
+	}
+	return nil
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Debug("Failed to write response", zap.Error(err))
+	}
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
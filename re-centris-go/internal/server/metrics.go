@@ -0,0 +1,137 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// metrics counts requests and detections for the /metrics endpoint
+type metrics struct {
+	start    time.Time
+	mutex    sync.Mutex
+	requests map[requestKey]uint64
+	seconds  map[string]float64
+	counts   map[string]uint64
+	targets  uint64
+	matches  uint64
+}
+
+// requestKey identifies a request counter
+type requestKey struct {
+	endpoint string
+	code     int
+}
+
+// newMetrics creates empty metrics
+func newMetrics() *metrics {
+	return &metrics{
+		start:    time.Now(),
+		requests: make(map[requestKey]uint64),
+		seconds:  make(map[string]float64),
+		counts:   make(map[string]uint64),
+	}
+}
+
+// observe records a finished request
+func (m *metrics) observe(endpoint string, code int, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[requestKey{endpoint, code}]++
+	m.seconds[endpoint] += duration.Seconds()
+	m.counts[endpoint]++
+}
+
+// observeMatches records the targets and matches of a detection
+func (m *metrics) observeMatches(results []*detector.DetectionResult) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.targets += uint64(len(results))
+	for _, result := range results {
+		m.matches += uint64(len(result.Matches))
+	}
+}
+
+// write writes the metrics and the given gauges in the Prometheus text format
+func (m *metrics) write(w io.Writer, gauges map[string]float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].code < keys[j].code
+	})
+
+	fmt.Fprintln(w, "# TYPE re_centris_requests_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "re_centris_requests_total{endpoint=%q,code=\"%d\"} %d\n", key.endpoint, key.code, m.requests[key])
+	}
+
+	endpoints := make([]string, 0, len(m.counts))
+	for endpoint := range m.counts {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintln(w, "# TYPE re_centris_request_duration_seconds summary")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "re_centris_request_duration_seconds_sum{endpoint=%q} %g\n", endpoint, m.seconds[endpoint])
+		fmt.Fprintf(w, "re_centris_request_duration_seconds_count{endpoint=%q} %d\n", endpoint, m.counts[endpoint])
+	}
+
+	fmt.Fprintln(w, "# TYPE re_centris_detected_targets_total counter")
+	fmt.Fprintf(w, "re_centris_detected_targets_total %d\n", m.targets)
+	fmt.Fprintln(w, "# TYPE re_centris_matches_total counter")
+	fmt.Fprintf(w, "re_centris_matches_total %d\n", m.matches)
+	fmt.Fprintln(w, "# TYPE re_centris_uptime_seconds gauge")
+	fmt.Fprintf(w, "re_centris_uptime_seconds %g\n", time.Since(m.start).Seconds())
+
+	names := make([]string, 0, len(gauges))
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "# TYPE %s gauge\n%s %g\n", name, name, gauges[name])
+	}
+}
+
+// statusWriter records the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// instrument restricts a handler to a method, unless method is empty, and
+// records its requests
+func (s *Server) instrument(endpoint, method string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+
+		if method != "" && r.Method != method {
+			sw.Header().Set("Allow", method)
+			writeError(sw, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		} else {
+			handler(sw, r)
+		}
+
+		s.metrics.observe(endpoint, sw.code, time.Since(start))
+	})
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/artifact"
+	"github.com/re-centris/re-centris-go/internal/collector/clone"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/common/schedule"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"go.uber.org/zap"
+)
+
+// RefreshOptions configures the corpus refresh scheduler
+type RefreshOptions struct {
+	// Schedule is a cron expression or descriptor such as "@every 6h"
+	Schedule string
+	// RepoList is the repository list file whose repositories are updated
+	// into the known files directory
+	RepoList string
+	Workers  int
+}
+
+// RefreshStatus reports the state of the refresh scheduler
+type RefreshStatus struct {
+	Enabled      bool      `json:"enabled"`
+	Schedule     string    `json:"schedule,omitempty"`
+	Running      bool      `json:"running"`
+	Runs         int       `json:"runs"`
+	NextRun      time.Time `json:"next_run"`
+	LastStarted  time.Time `json:"last_started"`
+	LastFinished time.Time `json:"last_finished"`
+	LastError    string    `json:"last_error,omitempty"`
+	// LastChanged lists the repositories that changed in the last run
+	LastChanged []string `json:"last_changed"`
+}
+
+// refresher periodically updates the corpus repositories, re-analyzes the
+// repositories that changed and swaps in the new corpus
+type refresher struct {
+	s       *Server
+	opts    RefreshOptions
+	trigger chan struct{}
+
+	mutex  sync.Mutex
+	status RefreshStatus
+}
+
+// start validates the options and starts the scheduler unless refreshing
+// is disabled
+func (r *refresher) start(ctx context.Context) error {
+	if r.opts.Schedule == "" {
+		return nil
+	}
+	if r.opts.RepoList == "" {
+		return errors.New("corpus refresh requires a repository list")
+	}
+	if r.s.opts.Detector.SignatureDir != "" {
+		return errors.New("corpus refresh requires a known files directory, not signatures")
+	}
+
+	sched, err := schedule.Parse(r.opts.Schedule)
+	if err != nil {
+		return err
+	}
+
+	r.trigger = make(chan struct{}, 1)
+	r.mutex.Lock()
+	r.status.Enabled = true
+	r.status.Schedule = r.opts.Schedule
+	r.mutex.Unlock()
+
+	go r.loop(ctx, sched)
+	return nil
+}
+
+// loop runs refreshes at the scheduled times and when triggered
+func (r *refresher) loop(ctx context.Context, sched schedule.Schedule) {
+	for {
+		next := sched.Next(time.Now())
+		r.mutex.Lock()
+		r.status.NextRun = next
+		r.mutex.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-r.trigger:
+			timer.Stop()
+		}
+
+		r.run(ctx)
+	}
+}
+
+// run performs a single refresh and records its outcome
+func (r *refresher) run(ctx context.Context) {
+	r.mutex.Lock()
+	r.status.Running = true
+	r.status.LastStarted = time.Now()
+	r.mutex.Unlock()
+
+	changed, err := r.refresh(ctx)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status.Running = false
+	r.status.Runs++
+	r.status.LastFinished = time.Now()
+	r.status.LastError = ""
+	r.status.LastChanged = changed
+	if err != nil {
+		r.status.LastError = err.Error()
+		logger.Error("Corpus refresh failed", zap.Error(err))
+		return
+	}
+
+	logger.Info("Corpus refresh completed",
+		zap.Strings("changed", changed),
+		zap.Duration("duration", r.status.LastFinished.Sub(r.status.LastStarted)))
+}
+
+// refresh updates the repositories and swaps in a corpus in which the
+// files of changed repositories are re-analyzed
+func (r *refresher) refresh(ctx context.Context) ([]string, error) {
+	urls, err := clone.ReadRepoList(r.opts.RepoList)
+	if err != nil {
+		return nil, err
+	}
+
+	knownDir := r.s.opts.Detector.KnownFilesDir
+	changed, err := clone.UpdateRepositories(ctx, urls, clone.CloneOptions{
+		TargetDir:  knownDir,
+		MaxWorkers: r.opts.Workers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return []string{}, nil
+	}
+
+	isChanged := make(map[string]bool, len(changed))
+	for _, name := range changed {
+		isChanged[name] = true
+	}
+
+	// Keep the analysis of unchanged repositories
+	var files []*analyzer.FileInfo
+	for _, file := range r.s.current().corpus.KnownFiles() {
+		if !isChanged[artifact.ComponentOf(knownDir, file.Path)] {
+			files = append(files, file)
+		}
+	}
+	for _, name := range changed {
+		analyzed, err := r.s.analyzer.AnalyzeDirectory(ctx, filepath.Join(knownDir, name))
+		if err != nil {
+			return changed, fmt.Errorf("failed to analyze %s: %v", name, err)
+		}
+		files = append(files, analyzed...)
+	}
+
+	// A fresh detector collects the repositories again, so new commits get
+	// new package URLs
+	r.s.swap(ctx, detector.New(r.s.opts.Detector), files)
+	return changed, nil
+}
+
+// snapshot returns a copy of the status
+func (r *refresher) snapshot() RefreshStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	status := r.status
+	if status.LastChanged == nil {
+		status.LastChanged = []string{}
+	}
+	return status
+}
+
+// handleRefresh reports the refresh status on GET and starts a refresh on POST
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.refresh.snapshot())
+	case http.MethodPost:
+		if s.refresh.trigger == nil {
+			writeError(w, http.StatusConflict, errors.New("corpus refresh is not enabled"))
+			return
+		}
+		select {
+		case s.refresh.trigger <- struct{}{}:
+		default: // a refresh is already pending
+		}
+		writeJSON(w, http.StatusAccepted, s.refresh.snapshot())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
@@ -0,0 +1,156 @@
+// Package server exposes analysis and detection over an HTTP API. The
+// known files and their function index are loaded once at startup and kept
+// in memory, so repeated detections only pay for analyzing the targets.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMaxUploadSize is the default maximum size of a request body
+	defaultMaxUploadSize = 32 << 20
+
+	// shutdownTimeout bounds how long in-flight requests may take on shutdown
+	shutdownTimeout = 30 * time.Second
+)
+
+// ServerOptions contains options for the server
+type ServerOptions struct {
+	Addr     string
+	Detector detector.DetectorOptions
+	// MaxUploadSize is the maximum size of a request body in bytes
+	MaxUploadSize int64
+	// AllowPaths allows requests to name files on the server's file system
+	// instead of uploading them
+	AllowPaths bool
+	// Refresh periodically updates the corpus, disabled if its schedule is empty
+	Refresh RefreshOptions
+}
+
+// Server serves the REST API
+type Server struct {
+	opts     ServerOptions
+	analyzer *analyzer.Analyzer
+	metrics  *metrics
+	refresh  *refresher
+
+	// state is swapped atomically when the corpus is refreshed, so requests
+	// always see a complete corpus
+	state atomic.Pointer[corpusState]
+}
+
+// corpusState is a loaded corpus with the detector that indexed it
+type corpusState struct {
+	detector *detector.Detector
+	corpus   *detector.Corpus
+}
+
+// New creates a new Server
+func New(opts ServerOptions) *Server {
+	if opts.MaxUploadSize <= 0 {
+		opts.MaxUploadSize = defaultMaxUploadSize
+	}
+	if opts.Refresh.Workers <= 0 {
+		opts.Refresh.Workers = 1
+	}
+
+	s := &Server{
+		opts:     opts,
+		analyzer: analyzer.New(opts.Detector.AnalyzerOptions()),
+		metrics:  newMetrics(),
+	}
+	s.refresh = &refresher{s: s, opts: opts.Refresh}
+	return s
+}
+
+// Load loads the known files and builds the corpus index
+func (s *Server) Load(ctx context.Context) error {
+	start := time.Now()
+
+	d := detector.New(s.opts.Detector)
+	knownFiles, err := d.LoadKnownFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load known files: %v", err)
+	}
+	corpus := s.swap(ctx, d, knownFiles)
+
+	logger.Info("Loaded corpus",
+		zap.Int("known_files", corpus.Files()),
+		zap.Int("components", len(corpus.Components())),
+		zap.Duration("duration", time.Since(start)))
+
+	return nil
+}
+
+// swap indexes known files with a detector and makes them the served corpus
+func (s *Server) swap(ctx context.Context, d *detector.Detector, knownFiles []*analyzer.FileInfo) *detector.Corpus {
+	corpus := d.NewCorpus(ctx, knownFiles)
+	s.state.Store(&corpusState{detector: d, corpus: corpus})
+	return corpus
+}
+
+// current returns the served corpus
+func (s *Server) current() *corpusState {
+	return s.state.Load()
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/analyze", s.instrument("analyze", http.MethodPost, s.handleAnalyze))
+	mux.Handle("/detect", s.instrument("detect", http.MethodPost, s.handleDetect))
+	mux.Handle("/components", s.instrument("components", http.MethodGet, s.handleComponents))
+	mux.Handle("/health", s.instrument("health", http.MethodGet, s.handleHealth))
+	mux.Handle("/metrics", s.instrument("metrics", http.MethodGet, s.handleMetrics))
+	mux.Handle("/refresh", s.instrument("refresh", "", s.handleRefresh))
+	return Compress(mux)
+}
+
+// ListenAndServe serves the API until ctx is canceled, then shuts down
+// gracefully
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.opts.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if err := s.refresh.start(ctx); err != nil {
+		return err
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		logger.Info("Server listening", zap.String("addr", s.opts.Addr))
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("failed to serve: %v", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %v", err)
+	}
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+func TestHandler(t *testing.T) {
+	s := New(ServerOptions{Detector: detector.DetectorOptions{KnownFilesDir: t.TempDir()}})
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	handler := s.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil || rec.Code != http.StatusOK || health["status"] != "ok" {
+		t.Errorf("GET /health = %d %v, want 200 ok", rec.Code, health)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/detect", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /detect = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	// Paths are rejected unless explicitly allowed
+	req := httptest.NewRequest(http.MethodPost, "/detect", strings.NewReader(`{"paths": ["/etc/passwd"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /detect with paths = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `re_centris_requests_total{endpoint="detect",code="405"} 1`) {
+		t.Errorf("GET /metrics = %q, want the rejected detect request counted", rec.Body.String())
+	}
+}
+
+func TestDetectArchiveUpload(t *testing.T) {
+	source := strings.Repeat("int f(int x) { return x * 3 + 7; }\n", 20)
+	known := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(known, "acme%lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(known, "acme%lib", "lib.c"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(ServerOptions{Detector: detector.DetectorOptions{
+		MaxWorkers:          2,
+		KnownFilesDir:       known,
+		Languages:           analyzer.DefaultLanguages(),
+		SimilarityThreshold: 0.8,
+	}})
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	entry, _ := zw.Create("src/main.c")
+	entry.Write([]byte(source))
+	zw.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "vendor.zip")
+	part.Write(archive.Bytes())
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/detect", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	var results []detector.DetectionResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST /detect = %d %v", rec.Code, err)
+	}
+	if len(results) != 1 || results[0].TargetFile != "vendor.zip!src/main.c" {
+		t.Errorf("got results %+v, want vendor.zip!src/main.c", results)
+	}
+}
@@ -0,0 +1,129 @@
+package sigindex
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// defaultFalsePositives is the false positive rate of a Bloom filter when
+// WriterOptions.FalsePositives is not set
+const defaultFalsePositives = 0.01
+
+// bloomMagic starts a Bloom filter file, followed by the format version
+const bloomMagic = "RCBF\x02"
+
+// Bloom is a Bloom filter of the content digests of the known files of an
+// index. A digest it does not contain belongs to no known file, so exact
+// copies are only looked up for the few target files it may contain.
+//
+// Its file starts with "RCBF" and the version byte 2, followed by the ID
+// of its index (see IDSize), the uint32 number of probes, and the uint64
+// number of bit words and the words themselves. Integers are
+// little-endian.
+type Bloom struct {
+	probes uint32
+	words  []uint64
+	id     []byte
+}
+
+// BloomPath returns the file of the Bloom filter written alongside the
+// index at path
+func BloomPath(path string) string {
+	return path + ".bloom"
+}
+
+// newBloom returns an empty filter sized for n digests at a false positive
+// rate
+func newBloom(n int, rate float64) *Bloom {
+	if rate <= 0 || rate >= 1 {
+		rate = defaultFalsePositives
+	}
+	n = max(n, 1)
+	bits := math.Ceil(-float64(n) * math.Log(rate) / (math.Ln2 * math.Ln2))
+	probes := math.Round(bits / float64(n) * math.Ln2)
+	return &Bloom{
+		probes: uint32(max(probes, 1)),
+		words:  make([]uint64, (int(bits)+63)/64),
+	}
+}
+
+// Add adds a digest to the filter
+func (b *Bloom) Add(digest string) {
+	h1, h2 := bloomHashes(digest)
+	bits := uint64(len(b.words)) * 64
+	for i := uint32(0); i < b.probes; i++ {
+		bit := (h1 + uint64(i)*h2) % bits
+		b.words[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains reports whether a digest may have been added. False positives
+// occur at the rate the filter was sized for; false negatives do not.
+func (b *Bloom) Contains(digest string) bool {
+	h1, h2 := bloomHashes(digest)
+	bits := uint64(len(b.words)) * 64
+	for i := uint32(0); i < b.probes; i++ {
+		bit := (h1 + uint64(i)*h2) % bits
+		if b.words[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes returns the two hashes of a digest the probes are derived
+// from, by double hashing
+func bloomHashes(digest string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(digest))
+	sum := h.Sum64()
+	// The second hash is odd so that the probes never coincide entirely
+	return sum, (sum>>32 | sum<<32) | 1
+}
+
+// writeBloom writes a filter to path
+func writeBloom(path string, b *Bloom) error {
+	data := []byte(bloomMagic)
+	data = append(data, b.id...)
+	data = binary.LittleEndian.AppendUint32(data, b.probes)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(b.words)))
+	for _, word := range b.words {
+		data = binary.LittleEndian.AppendUint64(data, word)
+	}
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write Bloom filter: %v", err)
+	}
+	return nil
+}
+
+// readBloom reads the filter at path
+func readBloom(path string) (*Bloom, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Bloom filter: %v", err)
+	}
+	corrupt := &fsutil.CorruptError{Path: path, Err: fmt.Errorf("not a Bloom filter")}
+	if len(data) < len(bloomMagic)+IDSize+12 || string(data[:len(bloomMagic)]) != bloomMagic {
+		return nil, corrupt
+	}
+	data = data[len(bloomMagic):]
+	b := &Bloom{
+		id:     data[:IDSize],
+		probes: binary.LittleEndian.Uint32(data[IDSize:]),
+	}
+	words := binary.LittleEndian.Uint64(data[IDSize+4:])
+	data = data[IDSize+12:]
+	if words == 0 || b.probes == 0 || uint64(len(data)) != words*8 {
+		return nil, corrupt
+	}
+	b.words = make([]uint64, words)
+	for i := range b.words {
+		b.words[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+	return b, nil
+}
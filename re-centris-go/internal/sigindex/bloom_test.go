@@ -0,0 +1,112 @@
+package sigindex
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/bodies"
+)
+
+func TestBloom(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	w, err := Create(path, WriterOptions{Manifest: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		digest := bodies.Digest(fmt.Sprint("known ", i))
+		if err := w.Add(&analyzer.FileInfo{Path: fmt.Sprintf("c/%d.c", i), Digest: digest}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	if loaded, err := x.LoadBloom(); !loaded || err != nil {
+		t.Fatalf("LoadBloom() = %v, %v", loaded, err)
+	}
+	for i := 0; i < 1000; i++ {
+		if !x.bloom.Contains(bodies.Digest(fmt.Sprint("known ", i))) {
+			t.Fatalf("Contains() of added digest %d = false", i)
+		}
+	}
+	positives := 0
+	for i := 0; i < 10000; i++ {
+		if x.bloom.Contains(bodies.Digest(fmt.Sprint("other ", i))) {
+			positives++
+		}
+	}
+	if positives > 300 {
+		t.Errorf("Contains() has %d false positives in 10000, want about 100", positives)
+	}
+
+	var copies []int
+	x.FilesWithDigest(bodies.Digest("known 7"), "", func(i int) { copies = append(copies, i) })
+	if len(copies) != 1 || copies[0] != 7 {
+		t.Errorf("FilesWithDigest() = %v, want [7]", copies)
+	}
+
+	// A filter of another index is rejected, even one of as many files
+	// from the same corpus manifest
+	other := filepath.Join(t.TempDir(), FileName)
+	w, err = Create(other, WriterOptions{Manifest: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		digest := bodies.Digest(fmt.Sprint("changed ", i))
+		if err := w.Add(&analyzer.FileInfo{Path: fmt.Sprintf("c/%d.c", i), Digest: digest}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(BloomPath(other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(BloomPath(path), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := x.LoadBloom(); loaded || err == nil {
+		t.Errorf("LoadBloom() of another index's filter = %v, %v, want an error", loaded, err)
+	}
+	os.Remove(BloomPath(path))
+	if loaded, err := x.LoadBloom(); loaded || err != nil {
+		t.Errorf("LoadBloom() without a filter = %v, %v", loaded, err)
+	}
+
+	// The index is not replaced if its filter cannot be written
+	os.Remove(BloomPath(other))
+	if err := os.Mkdir(BloomPath(other), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(BloomPath(other), "keep"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err = Create(other, WriterOptions{Manifest: "m3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Close(); err == nil {
+		t.Fatal("Close() with an unwritable filter succeeded")
+	}
+	y, err := Open(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	if y.Manifest() != "m1" || y.Files() != 1000 {
+		t.Errorf("index after a failed Close = %d files of manifest %q, want the previous index", y.Files(), y.Manifest())
+	}
+}
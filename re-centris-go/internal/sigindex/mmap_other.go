@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package sigindex
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads a file into memory on platforms without mmap
+func mapFile(file *os.File, size int64) ([]byte, bool, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, false, err
+	}
+	return data, false, nil
+}
+
+// unmap is not called for files read by mapFile
+func unmap(data []byte) error {
+	return nil
+}
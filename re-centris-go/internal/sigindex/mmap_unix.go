@@ -0,0 +1,22 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package sigindex
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps a file read-only into memory
+func mapFile(file *os.File, size int64) ([]byte, bool, error) {
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// unmap releases a mapping of mapFile
+func unmap(data []byte) error {
+	return syscall.Munmap(data)
+}
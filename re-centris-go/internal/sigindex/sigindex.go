@@ -0,0 +1,362 @@
+// Package sigindex reads and writes a compact binary index of the known
+// files and function signatures of a corpus, which the detector maps into
+// memory instead of loading the signatures onto the heap. Hashes are
+// compared where they are stored, and only the strings of matches are
+// copied, so corpora larger than memory can be searched with the page
+// cache holding the index.
+//
+// An index starts with a header: the magic "RCSI", the uint32 version 2,
+// the uint32 counts of files, functions and components, the uint32
+// reference of the corpus manifest hash, the uint64 offset and length of
+// the string table, and the IDSize bytes of the index ID. Fixed-width file records and function records
+// follow. A file record is the raw TLSH hash (see tlsh.AppendRaw), uint32
+// flags, the references of the path, component, language, digest and
+// normalized digest, and the uint32 index and count of its functions. A
+// function record is the raw TLSH hash, the uint32 index of its file, the
+// references of the name, scope and signature, the uint32 start and end
+// lines and the number of components containing the function. The string
+// table holds each distinct string once, prefixed with its uvarint length;
+// a reference is the offset of a string in the table, and 0 is the empty
+// string. Integers are little-endian.
+package sigindex
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// FileName is the index written into a signature directory by default
+const FileName = "signatures.idx"
+
+const (
+	magic   = "RCSI"
+	version = 2
+
+	// IDSize is the size of the ID identifying an index, the start of the
+	// SHA-256 of the header fields before it and of the string table
+	IDSize     = 16
+	headerSize = 40 + IDSize
+
+	// fileRecordSize and functionRecordSize are the widths of the records
+	fileRecordSize     = tlsh.RawSize + 4*8
+	functionRecordSize = tlsh.RawSize + 4*7
+)
+
+// Offsets of the fields of a file record after its hash
+const (
+	fileFlags = tlsh.RawSize + 4*iota
+	filePath
+	fileComponent
+	fileLanguage
+	fileDigest
+	fileNormalized
+	fileFirstFunction
+	fileFunctions
+)
+
+// Offsets of the fields of a function record after its hash
+const (
+	functionFile = tlsh.RawSize + 4*iota
+	functionName
+	functionScope
+	functionSignature
+	functionStartLine
+	functionEndLine
+	functionComponents
+)
+
+// flagHashed marks a file record with a hash; small files have none
+const flagHashed = 1
+
+// Index is a signature index opened from a file. It is safe for concurrent
+// use until Close is called; strings it returns are copies and remain valid
+// after Close.
+type Index struct {
+	path       string
+	data       []byte
+	mapped     bool
+	files      []byte
+	functions  []byte
+	strings    []byte
+	components int
+	manifest   string
+	id         []byte
+	// bloom, if loaded, holds the content digests of the known files
+	bloom *Bloom
+}
+
+// Open opens the index at path, mapping it into memory where the platform
+// allows and reading it otherwise
+func Open(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open signature index: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open signature index: %v", err)
+	}
+	if info.Size() < headerSize {
+		return nil, &fsutil.CorruptError{Path: path, Err: fmt.Errorf("index is truncated")}
+	}
+	data, mapped, err := mapFile(file, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to map signature index: %v", err)
+	}
+
+	x := &Index{path: path, data: data, mapped: mapped}
+	if err := x.parse(); err != nil {
+		x.Close()
+		return nil, &fsutil.CorruptError{Path: path, Err: err}
+	}
+	return x, nil
+}
+
+// parse checks the header and slices the sections of the index
+func (x *Index) parse() error {
+	header := x.data[:headerSize]
+	if string(header[:4]) != magic {
+		return fmt.Errorf("not a signature index")
+	}
+	if v := binary.LittleEndian.Uint32(header[4:]); v != version {
+		return fmt.Errorf("unsupported signature index version %d", v)
+	}
+	files := uint64(binary.LittleEndian.Uint32(header[8:]))
+	functions := uint64(binary.LittleEndian.Uint32(header[12:]))
+	stringsOffset := binary.LittleEndian.Uint64(header[24:])
+	stringsLength := binary.LittleEndian.Uint64(header[32:])
+
+	functionsOffset := headerSize + files*fileRecordSize
+	if stringsOffset != functionsOffset+functions*functionRecordSize || stringsOffset+stringsLength != uint64(len(x.data)) {
+		return fmt.Errorf("index sections do not match its size")
+	}
+	x.files = x.data[headerSize:functionsOffset]
+	x.functions = x.data[functionsOffset:stringsOffset]
+	x.strings = x.data[stringsOffset:]
+	x.components = int(binary.LittleEndian.Uint32(header[16:]))
+	x.manifest = x.str(binary.LittleEndian.Uint32(header[20:]))
+	x.id = header[headerSize-IDSize:]
+
+	// Function records reference their file by index
+	for i := 0; i < x.Functions(); i++ {
+		if file := uint64(binary.LittleEndian.Uint32(x.functionRecord(i)[functionFile:])); file >= files {
+			return fmt.Errorf("function %d references file %d of %d", i, file, files)
+		}
+	}
+	return nil
+}
+
+// indexID returns the ID of an index from the header fields before it and
+// the string table
+func indexID(header, strings []byte) []byte {
+	h := sha256.New()
+	h.Write(header[:headerSize-IDSize])
+	h.Write(strings)
+	return h.Sum(nil)[:IDSize]
+}
+
+// Close releases the index
+func (x *Index) Close() error {
+	if x.data == nil {
+		return nil
+	}
+	data := x.data
+	x.data, x.files, x.functions, x.strings = nil, nil, nil, nil
+	if !x.mapped {
+		return nil
+	}
+	if err := unmap(data); err != nil {
+		return fmt.Errorf("failed to unmap signature index: %v", err)
+	}
+	return nil
+}
+
+// Files returns the number of known files in the index
+func (x *Index) Files() int {
+	return len(x.files) / fileRecordSize
+}
+
+// Functions returns the number of function signatures in the index
+func (x *Index) Functions() int {
+	return len(x.functions) / functionRecordSize
+}
+
+// Components returns the number of components with function signatures
+func (x *Index) Components() int {
+	return x.components
+}
+
+// Mapped reports whether the index is mapped into memory rather than read
+func (x *Index) Mapped() bool {
+	return x.mapped
+}
+
+// Manifest returns the hash of the corpus manifest the index was built
+// from, if any
+func (x *Index) Manifest() string {
+	return x.manifest
+}
+
+// LoadBloom loads the Bloom filter written alongside the index, which
+// FilesWithDigest then consults first. It reports whether there is one; a
+// filter of another index than this one, whose ID differs, is an error.
+func (x *Index) LoadBloom() (bool, error) {
+	x.bloom = nil
+	path := BloomPath(x.path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	}
+	bloom, err := readBloom(path)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(bloom.id, x.id) {
+		return false, fmt.Errorf("%s is the Bloom filter of another index than %s", path, x.path)
+	}
+	x.bloom = bloom
+	return true, nil
+}
+
+// SimilarFiles calls fn with the index and distance of each known file of
+// a language, other than path, within maxDistance of hash
+func (x *Index) SimilarFiles(hash *tlsh.TLSH, language, path string, maxDistance int, fn func(i, distance int)) {
+	for i := 0; i < x.Files(); i++ {
+		record := x.fileRecord(i)
+		if binary.LittleEndian.Uint32(record[fileFlags:])&flagHashed == 0 || !x.equal(record[fileLanguage:], language) {
+			continue
+		}
+		distance := hash.DistanceRaw(record[:tlsh.RawSize])
+		if distance < 0 || distance > maxDistance || x.equal(record[filePath:], path) {
+			continue
+		}
+		fn(i, distance)
+	}
+}
+
+// FilesWithDigest calls fn with the index of each known file, other than
+// path, with the given content digest. The files are only scanned if the
+// Bloom filter, when loaded, may contain the digest.
+func (x *Index) FilesWithDigest(digest, path string, fn func(i int)) {
+	if x.bloom != nil && !x.bloom.Contains(digest) {
+		return
+	}
+	for i := 0; i < x.Files(); i++ {
+		record := x.fileRecord(i)
+		if x.equal(record[fileDigest:], digest) && !x.equal(record[filePath:], path) {
+			fn(i)
+		}
+	}
+}
+
+// SimilarFunctions calls fn with the index and distance of each function
+// within maxDistance of hash
+func (x *Index) SimilarFunctions(hash *tlsh.TLSH, maxDistance int, fn func(i, distance int)) {
+	for i := 0; i < x.Functions(); i++ {
+		distance := hash.DistanceRaw(x.functionRecord(i)[:tlsh.RawSize])
+		if distance >= 0 && distance <= maxDistance {
+			fn(i, distance)
+		}
+	}
+}
+
+// File returns the known file at index i, without its functions
+func (x *Index) File(i int) *analyzer.FileInfo {
+	record := x.fileRecord(i)
+	file := &analyzer.FileInfo{
+		Path:             x.field(record, filePath),
+		Component:        x.field(record, fileComponent),
+		Language:         x.field(record, fileLanguage),
+		Digest:           x.field(record, fileDigest),
+		NormalizedDigest: x.field(record, fileNormalized),
+	}
+	if binary.LittleEndian.Uint32(record[fileFlags:])&flagHashed != 0 {
+		file.Hash, _ = tlsh.ParseRaw(record[:tlsh.RawSize])
+	}
+	return file
+}
+
+// Function is a function signature of the index
+type Function struct {
+	File      string
+	Component string
+	Name      string
+	Scope     string
+	Signature string
+	StartLine int
+	EndLine   int
+	// Components is the number of components containing the function
+	Components int
+}
+
+// Function returns the function signature at index i
+func (x *Index) Function(i int) Function {
+	record := x.functionRecord(i)
+	file := x.fileRecord(int(binary.LittleEndian.Uint32(record[functionFile:])))
+	return Function{
+		File:       x.field(file, filePath),
+		Component:  x.field(file, fileComponent),
+		Name:       x.field(record, functionName),
+		Scope:      x.field(record, functionScope),
+		Signature:  x.field(record, functionSignature),
+		StartLine:  int(binary.LittleEndian.Uint32(record[functionStartLine:])),
+		EndLine:    int(binary.LittleEndian.Uint32(record[functionEndLine:])),
+		Components: int(binary.LittleEndian.Uint32(record[functionComponents:])),
+	}
+}
+
+// FunctionComponents returns the number of components containing the
+// function at index i, without copying the function
+func (x *Index) FunctionComponents(i int) int {
+	return int(binary.LittleEndian.Uint32(x.functionRecord(i)[functionComponents:]))
+}
+
+func (x *Index) fileRecord(i int) []byte {
+	return x.files[i*fileRecordSize : (i+1)*fileRecordSize]
+}
+
+func (x *Index) functionRecord(i int) []byte {
+	return x.functions[i*functionRecordSize : (i+1)*functionRecordSize]
+}
+
+// field returns a copy of the string referenced at an offset of a record
+func (x *Index) field(record []byte, offset int) string {
+	return x.str(binary.LittleEndian.Uint32(record[offset:]))
+}
+
+// str returns a copy of a string of the table
+func (x *Index) str(ref uint32) string {
+	return string(x.bytes(ref))
+}
+
+// equal reports whether the string referenced by the start of field is s,
+// without copying it
+func (x *Index) equal(field []byte, s string) bool {
+	return string(x.bytes(binary.LittleEndian.Uint32(field))) == s
+}
+
+// bytes returns the string table entry at ref in place. Entries cut off by
+// the end of the table are returned truncated.
+func (x *Index) bytes(ref uint32) []byte {
+	if uint64(ref) >= uint64(len(x.strings)) {
+		return nil
+	}
+	entry := x.strings[ref:]
+	n, size := binary.Uvarint(entry)
+	if size <= 0 {
+		return nil
+	}
+	entry = entry[size:]
+	if n < uint64(len(entry)) {
+		entry = entry[:n]
+	}
+	return entry
+}
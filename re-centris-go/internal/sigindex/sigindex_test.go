@@ -0,0 +1,131 @@
+package sigindex
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+func hash(t *testing.T, content string) *tlsh.TLSH {
+	t.Helper()
+	h, err := tlsh.New([]byte(strings.Repeat(content, 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	add, sub := hash(t, "int add(int a, int b) { return a + b; }"), hash(t, "int sub(int a, int b) { return a - b; }")
+
+	files := []*analyzer.FileInfo{
+		{
+			Path: "zlib/math.c", Component: "zlib", Language: "cpp", Hash: add, Digest: "d1",
+			Functions: []parser.Function{
+				{Name: "add", Scope: "math", Signature: "(int,int)", StartLine: 1, EndLine: 3, Hash: add.String()},
+				{Name: "broken", Hash: "not a hash"},
+			},
+		},
+		{Path: "zlib/small.c", Component: "zlib", Language: "cpp", Digest: "d2"},
+		{
+			Path: "png/math.c", Component: "png", Language: "cpp", Hash: sub, Digest: "d1",
+			Functions: []parser.Function{{Name: "sub", StartLine: 5, EndLine: 7, Hash: sub.String()}},
+		},
+	}
+
+	w, err := Create(path, WriterOptions{Manifest: "m1", Frequency: map[string]int{sub.String(): 2}})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	for _, file := range files {
+		if err := w.Add(file); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	stats, err := w.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if stats.Files != 3 || stats.Functions != 2 || stats.Components != 2 {
+		t.Errorf("Close() = %+v, want 3 files, 2 functions and 2 components", stats)
+	}
+
+	x, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if x.Files() != 3 || x.Functions() != 2 || x.Components() != 2 || x.Manifest() != "m1" {
+		t.Errorf("Open() = %d files, %d functions, %d components, manifest %q", x.Files(), x.Functions(), x.Components(), x.Manifest())
+	}
+
+	var similar []string
+	x.SimilarFiles(add, "cpp", "target.c", 0, func(i, distance int) {
+		similar = append(similar, x.File(i).Path)
+	})
+	if len(similar) != 1 || similar[0] != "zlib/math.c" {
+		t.Errorf("SimilarFiles() = %v, want [zlib/math.c]", similar)
+	}
+	x.SimilarFiles(add, "java", "target.c", 300, func(i, distance int) {
+		t.Errorf("SimilarFiles() matched %s of another language", x.File(i).Path)
+	})
+	var copies []string
+	x.FilesWithDigest("d1", "png/math.c", func(i int) {
+		copies = append(copies, x.File(i).Path)
+	})
+	if len(copies) != 1 || copies[0] != "zlib/math.c" {
+		t.Errorf("FilesWithDigest() = %v, want [zlib/math.c]", copies)
+	}
+	if file := x.File(1); file.Hash != nil || file.Digest != "d2" {
+		t.Errorf("File() of a small file = %+v", file)
+	}
+	if file := x.File(0); file.Hash.Distance(add) != 0 || file.Component != "zlib" {
+		t.Errorf("File() = %+v", file)
+	}
+
+	var functions []Function
+	x.SimilarFunctions(sub, 0, func(i, distance int) {
+		functions = append(functions, x.Function(i))
+	})
+	want := Function{File: "png/math.c", Component: "png", Name: "sub", StartLine: 5, EndLine: 7, Components: 2}
+	if len(functions) != 1 || functions[0] != want {
+		t.Errorf("SimilarFunctions() = %+v, want %+v", functions, want)
+	}
+	if fn := x.Function(0); fn.Scope != "math" || fn.Signature != "(int,int)" {
+		t.Errorf("Function() = %+v", fn)
+	}
+	if err := x.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var corrupt *fsutil.CorruptError
+	bad := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(bad[headerSize+3*fileRecordSize+functionFile:], 3)
+	if err := os.WriteFile(path, bad, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.As(err, &corrupt) {
+		t.Errorf("Open() of an index with a function of a missing file = %v, want a CorruptError", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)-1], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.As(err, &corrupt) {
+		t.Errorf("Open() of a truncated index = %v, want a CorruptError", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 2 {
+		t.Errorf("Close() left %d files, want only the index and its Bloom filter", len(entries))
+	}
+}
@@ -0,0 +1,251 @@
+package sigindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+// WriterOptions contains options for writing an index
+type WriterOptions struct {
+	// Manifest is the hash of the corpus manifest the known files belong to
+	Manifest string
+	// Frequency is the number of components containing each function
+	// signature shared by several, see preprocessor.FunctionFrequency
+	Frequency map[string]int
+	// FalsePositives is the false positive rate of the Bloom filter of
+	// content digests written alongside the index, 0.01 by default
+	FalsePositives float64
+}
+
+// Stats describes a written index
+type Stats struct {
+	Files      int   `json:"files"`
+	Functions  int   `json:"functions"`
+	Components int   `json:"components"`
+	Bytes      int64 `json:"bytes"`
+	// BloomBytes is the size of the Bloom filter's bit array
+	BloomBytes int64 `json:"bloom_bytes"`
+}
+
+// Writer writes an index. File records and the string table are held in
+// memory until Close, while function records, the bulk of an index, are
+// spilled to a temporary file next to it.
+type Writer struct {
+	opts       WriterOptions
+	path       string
+	output     *fsutil.AtomicFile
+	spill      *os.File
+	functions  *bufio.Writer
+	files      []byte
+	strings    []byte
+	refs       map[string]uint32
+	components map[string]struct{}
+	digests    []string
+	id         []byte
+	stats      Stats
+	err        error
+}
+
+// Create starts writing an index at path, which is replaced when the
+// writer is closed
+func Create(path string, opts WriterOptions) (*Writer, error) {
+	output, err := fsutil.CreateAtomic(path, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signature index: %v", err)
+	}
+	spill, err := os.CreateTemp(filepath.Dir(path), ".sigindex-*")
+	if err != nil {
+		output.Abort()
+		return nil, fmt.Errorf("failed to create signature index: %v", err)
+	}
+
+	w := &Writer{
+		opts:       opts,
+		path:       path,
+		output:     output,
+		spill:      spill,
+		functions:  bufio.NewWriter(spill),
+		strings:    []byte{0}, // the empty string
+		refs:       map[string]uint32{"": 0},
+		components: make(map[string]struct{}),
+	}
+	return w, nil
+}
+
+// Add adds a known file and its function signatures. Functions without a
+// valid hash are skipped.
+func (w *Writer) Add(file *analyzer.FileInfo) error {
+	if w.err != nil {
+		return w.err
+	}
+	if uint64(w.stats.Files) >= math.MaxUint32 {
+		return w.fail(fmt.Errorf("too many files"))
+	}
+
+	first := w.stats.Functions
+	for _, fn := range file.Functions {
+		hash, err := tlsh.Parse(fn.Hash)
+		if err != nil {
+			continue
+		}
+		if uint64(w.stats.Functions) >= math.MaxUint32 {
+			return w.fail(fmt.Errorf("too many functions"))
+		}
+		record := hash.AppendRaw(make([]byte, 0, functionRecordSize))
+		record = appendUint32(record, w.stats.Files)
+		record = appendUint32(record, int(w.ref(fn.Name)))
+		record = appendUint32(record, int(w.ref(fn.Scope)))
+		record = appendUint32(record, int(w.ref(fn.Signature)))
+		record = appendUint32(record, fn.StartLine)
+		record = appendUint32(record, fn.EndLine)
+		record = appendUint32(record, w.opts.Frequency[fn.Hash])
+		if _, err := w.functions.Write(record); err != nil {
+			return w.fail(err)
+		}
+		w.stats.Functions++
+	}
+	if w.stats.Functions > first && file.Component != "" {
+		w.components[file.Component] = struct{}{}
+	}
+
+	flags := 0
+	if file.Hash != nil {
+		flags |= flagHashed
+		w.files = file.Hash.AppendRaw(w.files)
+	} else {
+		w.files = append(w.files, make([]byte, tlsh.RawSize)...)
+	}
+	w.files = appendUint32(w.files, flags)
+	if file.Digest != "" {
+		w.digests = append(w.digests, file.Digest)
+	}
+	for _, s := range []string{file.Path, file.Component, file.Language, file.Digest, file.NormalizedDigest} {
+		w.files = appendUint32(w.files, int(w.ref(s)))
+	}
+	w.files = appendUint32(w.files, first)
+	w.files = appendUint32(w.files, w.stats.Functions-first)
+	w.stats.Files++
+
+	if uint64(len(w.strings)) > math.MaxUint32 {
+		return w.fail(fmt.Errorf("string table exceeds 4 GiB"))
+	}
+	return nil
+}
+
+// Close completes the index and replaces the file at its path, then
+// writes the Bloom filter of its content digests, see BloomPath
+func (w *Writer) Close() (*Stats, error) {
+	defer w.removeSpill()
+	if w.err != nil {
+		w.output.Abort()
+		return nil, w.err
+	}
+	if err := w.finish(); err != nil {
+		w.output.Abort()
+		return nil, fmt.Errorf("failed to write signature index: %v", err)
+	}
+	w.stats.Components = len(w.components)
+
+	// The filter replaces that of the previous index first, so that a
+	// failed write leaves the previous index with its filter; a filter
+	// left next to the previous index by a failed commit has another ID
+	bloom := newBloom(len(w.digests), w.opts.FalsePositives)
+	for _, digest := range w.digests {
+		bloom.Add(digest)
+	}
+	bloom.id = w.id
+	if err := writeBloom(BloomPath(w.path), bloom); err != nil {
+		w.output.Abort()
+		return nil, err
+	}
+	if err := w.output.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to write signature index: %v", err)
+	}
+	w.stats.BloomBytes = int64(len(bloom.words)) * 8
+	return &w.stats, nil
+}
+
+// Abort discards the index, keeping the previous file at its path
+func (w *Writer) Abort() {
+	w.removeSpill()
+	w.output.Abort()
+}
+
+// finish writes the header and the sections of the index
+func (w *Writer) finish() error {
+	if err := w.functions.Flush(); err != nil {
+		return err
+	}
+	manifest := w.ref(w.opts.Manifest)
+	functionsOffset := uint64(headerSize + len(w.files))
+	stringsOffset := functionsOffset + uint64(w.stats.Functions)*functionRecordSize
+
+	header := []byte(magic)
+	header = binary.LittleEndian.AppendUint32(header, version)
+	header = appendUint32(header, w.stats.Files)
+	header = appendUint32(header, w.stats.Functions)
+	header = appendUint32(header, len(w.components))
+	header = binary.LittleEndian.AppendUint32(header, manifest)
+	header = binary.LittleEndian.AppendUint64(header, stringsOffset)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(w.strings)))
+	header = append(header, make([]byte, IDSize)...)
+	w.id = indexID(header, w.strings)
+	copy(header[headerSize-IDSize:], w.id)
+
+	out := bufio.NewWriter(w.output)
+	out.Write(header)
+	out.Write(w.files)
+	if _, err := w.spill.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, w.spill); err != nil {
+		return err
+	}
+	out.Write(w.strings)
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	w.stats.Bytes = int64(stringsOffset) + int64(len(w.strings))
+	return nil
+}
+
+// ref returns the reference of a string, adding it to the table
+func (w *Writer) ref(s string) uint32 {
+	if ref, ok := w.refs[s]; ok {
+		return ref
+	}
+	ref := uint32(len(w.strings))
+	w.strings = binary.AppendUvarint(w.strings, uint64(len(s)))
+	w.strings = append(w.strings, s...)
+	w.refs[s] = ref
+	return ref
+}
+
+// fail records the first failed write, which Close reports
+func (w *Writer) fail(err error) error {
+	w.err = fmt.Errorf("failed to write signature index: %v", err)
+	return w.err
+}
+
+// removeSpill removes the temporary file of function records
+func (w *Writer) removeSpill() {
+	if w.spill != nil {
+		w.spill.Close()
+		os.Remove(w.spill.Name())
+		w.spill = nil
+	}
+}
+
+// appendUint32 appends a non-negative int as uint32
+func appendUint32(b []byte, n int) []byte {
+	return binary.LittleEndian.AppendUint32(b, uint32(n))
+}
@@ -0,0 +1,185 @@
+// Package signing signs result and SBOM files with ed25519 and verifies
+// them. The signature of a file is stored next to it in a JSON file with
+// the suffix SignatureSuffix, so that every output format stays valid.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/version"
+)
+
+const (
+	// SignatureSuffix is appended to the path of a signed file to name its
+	// signature file
+	SignatureSuffix = ".sig"
+	// Algorithm is the signature algorithm
+	Algorithm = "ed25519"
+
+	// formatVersion is the version of the signature file format
+	formatVersion = 1
+)
+
+// Signature is the signature file of a signed file
+type Signature struct {
+	Version     int       `json:"version"`
+	Algorithm   string    `json:"algorithm"`
+	ToolVersion string    `json:"tool_version"`
+	SignedAt    time.Time `json:"signed_at"`
+	// SHA256 is the hex digest of the signed file
+	SHA256 string `json:"sha256"`
+	// PublicKey and Signature are base64 encoded
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// message returns the bytes signed for a file: its digest together with
+// the tool version and time of signing, so that neither can be altered
+func (s *Signature) message() []byte {
+	return []byte(fmt.Sprintf("re-centris-signature/v%d\n%s\n%s\n%s\n%s\n",
+		s.Version, s.Algorithm, s.SHA256, s.ToolVersion, s.SignedAt.UTC().Format(time.RFC3339)))
+}
+
+// LoadPrivateKey reads an ed25519 private key from a PEM file in PKCS #8
+// form, as written by `openssl genpkey -algorithm ed25519`
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %v", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an ed25519 key", path)
+	}
+	return private, nil
+}
+
+// LoadPublicKey reads an ed25519 public key from a PEM file in PKIX form,
+// as written by `openssl pkey -pubout`
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %v", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an ed25519 key", path)
+	}
+	return public, nil
+}
+
+// readPEM reads the first PEM block of a file, which must have the given type
+func readPEM(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("key %s is not a PEM %s", path, blockType)
+	}
+	return block, nil
+}
+
+// SignFile signs a file and writes its signature file
+func SignFile(path string, key ed25519.PrivateKey) (*Signature, error) {
+	digest, err := fileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Signature{
+		Version:     formatVersion,
+		Algorithm:   Algorithm,
+		ToolVersion: version.Version,
+		SignedAt:    time.Now().UTC().Truncate(time.Second),
+		SHA256:      digest,
+		PublicKey:   base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	s.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, s.message()))
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signature: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path+SignatureSuffix, append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write signature: %v", err)
+	}
+	return s, nil
+}
+
+// VerifyFile checks a file against its signature file. The signature must
+// be made with trusted if it is set; otherwise the public key embedded in
+// the signature is used, which detects changes to the file but does not
+// authenticate the signer.
+func VerifyFile(path string, trusted ed25519.PublicKey) (*Signature, error) {
+	data, err := os.ReadFile(path + SignatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %v", err)
+	}
+	var s Signature
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, &fsutil.CorruptError{Path: path + SignatureSuffix, Err: err}
+	}
+	if s.Version != formatVersion || s.Algorithm != Algorithm {
+		return nil, fmt.Errorf("unsupported signature of %s: version %d, algorithm %s", path, s.Version, s.Algorithm)
+	}
+
+	public, err := base64.StdEncoding.DecodeString(s.PublicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key in signature of %s", path)
+	}
+	if trusted != nil && !trusted.Equal(ed25519.PublicKey(public)) {
+		return nil, fmt.Errorf("%s is signed with another key", path)
+	}
+	signature, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature of %s", path)
+	}
+	if !ed25519.Verify(public, s.message(), signature) {
+		return nil, fmt.Errorf("signature of %s is invalid", path)
+	}
+
+	digest, err := fileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+	if digest != s.SHA256 {
+		return nil, fmt.Errorf("%s was modified after it was signed", path)
+	}
+	return &s, nil
+}
+
+// fileDigest returns the hex SHA-256 of a file
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open signed file: %v", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to read signed file: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,78 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeKeys writes a new key pair as PEM files and returns their paths
+func writeKeys(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath, publicPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub.pem")
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+func TestSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	privatePath, publicPath := writeKeys(t, dir)
+	otherDir := t.TempDir()
+	_, otherPublicPath := writeKeys(t, otherDir)
+
+	results := filepath.Join(dir, "results.json")
+	if err := os.WriteFile(results, []byte("[]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	private, err := LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SignFile(results, private); err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFile(results, public); err != nil {
+		t.Errorf("VerifyFile() error = %v", err)
+	}
+	other, err := LoadPublicKey(otherPublicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFile(results, other); err == nil {
+		t.Error("VerifyFile() accepted a signature made with another key")
+	}
+
+	if err := os.WriteFile(results, []byte("[{}]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyFile(results, nil); err == nil {
+		t.Error("VerifyFile() accepted a modified file")
+	}
+}
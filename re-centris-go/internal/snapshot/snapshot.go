@@ -0,0 +1,534 @@
+// Package snapshot keeps immutable snapshots of a corpus in a
+// content-addressed store, so that detection runs can name the exact
+// signatures they compared against. The signatures of every repository are
+// stored as one object per commit, shared by all snapshots that contain the
+// repository at that commit.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/re-centris/re-centris-go/internal/collector/versions"
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+)
+
+const (
+	objectsDir   = "objects"
+	snapshotsDir = "snapshots"
+	checkoutsDir = "checkouts"
+)
+
+// Snapshot is an immutable corpus: the corpus manifest, the signatures of
+// each repository and the function frequency and version signatures
+// preprocessed with them. Its ID is the hash of its content, so storing
+// the same preprocessor output again yields the same snapshot.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Source is the preprocessor output the snapshot was created from
+	Source string `json:"source"`
+	// CorpusManifest is the hash stamped on results detected against the
+	// snapshot, see manifest.CorpusManifest
+	CorpusManifest string       `json:"corpus_manifest"`
+	Manifest       string       `json:"manifest"`
+	Frequency      string       `json:"frequency,omitempty"`
+	Versions       []File       `json:"versions,omitempty"`
+	Repositories   []Repository `json:"repositories"`
+}
+
+// Repository is the object holding the signatures of a repository at a
+// commit. Files outside any repository belong to a repository with an
+// empty name.
+type Repository struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit,omitempty"`
+	Object string `json:"object"`
+	Files  int    `json:"files"`
+}
+
+// File is a named object
+type File struct {
+	Name   string `json:"name"`
+	Object string `json:"object"`
+}
+
+// objects returns the objects the snapshot references
+func (s *Snapshot) objects() []string {
+	objects := []string{s.Manifest}
+	if s.Frequency != "" {
+		objects = append(objects, s.Frequency)
+	}
+	for _, f := range s.Versions {
+		objects = append(objects, f.Object)
+	}
+	for _, repo := range s.Repositories {
+		objects = append(objects, repo.Object)
+	}
+	return objects
+}
+
+// contentID returns the hash of the content of the snapshot, ignoring
+// where and when it was created
+func (s *Snapshot) contentID() (string, error) {
+	content := *s
+	content.ID, content.CreatedAt, content.Source = "", time.Time{}, ""
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal snapshot: %v", err)
+	}
+	return manifest.Hash(data), nil
+}
+
+// GCStats describes a garbage collection of a store
+type GCStats struct {
+	Snapshots int   `json:"snapshots"` // snapshots kept
+	Deleted   int   `json:"deleted"`   // snapshots deleted
+	Objects   int   `json:"objects"`   // objects kept
+	Removed   int   `json:"removed"`   // objects removed
+	Freed     int64 `json:"freed"`     // bytes of the removed objects
+}
+
+// Store is a content-addressed snapshot store in a directory. Objects are
+// zstd-compressed and named by the SHA-256 of their uncompressed content.
+// A Store is safe for concurrent use, except that GC must not run while
+// snapshots are created or checked out.
+type Store struct {
+	dir string
+}
+
+// Open returns the store in dir. The directory is created with the first
+// snapshot.
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Create stores the sharded preprocessor output in dir as a snapshot. The
+// output must have a corpus manifest, which pins its repositories to
+// commits. Creating a snapshot of output that is already stored returns
+// the existing snapshot.
+func (s *Store) Create(dir string) (*Snapshot, error) {
+	m, hash, err := manifest.Read(dir)
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{
+		CreatedAt:      time.Now().UTC(),
+		Source:         dir,
+		CorpusManifest: hash,
+	}
+
+	if snap.Manifest, err = s.putFile(filepath.Join(dir, manifest.FileName)); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, preprocessor.FrequencyFile)); err == nil {
+		if snap.Frequency, err = s.putFile(filepath.Join(dir, preprocessor.FrequencyFile)); err != nil {
+			return nil, err
+		}
+	}
+	if snap.Versions, err = s.putVersions(dir); err != nil {
+		return nil, err
+	}
+	if snap.Repositories, err = s.putRepositories(dir, m.Repositories); err != nil {
+		return nil, err
+	}
+
+	if snap.ID, err = snap.contentID(); err != nil {
+		return nil, err
+	}
+	if existing, err := s.Get(snap.ID); err == nil {
+		return existing, nil
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(s.snapshotPath(snap.ID), append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return snap, nil
+}
+
+// putRepositories stores the signatures of each repository as an object
+// of its records sorted by path, so that an unchanged repository yields
+// the same object
+func (s *Store) putRepositories(dir string, repos []manifest.Repository) ([]Repository, error) {
+	type record struct {
+		path string
+		line []byte
+	}
+	records := make(map[string][]record)
+	err := preprocessor.ReadShards(dir, func(metadata *preprocessor.FileMetadata) error {
+		line, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %v", err)
+		}
+		records[metadata.Component] = append(records[metadata.Component], record{path: metadata.Path, line: line})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signatures: %v", err)
+	}
+
+	commits := make(map[string]string, len(repos))
+	for _, repo := range repos {
+		commits[repo.Name] = repo.Commit
+	}
+
+	names := make([]string, 0, len(records))
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]Repository, 0, len(names))
+	for _, name := range names {
+		files := records[name]
+		sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+		var buf bytes.Buffer
+		for _, f := range files {
+			buf.Write(f.line)
+			buf.WriteByte('\n')
+		}
+		object, err := s.put(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Repository{Name: name, Commit: commits[name], Object: object, Files: len(files)})
+	}
+	return result, nil
+}
+
+// putVersions stores the version signatures of the preprocessor output in
+// dir, if any
+func (s *Store) putVersions(dir string) ([]File, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, versions.DirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions directory: %v", err)
+	}
+
+	var files []File
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		object, err := s.putFile(filepath.Join(dir, versions.DirName, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: entry.Name(), Object: object})
+	}
+	return files, nil
+}
+
+// putFile stores the content of a file as an object
+func (s *Store) putFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return s.put(data)
+}
+
+// put stores data as an object unless it is stored already, and returns
+// its name
+func (s *Store) put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	object := hex.EncodeToString(sum[:])
+	path := s.objectPath(object)
+	if _, err := os.Stat(path); err == nil {
+		return object, nil
+	}
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create object encoder: %v", err)
+	}
+	defer encoder.Close()
+	if err := fsutil.WriteFileAtomic(path, encoder.EncodeAll(data, nil), 0644); err != nil {
+		return "", fmt.Errorf("failed to write object: %v", err)
+	}
+	return object, nil
+}
+
+// read returns the compressed and the uncompressed content of an object,
+// reporting an object not matching its name as corrupt
+func (s *Store) read(object string) ([]byte, []byte, error) {
+	path := s.objectPath(object)
+	compressed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read object: %v", err)
+	}
+
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create object decoder: %v", err)
+	}
+	defer decoder.Close()
+	data, err := decoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, nil, &fsutil.CorruptError{Path: path, Err: err}
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != object {
+		return nil, nil, &fsutil.CorruptError{Path: path, Err: fmt.Errorf("content does not match its hash")}
+	}
+	return compressed, data, nil
+}
+
+// Get returns the snapshot with an ID or a unique prefix of it
+func (s *Store) Get(id string) (*Snapshot, error) {
+	if len(id) < 4 {
+		return nil, fmt.Errorf("snapshot ID %q is too short", id)
+	}
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Snapshot
+	for _, snap := range snapshots {
+		if !strings.HasPrefix(snap.ID, id) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("snapshot ID %s is ambiguous", id)
+		}
+		found = snap
+	}
+	if found == nil {
+		return nil, fmt.Errorf("snapshot %s not found in %s", id, s.dir)
+	}
+	return found, nil
+}
+
+// List returns the snapshots of the store, newest first
+func (s *Store) List() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, snapshotsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %v", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(s.dir, snapshotsDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %v", err)
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, &fsutil.CorruptError{Path: path, Err: err}
+		}
+		snapshots = append(snapshots, &snap)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Delete deletes a snapshot and its checkout. Its objects are removed by
+// GC once no other snapshot references them.
+func (s *Store) Delete(id string) error {
+	snap, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(s.dir, checkoutsDir, snap.ID)); err != nil {
+		return fmt.Errorf("failed to remove checkout: %v", err)
+	}
+	if err := os.Remove(s.snapshotPath(snap.ID)); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %v", err)
+	}
+	return nil
+}
+
+// GC deletes all but the keep newest snapshots, or none if keep is 0 or
+// less, and removes the objects and checkouts no snapshot references.
+func (s *Store) GC(keep int) (*GCStats, error) {
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &GCStats{}
+	if keep > 0 && len(snapshots) > keep {
+		for _, snap := range snapshots[keep:] {
+			if err := s.Delete(snap.ID); err != nil {
+				return nil, err
+			}
+			stats.Deleted++
+		}
+		snapshots = snapshots[:keep]
+	}
+	stats.Snapshots = len(snapshots)
+
+	referenced := make(map[string]bool)
+	ids := make(map[string]bool, len(snapshots))
+	for _, snap := range snapshots {
+		ids[snap.ID] = true
+		for _, object := range snap.objects() {
+			referenced[object] = true
+		}
+	}
+
+	err = filepath.WalkDir(filepath.Join(s.dir, objectsDir), func(path string, entry os.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if referenced[entry.Name()] {
+			stats.Objects++
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		stats.Removed++
+		stats.Freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect objects: %v", err)
+	}
+
+	// Checkouts of deleted snapshots and interrupted checkouts
+	entries, err := os.ReadDir(filepath.Join(s.dir, checkoutsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read checkouts: %v", err)
+	}
+	for _, entry := range entries {
+		if !ids[entry.Name()] {
+			if err := os.RemoveAll(filepath.Join(s.dir, checkoutsDir, entry.Name())); err != nil {
+				return nil, fmt.Errorf("failed to remove checkout: %v", err)
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Checkout returns a directory holding a snapshot as sharded preprocessor
+// output, creating it on first use. Detection reads it as its signatures.
+func (s *Store) Checkout(id string) (string, error) {
+	snap, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(s.dir, checkoutsDir, snap.ID)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	// Check out into a temporary directory renamed into place when
+	// complete, so an interrupted checkout is never used
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create checkouts directory: %v", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+snap.ID+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create checkout: %v", err)
+	}
+	if err := s.checkout(snap, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		if _, statErr := os.Stat(dir); statErr == nil {
+			// Checked out concurrently
+			return dir, nil
+		}
+		return "", fmt.Errorf("failed to create checkout: %v", err)
+	}
+	return dir, nil
+}
+
+// checkout writes the files of a snapshot to dir. The object of each
+// repository is a zstd-compressed JSONL shard already.
+func (s *Store) checkout(snap *Snapshot, dir string) error {
+	files := []File{{Name: manifest.FileName, Object: snap.Manifest}}
+	if snap.Frequency != "" {
+		files = append(files, File{Name: preprocessor.FrequencyFile, Object: snap.Frequency})
+	}
+	for _, f := range snap.Versions {
+		files = append(files, File{Name: filepath.Join(versions.DirName, f.Name), Object: f.Object})
+	}
+	for _, f := range files {
+		_, data, err := s.read(f.Object)
+		if err != nil {
+			return err
+		}
+		if err := fsutil.WriteFileAtomic(filepath.Join(dir, f.Name), data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", f.Name, err)
+		}
+	}
+
+	index := &preprocessor.ShardIndex{Files: make(map[string]string)}
+	for i, repo := range snap.Repositories {
+		compressed, data, err := s.read(repo.Object)
+		if err != nil {
+			return err
+		}
+		shard := fmt.Sprintf("shard-%05d.jsonl.zst", i)
+		if err := fsutil.WriteFileAtomic(filepath.Join(dir, shard), compressed, 0644); err != nil {
+			return fmt.Errorf("failed to write shard: %v", err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+		for scanner.Scan() {
+			var record struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return &fsutil.CorruptError{Path: s.objectPath(repo.Object), Err: err}
+			}
+			index.Files[record.Path] = shard
+		}
+		if err := scanner.Err(); err != nil {
+			return &fsutil.CorruptError{Path: s.objectPath(repo.Object), Err: err}
+		}
+		index.Shards = append(index.Shards, shard)
+	}
+	return preprocessor.WriteShardIndex(dir, index)
+}
+
+// objectPath returns the path of an object, fanned out by its first byte
+func (s *Store) objectPath(object string) string {
+	return filepath.Join(s.dir, objectsDir, object[:2], object)
+}
+
+// snapshotPath returns the path of a snapshot record
+func (s *Store) snapshotPath(id string) string {
+	return filepath.Join(s.dir, snapshotsDir, id+".json")
+}
@@ -0,0 +1,117 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"github.com/re-centris/re-centris-go/internal/preprocessor"
+)
+
+// preprocess writes the files of each repository to corpus and returns
+// its sharded preprocessor output
+func preprocess(t *testing.T, corpus string, repos map[string]string) string {
+	t.Helper()
+	for name, source := range repos {
+		if err := os.MkdirAll(filepath.Join(corpus, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(corpus, name, name+".c"), []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := t.TempDir()
+	p := preprocessor.New(preprocessor.PreprocessorOptions{
+		MaxWorkers:         2,
+		OutputDir:          out,
+		Languages:          analyzer.DefaultLanguages(),
+		CheckpointInterval: 1000,
+		OutputFormat:       preprocessor.FormatSharded,
+	})
+	if err := p.ProcessDirectory(context.Background(), corpus); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// source returns C functions computing with seed
+func source(seed int) string {
+	var b strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&b, "int f%d(int x) {\n  int y = x * %d + %d;\n  return y - %d;\n}\n", i, seed+i, seed*i, i)
+	}
+	return b.String()
+}
+
+func TestStore(t *testing.T) {
+	store := Open(t.TempDir())
+	corpus := t.TempDir()
+
+	out := preprocess(t, corpus, map[string]string{"lib": source(1), "app": source(2)})
+	old, err := store.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != old.ID {
+		t.Errorf("snapshot of the same output = %s, want %s", again.ID, old.ID)
+	}
+
+	// The unchanged repository is stored once
+	cur, err := store.Create(preprocess(t, corpus, map[string]string{"lib": source(1), "app": source(3)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cur.ID == old.ID {
+		t.Fatal("snapshot of a changed corpus has the same ID")
+	}
+	if len(cur.Repositories) != 2 || cur.Repositories[1].Name != "lib" || cur.Repositories[1].Object != old.Repositories[1].Object {
+		t.Errorf("repositories = %+v, want the lib object of %+v", cur.Repositories, old.Repositories)
+	}
+
+	// A checkout is read like the preprocessor output
+	dir, err := store.Checkout(old.ID[:8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, hash, err := manifest.Read(dir); err != nil || hash != old.CorpusManifest {
+		t.Errorf("checked out manifest hash = %s, %v, want %s", hash, err, old.CorpusManifest)
+	}
+	var components []string
+	if err := preprocessor.ReadShards(dir, func(m *preprocessor.FileMetadata) error {
+		components = append(components, m.Component)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(components, ",") != "app,lib" {
+		t.Errorf("checked out components = %v, want app and lib", components)
+	}
+
+	// Keeping the newest snapshot removes the old manifest and app objects
+	stats, err := store.GC(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Deleted != 1 || stats.Removed != 2 {
+		t.Errorf("gc = %+v, want 1 snapshot and 2 objects removed", stats)
+	}
+	if _, err := store.Get(old.ID); err == nil {
+		t.Error("deleted snapshot is still found")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("checkout of the deleted snapshot is still present")
+	}
+	if _, err := store.Checkout(cur.ID); err != nil {
+		t.Errorf("checkout of the kept snapshot: %v", err)
+	}
+}
@@ -0,0 +1,382 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// Levels of the browser, from the outermost
+const (
+	levelComponents = iota
+	levelFiles
+	levelMatches
+)
+
+// Keys the browser responds to
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyBack
+	keyToggle
+	keySave
+	keyQuit
+)
+
+// item is a match of a target file, keyed by its baseline entry and label
+type item struct {
+	result *detector.DetectionResult
+	match  detector.Match
+	entry  detector.BaselineEntry
+	label  detector.Label
+}
+
+// component groups the matched items of a component by target file
+type component struct {
+	name    string
+	targets []string
+	items   map[string][]item
+	best    float64
+}
+
+// model is the state of the browser, independent of the terminal
+type model struct {
+	components []*component
+	baseline   *detector.Baseline
+	labels     *detector.Labels
+	level      int
+	cursor     [3]int
+	offset     [3]int
+	dirty      bool
+	status     string
+}
+
+// newModel groups the matches of results by component, components with the
+// most matched files first and targets and matches most similar first.
+// Marks go to the baseline and the labels, whichever are set.
+func newModel(d *detector.Detector, results []*detector.DetectionResult, baseline *detector.Baseline, labels *detector.Labels) *model {
+	byName := make(map[string]*component)
+	for _, result := range results {
+		for _, match := range result.Matches {
+			entry := d.BaselineEntry(result, match)
+			c := byName[entry.Component]
+			if c == nil {
+				c = &component{name: entry.Component, items: make(map[string][]item)}
+				byName[entry.Component] = c
+			}
+			if _, ok := c.items[entry.Target]; !ok {
+				c.targets = append(c.targets, entry.Target)
+			}
+			it := item{result: result, match: match, entry: entry, label: d.Label(result, match)}
+			c.items[entry.Target] = append(c.items[entry.Target], it)
+			c.best = max(c.best, match.Similarity)
+		}
+	}
+
+	m := &model{baseline: baseline, labels: labels}
+	for _, c := range byName {
+		for _, items := range c.items {
+			sort.SliceStable(items, func(i, j int) bool {
+				return items[i].match.Similarity > items[j].match.Similarity
+			})
+		}
+		sort.SliceStable(c.targets, func(i, j int) bool {
+			x, y := c.items[c.targets[i]][0].match.Similarity, c.items[c.targets[j]][0].match.Similarity
+			if x != y {
+				return x > y
+			}
+			return c.targets[i] < c.targets[j]
+		})
+		m.components = append(m.components, c)
+	}
+	sort.Slice(m.components, func(i, j int) bool {
+		x, y := m.components[i], m.components[j]
+		if len(x.targets) != len(y.targets) {
+			return len(x.targets) > len(y.targets)
+		}
+		return x.name < y.name
+	})
+	return m
+}
+
+// rows returns the number of rows of the current level
+func (m *model) rows() int {
+	switch m.level {
+	case levelFiles:
+		return len(m.component().targets)
+	case levelMatches:
+		return len(m.matches())
+	}
+	return len(m.components)
+}
+
+// component returns the selected component
+func (m *model) component() *component {
+	return m.components[m.cursor[levelComponents]]
+}
+
+// matches returns the matches of the selected target file
+func (m *model) matches() []item {
+	c := m.component()
+	return c.items[c.targets[m.cursor[levelFiles]]]
+}
+
+// selected returns the items of the selected row
+func (m *model) selected() []item {
+	switch m.level {
+	case levelFiles:
+		return m.matches()
+	case levelMatches:
+		return m.matches()[m.cursor[levelMatches] : m.cursor[levelMatches]+1]
+	}
+	var items []item
+	c := m.component()
+	for _, target := range c.targets {
+		items = append(items, c.items[target]...)
+	}
+	return items
+}
+
+// marked counts the items marked as false positives
+func (m *model) marked(items []item) int {
+	n := 0
+	for _, it := range items {
+		if m.isMarked(it) {
+			n++
+		}
+	}
+	return n
+}
+
+// isMarked reports whether an item is marked, in the baseline if set and
+// in the labels otherwise
+func (m *model) isMarked(it item) bool {
+	if m.baseline != nil {
+		return m.baseline.Accepts(it.entry)
+	}
+	return m.labels.IsFalsePositive(it.label.TargetHash, it.label.KnownHash)
+}
+
+// update applies a key and reports whether the browser should quit
+func (m *model) update(k key, pageSize int) bool {
+	m.status = ""
+	if k == keyQuit {
+		return true
+	}
+	if len(m.components) == 0 {
+		return false
+	}
+
+	cursor := &m.cursor[m.level]
+	switch k {
+	case keyUp:
+		*cursor--
+	case keyDown:
+		*cursor++
+	case keyPageUp:
+		*cursor -= max(pageSize, 1)
+	case keyPageDown:
+		*cursor += max(pageSize, 1)
+	case keyHome:
+		*cursor = 0
+	case keyEnd:
+		*cursor = m.rows() - 1
+	case keyEnter:
+		if m.level < levelMatches {
+			m.level++
+			m.cursor[m.level], m.offset[m.level] = 0, 0
+		}
+	case keyBack:
+		if m.level > levelComponents {
+			m.level--
+		}
+	case keyToggle:
+		m.toggle()
+	}
+	m.cursor[m.level] = min(max(m.cursor[m.level], 0), m.rows()-1)
+	return false
+}
+
+// toggle marks the selected items as false positives, or unmarks them if
+// they all are
+func (m *model) toggle() {
+	items := m.selected()
+	unmark := m.marked(items) == len(items)
+	for _, it := range items {
+		switch {
+		case m.baseline != nil && unmark:
+			m.baseline.Reject(it.entry)
+		case m.baseline != nil:
+			m.baseline.Accept(it.entry)
+		}
+		// Results of older runs lack the target digest labels are keyed by
+		switch {
+		case m.labels == nil || it.label.TargetHash == "":
+		case unmark:
+			m.labels.Remove(it.label.TargetHash, it.label.KnownHash)
+		default:
+			m.labels.Add(it.label)
+		}
+	}
+	m.dirty = true
+	if unmark {
+		m.status = fmt.Sprintf("Unmarked %d matches", len(items))
+	} else {
+		m.status = fmt.Sprintf("Marked %d matches as false positives", len(items))
+	}
+}
+
+// view renders the current level in a terminal of the given size
+func (m *model) view(width, height int) string {
+	var lines []string
+	if len(m.components) == 0 {
+		lines = append(lines, "No matches")
+	} else {
+		lines = m.table(width, height)
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	footer := "↑/↓ move  enter open  esc back  space false positive  w save  q quit"
+	if m.status != "" {
+		footer = m.status
+	} else if m.dirty {
+		footer += "  (unsaved)"
+	}
+	lines = append(lines[:max(height-1, 0)], "\x1b[7m"+pad(footer, width)+"\x1b[0m")
+
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// table renders the title, the rows of the current level and, for matches,
+// the evidence of the selected match
+func (m *model) table(width, height int) []string {
+	c := m.component()
+	title := "Components"
+	var header string
+	var rows []string
+	switch m.level {
+	case levelComponents:
+		header = fmt.Sprintf("%-32s %6s %8s %6s %s", "COMPONENT", "FILES", "MATCHES", "BEST", "FALSE POSITIVES")
+		for _, c := range m.components {
+			var items []item
+			for _, target := range c.targets {
+				items = append(items, c.items[target]...)
+			}
+			rows = append(rows, fmt.Sprintf("%-32s %6d %8d %6.2f %d", truncate(c.name, 32), len(c.targets), len(items), c.best, m.marked(items)))
+		}
+	case levelFiles:
+		title = c.name
+		header = fmt.Sprintf("%-6s %8s %-3s %s", "BEST", "MATCHES", "FP", "TARGET")
+		for _, target := range c.targets {
+			items := c.items[target]
+			rows = append(rows, fmt.Sprintf("%-6.2f %8d %-3s %s", items[0].match.Similarity, len(items), mark(m.marked(items), len(items)), target))
+		}
+	case levelMatches:
+		title = c.name + " › " + c.targets[m.cursor[levelFiles]]
+		header = fmt.Sprintf("%-6s %8s %-10s %-3s %s", "SIM", "DISTANCE", "CLONE", "FP", "KNOWN FILE")
+		for _, it := range m.matches() {
+			fp := mark(m.marked([]item{it}), 1)
+			rows = append(rows, fmt.Sprintf("%-6.2f %8d %-10s %-3s %s", it.match.Similarity, it.match.Distance, it.match.CloneType, fp, it.match.File))
+		}
+	}
+
+	var detail []string
+	if m.level == levelMatches {
+		detail = evidence(m.matches()[m.cursor[levelMatches]].match)
+	}
+
+	// Rows scroll to keep the cursor visible above the detail pane
+	visible := max(height-3-min(len(detail), height/2), 1)
+	cursor, offset := m.cursor[m.level], &m.offset[m.level]
+	if cursor < *offset {
+		*offset = cursor
+	} else if cursor >= *offset+visible {
+		*offset = cursor - visible + 1
+	}
+
+	lines := []string{"\x1b[1m" + truncate(title, width) + "\x1b[0m", truncate(header, width)}
+	for i := *offset; i < len(rows) && i < *offset+visible; i++ {
+		line := truncate(rows[i], width)
+		if i == cursor {
+			line = "\x1b[7m" + pad(line, width) + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < visible+2 {
+		lines = append(lines, "")
+	}
+	for i := 0; i < len(detail) && len(lines) < height-1; i++ {
+		lines = append(lines, truncate(detail[i], width))
+	}
+	return lines
+}
+
+// evidence describes the matched functions of a match
+func evidence(match detector.Match) []string {
+	if len(match.Evidence) == 0 {
+		return []string{"", "No matched functions recorded"}
+	}
+	lines := []string{"", fmt.Sprintf("%-8s %-28s %-12s %-28s %s", "DISTANCE", "FUNCTION", "LINES", "KNOWN FUNCTION", "KNOWN LINES")}
+	for _, e := range match.Evidence {
+		lines = append(lines, fmt.Sprintf("%-8d %-28s %-12s %-28s %s", e.Distance,
+			truncate(e.Function, 28), lineRange(e.TargetLines), truncate(e.KnownFunction, 28), lineRange(e.KnownLines)))
+	}
+	return lines
+}
+
+// mark is the false positive column of a row with n of total items marked
+func mark(n, total int) string {
+	switch {
+	case n == 0:
+		return ""
+	case n == total:
+		return "x"
+	}
+	return "~"
+}
+
+func lineRange(r detector.LineRange) string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// truncate cuts s to at most width runes
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// pad extends s with spaces to width runes
+func pad(s string, width int) string {
+	if n := width - len([]rune(s)); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return truncate(s, width)
+}
@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package tui
+
+import "errors"
+
+// makeRaw reports that raw mode is unsupported on this platform
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("interactive terminals are not supported on this platform")
+}
+
+// terminalSize returns the assumed terminal size
+func terminalSize(fd int) (width, height int) {
+	return 80, 24
+}
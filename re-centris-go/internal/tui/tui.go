@@ -0,0 +1,179 @@
+// Package tui implements an interactive terminal browser for detection
+// results
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// Options contains options for the browser
+type Options struct {
+	Results []*detector.DetectionResult
+	// Detector derives the components and baseline entries of matches
+	Detector *detector.Detector
+	// Baseline and Labels, if set, receive the matches marked as false
+	// positives and are saved to BaselinePath and LabelsPath
+	Baseline     *detector.Baseline
+	BaselinePath string
+	Labels       *detector.Labels
+	LabelsPath   string
+}
+
+// Browser browses detection results grouped by component
+type Browser struct {
+	opts  Options
+	model *model
+}
+
+// New creates a browser
+func New(opts Options) *Browser {
+	return &Browser{
+		opts:  opts,
+		model: newModel(opts.Detector, opts.Results, opts.Baseline, opts.Labels),
+	}
+}
+
+// Run shows the browser on the terminal of stdin and stdout until it is
+// quit, saving the baseline and labels if false positives were marked or
+// unmarked
+func (b *Browser) Run() error {
+	fd := int(os.Stdin.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal: %v", err)
+	}
+	defer restore()
+
+	// Use the alternate screen so the shell is restored on exit
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	buf := make([]byte, 64)
+	for {
+		width, height := terminalSize(int(os.Stdout.Fd()))
+		fmt.Fprint(os.Stdout, "\x1b[H\x1b[2J"+b.model.view(width, height))
+
+		n, err := os.Stdin.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+		quit := false
+		for _, k := range parseKeys(buf[:n]) {
+			if k == keySave {
+				b.save()
+				continue
+			}
+			if b.model.update(k, height-4) {
+				quit = true
+				break
+			}
+		}
+		if quit {
+			break
+		}
+	}
+
+	if b.model.dirty {
+		return b.write()
+	}
+	return nil
+}
+
+// write saves the baseline and labels that are set
+func (b *Browser) write() error {
+	if b.opts.Baseline != nil {
+		if err := b.opts.Baseline.Save(b.opts.BaselinePath); err != nil {
+			return err
+		}
+	}
+	if b.opts.Labels != nil {
+		if err := b.opts.Labels.Save(b.opts.LabelsPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save writes the baseline and reports the outcome in the status line
+func (b *Browser) save() {
+	if err := b.write(); err != nil {
+		b.model.status = err.Error()
+		return
+	}
+	b.model.dirty = false
+	b.model.status = "Saved false positives"
+}
+
+// parseKeys decodes the keys of a chunk of terminal input
+func parseKeys(input []byte) []key {
+	var keys []key
+	for len(input) > 0 {
+		k, n := parseKey(input)
+		if k != keyNone {
+			keys = append(keys, k)
+		}
+		input = input[n:]
+	}
+	return keys
+}
+
+// parseKey decodes the first key of input and returns its length
+func parseKey(input []byte) (key, int) {
+	escapes := []struct {
+		seq string
+		key key
+	}{
+		{"\x1b[A", keyUp}, {"\x1bOA", keyUp},
+		{"\x1b[B", keyDown}, {"\x1bOB", keyDown},
+		{"\x1b[C", keyEnter}, {"\x1bOC", keyEnter},
+		{"\x1b[D", keyBack}, {"\x1bOD", keyBack},
+		{"\x1b[5~", keyPageUp}, {"\x1b[6~", keyPageDown},
+		{"\x1b[H", keyHome}, {"\x1b[1~", keyHome},
+		{"\x1b[F", keyEnd}, {"\x1b[4~", keyEnd},
+	}
+	if input[0] == 0x1b {
+		for _, e := range escapes {
+			if len(input) >= len(e.seq) && string(input[:len(e.seq)]) == e.seq {
+				return e.key, len(e.seq)
+			}
+		}
+		if len(input) > 1 && input[1] == '[' {
+			// Skip unknown sequences rather than reading them as keys
+			n := 2
+			for n < len(input) && (input[n] < 0x40 || input[n] > 0x7e) {
+				n++
+			}
+			return keyNone, min(n+1, len(input))
+		}
+		return keyBack, 1
+	}
+
+	switch input[0] {
+	case 'k':
+		return keyUp, 1
+	case 'j':
+		return keyDown, 1
+	case 'g':
+		return keyHome, 1
+	case 'G':
+		return keyEnd, 1
+	case '\r', '\n', 'l':
+		return keyEnter, 1
+	case 0x7f, 0x08, 'h':
+		return keyBack, 1
+	case ' ', 'f':
+		return keyToggle, 1
+	case 'w':
+		return keySave, 1
+	case 'q', 0x03:
+		return keyQuit, 1
+	}
+	return keyNone, 1
+}
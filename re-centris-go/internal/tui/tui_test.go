@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+func TestBrowser(t *testing.T) {
+	d := detector.New(detector.DetectorOptions{KnownFilesDir: "/known"})
+	results := []*detector.DetectionResult{
+		{TargetFile: "src/inflate.c", Matches: []detector.Match{
+			{File: "/known/zlib/inflate.c", Hash: "T1A", Similarity: 0.9},
+			{File: "/known/libpng/png.c", Hash: "T1B", Similarity: 0.6},
+		}},
+		{TargetFile: "src/deflate.c", Matches: []detector.Match{
+			{File: "/known/zlib/deflate.c", Hash: "T1C", Similarity: 0.95, Evidence: []detector.Evidence{
+				{Function: "deflate", KnownFunction: "deflate", Distance: 3},
+			}},
+		}},
+	}
+	baseline := d.NewBaseline(nil)
+	m := newModel(d, results, baseline, nil)
+
+	if got := m.view(100, 10); !strings.Contains(got, "zlib") || strings.Index(got, "zlib") > strings.Index(got, "libpng") {
+		t.Fatalf("components view = %q, want zlib before libpng", got)
+	}
+
+	// Open zlib, then its most similar target, and mark its match
+	for _, k := range parseKeys([]byte("\r\r ")) {
+		m.update(k, 5)
+	}
+	if got := m.view(100, 10); !strings.Contains(got, "/known/zlib/deflate.c") || !strings.Contains(got, "deflate") {
+		t.Errorf("matches view = %q, want the deflate match and its evidence", got)
+	}
+	want := detector.BaselineEntry{Target: "src/deflate.c", Component: "zlib", Hash: "T1C"}
+	if len(baseline.Entries) != 1 || baseline.Entries[0] != want || !m.dirty {
+		t.Fatalf("baseline = %+v, want %+v", baseline.Entries, want)
+	}
+
+	// Marking the whole component marks the other zlib match; marking it
+	// again unmarks both
+	for _, k := range parseKeys([]byte("\x1b\x1b[D ")) {
+		m.update(k, 5)
+	}
+	if len(baseline.Entries) != 2 {
+		t.Errorf("baseline after marking zlib = %+v, want both zlib matches", baseline.Entries)
+	}
+	m.update(keyToggle, 5)
+	if len(baseline.Entries) != 0 {
+		t.Errorf("baseline after unmarking zlib = %+v, want none", baseline.Entries)
+	}
+	if !m.update(keyQuit, 5) {
+		t.Error("update(keyQuit) = false, want true")
+	}
+}
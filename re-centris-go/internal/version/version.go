@@ -0,0 +1,5 @@
+package version
+
+// Version is the version of Re-Centris, set at build time with
+// -ldflags "-X github.com/re-centris/re-centris-go/internal/version.Version=..."
+var Version = "dev"
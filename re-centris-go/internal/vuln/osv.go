@@ -0,0 +1,186 @@
+package vuln
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/fsutil"
+)
+
+const (
+	// DefaultEndpoint is the query endpoint of the public OSV API
+	DefaultEndpoint = "https://api.osv.dev/v1/query"
+
+	// defaultCacheTTL is how long cached responses are used before they are refreshed
+	defaultCacheTTL = 24 * time.Hour
+
+	// defaultTimeout is the timeout of a single OSV request
+	defaultTimeout = 30 * time.Second
+)
+
+// validCommit matches git commit hashes, which are also used as cache file names
+var validCommit = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// Record is an OSV vulnerability record as returned by the query API
+type Record struct {
+	ID       string   `json:"id"`
+	Summary  string   `json:"summary,omitempty"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Modified string   `json:"modified,omitempty"`
+}
+
+// queryResponse is the response of the OSV query endpoint
+type queryResponse struct {
+	Vulns []Record `json:"vulns"`
+}
+
+// ClientOptions contains options for the OSV client
+type ClientOptions struct {
+	Endpoint string        // query endpoint, defaults to the public OSV API
+	CacheDir string        // directory caching responses per commit, disabled if empty
+	CacheTTL time.Duration // age after which cached responses are refreshed
+	Offline  bool          // answer only from the cache, never query the API
+	Timeout  time.Duration
+}
+
+// Client queries OSV for vulnerabilities affecting a commit
+type Client struct {
+	opts ClientOptions
+	http *http.Client
+}
+
+// NewClient creates a new OSV client
+func NewClient(opts ClientOptions) *Client {
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = defaultCacheTTL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	return &Client{
+		opts: opts,
+		http: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+// Query returns the vulnerabilities affecting a git commit. Responses are
+// cached in the cache directory; in offline mode a missing cache entry is
+// an error.
+func (c *Client) Query(ctx context.Context, commit string) ([]Record, error) {
+	if !validCommit.MatchString(commit) {
+		return nil, fmt.Errorf("invalid commit hash: %q", commit)
+	}
+
+	// Use the cache while it is fresh, or always when offline
+	if records, age, err := c.readCache(commit); err == nil && (c.opts.Offline || age < c.opts.CacheTTL) {
+		return records, nil
+	}
+	if c.opts.Offline {
+		return nil, fmt.Errorf("no cached vulnerability data for commit %s", commit)
+	}
+
+	records, err := c.query(ctx, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.writeCache(commit, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// query sends a query for a commit to the OSV API
+func (c *Client) query(ctx context.Context, commit string) ([]Record, error) {
+	body, err := json.Marshal(map[string]string{"commit": commit})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OSV query: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OSV request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OSV: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("OSV query failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+
+	var response queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse OSV response: %v", err)
+	}
+
+	return response.Vulns, nil
+}
+
+// readCache returns the cached records of a commit and their age
+func (c *Client) readCache(commit string) ([]Record, time.Duration, error) {
+	if c.opts.CacheDir == "" {
+		return nil, 0, os.ErrNotExist
+	}
+
+	path := filepath.Join(c.opts.CacheDir, commit+".json")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, 0, err
+	}
+
+	return records, time.Since(info.ModTime()), nil
+}
+
+// writeCache stores the records of a commit in the cache directory
+func (c *Client) writeCache(commit string, records []Record) error {
+	if c.opts.CacheDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.opts.CacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create vulnerability cache: %v", err)
+	}
+
+	if records == nil {
+		records = []Record{}
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vulnerability cache: %v", err)
+	}
+
+	path := filepath.Join(c.opts.CacheDir, commit+".json")
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write vulnerability cache: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package vuln
+
+import (
+	"context"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+	"go.uber.org/zap"
+)
+
+// EnricherOptions contains options for the vulnerability enricher
+type EnricherOptions struct {
+	Client        ClientOptions
+	KnownFilesDir string                // directory of known components, used to attribute file matches
+	Repositories  []manifest.Repository // commits of the known components
+}
+
+// Enricher attaches known vulnerabilities to detection results
+type Enricher struct {
+	opts    EnricherOptions
+	client  *Client
+	commits map[string]string
+}
+
+// New creates a new Enricher
+func New(opts EnricherOptions) *Enricher {
+	commits := make(map[string]string, len(opts.Repositories))
+	for _, repo := range opts.Repositories {
+		if repo.Commit != "" {
+			commits[repo.Name] = repo.Commit
+		}
+	}
+
+	return &Enricher{
+		opts:    opts,
+		client:  NewClient(opts.Client),
+		commits: commits,
+	}
+}
+
+// Enrich attaches the vulnerabilities of the matched components to every
+// result. Each component is queried once. Components without a known commit
+// or whose query fails are skipped with a warning.
+func (e *Enricher) Enrich(ctx context.Context, results []*detector.DetectionResult) error {
+	known := make(map[string][]Record)
+
+	for _, result := range results {
+		for _, component := range e.components(result) {
+			records, ok := known[component]
+			if !ok {
+				var err error
+				records, err = e.lookup(ctx, component)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err != nil {
+					logger.Warn("Failed to look up vulnerabilities",
+						zap.String("component", component),
+						zap.Error(err))
+				}
+				known[component] = records
+			}
+
+			for _, record := range records {
+				result.Vulnerabilities = append(result.Vulnerabilities, detector.Vulnerability{
+					ID:        record.ID,
+					Component: component,
+					Summary:   record.Summary,
+					Aliases:   record.Aliases,
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookup queries the vulnerabilities of a component's commit
+func (e *Enricher) lookup(ctx context.Context, component string) ([]Record, error) {
+	commit, ok := e.commits[component]
+	if !ok {
+		logger.Debug("No commit known for component",
+			zap.String("component", component))
+		return nil, nil
+	}
+	return e.client.Query(ctx, commit)
+}
+
+// components returns the distinct components matched by a result
+func (e *Enricher) components(result *detector.DetectionResult) []string {
+	var (
+		components []string
+		seen       = make(map[string]bool)
+	)
+
+	add := func(component string) {
+		if component != "" && !seen[component] {
+			seen[component] = true
+			components = append(components, component)
+		}
+	}
+
+	for _, c := range result.Components {
+		add(c.Component)
+	}
+	for _, match := range result.Matches {
+		add(match.ComponentIn(e.opts.KnownFilesDir))
+	}
+
+	return components
+}
@@ -0,0 +1,64 @@
+package vuln
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+)
+
+func TestEnrich(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		var query map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil || query["commit"] != commit {
+			t.Errorf("unexpected OSV query %v (%v)", query, err)
+		}
+		json.NewEncoder(w).Encode(queryResponse{Vulns: []Record{{ID: "OSV-2024-1", Aliases: []string{"CVE-2024-0001"}}}})
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	opts := EnricherOptions{
+		Client:        ClientOptions{Endpoint: server.URL, CacheDir: cacheDir},
+		KnownFilesDir: "/known",
+		Repositories:  []manifest.Repository{{Name: "zlib", Commit: commit}},
+	}
+
+	newResults := func() []*detector.DetectionResult {
+		return []*detector.DetectionResult{
+			{TargetFile: "a.c", Components: []detector.ComponentMatch{{Component: "zlib"}}},
+			{TargetFile: "b.c", Matches: []detector.Match{{File: "/known/zlib/inflate.c"}, {File: "/known/other/x.c"}}},
+		}
+	}
+
+	results := newResults()
+	if err := New(opts).Enrich(context.Background(), results); err != nil {
+		t.Fatalf("Enrich() error = %v", err)
+	}
+	for _, result := range results {
+		if len(result.Vulnerabilities) != 1 || result.Vulnerabilities[0].ID != "OSV-2024-1" {
+			t.Errorf("vulnerabilities of %s = %+v, want OSV-2024-1", result.TargetFile, result.Vulnerabilities)
+		}
+	}
+	if queries != 1 {
+		t.Errorf("OSV queries = %d, want 1", queries)
+	}
+
+	// Offline mode answers from the cache without querying
+	opts.Client.Offline = true
+	results = newResults()
+	if err := New(opts).Enrich(context.Background(), results); err != nil {
+		t.Fatalf("Enrich() offline error = %v", err)
+	}
+	if queries != 1 || len(results[0].Vulnerabilities) != 1 {
+		t.Errorf("offline enrichment made %d queries with %+v, want cached result", queries, results[0].Vulnerabilities)
+	}
+}
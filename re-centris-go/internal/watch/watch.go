@@ -0,0 +1,230 @@
+// Package watch keeps detection results of a directory up to date while
+// its files change, re-detecting only the files that were modified.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"go.uber.org/zap"
+)
+
+// defaultDebounce is how long the watcher waits for further changes before
+// re-detecting, so that editors saving in several steps trigger one update
+const defaultDebounce = 300 * time.Millisecond
+
+// Update describes the results after a batch of changes
+type Update struct {
+	// Results holds the current results of all watched files sorted by path
+	Results []*detector.DetectionResult
+	// NewMatches holds the matches that were not reported before, by target file
+	NewMatches map[string][]detector.Match
+	// Removed lists target files that were deleted
+	Removed []string
+}
+
+// WatcherOptions contains options for the watcher
+type WatcherOptions struct {
+	Dir      string
+	Detector *detector.Detector
+	Corpus   *detector.Corpus
+	// Languages maps languages to the extensions of watched files
+	Languages map[string][]string
+	Debounce  time.Duration
+}
+
+// Watcher re-detects changed files of a directory
+type Watcher struct {
+	opts     WatcherOptions
+	analyzer *analyzer.Analyzer
+	results  map[string]*detector.DetectionResult
+}
+
+// New creates a new Watcher
+func New(opts WatcherOptions) *Watcher {
+	if opts.Debounce <= 0 {
+		opts.Debounce = defaultDebounce
+	}
+	return &Watcher{
+		opts:     opts,
+		analyzer: analyzer.New(analyzer.AnalyzerOptions{Languages: opts.Languages}),
+		results:  make(map[string]*detector.DetectionResult),
+	}
+}
+
+// Run detects all files of the directory, then watches it until ctx is
+// canceled, calling fn after the initial detection and after every batch
+// of changes
+func (w *Watcher) Run(ctx context.Context, fn func(*Update) error) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %v", err)
+	}
+	defer fsw.Close()
+
+	// fsnotify does not watch recursively, so every directory is added
+	var files []string
+	err = filepath.Walk(w.opts.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fsw.Add(path)
+		}
+		if w.analyzer.LanguageOf(path) != "" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch directory: %v", err)
+	}
+
+	update, err := w.detect(ctx, files)
+	if err != nil {
+		return err
+	}
+	if err := fn(update); err != nil {
+		return err
+	}
+
+	logger.Info("Watching for changes", zap.String("dir", w.opts.Dir))
+
+	var (
+		pending = make(map[string]struct{})
+		timer   = time.NewTimer(0)
+	)
+	<-timer.C
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.addDirectory(fsw, event.Name, pending)
+				}
+			}
+			// Removed files are no longer sniffed, so tracked files always count
+			if _, tracked := w.results[event.Name]; !tracked && w.analyzer.LanguageOf(event.Name) == "" {
+				continue
+			}
+			pending[event.Name] = struct{}{}
+			timer.Reset(w.opts.Debounce)
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warn("Watcher error", zap.Error(err))
+
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			pending = make(map[string]struct{})
+
+			update, err := w.detect(ctx, paths)
+			if err != nil {
+				logger.Error("Failed to detect changed files", zap.Error(err))
+				continue
+			}
+			if err := fn(update); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// addDirectory watches a new directory and queues the files it already
+// contains, which were created before the watch was added
+func (w *Watcher) addDirectory(fsw *fsnotify.Watcher, dir string, pending map[string]struct{}) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if err := fsw.Add(path); err != nil {
+				logger.Warn("Failed to watch directory", zap.String("dir", path), zap.Error(err))
+			}
+			return nil
+		}
+		if w.analyzer.LanguageOf(path) != "" {
+			pending[path] = struct{}{}
+		}
+		return nil
+	})
+}
+
+// detect re-detects the given files and updates the results
+func (w *Watcher) detect(ctx context.Context, paths []string) (*Update, error) {
+	update := &Update{NewMatches: make(map[string][]detector.Match)}
+
+	var existing []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if _, ok := w.results[path]; ok {
+				delete(w.results, path)
+				update.Removed = append(update.Removed, path)
+			}
+			continue
+		}
+		existing = append(existing, path)
+	}
+	sort.Strings(update.Removed)
+
+	results, err := w.opts.Detector.DetectWithCorpus(ctx, existing, w.opts.Corpus)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files too small to hash produce no result and no longer match
+	detected := make(map[string]bool, len(results))
+	for _, result := range results {
+		detected[result.TargetFile] = true
+	}
+	for _, path := range existing {
+		if !detected[path] {
+			delete(w.results, path)
+		}
+	}
+
+	for _, result := range results {
+		known := make(map[string]bool)
+		if previous, ok := w.results[result.TargetFile]; ok {
+			for _, match := range previous.Matches {
+				known[match.File] = true
+			}
+		}
+		for _, match := range result.Matches {
+			if !known[match.File] {
+				update.NewMatches[result.TargetFile] = append(update.NewMatches[result.TargetFile], match)
+			}
+		}
+		w.results[result.TargetFile] = result
+	}
+
+	update.Results = make([]*detector.DetectionResult, 0, len(w.results))
+	for _, result := range w.results {
+		update.Results = append(update.Results, result)
+	}
+	sort.Slice(update.Results, func(i, j int) bool {
+		return update.Results[i].TargetFile < update.Results[j].TargetFile
+	})
+
+	return update, nil
+}
@@ -0,0 +1,237 @@
+// Package webhook notifies external systems when a detection run finishes.
+// Each webhook receives a JSON summary of the results; if it has a secret,
+// the body is signed with HMAC-SHA256 so the receiver can verify it.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/common/logger"
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"go.uber.org/zap"
+)
+
+const (
+	// EventDetectionCompleted is the event sent when a detection run finishes
+	EventDetectionCompleted = "detection.completed"
+
+	// SignatureHeader carries the HMAC-SHA256 signature of the body as
+	// "sha256=<hex>"
+	SignatureHeader = "X-Re-Centris-Signature"
+
+	// EventHeader carries the event name
+	EventHeader = "X-Re-Centris-Event"
+
+	// defaultTimeout is the timeout of a single delivery
+	defaultTimeout = 10 * time.Second
+
+	// defaultTopMatches is the default number of matches in a summary
+	defaultTopMatches = 10
+)
+
+// Hook is a webhook endpoint
+type Hook struct {
+	URL    string `mapstructure:"url"`
+	Secret string `mapstructure:"secret"`
+}
+
+// NotifierOptions contains options for the notifier
+type NotifierOptions struct {
+	Hooks   []Hook
+	Timeout time.Duration
+	// TopMatches is the number of highest-similarity matches in a summary
+	TopMatches int
+}
+
+// Notifier delivers detection summaries to webhooks
+type Notifier struct {
+	opts NotifierOptions
+	http *http.Client
+}
+
+// Summary is the payload of a detection.completed event
+type Summary struct {
+	Event           string             `json:"event"`
+	SchemaVersion   string             `json:"schema_version"`
+	Timestamp       time.Time          `json:"timestamp"`
+	TargetFiles     int                `json:"target_files"`
+	MatchedFiles    int                `json:"matched_files"`
+	TotalMatches    int                `json:"total_matches"`
+	Components      []ComponentSummary `json:"components"`
+	TopMatches      []TopMatch         `json:"top_matches"`
+	Vulnerabilities int                `json:"vulnerabilities"`
+}
+
+// ComponentSummary counts the matches of a component
+type ComponentSummary struct {
+	Component   string `json:"component"`
+	PURL        string `json:"purl,omitempty"`
+	TargetFiles int    `json:"target_files"`
+	Matches     int    `json:"matches"`
+}
+
+// TopMatch is one of the highest-similarity matches of a run
+type TopMatch struct {
+	TargetFile string  `json:"target_file"`
+	KnownFile  string  `json:"known_file"`
+	PURL       string  `json:"purl,omitempty"`
+	Similarity float64 `json:"similarity"`
+}
+
+// New creates a new Notifier
+func New(opts NotifierOptions) *Notifier {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.TopMatches <= 0 {
+		opts.TopMatches = defaultTopMatches
+	}
+
+	return &Notifier{
+		opts: opts,
+		http: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+// Summarize builds the summary of detection results. componentOf maps a
+// known file to its component for matches without a recorded component.
+func (n *Notifier) Summarize(results []*detector.DetectionResult, componentOf func(string) string) *Summary {
+	s := &Summary{
+		Event:         EventDetectionCompleted,
+		SchemaVersion: detector.SchemaVersion,
+		Timestamp:     time.Now().UTC(),
+		TargetFiles:   len(results),
+		Components:    []ComponentSummary{},
+		TopMatches:    []TopMatch{},
+	}
+
+	components := make(map[string]*ComponentSummary)
+	for _, result := range results {
+		if len(result.Matches) > 0 {
+			s.MatchedFiles++
+		}
+		s.TotalMatches += len(result.Matches)
+		s.Vulnerabilities += len(result.Vulnerabilities)
+
+		seen := make(map[string]bool)
+		for _, match := range result.Matches {
+			s.TopMatches = append(s.TopMatches, TopMatch{
+				TargetFile: result.TargetFile,
+				KnownFile:  match.File,
+				PURL:       match.PURL,
+				Similarity: match.Similarity,
+			})
+
+			name := match.Component
+			if name == "" {
+				name = componentOf(match.File)
+			}
+			if name == "" {
+				continue
+			}
+			c, ok := components[name]
+			if !ok {
+				c = &ComponentSummary{Component: name, PURL: match.PURL}
+				components[name] = c
+			}
+			c.Matches++
+			if !seen[name] {
+				seen[name] = true
+				c.TargetFiles++
+			}
+		}
+	}
+
+	for _, c := range components {
+		s.Components = append(s.Components, *c)
+	}
+	sort.Slice(s.Components, func(i, j int) bool {
+		if s.Components[i].Matches != s.Components[j].Matches {
+			return s.Components[i].Matches > s.Components[j].Matches
+		}
+		return s.Components[i].Component < s.Components[j].Component
+	})
+
+	sort.SliceStable(s.TopMatches, func(i, j int) bool {
+		return s.TopMatches[i].Similarity > s.TopMatches[j].Similarity
+	})
+	if len(s.TopMatches) > n.opts.TopMatches {
+		s.TopMatches = s.TopMatches[:n.opts.TopMatches]
+	}
+
+	return s
+}
+
+// Notify delivers a summary to every webhook. Failed deliveries are logged
+// and returned together, so one unreachable endpoint does not prevent the
+// others from being notified.
+func (n *Notifier) Notify(ctx context.Context, summary *Summary) error {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook summary: %v", err)
+	}
+
+	failed := 0
+	for _, hook := range n.opts.Hooks {
+		if err := n.deliver(ctx, hook, summary.Event, body); err != nil {
+			failed++
+			logger.Warn("Failed to deliver webhook",
+				zap.String("url", hook.URL),
+				zap.Error(err))
+			continue
+		}
+		logger.Debug("Delivered webhook", zap.String("url", hook.URL))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to deliver %d of %d webhooks", failed, len(n.opts.Hooks))
+	}
+	return nil
+}
+
+// deliver posts a body to a single webhook
+func (n *Notifier) deliver(ctx context.Context, hook Hook, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature header value of a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether a signature header value matches a body
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+func TestNotify(t *testing.T) {
+	var (
+		summary   Summary
+		signature string
+		body      []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		json.Unmarshal(body, &summary)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	results := []*detector.DetectionResult{
+		{TargetFile: "a.c", Matches: []detector.Match{{File: "known/zlib/a.c", Similarity: 0.9}, {File: "known/zlib/b.c", Similarity: 0.95}}},
+		{TargetFile: "b.c", Matches: []detector.Match{{File: "known/png/a.c", Similarity: 0.85}}},
+		{TargetFile: "c.c"},
+	}
+	componentOf := func(path string) string { return strings.Split(path, "/")[1] }
+
+	n := New(NotifierOptions{Hooks: []Hook{{URL: srv.URL, Secret: "secret"}}, TopMatches: 2})
+	if err := n.Notify(context.Background(), n.Summarize(results, componentOf)); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if !Verify("secret", body, signature) {
+		t.Errorf("signature %q does not verify", signature)
+	}
+	if summary.MatchedFiles != 2 || summary.TotalMatches != 3 {
+		t.Errorf("summary counts = %d files, %d matches, want 2, 3", summary.MatchedFiles, summary.TotalMatches)
+	}
+	if len(summary.Components) != 2 || summary.Components[0].Component != "zlib" || summary.Components[0].Matches != 2 {
+		t.Errorf("components = %+v, want zlib with 2 matches first", summary.Components)
+	}
+	if len(summary.TopMatches) != 2 || summary.TopMatches[0].Similarity != 0.95 {
+		t.Errorf("top matches = %+v, want the 2 best", summary.TopMatches)
+	}
+}
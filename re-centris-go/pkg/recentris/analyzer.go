@@ -0,0 +1,146 @@
+package recentris
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/analyzer/parser"
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+)
+
+// ErrFileTooSmall is returned for files too small to hash
+var ErrFileTooSmall = tlsh.ErrDataTooSmall
+
+// IsSkipped reports whether an error of AnalyzeFile means the file was
+// skipped, because it is too small to hash or binary, minified or generated
+func IsSkipped(err error) bool {
+	return analyzer.IsSkipped(err)
+}
+
+// File is an analyzed source file
+type File struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	// Hash is the TLSH digest of the file
+	Hash      string     `json:"hash"`
+	Size      int64      `json:"size"`
+	Functions []Function `json:"functions"`
+}
+
+// Function is a function of an analyzed file
+type Function struct {
+	Name      string `json:"name"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	// Hash is the TLSH digest of the function body
+	Hash string `json:"hash"`
+	// Scope is the qualified namespace and class of the function and
+	// Signature its normalized parameter types, telling overloads apart
+	Scope     string `json:"scope,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// Complexity is the cyclomatic complexity of the body, Tokens its
+	// number of tokens and Depth its deepest block nesting
+	Complexity int `json:"complexity,omitempty"`
+	Tokens     int `json:"tokens,omitempty"`
+	Depth      int `json:"depth,omitempty"`
+}
+
+// Analyzer computes the signatures of source files
+type Analyzer interface {
+	// AnalyzeFile analyzes a single file. Files too small to hash return
+	// ErrFileTooSmall, other skipped files an error satisfying IsSkipped.
+	AnalyzeFile(ctx context.Context, path string) (*File, error)
+	// AnalyzeDirectory analyzes all supported files below dir
+	AnalyzeDirectory(ctx context.Context, dir string) ([]*File, error)
+}
+
+// NewAnalyzer creates an Analyzer
+func NewAnalyzer(opts Options) Analyzer {
+	o := opts.detectorOptions()
+	return &fileAnalyzer{a: analyzer.New(analyzer.AnalyzerOptions{
+		MaxWorkers: o.MaxWorkers,
+		Languages:  o.Languages,
+	})}
+}
+
+// fileAnalyzer implements Analyzer
+type fileAnalyzer struct {
+	a *analyzer.Analyzer
+}
+
+func (f *fileAnalyzer) AnalyzeFile(ctx context.Context, path string) (*File, error) {
+	info, err := f.a.AnalyzeFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(info), nil
+}
+
+func (f *fileAnalyzer) AnalyzeDirectory(ctx context.Context, dir string) ([]*File, error) {
+	infos, err := f.a.AnalyzeDirectory(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*File, len(infos))
+	for i, info := range infos {
+		files[i] = newFile(info)
+	}
+	return files, nil
+}
+
+// newFile converts an internal file analysis
+func newFile(info *analyzer.FileInfo) *File {
+	file := &File{
+		Path:      info.Path,
+		Language:  info.Language,
+		Hash:      info.Hash.String(),
+		Size:      info.Size,
+		Functions: make([]Function, len(info.Functions)),
+	}
+	for i, fn := range info.Functions {
+		file.Functions[i] = Function{
+			Name:       fn.Name,
+			StartLine:  fn.StartLine,
+			EndLine:    fn.EndLine,
+			Hash:       fn.Hash,
+			Scope:      fn.Scope,
+			Signature:  fn.Signature,
+			Complexity: fn.Complexity,
+			Tokens:     fn.Tokens,
+			Depth:      fn.Depth,
+		}
+	}
+	return file
+}
+
+// fileInfo converts a file back into an internal file analysis
+func (f *File) fileInfo() (*analyzer.FileInfo, error) {
+	hash, err := tlsh.Parse(f.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hash for %s: %v", f.Path, err)
+	}
+
+	functions := make([]parser.Function, len(f.Functions))
+	for i, fn := range f.Functions {
+		functions[i] = parser.Function{
+			Name:       fn.Name,
+			StartLine:  fn.StartLine,
+			EndLine:    fn.EndLine,
+			Hash:       fn.Hash,
+			Scope:      fn.Scope,
+			Signature:  fn.Signature,
+			Complexity: fn.Complexity,
+			Tokens:     fn.Tokens,
+			Depth:      fn.Depth,
+		}
+	}
+
+	return &analyzer.FileInfo{
+		Path:      f.Path,
+		Language:  f.Language,
+		Hash:      hash,
+		Size:      f.Size,
+		Functions: functions,
+	}, nil
+}
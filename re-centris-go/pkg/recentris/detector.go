@@ -0,0 +1,105 @@
+package recentris
+
+import (
+	"context"
+	"sync"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// Component is a known component of a database
+type Component struct {
+	Name      string `json:"name"`
+	PURL      string `json:"purl,omitempty"`
+	Files     int    `json:"files"`
+	Functions int    `json:"functions"`
+}
+
+// Database holds the analyzed files of known components. Known file paths
+// start with the component directory below Options.KnownFilesDir.
+type Database interface {
+	// KnownFiles returns all known files
+	KnownFiles() []*File
+	// Components returns the known components sorted by name
+	Components() []Component
+}
+
+// Detector detects known code in target files. It is safe for concurrent use.
+type Detector interface {
+	// Detect analyzes target files and matches them against the database
+	Detect(ctx context.Context, paths []string) ([]*Result, error)
+}
+
+// OpenDatabase loads the known files of Options.SignatureDir or
+// Options.KnownFilesDir and indexes them for detection
+func OpenDatabase(ctx context.Context, opts Options) (Database, error) {
+	d := detector.New(opts.detectorOptions())
+	knownFiles, err := d.LoadKnownFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &database{detector: d, corpus: d.NewCorpus(ctx, knownFiles)}, nil
+}
+
+// NewDetector creates a Detector for a database. Databases opened with
+// OpenDatabase are reused as they are; other implementations are indexed
+// once here.
+func NewDetector(db Database, opts Options) (Detector, error) {
+	if own, ok := db.(*database); ok {
+		return own, nil
+	}
+
+	known := db.KnownFiles()
+	files := make([]*analyzer.FileInfo, 0, len(known))
+	for _, file := range known {
+		info, err := file.fileInfo()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, info)
+	}
+
+	d := detector.New(opts.detectorOptions())
+	return &database{detector: d, corpus: d.NewCorpus(context.Background(), files)}, nil
+}
+
+// database implements Database and Detector over an indexed corpus
+type database struct {
+	detector *detector.Detector
+	corpus   *detector.Corpus
+
+	filesOnce sync.Once
+	files     []*File
+}
+
+func (db *database) KnownFiles() []*File {
+	db.filesOnce.Do(func() {
+		db.files = make([]*File, 0, db.corpus.Files())
+		for _, info := range db.corpus.KnownFiles() {
+			db.files = append(db.files, newFile(info))
+		}
+	})
+	return db.files
+}
+
+func (db *database) Components() []Component {
+	components := make([]Component, 0, len(db.corpus.Components()))
+	for _, c := range db.corpus.Components() {
+		components = append(components, Component{
+			Name:      c.Name,
+			PURL:      c.PURL,
+			Files:     c.Files,
+			Functions: c.Functions,
+		})
+	}
+	return components
+}
+
+func (db *database) Detect(ctx context.Context, paths []string) ([]*Result, error) {
+	results, err := db.detector.DetectWithCorpus(ctx, paths, db.corpus)
+	if err != nil {
+		return nil, err
+	}
+	return newResults(results), nil
+}
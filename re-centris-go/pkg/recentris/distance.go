@@ -0,0 +1,105 @@
+package recentris
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/re-centris/re-centris-go/internal/analyzer/tlsh"
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// Hash is a TLSH hash compared by a DistanceBackend. The zero Hash stands
+// for a file or function without a hash.
+type Hash struct {
+	h *tlsh.TLSH
+}
+
+// ParseHash parses the hex form of a hash, as in File.Hash
+func ParseHash(s string) (Hash, error) {
+	h, err := tlsh.Parse(s)
+	if err != nil {
+		return Hash{}, fmt.Errorf("invalid hash %q: %v", s, err)
+	}
+	return Hash{h: h}, nil
+}
+
+// IsZero reports whether h is the zero Hash
+func (h Hash) IsZero() bool {
+	return h.h == nil
+}
+
+// String returns the hex form of the hash, or an empty string for the zero
+// Hash
+func (h Hash) String() string {
+	return h.h.String()
+}
+
+// Bytes returns the binary form of the hash that String encodes in hex, or
+// nil for the zero Hash
+func (h Hash) Bytes() []byte {
+	if h.h == nil {
+		return nil
+	}
+	return h.h.AppendRaw(nil)
+}
+
+// Distance returns the TLSH distance between two hashes, or -1 if either
+// is the zero Hash
+func (h Hash) Distance(other Hash) int {
+	return h.h.Distance(other.h)
+}
+
+// DistanceBackend computes the TLSH distances between batches of target
+// and known hashes, such as on vector units, a GPU or a remote service. It
+// must be safe for concurrent use.
+type DistanceBackend interface {
+	// Distances returns one row per target holding its distances to all
+	// candidates, as Hash.Distance computes them: -1 where a hash is zero
+	Distances(ctx context.Context, targets, candidates []Hash) ([][]int, error)
+}
+
+// GoDistances is the default, pure Go DistanceBackend
+type GoDistances struct{}
+
+// Distances implements DistanceBackend
+func (GoDistances) Distances(ctx context.Context, targets, candidates []Hash) ([][]int, error) {
+	rows := make([][]int, len(targets))
+	for i, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rows[i] = make([]int, len(candidates))
+		for j, candidate := range candidates {
+			rows[i][j] = target.Distance(candidate)
+		}
+	}
+	return rows, nil
+}
+
+// distanceBackend adapts a DistanceBackend to the detector
+type distanceBackend struct {
+	backend DistanceBackend
+}
+
+// internalBackend returns the detector backend of a DistanceBackend, or nil
+// for the default backend of the detector
+func internalBackend(backend DistanceBackend) detector.DistanceBackend {
+	switch backend.(type) {
+	case nil, GoDistances, *GoDistances:
+		return nil
+	}
+	return distanceBackend{backend: backend}
+}
+
+func (b distanceBackend) Distances(ctx context.Context, targets, candidates []*tlsh.TLSH) ([][]int, error) {
+	return b.backend.Distances(ctx, hashes(targets), hashes(candidates))
+}
+
+// hashes wraps internal hashes
+func hashes(internal []*tlsh.TLSH) []Hash {
+	wrapped := make([]Hash, len(internal))
+	for i, h := range internal {
+		wrapped[i] = Hash{h: h}
+	}
+	return wrapped
+}
@@ -0,0 +1,97 @@
+// Package recentris is the public Go API of Re-Centris. It analyzes source
+// files into TLSH signatures and detects code reused from a database of
+// known open source components.
+//
+// The package follows semantic versioning: exported identifiers are only
+// removed or changed incompatibly in a new major version. Result types
+// mirror the detection result JSON schema and change with SchemaVersion.
+//
+// A typical use opens a database once and reuses it for many detections:
+//
+//	db, err := recentris.OpenDatabase(ctx, recentris.Options{KnownFilesDir: "repos"})
+//	if err != nil {
+//		return err
+//	}
+//	d, err := recentris.NewDetector(db, recentris.Options{KnownFilesDir: "repos"})
+//	if err != nil {
+//		return err
+//	}
+//	results, err := d.Detect(ctx, []string{"src/inflate.c"})
+package recentris
+
+import (
+	"github.com/re-centris/re-centris-go/internal/analyzer"
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// SchemaVersion is the version of the detection result schema
+const SchemaVersion = detector.SchemaVersion
+
+// Default option values
+const (
+	DefaultWorkers           = 5
+	DefaultThreshold         = 0.8
+	DefaultFunctionThreshold = 30
+)
+
+// Options configures analyzers, databases and detectors
+type Options struct {
+	// KnownFilesDir holds one directory per known component
+	KnownFilesDir string
+	// SignatureDir, if set, loads the database from sharded preprocessor
+	// output instead of analyzing KnownFilesDir
+	SignatureDir string
+	// Workers is the number of files processed in parallel
+	Workers int
+	// Threshold is the minimum similarity (0.0-1.0) of a file match
+	Threshold float64
+	// LanguageThresholds overrides Threshold for specific languages
+	LanguageThresholds map[string]float64
+	// FunctionThreshold is the maximum TLSH distance for two functions to match
+	FunctionThreshold int
+	// Languages maps languages to file extensions; DefaultLanguages if nil
+	Languages map[string][]string
+	// MinSimilarity, MaxMatchesPerFile and TopK limit the matches reported;
+	// zero means no limit
+	MinSimilarity     float64
+	MaxMatchesPerFile int
+	TopK              int
+	// DistanceBackend, if set, computes the TLSH distances of detections,
+	// such as on a GPU or a remote service; pure Go by default
+	DistanceBackend DistanceBackend
+}
+
+// DefaultLanguages returns the file extensions of the supported languages
+func DefaultLanguages() map[string][]string {
+	return analyzer.DefaultLanguages()
+}
+
+// detectorOptions converts options to the internal detector options
+func (o Options) detectorOptions() detector.DetectorOptions {
+	opts := detector.DetectorOptions{
+		MaxWorkers:          o.Workers,
+		SimilarityThreshold: o.Threshold,
+		LanguageThresholds:  o.LanguageThresholds,
+		FunctionThreshold:   o.FunctionThreshold,
+		KnownFilesDir:       o.KnownFilesDir,
+		SignatureDir:        o.SignatureDir,
+		Languages:           o.Languages,
+		MinSimilarity:       o.MinSimilarity,
+		MaxMatchesPerFile:   o.MaxMatchesPerFile,
+		TopK:                o.TopK,
+		DistanceBackend:     internalBackend(o.DistanceBackend),
+	}
+	if opts.MaxWorkers <= 0 {
+		opts.MaxWorkers = DefaultWorkers
+	}
+	if opts.SimilarityThreshold <= 0 {
+		opts.SimilarityThreshold = DefaultThreshold
+	}
+	if opts.FunctionThreshold <= 0 {
+		opts.FunctionThreshold = DefaultFunctionThreshold
+	}
+	if opts.Languages == nil {
+		opts.Languages = DefaultLanguages()
+	}
+	return opts
+}
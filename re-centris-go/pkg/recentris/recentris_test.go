@@ -0,0 +1,173 @@
+package recentris
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+	"github.com/re-centris/re-centris-go/internal/manifest"
+)
+
+const source = `int component_a_specific(struct ctx *c) {
+    return c->flags & CTX_FLAG_READY && c->other_field_value > 10;
+}
+`
+
+// staticDatabase is a Database implemented outside the package
+type staticDatabase struct {
+	files []*File
+}
+
+func (db staticDatabase) KnownFiles() []*File     { return db.files }
+func (db staticDatabase) Components() []Component { return nil }
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	known := filepath.Join(dir, "known")
+	target := filepath.Join(dir, "target.c")
+	if err := os.MkdirAll(filepath.Join(known, "zlib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(known, "zlib", "inflate.c"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	opts := Options{KnownFilesDir: known}
+	db, err := OpenDatabase(ctx, opts)
+	if err != nil {
+		t.Fatalf("OpenDatabase() error = %v", err)
+	}
+	if components := db.Components(); len(components) != 1 || components[0].Name != "zlib" {
+		t.Errorf("Components() = %+v, want zlib", components)
+	}
+
+	// A database implemented by the caller is indexed by NewDetector
+	for name, db := range map[string]Database{"opened": db, "custom": staticDatabase{files: db.KnownFiles()}} {
+		d, err := NewDetector(db, opts)
+		if err != nil {
+			t.Fatalf("%s: NewDetector() error = %v", name, err)
+		}
+		results, err := d.Detect(ctx, []string{target})
+		if err != nil {
+			t.Fatalf("%s: Detect() error = %v", name, err)
+		}
+		if len(results) != 1 || len(results[0].Matches) != 1 || results[0].SchemaVersion != SchemaVersion {
+			t.Fatalf("%s: Detect() = %+v, want one versioned result with one match", name, results)
+		}
+		if got := results[0].Components; len(got) != 1 || got[0].Component != "zlib" {
+			t.Errorf("%s: components = %+v, want zlib", name, got)
+		}
+	}
+}
+
+// countingBackend is a DistanceBackend implemented outside the package
+type countingBackend struct {
+	calls atomic.Int64
+}
+
+func (b *countingBackend) Distances(ctx context.Context, targets, candidates []Hash) ([][]int, error) {
+	b.calls.Add(1)
+	return GoDistances{}.Distances(ctx, targets, candidates)
+}
+
+func TestDistanceBackend(t *testing.T) {
+	dir := t.TempDir()
+	known := filepath.Join(dir, "known")
+	target := filepath.Join(dir, "target.c")
+	if err := os.MkdirAll(filepath.Join(known, "zlib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(known, "zlib", "inflate.c"), target} {
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend := &countingBackend{}
+	opts := Options{KnownFilesDir: known, DistanceBackend: backend}
+	ctx := context.Background()
+	db, err := OpenDatabase(ctx, opts)
+	if err != nil {
+		t.Fatalf("OpenDatabase() error = %v", err)
+	}
+	d, err := NewDetector(db, opts)
+	if err != nil {
+		t.Fatalf("NewDetector() error = %v", err)
+	}
+	results, err := d.Detect(ctx, []string{target})
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if len(results) != 1 || len(results[0].Matches) != 1 || backend.calls.Load() == 0 {
+		t.Errorf("Detect() = %+v with %d backend calls, want one match computed by the backend", results, backend.calls.Load())
+	}
+
+	hash, err := ParseHash(results[0].Matches[0].Hash)
+	if err != nil {
+		t.Fatalf("ParseHash() error = %v", err)
+	}
+	if hash.Distance(hash) != 0 || hash.Distance(Hash{}) != -1 || len(hash.Bytes()) == 0 {
+		t.Errorf("Hash %s compares wrongly", hash)
+	}
+}
+
+// TestResultSchema checks that results converted from the detector encode
+// as the detection result schema does
+func TestResultSchema(t *testing.T) {
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	lines := detector.LineRange{Start: 3, End: 9}
+	internal := &detector.DetectionResult{
+		SchemaVersion: SchemaVersion, TargetFile: "src/inflate.c", Digest: "d", Hash: "h",
+		CorpusManifest: "m", CorpusSnapshot: "s", TotalFiles: 4, MatchCount: 1, OmittedMatches: 2,
+		Duplicates: []string{"src/copy.c"},
+		Matches: []detector.Match{{
+			File: "zlib/inflate.c", Component: "zlib", Similarity: 0.9, Distance: 12, Confidence: 0.8,
+			Hash: "h", PURL: "pkg:github/madler/zlib", CloneType: detector.CloneNearMiss,
+			Evidence: []detector.Evidence{{
+				Function: "inflate", TargetLines: lines, KnownFunction: "inflate", KnownLines: lines,
+				Distance: 5, QualifiedFunction: "inflate(z_streamp)", KnownQualifiedFunction: "inflate(z_streamp)", Diff: "-a\n+b\n",
+			}},
+			Explanation: &detector.Explanation{
+				Reason: detector.ReasonTLSHDistance, Distance: 12, MaxDistance: 40, SharedFunctions: 1, RareFunctions: 1,
+				MatchedLines: 7, MeanFrequency: 1.5, ComponentFunctions: 1, Summary: "similar",
+			},
+			Internal: true, FalsePositive: true,
+			Provenance: &detector.Provenance{Commit: "abc", Author: "dev", Date: date, Summary: "vendor zlib", Lines: 7, TotalLines: 7},
+		}},
+		Components: []detector.ComponentMatch{{
+			Component: "zlib", PURL: "pkg:github/madler/zlib", MatchedFunctions: 1, Score: 0.5, Internal: true,
+			Version: &detector.ComponentVersion{Range: "1.2–1.3", First: "1.2", FirstDate: date, Last: "1.3", LastDate: date, MatchedFunctions: 1},
+			Metadata: &manifest.Metadata{
+				Description: "zlib", License: "Zlib", Stars: 5, Archived: true, PushedAt: &date,
+				LatestRelease: "1.3", LatestReleaseDate: &date, FetchedAt: date,
+			},
+		}},
+		ModifiedFunctions: []detector.ModifiedFunction{{
+			Function: "inflate", Lines: lines, Component: "zlib", KnownFile: "zlib/inflate.c", KnownFunction: "inflate",
+			KnownLines: lines, Distance: 35, QualifiedFunction: "inflate(z_streamp)", KnownQualifiedFunction: "inflate(z_streamp)",
+		}},
+		Vulnerabilities: []detector.Vulnerability{{ID: "CVE-2022-37434", Component: "zlib", Summary: "overflow", Aliases: []string{"GHSA-x"}}},
+		License:         &detector.LicenseInfo{Target: "MIT", Components: map[string]string{"zlib": "Zlib"}, Conflicts: []string{"none"}},
+	}
+
+	want, err := json.Marshal(internal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(newResult(internal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("converted result encodes as\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+package recentris
+
+import (
+	"time"
+
+	"github.com/re-centris/re-centris-go/internal/detector"
+)
+
+// Result is the detection result of a target file
+type Result struct {
+	// SchemaVersion is the version of the result schema, see SchemaVersion
+	SchemaVersion  string           `json:"schema_version"`
+	TargetFile     string           `json:"target_file"`
+	Digest         string           `json:"digest,omitempty"`
+	Hash           string           `json:"hash,omitempty"`
+	CorpusManifest string           `json:"corpus_manifest,omitempty"`
+	CorpusSnapshot string           `json:"corpus_snapshot,omitempty"`
+	Matches        []Match          `json:"matches"`
+	TotalFiles     int              `json:"total_files"`
+	MatchCount     int              `json:"match_count"`
+	Components     []ComponentMatch `json:"components,omitempty"`
+	// OmittedMatches is the number of matches dropped by the output limits
+	OmittedMatches int `json:"omitted_matches,omitempty"`
+	// Duplicates lists the other target files with the same content
+	Duplicates []string `json:"duplicates,omitempty"`
+	// ModifiedFunctions lists functions likely patched locally
+	ModifiedFunctions []ModifiedFunction `json:"modified_functions,omitempty"`
+	// Vulnerabilities lists known vulnerabilities of the matched components
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+	// License holds the licenses of the target and its matched components
+	License *LicenseInfo `json:"license,omitempty"`
+}
+
+// Match is a known file similar to a target file
+type Match struct {
+	File string `json:"file"`
+	// Component is the component the known file belongs to, if any
+	Component  string  `json:"component,omitempty"`
+	Similarity float64 `json:"similarity"`
+	Distance   int     `json:"distance"`
+	// Confidence combines the distance with the evidence of shared
+	// functions into a score between 0 and 1
+	Confidence float64 `json:"confidence,omitempty"`
+	// Hash is the TLSH digest of the known file
+	Hash string `json:"hash,omitempty"`
+	PURL string `json:"purl,omitempty"`
+	// CloneType tells exact, renamed and near-miss (modified) clones apart
+	CloneType string `json:"clone_type,omitempty"`
+	// Evidence lists the matched functions with their line ranges
+	Evidence    []Evidence   `json:"evidence,omitempty"`
+	Explanation *Explanation `json:"explanation,omitempty"`
+	// Internal marks a match of an owned component
+	Internal bool `json:"internal,omitempty"`
+	// FalsePositive marks a match labelled as a false positive
+	FalsePositive bool `json:"false_positive,omitempty"`
+	// Provenance tells which commit of the target introduced the match
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// LineRange is an inclusive range of lines of a file
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Evidence is a function of the target file matching a function of the
+// known file
+type Evidence struct {
+	Function      string    `json:"function"`
+	TargetLines   LineRange `json:"target_lines"`
+	KnownFunction string    `json:"known_function"`
+	KnownLines    LineRange `json:"known_lines"`
+	Distance      int       `json:"distance"`
+	// QualifiedFunction and KnownQualifiedFunction add the scope and
+	// signature to the names, if they are known
+	QualifiedFunction      string `json:"qualified_function,omitempty"`
+	KnownQualifiedFunction string `json:"known_qualified_function,omitempty"`
+	// Diff is a unified diff from the target to the known function
+	Diff string `json:"diff,omitempty"`
+}
+
+// Explanation describes why a known file matched
+type Explanation struct {
+	Reason             string  `json:"reason"`
+	Distance           int     `json:"distance"`
+	MaxDistance        int     `json:"max_distance"`
+	SharedFunctions    int     `json:"shared_functions"`
+	RareFunctions      int     `json:"rare_functions"`
+	MatchedLines       int     `json:"matched_lines"`
+	MeanFrequency      float64 `json:"mean_frequency,omitempty"`
+	ComponentFunctions int     `json:"component_functions"`
+	Summary            string  `json:"summary"`
+}
+
+// Provenance tells when and by whom the matched code of a target file was
+// introduced, from the git history of the target
+type Provenance struct {
+	Commit     string    `json:"commit"`
+	Author     string    `json:"author"`
+	Date       time.Time `json:"date"`
+	Summary    string    `json:"summary"`
+	Lines      int       `json:"lines"`
+	TotalLines int       `json:"total_lines"`
+}
+
+// ComponentMatch attributes a target file to a known component
+type ComponentMatch struct {
+	Component        string  `json:"component"`
+	PURL             string  `json:"purl,omitempty"`
+	MatchedFunctions int     `json:"matched_functions"`
+	Score            float64 `json:"score"`
+	// Version is set if the database has version signatures of the component
+	Version *ComponentVersion `json:"version,omitempty"`
+	// Internal marks an owned component
+	Internal bool `json:"internal,omitempty"`
+	// Metadata is set if it was fetched from the host of the repository
+	Metadata *ComponentMetadata `json:"metadata,omitempty"`
+}
+
+// ComponentVersion is the range of versions of a component the matched
+// functions are found in
+type ComponentVersion struct {
+	Range            string    `json:"range"`
+	First            string    `json:"first"`
+	FirstDate        time.Time `json:"first_date"`
+	Last             string    `json:"last"`
+	LastDate         time.Time `json:"last_date"`
+	MatchedFunctions int       `json:"matched_functions"`
+}
+
+// ComponentMetadata describes the repository of a component
+type ComponentMetadata struct {
+	Description       string     `json:"description,omitempty"`
+	License           string     `json:"license,omitempty"`
+	Stars             int        `json:"stars"`
+	Archived          bool       `json:"archived"`
+	PushedAt          *time.Time `json:"pushed_at,omitempty"`
+	LatestRelease     string     `json:"latest_release,omitempty"`
+	LatestReleaseDate *time.Time `json:"latest_release_date,omitempty"`
+	FetchedAt         time.Time  `json:"fetched_at"`
+}
+
+// ModifiedFunction is a target function similar but not identical to a
+// function of a known component
+type ModifiedFunction struct {
+	Function               string    `json:"function"`
+	Lines                  LineRange `json:"lines"`
+	Component              string    `json:"component"`
+	KnownFile              string    `json:"known_file"`
+	KnownFunction          string    `json:"known_function"`
+	KnownLines             LineRange `json:"known_lines"`
+	Distance               int       `json:"distance"`
+	QualifiedFunction      string    `json:"qualified_function,omitempty"`
+	KnownQualifiedFunction string    `json:"known_qualified_function,omitempty"`
+}
+
+// Vulnerability is a known vulnerability of a matched component
+type Vulnerability struct {
+	ID        string   `json:"id"`
+	Component string   `json:"component"`
+	Summary   string   `json:"summary,omitempty"`
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+// LicenseInfo holds the licenses of a target and its components
+type LicenseInfo struct {
+	Target     string            `json:"target,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+	Conflicts  []string          `json:"conflicts,omitempty"`
+}
+
+// newResults converts internal detection results
+func newResults(results []*detector.DetectionResult) []*Result {
+	converted := make([]*Result, len(results))
+	for i, r := range results {
+		converted[i] = newResult(r)
+	}
+	return converted
+}
+
+// newResult converts an internal detection result
+func newResult(r *detector.DetectionResult) *Result {
+	result := &Result{
+		SchemaVersion:  r.SchemaVersion,
+		TargetFile:     r.TargetFile,
+		Digest:         r.Digest,
+		Hash:           r.Hash,
+		CorpusManifest: r.CorpusManifest,
+		CorpusSnapshot: r.CorpusSnapshot,
+		Matches:        make([]Match, len(r.Matches)),
+		TotalFiles:     r.TotalFiles,
+		MatchCount:     r.MatchCount,
+		OmittedMatches: r.OmittedMatches,
+		Duplicates:     r.Duplicates,
+	}
+	for i, m := range r.Matches {
+		result.Matches[i] = newMatch(m)
+	}
+	for _, c := range r.Components {
+		result.Components = append(result.Components, newComponentMatch(c))
+	}
+	for _, fn := range r.ModifiedFunctions {
+		result.ModifiedFunctions = append(result.ModifiedFunctions, ModifiedFunction{
+			Function:               fn.Function,
+			Lines:                  LineRange{Start: fn.Lines.Start, End: fn.Lines.End},
+			Component:              fn.Component,
+			KnownFile:              fn.KnownFile,
+			KnownFunction:          fn.KnownFunction,
+			KnownLines:             LineRange{Start: fn.KnownLines.Start, End: fn.KnownLines.End},
+			Distance:               fn.Distance,
+			QualifiedFunction:      fn.QualifiedFunction,
+			KnownQualifiedFunction: fn.KnownQualifiedFunction,
+		})
+	}
+	for _, v := range r.Vulnerabilities {
+		result.Vulnerabilities = append(result.Vulnerabilities, Vulnerability{
+			ID:        v.ID,
+			Component: v.Component,
+			Summary:   v.Summary,
+			Aliases:   v.Aliases,
+		})
+	}
+	if r.License != nil {
+		result.License = &LicenseInfo{
+			Target:     r.License.Target,
+			Components: r.License.Components,
+			Conflicts:  r.License.Conflicts,
+		}
+	}
+	return result
+}
+
+// newMatch converts an internal match
+func newMatch(m detector.Match) Match {
+	match := Match{
+		File:          m.File,
+		Component:     m.Component,
+		Similarity:    m.Similarity,
+		Distance:      m.Distance,
+		Confidence:    m.Confidence,
+		Hash:          m.Hash,
+		PURL:          m.PURL,
+		CloneType:     m.CloneType,
+		Internal:      m.Internal,
+		FalsePositive: m.FalsePositive,
+	}
+	for _, e := range m.Evidence {
+		match.Evidence = append(match.Evidence, Evidence{
+			Function:               e.Function,
+			TargetLines:            LineRange{Start: e.TargetLines.Start, End: e.TargetLines.End},
+			KnownFunction:          e.KnownFunction,
+			KnownLines:             LineRange{Start: e.KnownLines.Start, End: e.KnownLines.End},
+			Distance:               e.Distance,
+			QualifiedFunction:      e.QualifiedFunction,
+			KnownQualifiedFunction: e.KnownQualifiedFunction,
+			Diff:                   e.Diff,
+		})
+	}
+	if e := m.Explanation; e != nil {
+		match.Explanation = &Explanation{
+			Reason:             e.Reason,
+			Distance:           e.Distance,
+			MaxDistance:        e.MaxDistance,
+			SharedFunctions:    e.SharedFunctions,
+			RareFunctions:      e.RareFunctions,
+			MatchedLines:       e.MatchedLines,
+			MeanFrequency:      e.MeanFrequency,
+			ComponentFunctions: e.ComponentFunctions,
+			Summary:            e.Summary,
+		}
+	}
+	if p := m.Provenance; p != nil {
+		match.Provenance = &Provenance{
+			Commit:     p.Commit,
+			Author:     p.Author,
+			Date:       p.Date,
+			Summary:    p.Summary,
+			Lines:      p.Lines,
+			TotalLines: p.TotalLines,
+		}
+	}
+	return match
+}
+
+// newComponentMatch converts an internal component match
+func newComponentMatch(c detector.ComponentMatch) ComponentMatch {
+	match := ComponentMatch{
+		Component:        c.Component,
+		PURL:             c.PURL,
+		MatchedFunctions: c.MatchedFunctions,
+		Score:            c.Score,
+		Internal:         c.Internal,
+	}
+	if v := c.Version; v != nil {
+		match.Version = &ComponentVersion{
+			Range:            v.Range,
+			First:            v.First,
+			FirstDate:        v.FirstDate,
+			Last:             v.Last,
+			LastDate:         v.LastDate,
+			MatchedFunctions: v.MatchedFunctions,
+		}
+	}
+	if m := c.Metadata; m != nil {
+		match.Metadata = &ComponentMetadata{
+			Description:       m.Description,
+			License:           m.License,
+			Stars:             m.Stars,
+			Archived:          m.Archived,
+			PushedAt:          m.PushedAt,
+			LatestRelease:     m.LatestRelease,
+			LatestReleaseDate: m.LatestReleaseDate,
+			FetchedAt:         m.FetchedAt,
+		}
+	}
+	return match
+}
@@ -0,0 +1,110 @@
+"""Ctags调用模块测试
+
+该模块包含了对ctags输出解析、内置函数提取和参数校验的单元测试。
+
+作者: byRen2002
+修改日期: 2025年3月
+许可证: MIT License
+"""
+
+import os
+import tempfile
+import unittest
+from unittest.mock import patch
+
+from core import ctags
+from core.ctags import (CtagsError, extract_functions, find_functions, functions_from_tags,
+                        parse_ctags_json, parse_ctags_output)
+
+# Universal Ctags 6.0 对C++文件的JSON输出
+JSON_SAMPLE = """{"_type": "tag", "name": "ns", "path": "/tmp/a.cpp", "pattern": "/^namespace ns {$/", "line": 3, "kind": "namespace", "end": 14}
+{"_type": "tag", "name": "Foo", "path": "/tmp/a.cpp", "pattern": "/^class Foo {$/", "line": 4, "kind": "class", "scope": "ns", "scopeKind": "namespace", "end": 8}
+{"_type": "tag", "name": "bar", "path": "/tmp/a.cpp", "pattern": "/^void Foo::bar() const {$/", "line": 10, "typeref": "typename:void", "kind": "function", "scope": "ns::Foo", "scopeKind": "class", "signature": "() const", "end": 12}
+{"_type": "tag", "name": "odd\\tname", "path": "/tmp/a.cpp", "pattern": "/^int odd(void)$/", "line": 16, "typeref": "typename:int", "kind": "function", "signature": "(void)", "end": 19}
+{"_type": "ptag", "name": "JSON_OUTPUT_VERSION", "path": "1.0", "pattern": "in development"}
+not json
+"""
+
+
+class TestCtags(unittest.TestCase):
+    """ctags模块的测试用例"""
+
+    def setUp(self):
+        """测试前清空查找缓存"""
+        ctags._found.clear()
+        ctags._json.clear()
+
+    def test_parse_ctags_output(self):
+        """测试解析ctags的函数起止行"""
+        output = (
+            "main\t/tmp/a.c\t/^int main(void)$/;\"\tfunction\tline:3\ttyperef:typename:int\tend:6\n"
+            "count\t/tmp/a.c\t/^static int count;$/;\"\tvariable\tline:1\ttyperef:typename:int\n"
+            "helper\t/tmp/a.c\t/^void helper(int x)$/;\"\tkind:function\tline:8\tsignature:(int x)\tend:10\n"
+        )
+        self.assertEqual(parse_ctags_output(output), [(3, 6), (8, 10)])
+
+    def test_parse_ctags_json(self):
+        """测试解析ctags的JSON输出"""
+        tags = parse_ctags_json(JSON_SAMPLE)
+        self.assertEqual([tag["name"] for tag in tags], ["ns", "Foo", "bar", "odd\tname"])
+        self.assertEqual(tags[2]["scope"], "ns::Foo")
+        self.assertEqual(tags[2]["scope_kind"], "class")
+        self.assertIsNone(tags[3]["scope"])
+        self.assertEqual(functions_from_tags(tags), [(10, 12), (16, 19)])
+
+    def test_extract_functions_json(self):
+        """测试支持JSON输出时使用JSON格式"""
+        with tempfile.NamedTemporaryFile("w", suffix=".cpp", delete=False) as f:
+            f.write("int main() {}\n")
+        try:
+            with patch("core.ctags.supports_json", return_value=True), \
+                    patch("core.ctags.run_ctags", return_value=JSON_SAMPLE) as run:
+                self.assertEqual(extract_functions(f.name, ctags="/usr/bin/ctags"), [(10, 12), (16, 19)])
+                self.assertTrue(run.call_args.kwargs["json_output"])
+        finally:
+            os.remove(f.name)
+
+    def test_find_functions(self):
+        """测试不依赖ctags的函数提取"""
+        content = (
+            "#include <stdio.h>\n"
+            "/* int fake(void) { } */\n"
+            "static int add(int a, int b)\n"
+            "{\n"
+            "    if (a) { return a + b; }\n"
+            "    return b;\n"
+            "}\n"
+            "namespace ns {\n"
+            "void Foo::bar() const {\n"
+            "    printf(\"}\");\n"
+            "}\n"
+            "}\n"
+        )
+        self.assertEqual(find_functions(content), [(3, 7), (9, 11)])
+
+    def test_run_ctags_rejects_paths(self):
+        """测试拒绝非法的文件路径"""
+        with self.assertRaises(CtagsError):
+            ctags.run_ctags("/bin/true", "a.c\n--options=/etc/passwd")
+        with self.assertRaises(CtagsError):
+            ctags.run_ctags("/bin/true", tempfile.gettempdir())
+
+    def test_find_ctags_allowlist(self):
+        """测试不在白名单中的程序不会被执行"""
+        with patch("core.ctags.shutil.which", return_value="/bin/sh"), \
+                patch("core.ctags.subprocess.run") as run:
+            self.assertIsNone(ctags.find_ctags("sh"))
+            run.assert_not_called()
+
+    def test_extract_functions_fallback(self):
+        """测试没有ctags时使用内置的函数提取"""
+        with tempfile.NamedTemporaryFile("w", suffix=".c", delete=False) as f:
+            f.write("int main(void) {\n    return 0;\n}\n")
+        try:
+            self.assertEqual(extract_functions(f.name), [(1, 3)])
+        finally:
+            os.remove(f.name)
+
+
+if __name__ == "__main__":
+    unittest.main()